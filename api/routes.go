@@ -3,10 +3,11 @@ package api
 import (
 	"air-quality-server/internal/config"
 	"air-quality-server/internal/handlers"
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
 	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,86 +15,187 @@ import (
 // SetupAPIRoutes 设置API路由
 func SetupAPIRoutes(router *gin.Engine, handlers *handlers.Handlers, services *services.Services, cfg *config.Config, logger utils.Logger) {
 	// 健康检查
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "ok",
-			"timestamp": time.Now().Unix(),
-			"service":   cfg.Service.Name,
-			"version":   cfg.Service.Version,
-		})
-	})
+	router.GET("/health", handlers.Health.Check)
 	router.HEAD("/health", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
 
-	// API路由组
+	// API路由组（登录和刷新令牌外均需认证）
 	api := router.Group("/api/v1")
+	{
+		// 认证
+		api.POST("/auth/login", handlers.User.Login)
+		api.POST("/auth/refresh", handlers.User.RefreshToken)
+
+		// 固件下载（设备凭签名链接访问）
+		api.GET("/firmware/:id/download", handlers.Firmware.DownloadFirmware)
+	}
+
+	secured := api.Group("", middleware.Auth(services.Auth))
+
+	// perm 权限校验中间件（分组要求读权限，写操作另行声明）
+	perm := func(permissions ...string) gin.HandlerFunc {
+		return middleware.RequirePermission(services.Role, permissions...)
+	}
+
 	{
 		// 设备管理
-		devices := api.Group("/devices")
+		devices := secured.Group("/devices", perm(models.PermissionDeviceRead))
 		{
 			devices.GET("", handlers.Device.ListDevices)
-			devices.POST("", handlers.Device.CreateDevice)
+			devices.POST("", perm(models.PermissionDeviceWrite), handlers.Device.CreateDevice)
+			devices.POST("/import", perm(models.PermissionDeviceWrite), handlers.Device.ImportDevices)
+			devices.GET("/export", handlers.Device.ExportDevices)
+			devices.GET("/pending", handlers.Provisioning.ListPending)
+			devices.POST("/pending/:id/approve", perm(models.PermissionDeviceWrite), handlers.Provisioning.ApprovePending)
+			devices.DELETE("/pending/:id", perm(models.PermissionDeviceWrite), handlers.Provisioning.RejectPending)
 			devices.GET("/:id", handlers.Device.GetDevice)
-			devices.PUT("/:id", handlers.Device.UpdateDevice)
-			devices.DELETE("/:id", handlers.Device.DeleteDevice)
+			devices.PUT("/:id", perm(models.PermissionDeviceWrite), handlers.Device.UpdateDevice)
+			devices.DELETE("/:id", perm(models.PermissionDeviceDelete), handlers.Device.DeleteDevice)
 			devices.GET("/:id/status", handlers.Device.GetDeviceStatus)
+			devices.GET("/:id/config", handlers.Device.GetConfigSync)
+			devices.GET("/:id/shadow", handlers.Device.GetShadow)
+			devices.GET("/:id/credentials", handlers.Device.GetCredential)
+			devices.POST("/:id/credentials", perm(models.PermissionDeviceWrite), handlers.Device.IssueCredential)
+			devices.PUT("/:id/credentials", perm(models.PermissionDeviceWrite), handlers.Device.RotateCredential)
+			devices.DELETE("/:id/credentials", perm(models.PermissionDeviceWrite), handlers.Device.RevokeCredential)
+			devices.GET("/:id/commands", handlers.DeviceCommand.ListCommands)
+			devices.POST("/:id/commands", perm(models.PermissionDeviceWrite), handlers.DeviceCommand.SendCommand)
+			devices.GET("/:id/commands/:command_id", handlers.DeviceCommand.GetCommand)
 			// devices.PUT("/:id/status", handlers.Device.UpdateDeviceStatus) // 方法未实现
 			// devices.GET("/:id/statistics", handlers.Device.GetDeviceStatistics) // 方法未实现
 		}
 
+		// 位置层级（站点→楼栋→楼层→房间）
+		locations := secured.Group("/locations", perm(models.PermissionDeviceRead))
+		{
+			locations.GET("", handlers.Location.ListLocations)
+			locations.GET("/tree", handlers.Location.GetTree)
+			locations.POST("", perm(models.PermissionDeviceWrite), handlers.Location.CreateLocation)
+			locations.GET("/:id", handlers.Location.GetLocation)
+			locations.PUT("/:id", perm(models.PermissionDeviceWrite), handlers.Location.UpdateLocation)
+			locations.DELETE("/:id", perm(models.PermissionDeviceWrite), handlers.Location.DeleteLocation)
+			locations.POST("/:id/devices", perm(models.PermissionDeviceWrite), handlers.Location.AssignDevices)
+			locations.DELETE("/:id/devices", perm(models.PermissionDeviceWrite), handlers.Location.UnassignDevices)
+		}
+
+		// 设备分组
+		deviceGroups := secured.Group("/device-groups", perm(models.PermissionDeviceRead))
+		{
+			deviceGroups.GET("", handlers.DeviceGroup.ListGroups)
+			deviceGroups.POST("", perm(models.PermissionDeviceWrite), handlers.DeviceGroup.CreateGroup)
+			deviceGroups.GET("/:id", handlers.DeviceGroup.GetGroup)
+			deviceGroups.PUT("/:id", perm(models.PermissionDeviceWrite), handlers.DeviceGroup.UpdateGroup)
+			deviceGroups.DELETE("/:id", perm(models.PermissionDeviceWrite), handlers.DeviceGroup.DeleteGroup)
+			deviceGroups.POST("/:id/devices", perm(models.PermissionDeviceWrite), handlers.DeviceGroup.AddDevices)
+			deviceGroups.DELETE("/:id/devices", perm(models.PermissionDeviceWrite), handlers.DeviceGroup.RemoveDevices)
+		}
+
+		// 固件管理
+		firmware := secured.Group("/firmware", perm(models.PermissionDeviceRead))
+		{
+			firmware.GET("", handlers.Firmware.ListFirmware)
+			firmware.POST("", perm(models.PermissionDeviceWrite), handlers.Firmware.UploadFirmware)
+			firmware.GET("/:id", handlers.Firmware.GetFirmware)
+		}
+
+		// 固件升级活动
+		campaigns := secured.Group("/firmware-campaigns", perm(models.PermissionDeviceRead))
+		{
+			campaigns.GET("", handlers.Firmware.ListCampaigns)
+			campaigns.POST("", perm(models.PermissionDeviceWrite), handlers.Firmware.CreateCampaign)
+			campaigns.GET("/:id", handlers.Firmware.GetCampaign)
+			campaigns.POST("/:id/start", perm(models.PermissionDeviceWrite), handlers.Firmware.StartCampaign)
+			campaigns.POST("/:id/pause", perm(models.PermissionDeviceWrite), handlers.Firmware.PauseCampaign)
+			campaigns.GET("/:id/updates", handlers.Firmware.ListUpdates)
+		}
+
 		// 数据管理
-		data := api.Group("/data")
+		data := secured.Group("/data")
 		{
-			data.POST("/upload", handlers.AirQuality.UploadData)
-			data.GET("/realtime/:device_id", handlers.AirQuality.GetRealtimeData)
-			data.GET("/history/:device_id", handlers.AirQuality.GetHistoryData)
-			data.GET("/statistics/:device_id", handlers.AirQuality.GetStatistics)
-			data.GET("/export/:device_id", handlers.AirQuality.ExportData)
+			data.POST("/upload", perm(models.PermissionDataWrite), handlers.SensorData.UploadData)
+			data.GET("/realtime/:device_id", perm(models.PermissionDataRead), handlers.AirQuality.GetRealtimeData)
+			data.GET("/history/:device_id", perm(models.PermissionDataRead), handlers.AirQuality.GetHistoryData)
+			data.GET("/query", perm(models.PermissionDataRead), handlers.SensorData.QueryData)
+			data.GET("/statistics", perm(models.PermissionDataRead), handlers.SensorData.GetStatistics)
+			data.GET("/statistics/:device_id", perm(models.PermissionDataRead), handlers.AirQuality.GetStatistics)
+			data.GET("/aqi/:device_id", perm(models.PermissionDataRead), handlers.SensorData.GetAQI)
+			data.GET("/export/:device_id", perm(models.PermissionDataExport), handlers.AirQuality.ExportData)
 		}
 
 		// 用户管理
-		users := api.Group("/users")
+		users := secured.Group("/users", perm(models.PermissionUserRead))
 		{
 			users.GET("", handlers.User.ListUsers)
-			users.POST("", handlers.User.CreateUser)
+			users.POST("", perm(models.PermissionUserWrite), handlers.User.CreateUser)
 			users.GET("/:id", handlers.User.GetUser)
-			users.PUT("/:id", handlers.User.UpdateUser)
-			users.DELETE("/:id", handlers.User.DeleteUser)
+			users.PUT("/:id", perm(models.PermissionUserWrite), handlers.User.UpdateUser)
+			users.DELETE("/:id", perm(models.PermissionUserDelete), handlers.User.DeleteUser)
+		}
+
+		// 角色管理
+		roles := secured.Group("/roles", perm(models.PermissionRoleRead))
+		{
+			roles.GET("", handlers.Role.ListRoles)
+			roles.POST("", perm(models.PermissionRoleWrite), handlers.Role.CreateRole)
+			roles.GET("/:id", handlers.Role.GetRole)
+			roles.PUT("/:id", perm(models.PermissionRoleWrite), handlers.Role.UpdateRole)
+			roles.DELETE("/:id", perm(models.PermissionRoleDelete), handlers.Role.DeleteRole)
 		}
 
 		// 认证
-		auth := api.Group("/auth")
+		auth := secured.Group("/auth")
 		{
-			auth.POST("/login", handlers.User.Login)
 			auth.POST("/logout", handlers.User.Logout)
 			auth.POST("/change-password", handlers.User.ChangePassword)
 		}
 
 		// 告警管理
-		alerts := api.Group("/alerts")
+		alerts := secured.Group("/alerts", perm(models.PermissionAlertRead))
 		{
 			alerts.GET("", handlers.Alert.ListAlerts)
-			alerts.POST("", handlers.Alert.CreateAlert)
+			alerts.POST("", perm(models.PermissionAlertWrite), handlers.Alert.CreateAlert)
 			alerts.GET("/:id", handlers.Alert.GetAlert)
-			alerts.PUT("/:id", handlers.Alert.UpdateAlert)
-			alerts.DELETE("/:id", handlers.Alert.DeleteAlert)
+			alerts.PUT("/:id", perm(models.PermissionAlertWrite), handlers.Alert.UpdateAlert)
+			alerts.DELETE("/:id", perm(models.PermissionAlertWrite), handlers.Alert.DeleteAlert)
 			alerts.GET("/device/:device_id", handlers.Alert.GetAlertsByDevice)
-			alerts.POST("/:id/resolve", handlers.Alert.ResolveAlert)
+			alerts.POST("/:id/ack", perm(models.PermissionAlertAck), handlers.Alert.AcknowledgeAlert)
+			alerts.POST("/:id/resolve", perm(models.PermissionAlertResolve), handlers.Alert.ResolveAlert)
+			alerts.GET("/:id/history", handlers.Alert.GetAlertHistory)
 			alerts.GET("/unresolved", handlers.Alert.GetUnresolvedAlerts)
 		}
 
+		// 告警规则管理
+		alertRules := secured.Group("/alert-rules", perm(models.PermissionAlertRead))
+		{
+			alertRules.GET("", handlers.AlertRule.ListRules)
+			alertRules.POST("", perm(models.PermissionAlertWrite), handlers.AlertRule.CreateRule)
+			alertRules.GET("/:id", handlers.AlertRule.GetRule)
+			alertRules.PUT("/:id", perm(models.PermissionAlertWrite), handlers.AlertRule.UpdateRule)
+			alertRules.DELETE("/:id", perm(models.PermissionAlertWrite), handlers.AlertRule.DeleteRule)
+		}
+
 		// 配置管理
-		configs := api.Group("/configs")
+		configs := secured.Group("/configs", perm(models.PermissionSystemRead))
 		{
 			configs.GET("", handlers.Config.GetAllConfigs)
 			configs.GET("/:key", handlers.Config.GetConfig)
-			configs.POST("/:key", handlers.Config.SetConfig)
-			configs.PUT("/:key", handlers.Config.UpdateConfig)
-			configs.DELETE("/:key", handlers.Config.DeleteConfig)
+			configs.POST("/:key", perm(models.PermissionSystemConfig), handlers.Config.SetConfig)
+			configs.PUT("/:key", perm(models.PermissionSystemConfig), handlers.Config.UpdateConfig)
+			configs.DELETE("/:key", perm(models.PermissionSystemConfig), handlers.Config.DeleteConfig)
 			configs.GET("/category/:category", handlers.Config.GetConfigsByCategory)
 			configs.GET("/system/settings", handlers.Config.GetSystemSettings)
-			configs.PUT("/system/settings", handlers.Config.UpdateSystemSettings)
+			configs.PUT("/system/settings", perm(models.PermissionSystemConfig), handlers.Config.UpdateSystemSettings)
+		}
+
+		// MQTT访问控制规则
+		aclRules := secured.Group("/mqtt/acl-rules", perm(models.PermissionSystemRead))
+		{
+			aclRules.GET("", handlers.MQTTACL.ListRules)
+			aclRules.POST("", perm(models.PermissionSystemWrite), handlers.MQTTACL.CreateRule)
+			aclRules.GET("/:id", handlers.MQTTACL.GetRule)
+			aclRules.PUT("/:id", perm(models.PermissionSystemWrite), handlers.MQTTACL.UpdateRule)
+			aclRules.DELETE("/:id", perm(models.PermissionSystemWrite), handlers.MQTTACL.DeleteRule)
 		}
 	}
 
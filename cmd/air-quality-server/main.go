@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"air-quality-server/internal/aqi"
 	"air-quality-server/internal/config"
 	"air-quality-server/internal/handlers"
 	"air-quality-server/internal/mqtt"
@@ -123,6 +124,11 @@ func main() {
 	}
 	defer db.Close()
 
+	// 已有数据库中的内置角色补充新增的默认权限
+	if err := utils.MigrateRolePermissions(db.DB, logger); err != nil {
+		logger.Warn("合并内置角色权限失败", utils.ErrorField(err))
+	}
+
 	// 初始化Redis
 	redis, err := utils.NewRedis(&cfg.Redis, logger)
 	if err != nil {
@@ -136,16 +142,50 @@ func main() {
 	repos := initRepositories(db.DB, logger)
 
 	// 初始化服务层
-	svcs := initServices(repos, redis, logger)
+	svcs := initServices(cfg, repos, redis, logger)
+	if svcs.Notification != nil {
+		svcs.Notification.Start()
+		defer svcs.Notification.Stop()
+	}
+	svcs.DevicePresence.Start()
+	defer svcs.DevicePresence.Stop()
+	svcs.DeviceCommand.Start()
+	defer svcs.DeviceCommand.Stop()
+	svcs.FirmwareCampaign.Start()
+	defer svcs.FirmwareCampaign.Stop()
+	if svcs.SensorDataRollup != nil {
+		svcs.SensorDataRollup.Start()
+		defer svcs.SensorDataRollup.Stop()
+	}
+	if svcs.SensorDataArchive != nil {
+		svcs.SensorDataArchive.Start()
+		defer svcs.SensorDataArchive.Stop()
+	}
+	if svcs.Retention != nil {
+		svcs.Retention.Start()
+		defer svcs.Retention.Stop()
+	}
+
+	// 初始化落盘缓冲
+	spool := initSpool(cfg, db, repos, logger)
+	if spool != nil {
+		defer spool.Close()
+	}
 
 	// 初始化MQTT服务器
-	mqttServer := initMQTTServer(cfg, logger, repos, svcs)
+	mqttServer := initMQTTServer(cfg, logger, repos, svcs, spool)
 	if mqttServer != nil {
 		defer mqttServer.Stop()
+		if svcs.Notification != nil {
+			svcs.Notification.Register(services.NewMQTTNotifier(mqttServer, cfg.Notification.MQTTTopic))
+		}
+		svcs.DeviceCommand.SetPublisher(mqttServer)
+		svcs.DeviceConfig.SetPublisher(mqttServer)
+		svcs.FirmwareCampaign.SetPublisher(mqttServer)
 	}
 
 	// 初始化处理器
-	handlers := initHandlers(svcs, logger)
+	handlers := initHandlers(cfg, db, spool, svcs, logger)
 
 	// 初始化路由
 	router := router.InitRouter(handlers, svcs, cfg, logger)
@@ -219,28 +259,173 @@ func loadConfig() (*config.Config, error) {
 func initRepositories(db *gorm.DB, logger utils.Logger) *repositories.Repositories {
 	return &repositories.Repositories{
 		Device:            repositories.NewDeviceRepository(db, logger),
+		DeviceCredential:  repositories.NewDeviceCredentialRepository(db, logger),
 		AirQuality:        repositories.NewAirQualityRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		User:              repositories.NewUserRepository(db, logger),
+		Role:              repositories.NewRoleRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 		Config:            repositories.NewConfigRepository(db, logger),
+		MQTTACLRule:       repositories.NewMQTTACLRuleRepository(db, logger),
+		NotificationLog:   repositories.NewNotificationLogRepository(db, logger),
+		RevokedToken:      repositories.NewRevokedTokenRepository(db, logger),
+		DeviceRuntime:     repositories.NewDeviceRuntimeStatusRepository(db, logger),
+		DeviceCommand:     repositories.NewDeviceCommandRepository(db, logger),
+		DeviceConfigState: repositories.NewDeviceConfigStateRepository(db, logger),
+		DeviceShadow:      repositories.NewDeviceShadowRepository(db, logger),
+		Firmware:          repositories.NewFirmwareRepository(db, logger),
+		FirmwareCampaign:  repositories.NewFirmwareCampaignRepository(db, logger),
+		FirmwareUpdate:    repositories.NewFirmwareUpdateRepository(db, logger),
+		PendingDevice:     repositories.NewPendingDeviceRepository(db, logger),
+		Location:          repositories.NewLocationRepository(db, logger),
+		DeviceGroup:       repositories.NewDeviceGroupRepository(db, logger),
+		SensorDataRollup:  repositories.NewSensorDataRollupRepository(db, logger),
+		Retention:         repositories.NewRetentionRepository(db, logger),
+		SensorDataArchive: repositories.NewSensorDataArchiveRepository(db, logger),
 	}
 }
 
 // initServices 初始化服务层
-func initServices(repos *repositories.Repositories, redis *utils.Redis, logger utils.Logger) *services.Services {
+func initServices(cfg *config.Config, repos *repositories.Repositories, redis *utils.Redis, logger utils.Logger) *services.Services {
+	configService := services.NewConfigService(repos.Config, logger)
+	userService := services.NewUserService(repos.User, logger)
+	alertRuleService := services.NewAlertRuleService(repos.AlertRule, logger)
+
+	var dispatcher services.NotificationDispatcher
+	if cfg.Notification.Enabled {
+		dispatcher = services.NewNotificationDispatcher(cfg.Notification, repos.NotificationLog, repos.Alert, alertRuleService, configService, logger)
+	} else {
+		logger.Warn("告警通知已禁用")
+	}
+
+	alertService := services.NewAlertService(repos.Alert, dispatcher, logger)
+	scopeService := services.NewDeviceScopeService(repos.Device, repos.Location, repos.DeviceGroup, logger)
+	alertEvaluator := services.NewAlertEvaluator(alertRuleService, alertService, scopeService, logger)
+	deviceConfigService := services.NewDeviceConfigService(repos.DeviceConfigState, repos.Device, logger)
+	deviceShadowService := services.NewDeviceShadowService(repos.DeviceShadow, repos.Device, repos.DeviceRuntime, deviceConfigService, redis, logger)
+	firmwareService := services.NewFirmwareService(firmwareConfig(cfg, logger), repos.Firmware, logger)
+	provisioningService := services.NewDeviceProvisioningService(cfg.Provisioning, repos.Device, repos.PendingDevice, repos.UnifiedSensorData, deviceShadowService, logger)
+	logger.Info("设备接入策略", utils.String("mode", string(provisioningService.Mode())))
+
+	var rollupService services.SensorDataRollupService
+	var rollupRepo repositories.SensorDataRollupRepository
+	if cfg.Rollup.Enabled {
+		rollupService = services.NewSensorDataRollupService(cfg.Rollup, repos.SensorDataRollup, logger)
+		rollupRepo = repos.SensorDataRollup
+	} else {
+		logger.Warn("传感器数据汇总已禁用，统计和图表查询将直接扫描原始数据")
+	}
+
+	var archiveService services.SensorDataArchiveService
+	if cfg.Archive.Enabled {
+		archiveService = services.NewSensorDataArchiveService(archiveConfig(cfg), repos.SensorDataArchive, logger)
+	}
+
+	var retentionService services.RetentionService
+	if cfg.Retention.Enabled {
+		retentionService = services.NewRetentionService(cfg.Retention, repos.Retention, configService, rollupRepo, archiveService, logger)
+	} else {
+		logger.Warn("数据保留任务已禁用，过期数据不会被清理")
+	}
+
+	// AQI标准已在加载配置时校验
+	aqiStandard := aqi.Standard(cfg.AQI.Standard)
+
+	devicePresenceService := services.NewDevicePresenceService(cfg.MQTT.Device, repos.Device, repos.DeviceRuntime, alertService, logger)
+
 	return &services.Services{
-		Device:            services.NewDeviceService(repos.Device, logger),
-		AirQuality:        services.NewAirQualityService(repos.AirQuality, repos.Device, logger),
-		UnifiedSensorData: services.NewUnifiedSensorDataService(repos.UnifiedSensorData, repos.Device, services.NewAlertService(repos.Alert, logger), logger),
-		User:              services.NewUserService(repos.User, logger),
-		Alert:             services.NewAlertService(repos.Alert, logger),
-		Config:            services.NewConfigService(repos.Config, logger),
+		Device:            services.NewDeviceService(repos.Device, deviceConfigService, logger),
+		DeviceCredential:  services.NewDeviceCredentialService(repos.DeviceCredential, repos.Device, logger),
+		AirQuality:        services.NewAirQualityService(repos.AirQuality, repos.Device, aqiStandard, logger),
+		UnifiedSensorData: services.NewUnifiedSensorDataService(repos.UnifiedSensorData, repos.Device, alertEvaluator, devicePresenceService, deviceShadowService, provisioningService, rollupRepo, aqiStandard, logger),
+		User:              userService,
+		Auth:              services.NewAuthService(cfg.JWT, userService, repos.RevokedToken, redis, logger),
+		Role:              services.NewRoleService(repos.Role, logger),
+		Alert:             alertService,
+		AlertRule:         alertRuleService,
+		AlertEvaluator:    alertEvaluator,
+		Notification:      dispatcher,
+		Config:            configService,
+		MQTTACL:           services.NewMQTTACLService(repos.MQTTACLRule, logger),
+		DevicePresence:    devicePresenceService,
+		DeviceCommand:     services.NewDeviceCommandService(repos.DeviceCommand, repos.Device, logger),
+		DeviceConfig:      deviceConfigService,
+		DeviceShadow:      deviceShadowService,
+		Firmware:          firmwareService,
+		FirmwareCampaign: services.NewFirmwareCampaignService(cfg.Firmware, repos.FirmwareCampaign, repos.FirmwareUpdate,
+			repos.Device, repos.DeviceRuntime, firmwareService, logger),
+		DeviceProvisioning: provisioningService,
+		DeviceScope:        scopeService,
+		Location:           services.NewLocationService(repos.Location, repos.Device, scopeService, logger),
+		DeviceGroup:        services.NewDeviceGroupService(repos.DeviceGroup, repos.Device, scopeService, logger),
+		SensorDataRollup:   rollupService,
+		Retention:          retentionService,
+		SensorDataArchive:  archiveService,
+	}
+}
+
+// archiveConfig 将相对的归档目录解析到项目根目录下
+func archiveConfig(cfg *config.Config) config.ArchiveConfig {
+	archiveCfg := cfg.Archive
+	if archiveCfg.Dir == "" {
+		archiveCfg.Dir = "data/archive"
+	}
+	if !filepath.IsAbs(archiveCfg.Dir) {
+		archiveCfg.Dir = filepath.Join(getProjectRoot(), archiveCfg.Dir)
+	}
+	return archiveCfg
+}
+
+// firmwareConfig 补全固件存储目录、下载地址和签名密钥
+func firmwareConfig(cfg *config.Config, logger utils.Logger) config.FirmwareConfig {
+	firmwareCfg := cfg.Firmware
+	if firmwareCfg.Dir == "" {
+		firmwareCfg.Dir = "data/firmware"
 	}
+	if !filepath.IsAbs(firmwareCfg.Dir) {
+		firmwareCfg.Dir = filepath.Join(getProjectRoot(), firmwareCfg.Dir)
+	}
+	if firmwareCfg.PublicBaseURL == "" {
+		firmwareCfg.PublicBaseURL = "http://" + cfg.GetServerAddr()
+		logger.Warn("未配置firmware.public_base_url，设备可能无法访问固件下载链接",
+			utils.String("base_url", firmwareCfg.PublicBaseURL))
+	}
+	if firmwareCfg.URLSecret == "" {
+		firmwareCfg.URLSecret = cfg.JWT.DeriveSecret("firmware-download-url")
+	}
+	return firmwareCfg
+}
+
+// initSpool 初始化落盘缓冲并启动回放协程
+func initSpool(cfg *config.Config, db *utils.Database, repos *repositories.Repositories, logger utils.Logger) *utils.SensorDataSpool {
+	if !cfg.Spool.Enabled {
+		logger.Warn("落盘缓冲已禁用，数据库不可用时传感器数据将丢失")
+		return nil
+	}
+
+	dir := cfg.Spool.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(getProjectRoot(), dir)
+	}
+
+	spool, err := utils.NewSensorDataSpool(dir, int64(cfg.Spool.SegmentSize)<<20, logger)
+	if err != nil {
+		logger.Error("初始化落盘缓冲失败", utils.ErrorField(err))
+		return nil
+	}
+
+	interval := time.Duration(cfg.Spool.ReplayInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	spool.StartReplayer(db.Ping, repos.UnifiedSensorData.BatchInsert, interval, cfg.Spool.ReplayBatchSize)
+
+	return spool
 }
 
 // initMQTTServer 初始化MQTT服务器
-func initMQTTServer(cfg *config.Config, logger utils.Logger, repos *repositories.Repositories, svcs *services.Services) *mqtt.Server {
+func initMQTTServer(cfg *config.Config, logger utils.Logger, repos *repositories.Repositories, svcs *services.Services, spool *utils.SensorDataSpool) *mqtt.Server {
 	// 检查MQTT配置
 	if cfg.MQTT.Broker == "" {
 		logger.Warn("MQTT配置为空，跳过MQTT服务器启动")
@@ -251,12 +436,24 @@ func initMQTTServer(cfg *config.Config, logger utils.Logger, repos *repositories
 	sensorDataHandler := mqtt.NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		svcs.AlertEvaluator,
 		logger,
 	)
+	if spool != nil {
+		sensorDataHandler.SetSpool(spool)
+	}
+	sensorDataHandler.SetPresenceService(svcs.DevicePresence)
+	sensorDataHandler.SetShadowService(svcs.DeviceShadow)
+	sensorDataHandler.SetProvisioningService(svcs.DeviceProvisioning)
 
 	// 创建MQTT服务器
 	mqttServer := mqtt.NewServer(&cfg.MQTT, logger, sensorDataHandler)
+	mqttServer.SetCredentialService(svcs.DeviceCredential)
+	mqttServer.SetACLService(svcs.MQTTACL)
+	mqttServer.SetPresenceService(svcs.DevicePresence)
+	mqttServer.SetCommandService(svcs.DeviceCommand)
+	mqttServer.SetConfigService(svcs.DeviceConfig)
+	mqttServer.SetFirmwareService(svcs.FirmwareCampaign)
 
 	// 启动MQTT服务器windo
 	if err := mqttServer.Start(); err != nil {
@@ -272,12 +469,22 @@ func initMQTTServer(cfg *config.Config, logger utils.Logger, repos *repositories
 }
 
 // initHandlers 初始化处理器
-func initHandlers(svcs *services.Services, logger utils.Logger) *handlers.Handlers {
+func initHandlers(cfg *config.Config, db *utils.Database, spool *utils.SensorDataSpool, svcs *services.Services, logger utils.Logger) *handlers.Handlers {
 	return &handlers.Handlers{
-		Device:     handlers.NewDeviceHandler(svcs.Device, logger),
-		AirQuality: handlers.NewAirQualityHandler(svcs.AirQuality, logger),
-		User:       handlers.NewUserHandler(svcs.User, logger),
-		Alert:      handlers.NewAlertHandler(svcs.Alert, logger),
-		Config:     handlers.NewConfigHandler(svcs.Config, logger),
+		Device:        handlers.NewDeviceHandler(svcs.Device, svcs.DeviceCredential, svcs.DevicePresence, svcs.DeviceConfig, svcs.DeviceShadow, svcs.DeviceScope, logger),
+		DeviceCommand: handlers.NewDeviceCommandHandler(svcs.DeviceCommand, logger),
+		Provisioning:  handlers.NewDeviceProvisioningHandler(svcs.DeviceProvisioning, logger),
+		Location:      handlers.NewLocationHandler(svcs.Location, logger),
+		DeviceGroup:   handlers.NewDeviceGroupHandler(svcs.DeviceGroup, logger),
+		Firmware:      handlers.NewFirmwareHandler(svcs.Firmware, svcs.FirmwareCampaign, logger),
+		AirQuality:    handlers.NewAirQualityHandler(svcs.AirQuality, logger),
+		SensorData:    handlers.NewSensorDataHandler(svcs.UnifiedSensorData, svcs.DeviceScope, logger),
+		User:          handlers.NewUserHandler(svcs.User, svcs.Auth, svcs.Role, logger),
+		Role:          handlers.NewRoleHandler(svcs.Role, logger),
+		Alert:         handlers.NewAlertHandler(svcs.Alert, logger),
+		AlertRule:     handlers.NewAlertRuleHandler(svcs.AlertRule, logger),
+		Config:        handlers.NewConfigHandler(svcs.Config, logger),
+		MQTTACL:       handlers.NewMQTTACLHandler(svcs.MQTTACL, logger),
+		Health:        handlers.NewHealthHandler(cfg.Service, db, spool),
 	}
 }
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"air-quality-server/internal/config"
 	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
 
 	"gorm.io/gorm"
@@ -16,7 +20,11 @@ import (
 func main() {
 	var (
 		configPath = flag.String("config", "", "配置文件路径")
-		action     = flag.String("action", "init", "操作类型: init, status")
+		action     = flag.String("action", "init", "操作类型: init, status, purge, archive, restore")
+		dryRun     = flag.Bool("dry-run", false, "purge时只统计将被删除的数据，不实际删除")
+		from       = flag.String("from", "", "restore的开始日期（UTC，格式2006-01-02）")
+		to         = flag.String("to", "", "restore的结束日期（UTC，含当天），默认与开始日期相同")
+		deviceID   = flag.String("device", "", "restore只恢复指定设备，默认全部设备")
 		help       = flag.Bool("help", false, "显示帮助信息")
 	)
 	flag.Parse()
@@ -58,6 +66,18 @@ func main() {
 		if err := showStatus(db.DB, logger); err != nil {
 			logger.Fatal("获取状态失败", utils.ErrorField(err))
 		}
+	case "purge":
+		if err := runPurge(cfg, db.DB, *dryRun, logger); err != nil {
+			logger.Fatal("清理过期数据失败", utils.ErrorField(err))
+		}
+	case "archive":
+		if err := runArchive(cfg, db.DB, logger); err != nil {
+			logger.Fatal("归档数据失败", utils.ErrorField(err))
+		}
+	case "restore":
+		if err := runRestore(cfg, db.DB, *from, *to, *deviceID, logger); err != nil {
+			logger.Fatal("恢复归档数据失败", utils.ErrorField(err))
+		}
 	default:
 		fmt.Printf("❌ 未知操作: %s\n", *action)
 		showHelp()
@@ -159,6 +179,11 @@ func runInit(db *gorm.DB, logger utils.Logger) error {
 		return fmt.Errorf("插入初始数据失败: %w", err)
 	}
 
+	// 已有数据库中的内置角色补充新增的默认权限
+	if err := utils.MigrateRolePermissions(db, logger); err != nil {
+		return fmt.Errorf("合并内置角色权限失败: %w", err)
+	}
+
 	logger.Info("数据库初始化完成")
 	return nil
 }
@@ -170,8 +195,8 @@ func showStatus(db *gorm.DB, logger utils.Logger) error {
 	// 检查表是否存在
 	tables := []string{
 		"users", "roles", "user_roles",
-		"devices", "unified_sensor_data", "device_runtime_status",
-		"alerts", "alert_rules", "system_configs",
+		"devices", "unified_sensor_data", "device_runtime_status", "device_credentials",
+		"mqtt_acl_rules", "alerts", "alert_rules", "system_configs",
 	}
 
 	for _, table := range tables {
@@ -201,17 +226,135 @@ func showStatus(db *gorm.DB, logger utils.Logger) error {
 	return nil
 }
 
+// runPurge 按保留策略清理过期数据，与主程序的定时清理使用相同的策略
+func runPurge(cfg *config.Config, db *gorm.DB, dryRun bool, logger utils.Logger) error {
+	if dryRun {
+		logger.Info("试运行，只统计将被删除的数据")
+	}
+
+	var rollupRepo repositories.SensorDataRollupRepository
+	if cfg.Rollup.Enabled {
+		rollupRepo = repositories.NewSensorDataRollupRepository(db, logger)
+	}
+	var archive services.SensorDataArchiveService
+	if cfg.Archive.Enabled {
+		archive = newArchiveService(cfg, db, logger)
+	}
+	configService := services.NewConfigService(repositories.NewConfigRepository(db, logger), logger)
+	retention := services.NewRetentionService(cfg.Retention, repositories.NewRetentionRepository(db, logger), configService, rollupRepo, archive, logger)
+
+	results, err := retention.RunOnce(context.Background(), dryRun)
+	for _, result := range results {
+		logger.Info("保留策略",
+			utils.String("policy", result.Policy),
+			utils.String("table", result.Table),
+			utils.Int("days", result.Days),
+			utils.String("cutoff", result.Cutoff.Format("2006-01-02 15:04:05")),
+			utils.Int64("matched", result.Matched),
+			utils.Int64("deleted", result.Deleted),
+			utils.Int64("archived", result.Archived))
+	}
+	return err
+}
+
+// runArchive 立即执行一次冷归档，忽略archive.enabled
+func runArchive(cfg *config.Config, db *gorm.DB, logger utils.Logger) error {
+	archive := newArchiveService(cfg, db, logger)
+	total := 0
+	for {
+		days, err := archive.RunOnce(context.Background())
+		total += days
+		if err != nil {
+			return err
+		}
+		if days == 0 {
+			break
+		}
+	}
+	archivedUntil, err := archive.ArchivedUntil()
+	if err != nil {
+		return err
+	}
+	logger.Info("归档完成",
+		utils.Int("days", total),
+		utils.String("archived_until", archivedUntil.UTC().Format(models.ArchiveDateLayout)))
+	return nil
+}
+
+// runRestore 将日期范围内的归档数据写回unified_sensor_data，已存在的数据跳过
+func runRestore(cfg *config.Config, db *gorm.DB, from, to, deviceID string, logger utils.Logger) error {
+	if from == "" {
+		return fmt.Errorf("请使用-from指定开始日期")
+	}
+	if to == "" {
+		to = from
+	}
+	start, err := time.Parse(models.ArchiveDateLayout, from)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %w", err)
+	}
+	end, err := time.Parse(models.ArchiveDateLayout, to)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %w", err)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("结束日期不能早于开始日期")
+	}
+
+	result, err := newArchiveService(cfg, db, logger).Restore(context.Background(), start, end, deviceID)
+	if result != nil {
+		logger.Info("恢复归档数据",
+			utils.Int("days", result.Days),
+			utils.Int("files", result.Files),
+			utils.Int64("records", result.Records),
+			utils.Int64("restored", result.Restored))
+	}
+	return err
+}
+
+// newArchiveService 创建冷归档服务，相对的归档目录基于项目根目录
+func newArchiveService(cfg *config.Config, db *gorm.DB, logger utils.Logger) services.SensorDataArchiveService {
+	archiveCfg := cfg.Archive
+	if archiveCfg.Dir == "" {
+		archiveCfg.Dir = "data/archive"
+	}
+	if !filepath.IsAbs(archiveCfg.Dir) {
+		archiveCfg.Dir = filepath.Join(getProjectRoot(), archiveCfg.Dir)
+	}
+	return services.NewSensorDataArchiveService(archiveCfg, repositories.NewSensorDataArchiveRepository(db, logger), logger)
+}
+
 // getAllModels 获取所有数据模型
 func getAllModels() []interface{} {
 	return []interface{}{
 		&models.User{},
 		&models.Role{},
 		&models.UserRole{},
+		&models.RevokedToken{},
 		&models.Device{},
 		&models.UnifiedSensorData{},
+		&models.SensorDataRollup1m{},
+		&models.SensorDataRollup1h{},
+		&models.SensorDataRollup1d{},
+		&models.SensorDataRollupState{},
 		&models.DeviceRuntimeStatus{},
+		&models.DeviceCommand{},
+		&models.DeviceConfigState{},
+		&models.DeviceShadow{},
+		&models.Firmware{},
+		&models.FirmwareCampaign{},
+		&models.FirmwareUpdate{},
+		&models.PendingDevice{},
+		&models.QuarantinedReading{},
+		&models.LocationNode{},
+		&models.DeviceGroup{},
+		&models.DeviceGroupMember{},
+		&models.DeviceCredential{},
+		&models.MQTTACLRule{},
 		&models.Alert{},
+		&models.AlertHistory{},
 		&models.AlertRule{},
+		&models.NotificationLog{},
 		&models.SystemConfig{},
 	}
 }
@@ -238,7 +381,7 @@ func insertInitialData(db *gorm.DB, logger utils.Logger) error {
 		{
 			Name:        "operator",
 			Description: stringPtr("操作员"),
-			Permissions: stringPtr(`["device:read", "device:write", "data:read", "alert:read", "alert:write"]`),
+			Permissions: stringPtr(`["device:read", "device:write", "data:read", "alert:read", "alert:write", "alert:ack", "alert:resolve"]`),
 		},
 		{
 			Name:        "viewer",
@@ -345,13 +488,24 @@ func showHelp() {
 	fmt.Println("  -config string")
 	fmt.Println("        配置文件路径 (默认自动查找)")
 	fmt.Println("  -action string")
-	fmt.Println("        操作类型: init, status (默认: init)")
+	fmt.Println("        操作类型: init, status, purge, archive, restore (默认: init)")
+	fmt.Println("  -dry-run")
+	fmt.Println("        purge时只统计将被删除的数据，不实际删除")
+	fmt.Println("  -from string")
+	fmt.Println("        restore的开始日期（UTC，格式2006-01-02）")
+	fmt.Println("  -to string")
+	fmt.Println("        restore的结束日期（UTC，含当天），默认与开始日期相同")
+	fmt.Println("  -device string")
+	fmt.Println("        restore只恢复指定设备，默认全部设备")
 	fmt.Println("  -help")
 	fmt.Println("        显示帮助信息")
 	fmt.Println("")
 	fmt.Println("操作说明:")
 	fmt.Println("  init     - 初始化数据库（创建表结构 + 插入初始数据）")
 	fmt.Println("  status   - 显示数据库状态信息")
+	fmt.Println("  purge    - 按保留策略清理过期数据（配置项retention，原始数据保留天数取系统设置data_retention_days）")
+	fmt.Println("  archive  - 立即将已结束的天按设备归档到archive.dir（gzip压缩的NDJSON及清单）")
+	fmt.Println("  restore  - 将归档中指定日期范围的数据恢复到unified_sensor_data，用于调查历史数据")
 	fmt.Println("")
 	fmt.Println("示例:")
 	fmt.Println("  # 初始化数据库（推荐首次部署时使用）")
@@ -360,6 +514,12 @@ func showHelp() {
 	fmt.Println("  # 查看数据库状态")
 	fmt.Println("  go run cmd/migrate/main.go -action status")
 	fmt.Println("")
+	fmt.Println("  # 查看将被清理的过期数据")
+	fmt.Println("  go run cmd/migrate/main.go -action purge -dry-run")
+	fmt.Println("")
+	fmt.Println("  # 恢复某台设备一周的归档数据")
+	fmt.Println("  go run cmd/migrate/main.go -action restore -from 2024-03-01 -to 2024-03-07 -device hcho_001")
+	fmt.Println("")
 	fmt.Println("  # 使用指定配置文件")
 	fmt.Println("  go run cmd/migrate/main.go -config config/config.yaml -action init")
 	fmt.Println("")
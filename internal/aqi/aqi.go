@@ -0,0 +1,291 @@
+// Package aqi 按空气质量指数标准计算各污染物分指数（IAQI）、AQI、首要污染物和级别
+package aqi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Standard AQI计算标准
+type Standard string
+
+const (
+	StandardHJ633 Standard = "hj633"  // 中国《环境空气质量指数（AQI）技术规定（试行）》HJ 633-2012
+	StandardUSEPA Standard = "us_epa" // 美国EPA AQI（EPA-454/B-24-002，2024年修订）
+)
+
+// DefaultStandard 未配置时使用的标准
+const DefaultStandard = StandardHJ633
+
+// ErrNoPollutants 没有可用于计算的污染物浓度
+var ErrNoPollutants = errors.New("没有可用于计算AQI的污染物浓度")
+
+// ParseStandard 解析标准名称，为空时返回默认标准
+func ParseStandard(name string) (Standard, error) {
+	switch Standard(name) {
+	case "":
+		return DefaultStandard, nil
+	case StandardHJ633, StandardUSEPA:
+		return Standard(name), nil
+	default:
+		return "", fmt.Errorf("不支持的AQI标准: %s", name)
+	}
+}
+
+// Pollutant 参与AQI计算的污染物（按标准规定的平均时段取浓度）
+type Pollutant string
+
+const (
+	PM25  Pollutant = "pm25"  // PM2.5 24小时平均，μg/m³
+	PM10  Pollutant = "pm10"  // PM10 24小时平均，μg/m³
+	O3    Pollutant = "o3"    // O3 8小时平均，μg/m³
+	O3_1h Pollutant = "o3_1h" // O3 1小时平均，μg/m³，仅在8小时浓度超出分级表时使用
+	CO    Pollutant = "co"    // CO，mg/m³
+	NO2   Pollutant = "no2"   // NO2，μg/m³
+	SO2   Pollutant = "so2"   // SO2，μg/m³
+)
+
+// Pollutants 全部污染物，按报告顺序排列
+var Pollutants = []Pollutant{PM25, PM10, O3, CO, NO2, SO2}
+
+// Concentrations 各污染物在标准规定平均时段内的浓度，单位与UnifiedSensorData一致（CO为mg/m³，其余为μg/m³）
+type Concentrations map[Pollutant]float64
+
+// Category 空气质量指数级别
+type Category struct {
+	Level int    `json:"level"` // 1-6，数值越大污染越重
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// Result AQI计算结果
+type Result struct {
+	Standard          Standard          `json:"standard"`
+	AQI               int               `json:"aqi"`
+	Category          Category          `json:"category"`
+	PrimaryPollutants []Pollutant       `json:"primary_pollutants"` // 分指数最大的污染物，中国标准AQI不大于50时为空
+	IAQI              map[Pollutant]int `json:"iaqi"`
+}
+
+// Averaging 污染物在该标准下的浓度平均时段，标准不包含该污染物时返回0
+func (s Standard) Averaging(p Pollutant) time.Duration {
+	def, ok := standards[s]
+	if !ok {
+		return 0
+	}
+	return def.tables[p].averaging
+}
+
+// Calculate 计算各污染物分指数，取最大值为AQI并确定首要污染物和级别
+func Calculate(standard Standard, concentrations Concentrations) (*Result, error) {
+	def, ok := standards[standard]
+	if !ok {
+		return nil, fmt.Errorf("不支持的AQI标准: %s", standard)
+	}
+
+	result := &Result{Standard: standard, IAQI: make(map[Pollutant]int)}
+	for _, p := range Pollutants {
+		c, ok := concentrations[p]
+		if !ok || math.IsNaN(c) {
+			continue
+		}
+		index, ok := def.iaqi(p, c)
+		// O3 8小时浓度超出分级表时按1小时浓度计算
+		// 缺少1小时浓度时按8小时分级表上限计
+		if !ok && p == O3 {
+			index, ok = def.tables[O3].top(), true
+			if c1h, has := concentrations[O3_1h]; has && !math.IsNaN(c1h) {
+				if index, ok = def.iaqi(O3_1h, c1h); !ok {
+					index, ok = def.tables[O3_1h].top(), true
+				}
+			}
+		}
+		if !ok {
+			index = def.tables[p].top()
+		}
+		result.IAQI[p] = index
+		if index > result.AQI {
+			result.AQI = index
+		}
+	}
+	if len(result.IAQI) == 0 {
+		return nil, ErrNoPollutants
+	}
+
+	result.Category = def.category(result.AQI)
+	if result.AQI > def.primaryAbove {
+		for _, p := range Pollutants {
+			if index, ok := result.IAQI[p]; ok && index == result.AQI {
+				result.PrimaryPollutants = append(result.PrimaryPollutants, p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// IAQI 计算单个污染物的分指数，浓度超出分级表时返回false
+func IAQI(standard Standard, p Pollutant, concentration float64) (int, bool) {
+	def, ok := standards[standard]
+	if !ok {
+		return 0, false
+	}
+	return def.iaqi(p, concentration)
+}
+
+// Categories 标准的全部级别，按级别从低到高排列
+func Categories(standard Standard) []Category {
+	def, ok := standards[standard]
+	if !ok {
+		return nil
+	}
+	categories := make([]Category, len(def.categories))
+	for i := range def.categories {
+		categories[i] = def.categories[i].Category
+	}
+	return categories
+}
+
+// segment 分级表中的一段：浓度[cLow, cHigh]线性对应分指数[iLow, iHigh]
+type segment struct {
+	cLow, cHigh float64
+	iLow, iHigh int
+}
+
+// table 单个污染物的分级表
+type table struct {
+	averaging time.Duration
+	convert   float64 // 浓度换算系数，换算为分级表单位
+	decimals  int     // 换算后截断保留的小数位数，-1表示不截断
+	segments  []segment
+}
+
+// top 分级表的最高分指数
+func (t table) top() int {
+	if len(t.segments) == 0 {
+		return 0
+	}
+	return t.segments[len(t.segments)-1].iHigh
+}
+
+// categoryLimit 级别及其AQI上限
+type categoryLimit struct {
+	Category
+	max int
+}
+
+// standard 标准定义
+type standard struct {
+	tables       map[Pollutant]table
+	categories   []categoryLimit
+	primaryAbove int               // AQI大于该值时才确定首要污染物，-1表示总是确定
+	round        func(float64) int // 分指数取整方式
+}
+
+// iaqi 按分级表线性插值计算分指数
+func (s *standard) iaqi(p Pollutant, concentration float64) (int, bool) {
+	t, ok := s.tables[p]
+	if !ok || len(t.segments) == 0 {
+		return 0, false
+	}
+
+	c := concentration
+	if t.convert != 0 {
+		c *= t.convert
+	}
+	if c < 0 {
+		c = 0
+	}
+	if t.decimals >= 0 {
+		scale := math.Pow(10, float64(t.decimals))
+		c = math.Floor(c*scale+1e-9) / scale
+	}
+
+	i := sort.Search(len(t.segments), func(i int) bool { return c <= t.segments[i].cHigh })
+	if i == len(t.segments) {
+		return 0, false
+	}
+	seg := t.segments[i]
+	if c < seg.cLow {
+		// 截断后不应落在分段之间的空隙，按下一段下限处理
+		c = seg.cLow
+	}
+	value := float64(seg.iHigh-seg.iLow)/(seg.cHigh-seg.cLow)*(c-seg.cLow) + float64(seg.iLow)
+	return s.round(value), true
+}
+
+// category 按AQI确定级别
+func (s *standard) category(aqi int) Category {
+	for _, c := range s.categories {
+		if aqi <= c.max {
+			return c.Category
+		}
+	}
+	return s.categories[len(s.categories)-1].Category
+}
+
+// roundUp 进位取整（HJ 633-2012：分指数和AQI计算结果全部进位取整数）
+func roundUp(v float64) int {
+	return int(math.Ceil(v - 1e-9))
+}
+
+// roundHalfUp 四舍五入取整（EPA：AQI四舍五入到整数）
+func roundHalfUp(v float64) int {
+	return int(math.Floor(v + 0.5 + 1e-9))
+}
+
+// Sample 一次测量的污染物浓度
+type Sample struct {
+	Time   time.Time
+	Values Concentrations // 只需提供O3，O3_1h由O3的1小时平均得到
+}
+
+// MaxAveraging 标准中最长的浓度平均时段，即计算AQI需要回溯的时长
+func (s Standard) MaxAveraging() time.Duration {
+	def, ok := standards[s]
+	if !ok {
+		return 0
+	}
+	var longest time.Duration
+	for _, t := range def.tables {
+		if t.averaging > longest {
+			longest = t.averaging
+		}
+	}
+	return longest
+}
+
+// Averages 按标准规定的平均时段计算各污染物截至at的平均浓度，时段为(at-平均时段, at]，时段内没有测量值的污染物不返回
+func Averages(standard Standard, samples []Sample, at time.Time) Concentrations {
+	averages := make(Concentrations)
+	for _, p := range []Pollutant{PM25, PM10, O3, O3_1h, CO, NO2, SO2} {
+		window := standard.Averaging(p)
+		if window <= 0 {
+			continue
+		}
+		source := p
+		if p == O3_1h {
+			source = O3
+		}
+		from := at.Add(-window)
+
+		var sum float64
+		var count int
+		for _, sample := range samples {
+			if !sample.Time.After(from) || sample.Time.After(at) {
+				continue
+			}
+			if v, ok := sample.Values[source]; ok && !math.IsNaN(v) {
+				sum += v
+				count++
+			}
+		}
+		if count > 0 {
+			averages[p] = sum / float64(count)
+		}
+	}
+	return averages
+}
@@ -0,0 +1,111 @@
+package aqi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ppbToMicrograms 将EPA示例中的ppb换算为μg/m³（25℃、1个大气压），CO按ppm换算为mg/m³
+func ppbToMicrograms(ppb, molecularWeight float64) float64 {
+	return ppb * molecularWeight / 24.45
+}
+
+// TestCalculate 按HJ 633-2012和EPA技术文件中的分级表和示例值校验分指数、AQI、首要污染物和级别
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name           string
+		standard       Standard
+		concentrations Concentrations
+		aqi            int
+		level          int
+		category       string
+		primary        []Pollutant
+	}{
+		// HJ 633-2012：分指数进位取整，AQI大于50时才有首要污染物
+		{"hj633 pm25 ceiling", StandardHJ633, Concentrations{PM25: 80}, 107, 3, "light", []Pollutant{PM25}},
+		{"hj633 pm25 breakpoint", StandardHJ633, Concentrations{PM25: 35}, 50, 1, "excellent", nil},
+		{"hj633 excellent has no primary", StandardHJ633, Concentrations{PM25: 30, PM10: 40}, 43, 1, "excellent", nil},
+		{"hj633 pm10", StandardHJ633, Concentrations{PM10: 160}, 105, 3, "light", []Pollutant{PM10}},
+		{"hj633 tied primary", StandardHJ633, Concentrations{PM25: 75, PM10: 150}, 100, 2, "good", []Pollutant{PM25, PM10}},
+		{"hj633 co", StandardHJ633, Concentrations{CO: 5}, 105, 3, "light", []Pollutant{CO}},
+		{"hj633 so2", StandardHJ633, Concentrations{SO2: 600}, 170, 4, "moderate", []Pollutant{SO2}},
+		{"hj633 no2", StandardHJ633, Concentrations{NO2: 100, PM25: 20}, 110, 3, "light", []Pollutant{NO2}},
+		{"hj633 o3 8h", StandardHJ633, Concentrations{O3: 180}, 119, 3, "light", []Pollutant{O3}},
+		{"hj633 o3 above 8h table uses 1h", StandardHJ633, Concentrations{O3: 900, O3_1h: 1100}, 450, 6, "severe", []Pollutant{O3}},
+		{"hj633 o3 above 8h table without 1h", StandardHJ633, Concentrations{O3: 900}, 300, 5, "heavy", []Pollutant{O3}},
+		{"hj633 above table", StandardHJ633, Concentrations{PM25: 600}, 500, 6, "severe", []Pollutant{PM25}},
+
+		// EPA：浓度换算后截断，AQI四舍五入，任何AQI都报告首要污染物
+		{"epa o3 8h example", StandardUSEPA, Concentrations{O3: ppbToMicrograms(78, 48.00)}, 126, 3, "unhealthy_sensitive", []Pollutant{O3}},
+		{"epa pm25 example", StandardUSEPA, Concentrations{PM25: 35.9}, 102, 3, "unhealthy_sensitive", []Pollutant{PM25}},
+		{"epa pm25 truncated", StandardUSEPA, Concentrations{PM25: 35.97}, 102, 3, "unhealthy_sensitive", []Pollutant{PM25}},
+		{"epa pm25 moderate", StandardUSEPA, Concentrations{PM25: 12.0}, 56, 2, "moderate", []Pollutant{PM25}},
+		{"epa good has primary", StandardUSEPA, Concentrations{PM25: 5.0}, 28, 1, "good", []Pollutant{PM25}},
+		{"epa pm10", StandardUSEPA, Concentrations{PM10: 155}, 101, 3, "unhealthy_sensitive", []Pollutant{PM10}},
+		{"epa co 8h", StandardUSEPA, Concentrations{CO: ppbToMicrograms(6.0, 28.01)}, 66, 2, "moderate", []Pollutant{CO}},
+		{"epa so2 1h", StandardUSEPA, Concentrations{SO2: ppbToMicrograms(40, 64.07)}, 56, 2, "moderate", []Pollutant{SO2}},
+		{"epa no2 1h", StandardUSEPA, Concentrations{NO2: ppbToMicrograms(120, 46.01)}, 105, 3, "unhealthy_sensitive", []Pollutant{NO2}},
+		{"epa o3 above 8h table uses 1h", StandardUSEPA, Concentrations{
+			O3: ppbToMicrograms(210, 48.00), O3_1h: ppbToMicrograms(300, 48.00)}, 248, 5, "very_unhealthy", []Pollutant{O3}},
+		{"epa above table", StandardUSEPA, Concentrations{PM25: 400}, 500, 6, "hazardous", []Pollutant{PM25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Calculate(tt.standard, tt.concentrations)
+			require.NoError(t, err)
+			assert.Equal(t, tt.aqi, result.AQI)
+			assert.Equal(t, tt.level, result.Category.Level)
+			assert.Equal(t, tt.category, result.Category.Name)
+			assert.Equal(t, tt.primary, result.PrimaryPollutants)
+		})
+	}
+}
+
+// TestCalculateErrors 测试没有污染物和未知标准时返回错误
+func TestCalculateErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		standard       Standard
+		concentrations Concentrations
+		wantErr        error
+	}{
+		{"no pollutants", StandardHJ633, Concentrations{}, ErrNoPollutants},
+		{"unknown standard", "who", Concentrations{PM25: 10}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Calculate(tt.standard, tt.concentrations)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestParseStandard 测试空值默认使用HJ 633-2012，未知标准返回错误
+func TestParseStandard(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Standard
+		wantErr bool
+	}{
+		{"", StandardHJ633, false},
+		{string(StandardHJ633), StandardHJ633, false},
+		{string(StandardUSEPA), StandardUSEPA, false},
+		{"who", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			standard, err := ParseStandard(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, standard)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package aqi
+
+import "time"
+
+// 美国EPA浓度换算：μg/m³ × 24.45 / 分子量 = ppb（25℃、1个大气压），CO的mg/m³按同样方式换算为ppm
+const (
+	molarVolume  = 24.45
+	molecularO3  = 48.00
+	molecularNO2 = 46.01
+	molecularSO2 = 64.07
+	molecularCO  = 28.01
+)
+
+// hjIAQI HJ 633-2012 空气质量分指数分级
+var hjIAQI = []int{0, 50, 100, 150, 200, 300, 400, 500}
+
+// contiguous 按分指数分级和相邻的浓度限值生成连续的分级表
+func contiguous(limits []float64) []segment {
+	segments := make([]segment, 0, len(limits)-1)
+	for i := 1; i < len(limits); i++ {
+		segments = append(segments, segment{
+			cLow: limits[i-1], cHigh: limits[i],
+			iLow: hjIAQI[i-1], iHigh: hjIAQI[i],
+		})
+	}
+	return segments
+}
+
+// standards 各标准的分级表和级别
+var standards = map[Standard]*standard{
+	// HJ 633-2012 表1，浓度单位μg/m³（CO为mg/m³）
+	StandardHJ633: {
+		tables: map[Pollutant]table{
+			SO2:   {averaging: 24 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 50, 150, 475, 800, 1600, 2100, 2620})},
+			NO2:   {averaging: 24 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 40, 80, 180, 280, 565, 750, 940})},
+			PM10:  {averaging: 24 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 50, 150, 250, 350, 420, 500, 600})},
+			CO:    {averaging: 24 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 2, 4, 14, 24, 36, 48, 60})},
+			O3_1h: {averaging: time.Hour, decimals: -1, segments: contiguous([]float64{0, 160, 200, 300, 400, 800, 1000, 1200})},
+			// O3 8小时平均浓度高于800μg/m³时不再计算分指数，按1小时平均浓度计算
+			O3:   {averaging: 8 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 100, 160, 215, 265, 800})},
+			PM25: {averaging: 24 * time.Hour, decimals: -1, segments: contiguous([]float64{0, 35, 75, 115, 150, 250, 350, 500})},
+		},
+		categories: []categoryLimit{
+			{Category{Level: 1, Name: "excellent", Label: "优", Color: "#00e400"}, 50},
+			{Category{Level: 2, Name: "good", Label: "良", Color: "#ffff00"}, 100},
+			{Category{Level: 3, Name: "light", Label: "轻度污染", Color: "#ff7e00"}, 150},
+			{Category{Level: 4, Name: "moderate", Label: "中度污染", Color: "#ff0000"}, 200},
+			{Category{Level: 5, Name: "heavy", Label: "重度污染", Color: "#99004c"}, 300},
+			{Category{Level: 6, Name: "severe", Label: "严重污染", Color: "#7e0023"}, 500},
+		},
+		primaryAbove: 50, // AQI不大于50时空气质量为优，不报告首要污染物
+		round:        roundUp,
+	},
+	// EPA-454/B-24-002 表6，浓度先换算为ppm/ppb并截断
+	StandardUSEPA: {
+		tables: map[Pollutant]table{
+			// O3 8小时平均浓度高于0.200ppm时按1小时平均浓度计算
+			O3: {averaging: 8 * time.Hour, convert: molarVolume / molecularO3 / 1000, decimals: 3, segments: []segment{
+				{0, 0.054, 0, 50}, {0.055, 0.070, 51, 100}, {0.071, 0.085, 101, 150},
+				{0.086, 0.105, 151, 200}, {0.106, 0.200, 201, 300},
+			}},
+			O3_1h: {averaging: time.Hour, convert: molarVolume / molecularO3 / 1000, decimals: 3, segments: []segment{
+				{0.125, 0.164, 101, 150}, {0.165, 0.204, 151, 200}, {0.205, 0.404, 201, 300}, {0.405, 0.604, 301, 500},
+			}},
+			PM25: {averaging: 24 * time.Hour, decimals: 1, segments: []segment{
+				{0, 9.0, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+				{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500},
+			}},
+			PM10: {averaging: 24 * time.Hour, decimals: 0, segments: []segment{
+				{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+				{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500},
+			}},
+			CO: {averaging: 8 * time.Hour, convert: molarVolume / molecularCO, decimals: 1, segments: []segment{
+				{0, 4.4, 0, 50}, {4.5, 9.4, 51, 100}, {9.5, 12.4, 101, 150},
+				{12.5, 15.4, 151, 200}, {15.5, 30.4, 201, 300}, {30.5, 50.4, 301, 500},
+			}},
+			SO2: {averaging: time.Hour, convert: molarVolume / molecularSO2, decimals: 0, segments: []segment{
+				{0, 35, 0, 50}, {36, 75, 51, 100}, {76, 185, 101, 150},
+				{186, 304, 151, 200}, {305, 604, 201, 300}, {605, 1004, 301, 500},
+			}},
+			NO2: {averaging: time.Hour, convert: molarVolume / molecularNO2, decimals: 0, segments: []segment{
+				{0, 53, 0, 50}, {54, 100, 51, 100}, {101, 360, 101, 150},
+				{361, 649, 151, 200}, {650, 1249, 201, 300}, {1250, 2049, 301, 500},
+			}},
+		},
+		categories: []categoryLimit{
+			{Category{Level: 1, Name: "good", Label: "良好", Color: "#00e400"}, 50},
+			{Category{Level: 2, Name: "moderate", Label: "中等", Color: "#ffff00"}, 100},
+			{Category{Level: 3, Name: "unhealthy_sensitive", Label: "对敏感人群不健康", Color: "#ff7e00"}, 150},
+			{Category{Level: 4, Name: "unhealthy", Label: "不健康", Color: "#ff0000"}, 200},
+			{Category{Level: 5, Name: "very_unhealthy", Label: "非常不健康", Color: "#8f3f97"}, 300},
+			{Category{Level: 6, Name: "hazardous", Label: "危险", Color: "#7e0023"}, 500},
+		},
+		primaryAbove: -1,
+		round:        roundHalfUp,
+	},
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
@@ -11,13 +14,21 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Log      LogConfig      `mapstructure:"log"`
-	Service  ServiceConfig  `mapstructure:"service"`
-	MQTT     MQTTConfig     `mapstructure:"mqtt"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Redis        RedisConfig        `mapstructure:"redis"`
+	JWT          JWTConfig          `mapstructure:"jwt"`
+	Log          LogConfig          `mapstructure:"log"`
+	Service      ServiceConfig      `mapstructure:"service"`
+	MQTT         MQTTConfig         `mapstructure:"mqtt"`
+	Spool        SpoolConfig        `mapstructure:"spool"`
+	Notification NotificationConfig `mapstructure:"notification"`
+	Firmware     FirmwareConfig     `mapstructure:"firmware"`
+	Provisioning ProvisioningConfig `mapstructure:"provisioning"`
+	Rollup       RollupConfig       `mapstructure:"rollup"`
+	Retention    RetentionConfig    `mapstructure:"retention"`
+	Archive      ArchiveConfig      `mapstructure:"archive"`
+	AQI          AQIConfig          `mapstructure:"aqi"`
 }
 
 // ServerConfig 服务器配置
@@ -53,9 +64,36 @@ type RedisConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret      string `mapstructure:"secret"`
-	ExpireHours int    `mapstructure:"expire_hours"`
-	Issuer      string `mapstructure:"issuer"`
+	Secret             string `mapstructure:"secret"`
+	ExpireHours        int    `mapstructure:"expire_hours"`
+	RefreshExpireHours int    `mapstructure:"refresh_expire_hours"`
+	Issuer             string `mapstructure:"issuer"`
+}
+
+// publishedJWTSecrets 仓库中公开过的JWT密钥（旧默认值和示例配置），使用这些密钥任何人都可以伪造令牌
+var publishedJWTSecrets = map[string]bool{
+	"air-quality-secret-key":                             true,
+	"air-quality-secret-key-change-in-production":        true,
+	"air-quality-docker-secret-key-change-in-production": true,
+	"air-quality-dev-secret-key":                         true,
+}
+
+// ValidateSecret 校验JWT密钥已设置且不是公开过的默认值
+func (c JWTConfig) ValidateSecret() error {
+	if c.Secret == "" {
+		return fmt.Errorf("JWT密钥不能为空，请设置jwt.secret或环境变量JWT_SECRET")
+	}
+	if publishedJWTSecrets[c.Secret] {
+		return fmt.Errorf("JWT密钥不能使用公开的默认值，请设置jwt.secret或环境变量JWT_SECRET")
+	}
+	return nil
+}
+
+// DeriveSecret 由JWT密钥派生指定用途的独立密钥，其他用途不直接复用令牌签名密钥
+func (c JWTConfig) DeriveSecret(purpose string) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // LogConfig 日志配置
@@ -101,15 +139,20 @@ type MQTTConfig struct {
 
 // TopicConfig 主题配置
 type TopicConfig struct {
-	DeviceStatus   string `mapstructure:"device_status"`
-	DeviceResponse string `mapstructure:"device_response"`
+	DeviceStatus         string `mapstructure:"device_status"`
+	DeviceResponse       string `mapstructure:"device_response"`
+	DeviceConfigReported string `mapstructure:"device_config_reported"`
+	FirmwareProgress     string `mapstructure:"firmware_progress"`
 }
 
 // MessageConfig 消息配置
 type MessageConfig struct {
-	MaxSize    int `mapstructure:"max_size"`
-	BufferSize int `mapstructure:"buffer_size"`
-	BatchSize  int `mapstructure:"batch_size"`
+	MaxSize        int    `mapstructure:"max_size"`
+	BufferSize     int    `mapstructure:"buffer_size"`
+	BatchSize      int    `mapstructure:"batch_size"`
+	Workers        int    `mapstructure:"workers"`         // 入库工作协程数
+	FlushInterval  int    `mapstructure:"flush_interval"`  // 批量刷新间隔（毫秒）
+	OverflowPolicy string `mapstructure:"overflow_policy"` // 队列满时策略: drop_newest, drop_oldest, block
 }
 
 // DeviceConfig 设备配置
@@ -127,6 +170,101 @@ type AlertConfig struct {
 	SignalWeak           int     `mapstructure:"signal_weak"`
 }
 
+// SpoolConfig 本地落盘缓冲配置（数据库不可用时暂存传感器数据）
+type SpoolConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	Dir             string `mapstructure:"dir"`
+	SegmentSize     int    `mapstructure:"segment_size"`      // 单个段文件大小上限（MB）
+	ReplayInterval  int    `mapstructure:"replay_interval"`   // 回放检查间隔（秒）
+	ReplayBatchSize int    `mapstructure:"replay_batch_size"` // 回放批量大小
+}
+
+// FirmwareConfig 固件存储与OTA升级配置
+type FirmwareConfig struct {
+	Dir              string  `mapstructure:"dir"`               // 固件文件存储目录
+	MaxSize          int     `mapstructure:"max_size"`          // 单个固件大小上限（MB）
+	PublicBaseURL    string  `mapstructure:"public_base_url"`   // 设备访问本服务的外部地址，用于生成下载链接
+	URLSecret        string  `mapstructure:"url_secret"`        // 下载链接签名密钥，为空时由JWT密钥派生
+	UpdateTimeout    int     `mapstructure:"update_timeout"`    // 单台设备升级超时（秒），同时作为下载链接有效期
+	FailureThreshold float64 `mapstructure:"failure_threshold"` // 默认失败率阈值，超过后自动暂停升级活动
+	MinSamples       int     `mapstructure:"min_samples"`       // 计算失败率所需的最少完成设备数
+	CheckInterval    int     `mapstructure:"check_interval"`    // 升级超时检查间隔（秒）
+}
+
+// ProvisioningConfig 未注册设备接入策略（同时作用于MQTT和HTTP上报）
+type ProvisioningConfig struct {
+	Mode               string `mapstructure:"mode"`                 // strict: 拒绝未注册设备；auto: 自动注册；pending: 隔离数据等待管理员审批
+	MaxPendingReadings int    `mapstructure:"max_pending_readings"` // 每台待审批设备最多隔离的数据条数
+}
+
+// RollupConfig 传感器数据分钟/小时/天汇总任务配置
+type RollupConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	Interval    int  `mapstructure:"interval"`     // 汇总任务运行间隔（秒）
+	BatchSize   int  `mapstructure:"batch_size"`   // 每批读取的新增原始数据条数
+	SettleDelay int  `mapstructure:"settle_delay"` // 只汇总入库超过该时长（秒）的数据，避免遗漏未提交的事务
+}
+
+// RetentionConfig 数据保留与清理任务配置，保留天数为0表示永久保留
+type RetentionConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	Interval          int    `mapstructure:"interval"`            // 清理任务运行间隔（秒）
+	ChunkSize         int    `mapstructure:"chunk_size"`          // 每次删除的行数
+	ChunkPause        int    `mapstructure:"chunk_pause"`         // 两次删除之间的间隔（毫秒），减少对写入的影响
+	RawDays           int    `mapstructure:"raw_days"`            // 原始数据保留天数，系统设置data_retention_days优先
+	Rollup1mDays      int    `mapstructure:"rollup_1m_days"`      // 分钟汇总保留天数
+	Rollup1hDays      int    `mapstructure:"rollup_1h_days"`      // 小时汇总保留天数
+	Rollup1dDays      int    `mapstructure:"rollup_1d_days"`      // 天汇总保留天数
+	ResolvedAlertDays int    `mapstructure:"resolved_alert_days"` // 已解决告警（含历史和通知记录）保留天数
+	SoftDeleteDays    int    `mapstructure:"soft_delete_days"`    // 软删除的数据超过该天数后彻底删除
+	ArchiveDir        string `mapstructure:"archive_dir"`         // 删除前归档到该目录（gzip压缩的NDJSON），为空时不归档
+}
+
+// ArchiveConfig 原始数据冷归档配置
+type ArchiveConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Dir       string `mapstructure:"dir"`        // 归档根目录，相对路径基于项目根目录
+	Interval  int    `mapstructure:"interval"`   // 归档任务运行间隔（秒）
+	DelayDays int    `mapstructure:"delay_days"` // 一天结束后至少再等待的天数才归档，留出迟到数据的时间
+}
+
+// AQIConfig 空气质量指数计算配置
+type AQIConfig struct {
+	Standard string `mapstructure:"standard"` // hj633: 中国HJ 633-2012；us_epa: 美国EPA
+}
+
+// NotificationConfig 告警通知配置
+type NotificationConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Workers         int           `mapstructure:"workers"`          // 投递工作协程数
+	QueueSize       int           `mapstructure:"queue_size"`       // 待投递队列长度
+	MaxRetries      int           `mapstructure:"max_retries"`      // 失败后最大重试次数
+	RetryBackoff    int           `mapstructure:"retry_backoff"`    // 首次重试等待（毫秒），之后按2倍递增
+	EscalateAfter   int           `mapstructure:"escalate_after"`   // 告警未确认多久后升级通知（秒），0表示不升级
+	DefaultChannels []string      `mapstructure:"default_channels"` // 无规则告警（如设备离线）使用的通知渠道
+	Webhook         WebhookConfig `mapstructure:"webhook"`
+	SMTP            SMTPConfig    `mapstructure:"smtp"`
+	MQTTTopic       string        `mapstructure:"mqtt_topic"` // 告警通知发布主题，{device_id}替换为设备ID
+}
+
+// WebhookConfig Webhook通知配置
+type WebhookConfig struct {
+	URL     string `mapstructure:"url"`
+	Secret  string `mapstructure:"secret"`  // HMAC-SHA256签名密钥
+	Timeout int    `mapstructure:"timeout"` // 请求超时（秒）
+}
+
+// SMTPConfig 邮件通知配置
+type SMTPConfig struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`      // 默认收件人（系统设置notification_email优先）
+	Timeout  int      `mapstructure:"timeout"` // 发送超时（秒）
+}
+
 // Load 加载配置
 func Load(configPath string) (*Config, error) {
 	viper.SetConfigFile(configPath)
@@ -186,9 +324,10 @@ func setDefaults() {
 	viper.SetDefault("redis.db", 0)
 	viper.SetDefault("redis.pool_size", 10)
 
-	// JWT默认配置
-	viper.SetDefault("jwt.secret", "air-quality-secret-key")
+	// JWT默认配置（密钥无默认值，必须在配置文件或环境变量JWT_SECRET中设置）
+	viper.SetDefault("jwt.secret", "")
 	viper.SetDefault("jwt.expire_hours", 24)
+	viper.SetDefault("jwt.refresh_expire_hours", 168)
 	viper.SetDefault("jwt.issuer", "air-quality-server")
 
 	// 日志默认配置
@@ -209,8 +348,9 @@ func setDefaults() {
 	// MQTT默认配置
 	viper.SetDefault("mqtt.broker", "tcp://localhost:1883")
 	viper.SetDefault("mqtt.client_id", "air-quality-server")
-	viper.SetDefault("mqtt.username", "admin")
-	viper.SetDefault("mqtt.password", "password")
+	// 服务账号默认禁用，避免使用公开的默认口令连接嵌入式Broker
+	viper.SetDefault("mqtt.username", "")
+	viper.SetDefault("mqtt.password", "")
 	viper.SetDefault("mqtt.keep_alive", 60)
 	viper.SetDefault("mqtt.clean_session", true)
 	viper.SetDefault("mqtt.qos", 1)
@@ -221,12 +361,17 @@ func setDefaults() {
 	viper.SetDefault("mqtt.write_timeout", 10)
 	viper.SetDefault("mqtt.read_timeout", 10)
 	viper.SetDefault("mqtt.topics.formaldehyde_data", "air-quality/hcho/+/data")
-	viper.SetDefault("mqtt.topics.device_status", "air-quality/hcho/+/status")
-	viper.SetDefault("mqtt.topics.device_response", "air-quality/hcho/+/response")
+	viper.SetDefault("mqtt.topics.device_status", "air-quality/+/+/status")
+	viper.SetDefault("mqtt.topics.device_response", "air-quality/+/+/response")
+	viper.SetDefault("mqtt.topics.device_config_reported", "air-quality/+/+/config/reported")
+	viper.SetDefault("mqtt.topics.firmware_progress", "air-quality/+/+/ota/progress")
 	viper.SetDefault("mqtt.publish_prefix", "air-quality/hcho")
 	viper.SetDefault("mqtt.message.max_size", 1048576)
 	viper.SetDefault("mqtt.message.buffer_size", 1000)
 	viper.SetDefault("mqtt.message.batch_size", 100)
+	viper.SetDefault("mqtt.message.workers", 4)
+	viper.SetDefault("mqtt.message.flush_interval", 1000)
+	viper.SetDefault("mqtt.message.overflow_policy", "drop_newest")
 	viper.SetDefault("mqtt.device.offline_timeout", 300)
 	viper.SetDefault("mqtt.device.heartbeat_interval", 60)
 	viper.SetDefault("mqtt.device.report_interval", 300)
@@ -234,6 +379,65 @@ func setDefaults() {
 	viper.SetDefault("mqtt.alert.formaldehyde_critical", 0.1)
 	viper.SetDefault("mqtt.alert.battery_low", 20)
 	viper.SetDefault("mqtt.alert.signal_weak", -80)
+
+	// 落盘缓冲默认配置
+	viper.SetDefault("spool.enabled", true)
+	viper.SetDefault("spool.dir", "data/spool")
+	viper.SetDefault("spool.segment_size", 8)
+	viper.SetDefault("spool.replay_interval", 10)
+	viper.SetDefault("spool.replay_batch_size", 500)
+
+	// 告警通知默认配置
+	viper.SetDefault("notification.enabled", true)
+	viper.SetDefault("notification.workers", 2)
+	viper.SetDefault("notification.queue_size", 1000)
+	viper.SetDefault("notification.max_retries", 3)
+	viper.SetDefault("notification.retry_backoff", 1000)
+	viper.SetDefault("notification.escalate_after", 1800)
+	viper.SetDefault("notification.webhook.timeout", 10)
+	viper.SetDefault("notification.smtp.port", 25)
+	viper.SetDefault("notification.smtp.timeout", 10)
+	viper.SetDefault("notification.mqtt_topic", "air-quality/alerts/{device_id}")
+
+	// 固件升级默认配置
+	viper.SetDefault("firmware.dir", "data/firmware")
+	viper.SetDefault("firmware.max_size", 64)
+	viper.SetDefault("firmware.update_timeout", 86400)
+	viper.SetDefault("firmware.failure_threshold", 0.2)
+	viper.SetDefault("firmware.min_samples", 5)
+	viper.SetDefault("firmware.check_interval", 60)
+
+	// 设备接入策略默认配置
+	viper.SetDefault("provisioning.mode", "strict")
+	viper.SetDefault("provisioning.max_pending_readings", 1000)
+
+	// 数据汇总默认配置
+	viper.SetDefault("rollup.enabled", true)
+	viper.SetDefault("rollup.interval", 60)
+	viper.SetDefault("rollup.batch_size", 5000)
+	viper.SetDefault("rollup.settle_delay", 30)
+
+	// 数据保留默认配置
+	viper.SetDefault("retention.enabled", true)
+	viper.SetDefault("retention.interval", 3600)
+	viper.SetDefault("retention.chunk_size", 1000)
+	viper.SetDefault("retention.chunk_pause", 100)
+	viper.SetDefault("retention.raw_days", 90)
+	viper.SetDefault("retention.rollup_1m_days", 30)
+	viper.SetDefault("retention.rollup_1h_days", 730)
+	viper.SetDefault("retention.rollup_1d_days", 0)
+	viper.SetDefault("retention.resolved_alert_days", 365)
+	viper.SetDefault("retention.soft_delete_days", 30)
+	viper.SetDefault("retention.archive_dir", "")
+
+	// 冷归档默认配置
+	viper.SetDefault("archive.enabled", false)
+	viper.SetDefault("archive.dir", "data/archive")
+	viper.SetDefault("archive.interval", 3600)
+	viper.SetDefault("archive.delay_days", 1)
+
+	// AQI默认配置
+	viper.SetDefault("aqi.standard", "hj633")
 }
 
 // validateConfig 验证配置
@@ -258,8 +462,23 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("Redis主机地址不能为空")
 	}
 
-	if config.JWT.Secret == "" {
-		return fmt.Errorf("JWT密钥不能为空")
+	if err := config.JWT.ValidateSecret(); err != nil {
+		return err
+	}
+	if secret := config.Firmware.URLSecret; secret != "" && (publishedJWTSecrets[secret] || secret == config.JWT.Secret) {
+		return fmt.Errorf("固件下载链接签名密钥不能使用公开的默认值或与JWT密钥相同，留空时由JWT密钥派生")
+	}
+
+	switch config.Provisioning.Mode {
+	case "strict", "auto", "pending":
+	default:
+		return fmt.Errorf("设备接入策略必须为strict、auto或pending: %s", config.Provisioning.Mode)
+	}
+
+	switch config.AQI.Standard {
+	case "hj633", "us_epa":
+	default:
+		return fmt.Errorf("AQI标准必须为hj633或us_epa: %s", config.AQI.Standard)
 	}
 
 	return nil
@@ -326,9 +545,10 @@ func LoadFromEnv() (*Config, error) {
 			PoolSize: getEnvInt("REDIS_POOL_SIZE", 10),
 		},
 		JWT: JWTConfig{
-			Secret:      getEnvString("JWT_SECRET", "air-quality-secret-key"),
-			ExpireHours: getEnvInt("JWT_EXPIRE_HOURS", 24),
-			Issuer:      getEnvString("JWT_ISSUER", "air-quality-server"),
+			Secret:             getEnvString("JWT_SECRET", ""),
+			ExpireHours:        getEnvInt("JWT_EXPIRE_HOURS", 24),
+			RefreshExpireHours: getEnvInt("JWT_REFRESH_EXPIRE_HOURS", 168),
+			Issuer:             getEnvString("JWT_ISSUER", "air-quality-server"),
 		},
 		Log: LogConfig{
 			Level:      getEnvString("LOG_LEVEL", "info"),
@@ -345,6 +565,77 @@ func LoadFromEnv() (*Config, error) {
 			Environment: getEnvString("ENVIRONMENT", "development"),
 			Debug:       getEnvBool("DEBUG", false),
 		},
+		Spool: SpoolConfig{
+			Enabled:         getEnvBool("SPOOL_ENABLED", true),
+			Dir:             getEnvString("SPOOL_DIR", "data/spool"),
+			SegmentSize:     getEnvInt("SPOOL_SEGMENT_SIZE", 8),
+			ReplayInterval:  getEnvInt("SPOOL_REPLAY_INTERVAL", 10),
+			ReplayBatchSize: getEnvInt("SPOOL_REPLAY_BATCH_SIZE", 500),
+		},
+		Notification: NotificationConfig{
+			Enabled:       getEnvBool("NOTIFY_ENABLED", true),
+			Workers:       getEnvInt("NOTIFY_WORKERS", 2),
+			QueueSize:     getEnvInt("NOTIFY_QUEUE_SIZE", 1000),
+			MaxRetries:    getEnvInt("NOTIFY_MAX_RETRIES", 3),
+			RetryBackoff:  getEnvInt("NOTIFY_RETRY_BACKOFF", 1000),
+			EscalateAfter: getEnvInt("NOTIFY_ESCALATE_AFTER", 1800),
+			Webhook: WebhookConfig{
+				URL:     getEnvString("NOTIFY_WEBHOOK_URL", ""),
+				Secret:  getEnvString("NOTIFY_WEBHOOK_SECRET", ""),
+				Timeout: getEnvInt("NOTIFY_WEBHOOK_TIMEOUT", 10),
+			},
+			SMTP: SMTPConfig{
+				Host:     getEnvString("SMTP_HOST", ""),
+				Port:     getEnvInt("SMTP_PORT", 25),
+				Username: getEnvString("SMTP_USERNAME", ""),
+				Password: getEnvString("SMTP_PASSWORD", ""),
+				From:     getEnvString("SMTP_FROM", ""),
+				Timeout:  getEnvInt("SMTP_TIMEOUT", 10),
+			},
+			MQTTTopic: getEnvString("NOTIFY_MQTT_TOPIC", "air-quality/alerts/{device_id}"),
+		},
+		Firmware: FirmwareConfig{
+			Dir:              getEnvString("FIRMWARE_DIR", "data/firmware"),
+			MaxSize:          getEnvInt("FIRMWARE_MAX_SIZE", 64),
+			PublicBaseURL:    getEnvString("FIRMWARE_PUBLIC_BASE_URL", ""),
+			URLSecret:        getEnvString("FIRMWARE_URL_SECRET", ""),
+			UpdateTimeout:    getEnvInt("FIRMWARE_UPDATE_TIMEOUT", 86400),
+			FailureThreshold: getEnvFloat("FIRMWARE_FAILURE_THRESHOLD", 0.2),
+			MinSamples:       getEnvInt("FIRMWARE_MIN_SAMPLES", 5),
+			CheckInterval:    getEnvInt("FIRMWARE_CHECK_INTERVAL", 60),
+		},
+		Provisioning: ProvisioningConfig{
+			Mode:               getEnvString("PROVISIONING_MODE", "strict"),
+			MaxPendingReadings: getEnvInt("PROVISIONING_MAX_PENDING_READINGS", 1000),
+		},
+		Rollup: RollupConfig{
+			Enabled:     getEnvBool("ROLLUP_ENABLED", true),
+			Interval:    getEnvInt("ROLLUP_INTERVAL", 60),
+			BatchSize:   getEnvInt("ROLLUP_BATCH_SIZE", 5000),
+			SettleDelay: getEnvInt("ROLLUP_SETTLE_DELAY", 30),
+		},
+		Retention: RetentionConfig{
+			Enabled:           getEnvBool("RETENTION_ENABLED", true),
+			Interval:          getEnvInt("RETENTION_INTERVAL", 3600),
+			ChunkSize:         getEnvInt("RETENTION_CHUNK_SIZE", 1000),
+			ChunkPause:        getEnvInt("RETENTION_CHUNK_PAUSE", 100),
+			RawDays:           getEnvInt("RETENTION_RAW_DAYS", 90),
+			Rollup1mDays:      getEnvInt("RETENTION_ROLLUP_1M_DAYS", 30),
+			Rollup1hDays:      getEnvInt("RETENTION_ROLLUP_1H_DAYS", 730),
+			Rollup1dDays:      getEnvInt("RETENTION_ROLLUP_1D_DAYS", 0),
+			ResolvedAlertDays: getEnvInt("RETENTION_RESOLVED_ALERT_DAYS", 365),
+			SoftDeleteDays:    getEnvInt("RETENTION_SOFT_DELETE_DAYS", 30),
+			ArchiveDir:        getEnvString("RETENTION_ARCHIVE_DIR", ""),
+		},
+		Archive: ArchiveConfig{
+			Enabled:   getEnvBool("ARCHIVE_ENABLED", false),
+			Dir:       getEnvString("ARCHIVE_DIR", "data/archive"),
+			Interval:  getEnvInt("ARCHIVE_INTERVAL", 3600),
+			DelayDays: getEnvInt("ARCHIVE_DELAY_DAYS", 1),
+		},
+		AQI: AQIConfig{
+			Standard: getEnvString("AQI_STANDARD", "hj633"),
+		},
 	}
 
 	if err := validateConfig(config); err != nil {
@@ -379,3 +670,12 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
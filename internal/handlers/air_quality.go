@@ -23,31 +23,6 @@ func NewAirQualityHandler(airQualityService services.AirQualityService, logger u
 	}
 }
 
-// UploadData 上传空气质量数据
-func (h *AirQualityHandler) UploadData(c *gin.Context) {
-	var req struct {
-		DeviceID    string  `json:"device_id" binding:"required"`
-		PM2_5       float64 `json:"pm2_5" binding:"required"`
-		PM10        float64 `json:"pm10" binding:"required"`
-		CO2         float64 `json:"co2" binding:"required"`
-		Temperature float64 `json:"temperature" binding:"required"`
-		Humidity    float64 `json:"humidity" binding:"required"`
-		Timestamp   int64   `json:"timestamp"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("上传空气质量数据请求参数错误", utils.ErrorField(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
-		return
-	}
-
-	h.logger.Info("上传空气质量数据请求", utils.String("device_id", req.DeviceID))
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "数据上传成功",
-		"data":    req,
-	})
-}
-
 // GetRealtimeData 获取实时数据
 func (h *AirQualityHandler) GetRealtimeData(c *gin.Context) {
 	deviceID := c.Param("device_id")
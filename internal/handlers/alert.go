@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
 	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -176,35 +180,96 @@ func (h *AlertHandler) GetAlertsByDevice(c *gin.Context) {
 	})
 }
 
-// ResolveAlert 解决告警
-func (h *AlertHandler) ResolveAlert(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+// AcknowledgeAlert 确认告警
+func (h *AlertHandler) AcknowledgeAlert(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		h.logger.Error("告警ID参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "告警ID参数错误"})
 		return
 	}
 
-	var req struct {
-		Message string `json:"message"`
+	// 请求体可选，仅包含备注
+	var req models.AlertAcknowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Error("确认告警请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	alert, err := h.alertService.AcknowledgeAlert(c.Request.Context(), id, middleware.CurrentUserID(c), req.Comment)
+	if err != nil {
+		h.respondTransitionError(c, err, "确认告警失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "告警已确认",
+		"data":    alert,
+	})
+}
+
+// ResolveAlert 解决告警
+func (h *AlertHandler) ResolveAlert(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("告警ID参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "告警ID参数错误"})
+		return
+	}
+
+	var req models.AlertResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		h.logger.Error("解决告警请求参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
-	h.logger.Info("解决告警请求", utils.Int("alert_id", int(id)))
+	alert, err := h.alertService.ResolveAlert(c.Request.Context(), id, middleware.CurrentUserID(c), req.Comment)
+	if err != nil {
+		h.respondTransitionError(c, err, "解决告警失败")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "告警已解决",
-		"data": gin.H{
-			"id": id,
-		},
+		"data":    alert,
+	})
+}
+
+// GetAlertHistory 获取告警状态变更历史
+func (h *AlertHandler) GetAlertHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("告警ID参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "告警ID参数错误"})
+		return
+	}
+
+	histories, err := h.alertService.GetAlertHistory(c.Request.Context(), id)
+	if err != nil {
+		h.respondTransitionError(c, err, "获取告警历史失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取告警历史成功",
+		"data":    histories,
 	})
 }
 
+// respondTransitionError 将告警状态变更错误映射为HTTP响应
+func (h *AlertHandler) respondTransitionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlertAcknowledged), errors.Is(err, services.ErrAlertAlreadyResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
 // GetUnresolvedAlerts 获取未解决的告警
 func (h *AlertHandler) GetUnresolvedAlerts(c *gin.Context) {
 	h.logger.Info("获取未解决告警请求")
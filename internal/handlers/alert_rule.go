@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertRuleHandler 告警规则处理器
+type AlertRuleHandler struct {
+	ruleService services.AlertRuleService
+	logger      utils.Logger
+}
+
+// NewAlertRuleHandler 创建告警规则处理器
+func NewAlertRuleHandler(ruleService services.AlertRuleService, logger utils.Logger) *AlertRuleHandler {
+	return &AlertRuleHandler{
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+// ListRules 分页列出告警规则
+func (h *AlertRuleHandler) ListRules(c *gin.Context) {
+	req := models.AlertRuleListRequest{Page: 1, PageSize: 20}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Error("获取告警规则列表请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	resp, err := h.ruleService.ListRules(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取告警规则列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取告警规则列表成功",
+		"data":    resp,
+	})
+}
+
+// CreateRule 创建告警规则
+func (h *AlertRuleHandler) CreateRule(c *gin.Context) {
+	var req models.AlertRuleCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("创建告警规则请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.ruleService.CreateRule(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "告警规则创建成功",
+		"data":    rule,
+	})
+}
+
+// GetRule 获取告警规则
+func (h *AlertRuleHandler) GetRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	rule, err := h.ruleService.GetRule(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "告警规则不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取告警规则成功",
+		"data":    rule,
+	})
+}
+
+// UpdateRule 更新告警规则
+func (h *AlertRuleHandler) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	var req models.AlertRuleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("更新告警规则请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.ruleService.UpdateRule(c.Request.Context(), id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "告警规则更新成功",
+		"data":    rule,
+	})
+}
+
+// DeleteRule 删除告警规则
+func (h *AlertRuleHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	if err := h.ruleService.DeleteRule(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除告警规则失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "告警规则删除成功",
+	})
+}
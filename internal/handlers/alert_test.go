@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAlertHandler_AcknowledgeRecordsUser 测试确认和解决接口记录当前用户，重复确认返回冲突
+func TestAlertHandler_AcknowledgeRecordsUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	alertService := services.NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger)
+	alert := &models.Alert{
+		RuleID: 1, DeviceID: testutil.DeviceID1, Metric: "pm25",
+		CurrentValue: 90, ThresholdValue: 75, Severity: string(models.AlertSeverityWarning),
+		Status: string(models.AlertStatusActive), TriggeredAt: time.Now(),
+	}
+	require.NoError(t, alertService.CreateAlert(context.Background(), alert))
+
+	handler := NewAlertHandler(alertService, logger)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUserID, uint64(3))
+		c.Next()
+	})
+	router.POST("/alerts/:id/ack", handler.AcknowledgeAlert)
+	router.POST("/alerts/:id/resolve", handler.ResolveAlert)
+	router.GET("/alerts/:id/history", handler.GetAlertHistory)
+
+	// 各请求依次作用于同一条告警
+	id := strconv.FormatUint(alert.ID, 10)
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{"acknowledge", http.MethodPost, "/alerts/" + id + "/ack", "", http.StatusOK},
+		{"acknowledge twice", http.MethodPost, "/alerts/" + id + "/ack", "", http.StatusConflict},
+		{"resolve with comment", http.MethodPost, "/alerts/" + id + "/resolve", `{"comment":"传感器误报"}`, http.StatusOK},
+		{"resolve missing alert", http.MethodPost, "/alerts/9999/resolve", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := testutil.Serve(router, tt.method, tt.target, "", tt.body)
+		assert.Equal(t, tt.code, w.Code, "%s: %s", tt.name, w.Body.String())
+	}
+
+	w := testutil.Serve(router, http.MethodGet, "/alerts/"+id+"/history", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []models.AlertHistory
+	testutil.DecodeData(t, w, &history)
+	require.Len(t, history, 3)
+	require.NotNil(t, history[1].UserID)
+	assert.Equal(t, uint64(3), *history[1].UserID)
+	require.NotNil(t, history[2].Comment)
+	assert.Equal(t, "传感器误报", *history[2].Comment)
+}
@@ -1,25 +1,41 @@
 package handlers
 
 import (
+	"air-quality-server/internal/models"
 	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
-	"strconv"
+	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // DeviceHandler 设备处理器
 type DeviceHandler struct {
-	deviceService services.DeviceService
-	logger        utils.Logger
+	deviceService     services.DeviceService
+	credentialService services.DeviceCredentialService
+	presenceService   services.DevicePresenceService
+	configService     services.DeviceConfigService
+	shadowService     services.DeviceShadowService
+	scopeService      services.DeviceScopeService
+	logger            utils.Logger
 }
 
 // NewDeviceHandler 创建设备处理器
-func NewDeviceHandler(deviceService services.DeviceService, logger utils.Logger) *DeviceHandler {
+func NewDeviceHandler(deviceService services.DeviceService, credentialService services.DeviceCredentialService, presenceService services.DevicePresenceService, configService services.DeviceConfigService, shadowService services.DeviceShadowService, scopeService services.DeviceScopeService, logger utils.Logger) *DeviceHandler {
 	return &DeviceHandler{
-		deviceService: deviceService,
-		logger:        logger,
+		deviceService:     deviceService,
+		credentialService: credentialService,
+		presenceService:   presenceService,
+		configService:     configService,
+		shadowService:     shadowService,
+		scopeService:      scopeService,
+		logger:            logger,
 	}
 }
 
@@ -74,18 +90,49 @@ func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Name        string `json:"name"`
-		Location    string `json:"location"`
-		Description string `json:"description"`
-	}
-
+	var req models.DeviceUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("更新设备请求参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
+	device := &models.Device{ID: id}
+	if req.Name != nil {
+		device.Name = *req.Name
+	}
+	if req.Type != nil {
+		device.Type = models.DeviceType(*req.Type)
+		if !device.Type.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的设备类型"})
+			return
+		}
+	}
+	if req.Location != nil {
+		device.LocationLatitude = &req.Location.Latitude
+		device.LocationLongitude = &req.Location.Longitude
+		device.LocationAddress = &req.Location.Address
+	}
+	if req.Config != nil {
+		data, err := json.Marshal(req.Config)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "设备配置格式错误"})
+			return
+		}
+		config := string(data)
+		device.Config = &config
+	}
+
+	if err := h.deviceService.UpdateDevice(c.Request.Context(), device); err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("更新设备失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新设备失败"})
+		return
+	}
+
 	h.logger.Info("更新设备请求", utils.String("device_id", id))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "设备更新成功",
@@ -110,34 +157,52 @@ func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
 	})
 }
 
-// ListDevices 列出设备
+// ListDevices 列出设备，可按group_id、location_id（含下级位置）、type和status筛选
 func (h *DeviceHandler) ListDevices(c *gin.Context) {
-	limitStr := c.DefaultQuery("limit", "10")
-	offsetStr := c.DefaultQuery("offset", "0")
+	req := models.DeviceQueryRequest{Limit: 10}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
-		limit = 10
+	var deviceIDs []string
+	if !req.IsEmpty() {
+		if h.scopeService == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持按分组或位置筛选"})
+			return
+		}
+		ids, err := h.scopeService.ResolveDeviceIDs(c.Request.Context(), req.DeviceScopeFilter)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrDeviceGroupNotFound), errors.Is(err, services.ErrLocationNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				h.logger.Error("解析设备范围失败", utils.ErrorField(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备列表失败"})
+			}
+			return
+		}
+		deviceIDs = ids
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
+	devices, total, err := h.deviceService.QueryDevices(c.Request.Context(), &req, deviceIDs)
 	if err != nil {
-		offset = 0
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备列表失败"})
+		return
 	}
 
-	h.logger.Info("列出设备请求", utils.Int("limit", limit), utils.Int("offset", offset))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "获取设备列表成功",
 		"data": gin.H{
-			"devices": []gin.H{},
-			"total":   0,
-			"limit":   limit,
-			"offset":  offset,
+			"devices": devices,
+			"total":   total,
+			"limit":   req.Limit,
+			"offset":  req.Offset,
 		},
 	})
 }
 
-// GetDeviceStatus 获取设备状态
+// GetDeviceStatus 获取设备运行状态
 func (h *DeviceHandler) GetDeviceStatus(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -146,13 +211,211 @@ func (h *DeviceHandler) GetDeviceStatus(c *gin.Context) {
 		return
 	}
 
-	h.logger.Info("获取设备状态请求", utils.String("device_id", id))
+	status, err := h.presenceService.GetRuntimeStatus(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备状态失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备状态失败"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "获取设备状态成功",
-		"data": gin.H{
-			"device_id": id,
-			"status":    "online",
-			"last_seen": "2024-01-01T00:00:00Z",
-		},
+		"data":    status,
+	})
+}
+
+// GetConfigSync 获取设备期望配置与上报配置的差异及同步状态
+func (h *DeviceHandler) GetConfigSync(c *gin.Context) {
+	id := c.Param("id")
+	status, err := h.configService.GetSyncStatus(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备配置同步状态失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备配置同步状态失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备配置同步状态成功",
+		"data":    status,
+	})
+}
+
+// GetShadow 获取设备影子（各指标最近取值、上报状态与期望状态）
+func (h *DeviceHandler) GetShadow(c *gin.Context) {
+	id := c.Param("id")
+	shadow, err := h.shadowService.GetShadow(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备影子失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备影子失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备影子成功",
+		"data":    shadow,
+	})
+}
+
+// GetCredential 获取设备凭证信息（不含密钥）
+func (h *DeviceHandler) GetCredential(c *gin.Context) {
+	id := c.Param("id")
+	credential, err := h.credentialService.GetCredential(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrCredentialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备凭证失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备凭证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备凭证成功",
+		"data":    credential,
+	})
+}
+
+// IssueCredential 签发设备凭证
+func (h *DeviceHandler) IssueCredential(c *gin.Context) {
+	id := c.Param("id")
+	result, err := h.credentialService.IssueCredential(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("签发设备凭证失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("签发设备凭证", utils.String("device_id", id))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "设备凭证签发成功，密钥仅显示一次，请妥善保存",
+		"data":    result,
+	})
+}
+
+// RotateCredential 轮换设备凭证
+func (h *DeviceHandler) RotateCredential(c *gin.Context) {
+	id := c.Param("id")
+	result, err := h.credentialService.RotateCredential(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrCredentialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("轮换设备凭证失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("轮换设备凭证", utils.String("device_id", id))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备凭证轮换成功，旧密钥已失效",
+		"data":    result,
+	})
+}
+
+// RevokeCredential 吊销设备凭证
+func (h *DeviceHandler) RevokeCredential(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.credentialService.RevokeCredential(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备凭证已吊销",
 	})
 }
+
+// maxDeviceImportSize 设备导入文件大小上限
+const maxDeviceImportSize = 10 << 20
+
+// ImportDevices 批量导入设备：请求体或multipart文件字段file，format=csv|json，dry_run=true时只校验
+func (h *DeviceHandler) ImportDevices(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDeviceImportSize)
+	format := c.Query("format")
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "缺少导入文件"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.logger.Error("读取设备导入文件失败", utils.ErrorField(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取导入文件失败"})
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.ContentType(), "csv") {
+			format = "csv"
+		}
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	result, err := h.deviceService.ImportDevices(c.Request.Context(), format, body, dryRun)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "导入文件过大"})
+		case errors.Is(err, services.ErrInvalidImport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("批量导入设备失败", utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "批量导入设备失败"})
+		}
+		return
+	}
+
+	switch {
+	case dryRun:
+		c.JSON(http.StatusOK, gin.H{"message": "校验完成", "data": result})
+	case len(result.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "存在无效记录，未导入任何设备", "data": result})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"message": "批量导入设备成功", "data": result})
+	}
+}
+
+// ExportDevices 按导入格式导出全部设备，format=csv|json，默认csv
+func (h *DeviceHandler) ExportDevices(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	var buf bytes.Buffer
+	if err := h.deviceService.ExportDevices(c.Request.Context(), format, &buf); err != nil {
+		if errors.Is(err, services.ErrInvalidImport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("导出设备失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出设备失败"})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "json" {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Header("Content-Disposition", "attachment; filename=devices."+format)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceCommandHandler 设备指令处理器
+type DeviceCommandHandler struct {
+	commandService services.DeviceCommandService
+	logger         utils.Logger
+}
+
+// NewDeviceCommandHandler 创建设备指令处理器
+func NewDeviceCommandHandler(commandService services.DeviceCommandService, logger utils.Logger) *DeviceCommandHandler {
+	return &DeviceCommandHandler{
+		commandService: commandService,
+		logger:         logger,
+	}
+}
+
+// SendCommand 下发设备指令，wait=true 时等待设备响应或超时后返回
+func (h *DeviceCommandHandler) SendCommand(c *gin.Context) {
+	id := c.Param("id")
+	var req models.DeviceCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("下发设备指令请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	command, err := h.commandService.SendCommand(c.Request.Context(), id, &req, middleware.CurrentUserID(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCommand):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeviceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeviceOffline):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCommandChannelUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCommandPublishFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("下发设备指令失败", utils.String("device_id", id), utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "下发设备指令失败"})
+		}
+		return
+	}
+
+	if c.Query("wait") != "true" {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "设备指令已下发",
+			"data":    command,
+		})
+		return
+	}
+
+	command, err = h.commandService.WaitForCompletion(c.Request.Context(), command.CommandID)
+	if err != nil {
+		h.logger.Error("等待设备指令响应失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "等待设备指令响应失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备指令已结束",
+		"data":    command,
+	})
+}
+
+// ListCommands 获取设备指令历史
+func (h *DeviceCommandHandler) ListCommands(c *gin.Context) {
+	id := c.Param("id")
+	var req models.DeviceCommandListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Error("设备指令历史请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	resp, err := h.commandService.ListCommands(c.Request.Context(), id, &req)
+	if err != nil {
+		h.logger.Error("获取设备指令历史失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备指令历史失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备指令历史成功",
+		"data":    resp,
+	})
+}
+
+// GetCommand 获取设备指令状态
+func (h *DeviceCommandHandler) GetCommand(c *gin.Context) {
+	id := c.Param("id")
+	command, err := h.commandService.GetCommand(c.Request.Context(), id, c.Param("command_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrCommandNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备指令失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备指令失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备指令成功",
+		"data":    command,
+	})
+}
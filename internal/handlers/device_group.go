@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceGroupHandler 设备分组处理器
+type DeviceGroupHandler struct {
+	groupService services.DeviceGroupService
+	logger       utils.Logger
+}
+
+// NewDeviceGroupHandler 创建设备分组处理器
+func NewDeviceGroupHandler(groupService services.DeviceGroupService, logger utils.Logger) *DeviceGroupHandler {
+	return &DeviceGroupHandler{
+		groupService: groupService,
+		logger:       logger,
+	}
+}
+
+// ListGroups 获取全部分组
+func (h *DeviceGroupHandler) ListGroups(c *gin.Context) {
+	groups, err := h.groupService.ListGroups(c.Request.Context())
+	if err != nil {
+		h.logger.Error("获取设备分组列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备分组列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备分组列表成功",
+		"data":    groups,
+	})
+}
+
+// CreateGroup 创建分组
+func (h *DeviceGroupHandler) CreateGroup(c *gin.Context) {
+	var req models.DeviceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("创建设备分组请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, "创建设备分组", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "设备分组创建成功",
+		"data":    group,
+	})
+}
+
+// GetGroup 获取分组及成员设备ID
+func (h *DeviceGroupHandler) GetGroup(c *gin.Context) {
+	id, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.GetGroup(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, "获取设备分组", err)
+		return
+	}
+	members, err := h.groupService.ListMembers(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, "获取设备分组", err)
+		return
+	}
+	group.DeviceCount = int64(len(members))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备分组成功",
+		"data": gin.H{
+			"group":      group,
+			"device_ids": members,
+		},
+	})
+}
+
+// UpdateGroup 更新分组
+func (h *DeviceGroupHandler) UpdateGroup(c *gin.Context) {
+	id, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("更新设备分组请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(c.Request.Context(), id, &req)
+	if err != nil {
+		h.handleError(c, "更新设备分组", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备分组更新成功",
+		"data":    group,
+	})
+}
+
+// DeleteGroup 删除分组
+func (h *DeviceGroupHandler) DeleteGroup(c *gin.Context) {
+	id, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(c.Request.Context(), id); err != nil {
+		h.handleError(c, "删除设备分组", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备分组删除成功",
+	})
+}
+
+// AddDevices 将设备加入分组
+func (h *DeviceGroupHandler) AddDevices(c *gin.Context) {
+	id, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.groupService.AddDevices(c.Request.Context(), id, req.DeviceIDs); err != nil {
+		h.handleError(c, "添加分组设备", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加分组设备成功",
+	})
+}
+
+// RemoveDevices 从分组移除设备
+func (h *DeviceGroupHandler) RemoveDevices(c *gin.Context) {
+	id, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	removed, err := h.groupService.RemoveDevices(c.Request.Context(), id, req.DeviceIDs)
+	if err != nil {
+		h.handleError(c, "移除分组设备", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "移除分组设备成功",
+		"data":    gin.H{"removed": removed},
+	})
+}
+
+// groupID 解析路径中的分组ID
+func (h *DeviceGroupHandler) groupID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分组ID参数错误"})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleError 统一映射分组服务错误
+func (h *DeviceGroupHandler) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeviceGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDeviceGroup), errors.Is(err, services.ErrDeviceNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeviceGroupExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(action+"失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": action + "失败"})
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDeviceImportRouter 注册设备导入导出接口，数据库中已有一台设备
+func newDeviceImportRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "已有设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	handler := NewDeviceHandler(services.NewDeviceService(repositories.NewDeviceRepository(db, logger), nil, logger), nil, nil, nil, nil, nil, logger)
+	router := gin.New()
+	router.POST("/api/v1/devices/import", handler.ImportDevices)
+	router.GET("/api/v1/devices/export", handler.ExportDevices)
+	return router
+}
+
+// TestDeviceHandler_ImportDevices 测试导入格式识别和结果状态码
+func TestDeviceHandler_ImportDevices(t *testing.T) {
+	validCSV := "id,name,type\nhcho_010,客厅,hcho\n"
+	duplicateCSV := "id,name,type\n" + testutil.DeviceID1 + ",已存在,hcho\n"
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		code        int
+	}{
+		{"csv by content type", "", "text/csv", validCSV, http.StatusCreated},
+		{"json by default", "", "application/json", `[{"id":"hcho_010","name":"客厅","type":"hcho"}]`, http.StatusCreated},
+		{"format overrides content type", "?format=csv", "application/octet-stream", validCSV, http.StatusCreated},
+		{"dry run", "?dry_run=true", "text/csv", duplicateCSV, http.StatusOK},
+		{"invalid records", "", "text/csv", duplicateCSV, http.StatusUnprocessableEntity},
+		{"malformed file", "", "text/csv", "id,serial\nx,y\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newDeviceImportRouter(t)
+			w := testutil.Serve(router, http.MethodPost, "/api/v1/devices/import"+tt.query, tt.contentType, tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+// TestDeviceHandler_ImportDevicesMultipart 测试通过multipart上传文件导入，格式取自文件扩展名
+func TestDeviceHandler_ImportDevicesMultipart(t *testing.T) {
+	router := newDeviceImportRouter(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "devices.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("id,name,type\nhcho_010,客厅,hcho\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/devices/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var result models.DeviceImportResult
+	testutil.DecodeData(t, w, &result)
+	assert.Equal(t, 1, result.Imported)
+}
+
+// TestDeviceHandler_ExportDevices 测试导出格式对应的响应头
+func TestDeviceHandler_ExportDevices(t *testing.T) {
+	router := newDeviceImportRouter(t)
+
+	tests := []struct {
+		query       string
+		code        int
+		contentType string
+		disposition string
+	}{
+		{"", http.StatusOK, "text/csv; charset=utf-8", "attachment; filename=devices.csv"},
+		{"?format=json", http.StatusOK, "application/json; charset=utf-8", "attachment; filename=devices.json"},
+		{"?format=xml", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run("export"+tt.query, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/api/v1/devices/export"+tt.query, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.disposition, w.Header().Get("Content-Disposition"))
+			assert.Contains(t, w.Body.String(), testutil.DeviceID1)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceProvisioningHandler 待审批设备处理器
+type DeviceProvisioningHandler struct {
+	provisioningService services.DeviceProvisioningService
+	logger              utils.Logger
+}
+
+// NewDeviceProvisioningHandler 创建待审批设备处理器
+func NewDeviceProvisioningHandler(provisioningService services.DeviceProvisioningService, logger utils.Logger) *DeviceProvisioningHandler {
+	return &DeviceProvisioningHandler{
+		provisioningService: provisioningService,
+		logger:              logger,
+	}
+}
+
+// ListPending 获取待审批设备列表
+func (h *DeviceProvisioningHandler) ListPending(c *gin.Context) {
+	pending, err := h.provisioningService.ListPending(c.Request.Context())
+	if err != nil {
+		h.logger.Error("获取待审批设备列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待审批设备列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取待审批设备列表成功",
+		"data": gin.H{
+			"mode":    h.provisioningService.Mode(),
+			"devices": pending,
+		},
+	})
+}
+
+// ApprovePending 审批通过待接入设备，请求体可选，用于指定设备名称、类型和位置
+func (h *DeviceProvisioningHandler) ApprovePending(c *gin.Context) {
+	id := c.Param("id")
+	var req models.PendingDeviceApproveRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.Error("审批设备请求参数错误", utils.ErrorField(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	device, err := h.provisioningService.ApprovePending(c.Request.Context(), id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPendingDeviceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidApproval):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("审批设备失败", utils.String("device_id", id), utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "审批设备失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备审批通过",
+		"data":    device,
+	})
+}
+
+// RejectPending 拒绝待接入设备并丢弃隔离数据
+func (h *DeviceProvisioningHandler) RejectPending(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.provisioningService.RejectPending(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrPendingDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("拒绝设备失败", utils.String("device_id", id), utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "拒绝设备失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已拒绝设备并丢弃隔离数据"})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeviceProvisioningHandler_Pending 测试待审批设备列表、审批（请求体可选）和拒绝接口
+func TestDeviceProvisioningHandler_Pending(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	provisioning := services.NewDeviceProvisioningService(config.ProvisioningConfig{Mode: "pending"},
+		repositories.NewDeviceRepository(db, logger), repositories.NewPendingDeviceRepository(db, logger),
+		repositories.NewUnifiedSensorDataRepository(db, logger), nil, logger)
+	formaldehyde := 0.05
+	batch := []models.UnifiedSensorData{}
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2, testutil.DeviceID3} {
+		batch = append(batch, models.UnifiedSensorData{DeviceID: id, DeviceType: models.DeviceTypeFormaldehyde, Timestamp: time.Now(), Formaldehyde: &formaldehyde})
+	}
+	_, err := provisioning.Admit(context.Background(), batch)
+	require.NoError(t, err)
+
+	handler := NewDeviceProvisioningHandler(provisioning, logger)
+	router := gin.New()
+	router.GET("/api/v1/devices/pending", handler.ListPending)
+	router.POST("/api/v1/devices/pending/:id/approve", handler.ApprovePending)
+	router.DELETE("/api/v1/devices/pending/:id", handler.RejectPending)
+
+	w := testutil.Serve(router, http.MethodGet, "/api/v1/devices/pending", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listed struct {
+		Mode    models.ProvisioningMode `json:"mode"`
+		Devices []models.PendingDevice  `json:"devices"`
+	}
+	testutil.DecodeData(t, w, &listed)
+	assert.Equal(t, models.ProvisioningModePending, listed.Mode)
+	assert.Len(t, listed.Devices, 3)
+
+	// 各请求依次执行
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+		device string // 审批通过后返回的设备名称
+	}{
+		{"approve with name", http.MethodPost, "/api/v1/devices/pending/" + testutil.DeviceID1 + "/approve", `{"name":"客厅甲醛"}`, http.StatusOK, "客厅甲醛"},
+		{"approve without body", http.MethodPost, "/api/v1/devices/pending/" + testutil.DeviceID2 + "/approve", "", http.StatusOK, testutil.DeviceID2},
+		{"approve twice", http.MethodPost, "/api/v1/devices/pending/" + testutil.DeviceID1 + "/approve", "", http.StatusNotFound, ""},
+		{"malformed body", http.MethodPost, "/api/v1/devices/pending/" + testutil.DeviceID3 + "/approve", `{"name":`, http.StatusBadRequest, ""},
+		{"reject", http.MethodDelete, "/api/v1/devices/pending/" + testutil.DeviceID3, "", http.StatusOK, ""},
+		{"reject twice", http.MethodDelete, "/api/v1/devices/pending/" + testutil.DeviceID3, "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := testutil.Serve(router, tt.method, tt.target, "", tt.body)
+		require.Equal(t, tt.code, w.Code, "%s: %s", tt.name, w.Body.String())
+		if tt.device != "" {
+			var device models.Device
+			testutil.DecodeData(t, w, &device)
+			assert.Equal(t, tt.device, device.Name, tt.name)
+			assert.Equal(t, models.DeviceTypeFormaldehyde, device.Type, tt.name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeviceHandler_ConfigSync 测试通过更新设备设置期望配置，配置未变化时不递增版本
+func TestDeviceHandler_ConfigSync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	configService := services.NewDeviceConfigService(repositories.NewDeviceConfigStateRepository(db, logger), deviceRepo, logger)
+	handler := NewDeviceHandler(services.NewDeviceService(deviceRepo, configService, logger), nil, nil, configService, nil, nil, logger)
+	router := gin.New()
+	router.PUT("/devices/:id", handler.UpdateDevice)
+	router.GET("/devices/:id/config", handler.GetConfigSync)
+
+	syncStatus := func() models.DeviceConfigSyncStatus {
+		w := testutil.Serve(router, http.MethodGet, "/devices/"+testutil.DeviceID1+"/config", "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var status models.DeviceConfigSyncStatus
+		testutil.DecodeData(t, w, &status)
+		return status
+	}
+
+	// 各更新依次执行
+	desired := `{"config":{"report_interval":60,"sensors":{"formaldehyde":true}}}`
+	tests := []struct {
+		name    string
+		body    string
+		version int64
+	}{
+		{"no desired config", "", 0},
+		{"set config", desired, 1},
+		{"unchanged config", desired, 1},
+		{"other fields only", `{"name":"客厅"}`, 1},
+		{"changed config", `{"config":{"report_interval":30}}`, 2},
+	}
+	for _, tt := range tests {
+		if tt.body != "" {
+			w := testutil.Serve(router, http.MethodPut, "/devices/"+testutil.DeviceID1, "", tt.body)
+			require.Equal(t, http.StatusOK, w.Code, "%s: %s", tt.name, w.Body.String())
+		}
+		status := syncStatus()
+		assert.Equal(t, tt.version, status.DesiredVersion, tt.name)
+		assert.Equal(t, tt.version == 0, status.InSync, tt.name)
+	}
+	assert.Equal(t, map[string]interface{}{"report_interval": float64(30)}, syncStatus().Delta)
+
+	assert.Equal(t, http.StatusNotFound, testutil.Serve(router, http.MethodGet, "/devices/ghost/config", "", "").Code)
+}
+
+// TestDeviceHandler_GetShadow 测试设备影子接口返回最近取值，未知设备返回404
+func TestDeviceHandler_GetShadow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	configService := services.NewDeviceConfigService(repositories.NewDeviceConfigStateRepository(db, logger), deviceRepo, logger)
+	shadowService := services.NewDeviceShadowService(repositories.NewDeviceShadowRepository(db, logger), deviceRepo,
+		repositories.NewDeviceRuntimeStatusRepository(db, logger), configService, nil, logger)
+	formaldehyde := 0.05
+	require.NoError(t, shadowService.ApplyReadings(context.Background(), []models.UnifiedSensorData{
+		{DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeFormaldehyde, SensorID: "s1", Timestamp: time.Now(), Formaldehyde: &formaldehyde},
+	}))
+	handler := NewDeviceHandler(services.NewDeviceService(deviceRepo, configService, logger), nil, nil, configService, shadowService, nil, logger)
+	router := gin.New()
+	router.GET("/devices/:id/shadow", handler.GetShadow)
+
+	tests := []struct {
+		name     string
+		deviceID string
+		code     int
+	}{
+		{"known device", testutil.DeviceID1, http.StatusOK},
+		{"unknown device", "unknown-device", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/devices/"+tt.deviceID+"/shadow", "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			var shadow models.DeviceShadowView
+			testutil.DecodeData(t, w, &shadow)
+			assert.Equal(t, models.DeviceTypeFormaldehyde, shadow.DeviceType)
+			assert.Equal(t, 0.05, shadow.Metrics["formaldehyde"].Value)
+		})
+	}
+}
+
+// TestDeviceHandler_GetDeviceStatus 测试查询设备在线状态，未知设备返回404
+func TestDeviceHandler_GetDeviceStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	presence := services.NewDevicePresenceService(config.DeviceConfig{}, deviceRepo,
+		repositories.NewDeviceRuntimeStatusRepository(db, logger), nil, logger)
+	require.NoError(t, presence.MarkConnected(context.Background(), testutil.DeviceID1))
+	handler := NewDeviceHandler(services.NewDeviceService(deviceRepo, nil, logger), nil, presence, nil, nil, nil, logger)
+	router := gin.New()
+	router.GET("/devices/:id/status", handler.GetDeviceStatus)
+
+	tests := []struct {
+		name     string
+		deviceID string
+		code     int
+		online   bool
+	}{
+		{"connected device", testutil.DeviceID1, http.StatusOK, true},
+		{"never connected", testutil.DeviceID2, http.StatusOK, false},
+		{"unknown device", "unknown-device", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/devices/"+tt.deviceID+"/status", "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			var status models.DeviceRuntimeStatus
+			testutil.DecodeData(t, w, &status)
+			assert.Equal(t, tt.deviceID, status.DeviceID)
+			assert.Equal(t, tt.online, status.Online)
+			if tt.online {
+				assert.WithinDuration(t, time.Now(), status.LastHeartbeat, time.Minute)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FirmwareHandler 固件与升级活动处理器
+type FirmwareHandler struct {
+	firmwareService services.FirmwareService
+	campaignService services.FirmwareCampaignService
+	logger          utils.Logger
+}
+
+// NewFirmwareHandler 创建固件与升级活动处理器
+func NewFirmwareHandler(firmwareService services.FirmwareService, campaignService services.FirmwareCampaignService, logger utils.Logger) *FirmwareHandler {
+	return &FirmwareHandler{
+		firmwareService: firmwareService,
+		campaignService: campaignService,
+		logger:          logger,
+	}
+}
+
+// UploadFirmware 上传固件（multipart表单，文件字段为file）
+func (h *FirmwareHandler) UploadFirmware(c *gin.Context) {
+	var req models.FirmwareUploadRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Error("上传固件请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少固件文件"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		h.logger.Error("读取上传的固件文件失败", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取固件文件失败"})
+		return
+	}
+	defer file.Close()
+
+	firmware, err := h.firmwareService.UploadFirmware(c.Request.Context(), &req, header.Filename, file, middleware.CurrentUserID(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFirmware):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrFirmwareExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrFirmwareTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("上传固件失败", utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传固件失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "固件上传成功",
+		"data":    firmware,
+	})
+}
+
+// ListFirmware 获取固件列表，可按device_type过滤
+func (h *FirmwareHandler) ListFirmware(c *gin.Context) {
+	firmware, err := h.firmwareService.ListFirmware(c.Request.Context(), models.DeviceType(c.Query("device_type")))
+	if err != nil {
+		h.logger.Error("获取固件列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取固件列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取固件列表成功",
+		"data":    firmware,
+	})
+}
+
+// GetFirmware 获取固件信息
+func (h *FirmwareHandler) GetFirmware(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "固件ID参数错误"})
+		return
+	}
+
+	firmware, err := h.firmwareService.GetFirmware(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrFirmwareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取固件失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取固件失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取固件成功",
+		"data":    firmware,
+	})
+}
+
+// DownloadFirmware 设备凭签名链接下载固件（无需登录）
+func (h *FirmwareHandler) DownloadFirmware(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "固件ID参数错误"})
+		return
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	deviceID := c.Query("device_id")
+
+	firmware, err := h.firmwareService.VerifyDownload(c.Request.Context(), id, deviceID, expires, c.Query("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidDownloadLink):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrFirmwareNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("下载固件失败", utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "下载固件失败"})
+		}
+		return
+	}
+
+	h.logger.Info("设备下载固件",
+		utils.String("device_id", deviceID),
+		utils.String("version", firmware.Version))
+	c.Header("X-Firmware-SHA256", firmware.SHA256)
+	c.FileAttachment(firmware.FilePath, fmt.Sprintf("%s-%s.bin", firmware.DeviceType, firmware.Version))
+}
+
+// CreateCampaign 创建固件升级活动
+func (h *FirmwareHandler) CreateCampaign(c *gin.Context) {
+	var req models.FirmwareCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("创建升级活动请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(c.Request.Context(), &req, middleware.CurrentUserID(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCampaign) || errors.Is(err, services.ErrFirmwareNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("创建升级活动失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建升级活动失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "升级活动创建成功",
+		"data":    campaign,
+	})
+}
+
+// ListCampaigns 获取升级活动列表，可按status过滤
+func (h *FirmwareHandler) ListCampaigns(c *gin.Context) {
+	campaigns, err := h.campaignService.ListCampaigns(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		h.logger.Error("获取升级活动列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取升级活动列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取升级活动列表成功",
+		"data":    campaigns,
+	})
+}
+
+// GetCampaign 获取升级活动及进度统计
+func (h *FirmwareHandler) GetCampaign(c *gin.Context) {
+	h.campaignAction(c, "获取升级活动", h.campaignService.GetCampaign)
+}
+
+// StartCampaign 开始或恢复升级活动
+func (h *FirmwareHandler) StartCampaign(c *gin.Context) {
+	h.campaignAction(c, "开始升级活动", h.campaignService.StartCampaign)
+}
+
+// PauseCampaign 暂停升级活动
+func (h *FirmwareHandler) PauseCampaign(c *gin.Context) {
+	h.campaignAction(c, "暂停升级活动", h.campaignService.PauseCampaign)
+}
+
+// ListUpdates 获取升级活动中各设备的升级记录
+func (h *FirmwareHandler) ListUpdates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "升级活动ID参数错误"})
+		return
+	}
+	var req models.FirmwareUpdateListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	resp, err := h.campaignService.ListUpdates(c.Request.Context(), id, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrCampaignNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取设备升级记录失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备升级记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取设备升级记录成功",
+		"data":    resp,
+	})
+}
+
+// campaignAction 解析活动ID，执行操作并统一映射错误
+func (h *FirmwareHandler) campaignAction(c *gin.Context, action string, fn func(ctx context.Context, id uint64) (*models.FirmwareCampaign, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "升级活动ID参数错误"})
+		return
+	}
+
+	campaign, err := fn(c.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCampaignNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCampaignState):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOTAChannelUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error(action+"失败", utils.Int64("campaign_id", int64(id)), utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": action + "失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": action + "成功",
+		"data":    campaign,
+	})
+}
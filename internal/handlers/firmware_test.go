@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// firmwareContent 测试固件内容
+var firmwareContent = []byte("firmware-binary-1.4.0")
+
+// firmwareFixture 固件接口测试环境，数据库中已有设备1和设备2
+type firmwareFixture struct {
+	router   *gin.Engine
+	firmware services.FirmwareService
+}
+
+// newFirmwareFixture 注册固件和升级活动接口，升级活动服务未设置MQTT发布器
+func newFirmwareFixture(t *testing.T) *firmwareFixture {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+	cfg := config.FirmwareConfig{
+		Dir:           t.TempDir(),
+		MaxSize:       1,
+		PublicBaseURL: "http://firmware.example.com",
+		URLSecret:     "test-secret",
+		UpdateTimeout: 3600,
+	}
+	f := &firmwareFixture{firmware: services.NewFirmwareService(cfg, repositories.NewFirmwareRepository(db, logger), logger)}
+	campaigns := services.NewFirmwareCampaignService(cfg,
+		repositories.NewFirmwareCampaignRepository(db, logger), repositories.NewFirmwareUpdateRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger), repositories.NewDeviceRuntimeStatusRepository(db, logger), f.firmware, logger)
+
+	handler := NewFirmwareHandler(f.firmware, campaigns, logger)
+	f.router = gin.New()
+	f.router.POST("/api/v1/firmware", handler.UploadFirmware)
+	f.router.GET("/api/v1/firmware/:id/download", handler.DownloadFirmware)
+	f.router.POST("/api/v1/firmware-campaigns", handler.CreateCampaign)
+	f.router.GET("/api/v1/firmware-campaigns/:id", handler.GetCampaign)
+	f.router.POST("/api/v1/firmware-campaigns/:id/start", handler.StartCampaign)
+	return f
+}
+
+// upload 以multipart表单上传固件
+func (f *firmwareFixture) upload(t *testing.T, version string) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	require.NoError(t, form.WriteField("device_type", string(models.DeviceTypeFormaldehyde)))
+	require.NoError(t, form.WriteField("version", version))
+	part, err := form.CreateFormFile("file", "hcho.bin")
+	require.NoError(t, err)
+	_, err = part.Write(firmwareContent)
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+	return testutil.Serve(f.router, http.MethodPost, "/api/v1/firmware", form.FormDataContentType(), body.String())
+}
+
+// TestFirmwareHandler_UploadFirmware 测试上传固件计算SHA-256，重复版本和非法版本号被拒绝
+func TestFirmwareHandler_UploadFirmware(t *testing.T) {
+	f := newFirmwareFixture(t)
+	digest := sha256.Sum256(firmwareContent)
+
+	// 各上传依次执行
+	tests := []struct {
+		name    string
+		version string
+		code    int
+	}{
+		{"upload", "1.4.0", http.StatusCreated},
+		{"duplicate version", "1.4.0", http.StatusConflict},
+		{"invalid version", "../1.4.0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := f.upload(t, tt.version)
+		require.Equal(t, tt.code, w.Code, "%s: %s", tt.name, w.Body.String())
+		if tt.code != http.StatusCreated {
+			continue
+		}
+		var uploaded models.Firmware
+		testutil.DecodeData(t, w, &uploaded)
+		assert.Equal(t, hex.EncodeToString(digest[:]), uploaded.SHA256)
+		assert.Equal(t, int64(len(firmwareContent)), uploaded.Size)
+	}
+}
+
+// TestFirmwareHandler_DownloadFirmware 测试签名链接可直接下载，篡改设备ID后拒绝
+func TestFirmwareHandler_DownloadFirmware(t *testing.T) {
+	f := newFirmwareFixture(t)
+	require.Equal(t, http.StatusCreated, f.upload(t, "1.4.0").Code)
+	firmware, err := f.firmware.GetFirmware(context.Background(), 1)
+	require.NoError(t, err)
+	link, err := url.Parse(f.firmware.DownloadURL(firmware, testutil.DeviceID1, time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	tampered := link.Query()
+	tampered.Set("device_id", testutil.DeviceID2)
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+	}{
+		{"signed link", link.RequestURI(), http.StatusOK},
+		{"tampered device", link.Path + "?" + tampered.Encode(), http.StatusForbidden},
+		{"invalid id", "/api/v1/firmware/abc/download", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(f.router, http.MethodGet, tt.target, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code == http.StatusOK {
+				assert.Equal(t, firmwareContent, w.Body.Bytes())
+				assert.Equal(t, firmware.SHA256, w.Header().Get("X-Firmware-SHA256"))
+			}
+		})
+	}
+}
+
+// TestFirmwareHandler_Campaign 测试创建升级活动校验阶段，MQTT升级通道不可用时无法开始
+func TestFirmwareHandler_Campaign(t *testing.T) {
+	f := newFirmwareFixture(t)
+	require.Equal(t, http.StatusCreated, f.upload(t, "1.4.0").Code)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"stages must end with 100", `{"name":"bad","firmware_id":1,"stages":[50]}`, http.StatusBadRequest},
+		{"unknown firmware", `{"name":"bad","firmware_id":9,"stages":[100]}`, http.StatusBadRequest},
+		{"two stages", `{"name":"hcho 1.4.0","firmware_id":1,"stages":[50,100]}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := testutil.Serve(f.router, http.MethodPost, "/api/v1/firmware-campaigns", "", tt.body)
+		assert.Equal(t, tt.code, w.Code, "%s: %s", tt.name, w.Body.String())
+	}
+
+	w := testutil.Serve(f.router, http.MethodGet, "/api/v1/firmware-campaigns/1", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var campaign models.FirmwareCampaign
+	testutil.DecodeData(t, w, &campaign)
+	assert.Equal(t, 2, campaign.TotalDevices)
+	assert.Equal(t, models.FirmwareCampaignStatusDraft, campaign.Status)
+
+	path := "/api/v1/firmware-campaigns/" + strconv.FormatUint(campaign.ID, 10)
+	assert.Equal(t, http.StatusServiceUnavailable, testutil.Serve(f.router, http.MethodPost, path+"/start", "", "").Code)
+}
@@ -2,9 +2,19 @@ package handlers
 
 // Handlers 处理器集合
 type Handlers struct {
-	Device     *DeviceHandler
-	AirQuality *AirQualityHandler
-	User       *UserHandler
-	Alert      *AlertHandler
-	Config     *ConfigHandler
+	Device        *DeviceHandler
+	DeviceCommand *DeviceCommandHandler
+	Provisioning  *DeviceProvisioningHandler
+	Location      *LocationHandler
+	DeviceGroup   *DeviceGroupHandler
+	Firmware      *FirmwareHandler
+	AirQuality    *AirQualityHandler
+	SensorData    *SensorDataHandler
+	User          *UserHandler
+	Role          *RoleHandler
+	Alert         *AlertHandler
+	AlertRule     *AlertRuleHandler
+	Config        *ConfigHandler
+	MQTTACL       *MQTTACLHandler
+	Health        *HealthHandler
 }
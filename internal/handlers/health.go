@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler 健康检查处理器
+type HealthHandler struct {
+	service config.ServiceConfig
+	db      *utils.Database
+	spool   *utils.SensorDataSpool
+}
+
+// NewHealthHandler 创建健康检查处理器（db、spool可为nil）
+func NewHealthHandler(service config.ServiceConfig, db *utils.Database, spool *utils.SensorDataSpool) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+		db:      db,
+		spool:   spool,
+	}
+}
+
+// Check 健康检查，包含数据库连通性与落盘缓冲积压情况
+func (h *HealthHandler) Check(c *gin.Context) {
+	status := "ok"
+	response := gin.H{
+		"timestamp": time.Now().Unix(),
+		"service":   h.service.Name,
+		"version":   h.service.Version,
+	}
+
+	if h.db != nil {
+		if err := h.db.Ping(); err != nil {
+			status = "degraded"
+			response["database"] = gin.H{"status": "unavailable", "error": err.Error()}
+		} else {
+			response["database"] = gin.H{"status": "ok"}
+		}
+	}
+
+	if h.spool != nil {
+		stats := h.spool.Stats()
+		if depth, ok := stats["depth"].(int64); ok && depth > 0 && status == "ok" {
+			status = "draining"
+		}
+		response["spool"] = stats
+	}
+
+	response["status"] = status
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocationHandler 位置层级处理器
+type LocationHandler struct {
+	locationService services.LocationService
+	logger          utils.Logger
+}
+
+// NewLocationHandler 创建位置层级处理器
+func NewLocationHandler(locationService services.LocationService, logger utils.Logger) *LocationHandler {
+	return &LocationHandler{
+		locationService: locationService,
+		logger:          logger,
+	}
+}
+
+// ListLocations 获取全部位置（按路径排序的平铺列表）
+func (h *LocationHandler) ListLocations(c *gin.Context) {
+	locations, err := h.locationService.ListLocations(c.Request.Context())
+	if err != nil {
+		h.logger.Error("获取位置列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取位置列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取位置列表成功",
+		"data":    locations,
+	})
+}
+
+// GetTree 获取位置树
+func (h *LocationHandler) GetTree(c *gin.Context) {
+	tree, err := h.locationService.GetTree(c.Request.Context())
+	if err != nil {
+		h.logger.Error("获取位置树失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取位置树失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取位置树成功",
+		"data":    tree,
+	})
+}
+
+// CreateLocation 创建位置
+func (h *LocationHandler) CreateLocation(c *gin.Context) {
+	var req models.LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("创建位置请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	location, err := h.locationService.CreateLocation(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, "创建位置", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "位置创建成功",
+		"data":    location,
+	})
+}
+
+// GetLocation 获取位置
+func (h *LocationHandler) GetLocation(c *gin.Context) {
+	id, ok := h.locationID(c)
+	if !ok {
+		return
+	}
+
+	location, err := h.locationService.GetLocation(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, "获取位置", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取位置成功",
+		"data":    location,
+	})
+}
+
+// UpdateLocation 更新或移动位置
+func (h *LocationHandler) UpdateLocation(c *gin.Context) {
+	id, ok := h.locationID(c)
+	if !ok {
+		return
+	}
+	var req models.LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("更新位置请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	location, err := h.locationService.UpdateLocation(c.Request.Context(), id, &req)
+	if err != nil {
+		h.handleError(c, "更新位置", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "位置更新成功",
+		"data":    location,
+	})
+}
+
+// DeleteLocation 删除位置
+func (h *LocationHandler) DeleteLocation(c *gin.Context) {
+	id, ok := h.locationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.locationService.DeleteLocation(c.Request.Context(), id); err != nil {
+		h.handleError(c, "删除位置", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "位置删除成功",
+	})
+}
+
+// AssignDevices 将设备分配到位置
+func (h *LocationHandler) AssignDevices(c *gin.Context) {
+	id, ok := h.locationID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	updated, err := h.locationService.AssignDevices(c.Request.Context(), id, req.DeviceIDs)
+	if err != nil {
+		h.handleError(c, "分配设备", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备分配成功",
+		"data":    gin.H{"updated": updated},
+	})
+}
+
+// UnassignDevices 解除设备与位置的关联
+func (h *LocationHandler) UnassignDevices(c *gin.Context) {
+	id, ok := h.locationID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	updated, err := h.locationService.UnassignDevices(c.Request.Context(), id, req.DeviceIDs)
+	if err != nil {
+		h.handleError(c, "解除设备位置", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "解除设备位置成功",
+		"data":    gin.H{"updated": updated},
+	})
+}
+
+// locationID 解析路径中的位置ID
+func (h *LocationHandler) locationID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "位置ID参数错误"})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleError 统一映射位置服务错误
+func (h *LocationHandler) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrLocationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLocation), errors.Is(err, services.ErrDeviceNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLocationInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(action+"失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": action + "失败"})
+	}
+}
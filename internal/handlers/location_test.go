@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 位置测试路由中按创建顺序分配的位置和分组ID：总部→A栋→101、分部，分组“会议室”
+const (
+	siteID     = 1
+	buildingID = 2
+	roomID     = 3
+	otherID    = 4
+	groupID    = 1
+)
+
+// newLocationRouter 注册位置、分组、设备列表和数据统计接口，并通过接口建立位置树和分组：
+// 设备1在101，设备2在A栋，设备3在分部，设备2、3属于分组
+func newLocationRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	now := time.Now()
+	for i, id := range []string{testutil.DeviceID1, testutil.DeviceID2, testutil.DeviceID3} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+		value := float64(i+1) * 0.01
+		require.NoError(t, db.Create(&models.UnifiedSensorData{DeviceID: id, DeviceType: models.DeviceTypeFormaldehyde,
+			Timestamp: now.Add(-time.Minute), Formaldehyde: &value}).Error)
+	}
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	locationRepo := repositories.NewLocationRepository(db, logger)
+	groupRepo := repositories.NewDeviceGroupRepository(db, logger)
+	scope := services.NewDeviceScopeService(deviceRepo, locationRepo, groupRepo, logger)
+	locationHandler := NewLocationHandler(services.NewLocationService(locationRepo, deviceRepo, scope, logger), logger)
+	groupHandler := NewDeviceGroupHandler(services.NewDeviceGroupService(groupRepo, deviceRepo, scope, logger), logger)
+	deviceHandler := NewDeviceHandler(services.NewDeviceService(deviceRepo, nil, logger), nil, nil, nil, nil, scope, logger)
+	dataService := services.NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, nil, nil, nil, nil, "", logger)
+
+	router := gin.New()
+	router.GET("/api/v1/devices", deviceHandler.ListDevices)
+	router.POST("/api/v1/locations", locationHandler.CreateLocation)
+	router.GET("/api/v1/locations/tree", locationHandler.GetTree)
+	router.PUT("/api/v1/locations/:id", locationHandler.UpdateLocation)
+	router.DELETE("/api/v1/locations/:id", locationHandler.DeleteLocation)
+	router.POST("/api/v1/locations/:id/devices", locationHandler.AssignDevices)
+	router.POST("/api/v1/device-groups", groupHandler.CreateGroup)
+	router.POST("/api/v1/device-groups/:id/devices", groupHandler.AddDevices)
+	router.GET("/api/v1/data/statistics", NewSensorDataHandler(dataService, scope, logger).GetStatistics)
+
+	for _, step := range []struct {
+		target, body string
+		code         int
+	}{
+		{"/api/v1/locations", `{"name":"总部","type":"site"}`, http.StatusCreated},
+		{"/api/v1/locations", fmt.Sprintf(`{"name":"A栋","type":"building","parent_id":%d}`, siteID), http.StatusCreated},
+		{"/api/v1/locations", fmt.Sprintf(`{"name":"101","type":"room","parent_id":%d}`, buildingID), http.StatusCreated},
+		{"/api/v1/locations", `{"name":"分部","type":"site"}`, http.StatusCreated},
+		{fmt.Sprintf("/api/v1/locations/%d/devices", roomID), `{"device_ids":["` + testutil.DeviceID1 + `"]}`, http.StatusOK},
+		{fmt.Sprintf("/api/v1/locations/%d/devices", buildingID), `{"device_ids":["` + testutil.DeviceID2 + `"]}`, http.StatusOK},
+		{fmt.Sprintf("/api/v1/locations/%d/devices", otherID), `{"device_ids":["` + testutil.DeviceID3 + `"]}`, http.StatusOK},
+		{"/api/v1/device-groups", `{"name":"会议室"}`, http.StatusCreated},
+		{fmt.Sprintf("/api/v1/device-groups/%d/devices", groupID), `{"device_ids":["` + testutil.DeviceID2 + `","` + testutil.DeviceID3 + `"]}`, http.StatusOK},
+	} {
+		w := testutil.Serve(router, http.MethodPost, step.target, "", step.body)
+		require.Equal(t, step.code, w.Code, "%s %s", step.target, w.Body.String())
+	}
+	return router
+}
+
+// TestLocationHandler_ErrorStatus 测试位置和分组接口的错误映射
+func TestLocationHandler_ErrorStatus(t *testing.T) {
+	router := newLocationRouter(t)
+
+	tests := []struct {
+		name           string
+		method, target string
+		body           string
+		code           int
+	}{
+		{"top level must be site", http.MethodPost, "/api/v1/locations", `{"name":"101","type":"room"}`, http.StatusBadRequest},
+		{"building under room", http.MethodPost, "/api/v1/locations", fmt.Sprintf(`{"name":"B栋","type":"building","parent_id":%d}`, roomID), http.StatusBadRequest},
+		{"assign unknown device", http.MethodPost, fmt.Sprintf("/api/v1/locations/%d/devices", roomID), `{"device_ids":["unknown"]}`, http.StatusBadRequest},
+		{"assign to missing location", http.MethodPost, "/api/v1/locations/999/devices", `{"device_ids":["` + testutil.DeviceID1 + `"]}`, http.StatusNotFound},
+		{"move under own descendant", http.MethodPut, fmt.Sprintf("/api/v1/locations/%d", siteID), fmt.Sprintf(`{"name":"总部","type":"site","parent_id":%d}`, roomID), http.StatusBadRequest},
+		{"delete with children", http.MethodDelete, fmt.Sprintf("/api/v1/locations/%d", siteID), "", http.StatusConflict},
+		{"delete missing", http.MethodDelete, "/api/v1/locations/999", "", http.StatusNotFound},
+		{"duplicate group", http.MethodPost, "/api/v1/device-groups", `{"name":"会议室"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, tt.method, tt.target, "", tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+// TestDeviceHandler_ListDevicesByScope 测试按位置（含下级位置）和分组筛选设备列表
+func TestDeviceHandler_ListDevicesByScope(t *testing.T) {
+	router := newLocationRouter(t)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		want  []string
+	}{
+		{"all", "", http.StatusOK, []string{testutil.DeviceID1, testutil.DeviceID2, testutil.DeviceID3}},
+		{"site includes descendants", fmt.Sprintf("location_id=%d", siteID), http.StatusOK, []string{testutil.DeviceID1, testutil.DeviceID2}},
+		{"room", fmt.Sprintf("location_id=%d", roomID), http.StatusOK, []string{testutil.DeviceID1}},
+		{"group", fmt.Sprintf("group_id=%d", groupID), http.StatusOK, []string{testutil.DeviceID2, testutil.DeviceID3}},
+		{"group within site", fmt.Sprintf("group_id=%d&location_id=%d", groupID, siteID), http.StatusOK, []string{testutil.DeviceID2}},
+		{"missing location", "location_id=999", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/api/v1/devices?"+tt.query, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			var data struct {
+				Devices []models.Device `json:"devices"`
+				Total   int64           `json:"total"`
+			}
+			testutil.DecodeData(t, w, &data)
+			ids := []string{}
+			for _, d := range data.Devices {
+				ids = append(ids, d.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+			assert.Equal(t, int64(len(tt.want)), data.Total)
+		})
+	}
+}
+
+// TestSensorDataHandler_GetStatisticsByScope 测试按位置和分组统计数据，必须指定范围
+func TestSensorDataHandler_GetStatisticsByScope(t *testing.T) {
+	router := newLocationRouter(t)
+
+	tests := []struct {
+		name        string
+		query       string
+		code        int
+		deviceCount int
+		dataCount   int64
+		avg, max    float64
+	}{
+		{"site", fmt.Sprintf("location_id=%d", siteID), http.StatusOK, 2, 2, 0.015, 0.02},
+		{"room", fmt.Sprintf("location_id=%d", roomID), http.StatusOK, 1, 1, 0.01, 0.01},
+		{"group", fmt.Sprintf("group_id=%d", groupID), http.StatusOK, 2, 2, 0.025, 0.03},
+		{"scope required", "", http.StatusBadRequest, 0, 0, 0, 0},
+		{"missing group", "group_id=999", http.StatusNotFound, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/api/v1/data/statistics?"+tt.query, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			var data struct {
+				DeviceCount int                                `json:"device_count"`
+				Statistics  models.UnifiedSensorDataStatistics `json:"statistics"`
+			}
+			testutil.DecodeData(t, w, &data)
+			assert.Equal(t, tt.deviceCount, data.DeviceCount)
+			assert.Equal(t, tt.dataCount, data.Statistics.DataCount)
+			assert.InDelta(t, tt.avg, data.Statistics.FormaldehydeAvg, 1e-9)
+			assert.InDelta(t, tt.max, data.Statistics.FormaldehydeMax, 1e-9)
+			assert.Zero(t, data.Statistics.PM25Avg)
+		})
+	}
+}
+
+// TestLocationHandler_MoveSubtree 测试移动位置后位置树和筛选结果随之变化
+func TestLocationHandler_MoveSubtree(t *testing.T) {
+	router := newLocationRouter(t)
+
+	w := testutil.Serve(router, http.MethodPut, fmt.Sprintf("/api/v1/locations/%d", roomID), "",
+		fmt.Sprintf(`{"name":"101","type":"room","parent_id":%d}`, otherID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = testutil.Serve(router, http.MethodGet, "/api/v1/locations/tree", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var tree []models.LocationNode
+	testutil.DecodeData(t, w, &tree)
+	require.Len(t, tree, 2)
+	assert.Equal(t, "分部", tree[1].Name)
+	require.Len(t, tree[1].Children, 1)
+	assert.Equal(t, fmt.Sprintf("/%d/%d/", otherID, roomID), tree[1].Children[0].Path)
+
+	w = testutil.Serve(router, http.MethodGet, fmt.Sprintf("/api/v1/devices?location_id=%d", otherID), "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var data struct {
+		Total int64 `json:"total"`
+	}
+	testutil.DecodeData(t, w, &data)
+	assert.Equal(t, int64(2), data.Total)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MQTTACLHandler MQTT访问控制规则处理器
+type MQTTACLHandler struct {
+	aclService services.MQTTACLService
+	logger     utils.Logger
+}
+
+// NewMQTTACLHandler 创建MQTT访问控制规则处理器
+func NewMQTTACLHandler(aclService services.MQTTACLService, logger utils.Logger) *MQTTACLHandler {
+	return &MQTTACLHandler{
+		aclService: aclService,
+		logger:     logger,
+	}
+}
+
+// ListRules 列出ACL规则
+func (h *MQTTACLHandler) ListRules(c *gin.Context) {
+	rules, err := h.aclService.ListRules(c.Request.Context(), c.Query("username"))
+	if err != nil {
+		h.logger.Error("获取ACL规则列表失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取ACL规则列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取ACL规则列表成功",
+		"data":    rules,
+	})
+}
+
+// CreateRule 创建ACL规则
+func (h *MQTTACLHandler) CreateRule(c *gin.Context) {
+	var req models.MQTTACLRuleCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("创建ACL规则请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.aclService.CreateRule(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "ACL规则创建成功",
+		"data":    rule,
+	})
+}
+
+// GetRule 获取ACL规则
+func (h *MQTTACLHandler) GetRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	rule, err := h.aclService.GetRule(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ACL规则不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取ACL规则成功",
+		"data":    rule,
+	})
+}
+
+// UpdateRule 更新ACL规则
+func (h *MQTTACLHandler) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	var req models.MQTTACLRuleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("更新ACL规则请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.aclService.UpdateRule(c.Request.Context(), id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ACL规则更新成功",
+		"data":    rule,
+	})
+}
+
+// DeleteRule 删除ACL规则
+func (h *MQTTACLHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID参数错误"})
+		return
+	}
+
+	if err := h.aclService.DeleteRule(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除ACL规则失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ACL规则删除成功",
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/testutil"
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRBAC_RequirePermission 测试角色管理及权限校验（含通配符），修改角色权限后立即生效
+func TestRBAC_RequirePermission(t *testing.T) {
+	f := newUserFixture(t)
+	ctx := context.Background()
+	admin, err := f.roles.CreateRole(ctx, &models.RoleCreateRequest{Name: "admin", Permissions: []string{"*"}})
+	require.NoError(t, err)
+	require.NoError(t, f.users.CreateUserWithRoles(ctx,
+		&models.User{Username: "admin", Email: "admin@air-quality.local", PasswordHash: "admin123"}, []uint64{admin.ID}))
+
+	perm := func(permissions ...string) gin.HandlerFunc {
+		return middleware.RequirePermission(f.roles, permissions...)
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	roleHandler := NewRoleHandler(f.roles, testutil.NewLogger(t))
+	secured := f.router.Group("", middleware.Auth(f.auth))
+	devices := secured.Group("/devices", perm(models.PermissionDeviceRead))
+	devices.GET("", ok)
+	devices.POST("", perm(models.PermissionDeviceWrite), ok)
+	devices.DELETE("/:id", perm(models.PermissionDeviceDelete), ok)
+	secured.GET("/data/export", perm(models.PermissionDataExport), ok)
+	roles := secured.Group("/roles", perm(models.PermissionRoleRead))
+	roles.POST("", perm(models.PermissionRoleWrite), roleHandler.CreateRole)
+	roles.PUT("/:id", perm(models.PermissionRoleWrite), roleHandler.UpdateRole)
+	roles.DELETE("/:id", perm(models.PermissionRoleDelete), roleHandler.DeleteRole)
+
+	adminToken := f.login(t, "admin", "admin123")
+	bobToken := f.login(t, "bob", "secret123")
+	operatorPath := "/roles/" + strconv.FormatUint(f.operator.ID, 10)
+
+	// 各请求依次执行；bob拥有operator角色（device:*、data:read）
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		code   int
+	}{
+		{"unsupported permission", http.MethodPost, "/roles", adminToken, `{"name":"bad","permissions":["device:fly"]}`, http.StatusBadRequest},
+		{"create role", http.MethodPost, "/roles", adminToken, `{"name":"auditor","permissions":["data:export"]}`, http.StatusCreated},
+		{"duplicate role", http.MethodPost, "/roles", adminToken, `{"name":"operator"}`, http.StatusConflict},
+		{"wildcard read", http.MethodGet, "/devices", bobToken, "", http.StatusNoContent},
+		{"wildcard write", http.MethodPost, "/devices", bobToken, "", http.StatusNoContent},
+		{"wildcard delete", http.MethodDelete, "/devices/1", bobToken, "", http.StatusNoContent},
+		{"missing permission", http.MethodGet, "/data/export", bobToken, "", http.StatusForbidden},
+		{"role write denied", http.MethodPost, "/roles", bobToken, `{"name":"hacker","permissions":["*"]}`, http.StatusForbidden},
+		{"update role", http.MethodPut, operatorPath, adminToken, `{"permissions":["device:read","data:export"]}`, http.StatusOK},
+		{"revoked permission", http.MethodPost, "/devices", bobToken, "", http.StatusForbidden},
+		{"granted permission", http.MethodGet, "/data/export", bobToken, "", http.StatusNoContent},
+		{"delete assigned role", http.MethodDelete, operatorPath, adminToken, "", http.StatusConflict},
+		{"admin wildcard", http.MethodGet, "/data/export", adminToken, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		w := f.serveAs(tt.method, tt.target, tt.token, tt.body)
+		assert.Equal(t, tt.code, w.Code, "%s: %s", tt.name, w.Body.String())
+	}
+}
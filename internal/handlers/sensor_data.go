@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"air-quality-server/internal/aqi"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxScopedQueryRange 按范围查询数据允许的最大时间跨度
+	maxScopedQueryRange = 31 * 24 * time.Hour
+	// maxUploadBodySize 数据上传请求体大小上限
+	maxUploadBodySize = 4 << 20
+	// maxUploadBatchSize 单次批量上传的数据条数上限
+	maxUploadBatchSize = 1000
+)
+
+// SensorDataHandler 统一传感器数据处理器（HTTP上传及按分组或位置范围查询）
+type SensorDataHandler struct {
+	dataService  services.UnifiedSensorDataService
+	scopeService services.DeviceScopeService
+	logger       utils.Logger
+}
+
+// NewSensorDataHandler 创建统一传感器数据处理器
+func NewSensorDataHandler(dataService services.UnifiedSensorDataService, scopeService services.DeviceScopeService, logger utils.Logger) *SensorDataHandler {
+	return &SensorDataHandler{
+		dataService:  dataService,
+		scopeService: scopeService,
+		logger:       logger,
+	}
+}
+
+// UploadData 上传传感器数据：单个JSON对象、JSON数组或NDJSON（每行一条），批量上传逐条返回处理结果
+func (h *SensorDataHandler) UploadData(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBodySize)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+		return
+	}
+
+	items, batch, err := parseUploadBody(c.ContentType(), body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !batch {
+		h.uploadSingle(c, items[0])
+		return
+	}
+	if len(items) > maxUploadBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多上传%d条数据", maxUploadBatchSize)})
+		return
+	}
+
+	result := models.UploadBatchResult{Total: len(items), Results: make([]models.UploadItemResult, 0, len(items))}
+	for i := range items {
+		item := h.ingest(c.Request.Context(), i, &items[i])
+		switch item.Status {
+		case models.UploadItemStatusAccepted:
+			result.Accepted++
+		case models.UploadItemStatusDuplicate:
+			result.Duplicate++
+		case models.UploadItemStatusQuarantined:
+			result.Quarantined++
+		default:
+			result.Failed++
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	status := http.StatusOK
+	if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{
+		"message": "批量上传完成",
+		"data":    result,
+	})
+}
+
+// uploadSingle 处理单条上传，Idempotency-Key请求头可代替message_id
+func (h *SensorDataHandler) uploadSingle(c *gin.Context, item uploadItem) {
+	if key := c.GetHeader("Idempotency-Key"); key != "" && item.upload.MessageID == "" {
+		item.upload.MessageID = key
+	}
+
+	result := h.ingest(c.Request.Context(), 0, &item)
+	switch result.Status {
+	case models.UploadItemStatusAccepted:
+		c.JSON(http.StatusCreated, gin.H{"message": "数据上传成功", "data": result})
+	case models.UploadItemStatusDuplicate:
+		c.JSON(http.StatusOK, gin.H{"message": result.Error, "data": result})
+	case models.UploadItemStatusQuarantined:
+		c.JSON(http.StatusAccepted, gin.H{"message": result.Error, "data": result})
+	case models.UploadItemStatusRejected:
+		c.JSON(http.StatusForbidden, gin.H{"error": result.Error})
+	case models.UploadItemStatusInvalid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error})
+	}
+}
+
+// ingest 通过统一数据管道保存单条上传数据并转换为处理结果
+func (h *SensorDataHandler) ingest(ctx context.Context, index int, item *uploadItem) models.UploadItemResult {
+	result := models.UploadItemResult{
+		Index:     index,
+		DeviceID:  item.upload.DeviceID,
+		MessageID: item.upload.MessageID,
+	}
+	err := item.err
+	if err == nil {
+		err = h.dataService.CreateFromUpload(ctx, &item.upload)
+	}
+
+	switch {
+	case err == nil:
+		result.Status = models.UploadItemStatusAccepted
+	case errors.Is(err, services.ErrDuplicateUpload):
+		result.Status = models.UploadItemStatusDuplicate
+		result.Error = err.Error()
+	case errors.Is(err, services.ErrDeviceQuarantined):
+		result.Status = models.UploadItemStatusQuarantined
+		result.Error = err.Error()
+	case errors.Is(err, services.ErrDeviceNotRegistered):
+		result.Status = models.UploadItemStatusRejected
+		result.Error = err.Error()
+	case errors.Is(err, services.ErrInvalidUpload):
+		result.Status = models.UploadItemStatusInvalid
+		result.Error = err.Error()
+	default:
+		h.logger.Error("上传传感器数据失败",
+			utils.String("device_id", item.upload.DeviceID),
+			utils.ErrorField(err))
+		result.Status = models.UploadItemStatusFailed
+		result.Error = "保存数据失败"
+	}
+	return result
+}
+
+// uploadItem 请求体中的一条上传数据及其解析错误
+type uploadItem struct {
+	upload models.UnifiedSensorDataUpload
+	err    error
+}
+
+// parseUploadBody 解析上传请求体，返回数据列表及是否为批量上传
+func parseUploadBody(contentType string, body []byte) ([]uploadItem, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, false, fmt.Errorf("请求体不能为空")
+	}
+
+	ndjson := strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl")
+	if !ndjson && body[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, false, fmt.Errorf("JSON数组格式错误")
+		}
+		if len(raws) == 0 {
+			return nil, false, fmt.Errorf("上传数据不能为空")
+		}
+		items := make([]uploadItem, len(raws))
+		for i, raw := range raws {
+			items[i] = decodeUploadItem(raw)
+		}
+		return items, true, nil
+	}
+
+	if !ndjson {
+		// 单个对象之后还有内容时按NDJSON处理
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		var first json.RawMessage
+		if err := decoder.Decode(&first); err != nil {
+			return nil, false, fmt.Errorf("JSON格式错误")
+		}
+		if !decoder.More() {
+			return []uploadItem{decodeUploadItem(first)}, false, nil
+		}
+	}
+
+	var items []uploadItem
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, decodeUploadItem(line))
+	}
+	return items, true, nil
+}
+
+// decodeUploadItem 解析单条上传数据，格式错误记录在结果中
+func decodeUploadItem(raw []byte) uploadItem {
+	var item uploadItem
+	if err := json.Unmarshal(raw, &item.upload); err != nil {
+		item.err = fmt.Errorf("%w: JSON格式错误", services.ErrInvalidUpload)
+	}
+	return item
+}
+
+// QueryData 查询设备原始数据或按时间桶聚合的序列
+//
+//	@Summary		查询传感器数据
+//	@Description	查询指定设备或分组/位置子树内设备的数据，默认最近24小时，原始数据最长31天；指定interval时返回按时间桶聚合的序列。
+//	@Description	未指定limit时每个序列最多1000个时间桶，超出时自动改用能容纳该时间范围的最细时间桶，响应的interval为实际使用的值。
+//	@Tags			data
+//	@Produce		json
+//	@Param			device_ids	query		string	false	"设备ID，逗号分隔；与group_id、location_id至少指定一个"
+//	@Param			group_id	query		int		false	"设备分组ID，取分组成员"
+//	@Param			location_id	query		int		false	"位置ID，包含子位置"
+//	@Param			sensor_id	query		string	false	"只查询指定传感器的数据"
+//	@Param			start_time	query		int		false	"开始时间（Unix秒），默认结束时间前24小时"
+//	@Param			end_time	query		int		false	"结束时间（Unix秒），默认当前时间"
+//	@Param			interval	query		string	false	"聚合时间桶"	Enums(1m, 5m, 15m, 1h, 6h, 1d)
+//	@Param			metrics		query		string	false	"聚合的指标，逗号分隔，默认全部有数据的指标"
+//	@Param			aggregation	query		string	false	"聚合方式"	Enums(avg, max, min, sum)	default(avg)
+//	@Param			limit		query		int		false	"每个序列最多返回的时间桶数，指定后不自动放大时间桶"	minimum(1)	maximum(1000)
+//	@Success		200			{object}	map[string]interface{}	"原始数据或聚合序列"
+//	@Failure		400			{object}	map[string]interface{}	"参数无效，或时间桶数超过上限（错误信息给出该interval允许的最长时间范围）"
+//	@Failure		500			{object}	map[string]interface{}	"查询失败"
+//	@Router			/api/v1/data/query [get]
+func (h *SensorDataHandler) QueryData(c *gin.Context) {
+	var req models.AirQualityDataQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	req.DeviceIDs = splitQueryValues(req.DeviceIDs)
+	req.Metrics = splitQueryValues(req.Metrics)
+	if req.IsEmpty() && len(req.DeviceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "必须指定device_ids、group_id或location_id"})
+		return
+	}
+	req.StartTime, req.EndTime = defaultTimeRange(req.StartTime, req.EndTime)
+	if req.StartTime > req.EndTime {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间范围无效"})
+		return
+	}
+
+	if !req.IsEmpty() {
+		scoped, ok := h.resolveScope(c, req.DeviceScopeFilter)
+		if !ok {
+			return
+		}
+		if len(req.DeviceIDs) > 0 {
+			scoped = intersectDeviceIDs(scoped, req.DeviceIDs)
+		}
+		req.DeviceIDs = scoped
+	}
+
+	if req.Interval != "" {
+		h.aggregateData(c, &req)
+		return
+	}
+	if time.Duration(req.EndTime-req.StartTime)*time.Second > maxScopedQueryRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间范围无效，最长31天，更长范围请指定interval聚合查询"})
+		return
+	}
+
+	data := []models.UnifiedSensorData{}
+	if len(req.DeviceIDs) > 0 {
+		var err error
+		data, err = h.dataService.GetMultiDeviceData(c.Request.Context(), req.DeviceIDs, req.StartTime, req.EndTime)
+		if err != nil {
+			h.logger.Error("查询传感器数据失败", utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询传感器数据失败"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "查询传感器数据成功",
+		"data": gin.H{
+			"device_ids": req.DeviceIDs,
+			"start_time": req.StartTime,
+			"end_time":   req.EndTime,
+			"data":       data,
+		},
+	})
+}
+
+// aggregateData 返回按时间桶聚合的序列
+func (h *SensorDataHandler) aggregateData(c *gin.Context, req *models.AirQualityDataQuery) {
+	series, err := h.dataService.AggregateData(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDataQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("聚合查询传感器数据失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "聚合查询传感器数据失败"})
+		return
+	}
+
+	aggregation := req.Aggregation
+	if aggregation == "" {
+		aggregation = string(models.AggregationAvg)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "聚合查询传感器数据成功",
+		"data": gin.H{
+			"device_ids":  req.DeviceIDs,
+			"start_time":  req.StartTime,
+			"end_time":    req.EndTime,
+			"interval":    req.Interval,
+			"aggregation": aggregation,
+			"series":      series,
+		},
+	})
+}
+
+// GetStatistics 统计分组或位置子树内全部设备的数据
+func (h *SensorDataHandler) GetStatistics(c *gin.Context) {
+	req, deviceIDs, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.dataService.GetMultiDeviceStatistics(c.Request.Context(), deviceIDs, req.StartTime, req.EndTime)
+	if err != nil {
+		h.logger.Error("统计传感器数据失败", utils.ErrorField(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计传感器数据失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "统计传感器数据成功",
+		"data": gin.H{
+			"device_count": len(deviceIDs),
+			"start_time":   req.StartTime,
+			"end_time":     req.EndTime,
+			"statistics":   stats,
+		},
+	})
+}
+
+// GetAQI 按配置的AQI标准计算设备截至指定时间（at，Unix秒，默认当前时间）的AQI
+func (h *SensorDataHandler) GetAQI(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "时间参数错误"})
+			return
+		}
+		at = time.Unix(seconds, 0)
+	}
+
+	result, err := h.dataService.CalculateAQI(c.Request.Context(), deviceID, at)
+	if err != nil {
+		if errors.Is(err, aqi.ErrNoPollutants) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("计算AQI失败", utils.ErrorField(err), utils.String("device_id", deviceID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "计算AQI失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "计算AQI成功",
+		"data": gin.H{
+			"device_id": deviceID,
+			"at":        at.Unix(),
+			"aqi":       result,
+		},
+	})
+}
+
+// resolve 解析查询参数并将分组/位置解析为设备ID
+func (h *SensorDataHandler) resolve(c *gin.Context) (*models.ScopedDataQueryRequest, []string, bool) {
+	var req models.ScopedDataQueryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return nil, nil, false
+	}
+	if req.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "必须指定group_id或location_id"})
+		return nil, nil, false
+	}
+	req.StartTime, req.EndTime = defaultTimeRange(req.StartTime, req.EndTime)
+	if req.StartTime > req.EndTime || time.Duration(req.EndTime-req.StartTime)*time.Second > maxScopedQueryRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间范围无效，最长31天"})
+		return nil, nil, false
+	}
+
+	deviceIDs, ok := h.resolveScope(c, req.DeviceScopeFilter)
+	if !ok {
+		return nil, nil, false
+	}
+	return &req, deviceIDs, true
+}
+
+// resolveScope 将分组/位置解析为设备ID，失败时写入错误响应
+func (h *SensorDataHandler) resolveScope(c *gin.Context, filter models.DeviceScopeFilter) ([]string, bool) {
+	deviceIDs, err := h.scopeService.ResolveDeviceIDs(c.Request.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeviceGroupNotFound), errors.Is(err, services.ErrLocationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("解析设备范围失败", utils.ErrorField(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解析设备范围失败"})
+		}
+		return nil, false
+	}
+	return deviceIDs, true
+}
+
+// defaultTimeRange 结束时间默认为当前时间，开始时间默认为结束前24小时
+func defaultTimeRange(startTime, endTime int64) (int64, int64) {
+	if endTime == 0 {
+		endTime = time.Now().Unix()
+	}
+	if startTime == 0 {
+		startTime = endTime - int64((24 * time.Hour).Seconds())
+	}
+	return startTime, endTime
+}
+
+// splitQueryValues 展开逗号分隔的多值查询参数并去除空值
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// intersectDeviceIDs 保留同时出现在两个列表中的设备ID
+func intersectDeviceIDs(deviceIDs, allowed []string) []string {
+	set := make(map[string]struct{}, len(allowed))
+	for _, id := range allowed {
+		set[id] = struct{}{}
+	}
+	result := []string{}
+	for _, id := range deviceIDs {
+		if _, ok := set[id]; ok {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"air-quality-server/internal/aqi"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSensorDataHandler_GetAQI 测试AQI查询接口按指定时间计算，无污染物数据时返回404
+func TestSensorDataHandler_GetAQI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	at := time.Unix(1700000000, 0)
+	pm25 := 80.0
+	require.NoError(t, db.Create(&models.UnifiedSensorData{DeviceID: testutil.DeviceID1,
+		DeviceType: models.DeviceTypeAirQuality, Timestamp: at.Add(-time.Hour), PM25: &pm25}).Error)
+	service := services.NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger), nil, nil, nil, nil, nil, "", logger)
+	router := gin.New()
+	router.GET("/api/v1/data/aqi/:device_id", NewSensorDataHandler(service, nil, logger).GetAQI)
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		aqi    int
+	}{
+		// PM2.5 24小时平均80 → 107
+		{"at given time", "/api/v1/data/aqi/hcho_001?at=1700000000", http.StatusOK, 107},
+		{"reading after given time", "/api/v1/data/aqi/hcho_001?at=1699990000", http.StatusNotFound, 0},
+		{"no data", "/api/v1/data/aqi/hcho_002?at=1700000000", http.StatusNotFound, 0},
+		{"invalid time", "/api/v1/data/aqi/hcho_001?at=yesterday", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, tt.target, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			var data struct {
+				AQI aqi.Result `json:"aqi"`
+			}
+			testutil.DecodeData(t, w, &data)
+			assert.Equal(t, tt.aqi, data.AQI.AQI)
+			assert.Equal(t, aqi.StandardHJ633, data.AQI.Standard)
+		})
+	}
+}
+
+// TestSensorDataHandler_QueryData 测试查询接口：带interval时返回聚合序列，参数校验失败及时间桶数超过上限时返回400和最长时间范围
+func TestSensorDataHandler_QueryData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+	base := time.Unix(1700000000/3600*3600, 0)
+	for _, r := range []struct {
+		deviceID string
+		offset   time.Duration
+	}{
+		{testutil.DeviceID1, 5 * time.Minute},
+		{testutil.DeviceID1, 65 * time.Minute},
+		{testutil.DeviceID2, 10 * time.Minute},
+	} {
+		value := 0.05
+		require.NoError(t, db.Create(&models.UnifiedSensorData{DeviceID: r.deviceID, DeviceType: models.DeviceTypeFormaldehyde,
+			Timestamp: base.Add(r.offset), Formaldehyde: &value}).Error)
+	}
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	scope := services.NewDeviceScopeService(deviceRepo, repositories.NewLocationRepository(db, logger),
+		repositories.NewDeviceGroupRepository(db, logger), logger)
+	service := services.NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger),
+		deviceRepo, nil, nil, nil, nil, nil, "", logger)
+	router := gin.New()
+	router.GET("/api/v1/data/query", NewSensorDataHandler(service, scope, logger).QueryData)
+
+	window := fmt.Sprintf("start_time=%d&end_time=%d", base.Unix(), base.Add(2*time.Hour-time.Second).Unix())
+	since := func(days int) string {
+		return fmt.Sprintf("start_time=%d&end_time=%d", base.AddDate(0, 0, -days).Unix(), base.Add(2*time.Hour-time.Second).Unix())
+	}
+	tests := []struct {
+		name     string
+		query    string
+		code     int
+		interval string
+		series   int
+		errorMsg string
+	}{
+		{"multiple devices", "device_ids=hcho_001,hcho_002&interval=1h&metrics=formaldehyde&" + window, http.StatusOK, "1h", 2, ""},
+		{"unsupported interval", "device_ids=hcho_001&interval=2m&" + window, http.StatusBadRequest, "", 0, "不支持的时间桶"},
+		{"unsupported aggregation", "device_ids=hcho_001&interval=1h&aggregation=median&" + window, http.StatusBadRequest, "", 0, "不支持的聚合方式"},
+		{"missing devices", "interval=1h&" + window, http.StatusBadRequest, "", 0, ""},
+		{"raw query over a year", "device_ids=hcho_001&" + since(365), http.StatusBadRequest, "", 0, ""},
+		{"aggregate over a year", "device_ids=hcho_001&interval=1d&" + since(365), http.StatusOK, "1d", 1, ""},
+		{"coarsened to fit a year", "device_ids=hcho_001&interval=1m&" + since(365), http.StatusOK, "1d", 1, ""},
+		{"coarsened to fit a week", "device_ids=hcho_001&interval=1m&" + since(7), http.StatusOK, "15m", 1, ""},
+		{"fits requested interval", "device_ids=hcho_001&interval=1m&" + window, http.StatusOK, "1m", 1, ""},
+		{"explicit limit", "device_ids=hcho_001&interval=1m&limit=60&" + window, http.StatusBadRequest, "", 0, "时间范围最长1小时"},
+		{"beyond coarsest interval", "device_ids=hcho_001&interval=1h&" + since(1001), http.StatusBadRequest, "", 0,
+			"interval=1d时最多返回1000个时间桶，时间范围最长1000天"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Serve(router, http.MethodGet, "/api/v1/data/query?"+tt.query, "", "")
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			var resp struct {
+				Error string `json:"error"`
+				Data  struct {
+					Interval string                    `json:"interval"`
+					Series   []models.AggregatedSeries `json:"series"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.interval, resp.Data.Interval)
+			assert.Len(t, resp.Data.Series, tt.series)
+			assert.Contains(t, resp.Error, tt.errorMsg)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newUploadRouter 注册数据上传接口，数据服务与MQTT入库路径一样评估告警（甲醛>0.1）并刷新设备运行状态，数据库中已有设备1
+func newUploadRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	rules := services.NewAlertRuleService(repositories.NewAlertRuleRepository(db, logger), logger)
+	_, err := rules.CreateRule(context.Background(), &models.AlertRuleCreateRequest{
+		Name:           "甲醛浓度严重超标",
+		Metric:         "formaldehyde",
+		ConditionType:  string(models.AlertConditionGT),
+		ThresholdValue: 0.1,
+		Severity:       string(models.AlertSeverityCritical),
+	})
+	require.NoError(t, err)
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	evaluator := services.NewAlertEvaluator(rules, services.NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger), nil, logger)
+	presence := services.NewDevicePresenceService(config.DeviceConfig{}, deviceRepo, repositories.NewDeviceRuntimeStatusRepository(db, logger), nil, logger)
+	dataService := services.NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger),
+		deviceRepo, evaluator, presence, nil, nil, nil, "", logger)
+
+	router := gin.New()
+	router.POST("/api/v1/data/upload", NewSensorDataHandler(dataService, nil, logger).UploadData)
+	return router, db
+}
+
+// serveUpload 发送带Idempotency-Key的JSON上传请求
+func serveUpload(router http.Handler, body, idempotencyKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/data/upload", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// countReadings 统计已入库的数据条数
+func countReadings(t *testing.T, db *gorm.DB) int64 {
+	var count int64
+	require.NoError(t, db.Model(&models.UnifiedSensorData{}).Count(&count).Error)
+	return count
+}
+
+// TestSensorDataHandler_UploadData 测试单条、JSON数组、NDJSON和连续对象上传的状态码及逐条结果
+func TestSensorDataHandler_UploadData(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		statuses    []models.UploadItemStatus // 批量上传的逐条结果
+		stored      int64
+	}{
+		{"single", "", `{"device_id":"hcho_001","device_type":"hcho","data":{"pm25":10}}`, http.StatusCreated, nil, 1},
+		{"invalid device type", "", `{"device_id":"hcho_001","device_type":"unknown","data":{"pm25":10}}`, http.StatusBadRequest, nil, 0},
+		{"empty data", "", `{"device_id":"hcho_001","device_type":"hcho","data":{}}`, http.StatusBadRequest, nil, 0},
+		{"malformed json", "", `{"device_id":`, http.StatusBadRequest, nil, 0},
+		{"unregistered device", "", `{"device_id":"ghost","device_type":"hcho","data":{"pm25":10}}`, http.StatusForbidden, nil, 0},
+		{
+			name:        "json array with per-item errors and in-batch duplicate",
+			contentType: "application/json",
+			body: `[
+				{"device_id":"hcho_001","device_type":"hcho","message_id":"m-1","data":{"pm25":12}},
+				{"device_id":"hcho_001","device_type":"hcho","message_id":"m-1","data":{"pm25":12}},
+				{"device_id":"hcho_001","device_type":"hcho","data":{"pm25":"high"}},
+				{"device_id":"ghost","device_type":"hcho","data":{"pm25":10}}
+			]`,
+			code: http.StatusMultiStatus,
+			statuses: []models.UploadItemStatus{models.UploadItemStatusAccepted, models.UploadItemStatusDuplicate,
+				models.UploadItemStatusInvalid, models.UploadItemStatusRejected},
+			stored: 1,
+		},
+		{
+			name:        "ndjson skips malformed lines",
+			contentType: "application/x-ndjson",
+			body:        "{\"device_id\":\"hcho_001\",\"device_type\":\"hcho\",\"data\":{\"pm25\":15}}\n\n{bad json}\n{\"device_id\":\"hcho_001\",\"device_type\":\"hcho\",\"data\":{\"pm25\":16}}\n",
+			code:        http.StatusMultiStatus,
+			statuses:    []models.UploadItemStatus{models.UploadItemStatusAccepted, models.UploadItemStatusInvalid, models.UploadItemStatusAccepted},
+			stored:      2,
+		},
+		{
+			name:        "concatenated objects",
+			contentType: "application/json",
+			body:        "{\"device_id\":\"hcho_001\",\"device_type\":\"hcho\",\"data\":{\"pm25\":17}}\n{\"device_id\":\"hcho_001\",\"device_type\":\"hcho\",\"data\":{\"pm25\":18}}",
+			code:        http.StatusOK,
+			statuses:    []models.UploadItemStatus{models.UploadItemStatusAccepted, models.UploadItemStatusAccepted},
+			stored:      2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, db := newUploadRouter(t)
+			w := testutil.Serve(router, http.MethodPost, "/api/v1/data/upload", tt.contentType, tt.body)
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			assert.Equal(t, tt.stored, countReadings(t, db))
+			if tt.statuses == nil {
+				return
+			}
+			var result models.UploadBatchResult
+			testutil.DecodeData(t, w, &result)
+			statuses := []models.UploadItemStatus{}
+			for _, item := range result.Results {
+				statuses = append(statuses, item.Status)
+			}
+			assert.Equal(t, tt.statuses, statuses)
+			assert.Equal(t, len(tt.statuses), result.Total)
+			assert.Equal(t, int(tt.stored), result.Accepted)
+		})
+	}
+}
+
+// TestSensorDataHandler_UploadDataIdempotency 测试相同Idempotency-Key的重试被忽略
+func TestSensorDataHandler_UploadDataIdempotency(t *testing.T) {
+	router, db := newUploadRouter(t)
+	body := `{"device_id":"hcho_001","device_type":"hcho","data":{"pm25":10}}`
+
+	tests := []struct {
+		key    string
+		code   int
+		stored int64
+	}{
+		{"req-1", http.StatusCreated, 1},
+		{"req-1", http.StatusOK, 1},
+		{"req-2", http.StatusCreated, 2},
+	}
+	for _, tt := range tests {
+		w := serveUpload(router, body, tt.key)
+		require.Equal(t, tt.code, w.Code, w.Body.String())
+		assert.Equal(t, tt.stored, countReadings(t, db), tt.key)
+	}
+
+	var stored models.UnifiedSensorData
+	require.NoError(t, db.First(&stored).Error)
+	require.NotNil(t, stored.MessageID)
+	assert.Equal(t, "req-1", *stored.MessageID)
+}
+
+// TestSensorDataHandler_UploadDataPipeline 测试HTTP上传与MQTT入库路径一样触发告警并将设备标记为在线
+func TestSensorDataHandler_UploadDataPipeline(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"single", `{"device_id":"hcho_001","device_type":"hcho","timestamp":1700000000,"data":{"formaldehyde":0.12,"temperature":22.5,"battery":85}}`},
+		{"batch", `[{"device_id":"hcho_001","device_type":"hcho","timestamp":1700000000,"data":{"formaldehyde":0.12,"temperature":22.5,"battery":85}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, db := newUploadRouter(t)
+			w := testutil.Serve(router, http.MethodPost, "/api/v1/data/upload", "", tt.body)
+			require.Less(t, w.Code, http.StatusMultipleChoices, w.Body.String())
+
+			var stored models.UnifiedSensorData
+			require.NoError(t, db.First(&stored).Error)
+			require.NotNil(t, stored.Formaldehyde)
+			assert.Equal(t, 0.12, *stored.Formaldehyde)
+			require.NotNil(t, stored.Battery)
+			assert.Equal(t, 85, *stored.Battery)
+
+			var alerts []models.Alert
+			require.NoError(t, db.Find(&alerts).Error)
+			require.Len(t, alerts, 1)
+			assert.Equal(t, testutil.DeviceID1, alerts[0].DeviceID)
+			assert.Equal(t, "formaldehyde", alerts[0].Metric)
+
+			var status models.DeviceRuntimeStatus
+			require.NoError(t, db.Where("device_id = ?", testutil.DeviceID1).First(&status).Error)
+			assert.True(t, status.Online)
+			require.NotNil(t, status.BatteryLevel)
+			assert.Equal(t, 85, *status.BatteryLevel)
+			var device models.Device
+			require.NoError(t, db.First(&device, "id = ?", testutil.DeviceID1).Error)
+			assert.Equal(t, models.DeviceStatusOnline, device.Status)
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
 	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,44 +17,59 @@ import (
 // UserHandler 用户处理器
 type UserHandler struct {
 	userService services.UserService
+	authService services.AuthService
+	roleService services.RoleService
 	logger      utils.Logger
 }
 
 // NewUserHandler 创建用户处理器
-func NewUserHandler(userService services.UserService, logger utils.Logger) *UserHandler {
+func NewUserHandler(userService services.UserService, authService services.AuthService, roleService services.RoleService, logger utils.Logger) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		authService: authService,
+		roleService: roleService,
 		logger:      logger,
 	}
 }
 
-// CreateUser 创建用户
+// CreateUser 创建用户并分配角色
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	var req struct {
-		Username string `json:"username" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
-		Role     string `json:"role"`
-	}
-
+	var req models.UserCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("创建用户请求参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
-	if req.Role == "" {
-		req.Role = "user"
+	ctx := c.Request.Context()
+	if err := h.roleService.ValidateRoleIDs(ctx, req.RoleIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: req.Password,
+	}
+	if req.Phone != "" {
+		user.Phone = &req.Phone
+	}
+	if err := h.userService.CreateUserWithRoles(ctx, user, req.RoleIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles, err := h.roleService.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
+		return
 	}
 
-	h.logger.Info("创建用户请求", utils.String("username", req.Username))
+	h.logger.Info("创建用户成功", utils.String("username", req.Username))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "用户创建成功",
-		"data": gin.H{
-			"username": req.Username,
-			"email":    req.Email,
-			"role":     req.Role,
-		},
+		"data":    userInfo(user, roles),
 	})
 }
 
@@ -75,33 +95,72 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	})
 }
 
-// UpdateUser 更新用户
+// UpdateUser 更新用户信息，指定role_ids时替换用户的角色（空数组清空角色）
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		h.logger.Error("用户ID参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID参数错误"})
 		return
 	}
 
-	var req struct {
-		Email string `json:"email"`
-		Role  string `json:"role"`
-	}
-
+	var req models.UserUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("更新用户请求参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
+	if req.Status != nil && !models.UserStatus(*req.Status).IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户状态无效"})
+		return
+	}
 
-	h.logger.Info("更新用户请求", utils.Int("user_id", int(id)))
+	ctx := c.Request.Context()
+	if req.RoleIDs != nil {
+		if err := h.roleService.ValidateRoleIDs(ctx, req.RoleIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, err := h.userService.GetUser(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if req.Username != nil {
+		user.Username = *req.Username
+	}
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+	if req.Phone != nil {
+		user.Phone = req.Phone
+	}
+	if req.Status != nil {
+		user.Status = *req.Status
+	}
+	if err := h.userService.UpdateUser(ctx, user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RoleIDs != nil {
+		if err := h.roleService.AssignUserRoles(ctx, user.ID, req.RoleIDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "分配用户角色失败"})
+			return
+		}
+	}
+	roles, err := h.roleService.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
+		return
+	}
+
+	h.logger.Info("更新用户成功", utils.Int64("user_id", int64(user.ID)))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户更新成功",
-		"data": gin.H{
-			"id": id,
-		},
+		"data":    userInfo(user, roles),
 	})
 }
 
@@ -150,39 +209,131 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 // Login 用户登录
 func (h *UserHandler) Login(c *gin.Context) {
-	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
-
+	var req models.UserLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("用户登录请求参数错误", utils.ErrorField(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
-	h.logger.Info("用户登录请求", utils.String("username", req.Username))
+	resp, err := h.authService.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		h.logger.Warn("用户登录失败", utils.String("username", req.Username), utils.ErrorField(err))
+		h.respondAuthError(c, err)
+		return
+	}
+
+	h.fillRoles(c, resp)
+	h.setTokenCookie(c, resp.Token, resp.ExpiresAt)
+	h.logger.Info("用户登录成功", utils.String("username", req.Username))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "登录成功",
-		"data": gin.H{
-			"token": "fake_jwt_token",
-			"user": gin.H{
-				"id":       1,
-				"username": req.Username,
-				"role":     "user",
-			},
-		},
+		"data":    resp,
+	})
+}
+
+// RefreshToken 刷新令牌
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req models.TokenRefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("刷新令牌请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	resp, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		h.logger.Warn("刷新令牌失败", utils.ErrorField(err))
+		h.respondAuthError(c, err)
+		return
+	}
+
+	h.fillRoles(c, resp)
+	h.setTokenCookie(c, resp.Token, resp.ExpiresAt)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "刷新令牌成功",
+		"data":    resp,
 	})
 }
 
-// Logout 用户登出
+// Logout 用户登出，注销当前访问令牌及可选的刷新令牌
 func (h *UserHandler) Logout(c *gin.Context) {
-	h.logger.Info("用户登出请求")
+	claims := middleware.CurrentTokenClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	var req models.UserLogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Error("用户登出请求参数错误", utils.ErrorField(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), claims, req.RefreshToken); err != nil {
+		if errors.Is(err, utils.ErrTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "刷新令牌无效"})
+			return
+		}
+		h.logger.Error("用户登出失败", utils.ErrorField(err), utils.Int64("user_id", int64(claims.UserID)))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败"})
+		return
+	}
+
+	h.setTokenCookie(c, "", time.Time{})
+	h.logger.Info("用户登出", utils.String("username", claims.Username))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "登出成功",
 	})
 }
 
+// fillRoles 补充登录响应中的用户角色
+func (h *UserHandler) fillRoles(c *gin.Context, resp *models.UserLoginResponse) {
+	roles, err := h.roleService.GetUserRoles(c.Request.Context(), resp.User.ID)
+	if err != nil {
+		h.logger.Warn("获取用户角色失败", utils.ErrorField(err), utils.Int64("user_id", int64(resp.User.ID)))
+		return
+	}
+	resp.User.Roles = roles
+}
+
+// userInfo 构造用户信息响应
+func userInfo(user *models.User, roles []models.Role) models.UserInfo {
+	return models.UserInfo{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Phone:    user.Phone,
+		Status:   user.Status,
+		Roles:    roles,
+	}
+}
+
+// respondAuthError 根据认证错误返回对应的HTTP状态码
+func (h *UserHandler) respondAuthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrTokenInvalid), errors.Is(err, utils.ErrTokenExpired), errors.Is(err, services.ErrTokenRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "认证失败"})
+	}
+}
+
+// setTokenCookie 写入访问令牌Cookie，token为空时清除
+func (h *UserHandler) setTokenCookie(c *gin.Context, token string, expiresAt time.Time) {
+	maxAge := -1
+	if token != "" {
+		maxAge = int(time.Until(expiresAt).Seconds())
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(middleware.AccessTokenCookie, token, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
 // ChangePassword 修改密码
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	idStr := c.Param("id")
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userFixture 用户接口测试环境：角色operator（ID 1）、viewer（ID 2），用户bob拥有operator，用户alice没有角色
+type userFixture struct {
+	router      *gin.Engine
+	users       services.UserService
+	roles       services.RoleService
+	auth        services.AuthService
+	bob, alice  *models.User
+	operator    *models.Role
+	viewer      *models.Role
+	userHandler *UserHandler
+}
+
+// newUserFixture 创建角色和用户并注册用户接口
+func newUserFixture(t *testing.T) *userFixture {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+
+	f := &userFixture{
+		users: services.NewUserService(repositories.NewUserRepository(db, logger), logger),
+		roles: services.NewRoleService(repositories.NewRoleRepository(db, logger), logger),
+	}
+	f.auth = services.NewAuthService(config.JWTConfig{Secret: "test-secret", ExpireHours: 1, Issuer: "air-quality-test"},
+		f.users, repositories.NewRevokedTokenRepository(db, logger), nil, logger)
+	var err error
+	f.operator, err = f.roles.CreateRole(ctx, &models.RoleCreateRequest{Name: "operator", Permissions: []string{"device:*", "data:read"}})
+	require.NoError(t, err)
+	f.viewer, err = f.roles.CreateRole(ctx, &models.RoleCreateRequest{Name: "viewer", Permissions: []string{"device:read"}})
+	require.NoError(t, err)
+	f.bob = &models.User{Username: "bob", Email: "bob@air-quality.local", PasswordHash: "secret123"}
+	require.NoError(t, f.users.CreateUserWithRoles(ctx, f.bob, []uint64{f.operator.ID}))
+	f.alice = &models.User{Username: "alice", Email: "alice@air-quality.local", PasswordHash: "secret123"}
+	require.NoError(t, f.users.CreateUser(ctx, f.alice))
+
+	f.userHandler = NewUserHandler(f.users, f.auth, f.roles, logger)
+	f.router = gin.New()
+	f.router.POST("/api/v1/users", f.userHandler.CreateUser)
+	f.router.PUT("/api/v1/users/:id", f.userHandler.UpdateUser)
+	return f
+}
+
+// roleNames 返回用户当前的角色名
+func (f *userFixture) roleNames(t *testing.T, userID uint64) []string {
+	roles, err := f.roles.GetUserRoles(context.Background(), userID)
+	require.NoError(t, err)
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// login 登录并返回访问令牌
+func (f *userFixture) login(t *testing.T, username, password string) string {
+	resp, err := f.auth.Login(context.Background(), username, password)
+	require.NoError(t, err)
+	return resp.Token
+}
+
+// serveAs 携带访问令牌发送请求
+func (f *userFixture) serveAs(method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestUserHandler_CreateUser 测试创建用户时校验并分配角色
+func TestUserHandler_CreateUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles string
+		code  int
+		want  []string
+	}{
+		{"with role", "[1]", http.StatusCreated, []string{"operator"}},
+		{"duplicate role ids", "[1,1,2]", http.StatusCreated, []string{"operator", "viewer"}},
+		{"unknown role", "[999]", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserFixture(t)
+			w := testutil.Serve(f.router, http.MethodPost, "/api/v1/users", "",
+				`{"username":"carol","email":"carol@air-quality.local","password":"secret123","role_ids":`+tt.roles+`}`)
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusCreated {
+				return
+			}
+			var info models.UserInfo
+			testutil.DecodeData(t, w, &info)
+			assert.ElementsMatch(t, tt.want, f.roleNames(t, info.ID))
+		})
+	}
+}
+
+// TestUserHandler_UpdateUser 测试更新用户资料和角色：未指定role_ids时保留角色，空数组清空角色
+func TestUserHandler_UpdateUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		target func(f *userFixture) uint64
+		body   string
+		code   int
+		roles  []string
+		email  string
+	}{
+		{"replace roles", func(f *userFixture) uint64 { return f.bob.ID }, `{"role_ids":[2]}`, http.StatusOK, []string{"viewer"}, "bob@air-quality.local"},
+		{"assign first role", func(f *userFixture) uint64 { return f.alice.ID }, `{"role_ids":[1,2]}`, http.StatusOK, []string{"operator", "viewer"}, "alice@air-quality.local"},
+		{"clear roles", func(f *userFixture) uint64 { return f.bob.ID }, `{"role_ids":[]}`, http.StatusOK, []string{}, "bob@air-quality.local"},
+		{"profile only keeps roles", func(f *userFixture) uint64 { return f.bob.ID }, `{"email":"robert@air-quality.local"}`, http.StatusOK, []string{"operator"}, "robert@air-quality.local"},
+		{"unknown role", func(f *userFixture) uint64 { return f.bob.ID }, `{"role_ids":[999]}`, http.StatusBadRequest, []string{"operator"}, "bob@air-quality.local"},
+		{"invalid status", func(f *userFixture) uint64 { return f.bob.ID }, `{"status":"deleted"}`, http.StatusBadRequest, []string{"operator"}, "bob@air-quality.local"},
+		{"email taken", func(f *userFixture) uint64 { return f.bob.ID }, `{"email":"alice@air-quality.local","role_ids":[2]}`, http.StatusBadRequest, []string{"operator"}, "bob@air-quality.local"},
+		{"missing user", func(f *userFixture) uint64 { return 999 }, `{"role_ids":[2]}`, http.StatusNotFound, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserFixture(t)
+			id := tt.target(f)
+			w := testutil.Serve(f.router, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", id), "", tt.body)
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code == http.StatusNotFound {
+				return
+			}
+			assert.ElementsMatch(t, tt.roles, f.roleNames(t, id))
+			user, err := f.users.GetUser(context.Background(), uint(id))
+			require.NoError(t, err)
+			assert.Equal(t, tt.email, user.Email)
+		})
+	}
+}
+
+// TestAuth_LoginRefreshLogout 测试JWT登录、刷新令牌、登出注销及认证中间件
+func TestAuth_LoginRefreshLogout(t *testing.T) {
+	f := newUserFixture(t)
+	f.router.POST("/auth/login", f.userHandler.Login)
+	f.router.POST("/auth/refresh", f.userHandler.RefreshToken)
+	secured := f.router.Group("", middleware.Auth(f.auth))
+	secured.POST("/auth/logout", f.userHandler.Logout)
+	secured.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": *middleware.CurrentUserID(c), "username": middleware.CurrentUser(c).Username})
+	})
+
+	tokens := func(w *httptest.ResponseRecorder) models.UserLoginResponse {
+		var login models.UserLoginResponse
+		testutil.DecodeData(t, w, &login)
+		return login
+	}
+	refresh := func(token string) string { return `{"refresh_token":"` + token + `"}` }
+
+	// 错误密码和未携带令牌均拒绝
+	assert.Equal(t, http.StatusUnauthorized, f.serveAs(http.MethodPost, "/auth/login", "", `{"username":"bob","password":"wrong"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, f.serveAs(http.MethodGet, "/me", "", "").Code)
+
+	w := f.serveAs(http.MethodPost, "/auth/login", "", `{"username":"bob","password":"secret123"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	login := tokens(w)
+	assert.Equal(t, f.bob.ID, login.User.ID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), login.ExpiresAt, time.Minute)
+	assert.NotEmpty(t, w.Result().Cookies())
+	stored, err := f.users.GetUser(context.Background(), uint(f.bob.ID))
+	require.NoError(t, err)
+	assert.NotNil(t, stored.LastLoginAt)
+
+	// 刷新后旧刷新令牌失效
+	w = f.serveAs(http.MethodPost, "/auth/refresh", "", refresh(login.RefreshToken))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refreshed := tokens(w)
+	assert.NotEqual(t, login.Token, refreshed.Token)
+
+	// 登出后访问令牌和刷新令牌均被注销
+	w = f.serveAs(http.MethodPost, "/auth/logout", refreshed.Token, refresh(refreshed.RefreshToken))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		code   int
+	}{
+		{"access token", http.MethodGet, "/me", login.Token, "", http.StatusOK},
+		{"tampered token", http.MethodGet, "/me", login.Token + "x", "", http.StatusUnauthorized},
+		{"refresh token as access token", http.MethodGet, "/me", login.RefreshToken, "", http.StatusUnauthorized},
+		{"rotated refresh token", http.MethodPost, "/auth/refresh", "", refresh(login.RefreshToken), http.StatusUnauthorized},
+		{"logged out access token", http.MethodGet, "/me", refreshed.Token, "", http.StatusUnauthorized},
+		{"logged out refresh token", http.MethodPost, "/auth/refresh", "", refresh(refreshed.RefreshToken), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := f.serveAs(tt.method, tt.path, tt.token, tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+	assert.Contains(t, f.serveAs(http.MethodGet, "/me", login.Token, "").Body.String(), `"username":"bob"`)
+
+	// 禁用账户后未登出的令牌也被拒绝
+	stored.Status = string(models.UserStatusSuspended)
+	require.NoError(t, f.users.UpdateUser(context.Background(), stored))
+	assert.Equal(t, http.StatusForbidden, f.serveAs(http.MethodGet, "/me", login.Token, "").Code)
+}
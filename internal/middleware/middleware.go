@@ -1,7 +1,12 @@
 package middleware
 
 import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
+	"errors"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -64,14 +69,123 @@ func RequestID() gin.HandlerFunc {
 	}
 }
 
-// Auth 认证中间件
-func Auth() gin.HandlerFunc {
+// 认证中间件写入上下文的键
+const (
+	ContextKeyUserID      = "user_id"
+	ContextKeyUser        = "user"
+	ContextKeyTokenClaims = "token_claims"
+	ContextKeyPermissions = "permissions"
+)
+
+// AccessTokenCookie 访问令牌Cookie名称（供Web页面调用API）
+const AccessTokenCookie = "access_token"
+
+// CurrentUserID 获取当前请求的用户ID，未认证时返回nil
+func CurrentUserID(c *gin.Context) *uint64 {
+	value, ok := c.Get(ContextKeyUserID)
+	if !ok {
+		return nil
+	}
+	if id, ok := value.(uint64); ok {
+		return &id
+	}
+	return nil
+}
+
+// CurrentUser 获取当前请求的用户，未认证时返回nil
+func CurrentUser(c *gin.Context) *models.User {
+	if value, ok := c.Get(ContextKeyUser); ok {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// CurrentTokenClaims 获取当前请求的访问令牌载荷，未认证时返回nil
+func CurrentTokenClaims(c *gin.Context) *utils.JWTClaims {
+	if value, ok := c.Get(ContextKeyTokenClaims); ok {
+		if claims, ok := value.(*utils.JWTClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// Auth 认证中间件，从Authorization头（Bearer）或Cookie读取访问令牌
+func Auth(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+
+		user, claims, err := authService.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrTokenExpired):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌已过期"})
+			case errors.Is(err, services.ErrUserDisabled):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			default:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌无效"})
+			}
+			return
+		}
+
+		c.Set(ContextKeyUserID, user.ID)
+		c.Set(ContextKeyUser, user)
+		c.Set(ContextKeyTokenClaims, claims)
+		c.Next()
+	}
+}
+
+// RequirePermission 权限校验中间件，需在Auth之后使用，要求用户拥有全部指定权限
+func RequirePermission(roleService services.RoleService, permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: 实现JWT认证逻辑
+		userID := CurrentUserID(c)
+		if userID == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+
+		// 同一请求经过多个权限中间件时只查询一次
+		var granted []string
+		if value, ok := c.Get(ContextKeyPermissions); ok {
+			granted, _ = value.([]string)
+		} else {
+			var err error
+			granted, err = roleService.GetUserPermissions(c.Request.Context(), *userID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "获取用户权限失败"})
+				return
+			}
+			c.Set(ContextKeyPermissions, granted)
+		}
+
+		for _, permission := range permissions {
+			if !models.HasPermission(granted, permission) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足", "permission": permission})
+				return
+			}
+		}
 		c.Next()
 	}
 }
 
+// bearerToken 读取请求中的访问令牌
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	if token, err := c.Cookie(AccessTokenCookie); err == nil {
+		return token
+	}
+	return ""
+}
+
 // RateLimit 限流中间件
 func RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -53,15 +53,17 @@ type AirQualityDataBatch struct {
 	Data     []AirQualityDataUpload `json:"data" binding:"required"`
 }
 
-// AirQualityDataQuery 数据查询请求
+// AirQualityDataQuery 数据查询请求，指定interval时按时间桶聚合，否则返回原始数据
 type AirQualityDataQuery struct {
+	DeviceScopeFilter
 	DeviceIDs   []string `form:"device_ids"`
+	SensorID    string   `form:"sensor_id"`
 	StartTime   int64    `form:"start_time"`
 	EndTime     int64    `form:"end_time"`
-	Interval    string   `form:"interval"`    // 1m, 5m, 1h, 1d
-	Metrics     []string `form:"metrics"`     // pm25, pm10, co2, etc.
-	Aggregation string   `form:"aggregation"` // avg, max, min, sum
-	Limit       int      `form:"limit" binding:"min=1,max=1000"`
+	Interval    string   `form:"interval"`                                 // 1m, 5m, 15m, 1h, 6h, 1d
+	Metrics     []string `form:"metrics"`                                  // pm25, pm10, co2, etc.，为空时聚合全部有数据的指标
+	Aggregation string   `form:"aggregation"`                              // avg, max, min, sum，默认avg
+	Limit       int      `form:"limit" binding:"omitempty,min=1,max=1000"` // 每个序列最多返回的时间桶数
 	Offset      int      `form:"offset" binding:"min=0"`
 }
 
@@ -122,56 +124,6 @@ type AirQualityAlert struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// AirQualityLevel 空气质量等级
-type AirQualityLevel string
-
-const (
-	AirQualityLevelExcellent AirQualityLevel = "excellent" // 优
-	AirQualityLevelGood      AirQualityLevel = "good"      // 良
-	AirQualityLevelLight     AirQualityLevel = "light"     // 轻度污染
-	AirQualityLevelModerate  AirQualityLevel = "moderate"  // 中度污染
-	AirQualityLevelHeavy     AirQualityLevel = "heavy"     // 重度污染
-	AirQualityLevelSevere    AirQualityLevel = "severe"    // 严重污染
-)
-
-// GetAirQualityLevel 根据PM2.5值获取空气质量等级
-func GetAirQualityLevel(pm25 float64) AirQualityLevel {
-	switch {
-	case pm25 <= 35:
-		return AirQualityLevelExcellent
-	case pm25 <= 75:
-		return AirQualityLevelGood
-	case pm25 <= 115:
-		return AirQualityLevelLight
-	case pm25 <= 150:
-		return AirQualityLevelModerate
-	case pm25 <= 250:
-		return AirQualityLevelHeavy
-	default:
-		return AirQualityLevelSevere
-	}
-}
-
-// GetAirQualityLevelDescription 获取空气质量等级描述
-func GetAirQualityLevelDescription(level AirQualityLevel) string {
-	switch level {
-	case AirQualityLevelExcellent:
-		return "优"
-	case AirQualityLevelGood:
-		return "良"
-	case AirQualityLevelLight:
-		return "轻度污染"
-	case AirQualityLevelModerate:
-		return "中度污染"
-	case AirQualityLevelHeavy:
-		return "重度污染"
-	case AirQualityLevelSevere:
-		return "严重污染"
-	default:
-		return "未知"
-	}
-}
-
 // CalculateQualityScore 计算数据质量评分
 func CalculateQualityScore(data *AirQualityDataPayload) float64 {
 	score := 0.0
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,10 +12,14 @@ type AlertRule struct {
 	ID                   uint64         `json:"id" gorm:"primaryKey;autoIncrement"`
 	Name                 string         `json:"name" gorm:"type:varchar(100);not null"`
 	DeviceID             *string        `json:"device_id" gorm:"type:varchar(64);index"`
+	GroupID              *uint64        `json:"group_id" gorm:"index;comment:限定设备分组"`
+	LocationID           *uint64        `json:"location_id" gorm:"index;comment:限定位置子树"`
 	Metric               string         `json:"metric" gorm:"type:varchar(50);not null"`
 	ConditionType        string         `json:"condition_type" gorm:"type:varchar(10);not null"`
 	ThresholdValue       float64        `json:"threshold_value" gorm:"type:decimal(10,2);not null"`
 	DurationSeconds      int            `json:"duration_seconds" gorm:"default:0"`
+	ClearThreshold       *float64       `json:"clear_threshold" gorm:"type:decimal(10,2);comment:恢复阈值，为空时按阈值和回差计算"`
+	Hysteresis           float64        `json:"hysteresis" gorm:"type:decimal(10,2);default:0;comment:恢复回差"`
 	Severity             string         `json:"severity" gorm:"type:varchar(20);default:'warning'"`
 	Enabled              bool           `json:"enabled" gorm:"default:true"`
 	NotificationChannels *string        `json:"notification_channels" gorm:"type:json"`
@@ -54,6 +59,37 @@ func (Alert) TableName() string {
 	return "alerts"
 }
 
+// AlertHistory 告警状态变更历史
+type AlertHistory struct {
+	ID         uint64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	AlertID    uint64      `json:"alert_id" gorm:"not null;index"`
+	Action     AlertAction `json:"action" gorm:"type:varchar(20);not null"`
+	FromStatus string      `json:"from_status" gorm:"type:varchar(20)"`
+	ToStatus   string      `json:"to_status" gorm:"type:varchar(20);not null"`
+	UserID     *uint64     `json:"user_id" gorm:"index;comment:操作用户，为空表示系统自动处理"`
+	Comment    *string     `json:"comment" gorm:"type:text"`
+	Value      *float64    `json:"value" gorm:"type:decimal(10,2);comment:变更时的指标值"`
+	CreatedAt  time.Time   `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName 指定表名
+func (AlertHistory) TableName() string {
+	return "alert_histories"
+}
+
+// AlertAction 告警状态变更动作
+type AlertAction string
+
+const (
+	AlertActionTriggered    AlertAction = "triggered"     // 触发
+	AlertActionAcknowledged AlertAction = "acknowledged"  // 人工确认
+	AlertActionResolved     AlertAction = "resolved"      // 人工解决
+	AlertActionAutoResolved AlertAction = "auto_resolved" // 指标恢复后自动解决
+)
+
+// AlertMetricDeviceOffline 设备离线告警的指标名（非规则告警，RuleID为0）
+const AlertMetricDeviceOffline = "device_offline"
+
 // AlertConditionType 告警条件类型
 type AlertConditionType string
 
@@ -76,6 +112,105 @@ func (t AlertConditionType) IsValid() bool {
 	}
 }
 
+// alertValueEpsilon 等于/不等于条件的浮点比较容差
+const alertValueEpsilon = 1e-9
+
+// Matches 判断指标值是否满足条件
+func (t AlertConditionType) Matches(value, threshold float64) bool {
+	switch t {
+	case AlertConditionGT:
+		return value > threshold
+	case AlertConditionLT:
+		return value < threshold
+	case AlertConditionGTE:
+		return value >= threshold
+	case AlertConditionLTE:
+		return value <= threshold
+	case AlertConditionEQ:
+		return math.Abs(value-threshold) <= alertValueEpsilon
+	case AlertConditionNE:
+		return math.Abs(value-threshold) > alertValueEpsilon
+	default:
+		return false
+	}
+}
+
+// Symbol 获取条件的运算符表示
+func (t AlertConditionType) Symbol() string {
+	switch t {
+	case AlertConditionGT:
+		return ">"
+	case AlertConditionLT:
+		return "<"
+	case AlertConditionGTE:
+		return ">="
+	case AlertConditionLTE:
+		return "<="
+	case AlertConditionEQ:
+		return "="
+	case AlertConditionNE:
+		return "!="
+	default:
+		return string(t)
+	}
+}
+
+// ClearValue 获取告警恢复阈值（未配置恢复阈值时由阈值和回差计算）
+func (r *AlertRule) ClearValue() float64 {
+	if r.ClearThreshold != nil {
+		return *r.ClearThreshold
+	}
+	switch AlertConditionType(r.ConditionType) {
+	case AlertConditionLT, AlertConditionLTE:
+		return r.ThresholdValue + r.Hysteresis
+	default:
+		return r.ThresholdValue - r.Hysteresis
+	}
+}
+
+// IsCleared 判断指标值是否已回到恢复区间（告警可自动解决）
+func (r *AlertRule) IsCleared(value float64) bool {
+	condition := AlertConditionType(r.ConditionType)
+	if condition.Matches(value, r.ThresholdValue) {
+		return false
+	}
+	switch condition {
+	case AlertConditionGT, AlertConditionGTE:
+		return value <= r.ClearValue()
+	case AlertConditionLT, AlertConditionLTE:
+		return value >= r.ClearValue()
+	default:
+		return true
+	}
+}
+
+// AppliesTo 判断规则是否适用于指定设备（未指定设备的规则适用于全部设备）
+func (r *AlertRule) AppliesTo(deviceID string) bool {
+	return r.DeviceID == nil || *r.DeviceID == "" || *r.DeviceID == deviceID
+}
+
+// HasScope 规则是否限定了设备分组或位置
+func (r *AlertRule) HasScope() bool {
+	return r.GroupID != nil || r.LocationID != nil
+}
+
+// InScope 判断设备归属是否满足规则的分组和位置限定（membership为空视为不满足）
+func (r *AlertRule) InScope(membership *DeviceMembership) bool {
+	if !r.HasScope() {
+		return true
+	}
+	if membership == nil {
+		return false
+	}
+	if r.GroupID != nil && !membership.InGroup(*r.GroupID) {
+		return false
+	}
+	if r.LocationID != nil && !InSubtree(membership.LocationPath, *r.LocationID) {
+		return false
+	}
+	return true
+}
+
 // AlertSeverity 告警严重程度
 type AlertSeverity string
 
@@ -118,12 +253,16 @@ func (s AlertStatus) IsValid() bool {
 type AlertRuleCreateRequest struct {
 	Name                 string   `json:"name" binding:"required"`
 	DeviceID             *string  `json:"device_id,omitempty"`
+	GroupID              *uint64  `json:"group_id,omitempty"`
+	LocationID           *uint64  `json:"location_id,omitempty"`
 	Metric               string   `json:"metric" binding:"required"`
 	ConditionType        string   `json:"condition_type" binding:"required"`
 	ThresholdValue       float64  `json:"threshold_value" binding:"required"`
 	DurationSeconds      int      `json:"duration_seconds"`
+	ClearThreshold       *float64 `json:"clear_threshold,omitempty"`
+	Hysteresis           float64  `json:"hysteresis"`
 	Severity             string   `json:"severity"`
-	Enabled              bool     `json:"enabled"`
+	Enabled              *bool    `json:"enabled,omitempty"`
 	NotificationChannels []string `json:"notification_channels,omitempty"`
 }
 
@@ -131,10 +270,14 @@ type AlertRuleCreateRequest struct {
 type AlertRuleUpdateRequest struct {
 	Name                 *string  `json:"name,omitempty"`
 	DeviceID             *string  `json:"device_id,omitempty"`
+	GroupID              *uint64  `json:"group_id,omitempty"`
+	LocationID           *uint64  `json:"location_id,omitempty"`
 	Metric               *string  `json:"metric,omitempty"`
 	ConditionType        *string  `json:"condition_type,omitempty"`
 	ThresholdValue       *float64 `json:"threshold_value,omitempty"`
 	DurationSeconds      *int     `json:"duration_seconds,omitempty"`
+	ClearThreshold       *float64 `json:"clear_threshold,omitempty"`
+	Hysteresis           *float64 `json:"hysteresis,omitempty"`
 	Severity             *string  `json:"severity,omitempty"`
 	Enabled              *bool    `json:"enabled,omitempty"`
 	NotificationChannels []string `json:"notification_channels,omitempty"`
@@ -142,12 +285,14 @@ type AlertRuleUpdateRequest struct {
 
 // AlertRuleListRequest 告警规则列表请求
 type AlertRuleListRequest struct {
-	Page     int    `form:"page" binding:"min=1"`
-	PageSize int    `form:"page_size" binding:"min=1,max=100"`
-	DeviceID string `form:"device_id"`
-	Metric   string `form:"metric"`
-	Severity string `form:"severity"`
-	Enabled  *bool  `form:"enabled"`
+	Page       int     `form:"page" binding:"min=1"`
+	PageSize   int     `form:"page_size" binding:"min=1,max=100"`
+	DeviceID   string  `form:"device_id"`
+	GroupID    *uint64 `form:"group_id"`
+	LocationID *uint64 `form:"location_id"`
+	Metric     string  `form:"metric"`
+	Severity   string  `form:"severity"`
+	Enabled    *bool   `form:"enabled"`
 }
 
 // AlertRuleListResponse 告警规则列表响应
@@ -180,12 +325,12 @@ type AlertListResponse struct {
 
 // AlertAcknowledgeRequest 确认告警请求
 type AlertAcknowledgeRequest struct {
-	Message string `json:"message,omitempty"`
+	Comment string `json:"comment,omitempty"`
 }
 
 // AlertResolveRequest 解决告警请求
 type AlertResolveRequest struct {
-	Message string `json:"message,omitempty"`
+	Comment string `json:"comment,omitempty"`
 }
 
 // AlertStatistics 告警统计
@@ -217,12 +362,13 @@ const (
 	NotificationChannelWebhook  NotificationChannel = "webhook"
 	NotificationChannelDingTalk NotificationChannel = "dingtalk"
 	NotificationChannelWeChat   NotificationChannel = "wechat"
+	NotificationChannelMQTT     NotificationChannel = "mqtt"
 )
 
 // IsValid 验证通知渠道
 func (c NotificationChannel) IsValid() bool {
 	switch c {
-	case NotificationChannelEmail, NotificationChannelSMS, NotificationChannelWebhook, NotificationChannelDingTalk, NotificationChannelWeChat:
+	case NotificationChannelEmail, NotificationChannelSMS, NotificationChannelWebhook, NotificationChannelDingTalk, NotificationChannelWeChat, NotificationChannelMQTT:
 		return true
 	default:
 		return false
@@ -14,6 +14,7 @@ type Device struct {
 	LocationLatitude  *float64       `json:"location_latitude" gorm:"type:decimal(10,8)"`
 	LocationLongitude *float64       `json:"location_longitude" gorm:"type:decimal(11,8)"`
 	LocationAddress   *string        `json:"location_address" gorm:"type:varchar(200)"`
+	LocationID        *uint64        `json:"location_id" gorm:"index;comment:所属位置节点"`
 	Status            DeviceStatus   `json:"status" gorm:"type:varchar(20);default:'offline'"`
 	Config            *string        `json:"config" gorm:"type:json"`
 	CreatedAt         time.Time      `json:"created_at" gorm:"autoCreateTime"`
@@ -125,6 +126,15 @@ type DeviceListRequest struct {
 	Keyword  string `form:"keyword"`
 }
 
+// DeviceQueryRequest 设备查询请求，可按分组或位置子树筛选
+type DeviceQueryRequest struct {
+	DeviceScopeFilter
+	Type   string `form:"type"`
+	Status string `form:"status"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
 // DeviceListResponse 设备列表响应
 type DeviceListResponse struct {
 	Devices  []Device `json:"devices"`
@@ -144,6 +154,7 @@ type DeviceRuntimeStatus struct {
 	ErrorCode       int        `json:"error_code" gorm:"default:0;comment:错误代码"`
 	ErrorMessage    string     `json:"error_message" gorm:"type:text;comment:错误信息"`
 	FirmwareVersion string     `json:"firmware_version" gorm:"type:varchar(50);comment:固件版本"`
+	UptimeSeconds   *int64     `json:"uptime_seconds" gorm:"comment:运行时间(秒)"`
 	LastHeartbeat   time.Time  `json:"last_heartbeat" gorm:"autoUpdateTime;comment:最后心跳时间"`
 	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
@@ -154,6 +165,49 @@ func (DeviceRuntimeStatus) TableName() string {
 	return "device_runtime_status"
 }
 
+// DeviceStatusReport 设备在状态主题上报的在线状态（保留消息，遗嘱消息为offline）
+type DeviceStatusReport struct {
+	DeviceID        string       `json:"device_id"`
+	Status          DeviceStatus `json:"status"`
+	FirmwareVersion string       `json:"firmware_version,omitempty"`
+	Uptime          *int64       `json:"uptime,omitempty"`
+	Timestamp       int64        `json:"timestamp,omitempty"`
+}
+
+// DeviceCredential 设备MQTT接入凭证（用户名即设备ID）
+type DeviceCredential struct {
+	ID         uint64                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID   string                 `json:"device_id" gorm:"type:varchar(64);not null;uniqueIndex"`
+	SecretHash string                 `json:"-" gorm:"type:varchar(255);not null;comment:bcrypt哈希后的密钥"`
+	Status     DeviceCredentialStatus `json:"status" gorm:"type:varchar(20);default:'active'"`
+	IssuedAt   time.Time              `json:"issued_at" gorm:"comment:签发时间"`
+	RevokedAt  *time.Time             `json:"revoked_at" gorm:"comment:吊销时间"`
+	LastUsedAt *time.Time             `json:"last_used_at" gorm:"comment:最后认证时间"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DeviceCredential) TableName() string {
+	return "device_credentials"
+}
+
+// DeviceCredentialStatus 设备凭证状态
+type DeviceCredentialStatus string
+
+const (
+	DeviceCredentialStatusActive  DeviceCredentialStatus = "active"
+	DeviceCredentialStatusRevoked DeviceCredentialStatus = "revoked"
+)
+
+// DeviceCredentialIssueResponse 签发凭证响应（明文密钥仅返回一次）
+type DeviceCredentialIssueResponse struct {
+	DeviceID string    `json:"device_id"`
+	Username string    `json:"username"`
+	Secret   string    `json:"secret"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
 // DeviceRealtimeStatus 设备实时状态（用于API响应）
 type DeviceRealtimeStatus struct {
 	Device
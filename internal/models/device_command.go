@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeviceCommand 下发给设备的控制指令记录
+type DeviceCommand struct {
+	ID           uint64              `json:"id" gorm:"primaryKey;autoIncrement"`
+	CommandID    string              `json:"command_id" gorm:"type:varchar(64);not null;uniqueIndex;comment:关联ID，设备响应时原样返回"`
+	DeviceID     string              `json:"device_id" gorm:"type:varchar(64);not null;index"`
+	Command      DeviceCommandType   `json:"command" gorm:"type:varchar(50);not null"`
+	Params       *string             `json:"params" gorm:"type:json"`
+	Status       DeviceCommandStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	Result       *string             `json:"result" gorm:"type:json;comment:设备返回的执行结果"`
+	ErrorMessage *string             `json:"error_message" gorm:"type:text"`
+	RequestedBy  *uint64             `json:"requested_by" gorm:"comment:下发用户"`
+	SentAt       time.Time           `json:"sent_at"`
+	ExpiresAt    time.Time           `json:"expires_at" gorm:"index;comment:等待响应的截止时间"`
+	RespondedAt  *time.Time          `json:"responded_at"`
+	CreatedAt    time.Time           `json:"created_at" gorm:"autoCreateTime;index"`
+	UpdatedAt    time.Time           `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DeviceCommand) TableName() string {
+	return "device_commands"
+}
+
+// DeviceCommandType 设备指令类型
+type DeviceCommandType string
+
+const (
+	DeviceCommandSetReportInterval DeviceCommandType = "set_report_interval" // 设置上报间隔
+	DeviceCommandReboot            DeviceCommandType = "reboot"              // 重启
+	DeviceCommandCalibrate         DeviceCommandType = "calibrate"           // 校准传感器
+	DeviceCommandReadNow           DeviceCommandType = "read_now"            // 立即采集并上报
+)
+
+// 上报间隔取值范围（秒）
+const (
+	MinReportInterval = 1
+	MaxReportInterval = 86400
+)
+
+// ValidateParams 校验指令类型及参数
+func (t DeviceCommandType) ValidateParams(params map[string]interface{}) error {
+	switch t {
+	case DeviceCommandSetReportInterval:
+		interval, ok := params["interval"].(float64)
+		if !ok || interval != float64(int(interval)) {
+			return fmt.Errorf("interval 必须为整数秒")
+		}
+		if interval < MinReportInterval || interval > MaxReportInterval {
+			return fmt.Errorf("interval 取值范围为 %d-%d 秒", MinReportInterval, MaxReportInterval)
+		}
+	case DeviceCommandCalibrate:
+		if metric, ok := params["metric"]; ok {
+			if _, ok := metric.(string); !ok {
+				return fmt.Errorf("metric 必须为字符串")
+			}
+		}
+	case DeviceCommandReboot, DeviceCommandReadNow:
+	default:
+		return fmt.Errorf("不支持的指令: %s", t)
+	}
+	return nil
+}
+
+// DeviceCommandStatus 设备指令状态
+type DeviceCommandStatus string
+
+const (
+	DeviceCommandStatusPending  DeviceCommandStatus = "pending"   // 已下发，等待设备响应
+	DeviceCommandStatusAcked    DeviceCommandStatus = "acked"     // 设备执行成功
+	DeviceCommandStatusFailed   DeviceCommandStatus = "failed"    // 设备执行失败或下发失败
+	DeviceCommandStatusTimedOut DeviceCommandStatus = "timed_out" // 超时未响应
+)
+
+// DeviceCommandRequest 下发设备指令请求
+type DeviceCommandRequest struct {
+	Command DeviceCommandType      `json:"command" binding:"required"`
+	Params  map[string]interface{} `json:"params"`
+	Timeout int                    `json:"timeout" binding:"omitempty,min=1,max=300"` // 等待响应超时（秒）
+}
+
+// DeviceCommandListRequest 设备指令历史请求
+type DeviceCommandListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Status   string `form:"status"`
+}
+
+// DeviceCommandListResponse 设备指令历史响应
+type DeviceCommandListResponse struct {
+	Commands []DeviceCommand `json:"commands"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
+
+// DeviceCommandMessage 发布到 air-quality/{type}/{id}/cmd 的指令消息
+type DeviceCommandMessage struct {
+	CommandID string                 `json:"command_id"`
+	Command   DeviceCommandType      `json:"command"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Timestamp int64                  `json:"timestamp"`
+	ExpiresAt int64                  `json:"expires_at"`
+}
+
+// DeviceCommandResponse 设备在 air-quality/{type}/{id}/response 返回的指令执行结果
+type DeviceCommandResponse struct {
+	CommandID string                 `json:"command_id"`
+	Status    DeviceCommandStatus    `json:"status"` // acked 或 failed
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
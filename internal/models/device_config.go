@@ -0,0 +1,73 @@
+package models
+
+import (
+	"reflect"
+	"time"
+)
+
+// DeviceConfigState 设备配置同步状态（期望配置与设备上报的已应用配置）
+type DeviceConfigState struct {
+	ID              uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID        string     `json:"device_id" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Desired         *string    `json:"desired" gorm:"type:json;comment:期望配置"`
+	DesiredVersion  int64      `json:"desired_version" gorm:"default:0;comment:期望配置版本，每次变更递增"`
+	DesiredAt       *time.Time `json:"desired_at" gorm:"comment:期望配置变更时间"`
+	Reported        *string    `json:"reported" gorm:"type:json;comment:设备上报的已应用配置"`
+	ReportedVersion int64      `json:"reported_version" gorm:"default:0;comment:设备已应用的配置版本"`
+	ReportedAt      *time.Time `json:"reported_at" gorm:"comment:设备上报时间"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DeviceConfigState) TableName() string {
+	return "device_config_states"
+}
+
+// DeviceConfigMessage 发布到 air-quality/{type}/{id}/config 的期望配置（保留消息）
+type DeviceConfigMessage struct {
+	Version   int64                  `json:"version"`
+	Config    map[string]interface{} `json:"config"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// DeviceConfigReport 设备在 air-quality/{type}/{id}/config/reported 上报的已应用配置
+type DeviceConfigReport struct {
+	Version int64                  `json:"version"`
+	Config  map[string]interface{} `json:"config"`
+}
+
+// DeviceConfigSyncStatus 设备配置同步状态（用于API响应）
+type DeviceConfigSyncStatus struct {
+	DeviceID        string                 `json:"device_id"`
+	Desired         map[string]interface{} `json:"desired"`
+	DesiredVersion  int64                  `json:"desired_version"`
+	DesiredAt       *time.Time             `json:"desired_at"`
+	Reported        map[string]interface{} `json:"reported"`
+	ReportedVersion int64                  `json:"reported_version"`
+	ReportedAt      *time.Time             `json:"reported_at"`
+	Delta           map[string]interface{} `json:"delta"`
+	InSync          bool                   `json:"in_sync"`
+}
+
+// ConfigDelta 计算期望配置中尚未被设备应用的部分（嵌套对象逐层比较，未设置的项忽略）
+func ConfigDelta(desired, reported map[string]interface{}) map[string]interface{} {
+	delta := make(map[string]interface{})
+	for key, want := range desired {
+		if want == nil {
+			continue
+		}
+		got, ok := reported[key]
+		if wantMap, isMap := want.(map[string]interface{}); isMap {
+			gotMap, _ := got.(map[string]interface{})
+			if sub := ConfigDelta(wantMap, gotMap); len(sub) > 0 {
+				delta[key] = sub
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(want, got) {
+			delta[key] = want
+		}
+	}
+	return delta
+}
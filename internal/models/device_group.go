@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// DeviceGroup 设备分组（也用作标签，一台设备可属于多个分组）
+type DeviceGroup struct {
+	ID          uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	Description *string   `json:"description" gorm:"type:text"`
+	DeviceCount int64     `json:"device_count" gorm:"-"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DeviceGroup) TableName() string {
+	return "device_groups"
+}
+
+// DeviceGroupMember 设备分组成员关系
+type DeviceGroupMember struct {
+	GroupID   uint64    `json:"group_id" gorm:"primaryKey"`
+	DeviceID  string    `json:"device_id" gorm:"primaryKey;type:varchar(64);index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (DeviceGroupMember) TableName() string {
+	return "device_group_members"
+}
+
+// DeviceGroupRequest 创建或更新分组请求
+type DeviceGroupRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description *string `json:"description"`
+}
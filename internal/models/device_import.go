@@ -0,0 +1,36 @@
+package models
+
+import "encoding/json"
+
+// DeviceImportColumns 设备导入/导出CSV的列（与JSON字段名一致）
+var DeviceImportColumns = []string{"id", "name", "type", "latitude", "longitude", "address", "config"}
+
+// DeviceImportRecord 设备批量导入/导出记录
+type DeviceImportRecord struct {
+	Row       int             `json:"-"` // 来源行号：CSV为文件行号，JSON为数组下标+1
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Type      DeviceType      `json:"type"`
+	Latitude  *float64        `json:"latitude,omitempty"`
+	Longitude *float64        `json:"longitude,omitempty"`
+	Address   string          `json:"address,omitempty"`
+	Config    json.RawMessage `json:"config,omitempty"`
+}
+
+// DeviceImportError 导入时单行校验错误
+type DeviceImportError struct {
+	Row       int    `json:"row"`
+	DeviceID  string `json:"device_id,omitempty"`
+	Error     string `json:"error"`
+	Duplicate bool   `json:"duplicate,omitempty"` // 设备ID在文件中重复或已存在
+}
+
+// DeviceImportResult 批量导入结果，存在错误时不写入任何设备
+type DeviceImportResult struct {
+	DryRun     bool                `json:"dry_run"`
+	Total      int                 `json:"total"`
+	Valid      int                 `json:"valid"`
+	Imported   int                 `json:"imported"`
+	Duplicates int                 `json:"duplicates"`
+	Errors     []DeviceImportError `json:"errors"`
+}
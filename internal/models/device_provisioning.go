@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ProvisioningMode 未注册设备接入策略
+type ProvisioningMode string
+
+const (
+	ProvisioningModeStrict  ProvisioningMode = "strict"  // 拒绝未注册设备的数据
+	ProvisioningModeAuto    ProvisioningMode = "auto"    // 按上报的设备类型和ID自动注册
+	ProvisioningModePending ProvisioningMode = "pending" // 隔离数据，等待管理员审批
+)
+
+// IsValid 检查接入策略是否有效
+func (m ProvisioningMode) IsValid() bool {
+	switch m {
+	case ProvisioningModeStrict, ProvisioningModeAuto, ProvisioningModePending:
+		return true
+	}
+	return false
+}
+
+// PendingDevice 待审批设备：pending策略下首次上报的未注册设备
+type PendingDevice struct {
+	ID               uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID         string     `json:"device_id" gorm:"type:varchar(64);not null;uniqueIndex"`
+	DeviceType       DeviceType `json:"device_type" gorm:"type:varchar(50);not null;comment:首次上报的设备类型"`
+	ReadingCount     int64      `json:"reading_count" gorm:"default:0;comment:累计上报数据条数"`
+	QuarantinedCount int64      `json:"quarantined_count" gorm:"default:0;comment:已隔离保存的数据条数"`
+	FirstSeenAt      time.Time  `json:"first_seen_at"`
+	LastSeenAt       time.Time  `json:"last_seen_at" gorm:"index"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (PendingDevice) TableName() string {
+	return "pending_devices"
+}
+
+// QuarantinedReading 待审批设备的隔离数据，审批通过后写入传感器数据表
+type QuarantinedReading struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID  string    `json:"device_id" gorm:"type:varchar(64);not null;index"`
+	Data      string    `json:"data" gorm:"type:json;not null;comment:序列化的传感器数据"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (QuarantinedReading) TableName() string {
+	return "quarantined_readings"
+}
+
+// PendingDeviceApproveRequest 审批待接入设备请求，字段为空时使用上报信息
+type PendingDeviceApproveRequest struct {
+	Name              string     `json:"name" binding:"omitempty,max=100"`
+	Type              DeviceType `json:"type"`
+	LocationLatitude  *float64   `json:"location_latitude"`
+	LocationLongitude *float64   `json:"location_longitude"`
+	LocationAddress   *string    `json:"location_address"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// DeviceShadow 设备影子：各指标最近一次取值，仪表板无需查询原始数据表
+type DeviceShadow struct {
+	ID           uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID     string     `json:"device_id" gorm:"type:varchar(64);not null;uniqueIndex"`
+	DeviceType   DeviceType `json:"device_type" gorm:"type:varchar(50)"`
+	SensorID     string     `json:"sensor_id" gorm:"type:varchar(64);comment:最近一条数据的传感器ID"`
+	SensorType   string     `json:"sensor_type" gorm:"type:varchar(50);comment:最近一条数据的传感器类型"`
+	DataQuality  string     `json:"data_quality" gorm:"type:varchar(20);comment:最近一条数据的数据质量"`
+	Metrics      *string    `json:"metrics" gorm:"type:json;comment:各指标最近取值"`
+	LastDataTime *time.Time `json:"last_data_time" gorm:"index;comment:最后数据时间"`
+	Version      int64      `json:"version" gorm:"default:0;comment:影子版本，每次更新递增"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DeviceShadow) TableName() string {
+	return "device_shadows"
+}
+
+// ShadowMetric 指标最近取值
+type ShadowMetric struct {
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+	SensorID  string    `json:"sensor_id,omitempty"`
+}
+
+// ShadowReportedState 设备上报状态
+type ShadowReportedState struct {
+	Online          bool                   `json:"online"`
+	FirmwareVersion string                 `json:"firmware_version,omitempty"`
+	UptimeSeconds   *int64                 `json:"uptime_seconds,omitempty"`
+	LastHeartbeat   *time.Time             `json:"last_heartbeat,omitempty"`
+	Config          map[string]interface{} `json:"config"`
+	ConfigVersion   int64                  `json:"config_version"`
+}
+
+// ShadowDesiredState 服务端期望状态
+type ShadowDesiredState struct {
+	Config        map[string]interface{} `json:"config"`
+	ConfigVersion int64                  `json:"config_version"`
+}
+
+// DeviceShadowView 设备影子（用于API响应）
+type DeviceShadowView struct {
+	DeviceID     string                  `json:"device_id"`
+	DeviceType   DeviceType              `json:"device_type"`
+	Metrics      map[string]ShadowMetric `json:"metrics"`
+	LastDataTime *time.Time              `json:"last_data_time"`
+	Reported     ShadowReportedState     `json:"reported"`
+	Desired      ShadowDesiredState      `json:"desired"`
+	Delta        map[string]interface{}  `json:"delta"`
+	InSync       bool                    `json:"in_sync"`
+	Version      int64                   `json:"version"`
+	UpdatedAt    *time.Time              `json:"updated_at"`
+}
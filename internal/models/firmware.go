@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Firmware 已上传的固件包（文件保存在本地磁盘）
+type Firmware struct {
+	ID         uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceType DeviceType `json:"device_type" gorm:"type:varchar(50);not null;uniqueIndex:idx_firmware_type_version"`
+	Version    string     `json:"version" gorm:"type:varchar(50);not null;uniqueIndex:idx_firmware_type_version"`
+	FileName   string     `json:"file_name" gorm:"type:varchar(255);not null;comment:上传时的原始文件名"`
+	FilePath   string     `json:"-" gorm:"type:varchar(512);not null;comment:本地存储路径"`
+	Size       int64      `json:"size" gorm:"not null;comment:文件大小(字节)"`
+	SHA256     string     `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Notes      *string    `json:"notes" gorm:"type:text;comment:版本说明"`
+	UploadedBy *uint64    `json:"uploaded_by" gorm:"comment:上传用户"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Firmware) TableName() string {
+	return "firmware"
+}
+
+// FirmwareUploadRequest 固件上传表单（文件字段名为file）
+type FirmwareUploadRequest struct {
+	DeviceType DeviceType `form:"device_type" binding:"required"`
+	Version    string     `form:"version" binding:"required,max=50"`
+	Notes      string     `form:"notes"`
+}
+
+// FirmwareCampaign 固件升级活动，按阶段百分比逐步推送给目标设备
+type FirmwareCampaign struct {
+	ID               uint64                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name             string                 `json:"name" gorm:"type:varchar(100);not null"`
+	FirmwareID       uint64                 `json:"firmware_id" gorm:"not null;index"`
+	DeviceType       DeviceType             `json:"device_type" gorm:"type:varchar(50);not null"`
+	TargetDevices    *string                `json:"target_devices" gorm:"type:json;comment:指定设备列表，为空表示该类型全部设备"`
+	Stages           string                 `json:"stages" gorm:"type:json;not null;comment:各阶段累计覆盖百分比"`
+	CurrentStage     int                    `json:"current_stage" gorm:"default:0;comment:当前阶段序号，从0开始"`
+	Status           FirmwareCampaignStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	FailureThreshold float64                `json:"failure_threshold" gorm:"comment:失败率阈值"`
+	TotalDevices     int                    `json:"total_devices" gorm:"default:0"`
+	HaltReason       *string                `json:"halt_reason" gorm:"type:varchar(255)"`
+	CreatedBy        *uint64                `json:"created_by"`
+	StartedAt        *time.Time             `json:"started_at"`
+	CompletedAt      *time.Time             `json:"completed_at"`
+	CreatedAt        time.Time              `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Stats *FirmwareCampaignStats `json:"stats,omitempty" gorm:"-"`
+}
+
+// TableName 指定表名
+func (FirmwareCampaign) TableName() string {
+	return "firmware_campaigns"
+}
+
+// StageList 解析各阶段累计百分比
+func (c *FirmwareCampaign) StageList() []int {
+	var stages []int
+	if err := json.Unmarshal([]byte(c.Stages), &stages); err != nil || len(stages) == 0 {
+		return []int{100}
+	}
+	return stages
+}
+
+// FirmwareCampaignStatus 升级活动状态
+type FirmwareCampaignStatus string
+
+const (
+	FirmwareCampaignStatusDraft     FirmwareCampaignStatus = "draft"     // 已创建，未开始
+	FirmwareCampaignStatusRunning   FirmwareCampaignStatus = "running"   // 推送中
+	FirmwareCampaignStatusPaused    FirmwareCampaignStatus = "paused"    // 人工暂停
+	FirmwareCampaignStatusHalted    FirmwareCampaignStatus = "halted"    // 失败率超过阈值自动暂停
+	FirmwareCampaignStatusCompleted FirmwareCampaignStatus = "completed" // 全部阶段完成
+)
+
+// FirmwareCampaignRequest 创建升级活动请求
+type FirmwareCampaignRequest struct {
+	Name             string   `json:"name" binding:"required,max=100"`
+	FirmwareID       uint64   `json:"firmware_id" binding:"required"`
+	DeviceIDs        []string `json:"device_ids"`                                       // 为空时覆盖固件对应类型的全部设备
+	Stages           []int    `json:"stages"`                                           // 各阶段累计百分比，如[10,50,100]，默认[100]
+	FailureThreshold *float64 `json:"failure_threshold" binding:"omitempty,gt=0,lte=1"` // 为空时使用配置默认值
+}
+
+// ValidateStages 校验阶段百分比：递增、取值1-100且最后一个阶段为100
+func ValidateStages(stages []int) error {
+	prev := 0
+	for _, pct := range stages {
+		if pct <= prev || pct > 100 {
+			return fmt.Errorf("阶段百分比必须在1-100之间且严格递增")
+		}
+		prev = pct
+	}
+	if prev != 100 {
+		return fmt.Errorf("最后一个阶段必须覆盖100%%的设备")
+	}
+	return nil
+}
+
+// FirmwareCampaignStats 升级活动进度统计
+type FirmwareCampaignStats struct {
+	Total       int64   `json:"total"`
+	Pending     int64   `json:"pending"`
+	InProgress  int64   `json:"in_progress"`
+	Succeeded   int64   `json:"succeeded"`
+	Failed      int64   `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// FirmwareUpdate 单台设备在升级活动中的升级记录
+type FirmwareUpdate struct {
+	ID           uint64               `json:"id" gorm:"primaryKey;autoIncrement"`
+	CampaignID   uint64               `json:"campaign_id" gorm:"not null;uniqueIndex:idx_firmware_update_campaign_device"`
+	DeviceID     string               `json:"device_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_firmware_update_campaign_device;index"`
+	Stage        int                  `json:"stage" gorm:"not null;comment:所属阶段序号"`
+	Status       FirmwareUpdateStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	Progress     int                  `json:"progress" gorm:"default:0;comment:进度百分比"`
+	ErrorMessage *string              `json:"error_message" gorm:"type:text"`
+	NotifiedAt   *time.Time           `json:"notified_at" gorm:"index;comment:首次推送时间"`
+	CompletedAt  *time.Time           `json:"completed_at"`
+	CreatedAt    time.Time            `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time            `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (FirmwareUpdate) TableName() string {
+	return "firmware_updates"
+}
+
+// FirmwareUpdateStatus 设备升级状态
+type FirmwareUpdateStatus string
+
+const (
+	FirmwareUpdateStatusPending     FirmwareUpdateStatus = "pending"     // 所属阶段尚未开始
+	FirmwareUpdateStatusNotified    FirmwareUpdateStatus = "notified"    // 已推送升级通知
+	FirmwareUpdateStatusDownloading FirmwareUpdateStatus = "downloading" // 设备下载中
+	FirmwareUpdateStatusInstalling  FirmwareUpdateStatus = "installing"  // 设备安装中
+	FirmwareUpdateStatusSucceeded   FirmwareUpdateStatus = "succeeded"   // 升级成功
+	FirmwareUpdateStatusFailed      FirmwareUpdateStatus = "failed"      // 升级失败或超时
+)
+
+// FirmwareUpdateInProgressStatuses 已推送但尚未结束的升级状态
+var FirmwareUpdateInProgressStatuses = []FirmwareUpdateStatus{
+	FirmwareUpdateStatusNotified, FirmwareUpdateStatusDownloading, FirmwareUpdateStatusInstalling,
+}
+
+// IsFinal 是否为终态
+func (s FirmwareUpdateStatus) IsFinal() bool {
+	return s == FirmwareUpdateStatusSucceeded || s == FirmwareUpdateStatusFailed
+}
+
+// IsReportable 是否为设备可上报的状态
+func (s FirmwareUpdateStatus) IsReportable() bool {
+	switch s {
+	case FirmwareUpdateStatusDownloading, FirmwareUpdateStatusInstalling, FirmwareUpdateStatusSucceeded, FirmwareUpdateStatusFailed:
+		return true
+	}
+	return false
+}
+
+// FirmwareUpdateListRequest 设备升级记录查询请求
+type FirmwareUpdateListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Status   string `form:"status"`
+}
+
+// FirmwareUpdateListResponse 设备升级记录查询响应
+type FirmwareUpdateListResponse struct {
+	Updates  []FirmwareUpdate `json:"updates"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+}
+
+// FirmwareUpdateMessage 发布到 air-quality/{type}/{id}/ota 的升级通知（保留消息，结束后清除）
+type FirmwareUpdateMessage struct {
+	CampaignID uint64 `json:"campaign_id"`
+	Version    string `json:"version"`
+	URL        string `json:"url"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	ExpiresAt  int64  `json:"expires_at"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// FirmwareProgressReport 设备在 air-quality/{type}/{id}/ota/progress 上报的升级进度
+type FirmwareProgressReport struct {
+	CampaignID uint64               `json:"campaign_id"`
+	Status     FirmwareUpdateStatus `json:"status"`
+	Progress   int                  `json:"progress,omitempty"`
+	Version    string               `json:"version,omitempty"`
+	Error      string               `json:"error,omitempty"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocationNode 位置层级节点（站点→楼栋→楼层→房间）
+type LocationNode struct {
+	ID          uint64       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ParentID    *uint64      `json:"parent_id" gorm:"index"`
+	Name        string       `json:"name" gorm:"type:varchar(100);not null"`
+	Type        LocationType `json:"type" gorm:"type:varchar(20);not null"`
+	Path        string       `json:"path" gorm:"type:varchar(255);not null;index;comment:祖先ID路径，如/1/4/9/"`
+	Description *string      `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Children []LocationNode `json:"children,omitempty" gorm:"-"`
+}
+
+// TableName 指定表名
+func (LocationNode) TableName() string {
+	return "locations"
+}
+
+// LocationPath 根据父节点路径生成节点路径
+func LocationPath(parentPath string, id uint64) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return parentPath + strconv.FormatUint(id, 10) + "/"
+}
+
+// InSubtree 节点路径是否位于指定节点的子树中（包含节点本身）
+func InSubtree(path string, locationID uint64) bool {
+	return strings.Contains(path, "/"+strconv.FormatUint(locationID, 10)+"/")
+}
+
+// LocationType 位置层级类型
+type LocationType string
+
+const (
+	LocationTypeSite     LocationType = "site"     // 站点
+	LocationTypeBuilding LocationType = "building" // 楼栋
+	LocationTypeFloor    LocationType = "floor"    // 楼层
+	LocationTypeRoom     LocationType = "room"     // 房间
+)
+
+// Level 层级序号，站点为0，无效类型返回-1
+func (t LocationType) Level() int {
+	switch t {
+	case LocationTypeSite:
+		return 0
+	case LocationTypeBuilding:
+		return 1
+	case LocationTypeFloor:
+		return 2
+	case LocationTypeRoom:
+		return 3
+	default:
+		return -1
+	}
+}
+
+// IsValid 检查层级类型是否有效
+func (t LocationType) IsValid() bool {
+	return t.Level() >= 0
+}
+
+// ValidateParent 校验父子层级：根节点必须是站点，子节点层级必须低于父节点（允许跨级，如站点下直接建房间）
+func (t LocationType) ValidateParent(parent *LocationNode) error {
+	if !t.IsValid() {
+		return fmt.Errorf("不支持的位置类型 %s", t)
+	}
+	if parent == nil {
+		if t != LocationTypeSite {
+			return fmt.Errorf("顶层位置必须是站点")
+		}
+		return nil
+	}
+	if t.Level() <= parent.Type.Level() {
+		return fmt.Errorf("%s 不能位于 %s 之下", t, parent.Type)
+	}
+	return nil
+}
+
+// LocationRequest 创建或更新位置请求
+type LocationRequest struct {
+	ParentID    *uint64      `json:"parent_id"`
+	Name        string       `json:"name" binding:"required,max=100"`
+	Type        LocationType `json:"type" binding:"required"`
+	Description *string      `json:"description"`
+}
+
+// DeviceAssignRequest 设备分配请求
+type DeviceAssignRequest struct {
+	DeviceIDs []string `json:"device_ids" binding:"required,min=1"`
+}
+
+// DeviceScopeFilter 按分组或位置子树筛选设备，两者同时指定时取交集
+type DeviceScopeFilter struct {
+	GroupID    *uint64 `form:"group_id" json:"group_id,omitempty"`
+	LocationID *uint64 `form:"location_id" json:"location_id,omitempty"`
+}
+
+// IsEmpty 是否未指定任何筛选条件
+func (f DeviceScopeFilter) IsEmpty() bool {
+	return f.GroupID == nil && f.LocationID == nil
+}
+
+// DeviceMembership 设备所属位置和分组，用于告警规则范围匹配
+type DeviceMembership struct {
+	LocationPath string
+	GroupIDs     []uint64
+}
+
+// InGroup 设备是否属于指定分组
+func (m *DeviceMembership) InGroup(groupID uint64) bool {
+	for _, id := range m.GroupIDs {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
+}
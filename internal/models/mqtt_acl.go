@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+)
+
+// MQTTACLRule MQTT主题访问控制规则（网关、管理客户端的覆盖规则）
+type MQTTACLRule struct {
+	ID          uint64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	Username    string         `json:"username" gorm:"type:varchar(64);not null;index;comment:客户端用户名"`
+	ClientType  MQTTClientType `json:"client_type" gorm:"type:varchar(20);not null;default:'gateway'"`
+	TopicFilter string         `json:"topic_filter" gorm:"type:varchar(255);not null;comment:主题过滤器，支持+和#"`
+	Access      MQTTACLAccess  `json:"access" gorm:"type:varchar(20);not null;default:'readwrite'"`
+	Allow       bool           `json:"allow" gorm:"not null;comment:true为允许，false为拒绝"`
+	Priority    int            `json:"priority" gorm:"default:0;comment:优先级，数值越大越先匹配"`
+	Enabled     bool           `json:"enabled" gorm:"default:true"`
+	Description *string        `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (MQTTACLRule) TableName() string {
+	return "mqtt_acl_rules"
+}
+
+// MQTTClientType MQTT客户端类型
+type MQTTClientType string
+
+const (
+	MQTTClientTypeGateway MQTTClientType = "gateway" // 网关（代理多个子设备）
+	MQTTClientTypeAdmin   MQTTClientType = "admin"   // 管理/运维客户端
+)
+
+// IsValid 验证客户端类型
+func (t MQTTClientType) IsValid() bool {
+	switch t {
+	case MQTTClientTypeGateway, MQTTClientTypeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// MQTTACLAccess 访问类型
+type MQTTACLAccess string
+
+const (
+	MQTTACLAccessRead      MQTTACLAccess = "read"      // 订阅
+	MQTTACLAccessWrite     MQTTACLAccess = "write"     // 发布
+	MQTTACLAccessReadWrite MQTTACLAccess = "readwrite" // 订阅和发布
+)
+
+// IsValid 验证访问类型
+func (a MQTTACLAccess) IsValid() bool {
+	switch a {
+	case MQTTACLAccessRead, MQTTACLAccessWrite, MQTTACLAccessReadWrite:
+		return true
+	default:
+		return false
+	}
+}
+
+// Covers 判断访问类型是否覆盖发布/订阅操作
+func (a MQTTACLAccess) Covers(write bool) bool {
+	if a == MQTTACLAccessReadWrite {
+		return true
+	}
+	if write {
+		return a == MQTTACLAccessWrite
+	}
+	return a == MQTTACLAccessRead
+}
+
+// MQTTACLRuleCreateRequest 创建ACL规则请求
+type MQTTACLRuleCreateRequest struct {
+	Username    string  `json:"username" binding:"required"`
+	ClientType  string  `json:"client_type" binding:"required"`
+	TopicFilter string  `json:"topic_filter" binding:"required"`
+	Access      string  `json:"access" binding:"required"`
+	Allow       *bool   `json:"allow"`
+	Priority    int     `json:"priority"`
+	Description *string `json:"description"`
+}
+
+// MQTTACLRuleUpdateRequest 更新ACL规则请求
+type MQTTACLRuleUpdateRequest struct {
+	TopicFilter *string `json:"topic_filter,omitempty"`
+	Access      *string `json:"access,omitempty"`
+	Allow       *bool   `json:"allow,omitempty"`
+	Priority    *int    `json:"priority,omitempty"`
+	Enabled     *bool   `json:"enabled,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
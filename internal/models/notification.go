@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationLog 告警通知投递记录（每次投递尝试一条）
+type NotificationLog struct {
+	ID        uint64              `json:"id" gorm:"primaryKey;autoIncrement"`
+	AlertID   uint64              `json:"alert_id" gorm:"not null;index"`
+	RuleID    uint64              `json:"rule_id" gorm:"index"`
+	Event     NotificationEvent   `json:"event" gorm:"type:varchar(20);not null"`
+	Channel   NotificationChannel `json:"channel" gorm:"type:varchar(20);not null"`
+	Target    string              `json:"target" gorm:"type:varchar(255);comment:投递目标（URL、收件人、主题）"`
+	Attempt   int                 `json:"attempt" gorm:"not null;comment:第几次尝试，从1开始"`
+	Status    NotificationStatus  `json:"status" gorm:"type:varchar(20);not null;index"`
+	Error     *string             `json:"error" gorm:"type:text"`
+	CreatedAt time.Time           `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName 指定表名
+func (NotificationLog) TableName() string {
+	return "notification_logs"
+}
+
+// NotificationEvent 通知事件类型
+type NotificationEvent string
+
+const (
+	NotificationEventTriggered NotificationEvent = "triggered" // 告警触发
+	NotificationEventEscalated NotificationEvent = "escalated" // 告警长时间未确认升级
+	NotificationEventResolved  NotificationEvent = "resolved"  // 告警解决
+)
+
+// NotificationStatus 通知投递状态
+type NotificationStatus string
+
+const (
+	NotificationStatusSuccess NotificationStatus = "success"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// NotificationMessage 告警通知内容（Webhook、MQTT以JSON格式发送）
+type NotificationMessage struct {
+	Event      NotificationEvent `json:"event"`
+	Subject    string            `json:"subject"`
+	Content    string            `json:"content"`
+	RuleName   string            `json:"rule_name,omitempty"`
+	Alert      *Alert            `json:"alert"`
+	Recipients []string          `json:"-"`
+	SentAt     time.Time         `json:"sent_at"`
+}
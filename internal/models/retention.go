@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// RetentionTarget 数据保留策略作用的表：按时间列过期，按主键分批删除
+type RetentionTarget struct {
+	Table      string
+	KeyColumns []string      // 主键列，多列时按组合键删除
+	TimeColumn string        // 判断过期的时间列
+	Condition  string        // 附加过滤条件，为空表示无
+	Args       []interface{} // 附加过滤条件参数
+	Children   []RetentionChild
+}
+
+// RetentionChild 随主表一起删除的关联表（仅单列主键）
+type RetentionChild struct {
+	Table      string
+	ForeignKey string
+}
+
+// RetentionResult 单个保留策略的执行结果
+type RetentionResult struct {
+	Policy   string    `json:"policy"`
+	Table    string    `json:"table"`
+	Days     int       `json:"days"`
+	Cutoff   time.Time `json:"cutoff"`
+	DryRun   bool      `json:"dry_run"`
+	Matched  int64     `json:"matched"` // 试运行时为将被删除的行数
+	Deleted  int64     `json:"deleted"`
+	Archived int64     `json:"archived"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// ArchiveManifestVersion 归档清单格式版本
+const ArchiveManifestVersion = 1
+
+// ArchiveDateLayout 归档日期格式（UTC日期，与天汇总的时间桶一致）
+const ArchiveDateLayout = "2006-01-02"
+
+// ArchiveManifest 归档目录的总清单，记录已归档到的日期和每天的概要
+type ArchiveManifest struct {
+	Version       int                 `json:"version"`
+	ArchivedUntil time.Time           `json:"archived_until"` // 此前的每一天都已归档（不含当天）
+	UpdatedAt     time.Time           `json:"updated_at"`
+	Days          []ArchiveDaySummary `json:"days"`
+}
+
+// ArchiveDaySummary 一天归档的概要
+type ArchiveDaySummary struct {
+	Date    string `json:"date"`
+	Devices int    `json:"devices"`
+	Records int64  `json:"records"`
+}
+
+// ArchiveDayManifest 一天的归档清单，位于该日期目录下
+type ArchiveDayManifest struct {
+	Version   int           `json:"version"`
+	Date      string        `json:"date"`
+	CreatedAt time.Time     `json:"created_at"`
+	Files     []ArchiveFile `json:"files"`
+}
+
+// ArchiveFile 单个设备一天的归档文件（gzip压缩的NDJSON，每行一条UnifiedSensorData）
+type ArchiveFile struct {
+	DeviceID       string    `json:"device_id"`
+	Path           string    `json:"path"` // 相对归档根目录的路径
+	Records        int64     `json:"records"`
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256"`
+}
+
+// ArchiveRestoreResult 从归档恢复数据的结果
+type ArchiveRestoreResult struct {
+	Days     int   `json:"days"`
+	Files    int   `json:"files"`
+	Records  int64 `json:"records"`
+	Restored int64 `json:"restored"` // 实际插入的条数，已存在的数据跳过
+}
@@ -0,0 +1,158 @@
+package models
+
+import "time"
+
+// RollupResolution 汇总表时间粒度
+type RollupResolution string
+
+const (
+	RollupResolutionMinute RollupResolution = "1m"
+	RollupResolutionHour   RollupResolution = "1h"
+	RollupResolutionDay    RollupResolution = "1d"
+)
+
+// RollupResolutions 全部汇总粒度，按从细到粗排列
+var RollupResolutions = []RollupResolution{RollupResolutionMinute, RollupResolutionHour, RollupResolutionDay}
+
+// Duration 汇总粒度对应的时间桶长度
+func (r RollupResolution) Duration() time.Duration {
+	switch r {
+	case RollupResolutionMinute:
+		return time.Minute
+	case RollupResolutionHour:
+		return time.Hour
+	case RollupResolutionDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// TableName 汇总粒度对应的表名
+func (r RollupResolution) TableName() string {
+	return "sensor_data_" + string(r)
+}
+
+// BucketStart 计算时间所在时间桶的起始时间（按Unix时间对齐，天粒度对齐到UTC零点）
+func BucketStart(t time.Time, bucket time.Duration) time.Time {
+	seconds := int64(bucket / time.Second)
+	return time.Unix(t.Unix()/seconds*seconds, 0)
+}
+
+// RollupMetricReadings 汇总中记录原始读数条数的伪指标，Count为桶内数据条数
+const RollupMetricReadings = "_readings"
+
+// SensorDataRollup 单设备单指标在一个时间桶内的汇总值（含扩展数据中的指标）
+type SensorDataRollup struct {
+	DeviceID    string     `json:"device_id" gorm:"type:varchar(64);primaryKey"`
+	Metric      string     `json:"metric" gorm:"type:varchar(64);primaryKey"`
+	BucketStart time.Time  `json:"bucket_start" gorm:"primaryKey;index"`
+	DeviceType  DeviceType `json:"device_type" gorm:"type:varchar(50);index"`
+	Avg         float64    `json:"avg"`
+	Min         float64    `json:"min"`
+	Max         float64    `json:"max"`
+	Sum         float64    `json:"sum"`
+	Count       int64      `json:"count"`
+	Last        float64    `json:"last"`
+	LastAt      time.Time  `json:"last_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Merge 合并同一指标另一个汇总值，用于由细粒度汇总计算粗粒度汇总
+func (r *SensorDataRollup) Merge(other *SensorDataRollup) {
+	if r.Count == 0 || other.Min < r.Min {
+		r.Min = other.Min
+	}
+	if r.Count == 0 || other.Max > r.Max {
+		r.Max = other.Max
+	}
+	if r.Count == 0 || other.LastAt.After(r.LastAt) {
+		r.Last = other.Last
+		r.LastAt = other.LastAt
+		if other.DeviceType != "" {
+			r.DeviceType = other.DeviceType
+		}
+	}
+	r.Sum += other.Sum
+	r.Count += other.Count
+	if r.Count > 0 {
+		r.Avg = r.Sum / float64(r.Count)
+	}
+}
+
+// Add 累加一个原始读数
+func (r *SensorDataRollup) Add(value float64, at time.Time, deviceType DeviceType) {
+	r.Merge(&SensorDataRollup{
+		DeviceType: deviceType,
+		Min:        value,
+		Max:        value,
+		Sum:        value,
+		Count:      1,
+		Last:       value,
+		LastAt:     at,
+	})
+}
+
+// SensorDataRollup1m 分钟汇总
+type SensorDataRollup1m struct {
+	SensorDataRollup
+}
+
+// TableName 指定表名
+func (SensorDataRollup1m) TableName() string {
+	return RollupResolutionMinute.TableName()
+}
+
+// SensorDataRollup1h 小时汇总
+type SensorDataRollup1h struct {
+	SensorDataRollup
+}
+
+// TableName 指定表名
+func (SensorDataRollup1h) TableName() string {
+	return RollupResolutionHour.TableName()
+}
+
+// SensorDataRollup1d 天汇总
+type SensorDataRollup1d struct {
+	SensorDataRollup
+}
+
+// TableName 指定表名
+func (SensorDataRollup1d) TableName() string {
+	return RollupResolutionDay.TableName()
+}
+
+// SensorDataRollupState 汇总任务进度：已处理的原始数据最大ID，以及汇总已覆盖到的入库时间
+type SensorDataRollupState struct {
+	Name        string    `json:"name" gorm:"type:varchar(50);primaryKey"`
+	LastID      uint64    `json:"last_id"`
+	RolledUntil time.Time `json:"rolled_until"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (SensorDataRollupState) TableName() string {
+	return "sensor_data_rollup_state"
+}
+
+// RollupFilter 汇总及原始数据查询条件，DeviceIDs和DeviceType至少指定一个
+type RollupFilter struct {
+	DeviceIDs  []string
+	DeviceType DeviceType
+	Metrics    []string // 为空时返回全部指标
+}
+
+// RollupBucketKey 需要重新计算的汇总桶
+type RollupBucketKey struct {
+	DeviceID    string
+	BucketStart time.Time
+}
+
+// SensorDataChange 汇总任务读取的新增原始数据（只含定位时间桶所需的列）
+type SensorDataChange struct {
+	ID        uint64
+	DeviceID  string
+	Timestamp time.Time
+	CreatedAt time.Time
+}
@@ -10,7 +10,7 @@ import (
 // UnifiedSensorData 统一传感器数据模型
 type UnifiedSensorData struct {
 	ID         uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
-	DeviceID   string     `json:"device_id" gorm:"type:varchar(64);not null;index:idx_device_timestamp"`
+	DeviceID   string     `json:"device_id" gorm:"type:varchar(64);not null;index:idx_device_timestamp;uniqueIndex:idx_device_message"`
 	DeviceType DeviceType `json:"device_type" gorm:"type:varchar(50);not null;index:idx_device_type"`
 	SensorID   string     `json:"sensor_id" gorm:"type:varchar(64);comment:传感器ID;index:idx_sensor_id"`
 	SensorType string     `json:"sensor_type" gorm:"type:varchar(50);comment:传感器类型;index:idx_sensor_type"`
@@ -46,6 +46,9 @@ type UnifiedSensorData struct {
 	// 扩展数据（JSON格式存储非标准指标）
 	ExtendedData *string `json:"extended_data" gorm:"type:json;comment:扩展数据"`
 
+	// 客户端消息ID，同一设备内唯一，用于HTTP上传去重
+	MessageID *string `json:"message_id,omitempty" gorm:"type:varchar(64);uniqueIndex:idx_device_message;comment:客户端消息ID"`
+
 	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
@@ -56,6 +59,19 @@ func (UnifiedSensorData) TableName() string {
 	return "unified_sensor_data"
 }
 
+// ColumnMetrics 存储在独立列中的标准指标，列名与指标名相同，其余指标存储在扩展数据中
+var ColumnMetrics = []string{"pm25", "pm10", "co2", "formaldehyde", "temperature", "humidity", "pressure", "o3", "no2", "so2", "co", "voc"}
+
+// IsColumnMetric 判断指标是否存储在独立列中
+func IsColumnMetric(metric string) bool {
+	for _, column := range ColumnMetrics {
+		if column == metric {
+			return true
+		}
+	}
+	return false
+}
+
 // GetMetricValue 获取指定指标的值
 func (s *UnifiedSensorData) GetMetricValue(metric string) *float64 {
 	switch metric {
@@ -327,11 +343,118 @@ type MQTTMessage struct {
 type UnifiedSensorDataUpload struct {
 	DeviceID   string                 `json:"device_id" binding:"required"`
 	DeviceType string                 `json:"device_type" binding:"required"`
+	MessageID  string                 `json:"message_id,omitempty"` // 客户端消息ID，重复上传时忽略
 	SensorID   string                 `json:"sensor_id"`
 	SensorType string                 `json:"sensor_type"`
-	Timestamp  int64                  `json:"timestamp" binding:"required"`
+	Timestamp  int64                  `json:"timestamp"` // 为0时使用服务器接收时间
 	Data       map[string]interface{} `json:"data" binding:"required"`
 	Location   *LocationInfo          `json:"location,omitempty"`
 	Quality    *QualityInfo           `json:"quality,omitempty"`
 	Extended   map[string]interface{} `json:"extended,omitempty"`
 }
+
+// UploadItemStatus 单条上传数据的处理结果
+type UploadItemStatus string
+
+const (
+	UploadItemStatusAccepted    UploadItemStatus = "accepted"    // 已入库
+	UploadItemStatusDuplicate   UploadItemStatus = "duplicate"   // 消息ID重复，已忽略
+	UploadItemStatusQuarantined UploadItemStatus = "quarantined" // 设备待审批，数据已隔离
+	UploadItemStatusRejected    UploadItemStatus = "rejected"    // 设备未注册
+	UploadItemStatusInvalid     UploadItemStatus = "invalid"     // 数据格式或字段校验失败
+	UploadItemStatusFailed      UploadItemStatus = "failed"      // 服务器内部错误
+)
+
+// UploadItemResult 单条上传数据的处理结果（index从0开始，对应数组下标或NDJSON行序）
+type UploadItemResult struct {
+	Index     int              `json:"index"`
+	DeviceID  string           `json:"device_id,omitempty"`
+	MessageID string           `json:"message_id,omitempty"`
+	Status    UploadItemStatus `json:"status"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// UploadBatchResult 批量上传结果汇总
+type UploadBatchResult struct {
+	Total       int                `json:"total"`
+	Accepted    int                `json:"accepted"`
+	Duplicate   int                `json:"duplicate"`
+	Quarantined int                `json:"quarantined"`
+	Failed      int                `json:"failed"`
+	Results     []UploadItemResult `json:"results"`
+}
+
+// ScopedDataQueryRequest 按分组或位置子树查询传感器数据和统计
+type ScopedDataQueryRequest struct {
+	DeviceScopeFilter
+	StartTime int64 `form:"start_time"`
+	EndTime   int64 `form:"end_time"`
+}
+
+// AggregationType 时间桶聚合方式
+type AggregationType string
+
+const (
+	AggregationAvg AggregationType = "avg"
+	AggregationMax AggregationType = "max"
+	AggregationMin AggregationType = "min"
+	AggregationSum AggregationType = "sum"
+)
+
+// IsValid 检查聚合方式是否有效
+func (a AggregationType) IsValid() bool {
+	switch a {
+	case AggregationAvg, AggregationMax, AggregationMin, AggregationSum:
+		return true
+	default:
+		return false
+	}
+}
+
+// aggregationIntervals 支持的聚合时间桶，按从细到粗排列
+var aggregationIntervals = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// ParseAggregationInterval 解析聚合时间桶（1m、5m、15m、1h、6h、1d），不支持时返回false
+func ParseAggregationInterval(interval string) (time.Duration, bool) {
+	for _, item := range aggregationIntervals {
+		if item.name == interval {
+			return item.duration, true
+		}
+	}
+	return 0, false
+}
+
+// AutoAggregationInterval 选择使时间范围内桶数不超过maxPoints的最细时间桶，范围过大时返回最粗的时间桶
+func AutoAggregationInterval(startTime, endTime int64, maxPoints int) string {
+	span := time.Duration(endTime-startTime) * time.Second
+	for _, item := range aggregationIntervals {
+		if int(span/item.duration) < maxPoints {
+			return item.name
+		}
+	}
+	return aggregationIntervals[len(aggregationIntervals)-1].name
+}
+
+// AggregatedPoint 时间桶聚合值，Time为桶起始时间
+type AggregatedPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Count int64     `json:"count"`
+}
+
+// AggregatedSeries 单设备单指标的聚合时间序列
+type AggregatedSeries struct {
+	DeviceID string            `json:"device_id"`
+	Metric   string            `json:"metric"`
+	Points   []AggregatedPoint `json:"points"`
+}
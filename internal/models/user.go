@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -41,6 +43,18 @@ func (Role) TableName() string {
 	return "roles"
 }
 
+// PermissionList 解析角色权限列表
+func (r *Role) PermissionList() []string {
+	if r.Permissions == nil || *r.Permissions == "" {
+		return nil
+	}
+	var permissions []string
+	if err := json.Unmarshal([]byte(*r.Permissions), &permissions); err != nil {
+		return nil
+	}
+	return permissions
+}
+
 // UserRole 用户角色关联模型
 type UserRole struct {
 	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -54,6 +68,20 @@ func (UserRole) TableName() string {
 	return "user_roles"
 }
 
+// RevokedToken 已注销令牌（Redis不可用时的持久化黑名单）
+type RevokedToken struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	TokenID   string    `json:"token_id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID    uint64    `json:"user_id" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
 // UserStatus 用户状态
 type UserStatus string
 
@@ -99,9 +127,21 @@ type UserLoginRequest struct {
 
 // UserLoginResponse 用户登录响应
 type UserLoginResponse struct {
-	Token     string    `json:"token"`
-	User      UserInfo  `json:"user"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	User             UserInfo  `json:"user"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// TokenRefreshRequest 刷新令牌请求
+type TokenRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// UserLogoutRequest 用户登出请求（可同时注销刷新令牌）
+type UserLogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 // UserInfo 用户信息
@@ -196,6 +236,43 @@ const (
 	PermissionAdmin = "*"
 )
 
+// AllPermissions 全部可分配的权限
+var AllPermissions = []string{
+	PermissionDeviceRead, PermissionDeviceWrite, PermissionDeviceDelete,
+	PermissionDataRead, PermissionDataWrite, PermissionDataExport,
+	PermissionAlertRead, PermissionAlertWrite, PermissionAlertAck, PermissionAlertResolve,
+	PermissionUserRead, PermissionUserWrite, PermissionUserDelete,
+	PermissionRoleRead, PermissionRoleWrite, PermissionRoleDelete,
+	PermissionSystemRead, PermissionSystemWrite, PermissionSystemConfig,
+}
+
+// IsValidPermission 验证权限字符串，支持"*"及"资源:*"通配
+func IsValidPermission(permission string) bool {
+	if permission == PermissionAdmin {
+		return true
+	}
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+		if resource, _, _ := strings.Cut(p, ":"); permission == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission 判断已授予的权限是否包含所需权限，支持"*"及"资源:*"通配
+func HasPermission(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, p := range granted {
+		if p == PermissionAdmin || p == required || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
 // DefaultRoles 默认角色
 var DefaultRoles = []Role{
 	{
@@ -206,7 +283,7 @@ var DefaultRoles = []Role{
 	{
 		Name:        "operator",
 		Description: &[]string{"操作员"}[0],
-		Permissions: stringPtr(`["device:read","device:write","data:read","alert:read","alert:write"]`),
+		Permissions: stringPtr(`["device:read","device:write","data:read","data:write","alert:read","alert:write","alert:ack","alert:resolve"]`),
 	},
 	{
 		Name:        "viewer",
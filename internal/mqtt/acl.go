@@ -0,0 +1,209 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+)
+
+// clientBinding 客户端与设备ID的绑定关系
+type clientBinding struct {
+	client   *mqtt.Client
+	deviceID string
+}
+
+// ACLEngine MQTT主题访问控制引擎
+//
+// 设备客户端认证通过后绑定到其设备ID，默认只允许发布到
+// air-quality/{type}/{own_id}/data 及TopicConfig中的状态、响应主题，
+// 订阅限制在 air-quality/+/{own_id}/# 下；网关与管理客户端的覆盖规则存储在数据库中。
+type ACLEngine struct {
+	aclService      services.MQTTACLService
+	topics          config.TopicConfig
+	serviceUsername string
+	logger          utils.Logger
+
+	mu       sync.RWMutex
+	bindings map[string]*clientBinding
+	allowed  atomic.Int64
+	denied   atomic.Int64
+}
+
+// NewACLEngine 创建ACL引擎
+func NewACLEngine(aclService services.MQTTACLService, topics config.TopicConfig, serviceUsername string, logger utils.Logger) *ACLEngine {
+	if topics.DeviceStatus == "" {
+		topics.DeviceStatus = defaultDeviceStatusTopic
+	}
+	if topics.DeviceResponse == "" {
+		topics.DeviceResponse = defaultDeviceResponseTopic
+	}
+	if topics.DeviceConfigReported == "" {
+		topics.DeviceConfigReported = defaultDeviceConfigReportedTopic
+	}
+	if topics.FirmwareProgress == "" {
+		topics.FirmwareProgress = defaultFirmwareProgressTopic
+	}
+	return &ACLEngine{
+		aclService:      aclService,
+		topics:          topics,
+		serviceUsername: serviceUsername,
+		logger:          logger,
+		bindings:        make(map[string]*clientBinding),
+	}
+}
+
+// Bind 将已认证的客户端绑定到设备ID
+func (e *ACLEngine) Bind(cl *mqtt.Client, deviceID string) {
+	e.mu.Lock()
+	e.bindings[cl.ID] = &clientBinding{client: cl, deviceID: deviceID}
+	e.mu.Unlock()
+}
+
+// Unbind 解除客户端绑定（仅当绑定仍属于该连接时）
+func (e *ACLEngine) Unbind(cl *mqtt.Client) {
+	e.mu.Lock()
+	if binding, ok := e.bindings[cl.ID]; ok && binding.client == cl {
+		delete(e.bindings, cl.ID)
+	}
+	e.mu.Unlock()
+}
+
+// DeviceID 获取客户端绑定的设备ID
+func (e *ACLEngine) DeviceID(cl *mqtt.Client) (string, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	binding, ok := e.bindings[cl.ID]
+	if !ok || binding.client != cl {
+		return "", false
+	}
+	return binding.deviceID, true
+}
+
+// Check 检查客户端对主题的发布(write=true)或订阅权限
+func (e *ACLEngine) Check(cl *mqtt.Client, topic string, write bool) bool {
+	// 内联客户端（服务端自身）不受限制
+	if cl.Net.Inline {
+		return true
+	}
+
+	deviceID, ok := e.DeviceID(cl)
+	if !ok {
+		return e.deny(cl, "", topic, write, "client_unbound")
+	}
+	if e.serviceUsername != "" && deviceID == e.serviceUsername {
+		return e.allow()
+	}
+
+	// 数据库覆盖规则优先
+	if e.aclService != nil {
+		rules, err := e.aclService.GetRulesForUsername(context.Background(), deviceID)
+		if err != nil {
+			e.logger.Error("❌ 加载ACL规则失败", utils.String("username", deviceID), utils.ErrorField(err))
+			return e.deny(cl, deviceID, topic, write, "rules_unavailable")
+		}
+		for _, rule := range rules {
+			if rule.Access.Covers(write) && topicMatchesFilter(rule.TopicFilter, topic) {
+				if rule.Allow {
+					return e.allow()
+				}
+				return e.deny(cl, deviceID, topic, write, "rule_denied")
+			}
+		}
+	}
+
+	if write && e.isOwnWriteTopic(deviceID, topic) {
+		return e.allow()
+	}
+	if !write && topicMatchesFilter("air-quality/+/"+deviceID+"/#", topic) {
+		return e.allow()
+	}
+	return e.deny(cl, deviceID, topic, write, "topic_not_permitted")
+}
+
+// Stats 获取ACL统计信息
+func (e *ACLEngine) Stats() map[string]interface{} {
+	e.mu.RLock()
+	bound := len(e.bindings)
+	e.mu.RUnlock()
+
+	return map[string]interface{}{
+		"bound_clients": bound,
+		"allowed":       e.allowed.Load(),
+		"denied":        e.denied.Load(),
+	}
+}
+
+// isOwnWriteTopic 判断主题是否属于设备自身可发布的主题
+func (e *ACLEngine) isOwnWriteTopic(deviceID, topic string) bool {
+	if isSensorDataTopic(topic) && deviceIDFromTopic(topic) == deviceID {
+		return true
+	}
+	for _, filter := range []string{e.topics.DeviceStatus, e.topics.DeviceResponse, e.topics.DeviceConfigReported, e.topics.FirmwareProgress} {
+		if filter != "" && topicMatchesFilter(filter, topic) && deviceIDFromTopic(topic) == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// allow 记录允许
+func (e *ACLEngine) allow() bool {
+	e.allowed.Add(1)
+	return true
+}
+
+// deny 记录拒绝
+func (e *ACLEngine) deny(cl *mqtt.Client, deviceID, topic string, write bool, reason string) bool {
+	e.denied.Add(1)
+	e.logger.Warn("🚫 MQTT ACL拒绝访问",
+		utils.String("client_id", cl.ID),
+		utils.String("device_id", deviceID),
+		utils.String("topic", topic),
+		utils.String("action", map[bool]string{true: "发布", false: "订阅"}[write]),
+		utils.String("reason", reason))
+	return false
+}
+
+// deviceIDFromTopic 从 air-quality/{type}/{device_id}/... 主题中提取设备ID
+func deviceIDFromTopic(topic string) string {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 4 || parts[0] != "air-quality" {
+		return ""
+	}
+	return parts[2]
+}
+
+// deviceTypeFromTopic 从 air-quality/{type}/{id}/... 主题中提取设备类型
+func deviceTypeFromTopic(topic string) models.DeviceType {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 4 || parts[0] != "air-quality" {
+		return ""
+	}
+	return models.DeviceType(parts[1])
+}
+
+// topicMatchesFilter 判断主题（或订阅过滤器）是否被过滤器覆盖
+func topicMatchesFilter(filter, topic string) bool {
+	filterParts := strings.Split(filter, "/")
+	topicParts := strings.Split(topic, "/")
+
+	for i, part := range filterParts {
+		if part == "#" {
+			return true
+		}
+		if i >= len(topicParts) {
+			return false
+		}
+		if part != "+" && part != topicParts[i] {
+			return false
+		}
+	}
+	return len(filterParts) == len(topicParts)
+}
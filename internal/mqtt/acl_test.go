@@ -0,0 +1,162 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTopicConfig 测试用主题配置
+var testTopicConfig = config.TopicConfig{
+	DeviceStatus:   "air-quality/hcho/+/status",
+	DeviceResponse: "air-quality/hcho/+/response",
+}
+
+// TestTopicMatchesFilter 测试主题过滤器匹配
+func TestTopicMatchesFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		topic    string
+		expected bool
+	}{
+		{"air-quality/+/hcho_001/data", "air-quality/hcho/hcho_001/data", true},
+		{"air-quality/+/hcho_001/data", "air-quality/hcho/hcho_002/data", false},
+		{"air-quality/#", "air-quality/hcho/hcho_001/data", true},
+		{"air-quality/#", "air-quality", true},
+		{"#", "any/topic", true},
+		{"air-quality/+/hcho_001/#", "air-quality/hcho/hcho_001/cmd", true},
+		{"air-quality/+/hcho_001/#", "air-quality/+/hcho_002/#", false},
+		{"air-quality/hcho/+/data", "air-quality/hcho/hcho_001", false},
+		{"air-quality/hcho", "air-quality/hcho/hcho_001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter+"|"+tt.topic, func(t *testing.T) {
+			assert.Equal(t, tt.expected, topicMatchesFilter(tt.filter, tt.topic))
+		})
+	}
+}
+
+// TestACLEngine_DevicePolicy 测试设备默认主题权限
+func TestACLEngine_DevicePolicy(t *testing.T) {
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	engine := NewACLEngine(nil, testTopicConfig, "server", logger)
+	cl := &mqtt.Client{ID: "client-1"}
+
+	// 未绑定的客户端一律拒绝
+	assert.False(t, engine.Check(cl, "air-quality/hcho/hcho_001/data", true))
+
+	engine.Bind(cl, TestDeviceID1)
+	assert.True(t, engine.Check(cl, "air-quality/hcho/hcho_001/data", true))
+	assert.True(t, engine.Check(cl, "air-quality/hcho/hcho_001/status", true))
+	assert.True(t, engine.Check(cl, "air-quality/hcho/hcho_001/response", true))
+	assert.False(t, engine.Check(cl, "air-quality/hcho/hcho_002/data", true))
+	assert.False(t, engine.Check(cl, "other/topic", true))
+	assert.True(t, engine.Check(cl, "air-quality/hcho/hcho_001/#", false))
+	assert.False(t, engine.Check(cl, "air-quality/#", false))
+
+	// 同ID的新连接接管后，旧连接断开不影响新绑定
+	newCl := &mqtt.Client{ID: "client-1"}
+	engine.Bind(newCl, TestDeviceID1)
+	engine.Unbind(cl)
+	assert.True(t, engine.Check(newCl, "air-quality/hcho/hcho_001/data", true))
+	assert.False(t, engine.Check(cl, "air-quality/hcho/hcho_001/data", true))
+
+	// 服务账号不受限制
+	svcCl := &mqtt.Client{ID: "svc"}
+	engine.Bind(svcCl, "server")
+	assert.True(t, engine.Check(svcCl, "air-quality/hcho/hcho_002/cmd", true))
+}
+
+// TestACLEngine_RuleOverrides 测试数据库覆盖规则
+func TestACLEngine_RuleOverrides(t *testing.T) {
+	db := setupTestDatabase(t)
+	require.NoError(t, db.AutoMigrate(&models.MQTTACLRule{}))
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	aclService := services.NewMQTTACLService(repositories.NewMQTTACLRuleRepository(db, logger), logger)
+	ctx := context.Background()
+
+	_, err = aclService.CreateRule(ctx, &models.MQTTACLRuleCreateRequest{
+		Username:    "gateway_01",
+		ClientType:  string(models.MQTTClientTypeGateway),
+		TopicFilter: "air-quality/hcho/+/data",
+		Access:      string(models.MQTTACLAccessWrite),
+	})
+	require.NoError(t, err)
+
+	engine := NewACLEngine(aclService, testTopicConfig, "", logger)
+	gw := &mqtt.Client{ID: "gw"}
+	engine.Bind(gw, "gateway_01")
+
+	assert.True(t, engine.Check(gw, "air-quality/hcho/hcho_001/data", true))
+	assert.True(t, engine.Check(gw, "air-quality/hcho/hcho_002/data", true))
+	assert.False(t, engine.Check(gw, "air-quality/pm25/pm25_001/data", true))
+
+	// 高优先级拒绝规则覆盖允许规则，并在创建后立即生效
+	deny := false
+	_, err = aclService.CreateRule(ctx, &models.MQTTACLRuleCreateRequest{
+		Username:    "gateway_01",
+		ClientType:  string(models.MQTTClientTypeGateway),
+		TopicFilter: "air-quality/hcho/hcho_002/#",
+		Access:      string(models.MQTTACLAccessReadWrite),
+		Allow:       &deny,
+		Priority:    10,
+	})
+	require.NoError(t, err)
+	assert.False(t, engine.Check(gw, "air-quality/hcho/hcho_002/data", true))
+	assert.True(t, engine.Check(gw, "air-quality/hcho/hcho_001/data", true))
+
+	// 非法过滤器
+	_, err = aclService.CreateRule(ctx, &models.MQTTACLRuleCreateRequest{
+		Username:    "admin_01",
+		ClientType:  string(models.MQTTClientTypeAdmin),
+		TopicFilter: "air-quality/#/data",
+		Access:      string(models.MQTTACLAccessRead),
+	})
+	assert.Error(t, err)
+}
+
+// TestSensorDataHandler_RejectsMismatchedDeviceID 测试载荷设备ID与主题不一致时拒绝
+func TestSensorDataHandler_RejectsMismatchedDeviceID(t *testing.T) {
+	db := setupTestDatabase(t)
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+	handler := NewSensorDataHandler(
+		dataRepo,
+		repositories.NewDeviceRepository(db, logger),
+		newTestAlertEvaluator(db, logger),
+		logger,
+	)
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"device_id":   TestDeviceID2,
+		"device_type": "hcho",
+		"timestamp":   time.Now().Unix(),
+		"data":        map[string]interface{}{"formaldehyde": 0.03},
+	})
+	require.NoError(t, err)
+
+	err = handler.HandleMessage("air-quality/hcho/"+TestDeviceID1+"/data", payload)
+	assert.Error(t, err)
+
+	data, err := dataRepo.GetHistoryByDeviceID(context.Background(), TestDeviceID2, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
@@ -0,0 +1,215 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// 认证拒绝原因
+const (
+	AuthRejectMissingUsername = "missing_username"
+	AuthRejectMissingPassword = "missing_password"
+	AuthRejectNotFound        = "credential_not_found"
+	AuthRejectRevoked         = "credential_revoked"
+	AuthRejectMismatch        = "secret_mismatch"
+	AuthRejectUnavailable     = "auth_unavailable"
+	AuthRejectError           = "auth_error"
+	AuthRejectWillDenied      = "will_topic_denied"
+)
+
+// authTimeout 单次认证查询超时时间
+const authTimeout = 5 * time.Second
+
+// AuthHook 基于数据库设备凭证的MQTT连接认证及主题访问控制钩子
+type AuthHook struct {
+	mqtt.HookBase
+	logger            utils.Logger
+	credentialService services.DeviceCredentialService
+	acl               *ACLEngine
+	serviceUsername   string
+	servicePassword   string
+
+	accepted atomic.Int64
+	rejected atomic.Int64
+	mu       sync.Mutex
+	reasons  map[string]int64
+}
+
+// ID 返回钩子ID
+func (h *AuthHook) ID() string {
+	return "device-auth"
+}
+
+// Provides 返回钩子提供的事件
+func (h *AuthHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnConnectAuthenticate,
+		mqtt.OnACLCheck,
+		mqtt.OnDisconnect,
+	}, []byte{b})
+}
+
+// Init 初始化钩子
+func (h *AuthHook) Init(config interface{}) error {
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("配置类型转换失败")
+	}
+
+	logger, ok := configMap["logger"].(utils.Logger)
+	if !ok {
+		return fmt.Errorf("未找到logger配置")
+	}
+	h.logger = logger
+
+	if svc, ok := configMap["credentialService"].(services.DeviceCredentialService); ok {
+		h.credentialService = svc
+	}
+	if acl, ok := configMap["aclEngine"].(*ACLEngine); ok {
+		h.acl = acl
+	}
+	if username, ok := configMap["serviceUsername"].(string); ok {
+		h.serviceUsername = username
+	}
+	if password, ok := configMap["servicePassword"].(string); ok {
+		h.servicePassword = password
+	}
+	h.reasons = make(map[string]int64)
+
+	h.logger.Info("🔧 MQTT设备认证钩子已初始化",
+		utils.String("hook_id", h.ID()),
+		utils.Bool("has_credential_service", h.credentialService != nil),
+		utils.Bool("has_acl_engine", h.acl != nil),
+		utils.Bool("has_service_account", h.serviceUsername != ""))
+	return nil
+}
+
+// OnConnectAuthenticate 校验CONNECT报文中的设备ID与密钥
+func (h *AuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
+	username := string(pk.Connect.Username)
+	password := string(pk.Connect.Password)
+
+	if username == "" {
+		return h.reject(cl, username, AuthRejectMissingUsername, nil)
+	}
+	if password == "" {
+		return h.reject(cl, username, AuthRejectMissingPassword, nil)
+	}
+
+	// 服务账号（来自MQTT配置）用于内部客户端和运维工具
+	if h.serviceUsername != "" && h.servicePassword != "" && username == h.serviceUsername {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(h.servicePassword)) != 1 {
+			return h.reject(cl, username, AuthRejectMismatch, nil)
+		}
+		return h.accept(cl, username, pk)
+	}
+
+	if h.credentialService == nil {
+		return h.reject(cl, username, AuthRejectUnavailable, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+
+	if err := h.credentialService.Authenticate(ctx, username, password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCredentialNotFound):
+			return h.reject(cl, username, AuthRejectNotFound, nil)
+		case errors.Is(err, services.ErrCredentialRevoked):
+			return h.reject(cl, username, AuthRejectRevoked, nil)
+		case errors.Is(err, services.ErrCredentialMismatch):
+			return h.reject(cl, username, AuthRejectMismatch, nil)
+		default:
+			return h.reject(cl, username, AuthRejectError, err)
+		}
+	}
+
+	return h.accept(cl, username, pk)
+}
+
+// OnACLCheck 主题访问控制检查（未配置ACL引擎时仅允许内联客户端）
+func (h *AuthHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
+	if h.acl == nil {
+		return cl.Net.Inline
+	}
+	return h.acl.Check(cl, topic, write)
+}
+
+// OnDisconnect 客户端断开时解除设备绑定
+func (h *AuthHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
+	if h.acl != nil {
+		h.acl.Unbind(cl)
+	}
+}
+
+// Stats 获取认证统计信息
+func (h *AuthHook) Stats() map[string]interface{} {
+	h.mu.Lock()
+	reasons := make(map[string]int64, len(h.reasons))
+	for reason, count := range h.reasons {
+		reasons[reason] = count
+	}
+	h.mu.Unlock()
+
+	return map[string]interface{}{
+		"accepted":       h.accepted.Load(),
+		"rejected":       h.rejected.Load(),
+		"reject_reasons": reasons,
+	}
+}
+
+// accept 绑定设备并校验遗嘱主题后记录认证通过
+func (h *AuthHook) accept(cl *mqtt.Client, username string, pk packets.Packet) bool {
+	if h.acl != nil {
+		h.acl.Bind(cl, username)
+		// 遗嘱消息由Broker直接投递，不经过发布ACL检查，需在连接时校验遗嘱主题
+		if pk.Connect.WillFlag && !h.acl.Check(cl, pk.Connect.WillTopic, true) {
+			h.acl.Unbind(cl)
+			return h.reject(cl, username, AuthRejectWillDenied, nil)
+		}
+	}
+	h.accepted.Add(1)
+	h.logger.Info("✅ 客户端认证通过",
+		utils.String("client_id", cl.ID),
+		utils.String("username", username),
+		utils.String("remote_addr", cl.Net.Remote))
+	return true
+}
+
+// reject 记录认证拒绝及原因
+func (h *AuthHook) reject(cl *mqtt.Client, username, reason string, err error) bool {
+	total := h.rejected.Add(1)
+	h.mu.Lock()
+	h.reasons[reason]++
+	h.mu.Unlock()
+
+	if err != nil {
+		h.logger.Error("🚫 客户端认证失败",
+			utils.String("client_id", cl.ID),
+			utils.String("username", username),
+			utils.String("remote_addr", cl.Net.Remote),
+			utils.String("reason", reason),
+			utils.Int64("rejected_total", total),
+			utils.ErrorField(err))
+		return false
+	}
+
+	h.logger.Warn("🚫 客户端认证被拒绝",
+		utils.String("client_id", cl.ID),
+		utils.String("username", username),
+		utils.String("remote_addr", cl.Net.Remote),
+		utils.String("reason", reason),
+		utils.Int64("rejected_total", total))
+	return false
+}
@@ -0,0 +1,119 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAuthHook 创建带设备凭证服务的认证钩子
+func setupAuthHook(t *testing.T) (*AuthHook, services.DeviceCredentialService) {
+	db := setupTestDatabase(t)
+	require.NoError(t, db.AutoMigrate(&models.DeviceCredential{}))
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	credentialService := services.NewDeviceCredentialService(
+		repositories.NewDeviceCredentialRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger),
+		logger,
+	)
+
+	hook := new(AuthHook)
+	require.NoError(t, hook.Init(map[string]interface{}{
+		"logger":            logger,
+		"credentialService": credentialService,
+		"serviceUsername":   "server",
+		"servicePassword":   "server-secret",
+	}))
+	return hook, credentialService
+}
+
+// connectPacket 构造CONNECT报文
+func connectPacket(username, password string) packets.Packet {
+	return packets.Packet{
+		Connect: packets.ConnectParams{
+			Username: []byte(username),
+			Password: []byte(password),
+		},
+	}
+}
+
+// TestAuthHook_DeviceCredential 测试设备凭证签发、轮换与吊销后的认证结果
+func TestAuthHook_DeviceCredential(t *testing.T) {
+	hook, credentialService := setupAuthHook(t)
+	ctx := context.Background()
+	cl := &mqtt.Client{ID: "client-1"}
+
+	// 未签发凭证
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, "anything")))
+
+	issued, err := credentialService.IssueCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.Equal(t, TestDeviceID1, issued.Username)
+	assert.NotEmpty(t, issued.Secret)
+
+	// 重复签发应失败
+	_, err = credentialService.IssueCredential(ctx, TestDeviceID1)
+	assert.Error(t, err)
+
+	assert.True(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, issued.Secret)))
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, "wrong")))
+
+	// 轮换后旧密钥失效
+	rotated, err := credentialService.RotateCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, issued.Secret)))
+	assert.True(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, rotated.Secret)))
+
+	// 吊销后拒绝连接
+	require.NoError(t, credentialService.RevokeCredential(ctx, TestDeviceID1))
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, rotated.Secret)))
+
+	stats := hook.Stats()
+	assert.Equal(t, int64(2), stats["accepted"])
+	assert.Equal(t, int64(4), stats["rejected"])
+	reasons := stats["reject_reasons"].(map[string]int64)
+	assert.Equal(t, int64(1), reasons[AuthRejectNotFound])
+	assert.Equal(t, int64(2), reasons[AuthRejectMismatch])
+	assert.Equal(t, int64(1), reasons[AuthRejectRevoked])
+}
+
+// TestAuthHook_RejectReasons 测试缺少凭据及服务账号认证
+func TestAuthHook_RejectReasons(t *testing.T) {
+	hook, _ := setupAuthHook(t)
+	cl := &mqtt.Client{ID: "client-2"}
+
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket("", "")))
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket(TestDeviceID1, "")))
+	assert.False(t, hook.OnConnectAuthenticate(cl, connectPacket("server", "bad")))
+	assert.True(t, hook.OnConnectAuthenticate(cl, connectPacket("server", "server-secret")))
+
+	reasons := hook.Stats()["reject_reasons"].(map[string]int64)
+	assert.Equal(t, int64(1), reasons[AuthRejectMissingUsername])
+	assert.Equal(t, int64(1), reasons[AuthRejectMissingPassword])
+	assert.Equal(t, int64(1), reasons[AuthRejectMismatch])
+}
+
+// TestAuthHook_NoCredentialService 测试未配置凭证服务时拒绝设备连接
+func TestAuthHook_NoCredentialService(t *testing.T) {
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	hook := new(AuthHook)
+	require.NoError(t, hook.Init(map[string]interface{}{"logger": logger}))
+
+	assert.False(t, hook.OnConnectAuthenticate(&mqtt.Client{ID: "client-3"}, connectPacket(TestDeviceID1, "secret")))
+	assert.True(t, hook.Provides(mqtt.OnConnectAuthenticate))
+	assert.False(t, hook.Provides(mqtt.OnPublish))
+}
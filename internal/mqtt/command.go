@@ -0,0 +1,46 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+// 设备指令响应主题参数
+const (
+	defaultDeviceResponseTopic   = "air-quality/+/+/response"
+	deviceResponseSubscriptionID = 2
+	deviceResponseTimeout        = 5 * time.Second
+)
+
+// DeviceResponseHandler 设备指令响应主题处理器
+// 服务端在 air-quality/{type}/{id}/cmd 下发指令，设备执行后在 air-quality/{type}/{id}/response 返回带相同command_id的结果
+type DeviceResponseHandler struct {
+	commandService services.DeviceCommandService
+	logger         utils.Logger
+}
+
+// NewDeviceResponseHandler 创建设备指令响应主题处理器
+func NewDeviceResponseHandler(commandService services.DeviceCommandService, logger utils.Logger) *DeviceResponseHandler {
+	return &DeviceResponseHandler{
+		commandService: commandService,
+		logger:         logger,
+	}
+}
+
+// HandleMessage 处理设备指令响应消息
+func (h *DeviceResponseHandler) HandleMessage(topic string, payload []byte) error {
+	deviceID := deviceIDFromTopic(topic)
+	if deviceID == "" {
+		return fmt.Errorf("无效的设备响应主题: %s", topic)
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deviceResponseTimeout)
+	defer cancel()
+	return h.commandService.HandleResponse(ctx, deviceID, payload)
+}
@@ -0,0 +1,46 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+// 设备配置上报主题参数
+const (
+	defaultDeviceConfigReportedTopic   = "air-quality/+/+/config/reported"
+	deviceConfigReportedSubscriptionID = 3
+	deviceConfigReportedTimeout        = 5 * time.Second
+)
+
+// DeviceConfigReportHandler 设备配置上报主题处理器
+// 服务端在 air-quality/{type}/{id}/config 发布保留的期望配置，设备应用后在 config/reported 上报实际配置
+type DeviceConfigReportHandler struct {
+	configService services.DeviceConfigService
+	logger        utils.Logger
+}
+
+// NewDeviceConfigReportHandler 创建设备配置上报主题处理器
+func NewDeviceConfigReportHandler(configService services.DeviceConfigService, logger utils.Logger) *DeviceConfigReportHandler {
+	return &DeviceConfigReportHandler{
+		configService: configService,
+		logger:        logger,
+	}
+}
+
+// HandleMessage 处理设备配置上报消息
+func (h *DeviceConfigReportHandler) HandleMessage(topic string, payload []byte) error {
+	deviceID := deviceIDFromTopic(topic)
+	if deviceID == "" {
+		return fmt.Errorf("无效的设备配置上报主题: %s", topic)
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deviceConfigReportedTimeout)
+	defer cancel()
+	return h.configService.HandleReported(ctx, deviceID, payload)
+}
@@ -0,0 +1,69 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeviceConfig_RetainedAndReported 测试期望配置以保留消息下发给稍后订阅的设备，设备在config/reported上报后更新同步状态
+func TestDeviceConfig_RetainedAndReported(t *testing.T) {
+	db := setupTestDatabase(t)
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	credentialService := services.NewDeviceCredentialService(repositories.NewDeviceCredentialRepository(db, logger), deviceRepo, logger)
+	issued, err := credentialService.IssueCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	configService := services.NewDeviceConfigService(repositories.NewDeviceConfigStateRepository(db, logger), deviceRepo, logger)
+
+	cfg := &config.MQTTConfig{Broker: "tcp://127.0.0.1:0", Username: "server", Password: "server-secret"}
+	server := NewServer(cfg, logger, NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger))
+	server.SetCredentialService(credentialService)
+	server.SetConfigService(configService)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+	configService.SetPublisher(server)
+
+	desired := `{"report_interval":60,"thresholds":{"formaldehyde_warning":0.08}}`
+	require.NoError(t, configService.SetDesired(ctx, TestDeviceID1, desired))
+
+	// 设备稍后订阅仍能收到保留的期望配置
+	retained := make(chan models.DeviceConfigMessage, 1)
+	require.NoError(t, server.server.Subscribe("air-quality/hcho/"+TestDeviceID1+"/config", 97, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		var msg models.DeviceConfigMessage
+		if json.Unmarshal(pk.Payload, &msg) == nil {
+			retained <- msg
+		}
+	}))
+	select {
+	case msg := <-retained:
+		assert.Equal(t, int64(1), msg.Version)
+		assert.Equal(t, float64(60), msg.Config["report_interval"])
+	case <-time.After(time.Second):
+		t.Fatal("未收到保留的期望配置")
+	}
+
+	// 设备在上报主题报告已应用的配置
+	dc := dialDevice(t, server.server, TestDeviceID1, issued.Secret, "", nil)
+	defer dc.conn.Close()
+	dc.publish(t, "air-quality/hcho/"+TestDeviceID1+"/config/reported", []byte(`{"version":1,"config":`+desired+`}`), false)
+	require.Eventually(t, func() bool {
+		status, err := configService.GetSyncStatus(ctx, TestDeviceID1)
+		require.NoError(t, err)
+		return status.ReportedVersion == 1 && status.InSync
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,155 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/handlers"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeviceCommand_SendAndRespond 测试指令下发、按关联ID接收响应、超时及离线设备拒绝
+func TestDeviceCommand_SendAndRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDatabase(t)
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID2, Name: "离线设备", Type: models.DeviceTypeFormaldehyde, Status: models.DeviceStatusOffline}).Error)
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	credentialService := services.NewDeviceCredentialService(repositories.NewDeviceCredentialRepository(db, logger), deviceRepo, logger)
+	issued, err := credentialService.IssueCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+
+	presence := services.NewDevicePresenceService(config.DeviceConfig{}, deviceRepo,
+		repositories.NewDeviceRuntimeStatusRepository(db, logger), nil, logger)
+	commandService := services.NewDeviceCommandService(repositories.NewDeviceCommandRepository(db, logger), deviceRepo, logger)
+	defer commandService.Stop()
+
+	router := gin.New()
+	handler := handlers.NewDeviceCommandHandler(commandService, logger)
+	router.POST("/devices/:id/commands", handler.SendCommand)
+	router.GET("/devices/:id/commands", handler.ListCommands)
+	router.GET("/devices/:id/commands/:command_id", handler.GetCommand)
+	send := func(deviceID, query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/devices/"+deviceID+"/commands"+query, bytes.NewBufferString(body)))
+		return w
+	}
+
+	// MQTT服务器未启动时指令通道不可用
+	assert.Equal(t, http.StatusServiceUnavailable, send(TestDeviceID1, "", `{"command":"reboot"}`).Code)
+
+	cfg := &config.MQTTConfig{Broker: "tcp://127.0.0.1:0", Username: "server", Password: "server-secret"}
+	server := NewServer(cfg, logger, NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger))
+	server.SetCredentialService(credentialService)
+	server.SetPresenceService(presence)
+	server.SetCommandService(commandService)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+	commandService.SetPublisher(server)
+
+	commands := make(chan models.DeviceCommandMessage, 4)
+	require.NoError(t, server.server.Subscribe("air-quality/hcho/"+TestDeviceID1+"/cmd", 98, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		var msg models.DeviceCommandMessage
+		if json.Unmarshal(pk.Payload, &msg) == nil {
+			commands <- msg
+		}
+	}))
+
+	dc := dialDevice(t, server.server, TestDeviceID1, issued.Secret, "", nil)
+	defer dc.conn.Close()
+	require.Eventually(t, func() bool {
+		device, err := deviceRepo.GetByDeviceID(ctx, TestDeviceID1)
+		return err == nil && device.Status == models.DeviceStatusOnline
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// 参数校验与离线设备
+	assert.Equal(t, http.StatusBadRequest, send(TestDeviceID1, "", `{"command":"set_report_interval","params":{"interval":0}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(TestDeviceID1, "", `{"command":"self_destruct"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(TestDeviceID2, "", `{"command":"reboot"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("unknown-device", "", `{"command":"reboot"}`).Code)
+
+	// 同步等待：设备收到指令后带相同command_id回复
+	result := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		result <- send(TestDeviceID1, "?wait=true", `{"command":"set_report_interval","params":{"interval":60}}`)
+	}()
+	var msg models.DeviceCommandMessage
+	select {
+	case msg = <-commands:
+	case <-time.After(5 * time.Second):
+		t.Fatal("设备未收到指令")
+	}
+	assert.Equal(t, models.DeviceCommandSetReportInterval, msg.Command)
+	assert.Equal(t, float64(60), msg.Params["interval"])
+	dc.publish(t, "air-quality/hcho/"+TestDeviceID1+"/response",
+		[]byte(`{"command_id":"`+msg.CommandID+`","status":"acked","result":{"interval":60}}`), false)
+
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待指令响应超时")
+	}
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var sent struct {
+		Data models.DeviceCommand `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sent))
+	assert.Equal(t, msg.CommandID, sent.Data.CommandID)
+	assert.Equal(t, models.DeviceCommandStatusAcked, sent.Data.Status)
+	require.NotNil(t, sent.Data.Result)
+	assert.JSONEq(t, `{"interval":60}`, *sent.Data.Result)
+	assert.NotNil(t, sent.Data.RespondedAt)
+
+	// 未响应的指令超时，之后到达的响应不再改变状态
+	command, err := commandService.SendCommand(ctx, TestDeviceID1, &models.DeviceCommandRequest{Command: models.DeviceCommandReadNow, Timeout: 1}, nil)
+	require.NoError(t, err)
+	command, err = commandService.WaitForCompletion(ctx, command.CommandID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeviceCommandStatusTimedOut, command.Status)
+
+	dc.publish(t, "air-quality/hcho/"+TestDeviceID1+"/response", []byte(`{"command_id":"`+command.CommandID+`","status":"acked"}`), false)
+	time.Sleep(100 * time.Millisecond)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices/"+TestDeviceID1+"/commands/"+command.CommandID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sent))
+	assert.Equal(t, models.DeviceCommandStatusTimedOut, sent.Data.Status)
+
+	// 指令不属于该设备时视为不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices/"+TestDeviceID2+"/commands/"+command.CommandID, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 指令历史按状态过滤
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices/"+TestDeviceID1+"/commands?status=acked", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Data models.DeviceCommandListResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(1), list.Data.Total)
+	require.Len(t, list.Data.Commands, 1)
+	assert.Equal(t, msg.CommandID, list.Data.Commands[0].CommandID)
+}
@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDevicePresence_ConnectDataSweep 测试设备上下线、数据刷新及离线巡检告警
+func TestDevicePresence_ConnectDataSweep(t *testing.T) {
+	db := setupTestDatabase(t)
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	alertService := services.NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger)
+	presence := services.NewDevicePresenceService(
+		config.DeviceConfig{OfflineTimeout: 60},
+		deviceRepo,
+		repositories.NewDeviceRuntimeStatusRepository(db, logger),
+		alertService,
+		logger,
+	)
+
+	dataHandler := NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger)
+	dataHandler.SetPresenceService(presence)
+	hook := new(MessageHandlerHook)
+	require.NoError(t, hook.Init(map[string]interface{}{
+		"logger":            logger,
+		"sensorDataHandler": dataHandler,
+		"presenceService":   presence,
+		"serviceUsername":   "server",
+	}))
+
+	deviceStatus := func() models.DeviceStatus {
+		device, err := deviceRepo.GetByDeviceID(ctx, TestDeviceID1)
+		require.NoError(t, err)
+		return device.Status
+	}
+	offlineAlerts := func() []models.Alert {
+		alerts, err := alertService.GetOpenDeviceAlerts(ctx, TestDeviceID1, models.AlertMetricDeviceOffline)
+		require.NoError(t, err)
+		return alerts
+	}
+
+	// 服务账号不跟踪在线状态
+	service := &mqtt.Client{ID: "server-client"}
+	service.Properties.Username = []byte("server")
+	hook.OnSessionEstablished(service, packets.Packet{})
+	status, err := presence.GetRuntimeStatus(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.False(t, status.Online)
+	assert.Equal(t, models.DeviceStatusOffline, deviceStatus())
+
+	cl := &mqtt.Client{ID: "device-client"}
+	cl.Properties.Username = []byte(TestDeviceID1)
+	hook.OnSessionEstablished(cl, packets.Packet{})
+	assert.Equal(t, models.DeviceStatusOnline, deviceStatus())
+
+	// 上报数据刷新最后数据时间和电量
+	battery := 80
+	formaldehyde := 0.03
+	readAt := time.Now().Add(-10 * time.Second).Truncate(time.Second)
+	require.NoError(t, dataHandler.HandleBatch(ctx, []models.UnifiedSensorData{{
+		DeviceID: TestDeviceID1, DeviceType: models.DeviceTypeFormaldehyde,
+		Timestamp: readAt, Formaldehyde: &formaldehyde, Battery: &battery, DataQuality: "good",
+	}}))
+	status, err = presence.GetRuntimeStatus(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.True(t, status.Online)
+	require.NotNil(t, status.LastDataTime)
+	assert.True(t, readAt.Equal(*status.LastDataTime))
+	require.NotNil(t, status.BatteryLevel)
+	assert.Equal(t, 80, *status.BatteryLevel)
+
+	// 断开后运行状态离线，设备状态等待巡检超时
+	hook.OnDisconnect(cl, nil, true)
+	status, err = presence.GetRuntimeStatus(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.False(t, status.Online)
+	assert.Equal(t, models.DeviceStatusOnline, deviceStatus())
+
+	count, err := presence.SweepOffline(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	require.NoError(t, db.Model(&models.DeviceRuntimeStatus{}).
+		Where("device_id = ?", TestDeviceID1).
+		UpdateColumn("last_heartbeat", time.Now().Add(-2*time.Minute)).Error)
+	count, err = presence.SweepOffline(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, models.DeviceStatusOffline, deviceStatus())
+	alerts := offlineAlerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, uint64(0), alerts[0].RuleID)
+	assert.InDelta(t, 60, alerts[0].ThresholdValue, 0.001)
+
+	// 重复巡检不产生重复告警
+	count, err = presence.SweepOffline(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Len(t, offlineAlerts(), 1)
+
+	// 重新上线后自动解决离线告警
+	reconnected := &mqtt.Client{ID: "device-client"}
+	reconnected.Properties.Username = []byte(TestDeviceID1)
+	hook.OnSessionEstablished(reconnected, packets.Packet{})
+	assert.Equal(t, models.DeviceStatusOnline, deviceStatus())
+	assert.Empty(t, offlineAlerts())
+	history, err := alertService.GetAlertHistory(ctx, alerts[0].ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.AlertActionAutoResolved, history[1].Action)
+}
+
+// TestDevicePresence_PacketRefreshesHeartbeat 测试已连接但空闲的设备发送PINGREQ等报文时刷新心跳，不被巡检判定离线
+func TestDevicePresence_PacketRefreshesHeartbeat(t *testing.T) {
+	db := setupTestDatabase(t)
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备",
+		Type: models.DeviceTypeFormaldehyde, Status: models.DeviceStatusOnline}).Error)
+	require.NoError(t, db.Create(&models.DeviceRuntimeStatus{DeviceID: TestDeviceID1, Online: true,
+		LastHeartbeat: time.Now().Add(-2 * time.Minute)}).Error)
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	presence := services.NewDevicePresenceService(config.DeviceConfig{OfflineTimeout: 60},
+		deviceRepo, repositories.NewDeviceRuntimeStatusRepository(db, logger), nil, logger)
+	hook := new(MessageHandlerHook)
+	require.NoError(t, hook.Init(map[string]interface{}{
+		"logger":            logger,
+		"sensorDataHandler": NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger),
+		"presenceService":   presence,
+		"serviceUsername":   "server",
+	}))
+	assert.True(t, hook.Provides(mqtt.OnPacketRead))
+
+	heartbeat := func() time.Time {
+		status, err := presence.GetRuntimeStatus(ctx, TestDeviceID1)
+		require.NoError(t, err)
+		return status.LastHeartbeat
+	}
+	packet := func(cl *mqtt.Client, packetType byte) {
+		pk := packets.Packet{FixedHeader: packets.FixedHeader{Type: packetType}}
+		out, err := hook.OnPacketRead(cl, pk)
+		require.NoError(t, err)
+		assert.Equal(t, pk, out)
+	}
+
+	// 认证前的CONNECT报文和服务账号的报文不刷新心跳
+	cl := &mqtt.Client{ID: "device-client"}
+	cl.Properties.Username = []byte(TestDeviceID1)
+	packet(cl, packets.Connect)
+	service := &mqtt.Client{ID: "server-client"}
+	service.Properties.Username = []byte("server")
+	packet(service, packets.Pingreq)
+	assert.True(t, time.Since(heartbeat()) > time.Minute)
+
+	packet(cl, packets.Pingreq)
+	assert.WithinDuration(t, time.Now(), heartbeat(), 5*time.Second)
+	count, err := presence.SweepOffline(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// 刷新间隔内的后续报文不重复写入
+	require.NoError(t, db.Model(&models.DeviceRuntimeStatus{}).
+		Where("device_id = ?", TestDeviceID1).
+		UpdateColumn("last_heartbeat", time.Now().Add(-30*time.Second)).Error)
+	packet(cl, packets.Subscribe)
+	assert.True(t, time.Since(heartbeat()) >= 30*time.Second)
+}
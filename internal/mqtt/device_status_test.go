@@ -0,0 +1,165 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deviceConn 通过内存管道接入Broker的模拟设备连接
+type deviceConn struct {
+	conn   net.Conn
+	result chan error
+}
+
+// dialDevice 建立设备连接并发送CONNECT报文，遗嘱主题为空时不设置遗嘱
+func dialDevice(t *testing.T, server *mqtt.Server, username, password, willTopic string, willPayload []byte) *deviceConn {
+	serverConn, clientConn := net.Pipe()
+	dc := &deviceConn{conn: clientConn, result: make(chan error, 1)}
+	go func() {
+		dc.result <- server.EstablishConnection("tcp1", serverConn)
+	}()
+	// 丢弃Broker下发的报文，避免管道阻塞
+	go func() {
+		_, _ = io.Copy(io.Discard, clientConn)
+	}()
+
+	pk := packets.Packet{
+		FixedHeader:     packets.FixedHeader{Type: packets.Connect},
+		ProtocolVersion: 4,
+		Connect: packets.ConnectParams{
+			ProtocolName:     []byte("MQTT"),
+			ClientIdentifier: "client-" + username,
+			Clean:            true,
+			Keepalive:        60,
+			UsernameFlag:     true,
+			Username:         []byte(username),
+			PasswordFlag:     true,
+			Password:         []byte(password),
+		},
+	}
+	if willTopic != "" {
+		pk.Connect.WillFlag = true
+		pk.Connect.WillRetain = true
+		pk.Connect.WillTopic = willTopic
+		pk.Connect.WillPayload = willPayload
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, pk.ConnectEncode(buf))
+	_, err := clientConn.Write(buf.Bytes())
+	require.NoError(t, err)
+	return dc
+}
+
+// publish 以QoS0发布消息
+func (dc *deviceConn) publish(t *testing.T, topic string, payload []byte, retain bool) {
+	pk := packets.Packet{
+		FixedHeader:     packets.FixedHeader{Type: packets.Publish, Retain: retain},
+		ProtocolVersion: 4,
+		TopicName:       topic,
+		Payload:         payload,
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, pk.PublishEncode(buf))
+	_, err := dc.conn.Write(buf.Bytes())
+	require.NoError(t, err)
+}
+
+// TestDeviceStatus_RetainedStatusAndLWT 测试设备状态保留消息与遗嘱消息驱动设备状态
+func TestDeviceStatus_RetainedStatusAndLWT(t *testing.T) {
+	db := setupTestDatabase(t)
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	credentialService := services.NewDeviceCredentialService(repositories.NewDeviceCredentialRepository(db, logger), deviceRepo, logger)
+	issued, err := credentialService.IssueCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+
+	alertService := services.NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger)
+	presence := services.NewDevicePresenceService(config.DeviceConfig{}, deviceRepo,
+		repositories.NewDeviceRuntimeStatusRepository(db, logger), alertService, logger)
+
+	cfg := &config.MQTTConfig{Broker: "tcp://127.0.0.1:0", Username: "server", Password: "server-secret"}
+	server := NewServer(cfg, logger, NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger))
+	server.SetCredentialService(credentialService)
+	server.SetPresenceService(presence)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+
+	statusTopic := "air-quality/hcho/" + TestDeviceID1 + "/status"
+	deviceStatus := func() models.DeviceStatus {
+		device, err := deviceRepo.GetByDeviceID(ctx, TestDeviceID1)
+		require.NoError(t, err)
+		return device.Status
+	}
+
+	// 遗嘱主题指向其他设备时拒绝连接
+	denied := dialDevice(t, server.server, TestDeviceID1, issued.Secret, "air-quality/hcho/"+TestDeviceID2+"/status", []byte(`{"status":"offline"}`))
+	select {
+	case err := <-denied.result:
+		assert.ErrorIs(t, err, packets.ErrBadUsernameOrPassword)
+	case <-time.After(5 * time.Second):
+		t.Fatal("遗嘱主题越权的连接未被拒绝")
+	}
+	_ = denied.conn.Close()
+	assert.Equal(t, int64(1), server.authHook.Stats()["reject_reasons"].(map[string]int64)[AuthRejectWillDenied])
+
+	dc := dialDevice(t, server.server, TestDeviceID1, issued.Secret, statusTopic,
+		[]byte(`{"device_id":"`+TestDeviceID1+`","status":"offline"}`))
+	require.Eventually(t, func() bool { return deviceStatus() == models.DeviceStatusOnline }, 5*time.Second, 20*time.Millisecond)
+
+	// 设备发布保留的online消息，携带固件版本和运行时长
+	dc.publish(t, statusTopic, []byte(`{"device_id":"`+TestDeviceID1+`","status":"online","firmware_version":"1.2.3","uptime":3600}`), true)
+	require.Eventually(t, func() bool {
+		status, err := presence.GetRuntimeStatus(ctx, TestDeviceID1)
+		return err == nil && status.FirmwareVersion == "1.2.3"
+	}, 5*time.Second, 20*time.Millisecond)
+	status, err := presence.GetRuntimeStatus(ctx, TestDeviceID1)
+	require.NoError(t, err)
+	assert.True(t, status.Online)
+	require.NotNil(t, status.UptimeSeconds)
+	assert.Equal(t, int64(3600), *status.UptimeSeconds)
+
+	// 异常断线后Broker发布遗嘱，设备立即离线并产生离线告警
+	require.NoError(t, dc.conn.Close())
+	require.Eventually(t, func() bool { return deviceStatus() == models.DeviceStatusOffline }, 5*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		status, err := presence.GetRuntimeStatus(ctx, TestDeviceID1)
+		return err == nil && !status.Online
+	}, 5*time.Second, 20*time.Millisecond)
+	alerts, err := alertService.GetOpenDeviceAlerts(ctx, TestDeviceID1, models.AlertMetricDeviceOffline)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+
+	// 状态主题保留的是遗嘱offline消息
+	retained := make(chan []byte, 1)
+	require.NoError(t, server.server.Subscribe(statusTopic, 99, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		retained <- pk.Payload
+	}))
+	select {
+	case payload := <-retained:
+		var report models.DeviceStatusReport
+		require.NoError(t, json.Unmarshal(payload, &report))
+		assert.Equal(t, models.DeviceStatusOffline, report.Status)
+	case <-time.After(time.Second):
+		t.Fatal("未收到保留的状态消息")
+	}
+}
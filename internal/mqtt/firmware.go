@@ -0,0 +1,46 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+// 固件升级进度主题参数
+const (
+	defaultFirmwareProgressTopic   = "air-quality/+/+/ota/progress"
+	firmwareProgressSubscriptionID = 4
+	firmwareProgressTimeout        = 10 * time.Second
+)
+
+// FirmwareProgressHandler 固件升级进度主题处理器
+// 服务端在 air-quality/{type}/{id}/ota 发布保留的升级通知，设备下载安装过程中在 ota/progress 上报进度
+type FirmwareProgressHandler struct {
+	firmwareService services.FirmwareCampaignService
+	logger          utils.Logger
+}
+
+// NewFirmwareProgressHandler 创建固件升级进度主题处理器
+func NewFirmwareProgressHandler(firmwareService services.FirmwareCampaignService, logger utils.Logger) *FirmwareProgressHandler {
+	return &FirmwareProgressHandler{
+		firmwareService: firmwareService,
+		logger:          logger,
+	}
+}
+
+// HandleMessage 处理固件升级进度消息
+func (h *FirmwareProgressHandler) HandleMessage(topic string, payload []byte) error {
+	deviceID := deviceIDFromTopic(topic)
+	if deviceID == "" {
+		return fmt.Errorf("无效的固件升级进度主题: %s", topic)
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), firmwareProgressTimeout)
+	defer cancel()
+	return h.firmwareService.HandleProgress(ctx, deviceID, payload)
+}
@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFirmwareProgress_OverMQTT 测试升级通知经ota主题推送，设备在ota/progress上报升级成功后记录固件版本
+func TestFirmwareProgress_OverMQTT(t *testing.T) {
+	db := setupTestDatabase(t)
+	require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	runtimeRepo := repositories.NewDeviceRuntimeStatusRepository(db, logger)
+	credentialService := services.NewDeviceCredentialService(repositories.NewDeviceCredentialRepository(db, logger), deviceRepo, logger)
+	issued, err := credentialService.IssueCredential(ctx, TestDeviceID1)
+	require.NoError(t, err)
+
+	firmwareCfg := config.FirmwareConfig{Dir: t.TempDir(), URLSecret: "test-secret", UpdateTimeout: 3600}
+	firmwareService := services.NewFirmwareService(firmwareCfg, repositories.NewFirmwareRepository(db, logger), logger)
+	campaignService := services.NewFirmwareCampaignService(firmwareCfg,
+		repositories.NewFirmwareCampaignRepository(db, logger), repositories.NewFirmwareUpdateRepository(db, logger),
+		deviceRepo, runtimeRepo, firmwareService, logger)
+	firmware, err := firmwareService.UploadFirmware(ctx, &models.FirmwareUploadRequest{DeviceType: models.DeviceTypeFormaldehyde, Version: "1.4.0"},
+		"hcho.bin", strings.NewReader("firmware-binary-1.4.0"), nil)
+	require.NoError(t, err)
+	campaign, err := campaignService.CreateCampaign(ctx, &models.FirmwareCampaignRequest{Name: "hcho 1.4.0", FirmwareID: firmware.ID}, nil)
+	require.NoError(t, err)
+
+	cfg := &config.MQTTConfig{Broker: "tcp://127.0.0.1:0", Username: "server", Password: "server-secret"}
+	server := NewServer(cfg, logger, NewSensorDataHandler(repositories.NewUnifiedSensorDataRepository(db, logger), deviceRepo, nil, logger))
+	server.SetCredentialService(credentialService)
+	server.SetFirmwareService(campaignService)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+	campaignService.SetPublisher(server)
+
+	notifications := make(chan models.FirmwareUpdateMessage, 1)
+	require.NoError(t, server.server.Subscribe("air-quality/hcho/+/ota", 97, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		var msg models.FirmwareUpdateMessage
+		if len(pk.Payload) > 0 && json.Unmarshal(pk.Payload, &msg) == nil {
+			notifications <- msg
+		}
+	}))
+	_, err = campaignService.StartCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	select {
+	case msg := <-notifications:
+		assert.Equal(t, campaign.ID, msg.CampaignID)
+		assert.Equal(t, firmware.SHA256, msg.SHA256)
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到升级通知")
+	}
+
+	dc := dialDevice(t, server.server, TestDeviceID1, issued.Secret, "", nil)
+	defer dc.conn.Close()
+	dc.publish(t, "air-quality/hcho/"+TestDeviceID1+"/ota/progress",
+		[]byte(`{"campaign_id":`+strconv.FormatUint(campaign.ID, 10)+`,"status":"succeeded","version":"1.4.0"}`), false)
+	require.Eventually(t, func() bool {
+		status, err := runtimeRepo.GetByDeviceID(ctx, TestDeviceID1)
+		return err == nil && status != nil && status.FirmwareVersion == "1.4.0"
+	}, 5*time.Second, 20*time.Millisecond)
+}
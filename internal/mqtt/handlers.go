@@ -15,7 +15,11 @@ import (
 type SensorDataHandler struct {
 	dataRepo   repositories.UnifiedSensorDataRepository
 	deviceRepo repositories.DeviceRepository
-	alertSvc   services.AlertService
+	evaluator  services.AlertEvaluator
+	spool      *utils.SensorDataSpool
+	presence   services.DevicePresenceService
+	shadow     services.DeviceShadowService
+	provision  services.DeviceProvisioningService
 	logger     utils.Logger
 }
 
@@ -23,37 +27,194 @@ type SensorDataHandler struct {
 func NewSensorDataHandler(
 	dataRepo repositories.UnifiedSensorDataRepository,
 	deviceRepo repositories.DeviceRepository,
-	alertSvc services.AlertService,
+	evaluator services.AlertEvaluator,
 	logger utils.Logger,
 ) *SensorDataHandler {
 	return &SensorDataHandler{
 		dataRepo:   dataRepo,
 		deviceRepo: deviceRepo,
-		alertSvc:   alertSvc,
+		evaluator:  evaluator,
 		logger:     logger,
 	}
 }
 
+// SetSpool 设置落盘缓冲，数据库写入失败时暂存数据
+func (h *SensorDataHandler) SetSpool(spool *utils.SensorDataSpool) {
+	h.spool = spool
+}
+
+// SetPresenceService 设置设备在线状态服务，入库成功后刷新设备运行状态
+func (h *SensorDataHandler) SetPresenceService(presence services.DevicePresenceService) {
+	h.presence = presence
+}
+
+// SetShadowService 设置设备影子服务，入库成功后更新各指标最近取值
+func (h *SensorDataHandler) SetShadowService(shadow services.DeviceShadowService) {
+	h.shadow = shadow
+}
+
+// SetProvisioningService 设置设备接入策略服务，未设置时接收任意设备ID的数据
+func (h *SensorDataHandler) SetProvisioningService(provision services.DeviceProvisioningService) {
+	h.provision = provision
+}
+
 // HandleMessage 处理传感器数据消息
 func (h *SensorDataHandler) HandleMessage(topic string, payload []byte) error {
+	sensorData, err := h.parseMessage(topic, payload)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	admitted, err := h.admit(ctx, []models.UnifiedSensorData{*sensorData})
+	if err != nil {
+		if h.spoolData([]models.UnifiedSensorData{*sensorData}) {
+			return nil
+		}
+		return err
+	}
+	if len(admitted) == 0 {
+		return nil
+	}
+
+	// 保存数据
+	if err := h.dataRepo.Create(ctx, sensorData); err != nil {
+		h.logger.Error("保存传感器数据失败",
+			utils.String("device_id", sensorData.DeviceID),
+			utils.ErrorField(err))
+		if h.spoolData([]models.UnifiedSensorData{*sensorData}) {
+			return nil
+		}
+		return err
+	}
+	h.recordPresence(ctx, []models.UnifiedSensorData{*sensorData})
+
+	// 检查告警
+	if err := h.checkAlerts(ctx, sensorData); err != nil {
+		h.logger.Error("检查告警失败",
+			utils.String("device_id", sensorData.DeviceID),
+			utils.ErrorField(err))
+	}
+
+	h.logger.Info("处理传感器数据成功",
+		utils.String("device_id", sensorData.DeviceID),
+		utils.String("device_type", string(sensorData.DeviceType)),
+		utils.String("sensor_id", sensorData.SensorID),
+		utils.String("sensor_type", sensorData.SensorType),
+		utils.Float64("formaldehyde", getFloatValue(sensorData.Formaldehyde)))
+
+	return nil
+}
+
+// HandleBatch 批量保存已解析的传感器数据并检查告警
+func (h *SensorDataHandler) HandleBatch(ctx context.Context, batch []models.UnifiedSensorData) error {
+	batch, err := h.admit(ctx, batch)
+	if err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := h.dataRepo.BatchInsert(ctx, batch); err != nil {
+		h.logger.Error("批量保存传感器数据失败",
+			utils.Int("batch_size", len(batch)),
+			utils.ErrorField(err))
+		return err
+	}
+	h.recordPresence(ctx, batch)
+
+	for i := range batch {
+		if err := h.checkAlerts(ctx, &batch[i]); err != nil {
+			h.logger.Error("检查告警失败",
+				utils.String("device_id", batch[i].DeviceID),
+				utils.ErrorField(err))
+		}
+	}
+	return nil
+}
+
+// admit 按接入策略过滤未注册设备的数据，返回可入库的数据
+func (h *SensorDataHandler) admit(ctx context.Context, batch []models.UnifiedSensorData) ([]models.UnifiedSensorData, error) {
+	if h.provision == nil {
+		return batch, nil
+	}
+	result, err := h.provision.Admit(ctx, batch)
+	if err != nil {
+		h.logger.Error("检查设备接入策略失败",
+			utils.Int("batch_size", len(batch)),
+			utils.ErrorField(err))
+		return nil, err
+	}
+	return result.Admitted, nil
+}
+
+// recordPresence 刷新上报设备的运行状态和设备影子
+func (h *SensorDataHandler) recordPresence(ctx context.Context, batch []models.UnifiedSensorData) {
+	if h.presence != nil {
+		if err := h.presence.RecordReadings(ctx, batch); err != nil {
+			h.logger.Error("更新设备运行状态失败",
+				utils.Int("batch_size", len(batch)),
+				utils.ErrorField(err))
+		}
+	}
+	if h.shadow != nil {
+		if err := h.shadow.ApplyReadings(ctx, batch); err != nil {
+			h.logger.Error("更新设备影子失败",
+				utils.Int("batch_size", len(batch)),
+				utils.ErrorField(err))
+		}
+	}
+}
+
+// spoolData 将写库失败的数据写入落盘缓冲，返回是否已安全暂存
+func (h *SensorDataHandler) spoolData(batch []models.UnifiedSensorData) bool {
+	if h.spool == nil {
+		return false
+	}
+	if err := h.spool.Append(batch); err != nil {
+		h.logger.Error("❌ 写入落盘缓冲失败，数据丢失",
+			utils.Int("batch_size", len(batch)),
+			utils.ErrorField(err))
+		return false
+	}
+	h.logger.Warn("💾 数据库写入失败，数据已写入落盘缓冲",
+		utils.Int("batch_size", len(batch)),
+		utils.Int64("spool_depth", h.spool.Depth()))
+	return true
+}
+
+// parseMessage 解析并校验传感器数据消息
+func (h *SensorDataHandler) parseMessage(topic string, payload []byte) (*models.UnifiedSensorData, error) {
 	var msg models.MQTTMessage
 	if err := json.Unmarshal(payload, &msg); err != nil {
 		h.logger.Error("解析甲醛数据消息失败", utils.ErrorField(err))
-		return err
+		return nil, err
 	}
 
 	// 验证必要字段
 	if msg.DeviceID == "" {
-		return fmt.Errorf("设备ID不能为空")
+		return nil, fmt.Errorf("设备ID不能为空")
+	}
+
+	// 载荷中的设备ID必须与主题一致，防止冒充其他设备上报
+	if topicDeviceID := deviceIDFromTopic(topic); topicDeviceID != msg.DeviceID {
+		h.logger.Warn("设备ID与主题不匹配，拒绝数据",
+			utils.String("topic", topic),
+			utils.String("topic_device_id", topicDeviceID),
+			utils.String("payload_device_id", msg.DeviceID))
+		return nil, fmt.Errorf("设备ID与主题不匹配: %s", msg.DeviceID)
 	}
 
 	// 转换时间戳
 	timestamp := time.Unix(msg.Timestamp, 0)
 
-	// 确定设备类型
-	deviceType := models.DeviceTypeFormaldehyde // 默认甲醛传感器
+	// 确定设备类型：优先载荷，其次主题，默认甲醛传感器
+	deviceType := models.DeviceTypeFormaldehyde
 	if msg.DeviceType != "" {
 		deviceType = models.DeviceType(msg.DeviceType)
+	} else if topicType := deviceTypeFromTopic(topic); topicType.IsValid() {
+		deviceType = topicType
 	}
 
 	// 提取数据
@@ -66,31 +227,21 @@ func (h *SensorDataHandler) HandleMessage(topic string, payload []byte) error {
 		DataQuality: "good",
 	}
 
-	// 解析数据字段
-	if data, ok := msg.Data["formaldehyde"].(float64); ok {
-		sensorData.Formaldehyde = &data
-	}
-	if data, ok := msg.Data["pm25"].(float64); ok {
-		sensorData.PM25 = &data
-	}
-	if data, ok := msg.Data["pm10"].(float64); ok {
-		sensorData.PM10 = &data
-	}
-	if data, ok := msg.Data["co2"].(float64); ok {
-		sensorData.CO2 = &data
-	}
-	if data, ok := msg.Data["temperature"].(float64); ok {
-		sensorData.Temperature = &data
-	}
-	if data, ok := msg.Data["humidity"].(float64); ok {
-		sensorData.Humidity = &data
-	}
-	if data, ok := msg.Data["pressure"].(float64); ok {
-		sensorData.Pressure = &data
-	}
-	if data, ok := msg.Data["battery"].(float64); ok {
-		battery := int(data)
-		sensorData.Battery = &battery
+	// 解析数据字段：与HTTP上传一致，所有数值指标经SetMetricValue写入固定列或扩展数据，电量按整数保存
+	for metric, value := range msg.Data {
+		data, ok := value.(float64)
+		if !ok {
+			h.logger.Warn("忽略非数值指标",
+				utils.String("device_id", msg.DeviceID),
+				utils.String("metric", metric))
+			continue
+		}
+		if metric == "battery" {
+			battery := int(data)
+			sensorData.Battery = &battery
+		} else {
+			sensorData.SetMetricValue(metric, &data)
+		}
 	}
 
 	// 解析质量信息
@@ -114,67 +265,28 @@ func (h *SensorDataHandler) HandleMessage(topic string, payload []byte) error {
 		}
 	}
 
-	// 保存数据
-	ctx := context.Background()
-	if err := h.dataRepo.Create(ctx, sensorData); err != nil {
-		h.logger.Error("保存传感器数据失败",
-			utils.String("device_id", msg.DeviceID),
-			utils.ErrorField(err))
-		return err
-	}
-
-	// 检查告警
-	if err := h.checkAlerts(ctx, sensorData); err != nil {
-		h.logger.Error("检查告警失败",
-			utils.String("device_id", msg.DeviceID),
-			utils.ErrorField(err))
-	}
-
-	h.logger.Info("处理传感器数据成功",
-		utils.String("device_id", msg.DeviceID),
-		utils.String("device_type", string(deviceType)),
-		utils.String("sensor_id", msg.SensorID),
-		utils.String("sensor_type", msg.SensorType),
-		utils.Float64("formaldehyde", getFloatValue(sensorData.Formaldehyde)))
-
-	return nil
+	return sensorData, nil
 }
 
-// checkAlerts 检查告警
+// checkAlerts 按告警规则评估读数
 func (h *SensorDataHandler) checkAlerts(ctx context.Context, data *models.UnifiedSensorData) error {
-	if data.Formaldehyde == nil {
+	if h.evaluator == nil {
 		return nil
 	}
 
-	formaldehyde := *data.Formaldehyde
-	var alertLevel string
-	var message string
-
-	// 检查甲醛浓度告警
-	if formaldehyde >= 0.1 {
-		alertLevel = "critical"
-		message = fmt.Sprintf("甲醛浓度严重超标: %.3f mg/m³", formaldehyde)
-	} else if formaldehyde >= 0.08 {
-		alertLevel = "warning"
-		message = fmt.Sprintf("甲醛浓度超标: %.3f mg/m³", formaldehyde)
-	} else {
-		return nil // 正常范围，无需告警
+	alerts, err := h.evaluator.Evaluate(ctx, data)
+	if err != nil {
+		return err
 	}
-
-	// 创建告警 (使用默认规则ID 0，表示系统自动生成的告警)
-	alert := &models.Alert{
-		RuleID:         0, // 系统自动告警，无对应规则
-		DeviceID:       data.DeviceID,
-		Metric:         "formaldehyde",
-		CurrentValue:   formaldehyde,
-		ThresholdValue: 0.08, // 默认阈值
-		Severity:       alertLevel,
-		Status:         "active",
-		TriggeredAt:    time.Now(),
-		Message:        &message,
+	for _, alert := range alerts {
+		h.logger.Warn("🚨 触发告警",
+			utils.String("device_id", alert.DeviceID),
+			utils.Int64("rule_id", int64(alert.RuleID)),
+			utils.String("metric", alert.Metric),
+			utils.Float64("value", alert.CurrentValue),
+			utils.String("severity", alert.Severity))
 	}
-
-	return h.alertSvc.CreateAlert(ctx, alert)
+	return nil
 }
 
 // getFloatValue 安全获取浮点数值
@@ -0,0 +1,288 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 队列溢出策略
+const (
+	OverflowDropNewest = "drop_newest" // 丢弃新消息
+	OverflowDropOldest = "drop_oldest" // 丢弃队列中最旧的消息
+	OverflowBlock      = "block"       // 阻塞等待队列空位
+)
+
+// 入库管道默认参数（配置缺省时使用）
+const (
+	defaultIngestBufferSize    = 1000
+	defaultIngestBatchSize     = 100
+	defaultIngestWorkers       = 4
+	defaultIngestFlushInterval = time.Second
+	ingestFlushTimeout         = 30 * time.Second
+	dropLogEvery               = 100
+)
+
+// ingestMessage 待入库的原始消息
+type ingestMessage struct {
+	topic      string
+	payload    []byte
+	receivedAt time.Time
+}
+
+// IngestPipeline 传感器数据异步批量入库管道
+type IngestPipeline struct {
+	handler        *SensorDataHandler
+	logger         utils.Logger
+	queue          chan ingestMessage
+	workers        int
+	batchSize      int
+	flushInterval  time.Duration
+	overflowPolicy string
+
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+	wg      sync.WaitGroup
+
+	enqueued    atomic.Int64
+	dropped     atomic.Int64
+	parseFailed atomic.Int64
+	written     atomic.Int64
+	writeFailed atomic.Int64
+	spooled     atomic.Int64
+	batches     atomic.Int64
+	highWater   atomic.Int64
+}
+
+// NewIngestPipeline 创建入库管道
+func NewIngestPipeline(handler *SensorDataHandler, cfg config.MessageConfig, logger utils.Logger) *IngestPipeline {
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultIngestBufferSize
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIngestBatchSize
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultIngestWorkers
+	}
+	flushInterval := time.Duration(cfg.FlushInterval) * time.Millisecond
+	if flushInterval <= 0 {
+		flushInterval = defaultIngestFlushInterval
+	}
+	policy := cfg.OverflowPolicy
+	switch policy {
+	case OverflowDropNewest, OverflowDropOldest, OverflowBlock:
+	default:
+		policy = OverflowDropNewest
+	}
+
+	return &IngestPipeline{
+		handler:        handler,
+		logger:         logger,
+		queue:          make(chan ingestMessage, bufferSize),
+		workers:        workers,
+		batchSize:      batchSize,
+		flushInterval:  flushInterval,
+		overflowPolicy: policy,
+	}
+}
+
+// Start 启动工作协程
+func (p *IngestPipeline) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return
+	}
+	p.started = true
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	p.logger.Info("🚚 传感器数据入库管道已启动",
+		utils.Int("workers", p.workers),
+		utils.Int("buffer_size", cap(p.queue)),
+		utils.Int("batch_size", p.batchSize),
+		utils.Duration("flush_interval", p.flushInterval),
+		utils.String("overflow_policy", p.overflowPolicy))
+}
+
+// Submit 提交消息到队列，返回是否入队成功
+func (p *IngestPipeline) Submit(topic string, payload []byte) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.drop(topic, "pipeline_stopped")
+		return false
+	}
+
+	// 复制载荷，避免Broker复用缓冲区
+	msg := ingestMessage{
+		topic:      topic,
+		payload:    append([]byte(nil), payload...),
+		receivedAt: time.Now(),
+	}
+
+	switch p.overflowPolicy {
+	case OverflowBlock:
+		p.queue <- msg
+	case OverflowDropOldest:
+		for {
+			select {
+			case p.queue <- msg:
+				p.accepted()
+				return true
+			default:
+			}
+			select {
+			case old := <-p.queue:
+				p.drop(old.topic, "queue_full_drop_oldest")
+			default:
+			}
+		}
+	default:
+		select {
+		case p.queue <- msg:
+		default:
+			p.drop(topic, "queue_full")
+			return false
+		}
+	}
+
+	p.accepted()
+	return true
+}
+
+// Stop 停止接收新消息，并等待队列中的数据全部入库
+func (p *IngestPipeline) Stop() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	pending := len(p.queue)
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.logger.Info("🛑 正在排空传感器数据入库管道", utils.Int("pending", pending))
+	p.wg.Wait()
+	p.logger.Info("✅ 传感器数据入库管道已停止",
+		utils.Int64("written", p.written.Load()),
+		utils.Int64("dropped", p.dropped.Load()),
+		utils.Int64("write_failed", p.writeFailed.Load()))
+}
+
+// Stats 获取管道背压与吞吐指标
+func (p *IngestPipeline) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"queue_depth":     len(p.queue),
+		"queue_capacity":  cap(p.queue),
+		"queue_highwater": p.highWater.Load(),
+		"enqueued":        p.enqueued.Load(),
+		"dropped":         p.dropped.Load(),
+		"parse_failed":    p.parseFailed.Load(),
+		"written":         p.written.Load(),
+		"write_failed":    p.writeFailed.Load(),
+		"spooled":         p.spooled.Load(),
+		"batches":         p.batches.Load(),
+		"workers":         p.workers,
+		"batch_size":      p.batchSize,
+		"overflow_policy": p.overflowPolicy,
+	}
+}
+
+// worker 消费队列，按批量大小或刷新间隔写库
+func (p *IngestPipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]models.UnifiedSensorData, 0, p.batchSize)
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			data, err := p.handler.parseMessage(msg.topic, msg.payload)
+			if err != nil {
+				p.parseFailed.Add(1)
+				continue
+			}
+			batch = append(batch, *data)
+			if len(batch) >= p.batchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush 写入一个批次
+func (p *IngestPipeline) flush(batch []models.UnifiedSensorData) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ingestFlushTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := p.handler.HandleBatch(ctx, batch); err != nil {
+		if p.handler.spoolData(batch) {
+			p.spooled.Add(int64(len(batch)))
+		} else {
+			p.writeFailed.Add(int64(len(batch)))
+		}
+		return
+	}
+
+	p.written.Add(int64(len(batch)))
+	p.batches.Add(1)
+	p.logger.Debug("📦 传感器数据批量入库完成",
+		utils.Int("batch_size", len(batch)),
+		utils.Duration("elapsed", time.Since(start)))
+}
+
+// accepted 记录入队并更新队列高水位
+func (p *IngestPipeline) accepted() {
+	p.enqueued.Add(1)
+	depth := int64(len(p.queue))
+	for {
+		hw := p.highWater.Load()
+		if depth <= hw || p.highWater.CompareAndSwap(hw, depth) {
+			return
+		}
+	}
+}
+
+// drop 记录丢弃（按间隔输出日志，避免日志风暴）
+func (p *IngestPipeline) drop(topic, reason string) {
+	total := p.dropped.Add(1)
+	if total == 1 || total%dropLogEvery == 0 {
+		p.logger.Warn("⚠️ 传感器数据被丢弃",
+			utils.String("topic", topic),
+			utils.String("reason", reason),
+			utils.Int64("dropped_total", total),
+			utils.Int("queue_depth", len(p.queue)))
+	}
+}
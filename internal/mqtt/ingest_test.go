@@ -0,0 +1,174 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ingestTopic 测试数据主题
+var ingestTopic = NewTestTopicGenerator().GenerateValidTopic("hcho", TestDeviceID1)
+
+// setupIngestPipeline 创建基于内存数据库的入库管道
+func setupIngestPipeline(t *testing.T, cfg config.MessageConfig) (*IngestPipeline, repositories.UnifiedSensorDataRepository) {
+	db := setupTestDatabase(t)
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+	handler := NewSensorDataHandler(
+		dataRepo,
+		repositories.NewDeviceRepository(db, logger),
+		newTestAlertEvaluator(db, logger),
+		logger,
+	)
+	return NewIngestPipeline(handler, cfg, logger), dataRepo
+}
+
+// ingestPayload 构造测试载荷
+func ingestPayload(t *testing.T, deviceID string, value float64) []byte {
+	payload, err := json.Marshal(map[string]interface{}{
+		"device_id":   deviceID,
+		"device_type": "hcho",
+		"timestamp":   time.Now().Unix(),
+		"data":        map[string]interface{}{"formaldehyde": value},
+	})
+	require.NoError(t, err)
+	return payload
+}
+
+// TestIngestPipeline_BatchAndDrain 测试批量写入及停止时排空队列
+func TestIngestPipeline_BatchAndDrain(t *testing.T) {
+	pipeline, dataRepo := setupIngestPipeline(t, config.MessageConfig{
+		BufferSize:    100,
+		BatchSize:     10,
+		Workers:       2,
+		FlushInterval: 50,
+	})
+	pipeline.Start()
+
+	for i := 0; i < 25; i++ {
+		assert.True(t, pipeline.Submit(ingestTopic, ingestPayload(t, TestDeviceID1, 0.01*float64(i%5))))
+	}
+	// 主题与载荷设备ID不一致，解析阶段被拒绝
+	assert.True(t, pipeline.Submit(ingestTopic, ingestPayload(t, TestDeviceID2, 0.01)))
+
+	pipeline.Stop()
+
+	data, err := dataRepo.GetHistoryByDeviceID(context.Background(), TestDeviceID1, 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, data, 25)
+
+	stats := pipeline.Stats()
+	assert.Equal(t, int64(26), stats["enqueued"])
+	assert.Equal(t, int64(25), stats["written"])
+	assert.Equal(t, int64(1), stats["parse_failed"])
+	assert.Equal(t, 0, stats["queue_depth"])
+
+	// 停止后拒绝新消息
+	assert.False(t, pipeline.Submit(ingestTopic, ingestPayload(t, TestDeviceID1, 0.01)))
+}
+
+// TestIngestPipeline_OverflowPolicy 测试队列溢出策略
+func TestIngestPipeline_OverflowPolicy(t *testing.T) {
+	tests := []struct {
+		policy          string
+		expectAccepted  int
+		expectDropped   int64
+		expectLastValue float64
+	}{
+		{OverflowDropNewest, 2, 1, 0.02},
+		{OverflowDropOldest, 3, 1, 0.03},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			pipeline, dataRepo := setupIngestPipeline(t, config.MessageConfig{
+				BufferSize:     2,
+				BatchSize:      10,
+				Workers:        1,
+				FlushInterval:  50,
+				OverflowPolicy: tt.policy,
+			})
+
+			// 未启动工作协程，队列会被填满
+			accepted := 0
+			for i := 1; i <= 3; i++ {
+				if pipeline.Submit(ingestTopic, ingestPayload(t, TestDeviceID1, 0.01*float64(i))) {
+					accepted++
+				}
+			}
+			assert.Equal(t, tt.expectAccepted, accepted)
+			assert.Equal(t, tt.expectDropped, pipeline.Stats()["dropped"])
+			assert.Equal(t, int64(2), pipeline.Stats()["queue_highwater"])
+
+			pipeline.Start()
+			pipeline.Stop()
+
+			data, err := dataRepo.GetHistoryByDeviceID(context.Background(), TestDeviceID1, 10, 0)
+			require.NoError(t, err)
+			require.Len(t, data, 2)
+
+			values := []float64{*data[0].Formaldehyde, *data[1].Formaldehyde}
+			assert.Contains(t, values, tt.expectLastValue, fmt.Sprintf("values=%v", values))
+		})
+	}
+}
+
+// TestIngestPipeline_AllMetrics 测试MQTT上报的臭氧、二氧化氮等指标及未知指标都被保存，非数值指标被忽略
+func TestIngestPipeline_AllMetrics(t *testing.T) {
+	pipeline, dataRepo := setupIngestPipeline(t, config.MessageConfig{
+		BufferSize:    10,
+		BatchSize:     10,
+		Workers:       1,
+		FlushInterval: 50,
+	})
+	pipeline.Start()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"device_id":   TestDeviceID1,
+		"device_type": "hcho",
+		"timestamp":   time.Now().Unix(),
+		"data": map[string]interface{}{
+			"formaldehyde": 0.05, "o3": 160.0, "no2": 80.0, "so2": 20.0, "co": 1.2, "voc": 300.0,
+			"tvoc": 0.4, "battery": 87.0, "status": "ok",
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, pipeline.Submit(ingestTopic, payload))
+	pipeline.Stop()
+
+	data, err := dataRepo.GetHistoryByDeviceID(context.Background(), TestDeviceID1, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{"formaldehyde", 0.05},
+		{"o3", 160},
+		{"no2", 80},
+		{"so2", 20},
+		{"co", 1.2},
+		{"voc", 300},
+		{"tvoc", 0.4},
+	}
+	for _, tt := range tests {
+		value := data[0].GetMetricValue(tt.metric)
+		if assert.NotNil(t, value, tt.metric) {
+			assert.InDelta(t, tt.want, *value, 1e-9, tt.metric)
+		}
+	}
+	require.NotNil(t, data[0].Battery)
+	assert.Equal(t, 87, *data[0].Battery)
+	assert.Nil(t, data[0].GetMetricValue("status"))
+}
@@ -4,6 +4,7 @@ import (
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/repositories"
 	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
 	"air-quality-server/internal/utils"
 	"context"
 	"encoding/json"
@@ -12,30 +13,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 // setupTestDatabase 设置测试数据库
 func setupTestDatabase(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
+	return testutil.NewDatabase(t)
+}
 
-	// 自动迁移
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Role{},
-		&models.UserRole{},
-		&models.Device{},
-		&models.UnifiedSensorData{},
-		&models.DeviceRuntimeStatus{},
-		&models.Alert{},
-		&models.AlertRule{},
-		&models.SystemConfig{},
+// newTestAlertEvaluator 创建基于测试数据库的告警规则评估器
+func newTestAlertEvaluator(db *gorm.DB, logger utils.Logger) services.AlertEvaluator {
+	return services.NewAlertEvaluator(
+		services.NewAlertRuleService(repositories.NewAlertRuleRepository(db, logger), logger),
+		services.NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger),
+		nil,
+		logger,
 	)
-	require.NoError(t, err)
-
-	return db
 }
 
 // TestMQTTIntegration_CompleteFlow 测试完整的MQTT数据流
@@ -52,18 +45,19 @@ func TestMQTTIntegration_CompleteFlow(t *testing.T) {
 		Device:            repositories.NewDeviceRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 	}
 
 	// 创建服务
 	svcs := &services.Services{
-		Alert: services.NewAlertService(repos.Alert, logger),
+		Alert: services.NewAlertService(repos.Alert, nil, logger),
 	}
 
 	// 创建数据处理器
 	handler := NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		services.NewAlertEvaluator(services.NewAlertRuleService(repos.AlertRule, logger), svcs.Alert, nil, logger),
 		logger,
 	)
 
@@ -143,18 +137,47 @@ func TestMQTTIntegration_AlertGeneration(t *testing.T) {
 		Device:            repositories.NewDeviceRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 	}
 
 	// 创建服务
 	svcs := &services.Services{
-		Alert: services.NewAlertService(repos.Alert, logger),
+		Alert: services.NewAlertService(repos.Alert, nil, logger),
 	}
 
+	// 创建告警规则：全局严重规则、其他设备的警告规则、不满足条件的温度规则
+	ruleService := services.NewAlertRuleService(repos.AlertRule, logger)
+	ctx := context.Background()
+	otherDevice := "hcho_002"
+	criticalRule, err := ruleService.CreateRule(ctx, &models.AlertRuleCreateRequest{
+		Name:           "甲醛浓度严重超标",
+		Metric:         "formaldehyde",
+		ConditionType:  string(models.AlertConditionGT),
+		ThresholdValue: 0.1,
+		Severity:       string(models.AlertSeverityCritical),
+	})
+	require.NoError(t, err)
+	_, err = ruleService.CreateRule(ctx, &models.AlertRuleCreateRequest{
+		Name:           "甲醛浓度超标",
+		DeviceID:       &otherDevice,
+		Metric:         "formaldehyde",
+		ConditionType:  string(models.AlertConditionGT),
+		ThresholdValue: 0.08,
+	})
+	require.NoError(t, err)
+	_, err = ruleService.CreateRule(ctx, &models.AlertRuleCreateRequest{
+		Name:           "温度过低",
+		Metric:         "temperature",
+		ConditionType:  string(models.AlertConditionLT),
+		ThresholdValue: 10,
+	})
+	require.NoError(t, err)
+
 	// 创建数据处理器
 	handler := NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		services.NewAlertEvaluator(ruleService, svcs.Alert, nil, logger),
 		logger,
 	)
 
@@ -166,7 +189,7 @@ func TestMQTTIntegration_AlertGeneration(t *testing.T) {
 		"sensor_type": "hcho",
 		"timestamp":   time.Now().Unix(),
 		"data": map[string]interface{}{
-			"formaldehyde": 0.12, // 超过0.08的警告阈值
+			"formaldehyde": 0.12, // 超过0.1的严重阈值
 			"temperature":  22.5,
 			"humidity":     45.0,
 			"battery":      85.0,
@@ -189,8 +212,9 @@ func TestMQTTIntegration_AlertGeneration(t *testing.T) {
 	alert := alerts[0]
 	assert.Equal(t, "hcho_001", alert.DeviceID)
 	assert.Equal(t, "formaldehyde", alert.Metric)
+	assert.Equal(t, criticalRule.ID, alert.RuleID)
 	assert.Equal(t, 0.12, alert.CurrentValue)
-	assert.Equal(t, 0.08, alert.ThresholdValue)
+	assert.Equal(t, 0.1, alert.ThresholdValue)
 	assert.Equal(t, "critical", alert.Severity)
 	assert.Equal(t, "active", alert.Status)
 	assert.NotNil(t, alert.Message)
@@ -211,18 +235,19 @@ func TestMQTTIntegration_MultipleDevices(t *testing.T) {
 		Device:            repositories.NewDeviceRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 	}
 
 	// 创建服务
 	svcs := &services.Services{
-		Alert: services.NewAlertService(repos.Alert, logger),
+		Alert: services.NewAlertService(repos.Alert, nil, logger),
 	}
 
 	// 创建数据处理器
 	handler := NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		services.NewAlertEvaluator(services.NewAlertRuleService(repos.AlertRule, logger), svcs.Alert, nil, logger),
 		logger,
 	)
 
@@ -281,18 +306,19 @@ func TestMQTTIntegration_DataValidation(t *testing.T) {
 		Device:            repositories.NewDeviceRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 	}
 
 	// 创建服务
 	svcs := &services.Services{
-		Alert: services.NewAlertService(repos.Alert, logger),
+		Alert: services.NewAlertService(repos.Alert, nil, logger),
 	}
 
 	// 创建数据处理器
 	handler := NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		services.NewAlertEvaluator(services.NewAlertRuleService(repos.AlertRule, logger), svcs.Alert, nil, logger),
 		logger,
 	)
 
@@ -368,18 +394,19 @@ func BenchmarkMQTTIntegration_HandleMessage(b *testing.B) {
 		Device:            repositories.NewDeviceRepository(db, logger),
 		UnifiedSensorData: repositories.NewUnifiedSensorDataRepository(db, logger),
 		Alert:             repositories.NewAlertRepository(db, logger),
+		AlertRule:         repositories.NewAlertRuleRepository(db, logger),
 	}
 
 	// 创建服务
 	svcs := &services.Services{
-		Alert: services.NewAlertService(repos.Alert, logger),
+		Alert: services.NewAlertService(repos.Alert, nil, logger),
 	}
 
 	// 创建数据处理器
 	handler := NewSensorDataHandler(
 		repos.UnifiedSensorData,
 		repos.Device,
-		svcs.Alert,
+		services.NewAlertEvaluator(services.NewAlertRuleService(repos.AlertRule, logger), svcs.Alert, nil, logger),
 		logger,
 	)
 
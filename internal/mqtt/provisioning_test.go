@@ -0,0 +1,71 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/testutil"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sensorPayload 构造不带device_type的传感器数据消息
+func sensorPayload(deviceID string, formaldehyde float64) []byte {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"device_id": deviceID,
+		"timestamp": time.Now().Unix(),
+		"data":      map[string]interface{}{"formaldehyde": formaldehyde},
+	})
+	return payload
+}
+
+// TestSensorDataHandler_Provisioning 测试MQTT数据按接入策略处理未注册设备，自动注册时按主题确定设备类型
+func TestSensorDataHandler_Provisioning(t *testing.T) {
+	tests := []struct {
+		mode        string
+		stored      int64
+		quarantined int64
+		registered  bool
+	}{
+		{"strict", 0, 0, false},
+		{"auto", 2, 0, true},
+		{"pending", 0, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db := setupTestDatabase(t)
+			logger := testutil.NewLogger(t)
+			require.NoError(t, db.Create(&models.Device{ID: TestDeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+			deviceRepo := repositories.NewDeviceRepository(db, logger)
+			dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+			handler := NewSensorDataHandler(dataRepo, deviceRepo, nil, logger)
+			handler.SetProvisioningService(services.NewDeviceProvisioningService(config.ProvisioningConfig{Mode: tt.mode},
+				deviceRepo, repositories.NewPendingDeviceRepository(db, logger), dataRepo, nil, logger))
+
+			require.NoError(t, handler.HandleMessage("air-quality/hcho/"+TestDeviceID1+"/data", sensorPayload(TestDeviceID1, 0.05)))
+			for _, value := range []float64{0.05, 0.06} {
+				require.NoError(t, handler.HandleMessage("air-quality/pm25/"+TestDeviceID2+"/data", sensorPayload(TestDeviceID2, value)))
+			}
+
+			count := func(model interface{}, deviceID string) int64 {
+				var count int64
+				require.NoError(t, db.Model(model).Where("device_id = ?", deviceID).Count(&count).Error)
+				return count
+			}
+			assert.Equal(t, int64(1), count(&models.UnifiedSensorData{}, TestDeviceID1))
+			assert.Equal(t, tt.stored, count(&models.UnifiedSensorData{}, TestDeviceID2))
+			assert.Equal(t, tt.quarantined, count(&models.QuarantinedReading{}, TestDeviceID2))
+			var devices []models.Device
+			require.NoError(t, db.Where("id = ?", TestDeviceID2).Find(&devices).Error)
+			require.Equal(t, tt.registered, len(devices) == 1)
+			if tt.registered {
+				assert.Equal(t, models.DeviceTypePM25, devices[0].Type)
+			}
+		})
+	}
+}
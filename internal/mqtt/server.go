@@ -2,6 +2,7 @@ package mqtt
 
 import (
 	"air-quality-server/internal/config"
+	"air-quality-server/internal/services"
 	"air-quality-server/internal/utils"
 	"bytes"
 	"context"
@@ -10,10 +11,8 @@ import (
 	"log/slog"
 	"strings"
 	"sync"
-	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
-	"github.com/mochi-mqtt/server/v2/hooks/auth"
 	"github.com/mochi-mqtt/server/v2/hooks/storage"
 	"github.com/mochi-mqtt/server/v2/listeners"
 	"github.com/mochi-mqtt/server/v2/packets"
@@ -30,6 +29,15 @@ type Server struct {
 	running           bool
 	server            *mqtt.Server
 	sensorDataHandler *SensorDataHandler
+	credentialService services.DeviceCredentialService
+	aclService        services.MQTTACLService
+	presenceService   services.DevicePresenceService
+	commandService    services.DeviceCommandService
+	configService     services.DeviceConfigService
+	firmwareService   services.FirmwareCampaignService
+	authHook          *AuthHook
+	aclEngine         *ACLEngine
+	ingest            *IngestPipeline
 }
 
 // NewServer 创建MQTT服务器
@@ -45,6 +53,36 @@ func NewServer(cfg *config.MQTTConfig, logger utils.Logger, sensorDataHandler *S
 	}
 }
 
+// SetCredentialService 设置设备凭证服务（需在Start之前调用）
+func (s *Server) SetCredentialService(credentialService services.DeviceCredentialService) {
+	s.credentialService = credentialService
+}
+
+// SetACLService 设置ACL规则服务（需在Start之前调用）
+func (s *Server) SetACLService(aclService services.MQTTACLService) {
+	s.aclService = aclService
+}
+
+// SetPresenceService 设置设备在线状态服务（需在Start之前调用）
+func (s *Server) SetPresenceService(presenceService services.DevicePresenceService) {
+	s.presenceService = presenceService
+}
+
+// SetCommandService 设置设备指令服务（需在Start之前调用）
+func (s *Server) SetCommandService(commandService services.DeviceCommandService) {
+	s.commandService = commandService
+}
+
+// SetConfigService 设置设备配置同步服务（需在Start之前调用）
+func (s *Server) SetConfigService(configService services.DeviceConfigService) {
+	s.configService = configService
+}
+
+// SetFirmwareService 设置固件升级活动服务（需在Start之前调用）
+func (s *Server) SetFirmwareService(firmwareService services.FirmwareCampaignService) {
+	s.firmwareService = firmwareService
+}
+
 // Start 启动MQTT服务器
 func (s *Server) Start() error {
 	s.logger.Info("🚀 开始启动MQTT服务器...",
@@ -58,26 +96,44 @@ func (s *Server) Start() error {
 
 	// 创建Mochi MQTT服务器
 	s.logger.Debug("📦 正在创建Mochi MQTT服务器实例...")
-	s.server = mqtt.New(nil)
+	// 启用内联客户端，服务端可直接发布消息（通知、指令等）
+	s.server = mqtt.New(&mqtt.Options{InlineClient: true})
 	s.logger.Info("✅ Mochi MQTT服务器实例已创建",
 		utils.String("server_type", "mochi-mqtt"),
 		utils.String("version", "v2"))
 
-	// 添加认证钩子（允许所有连接，生产环境应使用自定义认证）
+	// 添加认证钩子（基于数据库中的设备凭证，并按设备ID执行主题ACL）
 	s.logger.Debug("🔐 正在添加认证钩子...")
-	if err := s.server.AddHook(new(auth.AllowHook), nil); err != nil {
+	s.aclEngine = NewACLEngine(s.aclService, s.config.Topics, s.config.Username, s.logger)
+	s.authHook = new(AuthHook)
+	if err := s.server.AddHook(s.authHook, map[string]interface{}{
+		"logger":            s.logger,
+		"credentialService": s.credentialService,
+		"aclEngine":         s.aclEngine,
+		"serviceUsername":   s.config.Username,
+		"servicePassword":   s.config.Password,
+	}); err != nil {
 		s.logger.Error("❌ 添加认证钩子失败", utils.ErrorField(err))
 		return fmt.Errorf("添加认证钩子失败: %w", err)
 	}
 	s.logger.Info("✅ 认证钩子已添加",
-		utils.String("hook_type", "AllowHook"),
-		utils.String("description", "允许所有连接"))
+		utils.String("hook_type", "AuthHook"),
+		utils.String("description", "校验设备凭证及主题ACL"))
+
+	// 创建异步批量入库管道
+	if s.sensorDataHandler != nil {
+		s.ingest = NewIngestPipeline(s.sensorDataHandler, s.config.Message, s.logger)
+		s.ingest.Start()
+	}
 
 	// 添加消息处理钩子
 	s.logger.Debug("📨 正在添加消息处理钩子...")
 	if err := s.server.AddHook(new(MessageHandlerHook), map[string]interface{}{
 		"logger":            s.logger,
 		"sensorDataHandler": s.sensorDataHandler,
+		"ingestPipeline":    s.ingest,
+		"presenceService":   s.presenceService,
+		"serviceUsername":   s.config.Username,
 	}); err != nil {
 		s.logger.Error("❌ 添加消息处理钩子失败", utils.ErrorField(err))
 		return fmt.Errorf("添加消息处理钩子失败: %w", err)
@@ -86,6 +142,32 @@ func (s *Server) Start() error {
 		utils.String("hook_type", "MessageHandlerHook"),
 		utils.String("description", "处理MQTT消息和事件"))
 
+	// 订阅设备状态主题（遗嘱消息由Broker直接投递给订阅者，不经过OnPublish）
+	if s.presenceService != nil {
+		if err := s.subscribeDeviceStatus(); err != nil {
+			s.logger.Error("❌ 订阅设备状态主题失败", utils.ErrorField(err))
+			return fmt.Errorf("订阅设备状态主题失败: %w", err)
+		}
+	}
+	if s.commandService != nil {
+		if err := s.subscribeDeviceResponse(); err != nil {
+			s.logger.Error("❌ 订阅设备指令响应主题失败", utils.ErrorField(err))
+			return fmt.Errorf("订阅设备指令响应主题失败: %w", err)
+		}
+	}
+	if s.configService != nil {
+		if err := s.subscribeDeviceConfigReported(); err != nil {
+			s.logger.Error("❌ 订阅设备配置上报主题失败", utils.ErrorField(err))
+			return fmt.Errorf("订阅设备配置上报主题失败: %w", err)
+		}
+	}
+	if s.firmwareService != nil {
+		if err := s.subscribeFirmwareProgress(); err != nil {
+			s.logger.Error("❌ 订阅固件升级进度主题失败", utils.ErrorField(err))
+			return fmt.Errorf("订阅固件升级进度主题失败: %w", err)
+		}
+	}
+
 	// 添加TCP监听器（端口1883）
 	// 从配置中解析端口
 	port := "1883" // 默认端口
@@ -129,52 +211,15 @@ func (s *Server) Start() error {
 		utils.String("address", ":"+port),
 		utils.String("status", "ready"))
 
-	// 启动服务器
+	// 启动服务器（Serve启动监听器后立即返回，不会阻塞）
 	s.logger.Info("🚀 正在启动MQTT服务器服务...")
-	s.running = true
-
-	// 在goroutine中运行服务器
-	go func() {
-		s.logger.Info("🔄 MQTT服务器服务正在启动...")
-		// Serve()方法是阻塞的，会一直运行直到服务器停止
-		// 如果Serve()返回，说明服务器已经停止
-		if err := s.server.Serve(); err != nil {
-			s.logger.Error("❌ MQTT服务器服务异常停止",
-				utils.ErrorField(err),
-				utils.String("reason", "server_error"))
-		} else {
-			s.logger.Info("🛑 MQTT服务器服务正常停止",
-				utils.String("reason", "normal_shutdown"))
-		}
-		// 无论正常停止还是异常停止，都设置running为false
-		s.running = false
-		s.logger.Info("📊 服务器状态已更新", utils.Bool("running", s.running))
-	}()
-
-	// 等待一小段时间确保服务器启动
-	s.logger.Debug("⏳ 等待服务器启动...")
-	time.Sleep(200 * time.Millisecond)
-
-	// 检查服务器是否真正启动
-	if s.server == nil {
-		s.logger.Error("❌ MQTT服务器实例为空")
-		return fmt.Errorf("MQTT服务器实例为空")
-	}
-
-	// 检查监听器是否已添加
-	s.logger.Debug("🔍 检查MQTT服务器监听器状态...")
-
-	// 等待running状态被设置
-	s.logger.Debug("⏳ 等待服务器运行状态确认...")
-	for i := 0; i < 10; i++ {
-		if s.running {
-			s.logger.Debug("✅ 服务器运行状态已确认",
-				utils.Int("attempt", i+1),
-				utils.Bool("running", s.running))
-			break
-		}
-		time.Sleep(50 * time.Millisecond)
+	if err := s.server.Serve(); err != nil {
+		s.logger.Error("❌ MQTT服务器服务启动失败", utils.ErrorField(err))
+		return fmt.Errorf("启动MQTT服务器失败: %w", err)
 	}
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
 
 	s.logger.Info("🎉 MQTT服务器启动成功！",
 		utils.String("address", ":1883"),
@@ -195,12 +240,106 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// subscribeDeviceStatus 使用内联客户端订阅设备状态主题
+func (s *Server) subscribeDeviceStatus() error {
+	filter := s.config.Topics.DeviceStatus
+	if filter == "" {
+		filter = defaultDeviceStatusTopic
+	}
+
+	handler := NewDeviceStatusHandler(s.presenceService, s.logger)
+	if err := s.server.Subscribe(filter, deviceStatusSubscriptionID, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		if err := handler.HandleMessage(pk.TopicName, pk.Payload); err != nil {
+			s.logger.Error("❌ 处理设备状态消息失败",
+				utils.String("client_id", pk.Origin),
+				utils.String("topic", pk.TopicName),
+				utils.ErrorField(err))
+		}
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("✅ 已订阅设备状态主题", utils.String("filter", filter))
+	return nil
+}
+
+// subscribeDeviceResponse 使用内联客户端订阅设备指令响应主题
+func (s *Server) subscribeDeviceResponse() error {
+	filter := s.config.Topics.DeviceResponse
+	if filter == "" {
+		filter = defaultDeviceResponseTopic
+	}
+
+	handler := NewDeviceResponseHandler(s.commandService, s.logger)
+	if err := s.server.Subscribe(filter, deviceResponseSubscriptionID, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		if err := handler.HandleMessage(pk.TopicName, pk.Payload); err != nil {
+			s.logger.Error("❌ 处理设备指令响应失败",
+				utils.String("client_id", pk.Origin),
+				utils.String("topic", pk.TopicName),
+				utils.ErrorField(err))
+		}
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("✅ 已订阅设备指令响应主题", utils.String("filter", filter))
+	return nil
+}
+
+// subscribeDeviceConfigReported 使用内联客户端订阅设备配置上报主题
+func (s *Server) subscribeDeviceConfigReported() error {
+	filter := s.config.Topics.DeviceConfigReported
+	if filter == "" {
+		filter = defaultDeviceConfigReportedTopic
+	}
+
+	handler := NewDeviceConfigReportHandler(s.configService, s.logger)
+	if err := s.server.Subscribe(filter, deviceConfigReportedSubscriptionID, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		if err := handler.HandleMessage(pk.TopicName, pk.Payload); err != nil {
+			s.logger.Error("❌ 处理设备配置上报失败",
+				utils.String("client_id", pk.Origin),
+				utils.String("topic", pk.TopicName),
+				utils.ErrorField(err))
+		}
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("✅ 已订阅设备配置上报主题", utils.String("filter", filter))
+	return nil
+}
+
+// subscribeFirmwareProgress 使用内联客户端订阅固件升级进度主题
+func (s *Server) subscribeFirmwareProgress() error {
+	filter := s.config.Topics.FirmwareProgress
+	if filter == "" {
+		filter = defaultFirmwareProgressTopic
+	}
+
+	handler := NewFirmwareProgressHandler(s.firmwareService, s.logger)
+	if err := s.server.Subscribe(filter, firmwareProgressSubscriptionID, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		if err := handler.HandleMessage(pk.TopicName, pk.Payload); err != nil {
+			s.logger.Error("❌ 处理固件升级进度失败",
+				utils.String("client_id", pk.Origin),
+				utils.String("topic", pk.TopicName),
+				utils.ErrorField(err))
+		}
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("✅ 已订阅固件升级进度主题", utils.String("filter", filter))
+	return nil
+}
+
 // Stop 停止MQTT服务器
 func (s *Server) Stop() {
 	s.logger.Info("🛑 开始停止MQTT服务器...")
 
 	s.cancel()
+	s.mu.Lock()
 	s.running = false
+	s.mu.Unlock()
 	s.logger.Debug("📊 服务器状态已更新", utils.Bool("running", s.running))
 
 	if s.server != nil {
@@ -211,6 +350,11 @@ func (s *Server) Stop() {
 		s.logger.Warn("⚠️ MQTT服务器实例为空，无需关闭")
 	}
 
+	// Broker关闭后不再有新消息，排空入库队列
+	if s.ingest != nil {
+		s.ingest.Stop()
+	}
+
 	s.logger.Info("🎯 MQTT服务器已完全停止",
 		utils.String("status", "stopped"),
 		utils.Bool("running", s.running))
@@ -218,10 +362,18 @@ func (s *Server) Stop() {
 
 // Publish 发布消息到MQTT服务器
 func (s *Server) Publish(topic string, payload interface{}) error {
-	if !s.running || s.server == nil {
-		s.logger.Error("❌ 无法发布消息：MQTT服务器未运行",
-			utils.Bool("running", s.running),
-			utils.Bool("server_exists", s.server != nil))
+	return s.publish(topic, payload, false)
+}
+
+// PublishRetained 发布保留消息，后连接的订阅者也能收到最新一条
+func (s *Server) PublishRetained(topic string, payload interface{}) error {
+	return s.publish(topic, payload, true)
+}
+
+// publish 序列化载荷并以QoS1发布
+func (s *Server) publish(topic string, payload interface{}, retain bool) error {
+	if !s.IsRunning() {
+		s.logger.Error("❌ 无法发布消息：MQTT服务器未运行", utils.String("topic", topic))
 		return fmt.Errorf("MQTT服务器未运行")
 	}
 
@@ -260,10 +412,10 @@ func (s *Server) Publish(topic string, payload interface{}) error {
 	s.logger.Debug("🚀 正在发布消息到MQTT服务器",
 		utils.String("topic", topic),
 		utils.Int("payload_size", len(data)),
-		utils.Bool("retain", false),
+		utils.Bool("retain", retain),
 		utils.Int("qos", 1))
 
-	if err := s.server.Publish(topic, data, false, 1); err != nil {
+	if err := s.server.Publish(topic, data, retain, 1); err != nil {
 		s.logger.Error("❌ 发布消息失败",
 			utils.String("topic", topic),
 			utils.Int("payload_size", len(data)),
@@ -281,6 +433,8 @@ func (s *Server) Publish(topic string, payload interface{}) error {
 
 // IsRunning 检查服务器是否运行中
 func (s *Server) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	running := s.running && s.server != nil
 	s.logger.Debug("🔍 检查服务器运行状态",
 		utils.Bool("running", s.running),
@@ -293,8 +447,9 @@ func (s *Server) IsRunning() bool {
 func (s *Server) GetStatus() map[string]interface{} {
 	s.logger.Debug("📊 获取MQTT服务器状态...")
 
+	running := s.IsRunning()
 	status := map[string]interface{}{
-		"running":   s.running,
+		"running":   running,
 		"connected": s.server != nil,
 		"address":   ":1883",
 		"client_id": s.config.ClientID,
@@ -304,9 +459,18 @@ func (s *Server) GetStatus() map[string]interface{} {
 		status["server_type"] = "mochi-mqtt"
 		status["listeners"] = s.server.Listeners
 	}
+	if s.authHook != nil {
+		status["auth"] = s.authHook.Stats()
+	}
+	if s.aclEngine != nil {
+		status["acl"] = s.aclEngine.Stats()
+	}
+	if s.ingest != nil {
+		status["ingest"] = s.ingest.Stats()
+	}
 
 	s.logger.Debug("📋 服务器状态信息",
-		utils.Bool("running", s.running),
+		utils.Bool("running", running),
 		utils.Bool("connected", s.server != nil),
 		utils.String("address", ":1883"),
 		utils.String("client_id", s.config.ClientID))
@@ -318,6 +482,9 @@ func (s *Server) GetStatus() map[string]interface{} {
 type MessageHandlerHook struct {
 	logger            utils.Logger
 	sensorDataHandler *SensorDataHandler
+	ingestPipeline    *IngestPipeline
+	presenceService   services.DevicePresenceService
+	serviceUsername   string
 }
 
 // ID 返回钩子ID
@@ -330,25 +497,24 @@ func (h *MessageHandlerHook) Provides(b byte) bool {
 	// 使用bytes.Contains方法检查事件是否在支持的事件列表中
 	// 这种方式更优雅，避免了硬编码的switch语句
 	return bytes.Contains([]byte{
-		mqtt.OnConnect,             // 客户端连接
-		mqtt.OnDisconnect,          // 客户端断开
-		mqtt.OnConnectAuthenticate, // 连接认证
-		mqtt.OnACLCheck,            // ACL检查
-		mqtt.OnSubscribe,           // 订阅
-		mqtt.OnSubscribed,          // 已订阅
-		mqtt.OnUnsubscribe,         // 取消订阅
-		mqtt.OnUnsubscribed,        // 已取消订阅
-		mqtt.OnPublish,             // 发布消息
-		mqtt.OnPublished,           // 消息已发布
-		mqtt.OnPublishDropped,      // 发布丢弃
-		mqtt.OnSysInfoTick,         // 系统信息定时器
-		mqtt.OnSessionEstablish,    // 会话建立
-		mqtt.OnSessionEstablished,  // 会话已建立
-		mqtt.OnQosPublish,          // QoS发布
-		mqtt.OnQosComplete,         // QoS完成
-		mqtt.OnQosDropped,          // QoS丢弃
-		mqtt.OnPacketIDExhausted,   // 包ID耗尽
-		mqtt.OnClientExpired,       // 客户端过期
+		mqtt.OnConnect,            // 客户端连接
+		mqtt.OnDisconnect,         // 客户端断开
+		mqtt.OnSubscribe,          // 订阅
+		mqtt.OnSubscribed,         // 已订阅
+		mqtt.OnUnsubscribe,        // 取消订阅
+		mqtt.OnUnsubscribed,       // 已取消订阅
+		mqtt.OnPublish,            // 发布消息
+		mqtt.OnPublished,          // 消息已发布
+		mqtt.OnPublishDropped,     // 发布丢弃
+		mqtt.OnSysInfoTick,        // 系统信息定时器
+		mqtt.OnSessionEstablish,   // 会话建立
+		mqtt.OnSessionEstablished, // 会话已建立
+		mqtt.OnQosPublish,         // QoS发布
+		mqtt.OnQosComplete,        // QoS完成
+		mqtt.OnQosDropped,         // QoS丢弃
+		mqtt.OnPacketIDExhausted,  // 包ID耗尽
+		mqtt.OnClientExpired,      // 客户端过期
+		mqtt.OnPacketRead,         // 包读取
 	}, []byte{b})
 }
 
@@ -377,6 +543,19 @@ func (h *MessageHandlerHook) Init(config interface{}) error {
 			return fmt.Errorf("未找到sensorDataHandler配置")
 		}
 
+		// 入库管道为可选配置，未提供时同步处理
+		if pipeline, ok := configMap["ingestPipeline"].(*IngestPipeline); ok {
+			h.ingestPipeline = pipeline
+		}
+
+		// 在线状态服务为可选配置，未提供时不跟踪设备上下线
+		if presence, ok := configMap["presenceService"].(services.DevicePresenceService); ok {
+			h.presenceService = presence
+		}
+		if username, ok := configMap["serviceUsername"].(string); ok {
+			h.serviceUsername = username
+		}
+
 		h.logger.Info("🔧 MQTT消息处理钩子已初始化",
 			utils.String("hook_id", h.ID()),
 			utils.String("description", "处理MQTT消息和事件"),
@@ -415,56 +594,14 @@ func (h *MessageHandlerHook) OnStopped() {
 	}
 }
 
-// OnConnectAuthenticate 连接认证
+// OnConnectAuthenticate 连接认证（由AuthHook负责，此钩子不参与认证）
 func (h *MessageHandlerHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
-	if h.logger != nil {
-		h.logger.Info("🔐 客户端认证请求",
-			utils.String("client_id", cl.ID),
-			utils.String("remote_addr", cl.Net.Remote),
-			utils.String("protocol_version", fmt.Sprintf("MQTT v%d", pk.ProtocolVersion)),
-			utils.Bool("clean_session", pk.Connect.Clean),
-			utils.Int("keep_alive", int(pk.Connect.Keepalive)))
-
-		// 记录连接包详细信息
-		if len(pk.Connect.Username) > 0 {
-			h.logger.Info("👤 客户端提供用户名",
-				utils.String("client_id", cl.ID),
-				utils.String("username", string(pk.Connect.Username)))
-		} else {
-			h.logger.Info("👤 客户端未提供用户名",
-				utils.String("client_id", cl.ID))
-		}
-		if len(pk.Connect.Password) > 0 {
-			h.logger.Info("🔑 客户端提供密码",
-				utils.String("client_id", cl.ID),
-				utils.Bool("has_password", true))
-		} else {
-			h.logger.Info("🔑 客户端未提供密码",
-				utils.String("client_id", cl.ID))
-		}
-	}
-
-	// 允许所有连接
-	result := true
-	if h.logger != nil {
-		h.logger.Info("✅ 客户端认证通过",
-			utils.String("client_id", cl.ID),
-			utils.Bool("authenticated", result),
-			utils.String("reason", "AllowHook - 允许所有连接"))
-	}
-	return result
+	return false
 }
 
-// OnACLCheck ACL检查（允许所有访问）
+// OnACLCheck ACL检查（由AuthHook负责，此钩子不参与）
 func (h *MessageHandlerHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
-	if h.logger != nil {
-		h.logger.Debug("MQTT ACL检查",
-			utils.String("client_id", cl.ID),
-			utils.String("topic", topic),
-			utils.Bool("write", write),
-			utils.String("action", map[bool]string{true: "发布", false: "订阅"}[write]))
-	}
-	return true
+	return false
 }
 
 // OnSysInfoTick 系统信息更新
@@ -526,6 +663,13 @@ func (h *MessageHandlerHook) OnSessionEstablished(cl *mqtt.Client, pk packets.Pa
 			utils.String("status", "ready"),
 			utils.String("message", "可以开始发送和接收消息"))
 	}
+
+	// OnConnect在认证之前触发，设备上线需在会话建立（认证通过）后记录
+	if deviceID, ok := h.presenceDeviceID(cl); ok {
+		if err := h.presenceService.MarkConnected(context.Background(), deviceID); err != nil && h.logger != nil {
+			h.logger.Error("❌ 记录设备上线失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+	}
 }
 
 // OnDisconnect 处理客户端断开连接
@@ -553,6 +697,28 @@ func (h *MessageHandlerHook) OnDisconnect(cl *mqtt.Client, err error, expire boo
 			utils.Bool("session_expired", expire),
 			utils.String("error_type", fmt.Sprintf("%T", err)))
 	}
+
+	// 同一客户端ID重连时旧连接被接管，不应将设备标记为断开
+	if cl.IsTakenOver() {
+		return
+	}
+	if deviceID, ok := h.presenceDeviceID(cl); ok {
+		if err := h.presenceService.MarkDisconnected(context.Background(), deviceID); err != nil && h.logger != nil {
+			h.logger.Error("❌ 记录设备断开失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+	}
+}
+
+// presenceDeviceID 获取需要跟踪在线状态的设备ID（跳过内联客户端和服务账号）
+func (h *MessageHandlerHook) presenceDeviceID(cl *mqtt.Client) (string, bool) {
+	if h.presenceService == nil || cl.Net.Inline {
+		return "", false
+	}
+	username := string(cl.Properties.Username)
+	if username == "" || username == h.serviceUsername {
+		return "", false
+	}
+	return username, true
 }
 
 // OnAuthPacket 认证包处理
@@ -560,8 +726,17 @@ func (h *MessageHandlerHook) OnAuthPacket(cl *mqtt.Client, pk packets.Packet) (p
 	return pk, nil
 }
 
-// OnPacketRead 包读取
+// OnPacketRead 包读取，设备发送的任意报文（含PINGREQ）都刷新心跳
 func (h *MessageHandlerHook) OnPacketRead(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	// CONNECT报文在认证之前读取，由OnSessionEstablished记录上线
+	if pk.FixedHeader.Type == packets.Connect {
+		return pk, nil
+	}
+	if deviceID, ok := h.presenceDeviceID(cl); ok {
+		if err := h.presenceService.Touch(context.Background(), deviceID); err != nil && h.logger != nil {
+			h.logger.Error("❌ 刷新设备心跳失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+	}
 	return pk, nil
 }
 
@@ -743,6 +918,12 @@ func (h *MessageHandlerHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (pack
 				utils.String("client_id", cl.ID),
 				utils.String("topic", pk.TopicName))
 
+			// 有入库管道时异步入队，避免阻塞Broker的报文处理协程
+			if h.ingestPipeline != nil {
+				h.ingestPipeline.Submit(pk.TopicName, pk.Payload)
+				return pk, nil
+			}
+
 			// 调用数据处理器处理消息
 			if err := h.sensorDataHandler.HandleMessage(pk.TopicName, pk.Payload); err != nil {
 				h.logger.Error("❌ 处理传感器数据失败",
@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSensorDataSpool_SpoolAndReplay 测试数据库不可用时落盘，恢复后按序回放
+func TestSensorDataSpool_SpoolAndReplay(t *testing.T) {
+	db := setupTestDatabase(t)
+
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	spool, err := utils.NewSensorDataSpool(dir, 0, logger)
+	require.NoError(t, err)
+
+	dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+	handler := NewSensorDataHandler(
+		dataRepo,
+		repositories.NewDeviceRepository(db, logger),
+		newTestAlertEvaluator(db, logger),
+		logger,
+	)
+	handler.SetSpool(spool)
+
+	// 删除数据表模拟数据库写入失败
+	require.NoError(t, db.Migrator().DropTable(&models.UnifiedSensorData{}))
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, handler.HandleMessage(ingestTopic, ingestPayload(t, TestDeviceID1, 0.01*float64(i))))
+	}
+	assert.Equal(t, int64(3), spool.Depth())
+	assert.Contains(t, spool.Stats(), "oldest_spooled_at")
+
+	// 重启后恢复待回放数据
+	require.NoError(t, spool.Close())
+	spool, err = utils.NewSensorDataSpool(dir, 0, logger)
+	require.NoError(t, err)
+	defer spool.Close()
+	assert.Equal(t, int64(3), spool.Depth())
+
+	// 数据库仍不可用时回放中断，数据保留
+	down := func() error { return errors.New("database down") }
+	n, err := spool.Replay(context.Background(), down, dataRepo.BatchInsert, 2)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(3), spool.Depth())
+
+	// 数据库恢复后按写入顺序回放
+	require.NoError(t, db.AutoMigrate(&models.UnifiedSensorData{}))
+	up := func() error { return nil }
+	n, err = spool.Replay(context.Background(), up, dataRepo.BatchInsert, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(0), spool.Depth())
+	assert.Equal(t, 0, spool.Stats()["segments"])
+
+	var data []models.UnifiedSensorData
+	require.NoError(t, db.Order("id ASC").Find(&data).Error)
+	require.Len(t, data, 3)
+	for i, d := range data {
+		assert.InDelta(t, 0.01*float64(i+1), *d.Formaldehyde, 1e-9)
+	}
+}
+
+// TestSensorDataSpool_TornTailAndDeadLetter 测试半行记录丢弃及无法写入记录转入死信
+func TestSensorDataSpool_TornTailAndDeadLetter(t *testing.T) {
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	spool, err := utils.NewSensorDataSpool(dir, 0, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, spool.Append([]models.UnifiedSensorData{
+		{DeviceID: TestDeviceID1},
+		{DeviceID: "poison"},
+		{DeviceID: TestDeviceID2},
+	}))
+	require.NoError(t, spool.Close())
+
+	// 模拟写入过程中进程退出留下的半行记录
+	segments, err := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"spooled_at":"2024-01-01T00:00:00Z","data":{"device_`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	spool, err = utils.NewSensorDataSpool(dir, 0, logger)
+	require.NoError(t, err)
+	defer spool.Close()
+	assert.Equal(t, int64(3), spool.Depth())
+
+	var written []string
+	write := func(ctx context.Context, data []models.UnifiedSensorData) error {
+		for _, d := range data {
+			if d.DeviceID == "poison" {
+				return errors.New("invalid record")
+			}
+		}
+		for _, d := range data {
+			written = append(written, d.DeviceID)
+		}
+		return nil
+	}
+
+	n, err := spool.Replay(context.Background(), func() error { return nil }, write, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{TestDeviceID1, TestDeviceID2}, written)
+	assert.Equal(t, int64(1), spool.Stats()["dead_lettered"])
+	assert.FileExists(t, filepath.Join(dir, "dead-letter.log"))
+}
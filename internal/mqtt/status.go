@@ -0,0 +1,65 @@
+package mqtt
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/services"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 设备状态主题参数
+const (
+	defaultDeviceStatusTopic   = "air-quality/+/+/status"
+	deviceStatusSubscriptionID = 1
+	deviceStatusTimeout        = 5 * time.Second
+)
+
+// DeviceStatusHandler 设备状态主题处理器
+// 设备连接后在 air-quality/{type}/{id}/status 发布保留的online消息，遗嘱消息为同一主题的offline消息
+type DeviceStatusHandler struct {
+	presence services.DevicePresenceService
+	logger   utils.Logger
+}
+
+// NewDeviceStatusHandler 创建设备状态主题处理器
+func NewDeviceStatusHandler(presence services.DevicePresenceService, logger utils.Logger) *DeviceStatusHandler {
+	return &DeviceStatusHandler{
+		presence: presence,
+		logger:   logger,
+	}
+}
+
+// HandleMessage 处理设备状态消息
+func (h *DeviceStatusHandler) HandleMessage(topic string, payload []byte) error {
+	deviceID := deviceIDFromTopic(topic)
+	if deviceID == "" {
+		return fmt.Errorf("无效的设备状态主题: %s", topic)
+	}
+	// 空载荷用于清除保留消息
+	if len(payload) == 0 {
+		return nil
+	}
+
+	var report models.DeviceStatusReport
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return fmt.Errorf("解析设备状态消息失败: %w", err)
+	}
+	if report.DeviceID != "" && report.DeviceID != deviceID {
+		return fmt.Errorf("设备ID与主题不匹配: %s", report.DeviceID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deviceStatusTimeout)
+	defer cancel()
+	if err := h.presence.ApplyStatusReport(ctx, deviceID, &report); err != nil {
+		return err
+	}
+
+	h.logger.Info("📶 设备状态已更新",
+		utils.String("device_id", deviceID),
+		utils.String("status", string(report.Status)),
+		utils.String("firmware_version", report.FirmwareVersion))
+	return nil
+}
@@ -1,6 +1,10 @@
 package repositories
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/utils"
 
@@ -14,10 +18,20 @@ type AlertRepository interface {
 	GetByStatus(status string) ([]models.Alert, error)
 	GetByType(alertType string) ([]models.Alert, error)
 	GetUnresolved() ([]models.Alert, error)
-	MarkAsResolved(alertID uint) error
+	Acknowledge(ctx context.Context, alertID uint64, userID *uint64, comment string, at time.Time) (*models.Alert, bool, error)
+	Resolve(ctx context.Context, alertID uint64, userID *uint64, comment string, at time.Time) (*models.Alert, bool, error)
 	GetByTimeRange(startTime, endTime int64) ([]models.Alert, error)
+	GetOpenRuleAlerts(ctx context.Context) ([]models.Alert, error)
+	GetOpenDeviceAlerts(ctx context.Context, deviceID, metric string) ([]models.Alert, error)
+	UpdateOpenValue(ctx context.Context, alertID uint64, value float64) (bool, error)
+	ResolveOpen(ctx context.Context, alertID uint64, value float64, resolvedAt time.Time) (bool, error)
+	GetHistory(ctx context.Context, alertID uint64) ([]models.AlertHistory, error)
+	GetHistories(ctx context.Context, alertIDs []uint64) (map[uint64][]models.AlertHistory, error)
 }
 
+// openAlertStatuses 未关闭的告警状态
+var openAlertStatuses = []string{string(models.AlertStatusActive), string(models.AlertStatusAcknowledged)}
+
 // alertRepository 告警仓储实现
 type alertRepository struct {
 	*baseRepository[models.Alert]
@@ -78,19 +92,102 @@ func (r *alertRepository) GetUnresolved() ([]models.Alert, error) {
 	return alerts, nil
 }
 
-// MarkAsResolved 标记告警为已解决
-func (r *alertRepository) MarkAsResolved(alertID uint) error {
-	err := r.db.Model(&models.Alert{}).Where("id = ?", alertID).Updates(map[string]interface{}{
-		"status":      "resolved",
-		"resolved_at": "NOW()",
-	}).Error
+// Create 创建告警并记录触发历史
+func (r *alertRepository) Create(ctx context.Context, alert *models.Alert) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(alert).Error; err != nil {
+			return err
+		}
+		value := alert.CurrentValue
+		return tx.Create(&models.AlertHistory{
+			AlertID:   alert.ID,
+			Action:    models.AlertActionTriggered,
+			ToStatus:  alert.Status,
+			Value:     &value,
+			CreatedAt: alert.TriggeredAt,
+		}).Error
+	})
 	if err != nil {
-		r.logger.Error("标记告警为已解决失败", utils.ErrorField(err), utils.Int("alert_id", int(alertID)))
+		r.logger.Error("创建告警失败", utils.ErrorField(err))
 		return err
 	}
 	return nil
 }
 
+// Acknowledge 确认待处理的告警，返回告警当前状态及是否实际更新（告警不存在时返回nil）
+func (r *alertRepository) Acknowledge(ctx context.Context, alertID uint64, userID *uint64, comment string, at time.Time) (*models.Alert, bool, error) {
+	return r.transition(ctx, alertID, []string{string(models.AlertStatusActive)}, map[string]interface{}{
+		"status":          string(models.AlertStatusAcknowledged),
+		"acknowledged_at": at,
+		"acknowledged_by": userID,
+	}, &models.AlertHistory{
+		Action:    models.AlertActionAcknowledged,
+		ToStatus:  string(models.AlertStatusAcknowledged),
+		UserID:    userID,
+		Comment:   optionalComment(comment),
+		CreatedAt: at,
+	})
+}
+
+// Resolve 人工解决未关闭的告警，返回告警当前状态及是否实际更新（告警不存在时返回nil）
+func (r *alertRepository) Resolve(ctx context.Context, alertID uint64, userID *uint64, comment string, at time.Time) (*models.Alert, bool, error) {
+	return r.transition(ctx, alertID, openAlertStatuses, map[string]interface{}{
+		"status":      string(models.AlertStatusResolved),
+		"resolved_at": at,
+		"resolved_by": userID,
+	}, &models.AlertHistory{
+		Action:    models.AlertActionResolved,
+		ToStatus:  string(models.AlertStatusResolved),
+		UserID:    userID,
+		Comment:   optionalComment(comment),
+		CreatedAt: at,
+	})
+}
+
+// transition 在事务中按当前状态更新告警并记录变更历史
+func (r *alertRepository) transition(ctx context.Context, alertID uint64, from []string, updates map[string]interface{}, history *models.AlertHistory) (*models.Alert, bool, error) {
+	var alert models.Alert
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&alert, alertID).Error; err != nil {
+			return err
+		}
+		if !containsStatus(from, alert.Status) {
+			return nil
+		}
+
+		// 以读取到的状态为条件更新，避免并发操作重复变更
+		result := tx.Model(&models.Alert{}).
+			Where("id = ? AND status = ?", alertID, alert.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		history.AlertID = alertID
+		history.FromStatus = alert.Status
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		changed = true
+		return tx.First(&alert, alertID).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		r.logger.Error("更新告警状态失败",
+			utils.ErrorField(err),
+			utils.Int64("alert_id", int64(alertID)),
+			utils.String("action", string(history.Action)))
+		return nil, false, err
+	}
+	return &alert, changed, nil
+}
+
 // GetByTimeRange 根据时间范围获取告警
 func (r *alertRepository) GetByTimeRange(startTime, endTime int64) ([]models.Alert, error) {
 	var alerts []models.Alert
@@ -101,3 +198,121 @@ func (r *alertRepository) GetByTimeRange(startTime, endTime int64) ([]models.Ale
 	}
 	return alerts, nil
 }
+
+// GetOpenRuleAlerts 获取由告警规则产生且未关闭的告警
+func (r *alertRepository) GetOpenRuleAlerts(ctx context.Context) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.WithContext(ctx).
+		Where("rule_id > 0 AND status IN ?", openAlertStatuses).
+		Order("triggered_at ASC").
+		Find(&alerts).Error
+	if err != nil {
+		r.logger.Error("获取未关闭的规则告警失败", utils.ErrorField(err))
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetOpenDeviceAlerts 获取设备指定指标未关闭的告警
+func (r *alertRepository) GetOpenDeviceAlerts(ctx context.Context, deviceID, metric string) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.WithContext(ctx).
+		Where("device_id = ? AND metric = ? AND status IN ?", deviceID, metric, openAlertStatuses).
+		Order("triggered_at ASC").
+		Find(&alerts).Error
+	if err != nil {
+		r.logger.Error("获取设备未关闭的告警失败", utils.ErrorField(err), utils.String("device_id", deviceID))
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// UpdateOpenValue 更新未关闭告警的当前值，返回告警是否仍未关闭
+func (r *alertRepository) UpdateOpenValue(ctx context.Context, alertID uint64, value float64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ? AND status IN ?", alertID, openAlertStatuses).
+		Update("current_value", value)
+	if result.Error != nil {
+		r.logger.Error("更新告警当前值失败", utils.ErrorField(result.Error), utils.Int64("alert_id", int64(alertID)))
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// MySQL对值未变化的行不计入影响行数，需再次确认告警状态
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ? AND status IN ?", alertID, openAlertStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ResolveOpen 自动解决未关闭的告警，返回是否实际更新
+func (r *alertRepository) ResolveOpen(ctx context.Context, alertID uint64, value float64, resolvedAt time.Time) (bool, error) {
+	_, changed, err := r.transition(ctx, alertID, openAlertStatuses, map[string]interface{}{
+		"status":        string(models.AlertStatusResolved),
+		"current_value": value,
+		"resolved_at":   resolvedAt,
+	}, &models.AlertHistory{
+		Action:    models.AlertActionAutoResolved,
+		ToStatus:  string(models.AlertStatusResolved),
+		Value:     &value,
+		CreatedAt: resolvedAt,
+	})
+	return changed, err
+}
+
+// GetHistory 获取告警的状态变更历史（按时间正序）
+func (r *alertRepository) GetHistory(ctx context.Context, alertID uint64) ([]models.AlertHistory, error) {
+	var histories []models.AlertHistory
+	err := r.db.WithContext(ctx).
+		Where("alert_id = ?", alertID).
+		Order("created_at ASC, id ASC").
+		Find(&histories).Error
+	if err != nil {
+		r.logger.Error("获取告警历史失败", utils.ErrorField(err), utils.Int64("alert_id", int64(alertID)))
+		return nil, err
+	}
+	return histories, nil
+}
+
+// GetHistories 批量获取多个告警的状态变更历史
+func (r *alertRepository) GetHistories(ctx context.Context, alertIDs []uint64) (map[uint64][]models.AlertHistory, error) {
+	result := make(map[uint64][]models.AlertHistory, len(alertIDs))
+	if len(alertIDs) == 0 {
+		return result, nil
+	}
+
+	var histories []models.AlertHistory
+	err := r.db.WithContext(ctx).
+		Where("alert_id IN ?", alertIDs).
+		Order("created_at ASC, id ASC").
+		Find(&histories).Error
+	if err != nil {
+		r.logger.Error("批量获取告警历史失败", utils.ErrorField(err))
+		return nil, err
+	}
+	for _, history := range histories {
+		result[history.AlertID] = append(result[history.AlertID], history)
+	}
+	return result, nil
+}
+
+// containsStatus 判断状态是否在列表中
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// optionalComment 空备注记为NULL
+func optionalComment(comment string) *string {
+	if comment == "" {
+		return nil
+	}
+	return &comment
+}
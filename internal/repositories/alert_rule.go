@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// AlertRuleRepository 告警规则仓储接口
+type AlertRuleRepository interface {
+	BaseRepository[models.AlertRule]
+	GetEnabled(ctx context.Context) ([]models.AlertRule, error)
+}
+
+// alertRuleRepository 告警规则仓储实现
+type alertRuleRepository struct {
+	*baseRepository[models.AlertRule]
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewAlertRuleRepository 创建告警规则仓储
+func NewAlertRuleRepository(db *gorm.DB, logger utils.Logger) AlertRuleRepository {
+	return &alertRuleRepository{
+		baseRepository: NewBaseRepository[models.AlertRule](db, logger).(*baseRepository[models.AlertRule]),
+		db:             db,
+		logger:         logger,
+	}
+}
+
+// GetEnabled 获取所有启用的告警规则
+func (r *alertRuleRepository) GetEnabled(ctx context.Context) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	if err := r.db.WithContext(ctx).Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		r.logger.Error("获取启用的告警规则失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("获取告警规则失败: %w", err)
+	}
+	return rules, nil
+}
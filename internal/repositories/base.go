@@ -18,6 +18,7 @@ type BaseRepository[T any] interface {
 	Delete(ctx context.Context, id interface{}) error
 	List(ctx context.Context, req *ListRequest) (*ListResponse[T], error)
 	Count(ctx context.Context, conditions map[string]interface{}) (int64, error)
+	Transaction(ctx context.Context, fn func(*gorm.DB) error) error
 }
 
 // ListRequest 列表请求
@@ -16,11 +16,28 @@ type DeviceRepository interface {
 	BaseRepository[models.Device]
 	GetByDeviceID(ctx context.Context, deviceID string) (*models.Device, error)
 	UpdateStatus(ctx context.Context, deviceID string, status string) error
+	TransitionStatus(ctx context.Context, deviceID string, from, to models.DeviceStatus) (bool, error)
 	GetRealtimeStatus(ctx context.Context, deviceID string) (*models.DeviceRealtimeStatus, error)
 	GetRealtimeStatusList(ctx context.Context, req *models.DeviceListRequest) (*ListResponse[models.DeviceRealtimeStatus], error)
 	GetStatistics(ctx context.Context, deviceID string, startTime, endTime time.Time) (*models.DeviceStatistics, error)
 	GetOnlineDevices(ctx context.Context) ([]models.Device, error)
 	GetOfflineDevices(ctx context.Context, duration time.Duration) ([]models.Device, error)
+	ListByType(ctx context.Context, deviceType models.DeviceType) ([]models.Device, error)
+	ListAll(ctx context.Context) ([]models.Device, error)
+	// FindExistingIDs 返回已被占用的设备ID（包括已软删除的设备）
+	FindExistingIDs(ctx context.Context, ids []string) ([]string, error)
+	// CreateBatch 在单个事务中批量创建设备
+	CreateBatch(ctx context.Context, devices []models.Device) error
+	// ListByIDs 获取指定ID的设备（不含已删除设备）
+	ListByIDs(ctx context.Context, ids []string) ([]models.Device, error)
+	// Query 按类型、状态分页查询设备，deviceIDs不为nil时只在其中查询
+	Query(ctx context.Context, req *models.DeviceQueryRequest, deviceIDs []string) ([]models.Device, int64, error)
+	// SetLocation 设置设备所属位置
+	SetLocation(ctx context.Context, deviceIDs []string, locationID uint64) (int64, error)
+	// UnsetLocation 解除位于指定节点上的设备与该节点的关联
+	UnsetLocation(ctx context.Context, locationID uint64, deviceIDs []string) (int64, error)
+	// ListIDsByLocations 获取位于指定节点上的设备ID
+	ListIDsByLocations(ctx context.Context, locationIDs []uint64) ([]string, error)
 }
 
 // deviceRepository 设备仓储实现
@@ -62,6 +79,18 @@ func (r *deviceRepository) UpdateStatus(ctx context.Context, deviceID string, st
 	return nil
 }
 
+// TransitionStatus 仅当设备处于from状态时更新为to状态，返回是否实际变更
+func (r *deviceRepository) TransitionStatus(ctx context.Context, deviceID string, from, to models.DeviceStatus) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Device{}).
+		Where("id = ? AND status = ?", deviceID, from).
+		Update("status", to)
+	if result.Error != nil {
+		r.logger.Error("更新设备状态失败", utils.String("device_id", deviceID), utils.String("status", string(to)), utils.ErrorField(result.Error))
+		return false, fmt.Errorf("更新设备状态失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 // GetRealtimeStatus 获取设备实时状态
 func (r *deviceRepository) GetRealtimeStatus(ctx context.Context, deviceID string) (*models.DeviceRealtimeStatus, error) {
 	var status models.DeviceRealtimeStatus
@@ -223,29 +252,146 @@ func (r *deviceRepository) GetOnlineDevices(ctx context.Context) ([]models.Devic
 	return devices, nil
 }
 
-// GetOfflineDevices 获取离线设备
+// GetOfflineDevices 获取状态为在线但超过指定时间没有心跳或数据的设备
 func (r *deviceRepository) GetOfflineDevices(ctx context.Context, duration time.Duration) ([]models.Device, error) {
 	var devices []models.Device
 
-	// 查询超过指定时间没有数据的设备
-	query := `
-		SELECT d.* FROM devices d
-		LEFT JOIN (
-			SELECT device_id, MAX(timestamp) as last_timestamp
-			FROM air_quality_data
-			GROUP BY device_id
-		) aqd ON d.id = aqd.device_id
-		WHERE d.status = 'online' AND (
-			aqd.last_timestamp IS NULL OR 
-			aqd.last_timestamp < ?
-		)
-	`
-
 	cutoffTime := time.Now().Add(-duration)
-	if err := r.db.WithContext(ctx).Raw(query, cutoffTime).Scan(&devices).Error; err != nil {
+	err := r.db.WithContext(ctx).
+		Joins("LEFT JOIN device_runtime_status rs ON rs.device_id = devices.id").
+		Where("devices.status = ?", models.DeviceStatusOnline).
+		Where("rs.id IS NULL OR rs.last_heartbeat < ?", cutoffTime).
+		Find(&devices).Error
+	if err != nil {
 		r.logger.Error("获取离线设备失败", utils.ErrorField(err))
 		return nil, fmt.Errorf("获取离线设备失败: %w", err)
 	}
 
 	return devices, nil
 }
+
+// ListByType 获取指定类型的全部设备
+func (r *deviceRepository) ListByType(ctx context.Context, deviceType models.DeviceType) ([]models.Device, error) {
+	var devices []models.Device
+	if err := r.db.WithContext(ctx).Where("type = ?", deviceType).Order("id").Find(&devices).Error; err != nil {
+		r.logger.Error("按类型获取设备失败", utils.String("device_type", string(deviceType)), utils.ErrorField(err))
+		return nil, fmt.Errorf("按类型获取设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// ListAll 获取全部设备（按ID排序）
+func (r *deviceRepository) ListAll(ctx context.Context) ([]models.Device, error) {
+	var devices []models.Device
+	if err := r.db.WithContext(ctx).Order("id").Find(&devices).Error; err != nil {
+		r.logger.Error("获取全部设备失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("获取全部设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// FindExistingIDs 查询已占用的设备ID
+func (r *deviceRepository) FindExistingIDs(ctx context.Context, ids []string) ([]string, error) {
+	var existing []string
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	if err := r.db.WithContext(ctx).Unscoped().Model(&models.Device{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		r.logger.Error("查询已存在的设备ID失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询已存在的设备ID失败: %w", err)
+	}
+	return existing, nil
+}
+
+// CreateBatch 批量创建设备，任一设备失败时整体回滚
+func (r *deviceRepository) CreateBatch(ctx context.Context, devices []models.Device) error {
+	err := r.Transaction(ctx, func(tx *gorm.DB) error {
+		return tx.CreateInBatches(devices, 100).Error
+	})
+	if err != nil {
+		r.logger.Error("批量创建设备失败", utils.Int("count", len(devices)), utils.ErrorField(err))
+		return fmt.Errorf("批量创建设备失败: %w", err)
+	}
+	return nil
+}
+
+// ListByIDs 按ID批量获取设备
+func (r *deviceRepository) ListByIDs(ctx context.Context, ids []string) ([]models.Device, error) {
+	var devices []models.Device
+	if len(ids) == 0 {
+		return devices, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&devices).Error; err != nil {
+		r.logger.Error("按ID获取设备失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("按ID获取设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// Query 分页查询设备（按ID排序）
+func (r *deviceRepository) Query(ctx context.Context, req *models.DeviceQueryRequest, deviceIDs []string) ([]models.Device, int64, error) {
+	var devices []models.Device
+	var total int64
+	if deviceIDs != nil && len(deviceIDs) == 0 {
+		return devices, 0, nil
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.Device{})
+	if deviceIDs != nil {
+		query = query.Where("id IN ?", deviceIDs)
+	}
+	if req.Type != "" {
+		query = query.Where("type = ?", req.Type)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error("计算设备总数失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("计算设备总数失败: %w", err)
+	}
+	if req.Limit > 0 {
+		query = query.Limit(req.Limit).Offset(req.Offset)
+	}
+	if err := query.Order("id").Find(&devices).Error; err != nil {
+		r.logger.Error("查询设备列表失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("查询设备列表失败: %w", err)
+	}
+	return devices, total, nil
+}
+
+// SetLocation 批量设置设备位置，返回实际更新数量
+func (r *deviceRepository) SetLocation(ctx context.Context, deviceIDs []string, locationID uint64) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Device{}).Where("id IN ?", deviceIDs).Update("location_id", locationID)
+	if result.Error != nil {
+		r.logger.Error("设置设备位置失败", utils.Int64("location_id", int64(locationID)), utils.ErrorField(result.Error))
+		return 0, fmt.Errorf("设置设备位置失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// UnsetLocation 仅清空当前位于该节点的设备，返回实际更新数量
+func (r *deviceRepository) UnsetLocation(ctx context.Context, locationID uint64, deviceIDs []string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Device{}).
+		Where("location_id = ? AND id IN ?", locationID, deviceIDs).
+		Update("location_id", nil)
+	if result.Error != nil {
+		r.logger.Error("解除设备位置失败", utils.Int64("location_id", int64(locationID)), utils.ErrorField(result.Error))
+		return 0, fmt.Errorf("解除设备位置失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ListIDsByLocations 按位置节点获取设备ID（按ID排序）
+func (r *deviceRepository) ListIDsByLocations(ctx context.Context, locationIDs []uint64) ([]string, error) {
+	var ids []string
+	if len(locationIDs) == 0 {
+		return ids, nil
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Device{}).Where("location_id IN ?", locationIDs).Order("id").Pluck("id", &ids).Error; err != nil {
+		r.logger.Error("按位置获取设备失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("按位置获取设备失败: %w", err)
+	}
+	return ids, nil
+}
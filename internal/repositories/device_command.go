@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// DeviceCommandRepository 设备指令仓储接口
+type DeviceCommandRepository interface {
+	Create(ctx context.Context, command *models.DeviceCommand) error
+	GetByCommandID(ctx context.Context, commandID string) (*models.DeviceCommand, error)
+	ListByDevice(ctx context.Context, deviceID string, req *models.DeviceCommandListRequest) ([]models.DeviceCommand, int64, error)
+	Complete(ctx context.Context, commandID string, status models.DeviceCommandStatus, result, errorMessage *string, at time.Time) (bool, error)
+	ExpirePending(ctx context.Context, before time.Time) (int64, error)
+}
+
+// deviceCommandRepository 设备指令仓储实现
+type deviceCommandRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceCommandRepository 创建设备指令仓储
+func NewDeviceCommandRepository(db *gorm.DB, logger utils.Logger) DeviceCommandRepository {
+	return &deviceCommandRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 创建指令记录
+func (r *deviceCommandRepository) Create(ctx context.Context, command *models.DeviceCommand) error {
+	if err := r.db.WithContext(ctx).Create(command).Error; err != nil {
+		r.logger.Error("创建设备指令失败", utils.String("device_id", command.DeviceID), utils.ErrorField(err))
+		return fmt.Errorf("创建设备指令失败: %w", err)
+	}
+	return nil
+}
+
+// GetByCommandID 根据关联ID获取指令，不存在时返回nil
+func (r *deviceCommandRepository) GetByCommandID(ctx context.Context, commandID string) (*models.DeviceCommand, error) {
+	var command models.DeviceCommand
+	if err := r.db.WithContext(ctx).Where("command_id = ?", commandID).First(&command).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备指令失败", utils.String("command_id", commandID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备指令失败: %w", err)
+	}
+	return &command, nil
+}
+
+// ListByDevice 分页查询设备的指令历史（按下发时间倒序）
+func (r *deviceCommandRepository) ListByDevice(ctx context.Context, deviceID string, req *models.DeviceCommandListRequest) ([]models.DeviceCommand, int64, error) {
+	var commands []models.DeviceCommand
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.DeviceCommand{}).Where("device_id = ?", deviceID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error("统计设备指令数量失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("统计设备指令数量失败: %w", err)
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(req.PageSize).Find(&commands).Error; err != nil {
+		r.logger.Error("查询设备指令历史失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("查询设备指令历史失败: %w", err)
+	}
+	return commands, total, nil
+}
+
+// Complete 结束等待中的指令，返回是否实际更新（已结束的指令不再变更）
+func (r *deviceCommandRepository) Complete(ctx context.Context, commandID string, status models.DeviceCommandStatus, result, errorMessage *string, at time.Time) (bool, error) {
+	updates := map[string]interface{}{
+		"status":     status,
+		"updated_at": at,
+	}
+	if status != models.DeviceCommandStatusTimedOut {
+		updates["responded_at"] = at
+	}
+	if result != nil {
+		updates["result"] = *result
+	}
+	if errorMessage != nil {
+		updates["error_message"] = *errorMessage
+	}
+
+	res := r.db.WithContext(ctx).Model(&models.DeviceCommand{}).
+		Where("command_id = ? AND status = ?", commandID, models.DeviceCommandStatusPending).
+		UpdateColumns(updates)
+	if res.Error != nil {
+		r.logger.Error("更新设备指令状态失败", utils.String("command_id", commandID), utils.ErrorField(res.Error))
+		return false, fmt.Errorf("更新设备指令状态失败: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// ExpirePending 将超过截止时间仍未响应的指令标记为超时
+func (r *deviceCommandRepository) ExpirePending(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&models.DeviceCommand{}).
+		Where("status = ? AND expires_at < ?", models.DeviceCommandStatusPending, before).
+		UpdateColumns(map[string]interface{}{
+			"status":     models.DeviceCommandStatusTimedOut,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		r.logger.Error("标记超时设备指令失败", utils.ErrorField(res.Error))
+		return 0, fmt.Errorf("标记超时设备指令失败: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
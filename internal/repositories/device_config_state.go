@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceConfigStateRepository 设备配置同步状态仓储接口
+type DeviceConfigStateRepository interface {
+	GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceConfigState, error)
+	ListWithDesired(ctx context.Context) ([]models.DeviceConfigState, error)
+	SaveDesired(ctx context.Context, deviceID, desired string, at time.Time) (*models.DeviceConfigState, error)
+	SaveReported(ctx context.Context, deviceID, reported string, version int64, at time.Time) error
+}
+
+// deviceConfigStateRepository 设备配置同步状态仓储实现
+type deviceConfigStateRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceConfigStateRepository 创建设备配置同步状态仓储
+func NewDeviceConfigStateRepository(db *gorm.DB, logger utils.Logger) DeviceConfigStateRepository {
+	return &deviceConfigStateRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByDeviceID 获取设备配置同步状态，不存在时返回nil
+func (r *deviceConfigStateRepository) GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceConfigState, error) {
+	var state models.DeviceConfigState
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备配置状态失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备配置状态失败: %w", err)
+	}
+	return &state, nil
+}
+
+// ListWithDesired 获取所有设置过期望配置的设备
+func (r *deviceConfigStateRepository) ListWithDesired(ctx context.Context) ([]models.DeviceConfigState, error) {
+	var states []models.DeviceConfigState
+	if err := r.db.WithContext(ctx).Where("desired_version > 0").Find(&states).Error; err != nil {
+		r.logger.Error("查询设备期望配置失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询设备期望配置失败: %w", err)
+	}
+	return states, nil
+}
+
+// SaveDesired 写入期望配置并递增版本，返回最新状态
+func (r *deviceConfigStateRepository) SaveDesired(ctx context.Context, deviceID, desired string, at time.Time) (*models.DeviceConfigState, error) {
+	state := &models.DeviceConfigState{
+		DeviceID:       deviceID,
+		Desired:        &desired,
+		DesiredVersion: 1,
+		DesiredAt:      &at,
+	}
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"desired":         desired,
+				"desired_version": gorm.Expr("desired_version + 1"),
+				"desired_at":      at,
+				"updated_at":      at,
+			}),
+		}).
+		Create(state).Error
+	if err != nil {
+		r.logger.Error("保存设备期望配置失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("保存设备期望配置失败: %w", err)
+	}
+	return r.GetByDeviceID(ctx, deviceID)
+}
+
+// SaveReported 写入设备上报的已应用配置
+func (r *deviceConfigStateRepository) SaveReported(ctx context.Context, deviceID, reported string, version int64, at time.Time) error {
+	state := &models.DeviceConfigState{
+		DeviceID:        deviceID,
+		Reported:        &reported,
+		ReportedVersion: version,
+		ReportedAt:      &at,
+	}
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reported", "reported_version", "reported_at", "updated_at"}),
+		}).
+		Create(state).Error
+	if err != nil {
+		r.logger.Error("保存设备上报配置失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return fmt.Errorf("保存设备上报配置失败: %w", err)
+	}
+	return nil
+}
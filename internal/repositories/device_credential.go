@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// DeviceCredentialRepository 设备凭证仓储接口
+type DeviceCredentialRepository interface {
+	BaseRepository[models.DeviceCredential]
+	GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceCredential, error)
+	Upsert(ctx context.Context, credential *models.DeviceCredential) error
+	Revoke(ctx context.Context, deviceID string) error
+	TouchLastUsed(ctx context.Context, deviceID string) error
+}
+
+// deviceCredentialRepository 设备凭证仓储实现
+type deviceCredentialRepository struct {
+	*baseRepository[models.DeviceCredential]
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceCredentialRepository 创建设备凭证仓储
+func NewDeviceCredentialRepository(db *gorm.DB, logger utils.Logger) DeviceCredentialRepository {
+	return &deviceCredentialRepository{
+		baseRepository: NewBaseRepository[models.DeviceCredential](db, logger).(*baseRepository[models.DeviceCredential]),
+		db:             db,
+		logger:         logger,
+	}
+}
+
+// GetByDeviceID 根据设备ID获取凭证，不存在时返回nil
+func (r *deviceCredentialRepository) GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceCredential, error) {
+	var credential models.DeviceCredential
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&credential).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.Error("根据设备ID获取凭证失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备凭证失败: %w", err)
+	}
+	return &credential, nil
+}
+
+// Upsert 创建或覆盖设备凭证（每台设备仅保留一份凭证）
+func (r *deviceCredentialRepository) Upsert(ctx context.Context, credential *models.DeviceCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.DeviceCredential
+		err := tx.Where("device_id = ?", credential.DeviceID).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			if err := tx.Create(credential).Error; err != nil {
+				r.logger.Error("创建设备凭证失败", utils.String("device_id", credential.DeviceID), utils.ErrorField(err))
+				return fmt.Errorf("创建设备凭证失败: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("查询设备凭证失败: %w", err)
+		}
+
+		credential.ID = existing.ID
+		credential.CreatedAt = existing.CreatedAt
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"secret_hash":  credential.SecretHash,
+			"status":       credential.Status,
+			"issued_at":    credential.IssuedAt,
+			"revoked_at":   nil,
+			"last_used_at": nil,
+		}).Error; err != nil {
+			r.logger.Error("更新设备凭证失败", utils.String("device_id", credential.DeviceID), utils.ErrorField(err))
+			return fmt.Errorf("更新设备凭证失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// Revoke 吊销设备凭证
+func (r *deviceCredentialRepository) Revoke(ctx context.Context, deviceID string) error {
+	result := r.db.WithContext(ctx).Model(&models.DeviceCredential{}).
+		Where("device_id = ? AND status = ?", deviceID, models.DeviceCredentialStatusActive).
+		Updates(map[string]interface{}{
+			"status":     models.DeviceCredentialStatusRevoked,
+			"revoked_at": time.Now(),
+		})
+	if result.Error != nil {
+		r.logger.Error("吊销设备凭证失败", utils.String("device_id", deviceID), utils.ErrorField(result.Error))
+		return fmt.Errorf("吊销设备凭证失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("设备凭证不存在或已吊销")
+	}
+	return nil
+}
+
+// TouchLastUsed 更新凭证最后认证时间
+func (r *deviceCredentialRepository) TouchLastUsed(ctx context.Context, deviceID string) error {
+	if err := r.db.WithContext(ctx).Model(&models.DeviceCredential{}).
+		Where("device_id = ?", deviceID).
+		Update("last_used_at", time.Now()).Error; err != nil {
+		r.logger.Error("更新凭证使用时间失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return fmt.Errorf("更新凭证使用时间失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceGroupRepository 设备分组仓储接口
+type DeviceGroupRepository interface {
+	Create(ctx context.Context, group *models.DeviceGroup) error
+	GetByID(ctx context.Context, id uint64) (*models.DeviceGroup, error)
+	GetByName(ctx context.Context, name string) (*models.DeviceGroup, error)
+	// List 获取全部分组并统计成员数量
+	List(ctx context.Context) ([]models.DeviceGroup, error)
+	Update(ctx context.Context, group *models.DeviceGroup) error
+	// Delete 删除分组及其成员关系
+	Delete(ctx context.Context, id uint64) error
+	// AddMembers 添加成员，已存在的成员忽略
+	AddMembers(ctx context.Context, groupID uint64, deviceIDs []string) error
+	RemoveMembers(ctx context.Context, groupID uint64, deviceIDs []string) (int64, error)
+	ListMemberIDs(ctx context.Context, groupID uint64) ([]string, error)
+	// ListGroupIDsByDevice 获取设备所属的全部分组ID
+	ListGroupIDsByDevice(ctx context.Context, deviceID string) ([]uint64, error)
+}
+
+// deviceGroupRepository 设备分组仓储实现
+type deviceGroupRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceGroupRepository 创建设备分组仓储
+func NewDeviceGroupRepository(db *gorm.DB, logger utils.Logger) DeviceGroupRepository {
+	return &deviceGroupRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 创建分组
+func (r *deviceGroupRepository) Create(ctx context.Context, group *models.DeviceGroup) error {
+	if err := r.db.WithContext(ctx).Create(group).Error; err != nil {
+		r.logger.Error("创建设备分组失败", utils.String("name", group.Name), utils.ErrorField(err))
+		return fmt.Errorf("创建设备分组失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据ID获取分组，不存在时返回nil
+func (r *deviceGroupRepository) GetByID(ctx context.Context, id uint64) (*models.DeviceGroup, error) {
+	var group models.DeviceGroup
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备分组失败", utils.Int64("group_id", int64(id)), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备分组失败: %w", err)
+	}
+	return &group, nil
+}
+
+// GetByName 根据名称获取分组，不存在时返回nil
+func (r *deviceGroupRepository) GetByName(ctx context.Context, name string) (*models.DeviceGroup, error) {
+	var group models.DeviceGroup
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备分组失败", utils.String("name", name), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备分组失败: %w", err)
+	}
+	return &group, nil
+}
+
+// List 获取全部分组（按名称排序）
+func (r *deviceGroupRepository) List(ctx context.Context) ([]models.DeviceGroup, error) {
+	var groups []models.DeviceGroup
+	if err := r.db.WithContext(ctx).Order("name").Find(&groups).Error; err != nil {
+		r.logger.Error("查询设备分组列表失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询设备分组列表失败: %w", err)
+	}
+
+	var counts []struct {
+		GroupID uint64
+		Total   int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.DeviceGroupMember{}).
+		Select("group_id, COUNT(*) AS total").
+		Group("group_id").
+		Scan(&counts).Error
+	if err != nil {
+		r.logger.Error("统计分组成员失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("统计分组成员失败: %w", err)
+	}
+	totals := make(map[uint64]int64, len(counts))
+	for _, c := range counts {
+		totals[c.GroupID] = c.Total
+	}
+	for i := range groups {
+		groups[i].DeviceCount = totals[groups[i].ID]
+	}
+	return groups, nil
+}
+
+// Update 更新分组名称和描述
+func (r *deviceGroupRepository) Update(ctx context.Context, group *models.DeviceGroup) error {
+	if err := r.db.WithContext(ctx).Model(group).Select("name", "description").Updates(group).Error; err != nil {
+		r.logger.Error("更新设备分组失败", utils.Int64("group_id", int64(group.ID)), utils.ErrorField(err))
+		return fmt.Errorf("更新设备分组失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除分组
+func (r *deviceGroupRepository) Delete(ctx context.Context, id uint64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.DeviceGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.DeviceGroup{}).Error
+	})
+	if err != nil {
+		r.logger.Error("删除设备分组失败", utils.Int64("group_id", int64(id)), utils.ErrorField(err))
+		return fmt.Errorf("删除设备分组失败: %w", err)
+	}
+	return nil
+}
+
+// AddMembers 批量添加成员
+func (r *deviceGroupRepository) AddMembers(ctx context.Context, groupID uint64, deviceIDs []string) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	members := make([]models.DeviceGroupMember, 0, len(deviceIDs))
+	for _, id := range deviceIDs {
+		members = append(members, models.DeviceGroupMember{GroupID: groupID, DeviceID: id})
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+		r.logger.Error("添加分组成员失败", utils.Int64("group_id", int64(groupID)), utils.ErrorField(err))
+		return fmt.Errorf("添加分组成员失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveMembers 批量移除成员，返回实际移除数量
+func (r *deviceGroupRepository) RemoveMembers(ctx context.Context, groupID uint64, deviceIDs []string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("group_id = ? AND device_id IN ?", groupID, deviceIDs).Delete(&models.DeviceGroupMember{})
+	if result.Error != nil {
+		r.logger.Error("移除分组成员失败", utils.Int64("group_id", int64(groupID)), utils.ErrorField(result.Error))
+		return 0, fmt.Errorf("移除分组成员失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ListMemberIDs 获取分组成员设备ID（按ID排序）
+func (r *deviceGroupRepository) ListMemberIDs(ctx context.Context, groupID uint64) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&models.DeviceGroupMember{}).
+		Where("group_id = ?", groupID).
+		Order("device_id").
+		Pluck("device_id", &ids).Error
+	if err != nil {
+		r.logger.Error("查询分组成员失败", utils.Int64("group_id", int64(groupID)), utils.ErrorField(err))
+		return nil, fmt.Errorf("查询分组成员失败: %w", err)
+	}
+	return ids, nil
+}
+
+// ListGroupIDsByDevice 获取设备所属分组
+func (r *deviceGroupRepository) ListGroupIDsByDevice(ctx context.Context, deviceID string) ([]uint64, error) {
+	var ids []uint64
+	err := r.db.WithContext(ctx).Model(&models.DeviceGroupMember{}).
+		Where("device_id = ?", deviceID).
+		Pluck("group_id", &ids).Error
+	if err != nil {
+		r.logger.Error("查询设备所属分组失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("查询设备所属分组失败: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceRuntimeStatusRepository 设备运行状态仓储接口
+type DeviceRuntimeStatusRepository interface {
+	GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceRuntimeStatus, error)
+	Upsert(ctx context.Context, status *models.DeviceRuntimeStatus, columns ...string) error
+	SetOffline(ctx context.Context, deviceID string) error
+}
+
+// deviceRuntimeStatusRepository 设备运行状态仓储实现
+type deviceRuntimeStatusRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceRuntimeStatusRepository 创建设备运行状态仓储
+func NewDeviceRuntimeStatusRepository(db *gorm.DB, logger utils.Logger) DeviceRuntimeStatusRepository {
+	return &deviceRuntimeStatusRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByDeviceID 获取设备运行状态，不存在时返回nil
+func (r *deviceRuntimeStatusRepository) GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceRuntimeStatus, error) {
+	var status models.DeviceRuntimeStatus
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&status).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备运行状态失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备运行状态失败: %w", err)
+	}
+	return &status, nil
+}
+
+// Upsert 写入设备运行状态，记录已存在时仅更新指定列
+func (r *deviceRuntimeStatusRepository) Upsert(ctx context.Context, status *models.DeviceRuntimeStatus, columns ...string) error {
+	columns = append(columns, "updated_at")
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).
+		Create(status).Error
+	if err != nil {
+		r.logger.Error("更新设备运行状态失败", utils.String("device_id", status.DeviceID), utils.ErrorField(err))
+		return fmt.Errorf("更新设备运行状态失败: %w", err)
+	}
+	return nil
+}
+
+// SetOffline 将设备运行状态标记为离线（保留最后心跳时间）
+func (r *deviceRuntimeStatusRepository) SetOffline(ctx context.Context, deviceID string) error {
+	err := r.db.WithContext(ctx).Model(&models.DeviceRuntimeStatus{}).
+		Where("device_id = ?", deviceID).
+		UpdateColumns(map[string]interface{}{"online": false, "updated_at": time.Now()}).Error
+	if err != nil {
+		r.logger.Error("标记设备离线失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return fmt.Errorf("标记设备离线失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceShadowRepository 设备影子仓储接口
+type DeviceShadowRepository interface {
+	GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceShadow, error)
+	List(ctx context.Context) ([]models.DeviceShadow, error)
+	Save(ctx context.Context, shadow *models.DeviceShadow) error
+}
+
+// deviceShadowRepository 设备影子仓储实现
+type deviceShadowRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewDeviceShadowRepository 创建设备影子仓储
+func NewDeviceShadowRepository(db *gorm.DB, logger utils.Logger) DeviceShadowRepository {
+	return &deviceShadowRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByDeviceID 获取设备影子，不存在时返回nil
+func (r *deviceShadowRepository) GetByDeviceID(ctx context.Context, deviceID string) (*models.DeviceShadow, error) {
+	var shadow models.DeviceShadow
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&shadow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备影子失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备影子失败: %w", err)
+	}
+	return &shadow, nil
+}
+
+// List 获取全部设备影子（按最后数据时间倒序）
+func (r *deviceShadowRepository) List(ctx context.Context) ([]models.DeviceShadow, error) {
+	var shadows []models.DeviceShadow
+	if err := r.db.WithContext(ctx).Order("last_data_time DESC").Find(&shadows).Error; err != nil {
+		r.logger.Error("查询设备影子失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询设备影子失败: %w", err)
+	}
+	return shadows, nil
+}
+
+// Save 写入设备影子
+func (r *deviceShadowRepository) Save(ctx context.Context, shadow *models.DeviceShadow) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"device_type", "sensor_id", "sensor_type", "data_quality",
+				"metrics", "last_data_time", "version", "updated_at",
+			}),
+		}).
+		Create(shadow).Error
+	if err != nil {
+		r.logger.Error("保存设备影子失败", utils.String("device_id", shadow.DeviceID), utils.ErrorField(err))
+		return fmt.Errorf("保存设备影子失败: %w", err)
+	}
+	return nil
+}
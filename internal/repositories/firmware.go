@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// FirmwareRepository 固件仓储接口
+type FirmwareRepository interface {
+	Create(ctx context.Context, firmware *models.Firmware) error
+	GetByID(ctx context.Context, id uint64) (*models.Firmware, error)
+	GetByVersion(ctx context.Context, deviceType models.DeviceType, version string) (*models.Firmware, error)
+	List(ctx context.Context, deviceType models.DeviceType) ([]models.Firmware, error)
+}
+
+// firmwareRepository 固件仓储实现
+type firmwareRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewFirmwareRepository 创建固件仓储
+func NewFirmwareRepository(db *gorm.DB, logger utils.Logger) FirmwareRepository {
+	return &firmwareRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 创建固件记录
+func (r *firmwareRepository) Create(ctx context.Context, firmware *models.Firmware) error {
+	if err := r.db.WithContext(ctx).Create(firmware).Error; err != nil {
+		r.logger.Error("创建固件记录失败", utils.String("version", firmware.Version), utils.ErrorField(err))
+		return fmt.Errorf("创建固件记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据ID获取固件，不存在时返回nil
+func (r *firmwareRepository) GetByID(ctx context.Context, id uint64) (*models.Firmware, error) {
+	var firmware models.Firmware
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&firmware).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取固件失败", utils.Int64("firmware_id", int64(id)), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取固件失败: %w", err)
+	}
+	return &firmware, nil
+}
+
+// GetByVersion 根据设备类型和版本号获取固件，不存在时返回nil
+func (r *firmwareRepository) GetByVersion(ctx context.Context, deviceType models.DeviceType, version string) (*models.Firmware, error) {
+	var firmware models.Firmware
+	err := r.db.WithContext(ctx).Where("device_type = ? AND version = ?", deviceType, version).First(&firmware).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取固件失败", utils.String("version", version), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取固件失败: %w", err)
+	}
+	return &firmware, nil
+}
+
+// List 获取固件列表（按上传时间倒序），deviceType为空时返回全部
+func (r *firmwareRepository) List(ctx context.Context, deviceType models.DeviceType) ([]models.Firmware, error) {
+	var firmware []models.Firmware
+	query := r.db.WithContext(ctx)
+	if deviceType != "" {
+		query = query.Where("device_type = ?", deviceType)
+	}
+	if err := query.Order("created_at DESC, id DESC").Find(&firmware).Error; err != nil {
+		r.logger.Error("查询固件列表失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询固件列表失败: %w", err)
+	}
+	return firmware, nil
+}
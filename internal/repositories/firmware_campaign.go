@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// FirmwareCampaignRepository 固件升级活动仓储接口
+type FirmwareCampaignRepository interface {
+	Create(ctx context.Context, campaign *models.FirmwareCampaign, updates []models.FirmwareUpdate) error
+	GetByID(ctx context.Context, id uint64) (*models.FirmwareCampaign, error)
+	List(ctx context.Context, status string) ([]models.FirmwareCampaign, error)
+	Transition(ctx context.Context, id uint64, from []models.FirmwareCampaignStatus, updates map[string]interface{}) (bool, error)
+	Update(ctx context.Context, id uint64, updates map[string]interface{}) error
+}
+
+// firmwareCampaignRepository 固件升级活动仓储实现
+type firmwareCampaignRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewFirmwareCampaignRepository 创建固件升级活动仓储
+func NewFirmwareCampaignRepository(db *gorm.DB, logger utils.Logger) FirmwareCampaignRepository {
+	return &firmwareCampaignRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 在同一事务中创建升级活动及各设备的升级记录
+func (r *firmwareCampaignRepository) Create(ctx context.Context, campaign *models.FirmwareCampaign, updates []models.FirmwareUpdate) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+		for i := range updates {
+			updates[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(updates, 500).Error
+	})
+	if err != nil {
+		r.logger.Error("创建固件升级活动失败", utils.String("name", campaign.Name), utils.ErrorField(err))
+		return fmt.Errorf("创建固件升级活动失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据ID获取升级活动，不存在时返回nil
+func (r *firmwareCampaignRepository) GetByID(ctx context.Context, id uint64) (*models.FirmwareCampaign, error) {
+	var campaign models.FirmwareCampaign
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&campaign).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取固件升级活动失败", utils.Int64("campaign_id", int64(id)), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取固件升级活动失败: %w", err)
+	}
+	return &campaign, nil
+}
+
+// List 获取升级活动列表（按创建时间倒序），status为空时返回全部
+func (r *firmwareCampaignRepository) List(ctx context.Context, status string) ([]models.FirmwareCampaign, error) {
+	var campaigns []models.FirmwareCampaign
+	query := r.db.WithContext(ctx)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC, id DESC").Find(&campaigns).Error; err != nil {
+		r.logger.Error("查询固件升级活动失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询固件升级活动失败: %w", err)
+	}
+	return campaigns, nil
+}
+
+// Transition 仅当活动处于from状态之一时更新，返回是否实际更新
+func (r *firmwareCampaignRepository) Transition(ctx context.Context, id uint64, from []models.FirmwareCampaignStatus, updates map[string]interface{}) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.FirmwareCampaign{}).
+		Where("id = ? AND status IN ?", id, from).
+		UpdateColumns(updates)
+	if res.Error != nil {
+		r.logger.Error("更新固件升级活动状态失败", utils.Int64("campaign_id", int64(id)), utils.ErrorField(res.Error))
+		return false, fmt.Errorf("更新固件升级活动状态失败: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// Update 更新升级活动字段
+func (r *firmwareCampaignRepository) Update(ctx context.Context, id uint64, updates map[string]interface{}) error {
+	if err := r.db.WithContext(ctx).Model(&models.FirmwareCampaign{}).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+		r.logger.Error("更新固件升级活动失败", utils.Int64("campaign_id", int64(id)), utils.ErrorField(err))
+		return fmt.Errorf("更新固件升级活动失败: %w", err)
+	}
+	return nil
+}
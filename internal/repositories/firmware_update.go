@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// FirmwareUpdateRepository 设备升级记录仓储接口
+type FirmwareUpdateRepository interface {
+	GetByCampaignDevice(ctx context.Context, campaignID uint64, deviceID string) (*models.FirmwareUpdate, error)
+	ListByCampaign(ctx context.Context, campaignID uint64, req *models.FirmwareUpdateListRequest) ([]models.FirmwareUpdate, int64, error)
+	ListByStatus(ctx context.Context, campaignID uint64, maxStage int, statuses ...models.FirmwareUpdateStatus) ([]models.FirmwareUpdate, error)
+	ListTimedOut(ctx context.Context, before time.Time) ([]models.FirmwareUpdate, error)
+	CountByStatus(ctx context.Context, campaignID uint64, maxStage int) (map[models.FirmwareUpdateStatus]int64, error)
+	Transition(ctx context.Context, id uint64, from []models.FirmwareUpdateStatus, updates map[string]interface{}) (bool, error)
+}
+
+// firmwareUpdateRepository 设备升级记录仓储实现
+type firmwareUpdateRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewFirmwareUpdateRepository 创建设备升级记录仓储
+func NewFirmwareUpdateRepository(db *gorm.DB, logger utils.Logger) FirmwareUpdateRepository {
+	return &firmwareUpdateRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByCampaignDevice 获取设备在升级活动中的记录，不存在时返回nil
+func (r *firmwareUpdateRepository) GetByCampaignDevice(ctx context.Context, campaignID uint64, deviceID string) (*models.FirmwareUpdate, error) {
+	var update models.FirmwareUpdate
+	err := r.db.WithContext(ctx).Where("campaign_id = ? AND device_id = ?", campaignID, deviceID).First(&update).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取设备升级记录失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取设备升级记录失败: %w", err)
+	}
+	return &update, nil
+}
+
+// ListByCampaign 分页查询升级活动的设备升级记录
+func (r *firmwareUpdateRepository) ListByCampaign(ctx context.Context, campaignID uint64, req *models.FirmwareUpdateListRequest) ([]models.FirmwareUpdate, int64, error) {
+	var updates []models.FirmwareUpdate
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.FirmwareUpdate{}).Where("campaign_id = ?", campaignID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error("统计设备升级记录失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("统计设备升级记录失败: %w", err)
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Order("stage, device_id").Offset(offset).Limit(req.PageSize).Find(&updates).Error; err != nil {
+		r.logger.Error("查询设备升级记录失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("查询设备升级记录失败: %w", err)
+	}
+	return updates, total, nil
+}
+
+// ListByStatus 获取升级活动中阶段不超过maxStage且处于指定状态的记录
+func (r *firmwareUpdateRepository) ListByStatus(ctx context.Context, campaignID uint64, maxStage int, statuses ...models.FirmwareUpdateStatus) ([]models.FirmwareUpdate, error) {
+	var updates []models.FirmwareUpdate
+	err := r.db.WithContext(ctx).
+		Where("campaign_id = ? AND stage <= ? AND status IN ?", campaignID, maxStage, statuses).
+		Order("stage, device_id").
+		Find(&updates).Error
+	if err != nil {
+		r.logger.Error("查询设备升级记录失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询设备升级记录失败: %w", err)
+	}
+	return updates, nil
+}
+
+// ListTimedOut 获取推送时间早于before仍未结束的升级记录
+func (r *firmwareUpdateRepository) ListTimedOut(ctx context.Context, before time.Time) ([]models.FirmwareUpdate, error) {
+	var updates []models.FirmwareUpdate
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND notified_at < ?", models.FirmwareUpdateInProgressStatuses, before).
+		Find(&updates).Error
+	if err != nil {
+		r.logger.Error("查询超时设备升级记录失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询超时设备升级记录失败: %w", err)
+	}
+	return updates, nil
+}
+
+// CountByStatus 按状态统计阶段不超过maxStage的升级记录，maxStage小于0时统计全部
+func (r *firmwareUpdateRepository) CountByStatus(ctx context.Context, campaignID uint64, maxStage int) (map[models.FirmwareUpdateStatus]int64, error) {
+	var rows []struct {
+		Status models.FirmwareUpdateStatus
+		Count  int64
+	}
+	query := r.db.WithContext(ctx).Model(&models.FirmwareUpdate{}).Where("campaign_id = ?", campaignID)
+	if maxStage >= 0 {
+		query = query.Where("stage <= ?", maxStage)
+	}
+	if err := query.Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		r.logger.Error("统计设备升级状态失败", utils.Int64("campaign_id", int64(campaignID)), utils.ErrorField(err))
+		return nil, fmt.Errorf("统计设备升级状态失败: %w", err)
+	}
+
+	counts := make(map[models.FirmwareUpdateStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// Transition 仅当记录处于from状态之一时更新，返回是否实际更新
+func (r *firmwareUpdateRepository) Transition(ctx context.Context, id uint64, from []models.FirmwareUpdateStatus, updates map[string]interface{}) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.FirmwareUpdate{}).
+		Where("id = ? AND status IN ?", id, from).
+		UpdateColumns(updates)
+	if res.Error != nil {
+		r.logger.Error("更新设备升级状态失败", utils.Int64("update_id", int64(id)), utils.ErrorField(res.Error))
+		return false, fmt.Errorf("更新设备升级状态失败: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
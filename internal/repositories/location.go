@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// LocationRepository 位置层级仓储接口
+type LocationRepository interface {
+	// Create 创建节点并根据父节点生成路径
+	Create(ctx context.Context, node *models.LocationNode, parent *models.LocationNode) error
+	GetByID(ctx context.Context, id uint64) (*models.LocationNode, error)
+	// List 获取全部节点（按路径排序，父节点在子节点之前）
+	List(ctx context.Context) ([]models.LocationNode, error)
+	// ListSubtree 获取节点及其全部后代
+	ListSubtree(ctx context.Context, node *models.LocationNode) ([]models.LocationNode, error)
+	// Update 更新节点，路径变化时同步更新全部后代的路径
+	Update(ctx context.Context, node *models.LocationNode, oldPath string) error
+	// Delete 删除节点并解除设备与该节点的关联
+	Delete(ctx context.Context, id uint64) error
+	CountChildren(ctx context.Context, id uint64) (int64, error)
+	// GetPathByDevice 获取设备所在节点的路径，未分配位置时返回空字符串
+	GetPathByDevice(ctx context.Context, deviceID string) (string, error)
+}
+
+// locationRepository 位置层级仓储实现
+type locationRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewLocationRepository 创建位置层级仓储
+func NewLocationRepository(db *gorm.DB, logger utils.Logger) LocationRepository {
+	return &locationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 先插入节点获得ID，再在同一事务中写入完整路径
+func (r *locationRepository) Create(ctx context.Context, node *models.LocationNode, parent *models.LocationNode) error {
+	parentPath := "/"
+	if parent != nil {
+		parentPath = parent.Path
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		node.Path = parentPath
+		if err := tx.Create(node).Error; err != nil {
+			return err
+		}
+		node.Path = models.LocationPath(parentPath, node.ID)
+		return tx.Model(node).Update("path", node.Path).Error
+	})
+	if err != nil {
+		r.logger.Error("创建位置失败", utils.String("name", node.Name), utils.ErrorField(err))
+		return fmt.Errorf("创建位置失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据ID获取节点，不存在时返回nil
+func (r *locationRepository) GetByID(ctx context.Context, id uint64) (*models.LocationNode, error) {
+	var node models.LocationNode
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&node).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取位置失败", utils.Int64("location_id", int64(id)), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取位置失败: %w", err)
+	}
+	return &node, nil
+}
+
+// List 获取全部节点
+func (r *locationRepository) List(ctx context.Context) ([]models.LocationNode, error) {
+	var nodes []models.LocationNode
+	if err := r.db.WithContext(ctx).Order("path").Find(&nodes).Error; err != nil {
+		r.logger.Error("查询位置列表失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询位置列表失败: %w", err)
+	}
+	return nodes, nil
+}
+
+// ListSubtree 按路径前缀获取子树
+func (r *locationRepository) ListSubtree(ctx context.Context, node *models.LocationNode) ([]models.LocationNode, error) {
+	var nodes []models.LocationNode
+	if err := r.db.WithContext(ctx).Where("path LIKE ?", node.Path+"%").Order("path").Find(&nodes).Error; err != nil {
+		r.logger.Error("查询位置子树失败", utils.Int64("location_id", int64(node.ID)), utils.ErrorField(err))
+		return nil, fmt.Errorf("查询位置子树失败: %w", err)
+	}
+	return nodes, nil
+}
+
+// Update 更新节点属性，移动节点时逐个改写后代路径前缀
+func (r *locationRepository) Update(ctx context.Context, node *models.LocationNode, oldPath string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(node).Select("parent_id", "name", "type", "path", "description").Updates(node).Error; err != nil {
+			return err
+		}
+		if node.Path == oldPath {
+			return nil
+		}
+		var descendants []models.LocationNode
+		if err := tx.Where("path LIKE ? AND id <> ?", oldPath+"%", node.ID).Find(&descendants).Error; err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			path := node.Path + strings.TrimPrefix(d.Path, oldPath)
+			if err := tx.Model(&models.LocationNode{}).Where("id = ?", d.ID).Update("path", path).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("更新位置失败", utils.Int64("location_id", int64(node.ID)), utils.ErrorField(err))
+		return fmt.Errorf("更新位置失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除节点，设备的位置关联置空
+func (r *locationRepository) Delete(ctx context.Context, id uint64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Device{}).Where("location_id = ?", id).Update("location_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.LocationNode{}).Error
+	})
+	if err != nil {
+		r.logger.Error("删除位置失败", utils.Int64("location_id", int64(id)), utils.ErrorField(err))
+		return fmt.Errorf("删除位置失败: %w", err)
+	}
+	return nil
+}
+
+// CountChildren 统计直接子节点数量
+func (r *locationRepository) CountChildren(ctx context.Context, id uint64) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.LocationNode{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+		r.logger.Error("统计子位置失败", utils.Int64("location_id", int64(id)), utils.ErrorField(err))
+		return 0, fmt.Errorf("统计子位置失败: %w", err)
+	}
+	return count, nil
+}
+
+// GetPathByDevice 关联设备表查询设备所在节点路径
+func (r *locationRepository) GetPathByDevice(ctx context.Context, deviceID string) (string, error) {
+	var paths []string
+	err := r.db.WithContext(ctx).Table("devices").
+		Joins("JOIN locations ON locations.id = devices.location_id").
+		Where("devices.id = ?", deviceID).
+		Pluck("locations.path", &paths).Error
+	if err != nil {
+		r.logger.Error("查询设备位置失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return "", fmt.Errorf("查询设备位置失败: %w", err)
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	return paths[0], nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// MQTTACLRuleRepository MQTT访问控制规则仓储接口
+type MQTTACLRuleRepository interface {
+	BaseRepository[models.MQTTACLRule]
+	GetEnabled(ctx context.Context) ([]models.MQTTACLRule, error)
+	GetByUsername(ctx context.Context, username string) ([]models.MQTTACLRule, error)
+}
+
+// mqttACLRuleRepository MQTT访问控制规则仓储实现
+type mqttACLRuleRepository struct {
+	*baseRepository[models.MQTTACLRule]
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewMQTTACLRuleRepository 创建MQTT访问控制规则仓储
+func NewMQTTACLRuleRepository(db *gorm.DB, logger utils.Logger) MQTTACLRuleRepository {
+	return &mqttACLRuleRepository{
+		baseRepository: NewBaseRepository[models.MQTTACLRule](db, logger).(*baseRepository[models.MQTTACLRule]),
+		db:             db,
+		logger:         logger,
+	}
+}
+
+// GetEnabled 获取所有启用的规则（按优先级降序）
+func (r *mqttACLRuleRepository) GetEnabled(ctx context.Context) ([]models.MQTTACLRule, error) {
+	var rules []models.MQTTACLRule
+	if err := r.db.WithContext(ctx).Where("enabled = ?", true).Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		r.logger.Error("获取启用的ACL规则失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("获取ACL规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// GetByUsername 获取指定用户名的全部规则
+func (r *mqttACLRuleRepository) GetByUsername(ctx context.Context, username string) ([]models.MQTTACLRule, error) {
+	var rules []models.MQTTACLRule
+	if err := r.db.WithContext(ctx).Where("username = ?", username).Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		r.logger.Error("根据用户名获取ACL规则失败", utils.String("username", username), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取ACL规则失败: %w", err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// NotificationLogRepository 通知投递记录仓储接口
+type NotificationLogRepository interface {
+	BaseRepository[models.NotificationLog]
+	GetByAlertID(ctx context.Context, alertID uint64) ([]models.NotificationLog, error)
+	HasEvent(ctx context.Context, alertID uint64, event models.NotificationEvent) (bool, error)
+}
+
+// notificationLogRepository 通知投递记录仓储实现
+type notificationLogRepository struct {
+	*baseRepository[models.NotificationLog]
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewNotificationLogRepository 创建通知投递记录仓储
+func NewNotificationLogRepository(db *gorm.DB, logger utils.Logger) NotificationLogRepository {
+	return &notificationLogRepository{
+		baseRepository: NewBaseRepository[models.NotificationLog](db, logger).(*baseRepository[models.NotificationLog]),
+		db:             db,
+		logger:         logger,
+	}
+}
+
+// GetByAlertID 获取告警的全部投递记录
+func (r *notificationLogRepository) GetByAlertID(ctx context.Context, alertID uint64) ([]models.NotificationLog, error) {
+	var logs []models.NotificationLog
+	if err := r.db.WithContext(ctx).Where("alert_id = ?", alertID).Order("id ASC").Find(&logs).Error; err != nil {
+		r.logger.Error("获取通知投递记录失败", utils.ErrorField(err), utils.Int64("alert_id", int64(alertID)))
+		return nil, fmt.Errorf("获取通知投递记录失败: %w", err)
+	}
+	return logs, nil
+}
+
+// HasEvent 判断告警是否已发送过指定事件的通知
+func (r *notificationLogRepository) HasEvent(ctx context.Context, alertID uint64, event models.NotificationEvent) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.NotificationLog{}).
+		Where("alert_id = ? AND event = ?", alertID, event).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询通知投递记录失败: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PendingDeviceRepository 待审批设备及隔离数据仓储接口
+type PendingDeviceRepository interface {
+	// Quarantine 登记待审批设备并隔离数据，超过上限的数据只计数不保存，返回实际保存条数
+	Quarantine(ctx context.Context, deviceID string, deviceType models.DeviceType, readings []string, seenAt time.Time, maxStored int) (int, error)
+	GetByDeviceID(ctx context.Context, deviceID string) (*models.PendingDevice, error)
+	List(ctx context.Context) ([]models.PendingDevice, error)
+	ListReadings(ctx context.Context, deviceID string) ([]models.QuarantinedReading, error)
+	// Delete 删除待审批设备及其隔离数据
+	Delete(ctx context.Context, deviceID string) error
+}
+
+// pendingDeviceRepository 待审批设备仓储实现
+type pendingDeviceRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewPendingDeviceRepository 创建待审批设备仓储
+func NewPendingDeviceRepository(db *gorm.DB, logger utils.Logger) PendingDeviceRepository {
+	return &pendingDeviceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Quarantine 登记待审批设备并隔离数据
+func (r *pendingDeviceRepository) Quarantine(ctx context.Context, deviceID string, deviceType models.DeviceType, readings []string, seenAt time.Time, maxStored int) (int, error) {
+	stored := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		pending := models.PendingDevice{
+			DeviceID:    deviceID,
+			DeviceType:  deviceType,
+			FirstSeenAt: seenAt,
+			LastSeenAt:  seenAt,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "device_id"}},
+			DoNothing: true,
+		}).Create(&pending).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("device_id = ?", deviceID).First(&pending).Error; err != nil {
+			return err
+		}
+
+		if space := int64(maxStored) - pending.QuarantinedCount; space > 0 {
+			stored = len(readings)
+			if int64(stored) > space {
+				stored = int(space)
+			}
+		}
+		if stored > 0 {
+			rows := make([]models.QuarantinedReading, stored)
+			for i := range rows {
+				rows[i] = models.QuarantinedReading{DeviceID: deviceID, Data: readings[i]}
+			}
+			if err := tx.CreateInBatches(rows, 100).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.PendingDevice{}).Where("device_id = ?", deviceID).Updates(map[string]interface{}{
+			"reading_count":     gorm.Expr("reading_count + ?", len(readings)),
+			"quarantined_count": gorm.Expr("quarantined_count + ?", stored),
+			"last_seen_at":      seenAt,
+		}).Error
+	})
+	if err != nil {
+		r.logger.Error("隔离待审批设备数据失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return 0, fmt.Errorf("隔离待审批设备数据失败: %w", err)
+	}
+	return stored, nil
+}
+
+// GetByDeviceID 获取待审批设备，不存在时返回nil
+func (r *pendingDeviceRepository) GetByDeviceID(ctx context.Context, deviceID string) (*models.PendingDevice, error) {
+	var pending models.PendingDevice
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("获取待审批设备失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("获取待审批设备失败: %w", err)
+	}
+	return &pending, nil
+}
+
+// List 获取全部待审批设备（按最后上报时间倒序）
+func (r *pendingDeviceRepository) List(ctx context.Context) ([]models.PendingDevice, error) {
+	var pending []models.PendingDevice
+	if err := r.db.WithContext(ctx).Order("last_seen_at DESC").Find(&pending).Error; err != nil {
+		r.logger.Error("查询待审批设备失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("查询待审批设备失败: %w", err)
+	}
+	return pending, nil
+}
+
+// ListReadings 获取设备的隔离数据（按接收顺序）
+func (r *pendingDeviceRepository) ListReadings(ctx context.Context, deviceID string) ([]models.QuarantinedReading, error) {
+	var readings []models.QuarantinedReading
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).Order("id ASC").Find(&readings).Error; err != nil {
+		r.logger.Error("查询隔离数据失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil, fmt.Errorf("查询隔离数据失败: %w", err)
+	}
+	return readings, nil
+}
+
+// Delete 删除待审批设备及其隔离数据
+func (r *pendingDeviceRepository) Delete(ctx context.Context, deviceID string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device_id = ?", deviceID).Delete(&models.QuarantinedReading{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("device_id = ?", deviceID).Delete(&models.PendingDevice{}).Error
+	})
+	if err != nil {
+		r.logger.Error("删除待审批设备失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return fmt.Errorf("删除待审批设备失败: %w", err)
+	}
+	return nil
+}
@@ -3,9 +3,28 @@ package repositories
 // Repositories 仓储层集合
 type Repositories struct {
 	Device            DeviceRepository
+	DeviceCredential  DeviceCredentialRepository
 	AirQuality        AirQualityRepository
 	UnifiedSensorData UnifiedSensorDataRepository
 	User              UserRepository
+	Role              RoleRepository
 	Alert             AlertRepository
+	AlertRule         AlertRuleRepository
 	Config            ConfigRepository
+	MQTTACLRule       MQTTACLRuleRepository
+	NotificationLog   NotificationLogRepository
+	RevokedToken      RevokedTokenRepository
+	DeviceRuntime     DeviceRuntimeStatusRepository
+	DeviceCommand     DeviceCommandRepository
+	DeviceConfigState DeviceConfigStateRepository
+	DeviceShadow      DeviceShadowRepository
+	Firmware          FirmwareRepository
+	FirmwareCampaign  FirmwareCampaignRepository
+	FirmwareUpdate    FirmwareUpdateRepository
+	PendingDevice     PendingDeviceRepository
+	Location          LocationRepository
+	DeviceGroup       DeviceGroupRepository
+	SensorDataRollup  SensorDataRollupRepository
+	Retention         RetentionRepository
+	SensorDataArchive SensorDataArchiveRepository
 }
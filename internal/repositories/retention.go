@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetentionRepository 数据保留仓储接口，按表名操作，不经过模型的软删除过滤
+type RetentionRepository interface {
+	// 统计时间列早于before的行数
+	CountExpired(ctx context.Context, target *models.RetentionTarget, before time.Time) (int64, error)
+	// 按主键顺序读取最多limit行过期数据，withRows为false时只读取主键列
+	ListExpired(ctx context.Context, target *models.RetentionTarget, before time.Time, limit int, withRows bool) ([]map[string]interface{}, error)
+	// 在事务中按主键删除数据及其关联表中的记录，返回主表删除行数
+	DeleteRows(ctx context.Context, target *models.RetentionTarget, rows []map[string]interface{}) (int64, error)
+}
+
+// retentionRepository 数据保留仓储实现
+type retentionRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewRetentionRepository 创建数据保留仓储
+func NewRetentionRepository(db *gorm.DB, logger utils.Logger) RetentionRepository {
+	return &retentionRepository{db: db, logger: logger}
+}
+
+// expired 构造过期数据查询
+func (r *retentionRepository) expired(ctx context.Context, target *models.RetentionTarget, before time.Time) *gorm.DB {
+	query := r.db.WithContext(ctx).Table(target.Table).Where(target.TimeColumn+" < ?", before)
+	if target.Condition != "" {
+		query = query.Where(target.Condition, target.Args...)
+	}
+	return query
+}
+
+// CountExpired 统计过期数据
+func (r *retentionRepository) CountExpired(ctx context.Context, target *models.RetentionTarget, before time.Time) (int64, error) {
+	var count int64
+	if err := r.expired(ctx, target, before).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计%s过期数据失败: %w", target.Table, err)
+	}
+	return count, nil
+}
+
+// ListExpired 读取一批过期数据
+func (r *retentionRepository) ListExpired(ctx context.Context, target *models.RetentionTarget, before time.Time, limit int, withRows bool) ([]map[string]interface{}, error) {
+	query := r.expired(ctx, target, before)
+	if !withRows {
+		query = query.Select(target.KeyColumns)
+	}
+	var rows []map[string]interface{}
+	err := query.Order(strings.Join(target.KeyColumns, ", ")).Limit(limit).Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取%s过期数据失败: %w", target.Table, err)
+	}
+	return rows, nil
+}
+
+// DeleteRows 按主键删除，单列主键用IN列表，组合主键用行值IN列表
+func (r *retentionRepository) DeleteRows(ctx context.Context, target *models.RetentionTarget, rows []map[string]interface{}) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var condition string
+	var keys interface{}
+	if len(target.KeyColumns) == 1 {
+		column := target.KeyColumns[0]
+		values := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			values = append(values, row[column])
+		}
+		condition, keys = column+" IN ?", values
+	} else {
+		tuples := make([][]interface{}, 0, len(rows))
+		for _, row := range rows {
+			tuple := make([]interface{}, 0, len(target.KeyColumns))
+			for _, column := range target.KeyColumns {
+				tuple = append(tuple, row[column])
+			}
+			tuples = append(tuples, tuple)
+		}
+		condition, keys = "("+strings.Join(target.KeyColumns, ", ")+") IN ?", tuples
+	}
+
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, child := range target.Children {
+			if err := tx.Exec("DELETE FROM "+child.Table+" WHERE "+child.ForeignKey+" IN ?", keys).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Exec("DELETE FROM "+target.Table+" WHERE "+condition, keys)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("删除%s过期数据失败: %w", target.Table, err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedTokenRepository 已注销令牌仓储接口
+type RevokedTokenRepository interface {
+	Revoke(ctx context.Context, token *models.RevokedToken) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// revokedTokenRepository 已注销令牌仓储实现
+type revokedTokenRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewRevokedTokenRepository 创建已注销令牌仓储
+func NewRevokedTokenRepository(db *gorm.DB, logger utils.Logger) RevokedTokenRepository {
+	return &revokedTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Revoke 记录注销的令牌（重复注销忽略）
+func (r *revokedTokenRepository) Revoke(ctx context.Context, token *models.RevokedToken) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "token_id"}}, DoNothing: true}).
+		Create(token).Error
+	if err != nil {
+		r.logger.Error("记录注销令牌失败", utils.ErrorField(err), utils.String("token_id", token.TokenID))
+		return fmt.Errorf("记录注销令牌失败: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked 判断令牌是否已注销
+func (r *revokedTokenRepository) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RevokedToken{}).
+		Where("token_id = ?", tokenID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询注销令牌失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// DeleteExpired 清理已过期的注销记录
+func (r *revokedTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理过期注销令牌失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// RoleRepository 角色仓储接口
+type RoleRepository interface {
+	BaseRepository[models.Role]
+	GetByName(ctx context.Context, name string) (*models.Role, error)
+	GetByIDs(ctx context.Context, ids []uint64) ([]models.Role, error)
+	Search(ctx context.Context, req *models.RoleListRequest) ([]models.Role, int64, error)
+	GetUserRoles(ctx context.Context, userID uint64) ([]models.Role, error)
+	SetUserRoles(ctx context.Context, userID uint64, roleIDs []uint64) error
+	CountUsers(ctx context.Context, roleID uint64) (int64, error)
+}
+
+// roleRepository 角色仓储实现
+type roleRepository struct {
+	*baseRepository[models.Role]
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewRoleRepository 创建角色仓储
+func NewRoleRepository(db *gorm.DB, logger utils.Logger) RoleRepository {
+	return &roleRepository{
+		baseRepository: NewBaseRepository[models.Role](db, logger).(*baseRepository[models.Role]),
+		db:             db,
+		logger:         logger,
+	}
+}
+
+// GetByName 根据名称获取角色，不存在时返回nil
+func (r *roleRepository) GetByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error("根据名称获取角色失败", utils.ErrorField(err), utils.String("name", name))
+		return nil, fmt.Errorf("获取角色失败: %w", err)
+	}
+	return &role, nil
+}
+
+// GetByIDs 批量获取角色
+func (r *roleRepository) GetByIDs(ctx context.Context, ids []uint64) ([]models.Role, error) {
+	var roles []models.Role
+	if len(ids) == 0 {
+		return roles, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id ASC").Find(&roles).Error; err != nil {
+		r.logger.Error("批量获取角色失败", utils.ErrorField(err))
+		return nil, fmt.Errorf("获取角色失败: %w", err)
+	}
+	return roles, nil
+}
+
+// Search 按关键字分页查询角色
+func (r *roleRepository) Search(ctx context.Context, req *models.RoleListRequest) ([]models.Role, int64, error) {
+	var roles []models.Role
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Role{})
+	if req.Keyword != "" {
+		keyword := "%" + req.Keyword + "%"
+		query = query.Where("name LIKE ? OR description LIKE ?", keyword, keyword)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error("统计角色数量失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("统计角色数量失败: %w", err)
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Order("id ASC").Offset(offset).Limit(req.PageSize).Find(&roles).Error; err != nil {
+		r.logger.Error("查询角色列表失败", utils.ErrorField(err))
+		return nil, 0, fmt.Errorf("查询角色列表失败: %w", err)
+	}
+	return roles, total, nil
+}
+
+// GetUserRoles 获取用户的全部角色
+func (r *roleRepository) GetUserRoles(ctx context.Context, userID uint64) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.id ASC").
+		Find(&roles).Error
+	if err != nil {
+		r.logger.Error("获取用户角色失败", utils.ErrorField(err), utils.Int64("user_id", int64(userID)))
+		return nil, fmt.Errorf("获取用户角色失败: %w", err)
+	}
+	return roles, nil
+}
+
+// SetUserRoles 替换用户的角色
+func (r *roleRepository) SetUserRoles(ctx context.Context, userID uint64, roleIDs []uint64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			if err := tx.Create(&models.UserRole{UserID: userID, RoleID: roleID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("设置用户角色失败", utils.ErrorField(err), utils.Int64("user_id", int64(userID)))
+		return fmt.Errorf("设置用户角色失败: %w", err)
+	}
+	return nil
+}
+
+// CountUsers 统计拥有该角色的用户数
+func (r *roleRepository) CountUsers(ctx context.Context, roleID uint64) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.UserRole{}).Where("role_id = ?", roleID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计角色用户数失败: %w", err)
+	}
+	return count, nil
+}
+
+// Delete 删除角色（物理删除，以便同名角色可重新创建）
+func (r *roleRepository) Delete(ctx context.Context, id interface{}) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Role{}, id).Error
+	})
+	if err != nil {
+		r.logger.Error("删除角色失败", utils.ErrorField(err))
+		return fmt.Errorf("删除角色失败: %w", err)
+	}
+	return nil
+}
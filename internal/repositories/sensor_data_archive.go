@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SensorDataArchiveRepository 传感器数据归档仓储接口
+type SensorDataArchiveRepository interface {
+	// 查询不早于from的最早数据时间，没有数据时返回nil
+	NextTimestamp(ctx context.Context, from time.Time) (*time.Time, error)
+	// 查询[start, end)内有数据的设备ID
+	ListDeviceIDs(ctx context.Context, start, end time.Time) ([]string, error)
+	// 按时间顺序读取设备在[start, end)内的数据
+	ListReadings(ctx context.Context, deviceID string, start, end time.Time) ([]models.UnifiedSensorData, error)
+	// 按原ID写回归档数据，ID已存在的数据跳过，返回实际插入的条数
+	Restore(ctx context.Context, data []models.UnifiedSensorData) (int64, error)
+}
+
+// sensorDataArchiveRepository 传感器数据归档仓储实现
+type sensorDataArchiveRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewSensorDataArchiveRepository 创建传感器数据归档仓储
+func NewSensorDataArchiveRepository(db *gorm.DB, logger utils.Logger) SensorDataArchiveRepository {
+	return &sensorDataArchiveRepository{db: db, logger: logger}
+}
+
+// NextTimestamp 查询最早的数据时间
+func (r *sensorDataArchiveRepository) NextTimestamp(ctx context.Context, from time.Time) (*time.Time, error) {
+	var data []models.UnifiedSensorData
+	err := r.db.WithContext(ctx).
+		Select("timestamp").
+		Where("timestamp >= ?", from).
+		Order("timestamp ASC").
+		Limit(1).
+		Find(&data).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询最早数据时间失败: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return &data[0].Timestamp, nil
+}
+
+// ListDeviceIDs 查询有数据的设备
+func (r *sensorDataArchiveRepository) ListDeviceIDs(ctx context.Context, start, end time.Time) ([]string, error) {
+	var deviceIDs []string
+	err := r.db.WithContext(ctx).Model(&models.UnifiedSensorData{}).
+		Where("timestamp >= ? AND timestamp < ?", start, end).
+		Distinct().
+		Order("device_id").
+		Pluck("device_id", &deviceIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询设备列表失败: %w", err)
+	}
+	return deviceIDs, nil
+}
+
+// ListReadings 读取设备数据
+func (r *sensorDataArchiveRepository) ListReadings(ctx context.Context, deviceID string, start, end time.Time) ([]models.UnifiedSensorData, error) {
+	var data []models.UnifiedSensorData
+	err := r.db.WithContext(ctx).
+		Where("device_id = ? AND timestamp >= ? AND timestamp < ?", deviceID, start, end).
+		Order("timestamp ASC, id ASC").
+		Find(&data).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取设备数据失败: %w", err)
+	}
+	return data, nil
+}
+
+// Restore 写回归档数据
+func (r *sensorDataArchiveRepository) Restore(ctx context.Context, data []models.UnifiedSensorData) (int64, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(data, 500)
+	if result.Error != nil {
+		return 0, fmt.Errorf("恢复归档数据失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rollupStateName 汇总任务进度记录名称
+const rollupStateName = "sensor_data"
+
+// SensorDataRollupRepository 传感器数据汇总仓储接口
+type SensorDataRollupRepository interface {
+	// 读取汇总任务进度，从未运行时返回零值
+	GetState(ctx context.Context) (*models.SensorDataRollupState, error)
+	// 保存汇总任务进度
+	SaveState(ctx context.Context, state *models.SensorDataRollupState) error
+
+	// 按ID顺序读取ID大于afterID的原始数据（含已软删除的数据）
+	ListChanges(ctx context.Context, afterID uint64, limit int) ([]models.SensorDataChange, error)
+	// 读取[start, end)内符合条件的原始数据
+	ListReadings(ctx context.Context, filter models.RollupFilter, start, end time.Time) ([]models.UnifiedSensorData, error)
+
+	// 用新的汇总替换设备在[start, end)内的全部汇总
+	Replace(ctx context.Context, resolution models.RollupResolution, deviceID string, start, end time.Time, rollups []models.SensorDataRollup) error
+	// 查询时间桶起始时间在[start, end)内的汇总
+	Find(ctx context.Context, resolution models.RollupResolution, filter models.RollupFilter, start, end time.Time) ([]models.SensorDataRollup, error)
+}
+
+// sensorDataRollupRepository 传感器数据汇总仓储实现
+type sensorDataRollupRepository struct {
+	db     *gorm.DB
+	logger utils.Logger
+}
+
+// NewSensorDataRollupRepository 创建传感器数据汇总仓储
+func NewSensorDataRollupRepository(db *gorm.DB, logger utils.Logger) SensorDataRollupRepository {
+	return &sensorDataRollupRepository{db: db, logger: logger}
+}
+
+// GetState 读取汇总任务进度
+func (r *sensorDataRollupRepository) GetState(ctx context.Context) (*models.SensorDataRollupState, error) {
+	var state models.SensorDataRollupState
+	err := r.db.WithContext(ctx).Where("name = ?", rollupStateName).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.SensorDataRollupState{Name: rollupStateName}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取汇总进度失败: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveState 保存汇总任务进度
+func (r *sensorDataRollupRepository) SaveState(ctx context.Context, state *models.SensorDataRollupState) error {
+	state.Name = rollupStateName
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_id", "rolled_until", "updated_at"}),
+	}).Create(state).Error
+	if err != nil {
+		return fmt.Errorf("保存汇总进度失败: %w", err)
+	}
+	return nil
+}
+
+// ListChanges 读取新增的原始数据，只查询定位时间桶所需的列
+func (r *sensorDataRollupRepository) ListChanges(ctx context.Context, afterID uint64, limit int) ([]models.SensorDataChange, error) {
+	var changes []models.SensorDataChange
+	err := r.db.WithContext(ctx).Unscoped().
+		Model(&models.UnifiedSensorData{}).
+		Select("id, device_id, timestamp, created_at").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Scan(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取新增传感器数据失败: %w", err)
+	}
+	return changes, nil
+}
+
+// ListReadings 读取时间范围内的原始数据
+func (r *sensorDataRollupRepository) ListReadings(ctx context.Context, filter models.RollupFilter, start, end time.Time) ([]models.UnifiedSensorData, error) {
+	var data []models.UnifiedSensorData
+	query := r.db.WithContext(ctx).Where("timestamp >= ? AND timestamp < ?", start, end)
+	if len(filter.DeviceIDs) > 0 {
+		query = query.Where("device_id IN ?", filter.DeviceIDs)
+	}
+	if filter.DeviceType != "" {
+		query = query.Where("device_type = ?", filter.DeviceType)
+	}
+	if err := query.Order("timestamp ASC").Find(&data).Error; err != nil {
+		return nil, fmt.Errorf("读取传感器数据失败: %w", err)
+	}
+	return data, nil
+}
+
+// Replace 在事务中删除时间范围内的旧汇总并写入新汇总
+func (r *sensorDataRollupRepository) Replace(ctx context.Context, resolution models.RollupResolution, deviceID string, start, end time.Time, rollups []models.SensorDataRollup) error {
+	table := resolution.TableName()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(table).
+			Where("device_id = ? AND bucket_start >= ? AND bucket_start < ?", deviceID, start, end).
+			Delete(&models.SensorDataRollup{}).Error; err != nil {
+			return err
+		}
+		if len(rollups) == 0 {
+			return nil
+		}
+		return tx.Table(table).CreateInBatches(rollups, 500).Error
+	})
+	if err != nil {
+		return fmt.Errorf("更新%s汇总失败: %w", table, err)
+	}
+	return nil
+}
+
+// Find 查询汇总，按设备、指标和时间桶排序
+func (r *sensorDataRollupRepository) Find(ctx context.Context, resolution models.RollupResolution, filter models.RollupFilter, start, end time.Time) ([]models.SensorDataRollup, error) {
+	var rollups []models.SensorDataRollup
+	query := r.db.WithContext(ctx).Table(resolution.TableName()).
+		Where("bucket_start >= ? AND bucket_start < ?", start, end)
+	if len(filter.DeviceIDs) > 0 {
+		query = query.Where("device_id IN ?", filter.DeviceIDs)
+	}
+	if filter.DeviceType != "" {
+		query = query.Where("device_type = ?", filter.DeviceType)
+	}
+	if len(filter.Metrics) > 0 {
+		query = query.Where("metric IN ?", filter.Metrics)
+	}
+	err := query.Order("device_id, metric, bucket_start").Find(&rollups).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询%s汇总失败: %w", resolution.TableName(), err)
+	}
+	return rollups, nil
+}
@@ -4,9 +4,14 @@ import (
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/utils"
 	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UnifiedSensorDataRepository 统一传感器数据仓库接口
@@ -28,6 +33,12 @@ type UnifiedSensorDataRepository interface {
 	// 批量插入数据
 	BatchInsert(ctx context.Context, data []models.UnifiedSensorData) error
 
+	// 插入带消息ID的数据，同一设备的消息ID已存在时不插入并返回false
+	CreateIfAbsent(ctx context.Context, data *models.UnifiedSensorData) (bool, error)
+
+	// 检查设备的消息ID是否已入库
+	ExistsByMessageID(ctx context.Context, deviceID, messageID string) (bool, error)
+
 	// 根据设备类型获取数据
 	GetByDeviceType(ctx context.Context, deviceType models.DeviceType, limit, offset int) ([]models.UnifiedSensorData, error)
 
@@ -37,6 +48,15 @@ type UnifiedSensorDataRepository interface {
 	// 获取多设备数据
 	GetMultiDeviceData(ctx context.Context, deviceIDs []string, startTime, endTime int64) ([]models.UnifiedSensorData, error)
 
+	// 按时间桶聚合多设备指标
+	Aggregate(ctx context.Context, query *models.AirQualityDataQuery) ([]models.AggregatedSeries, error)
+
+	// 按时间桶聚合[start, end)内的多设备指标，忽略query中的起止时间
+	AggregateRange(ctx context.Context, query *models.AirQualityDataQuery, start, end time.Time) ([]models.AggregatedSeries, error)
+
+	// 获取多设备汇总统计
+	GetMultiDeviceStatistics(ctx context.Context, deviceIDs []string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error)
+
 	// 获取设备类型统计
 	GetDeviceTypeStatistics(ctx context.Context, deviceType models.DeviceType, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error)
 
@@ -106,6 +126,32 @@ func (r *unifiedSensorDataRepository) GetByTimeRange(ctx context.Context, device
 	return data, err
 }
 
+// statisticsColumns 统计查询列，无数据时聚合结果为NULL，统一按0返回
+const statisticsColumns = `
+	COUNT(*) as data_count,
+	COALESCE(AVG(pm25), 0) as pm25_avg,
+	COALESCE(MIN(pm25), 0) as pm25_min,
+	COALESCE(MAX(pm25), 0) as pm25_max,
+	COALESCE(AVG(pm10), 0) as pm10_avg,
+	COALESCE(MIN(pm10), 0) as pm10_min,
+	COALESCE(MAX(pm10), 0) as pm10_max,
+	COALESCE(AVG(co2), 0) as co2_avg,
+	COALESCE(MIN(co2), 0) as co2_min,
+	COALESCE(MAX(co2), 0) as co2_max,
+	COALESCE(AVG(formaldehyde), 0) as formaldehyde_avg,
+	COALESCE(MIN(formaldehyde), 0) as formaldehyde_min,
+	COALESCE(MAX(formaldehyde), 0) as formaldehyde_max,
+	COALESCE(AVG(temperature), 0) as temperature_avg,
+	COALESCE(MIN(temperature), 0) as temperature_min,
+	COALESCE(MAX(temperature), 0) as temperature_max,
+	COALESCE(AVG(humidity), 0) as humidity_avg,
+	COALESCE(MIN(humidity), 0) as humidity_min,
+	COALESCE(MAX(humidity), 0) as humidity_max,
+	COALESCE(AVG(pressure), 0) as pressure_avg,
+	COALESCE(MIN(pressure), 0) as pressure_min,
+	COALESCE(MAX(pressure), 0) as pressure_max
+`
+
 // GetStatistics 获取设备统计数据
 func (r *unifiedSensorDataRepository) GetStatistics(ctx context.Context, deviceID string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
 	var stats models.UnifiedSensorDataStatistics
@@ -118,30 +164,7 @@ func (r *unifiedSensorDataRepository) GetStatistics(ctx context.Context, deviceI
 			time.Unix(endTime, 0))
 
 	// 计算统计数据
-	err := query.Select(`
-		COUNT(*) as data_count,
-		AVG(pm25) as pm25_avg,
-		MIN(pm25) as pm25_min,
-		MAX(pm25) as pm25_max,
-		AVG(pm10) as pm10_avg,
-		MIN(pm10) as pm10_min,
-		MAX(pm10) as pm10_max,
-		AVG(co2) as co2_avg,
-		MIN(co2) as co2_min,
-		MAX(co2) as co2_max,
-		AVG(formaldehyde) as formaldehyde_avg,
-		MIN(formaldehyde) as formaldehyde_min,
-		MAX(formaldehyde) as formaldehyde_max,
-		AVG(temperature) as temperature_avg,
-		MIN(temperature) as temperature_min,
-		MAX(temperature) as temperature_max,
-		AVG(humidity) as humidity_avg,
-		MIN(humidity) as humidity_min,
-		MAX(humidity) as humidity_max,
-		AVG(pressure) as pressure_avg,
-		MIN(pressure) as pressure_min,
-		MAX(pressure) as pressure_max
-	`).Scan(&stats).Error
+	err := query.Select(statisticsColumns).Scan(&stats).Error
 
 	if err != nil {
 		return nil, err
@@ -159,6 +182,30 @@ func (r *unifiedSensorDataRepository) BatchInsert(ctx context.Context, data []mo
 	return r.db.WithContext(ctx).CreateInBatches(data, 100).Error
 }
 
+// CreateIfAbsent 依赖(device_id, message_id)唯一索引插入，冲突时不报错
+func (r *unifiedSensorDataRepository) CreateIfAbsent(ctx context.Context, data *models.UnifiedSensorData) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(data)
+	if result.Error != nil {
+		return false, fmt.Errorf("保存传感器数据失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ExistsByMessageID 检查设备的消息ID是否已入库（含已软删除的数据）
+func (r *unifiedSensorDataRepository) ExistsByMessageID(ctx context.Context, deviceID, messageID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().
+		Model(&models.UnifiedSensorData{}).
+		Where("device_id = ? AND message_id = ?", deviceID, messageID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("查询消息ID失败: %w", err)
+	}
+	return count > 0, nil
+}
+
 // GetByDeviceType 根据设备类型获取数据
 func (r *unifiedSensorDataRepository) GetByDeviceType(ctx context.Context, deviceType models.DeviceType, limit, offset int) ([]models.UnifiedSensorData, error) {
 	var data []models.UnifiedSensorData
@@ -225,6 +272,262 @@ func (r *unifiedSensorDataRepository) GetMultiDeviceData(ctx context.Context, de
 	return data, err
 }
 
+// aggregateBucket 单个时间桶的累计值
+type aggregateBucket struct {
+	sum   float64
+	min   float64
+	max   float64
+	count int64
+}
+
+// add 累加一个读数
+func (b *aggregateBucket) add(value float64) {
+	if b.count == 0 || value < b.min {
+		b.min = value
+	}
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	b.sum += value
+	b.count++
+}
+
+// value 按聚合方式取桶的值
+func (b *aggregateBucket) value(aggregation models.AggregationType) float64 {
+	switch aggregation {
+	case models.AggregationMax:
+		return b.max
+	case models.AggregationMin:
+		return b.min
+	case models.AggregationSum:
+		return b.sum
+	default:
+		return b.sum / float64(b.count)
+	}
+}
+
+// Aggregate 按时间桶聚合多设备指标，未指定指标时聚合全部有数据的指标
+func (r *unifiedSensorDataRepository) Aggregate(ctx context.Context, query *models.AirQualityDataQuery) ([]models.AggregatedSeries, error) {
+	return r.aggregate(ctx, query, "timestamp BETWEEN ? AND ?", time.Unix(query.StartTime, 0), time.Unix(query.EndTime, 0))
+}
+
+// AggregateRange 按时间桶聚合半开时间区间内的多设备指标
+func (r *unifiedSensorDataRepository) AggregateRange(ctx context.Context, query *models.AirQualityDataQuery, start, end time.Time) ([]models.AggregatedSeries, error) {
+	return r.aggregate(ctx, query, "timestamp >= ? AND timestamp < ?", start, end)
+}
+
+// aggregateSeriesKey 聚合序列的设备和指标
+type aggregateSeriesKey struct {
+	deviceID string
+	metric   string
+}
+
+// aggregate 按时间条件聚合数据：独立列中的指标由数据库按时间桶分组聚合，扩展数据中的指标逐行读取后在内存中聚合
+func (r *unifiedSensorDataRepository) aggregate(ctx context.Context, query *models.AirQualityDataQuery, timeCondition string, start, end time.Time) ([]models.AggregatedSeries, error) {
+	interval, ok := models.ParseAggregationInterval(query.Interval)
+	if !ok {
+		return nil, fmt.Errorf("不支持的聚合时间桶: %s", query.Interval)
+	}
+	aggregation := models.AggregationType(query.Aggregation)
+	if aggregation == "" {
+		aggregation = models.AggregationAvg
+	}
+	if !aggregation.IsValid() {
+		return nil, fmt.Errorf("不支持的聚合方式: %s", query.Aggregation)
+	}
+	if len(query.DeviceIDs) == 0 {
+		return []models.AggregatedSeries{}, nil
+	}
+
+	// 未指定指标时聚合全部独立列和扩展数据中出现的全部指标
+	columns := models.ColumnMetrics
+	var extended []string
+	allExtended := len(query.Metrics) == 0
+	if !allExtended {
+		columns = nil
+		for _, metric := range query.Metrics {
+			if models.IsColumnMetric(metric) {
+				columns = append(columns, metric)
+			} else {
+				extended = append(extended, metric)
+			}
+		}
+	}
+
+	scope := func() *gorm.DB {
+		db := r.db.WithContext(ctx).Model(&models.UnifiedSensorData{}).
+			Where("device_id IN ?", query.DeviceIDs).
+			Where(timeCondition, start, end)
+		if query.SensorID != "" {
+			db = db.Where("sensor_id = ?", query.SensorID)
+		}
+		return db
+	}
+
+	series := make(map[aggregateSeriesKey][]models.AggregatedPoint)
+	if len(columns) > 0 {
+		if err := r.aggregateColumns(scope(), columns, interval, aggregation, series); err != nil {
+			return nil, err
+		}
+	}
+	if allExtended || len(extended) > 0 {
+		if err := r.aggregateExtended(scope(), extended, interval, aggregation, series); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]models.AggregatedSeries, 0, len(series))
+	for key, points := range series {
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		result = append(result, models.AggregatedSeries{DeviceID: key.deviceID, Metric: key.metric, Points: points})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceID != result[j].DeviceID {
+			return result[i].DeviceID < result[j].DeviceID
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	return result, nil
+}
+
+// bucketExpression 按Unix时间对齐时间桶起点（秒）的SQL表达式，与models.BucketStart一致。
+// MySQL按会话时区解析DATETIME，需与连接的loc=Local一致
+func (r *unifiedSensorDataRepository) bucketExpression(interval time.Duration) string {
+	seconds := int64(interval / time.Second)
+	if r.db.Dialector.Name() == "sqlite" {
+		return fmt.Sprintf("CAST(strftime('%%s', timestamp) AS INTEGER) / %d * %d", seconds, seconds)
+	}
+	return fmt.Sprintf("UNIX_TIMESTAMP(timestamp) DIV %d * %d", seconds, seconds)
+}
+
+// aggregateColumns 按设备和时间桶分组，由数据库计算各独立列指标的聚合值和非空读数条数
+func (r *unifiedSensorDataRepository) aggregateColumns(db *gorm.DB, columns []string, interval time.Duration, aggregation models.AggregationType, series map[aggregateSeriesKey][]models.AggregatedPoint) error {
+	function := "AVG"
+	switch aggregation {
+	case models.AggregationMax:
+		function = "MAX"
+	case models.AggregationMin:
+		function = "MIN"
+	case models.AggregationSum:
+		function = "SUM"
+	}
+	selects := []string{"device_id", r.bucketExpression(interval) + " AS bucket"}
+	for _, column := range columns {
+		selects = append(selects, fmt.Sprintf("%s(%s)", function, column), fmt.Sprintf("COUNT(%s)", column))
+	}
+
+	rows, err := db.Select(strings.Join(selects, ", ")).Group("device_id, bucket").Rows()
+	if err != nil {
+		return fmt.Errorf("聚合传感器数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullFloat64, len(columns))
+	counts := make([]int64, len(columns))
+	for rows.Next() {
+		var deviceID string
+		var bucket int64
+		dest := []interface{}{&deviceID, &bucket}
+		for i := range columns {
+			dest = append(dest, &values[i], &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("读取聚合结果失败: %w", err)
+		}
+		for i, column := range columns {
+			if counts[i] == 0 || !values[i].Valid {
+				continue
+			}
+			key := aggregateSeriesKey{deviceID: deviceID, metric: column}
+			series[key] = append(series[key], models.AggregatedPoint{
+				Time:  time.Unix(bucket, 0),
+				Value: values[i].Float64,
+				Count: counts[i],
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取聚合结果失败: %w", err)
+	}
+	return nil
+}
+
+// aggregateExtended 逐行读取扩展数据并在内存中聚合，metrics为空时聚合扩展数据中出现的全部指标
+func (r *unifiedSensorDataRepository) aggregateExtended(db *gorm.DB, metrics []string, interval time.Duration, aggregation models.AggregationType, series map[aggregateSeriesKey][]models.AggregatedPoint) error {
+	rows, err := db.Select("device_id, timestamp, extended_data").Where("extended_data IS NOT NULL").Rows()
+	if err != nil {
+		return fmt.Errorf("查询传感器数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := make(map[aggregateSeriesKey]map[time.Time]*aggregateBucket)
+	for rows.Next() {
+		var item models.UnifiedSensorData
+		if err := r.db.ScanRows(rows, &item); err != nil {
+			return fmt.Errorf("读取传感器数据失败: %w", err)
+		}
+		start := models.BucketStart(item.Timestamp, interval)
+		names := metrics
+		if len(names) == 0 {
+			names = item.GetAvailableMetrics()
+		}
+		for _, metric := range names {
+			if models.IsColumnMetric(metric) {
+				continue
+			}
+			value := item.GetMetricValue(metric)
+			if value == nil {
+				continue
+			}
+			key := aggregateSeriesKey{deviceID: item.DeviceID, metric: metric}
+			if buckets[key] == nil {
+				buckets[key] = make(map[time.Time]*aggregateBucket)
+			}
+			bucket, ok := buckets[key][start]
+			if !ok {
+				bucket = &aggregateBucket{}
+				buckets[key][start] = bucket
+			}
+			bucket.add(*value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取传感器数据失败: %w", err)
+	}
+
+	for key, byStart := range buckets {
+		for start, bucket := range byStart {
+			series[key] = append(series[key], models.AggregatedPoint{
+				Time:  start,
+				Value: bucket.value(aggregation),
+				Count: bucket.count,
+			})
+		}
+	}
+	return nil
+}
+
+// GetMultiDeviceStatistics 获取多设备汇总统计
+func (r *unifiedSensorDataRepository) GetMultiDeviceStatistics(ctx context.Context, deviceIDs []string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
+	var stats models.UnifiedSensorDataStatistics
+	if len(deviceIDs) == 0 {
+		return &stats, nil
+	}
+
+	err := r.db.WithContext(ctx).Model(&models.UnifiedSensorData{}).
+		Where("device_id IN ? AND timestamp BETWEEN ? AND ?",
+			deviceIDs,
+			time.Unix(startTime, 0),
+			time.Unix(endTime, 0)).
+		Select(statisticsColumns).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
 // GetDeviceTypeStatistics 获取设备类型统计
 func (r *unifiedSensorDataRepository) GetDeviceTypeStatistics(ctx context.Context, deviceType models.DeviceType, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
 	var stats models.UnifiedSensorDataStatistics
@@ -237,30 +540,7 @@ func (r *unifiedSensorDataRepository) GetDeviceTypeStatistics(ctx context.Contex
 			time.Unix(endTime, 0))
 
 	// 计算统计数据
-	err := query.Select(`
-		COUNT(*) as data_count,
-		AVG(pm25) as pm25_avg,
-		MIN(pm25) as pm25_min,
-		MAX(pm25) as pm25_max,
-		AVG(pm10) as pm10_avg,
-		MIN(pm10) as pm10_min,
-		MAX(pm10) as pm10_max,
-		AVG(co2) as co2_avg,
-		MIN(co2) as co2_min,
-		MAX(co2) as co2_max,
-		AVG(formaldehyde) as formaldehyde_avg,
-		MIN(formaldehyde) as formaldehyde_min,
-		MAX(formaldehyde) as formaldehyde_max,
-		AVG(temperature) as temperature_avg,
-		MIN(temperature) as temperature_min,
-		MAX(temperature) as temperature_max,
-		AVG(humidity) as humidity_avg,
-		MIN(humidity) as humidity_min,
-		MAX(humidity) as humidity_max,
-		AVG(pressure) as pressure_avg,
-		MIN(pressure) as pressure_min,
-		MAX(pressure) as pressure_max
-	`).Scan(&stats).Error
+	err := query.Select(statisticsColumns).Scan(&stats).Error
 
 	if err != nil {
 		return nil, err
@@ -3,6 +3,8 @@ package repositories
 import (
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -16,6 +18,7 @@ type UserRepository interface {
 	GetByRole(role string) ([]models.User, error)
 	UpdateLastLogin(userID uint) error
 	ChangePassword(userID uint, hashedPassword string) error
+	CreateWithRoles(ctx context.Context, user *models.User, roleIDs []uint64) error
 }
 
 // userRepository 用户仓储实现
@@ -93,3 +96,23 @@ func (r *userRepository) ChangePassword(userID uint, hashedPassword string) erro
 	}
 	return nil
 }
+
+// CreateWithRoles 在同一事务中创建用户并分配角色，任一步失败时都不会留下用户
+func (r *userRepository) CreateWithRoles(ctx context.Context, user *models.User, roleIDs []uint64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: roleID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("创建用户失败", utils.ErrorField(err), utils.String("username", user.Username))
+		return fmt.Errorf("创建用户失败: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"air-quality-server/internal/aqi"
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/repositories"
 	"air-quality-server/internal/utils"
@@ -22,14 +23,19 @@ type AirQualityService interface {
 type airQualityService struct {
 	airQualityRepo repositories.AirQualityRepository
 	deviceRepo     repositories.DeviceRepository
+	standard       aqi.Standard
 	logger         utils.Logger
 }
 
-// NewAirQualityService 创建空气质量服务
-func NewAirQualityService(airQualityRepo repositories.AirQualityRepository, deviceRepo repositories.DeviceRepository, logger utils.Logger) AirQualityService {
+// NewAirQualityService 创建空气质量服务（standard为空时按HJ 633-2012计算AQI）
+func NewAirQualityService(airQualityRepo repositories.AirQualityRepository, deviceRepo repositories.DeviceRepository, standard aqi.Standard, logger utils.Logger) AirQualityService {
+	if standard == "" {
+		standard = aqi.DefaultStandard
+	}
 	return &airQualityService{
 		airQualityRepo: airQualityRepo,
 		deviceRepo:     deviceRepo,
+		standard:       standard,
 		logger:         logger,
 	}
 }
@@ -43,10 +49,12 @@ func (s *airQualityService) CreateData(ctx context.Context, data *models.AirQual
 
 	// 分析数据并记录
 	if analysis, err := s.AnalyzeData(ctx, data); err == nil {
-		s.logger.Info("空气质量数据分析完成",
-			utils.String("device_id", data.DeviceID),
-			utils.String("quality", analysis["quality"].(string)),
-			utils.Float64("aqi", analysis["aqi"].(float64)))
+		if index, ok := analysis["aqi"].(int); ok {
+			s.logger.Info("空气质量数据分析完成",
+				utils.String("device_id", data.DeviceID),
+				utils.String("quality", analysis["quality"].(string)),
+				utils.Int("aqi", index))
+		}
 	}
 
 	return nil
@@ -112,20 +120,21 @@ func (s *airQualityService) AnalyzeData(ctx context.Context, data *models.AirQua
 	analysis["device_id"] = data.DeviceID
 	analysis["timestamp"] = data.CreatedAt
 
-	// 计算AQI（简化版本）
-	var pm25, pm10 float64
+	// 旧数据表只有PM2.5和PM10，单条数据按平均浓度计算AQI
+	concentrations := make(aqi.Concentrations)
 	if data.PM25 != nil {
-		pm25 = *data.PM25
+		concentrations[aqi.PM25] = *data.PM25
 	}
 	if data.PM10 != nil {
-		pm10 = *data.PM10
+		concentrations[aqi.PM10] = *data.PM10
+	}
+	if result, err := aqi.Calculate(s.standard, concentrations); err == nil {
+		analysis["aqi"] = result.AQI
+		analysis["quality"] = result.Category.Label
+		analysis["primary_pollutants"] = result.PrimaryPollutants
+		// 健康建议
+		analysis["health_advice"] = healthAdvice(result.Category.Level)
 	}
-	aqi := s.calculateAQI(pm25, pm10)
-	analysis["aqi"] = aqi
-	analysis["quality"] = s.getQualityLevel(aqi)
-
-	// 健康建议
-	analysis["health_advice"] = s.getHealthAdvice(analysis["quality"].(string))
 
 	// 趋势分析（简化版本）
 	analysis["trend"] = s.analyzeTrend(data)
@@ -133,47 +142,20 @@ func (s *airQualityService) AnalyzeData(ctx context.Context, data *models.AirQua
 	return analysis, nil
 }
 
-// calculateAQI 计算AQI
-func (s *airQualityService) calculateAQI(pm2_5, pm10 float64) float64 {
-	// 简化的AQI计算，实际应该根据国家标准计算
-	aqi := (pm2_5*2.5 + pm10*1.5) / 4
-	if aqi > 500 {
-		return 500
-	}
-	return aqi
-}
-
-// getQualityLevel 获取空气质量等级
-func (s *airQualityService) getQualityLevel(aqi float64) string {
-	if aqi <= 50 {
-		return "优"
-	} else if aqi <= 100 {
-		return "良"
-	} else if aqi <= 150 {
-		return "轻度污染"
-	} else if aqi <= 200 {
-		return "中度污染"
-	} else if aqi <= 300 {
-		return "重度污染"
-	} else {
-		return "严重污染"
-	}
-}
-
-// getHealthAdvice 获取健康建议
-func (s *airQualityService) getHealthAdvice(qualityLevel string) string {
-	switch qualityLevel {
-	case "优":
+// healthAdvice 按AQI级别（1-6）获取健康建议
+func healthAdvice(level int) string {
+	switch level {
+	case 1:
 		return "空气质量令人满意，基本无空气污染，各类人群可正常活动。"
-	case "良":
+	case 2:
 		return "空气质量可以接受，但某些污染物可能对极少数异常敏感人群健康有较弱影响。"
-	case "轻度污染":
+	case 3:
 		return "易感人群症状有轻度加剧，健康人群出现刺激症状。建议儿童、老年人及心脏病、呼吸系统疾病患者应减少长时间、高强度的户外锻炼。"
-	case "中度污染":
+	case 4:
 		return "进一步加剧易感人群症状，可能对健康人群心脏、呼吸系统有影响。建议儿童、老年人及心脏病、呼吸系统疾病患者避免长时间、高强度的户外锻炼。"
-	case "重度污染":
+	case 5:
 		return "心脏病和肺病患者症状显著加剧，运动耐受力降低，健康人群普遍出现症状。建议儿童、老年人和心脏病、肺病患者应停留在室内，停止户外运动。"
-	case "严重污染":
+	case 6:
 		return "健康人群运动耐受力降低，有明显强烈症状，提前出现某些疾病。建议儿童、老年人和病人应当停留在室内，避免体力消耗，一般人群应避免户外活动。"
 	default:
 		return "建议关注空气质量变化，做好防护措施。"
@@ -5,9 +5,16 @@ import (
 	"air-quality-server/internal/repositories"
 	"air-quality-server/internal/utils"
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	ErrAlertNotFound        = errors.New("告警不存在")
+	ErrAlertAcknowledged    = errors.New("告警已确认")
+	ErrAlertAlreadyResolved = errors.New("告警已解决")
+)
+
 // AlertService 告警服务接口
 type AlertService interface {
 	CreateAlert(ctx context.Context, alert *models.Alert) error
@@ -19,22 +26,30 @@ type AlertService interface {
 	GetAlertsByDeviceID(ctx context.Context, deviceID string) ([]models.Alert, error)
 	GetAlertsByStatus(ctx context.Context, status string) ([]models.Alert, error)
 	GetUnresolvedAlerts(ctx context.Context) ([]models.Alert, error)
-	ResolveAlert(ctx context.Context, alertID uint) error
+	AcknowledgeAlert(ctx context.Context, alertID uint64, userID *uint64, comment string) (*models.Alert, error)
+	ResolveAlert(ctx context.Context, alertID uint64, userID *uint64, comment string) (*models.Alert, error)
+	GetAlertHistory(ctx context.Context, alertID uint64) ([]models.AlertHistory, error)
+	GetAlertHistories(ctx context.Context, alertIDs []uint64) (map[uint64][]models.AlertHistory, error)
 	GetAlertsByTimeRange(ctx context.Context, startTime, endTime int64) ([]models.Alert, error)
-	CheckAirQualityAlerts(ctx context.Context, data *models.AirQualityData) error
+	GetOpenRuleAlerts(ctx context.Context) ([]models.Alert, error)
+	GetOpenDeviceAlerts(ctx context.Context, deviceID, metric string) ([]models.Alert, error)
+	UpdateOpenAlertValue(ctx context.Context, alertID uint64, value float64) (bool, error)
+	AutoResolveAlert(ctx context.Context, alertID uint64, value float64) (bool, error)
 }
 
 // alertService 告警服务实现
 type alertService struct {
-	alertRepo repositories.AlertRepository
-	logger    utils.Logger
+	alertRepo  repositories.AlertRepository
+	dispatcher NotificationDispatcher
+	logger     utils.Logger
 }
 
-// NewAlertService 创建告警服务
-func NewAlertService(alertRepo repositories.AlertRepository, logger utils.Logger) AlertService {
+// NewAlertService 创建告警服务（dispatcher为nil时不发送通知）
+func NewAlertService(alertRepo repositories.AlertRepository, dispatcher NotificationDispatcher, logger utils.Logger) AlertService {
 	return &alertService{
-		alertRepo: alertRepo,
-		logger:    logger,
+		alertRepo:  alertRepo,
+		dispatcher: dispatcher,
+		logger:     logger,
 	}
 }
 
@@ -50,6 +65,9 @@ func (s *alertService) CreateAlert(ctx context.Context, alert *models.Alert) err
 	}
 
 	s.logger.Info("告警创建成功", utils.Int("alert_id", int(alert.ID)), utils.String("metric", alert.Metric))
+	if s.dispatcher != nil {
+		s.dispatcher.Dispatch(models.NotificationEventTriggered, alert)
+	}
 	return nil
 }
 
@@ -140,151 +158,115 @@ func (s *alertService) GetUnresolvedAlerts(ctx context.Context) ([]models.Alert,
 	return alerts, nil
 }
 
-// ResolveAlert 解决告警
-func (s *alertService) ResolveAlert(ctx context.Context, alertID uint) error {
-	if err := s.alertRepo.MarkAsResolved(alertID); err != nil {
-		s.logger.Error("解决告警失败", utils.ErrorField(err), utils.Int("alert_id", int(alertID)))
-		return err
-	}
-
-	s.logger.Info("告警已解决", utils.Int("alert_id", int(alertID)))
-	return nil
-}
-
-// GetAlertsByTimeRange 根据时间范围获取告警
-func (s *alertService) GetAlertsByTimeRange(ctx context.Context, startTime, endTime int64) ([]models.Alert, error) {
-	alerts, err := s.alertRepo.GetByTimeRange(startTime, endTime)
+// AcknowledgeAlert 确认告警，记录确认人及备注
+func (s *alertService) AcknowledgeAlert(ctx context.Context, alertID uint64, userID *uint64, comment string) (*models.Alert, error) {
+	alert, changed, err := s.alertRepo.Acknowledge(ctx, alertID, userID, comment, time.Now())
 	if err != nil {
-		s.logger.Error("根据时间范围获取告警失败", utils.ErrorField(err))
+		s.logger.Error("确认告警失败", utils.ErrorField(err), utils.Int64("alert_id", int64(alertID)))
 		return nil, err
 	}
-	return alerts, nil
-}
-
-// CheckAirQualityAlerts 检查空气质量告警
-func (s *alertService) CheckAirQualityAlerts(ctx context.Context, data *models.AirQualityData) error {
-	var alerts []models.Alert
-
-	// PM2.5告警检查
-	if data.PM25 != nil && *data.PM25 > 75 {
-		message := s.getPM2_5AlertMessage(*data.PM25)
-		alert := &models.Alert{
-			DeviceID:  data.DeviceID,
-			Metric:    "pm25",
-			Severity:  s.getAlertLevel(*data.PM25, 75, 150, 250),
-			Message:   &message,
-			Status:    "active",
-			CreatedAt: data.CreatedAt,
-		}
-		alerts = append(alerts, *alert)
+	if alert == nil {
+		return nil, ErrAlertNotFound
 	}
-
-	// PM10告警检查
-	if data.PM10 != nil && *data.PM10 > 150 {
-		message := s.getPM10AlertMessage(*data.PM10)
-		alert := &models.Alert{
-			DeviceID:  data.DeviceID,
-			Metric:    "pm10",
-			Severity:  s.getAlertLevel(*data.PM10, 150, 300, 500),
-			Message:   &message,
-			Status:    "active",
-			CreatedAt: data.CreatedAt,
+	if !changed {
+		if alert.Status == string(models.AlertStatusResolved) {
+			return nil, ErrAlertAlreadyResolved
 		}
-		alerts = append(alerts, *alert)
+		return nil, ErrAlertAcknowledged
 	}
 
-	// CO2告警检查
-	if data.CO2 != nil && *data.CO2 > 1000 {
-		message := s.getCO2AlertMessage(*data.CO2)
-		alert := &models.Alert{
-			DeviceID:  data.DeviceID,
-			Metric:    "co2",
-			Severity:  s.getAlertLevel(*data.CO2, 1000, 2000, 5000),
-			Message:   &message,
-			Status:    "active",
-			CreatedAt: data.CreatedAt,
-		}
-		alerts = append(alerts, *alert)
-	}
+	s.logger.Info("告警已确认", utils.Int64("alert_id", int64(alertID)), utils.Any("user_id", userID))
+	return alert, nil
+}
 
-	// 温度告警检查
-	if data.Temperature != nil && (*data.Temperature > 35 || *data.Temperature < -10) {
-		message := s.getTemperatureAlertMessage(*data.Temperature)
-		alert := &models.Alert{
-			DeviceID:  data.DeviceID,
-			Metric:    "temperature",
-			Severity:  "warning",
-			Message:   &message,
-			Status:    "active",
-			CreatedAt: data.CreatedAt,
-		}
-		alerts = append(alerts, *alert)
+// ResolveAlert 人工解决告警，记录处理人及备注
+func (s *alertService) ResolveAlert(ctx context.Context, alertID uint64, userID *uint64, comment string) (*models.Alert, error) {
+	alert, changed, err := s.alertRepo.Resolve(ctx, alertID, userID, comment, time.Now())
+	if err != nil {
+		s.logger.Error("解决告警失败", utils.ErrorField(err), utils.Int64("alert_id", int64(alertID)))
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	if !changed {
+		return nil, ErrAlertAlreadyResolved
 	}
 
-	// 湿度告警检查
-	if data.Humidity != nil && (*data.Humidity > 80 || *data.Humidity < 20) {
-		message := s.getHumidityAlertMessage(*data.Humidity)
-		alert := &models.Alert{
-			DeviceID:  data.DeviceID,
-			Metric:    "humidity",
-			Severity:  "warning",
-			Message:   &message,
-			Status:    "active",
-			CreatedAt: data.CreatedAt,
-		}
-		alerts = append(alerts, *alert)
+	s.logger.Info("告警已解决", utils.Int64("alert_id", int64(alertID)), utils.Any("user_id", userID))
+	if s.dispatcher != nil {
+		s.dispatcher.Dispatch(models.NotificationEventResolved, alert)
 	}
+	return alert, nil
+}
 
-	// 创建告警
-	for _, alert := range alerts {
-		if err := s.CreateAlert(ctx, &alert); err != nil {
-			s.logger.Error("创建空气质量告警失败", utils.ErrorField(err))
-		}
+// GetAlertHistory 获取告警状态变更历史
+func (s *alertService) GetAlertHistory(ctx context.Context, alertID uint64) ([]models.AlertHistory, error) {
+	if _, err := s.alertRepo.GetByID(ctx, alertID); err != nil {
+		return nil, ErrAlertNotFound
 	}
+	return s.alertRepo.GetHistory(ctx, alertID)
+}
 
-	return nil
+// GetAlertHistories 批量获取告警状态变更历史
+func (s *alertService) GetAlertHistories(ctx context.Context, alertIDs []uint64) (map[uint64][]models.AlertHistory, error) {
+	return s.alertRepo.GetHistories(ctx, alertIDs)
 }
 
-// getAlertLevel 获取告警级别
-func (s *alertService) getAlertLevel(value, warning, critical, severe float64) string {
-	if value >= severe {
-		return "severe"
-	} else if value >= critical {
-		return "critical"
-	} else if value >= warning {
-		return "warning"
+// GetAlertsByTimeRange 根据时间范围获取告警
+func (s *alertService) GetAlertsByTimeRange(ctx context.Context, startTime, endTime int64) ([]models.Alert, error) {
+	alerts, err := s.alertRepo.GetByTimeRange(startTime, endTime)
+	if err != nil {
+		s.logger.Error("根据时间范围获取告警失败", utils.ErrorField(err))
+		return nil, err
 	}
-	return "info"
+	return alerts, nil
 }
 
-// getPM2_5AlertMessage 获取PM2.5告警消息
-func (s *alertService) getPM2_5AlertMessage(value float64) string {
-	message := "PM2.5浓度过高"
-	return message
+// GetOpenRuleAlerts 获取由告警规则产生且未关闭的告警
+func (s *alertService) GetOpenRuleAlerts(ctx context.Context) ([]models.Alert, error) {
+	alerts, err := s.alertRepo.GetOpenRuleAlerts(ctx)
+	if err != nil {
+		s.logger.Error("获取未关闭的规则告警失败", utils.ErrorField(err))
+		return nil, err
+	}
+	return alerts, nil
 }
 
-// getPM10AlertMessage 获取PM10告警消息
-func (s *alertService) getPM10AlertMessage(value float64) string {
-	message := "PM10浓度过高"
-	return message
+// GetOpenDeviceAlerts 获取设备指定指标未关闭的告警
+func (s *alertService) GetOpenDeviceAlerts(ctx context.Context, deviceID, metric string) ([]models.Alert, error) {
+	return s.alertRepo.GetOpenDeviceAlerts(ctx, deviceID, metric)
 }
 
-// getCO2AlertMessage 获取CO2告警消息
-func (s *alertService) getCO2AlertMessage(value float64) string {
-	message := "CO2浓度过高"
-	return message
+// UpdateOpenAlertValue 更新未关闭告警的当前值，返回告警是否仍未关闭
+func (s *alertService) UpdateOpenAlertValue(ctx context.Context, alertID uint64, value float64) (bool, error) {
+	return s.alertRepo.UpdateOpenValue(ctx, alertID, value)
 }
 
-// getTemperatureAlertMessage 获取温度告警消息
-func (s *alertService) getTemperatureAlertMessage(value float64) string {
-	message := "温度异常"
-	return message
+// AutoResolveAlert 指标恢复正常后自动解决告警
+func (s *alertService) AutoResolveAlert(ctx context.Context, alertID uint64, value float64) (bool, error) {
+	resolved, err := s.alertRepo.ResolveOpen(ctx, alertID, value, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if resolved {
+		s.logger.Info("告警已自动解决", utils.Int64("alert_id", int64(alertID)), utils.Float64("value", value))
+		s.notifyResolved(ctx, alertID)
+	}
+	return resolved, nil
 }
 
-// getHumidityAlertMessage 获取湿度告警消息
-func (s *alertService) getHumidityAlertMessage(value float64) string {
-	message := "湿度异常"
-	return message
+// notifyResolved 发送告警解决通知
+func (s *alertService) notifyResolved(ctx context.Context, alertID uint64) {
+	if s.dispatcher == nil {
+		return
+	}
+	alert, err := s.alertRepo.GetByID(ctx, alertID)
+	if err != nil {
+		s.logger.Error("获取已解决告警失败，无法发送通知", utils.ErrorField(err), utils.Int64("alert_id", int64(alertID)))
+		return
+	}
+	s.dispatcher.Dispatch(models.NotificationEventResolved, alert)
 }
 
 // CountAlerts 获取告警总数
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertEvaluator 告警规则评估器接口
+type AlertEvaluator interface {
+	// Match 计算读数满足条件的告警（不考虑持续时间，不落库）
+	Match(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error)
+	// Evaluate 评估读数：条件持续满足后触发告警，已有告警更新当前值，恢复后自动解决
+	Evaluate(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error)
+}
+
+// alertKey 告警去重键（规则、设备、指标）
+type alertKey struct {
+	ruleID   uint64
+	deviceID string
+	metric   string
+}
+
+// alertState 单个去重键的评估状态
+type alertState struct {
+	mu      sync.Mutex
+	since   time.Time // 条件开始持续满足的时间，零值表示当前不满足
+	alertID uint64    // 未关闭的告警ID，0表示无
+}
+
+// alertEvaluator 告警规则评估器实现
+type alertEvaluator struct {
+	ruleService  AlertRuleService
+	alertService AlertService
+	scope        DeviceScopeService
+	logger       utils.Logger
+
+	mu     sync.Mutex
+	loaded bool
+	states map[alertKey]*alertState
+}
+
+// NewAlertEvaluator 创建告警规则评估器（scope为空时限定分组或位置的规则不生效）
+func NewAlertEvaluator(ruleService AlertRuleService, alertService AlertService, scope DeviceScopeService, logger utils.Logger) AlertEvaluator {
+	return &alertEvaluator{
+		ruleService:  ruleService,
+		alertService: alertService,
+		scope:        scope,
+		logger:       logger,
+		states:       make(map[alertKey]*alertState),
+	}
+}
+
+// Match 按所有适用的启用规则评估读数
+func (e *alertEvaluator) Match(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error) {
+	rules, err := e.ruleService.GetEnabledRules(ctx)
+	if err != nil {
+		e.logger.Error("获取告警规则失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	var alerts []models.Alert
+	now := time.Now()
+	applies := e.ruleFilter(ctx, data.DeviceID)
+	for i := range rules {
+		rule := &rules[i]
+		if !applies(rule) {
+			continue
+		}
+
+		value := readingValue(data, rule.Metric)
+		if value == nil {
+			continue
+		}
+
+		condition := models.AlertConditionType(rule.ConditionType)
+		if !condition.Matches(*value, rule.ThresholdValue) {
+			continue
+		}
+
+		message := fmt.Sprintf("%s: 当前值 %.3f %s 阈值 %.3f",
+			rule.Name, *value, condition.Symbol(), rule.ThresholdValue)
+		alerts = append(alerts, models.Alert{
+			RuleID:         rule.ID,
+			DeviceID:       data.DeviceID,
+			Metric:         rule.Metric,
+			CurrentValue:   *value,
+			ThresholdValue: rule.ThresholdValue,
+			Severity:       rule.Severity,
+			Status:         string(models.AlertStatusActive),
+			TriggeredAt:    now,
+			Message:        &message,
+		})
+	}
+	return alerts, nil
+}
+
+// Evaluate 按规则评估读数并维护告警生命周期，返回新触发的告警
+func (e *alertEvaluator) Evaluate(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error) {
+	if err := e.loadOpenAlerts(ctx); err != nil {
+		return nil, err
+	}
+
+	rules, err := e.ruleService.GetEnabledRules(ctx)
+	if err != nil {
+		e.logger.Error("获取告警规则失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	at := data.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var fired []models.Alert
+	applies := e.ruleFilter(ctx, data.DeviceID)
+	for i := range rules {
+		rule := &rules[i]
+		if !applies(rule) {
+			continue
+		}
+		value := readingValue(data, rule.Metric)
+		if value == nil {
+			continue
+		}
+
+		alert, err := e.evaluateRule(ctx, rule, data.DeviceID, *value, at)
+		if err != nil {
+			e.logger.Error("评估告警规则失败",
+				utils.String("device_id", data.DeviceID),
+				utils.Int64("rule_id", int64(rule.ID)),
+				utils.ErrorField(err))
+			continue
+		}
+		if alert != nil {
+			fired = append(fired, *alert)
+		}
+	}
+	return fired, nil
+}
+
+// ruleFilter 返回判断规则是否适用于设备的函数，限定范围的规则首次出现时才查询设备归属
+func (e *alertEvaluator) ruleFilter(ctx context.Context, deviceID string) func(rule *models.AlertRule) bool {
+	var membership *models.DeviceMembership
+	loaded := false
+	return func(rule *models.AlertRule) bool {
+		if !rule.AppliesTo(deviceID) {
+			return false
+		}
+		if !rule.HasScope() {
+			return true
+		}
+		if !loaded && e.scope != nil {
+			loaded = true
+			m, err := e.scope.Membership(ctx, deviceID)
+			if err != nil {
+				e.logger.Error("获取设备归属失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+			}
+			membership = m
+		}
+		return rule.InScope(membership)
+	}
+}
+
+// evaluateRule 评估单条规则，条件持续满足DurationSeconds后触发
+func (e *alertEvaluator) evaluateRule(ctx context.Context, rule *models.AlertRule, deviceID string, value float64, at time.Time) (*models.Alert, error) {
+	state := e.state(alertKey{ruleID: rule.ID, deviceID: deviceID, metric: rule.Metric})
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	condition := models.AlertConditionType(rule.ConditionType)
+	if !condition.Matches(value, rule.ThresholdValue) {
+		state.since = time.Time{}
+		if state.alertID == 0 {
+			return nil, nil
+		}
+
+		// 回到恢复区间时自动解决，处于回差区间内仅更新当前值
+		if rule.IsCleared(value) {
+			if _, err := e.alertService.AutoResolveAlert(ctx, state.alertID, value); err != nil {
+				return nil, err
+			}
+			state.alertID = 0
+			return nil, nil
+		}
+		open, err := e.alertService.UpdateOpenAlertValue(ctx, state.alertID, value)
+		if err != nil {
+			return nil, err
+		}
+		if !open {
+			state.alertID = 0
+		}
+		return nil, nil
+	}
+
+	if state.since.IsZero() || at.Before(state.since) {
+		state.since = at
+	}
+
+	// 已有未关闭的告警时只更新当前值（告警被手动解决后重新计算）
+	if state.alertID != 0 {
+		open, err := e.alertService.UpdateOpenAlertValue(ctx, state.alertID, value)
+		if err != nil {
+			return nil, err
+		}
+		if open {
+			return nil, nil
+		}
+		state.alertID = 0
+	}
+
+	if at.Sub(state.since) < time.Duration(rule.DurationSeconds)*time.Second {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("%s: 当前值 %.3f %s 阈值 %.3f",
+		rule.Name, value, condition.Symbol(), rule.ThresholdValue)
+	if rule.DurationSeconds > 0 {
+		message += fmt.Sprintf("，已持续 %d 秒", int(at.Sub(state.since).Seconds()))
+	}
+	alert := &models.Alert{
+		RuleID:         rule.ID,
+		DeviceID:       deviceID,
+		Metric:         rule.Metric,
+		CurrentValue:   value,
+		ThresholdValue: rule.ThresholdValue,
+		Severity:       rule.Severity,
+		Status:         string(models.AlertStatusActive),
+		TriggeredAt:    at,
+		Message:        &message,
+	}
+	if err := e.alertService.CreateAlert(ctx, alert); err != nil {
+		return nil, err
+	}
+	state.alertID = alert.ID
+	return alert, nil
+}
+
+// state 获取去重键对应的评估状态
+func (e *alertEvaluator) state(key alertKey) *alertState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.states[key]
+	if !ok {
+		state = &alertState{}
+		e.states[key] = state
+	}
+	return state
+}
+
+// loadOpenAlerts 首次评估时加载未关闭的规则告警，避免重启后重复告警
+func (e *alertEvaluator) loadOpenAlerts(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loaded {
+		return nil
+	}
+
+	alerts, err := e.alertService.GetOpenRuleAlerts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		key := alertKey{ruleID: alert.RuleID, deviceID: alert.DeviceID, metric: alert.Metric}
+		state, ok := e.states[key]
+		if !ok {
+			state = &alertState{}
+			e.states[key] = state
+		}
+		// 同一键存在多条历史告警时保留最新一条
+		state.alertID = alert.ID
+		state.since = alert.TriggeredAt
+	}
+	e.loaded = true
+
+	e.logger.Debug("已加载未关闭的规则告警", utils.Int("count", len(alerts)))
+	return nil
+}
+
+// readingValue 获取读数中的指标值（含电量、信号强度等设备状态指标）
+func readingValue(data *models.UnifiedSensorData, metric string) *float64 {
+	switch metric {
+	case "battery":
+		if data.Battery == nil {
+			return nil
+		}
+		value := float64(*data.Battery)
+		return &value
+	case "signal_strength":
+		if data.SignalStrength == nil {
+			return nil
+		}
+		value := float64(*data.SignalStrength)
+		return &value
+	default:
+		return data.GetMetricValue(metric)
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"air-quality-server/internal/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// evaluatorFixture 告警规则评估测试环境
+type evaluatorFixture struct {
+	rules     AlertRuleService
+	alerts    repositories.AlertRepository
+	evaluator AlertEvaluator
+	logger    utils.Logger
+}
+
+// newEvaluatorFixture 创建基于测试数据库的规则服务和评估器
+func newEvaluatorFixture(t *testing.T) *evaluatorFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	f := &evaluatorFixture{
+		rules:  NewAlertRuleService(repositories.NewAlertRuleRepository(db, logger), logger),
+		alerts: repositories.NewAlertRepository(db, logger),
+		logger: logger,
+	}
+	f.evaluator = NewAlertEvaluator(f.rules, NewAlertService(f.alerts, nil, logger), nil, logger)
+	return f
+}
+
+// createRule 创建告警规则
+func (f *evaluatorFixture) createRule(t *testing.T, req *models.AlertRuleCreateRequest) *models.AlertRule {
+	rule, err := f.rules.CreateRule(context.Background(), req)
+	require.NoError(t, err)
+	return rule
+}
+
+// evaluate 评估一条读数，返回新触发的告警
+func (f *evaluatorFixture) evaluate(t *testing.T, data *models.UnifiedSensorData) []models.Alert {
+	alerts, err := f.evaluator.Evaluate(context.Background(), data)
+	require.NoError(t, err)
+	return alerts
+}
+
+// TestAlertConditionType_Matches 测试告警条件运算符
+func TestAlertConditionType_Matches(t *testing.T) {
+	tests := []struct {
+		condition models.AlertConditionType
+		value     float64
+		threshold float64
+		expected  bool
+	}{
+		{models.AlertConditionGT, 0.09, 0.08, true},
+		{models.AlertConditionGT, 0.08, 0.08, false},
+		{models.AlertConditionGTE, 0.08, 0.08, true},
+		{models.AlertConditionLT, 15, 20, true},
+		{models.AlertConditionLTE, 20, 20, true},
+		{models.AlertConditionLTE, 20.1, 20, false},
+		{models.AlertConditionEQ, 0, 0, true},
+		{models.AlertConditionEQ, 0.1 + 0.2, 0.3, true},
+		{models.AlertConditionNE, 1, 0, true},
+		{models.AlertConditionType("between"), 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.condition), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.condition.Matches(tt.value, tt.threshold))
+		})
+	}
+}
+
+// TestAlertEvaluator_RuleChanges 测试规则变更后评估结果立即生效，禁用的规则不参与评估
+func TestAlertEvaluator_RuleChanges(t *testing.T) {
+	f := newEvaluatorFixture(t)
+	rule := f.createRule(t, &models.AlertRuleCreateRequest{
+		Name:           "电量低",
+		Metric:         "battery",
+		ConditionType:  string(models.AlertConditionLT),
+		ThresholdValue: 20,
+	})
+	battery := 15
+	reading := &models.UnifiedSensorData{DeviceID: testutil.DeviceID1, Battery: &battery}
+
+	// 各步骤依次执行，规则变更在同一评估器上累积
+	steps := []struct {
+		name   string
+		change func(t *testing.T)
+		fired  int
+	}{
+		{"rule matches", func(t *testing.T) {}, 1},
+		{"threshold lowered", func(t *testing.T) {
+			threshold := 10.0
+			_, err := f.rules.UpdateRule(context.Background(), rule.ID, &models.AlertRuleUpdateRequest{ThresholdValue: &threshold})
+			require.NoError(t, err)
+		}, 0},
+		{"disabled rule", func(t *testing.T) {
+			disabled := false
+			f.createRule(t, &models.AlertRuleCreateRequest{
+				Name:           "电量低（禁用）",
+				Metric:         "battery",
+				ConditionType:  string(models.AlertConditionLT),
+				ThresholdValue: 50,
+				Enabled:        &disabled,
+			})
+		}, 0},
+	}
+	for _, step := range steps {
+		step.change(t)
+		alerts := f.evaluate(t, reading)
+		require.Len(t, alerts, step.fired, step.name)
+		for _, alert := range alerts {
+			assert.Equal(t, rule.ID, alert.RuleID, step.name)
+			assert.Equal(t, string(models.AlertSeverityWarning), alert.Severity, step.name)
+			assert.NotZero(t, alert.ID, step.name)
+		}
+	}
+
+	// 非法条件类型
+	_, err := f.rules.CreateRule(context.Background(), &models.AlertRuleCreateRequest{
+		Name:          "非法规则",
+		Metric:        "pm25",
+		ConditionType: "between",
+	})
+	assert.Error(t, err)
+}
+
+// TestAlertEvaluator_SustainedAndHysteresis 测试持续时间、告警去重及回差自动恢复
+func TestAlertEvaluator_SustainedAndHysteresis(t *testing.T) {
+	f := newEvaluatorFixture(t)
+	ctx := context.Background()
+	rule := f.createRule(t, &models.AlertRuleCreateRequest{
+		Name:            "甲醛浓度超标",
+		Metric:          "formaldehyde",
+		ConditionType:   string(models.AlertConditionGT),
+		ThresholdValue:  0.08,
+		DurationSeconds: 60,
+		Hysteresis:      0.02,
+	})
+
+	start := time.Now().Add(-time.Hour)
+	reading := func(offset time.Duration, value float64) *models.UnifiedSensorData {
+		return &models.UnifiedSensorData{DeviceID: testutil.DeviceID1, Timestamp: start.Add(offset), Formaldehyde: &value}
+	}
+	openAlerts := func() []models.Alert {
+		alerts, err := f.alerts.GetOpenRuleAlerts(ctx)
+		require.NoError(t, err)
+		return alerts
+	}
+
+	// 依次评估的读数：未持续满足60秒不触发，中途恢复则重新计时；持续超标只更新同一条告警；
+	// 回差区间内（0.06, 0.08]不恢复，低于恢复阈值后自动解决
+	steps := []struct {
+		offset time.Duration
+		value  float64
+		fired  int
+		open   int
+	}{
+		{0, 0.09, 0, 0},
+		{30 * time.Second, 0.09, 0, 0},
+		{45 * time.Second, 0.05, 0, 0},
+		{60 * time.Second, 0.09, 0, 0},
+		{100 * time.Second, 0.09, 0, 0},
+		{120 * time.Second, 0.095, 1, 1},
+		{180 * time.Second, 0.11, 0, 1},
+		{240 * time.Second, 0.07, 0, 1},
+		{300 * time.Second, 0.05, 0, 0},
+	}
+	var fired []models.Alert
+	for _, step := range steps {
+		alerts := f.evaluate(t, reading(step.offset, step.value))
+		require.Len(t, alerts, step.fired, "offset %s", step.offset)
+		fired = append(fired, alerts...)
+		open := openAlerts()
+		require.Len(t, open, step.open, "offset %s", step.offset)
+		if step.open > 0 {
+			assert.Equal(t, fired[0].ID, open[0].ID)
+			assert.InDelta(t, step.value, open[0].CurrentValue, 0.001, "offset %s", step.offset)
+		}
+	}
+	require.Len(t, fired, 1)
+	assert.Equal(t, rule.ID, fired[0].RuleID)
+	resolved, err := f.alerts.GetByID(ctx, fired[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, string(models.AlertStatusResolved), resolved.Status)
+	assert.NotNil(t, resolved.ResolvedAt)
+
+	// 恢复阈值须位于正常值一侧
+	clear := 0.09
+	_, err = f.rules.UpdateRule(ctx, rule.ID, &models.AlertRuleUpdateRequest{ClearThreshold: &clear})
+	assert.Error(t, err)
+
+	// 重启后加载未关闭的告警，不重复触发
+	assert.Empty(t, f.evaluate(t, reading(400*time.Second, 0.09)))
+	require.Len(t, f.evaluate(t, reading(470*time.Second, 0.09)), 1)
+	f.evaluator = NewAlertEvaluator(f.rules, NewAlertService(f.alerts, nil, f.logger), nil, f.logger)
+	assert.Empty(t, f.evaluate(t, reading(500*time.Second, 0.1)))
+	assert.Len(t, openAlerts(), 1)
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// alertRuleCacheTTL 告警规则缓存有效期（兜底刷新直接修改数据库的情况）
+const alertRuleCacheTTL = time.Minute
+
+// AlertRuleService 告警规则服务接口
+type AlertRuleService interface {
+	CreateRule(ctx context.Context, req *models.AlertRuleCreateRequest) (*models.AlertRule, error)
+	GetRule(ctx context.Context, id uint64) (*models.AlertRule, error)
+	UpdateRule(ctx context.Context, id uint64, req *models.AlertRuleUpdateRequest) (*models.AlertRule, error)
+	DeleteRule(ctx context.Context, id uint64) error
+	ListRules(ctx context.Context, req *models.AlertRuleListRequest) (*models.AlertRuleListResponse, error)
+	GetEnabledRules(ctx context.Context) ([]models.AlertRule, error)
+}
+
+// alertRuleService 告警规则服务实现
+type alertRuleService struct {
+	ruleRepo repositories.AlertRuleRepository
+	logger   utils.Logger
+
+	mu       sync.RWMutex
+	cache    []models.AlertRule
+	loadedAt time.Time
+}
+
+// NewAlertRuleService 创建告警规则服务
+func NewAlertRuleService(ruleRepo repositories.AlertRuleRepository, logger utils.Logger) AlertRuleService {
+	return &alertRuleService{
+		ruleRepo: ruleRepo,
+		logger:   logger,
+	}
+}
+
+// CreateRule 创建告警规则
+func (s *alertRuleService) CreateRule(ctx context.Context, req *models.AlertRuleCreateRequest) (*models.AlertRule, error) {
+	if req.Name == "" {
+		return nil, errors.New("规则名称不能为空")
+	}
+	if req.Metric == "" {
+		return nil, errors.New("监测指标不能为空")
+	}
+	if !models.AlertConditionType(req.ConditionType).IsValid() {
+		return nil, fmt.Errorf("不支持的条件类型: %s", req.ConditionType)
+	}
+	if req.DurationSeconds < 0 {
+		return nil, errors.New("持续时间不能为负数")
+	}
+	if err := validateAlertRecovery(req.ConditionType, req.ThresholdValue, req.ClearThreshold, req.Hysteresis); err != nil {
+		return nil, err
+	}
+
+	severity := req.Severity
+	if severity == "" {
+		severity = string(models.AlertSeverityWarning)
+	}
+	if !models.AlertSeverity(severity).IsValid() {
+		return nil, fmt.Errorf("不支持的告警级别: %s", severity)
+	}
+
+	channels, err := encodeNotificationChannels(req.NotificationChannels)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &models.AlertRule{
+		Name:                 req.Name,
+		DeviceID:             req.DeviceID,
+		GroupID:              scopeID(req.GroupID),
+		LocationID:           scopeID(req.LocationID),
+		Metric:               req.Metric,
+		ConditionType:        req.ConditionType,
+		ThresholdValue:       req.ThresholdValue,
+		DurationSeconds:      req.DurationSeconds,
+		ClearThreshold:       req.ClearThreshold,
+		Hysteresis:           req.Hysteresis,
+		Severity:             severity,
+		Enabled:              true,
+		NotificationChannels: channels,
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		s.logger.Error("创建告警规则失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	// Enabled列有默认值，创建时零值会被忽略，禁用状态需单独更新
+	if req.Enabled != nil && !*req.Enabled {
+		if err := s.ruleRepo.Update(ctx, rule.ID, map[string]interface{}{"enabled": false}); err != nil {
+			s.logger.Error("设置告警规则禁用状态失败", utils.ErrorField(err), utils.Int64("rule_id", int64(rule.ID)))
+			return nil, err
+		}
+		rule.Enabled = false
+	}
+	s.invalidate()
+
+	s.logger.Info("告警规则创建成功",
+		utils.Int64("rule_id", int64(rule.ID)),
+		utils.String("name", rule.Name),
+		utils.String("metric", rule.Metric))
+	return rule, nil
+}
+
+// GetRule 获取告警规则
+func (s *alertRuleService) GetRule(ctx context.Context, id uint64) (*models.AlertRule, error) {
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// UpdateRule 更新告警规则
+func (s *alertRuleService) UpdateRule(ctx context.Context, id uint64, req *models.AlertRuleUpdateRequest) (*models.AlertRule, error) {
+	rule, err := s.ruleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			return nil, errors.New("规则名称不能为空")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.DeviceID != nil {
+		updates["device_id"] = *req.DeviceID
+	}
+	if req.GroupID != nil {
+		updates["group_id"] = scopeID(req.GroupID)
+	}
+	if req.LocationID != nil {
+		updates["location_id"] = scopeID(req.LocationID)
+	}
+	if req.Metric != nil {
+		if *req.Metric == "" {
+			return nil, errors.New("监测指标不能为空")
+		}
+		updates["metric"] = *req.Metric
+	}
+	if req.ConditionType != nil {
+		if !models.AlertConditionType(*req.ConditionType).IsValid() {
+			return nil, fmt.Errorf("不支持的条件类型: %s", *req.ConditionType)
+		}
+		updates["condition_type"] = *req.ConditionType
+		rule.ConditionType = *req.ConditionType
+	}
+	if req.ThresholdValue != nil {
+		updates["threshold_value"] = *req.ThresholdValue
+		rule.ThresholdValue = *req.ThresholdValue
+	}
+	if req.ClearThreshold != nil {
+		updates["clear_threshold"] = *req.ClearThreshold
+		rule.ClearThreshold = req.ClearThreshold
+	}
+	if req.Hysteresis != nil {
+		updates["hysteresis"] = *req.Hysteresis
+		rule.Hysteresis = *req.Hysteresis
+	}
+	if err := validateAlertRecovery(rule.ConditionType, rule.ThresholdValue, rule.ClearThreshold, rule.Hysteresis); err != nil {
+		return nil, err
+	}
+	if req.DurationSeconds != nil {
+		if *req.DurationSeconds < 0 {
+			return nil, errors.New("持续时间不能为负数")
+		}
+		updates["duration_seconds"] = *req.DurationSeconds
+	}
+	if req.Severity != nil {
+		if !models.AlertSeverity(*req.Severity).IsValid() {
+			return nil, fmt.Errorf("不支持的告警级别: %s", *req.Severity)
+		}
+		updates["severity"] = *req.Severity
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.NotificationChannels != nil {
+		channels, err := encodeNotificationChannels(req.NotificationChannels)
+		if err != nil {
+			return nil, err
+		}
+		updates["notification_channels"] = channels
+	}
+
+	if len(updates) > 0 {
+		if err := s.ruleRepo.Update(ctx, id, updates); err != nil {
+			s.logger.Error("更新告警规则失败", utils.ErrorField(err), utils.Int64("rule_id", int64(id)))
+			return nil, err
+		}
+		s.invalidate()
+	}
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// DeleteRule 删除告警规则
+func (s *alertRuleService) DeleteRule(ctx context.Context, id uint64) error {
+	if err := s.ruleRepo.Delete(ctx, id); err != nil {
+		s.logger.Error("删除告警规则失败", utils.ErrorField(err), utils.Int64("rule_id", int64(id)))
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// ListRules 分页列出告警规则
+func (s *alertRuleService) ListRules(ctx context.Context, req *models.AlertRuleListRequest) (*models.AlertRuleListResponse, error) {
+	conditions := map[string]interface{}{}
+	if req.DeviceID != "" {
+		conditions["device_id"] = req.DeviceID
+	}
+	if req.GroupID != nil {
+		conditions["group_id"] = *req.GroupID
+	}
+	if req.LocationID != nil {
+		conditions["location_id"] = *req.LocationID
+	}
+	if req.Metric != "" {
+		conditions["metric"] = req.Metric
+	}
+	if req.Severity != "" {
+		conditions["severity"] = req.Severity
+	}
+	if req.Enabled != nil {
+		conditions["enabled"] = *req.Enabled
+	}
+
+	resp, err := s.ruleRepo.List(ctx, &repositories.ListRequest{
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+		OrderBy:    "id",
+		Order:      "asc",
+		Conditions: conditions,
+	})
+	if err != nil {
+		s.logger.Error("获取告警规则列表失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	return &models.AlertRuleListResponse{
+		Rules:    resp.Data,
+		Total:    resp.Total,
+		Page:     resp.Page,
+		PageSize: resp.PageSize,
+	}, nil
+}
+
+// GetEnabledRules 从缓存获取启用的告警规则
+func (s *alertRuleService) GetEnabledRules(ctx context.Context) ([]models.AlertRule, error) {
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.loadedAt) < alertRuleCacheTTL {
+		rules := s.cache
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	rules, err := s.ruleRepo.GetEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.AlertRule{}
+	}
+
+	s.mu.Lock()
+	s.cache = rules
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Debug("告警规则缓存已刷新", utils.Int("rules_count", len(rules)))
+	return rules, nil
+}
+
+// invalidate 使规则缓存失效
+func (s *alertRuleService) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+// validateAlertRecovery 校验恢复阈值与回差（恢复阈值须位于正常值一侧）
+func validateAlertRecovery(conditionType string, threshold float64, clearThreshold *float64, hysteresis float64) error {
+	if hysteresis < 0 {
+		return errors.New("恢复回差不能为负数")
+	}
+	if clearThreshold == nil {
+		return nil
+	}
+	switch models.AlertConditionType(conditionType) {
+	case models.AlertConditionGT, models.AlertConditionGTE:
+		if *clearThreshold > threshold {
+			return fmt.Errorf("恢复阈值 %.3f 不能高于告警阈值 %.3f", *clearThreshold, threshold)
+		}
+	case models.AlertConditionLT, models.AlertConditionLTE:
+		if *clearThreshold < threshold {
+			return fmt.Errorf("恢复阈值 %.3f 不能低于告警阈值 %.3f", *clearThreshold, threshold)
+		}
+	}
+	return nil
+}
+
+// scopeID 规则范围ID，0表示取消限定
+func scopeID(id *uint64) *uint64 {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	return id
+}
+
+// encodeNotificationChannels 校验并序列化通知渠道
+func encodeNotificationChannels(channels []string) (*string, error) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+	for _, channel := range channels {
+		if !models.NotificationChannel(channel).IsValid() {
+			return nil, fmt.Errorf("不支持的通知渠道: %s", channel)
+		}
+	}
+	data, err := json.Marshal(channels)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAlert 创建一条未确认的甲醛告警
+func newTestAlert(t *testing.T, alertService AlertService) *models.Alert {
+	alert := &models.Alert{
+		RuleID:         1,
+		DeviceID:       testutil.DeviceID1,
+		Metric:         "formaldehyde",
+		CurrentValue:   0.12,
+		ThresholdValue: 0.08,
+		Severity:       string(models.AlertSeverityCritical),
+		Status:         string(models.AlertStatusActive),
+		TriggeredAt:    time.Now(),
+	}
+	require.NoError(t, alertService.CreateAlert(context.Background(), alert))
+	return alert
+}
+
+// TestAlertService_AcknowledgeAndResolve 测试告警确认、解决的状态流转及状态变更历史
+func TestAlertService_AcknowledgeAndResolve(t *testing.T) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+	alertService := NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger)
+	alert := newTestAlert(t, alertService)
+	userID := uint64(7)
+
+	// 各操作依次作用于同一条告警
+	acknowledge := func(id uint64, comment string) (*models.Alert, error) {
+		return alertService.AcknowledgeAlert(ctx, id, &userID, comment)
+	}
+	resolve := func(id uint64, comment string) (*models.Alert, error) {
+		return alertService.ResolveAlert(ctx, id, &userID, comment)
+	}
+	steps := []struct {
+		name    string
+		op      func(id uint64, comment string) (*models.Alert, error)
+		id      uint64
+		comment string
+		err     error
+		status  models.AlertStatus
+	}{
+		{"acknowledge", acknowledge, alert.ID, "已通知现场人员", nil, models.AlertStatusAcknowledged},
+		{"acknowledge twice", acknowledge, alert.ID, "", ErrAlertAcknowledged, ""},
+		{"resolve", resolve, alert.ID, "已开窗通风", nil, models.AlertStatusResolved},
+		{"resolve twice", resolve, alert.ID, "", ErrAlertAlreadyResolved, ""},
+		{"acknowledge resolved", acknowledge, alert.ID, "", ErrAlertAlreadyResolved, ""},
+		{"missing alert", acknowledge, 9999, "", ErrAlertNotFound, ""},
+	}
+	for _, step := range steps {
+		updated, err := step.op(step.id, step.comment)
+		if step.err != nil {
+			assert.ErrorIs(t, err, step.err, step.name)
+			continue
+		}
+		require.NoError(t, err, step.name)
+		assert.Equal(t, string(step.status), updated.Status, step.name)
+	}
+
+	stored, err := alertService.GetAlert(ctx, uint(alert.ID))
+	require.NoError(t, err)
+	require.NotNil(t, stored.AcknowledgedBy)
+	assert.Equal(t, userID, *stored.AcknowledgedBy)
+	assert.NotNil(t, stored.AcknowledgedAt)
+	require.NotNil(t, stored.ResolvedBy)
+	assert.Equal(t, userID, *stored.ResolvedBy)
+
+	history, err := alertService.GetAlertHistory(ctx, alert.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, models.AlertActionTriggered, history[0].Action)
+	assert.Nil(t, history[0].UserID)
+	assert.Equal(t, models.AlertActionAcknowledged, history[1].Action)
+	assert.Equal(t, string(models.AlertStatusActive), history[1].FromStatus)
+	require.NotNil(t, history[1].Comment)
+	assert.Equal(t, "已通知现场人员", *history[1].Comment)
+	assert.Equal(t, models.AlertActionResolved, history[2].Action)
+	assert.Equal(t, string(models.AlertStatusAcknowledged), history[2].FromStatus)
+	assert.Equal(t, userID, *history[2].UserID)
+
+	// 自动恢复记录为系统操作
+	auto := newTestAlert(t, alertService)
+	ok, err := alertService.AutoResolveAlert(ctx, auto.ID, 0.05)
+	require.NoError(t, err)
+	require.True(t, ok)
+	histories, err := alertService.GetAlertHistories(ctx, []uint64{alert.ID, auto.ID})
+	require.NoError(t, err)
+	assert.Len(t, histories[alert.ID], 3)
+	require.Len(t, histories[auto.ID], 2)
+	assert.Equal(t, models.AlertActionAutoResolved, histories[auto.ID][1].Action)
+	assert.Nil(t, histories[auto.ID][1].UserID)
+	assert.InDelta(t, 0.05, *histories[auto.ID][1].Value, 0.001)
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// revokedTokenKeyPrefix Redis令牌黑名单键前缀
+const revokedTokenKeyPrefix = "auth:revoked:"
+
+// ErrTokenRevoked 令牌已注销
+var ErrTokenRevoked = errors.New("令牌已注销")
+
+// AuthService 认证服务接口
+type AuthService interface {
+	Login(ctx context.Context, username, password string) (*models.UserLoginResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.UserLoginResponse, error)
+	Logout(ctx context.Context, claims *utils.JWTClaims, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (*models.User, *utils.JWTClaims, error)
+}
+
+// authService 认证服务实现
+type authService struct {
+	cfg         config.JWTConfig
+	userService UserService
+	revokedRepo repositories.RevokedTokenRepository
+	cache       *utils.Cache
+	logger      utils.Logger
+}
+
+// NewAuthService 创建认证服务，redis为nil时令牌黑名单仅使用数据库
+func NewAuthService(cfg config.JWTConfig, userService UserService, revokedRepo repositories.RevokedTokenRepository, redis *utils.Redis, logger utils.Logger) AuthService {
+	if cfg.ExpireHours <= 0 {
+		cfg.ExpireHours = 24
+	}
+	if cfg.RefreshExpireHours <= 0 {
+		cfg.RefreshExpireHours = 168
+	}
+
+	s := &authService{
+		cfg:         cfg,
+		userService: userService,
+		revokedRepo: revokedRepo,
+		logger:      logger,
+	}
+	if redis != nil {
+		s.cache = utils.NewCache(redis)
+	}
+	return s
+}
+
+// Login 校验用户名密码并签发访问令牌和刷新令牌
+func (s *authService) Login(ctx context.Context, username, password string) (*models.UserLoginResponse, error) {
+	user, err := s.userService.AuthenticateUser(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userService.UpdateLastLogin(ctx, uint(user.ID)); err != nil {
+		s.logger.Warn("更新最后登录时间失败", utils.ErrorField(err), utils.Int64("user_id", int64(user.ID)))
+	}
+
+	return s.issueTokens(user)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧刷新令牌随即注销
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*models.UserLoginResponse, error) {
+	claims, err := s.parseToken(ctx, refreshToken, utils.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revoke(ctx, claims); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user)
+}
+
+// Logout 注销当前访问令牌，提供刷新令牌时一并注销
+func (s *authService) Logout(ctx context.Context, claims *utils.JWTClaims, refreshToken string) error {
+	if err := s.revoke(ctx, claims); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	refreshClaims, err := s.parseToken(ctx, refreshToken, utils.TokenTypeRefresh)
+	if err != nil {
+		// 已过期或已注销的刷新令牌无需再处理
+		if errors.Is(err, utils.ErrTokenExpired) || errors.Is(err, ErrTokenRevoked) {
+			return nil
+		}
+		return err
+	}
+	if refreshClaims.UserID != claims.UserID {
+		return utils.ErrTokenInvalid
+	}
+	return s.revoke(ctx, refreshClaims)
+}
+
+// Authenticate 校验访问令牌并加载当前用户
+func (s *authService) Authenticate(ctx context.Context, accessToken string) (*models.User, *utils.JWTClaims, error) {
+	claims, err := s.parseToken(ctx, accessToken, utils.TokenTypeAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// issueTokens 签发令牌对
+func (s *authService) issueTokens(user *models.User) (*models.UserLoginResponse, error) {
+	now := time.Now()
+	access := s.newClaims(user, utils.TokenTypeAccess, now, time.Duration(s.cfg.ExpireHours)*time.Hour)
+	refresh := s.newClaims(user, utils.TokenTypeRefresh, now, time.Duration(s.cfg.RefreshExpireHours)*time.Hour)
+
+	accessToken, err := utils.SignJWT(s.cfg.Secret, access)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.SignJWT(s.cfg.Secret, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserLoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		User: models.UserInfo{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Phone:    user.Phone,
+			Status:   user.Status,
+		},
+		ExpiresAt:        access.ExpiresTime(),
+		RefreshExpiresAt: refresh.ExpiresTime(),
+	}, nil
+}
+
+// newClaims 构造令牌载荷
+func (s *authService) newClaims(user *models.User, tokenType string, now time.Time, ttl time.Duration) *utils.JWTClaims {
+	return &utils.JWTClaims{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Username:  user.Username,
+		TokenType: tokenType,
+		Issuer:    s.cfg.Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+// parseToken 解析令牌并校验类型和注销状态
+func (s *authService) parseToken(ctx context.Context, token, tokenType string) (*utils.JWTClaims, error) {
+	claims, err := utils.ParseJWT(s.cfg.Secret, s.cfg.Issuer, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, utils.ErrTokenInvalid
+	}
+
+	revoked, err := s.isRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// activeUser 获取状态正常的用户
+func (s *authService) activeUser(ctx context.Context, userID uint64) (*models.User, error) {
+	user, err := s.userService.GetUser(ctx, uint(userID))
+	if err != nil || user == nil {
+		return nil, utils.ErrTokenInvalid
+	}
+	if user.Status != string(models.UserStatusActive) {
+		return nil, ErrUserDisabled
+	}
+	return user, nil
+}
+
+// revoke 注销令牌直至其过期：数据库持久记录，Redis可用时同时写入黑名单供快速校验
+func (s *authService) revoke(ctx context.Context, claims *utils.JWTClaims) error {
+	expiresAt := claims.ExpiresTime()
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if s.cache != nil {
+		if err := s.cache.Set(ctx, revokedTokenKeyPrefix+claims.ID, claims.UserID, ttl); err != nil {
+			s.logger.Warn("写入Redis令牌黑名单失败，仅记录到数据库", utils.ErrorField(err))
+		}
+	}
+
+	if err := s.revokedRepo.Revoke(ctx, &models.RevokedToken{
+		TokenID:   claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := s.revokedRepo.DeleteExpired(ctx, time.Now()); err != nil {
+		s.logger.Warn("清理过期注销令牌失败", utils.ErrorField(err))
+	}
+	return nil
+}
+
+// isRevoked 判断令牌是否已注销：Redis命中时直接返回，未命中或不可用时以数据库为准
+// （Redis写入失败或重启后黑名单可能缺失）
+func (s *authService) isRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if s.cache != nil {
+		count, err := s.cache.Exists(ctx, revokedTokenKeyPrefix+tokenID)
+		if err == nil && count > 0 {
+			return true, nil
+		}
+		if err != nil {
+			s.logger.Warn("查询Redis令牌黑名单失败，改为查询数据库", utils.ErrorField(err))
+		}
+	}
+	return s.revokedRepo.IsRevoked(ctx, tokenID)
+}
@@ -5,8 +5,15 @@ import (
 	"air-quality-server/internal/repositories"
 	"air-quality-server/internal/utils"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
 )
 
+// ErrDeviceNotFound 设备不存在
+var ErrDeviceNotFound = errors.New("设备不存在")
+
 // DeviceService 设备服务接口
 type DeviceService interface {
 	CreateDevice(ctx context.Context, device *models.Device) error
@@ -15,22 +22,30 @@ type DeviceService interface {
 	UpdateDevice(ctx context.Context, device *models.Device) error
 	DeleteDevice(ctx context.Context, id string) error
 	ListDevices(ctx context.Context, limit, offset int) ([]models.Device, error)
+	// QueryDevices 按条件分页查询设备，deviceIDs不为nil时限定在其中（由分组/位置解析得到）
+	QueryDevices(ctx context.Context, req *models.DeviceQueryRequest, deviceIDs []string) ([]models.Device, int64, error)
 	CountDevices(ctx context.Context) (int64, error)
 	GetDeviceStatus(ctx context.Context, id string) (*models.Device, error)
 	UpdateDeviceStatus(ctx context.Context, id string, status string) error
+	// ImportDevices 解析CSV或JSON并批量创建设备，dryRun时只校验不写入
+	ImportDevices(ctx context.Context, format string, r io.Reader, dryRun bool) (*models.DeviceImportResult, error)
+	// ExportDevices 按导入格式（csv/json）导出全部设备
+	ExportDevices(ctx context.Context, format string, w io.Writer) error
 }
 
 // deviceService 设备服务实现
 type deviceService struct {
-	deviceRepo repositories.DeviceRepository
-	logger     utils.Logger
+	deviceRepo    repositories.DeviceRepository
+	configService DeviceConfigService
+	logger        utils.Logger
 }
 
-// NewDeviceService 创建设备服务
-func NewDeviceService(deviceRepo repositories.DeviceRepository, logger utils.Logger) DeviceService {
+// NewDeviceService 创建设备服务（configService为空时配置变更不下发到设备）
+func NewDeviceService(deviceRepo repositories.DeviceRepository, configService DeviceConfigService, logger utils.Logger) DeviceService {
 	return &deviceService{
-		deviceRepo: deviceRepo,
-		logger:     logger,
+		deviceRepo:    deviceRepo,
+		configService: configService,
+		logger:        logger,
 	}
 }
 
@@ -64,8 +79,13 @@ func (s *deviceService) GetDeviceBySerialNumber(ctx context.Context, serialNumbe
 	return device, nil
 }
 
-// UpdateDevice 更新设备
+// UpdateDevice 更新设备，配置变更时同步下发期望配置
 func (s *deviceService) UpdateDevice(ctx context.Context, device *models.Device) error {
+	existing, err := s.deviceRepo.GetByDeviceID(ctx, device.ID)
+	if err != nil {
+		return ErrDeviceNotFound
+	}
+
 	// 使用结构体更新，只更新非零值字段
 	updateData := &models.Device{
 		Name:              device.Name,
@@ -83,9 +103,28 @@ func (s *deviceService) UpdateDevice(ctx context.Context, device *models.Device)
 		return err
 	}
 	s.logger.Info("设备更新成功", utils.String("device_id", device.ID))
+
+	if s.configService != nil && device.Config != nil && !sameConfig(existing.Config, device.Config) {
+		if err := s.configService.SetDesired(ctx, device.ID, *device.Config); err != nil {
+			s.logger.Error("下发设备期望配置失败", utils.ErrorField(err), utils.String("device_id", device.ID))
+			return err
+		}
+	}
 	return nil
 }
 
+// sameConfig 比较两份JSON配置内容是否一致
+func sameConfig(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	var left, right interface{}
+	if json.Unmarshal([]byte(*a), &left) != nil || json.Unmarshal([]byte(*b), &right) != nil {
+		return *a == *b
+	}
+	return reflect.DeepEqual(left, right)
+}
+
 // DeleteDevice 删除设备
 func (s *deviceService) DeleteDevice(ctx context.Context, id string) error {
 	if err := s.deviceRepo.Delete(ctx, id); err != nil {
@@ -113,6 +152,16 @@ func (s *deviceService) ListDevices(ctx context.Context, limit, offset int) ([]m
 	return response.Data, nil
 }
 
+// QueryDevices 按条件分页查询设备
+func (s *deviceService) QueryDevices(ctx context.Context, req *models.DeviceQueryRequest, deviceIDs []string) ([]models.Device, int64, error) {
+	devices, total, err := s.deviceRepo.Query(ctx, req, deviceIDs)
+	if err != nil {
+		s.logger.Error("查询设备列表失败", utils.ErrorField(err))
+		return nil, 0, err
+	}
+	return devices, total, nil
+}
+
 // GetDeviceStatus 获取设备状态
 func (s *deviceService) GetDeviceStatus(ctx context.Context, id string) (*models.Device, error) {
 	device, err := s.deviceRepo.GetByID(ctx, id)
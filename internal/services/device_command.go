@@ -0,0 +1,369 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 设备指令默认参数
+const (
+	defaultCommandTimeout = 30 * time.Second
+	commandSweepInterval  = 30 * time.Second
+	commandSweepTimeout   = 10 * time.Second
+)
+
+var (
+	// ErrCommandNotFound 设备指令不存在
+	ErrCommandNotFound = errors.New("设备指令不存在")
+	// ErrInvalidCommand 指令类型或参数无效
+	ErrInvalidCommand = errors.New("指令无效")
+	// ErrDeviceOffline 设备不在线
+	ErrDeviceOffline = errors.New("设备不在线")
+	// ErrCommandChannelUnavailable MQTT指令通道不可用
+	ErrCommandChannelUnavailable = errors.New("MQTT指令通道不可用")
+	// ErrCommandPublishFailed 指令下发失败
+	ErrCommandPublishFailed = errors.New("指令下发失败")
+)
+
+// DeviceCommandService 设备指令服务接口
+type DeviceCommandService interface {
+	// SetPublisher 设置MQTT发布器（MQTT服务器启动后注入）
+	SetPublisher(publisher MessagePublisher)
+	// SendCommand 持久化并下发指令，返回等待响应中的指令
+	SendCommand(ctx context.Context, deviceID string, req *models.DeviceCommandRequest, userID *uint64) (*models.DeviceCommand, error)
+	// WaitForCompletion 等待指令收到响应或超时，返回最新的指令记录
+	WaitForCompletion(ctx context.Context, commandID string) (*models.DeviceCommand, error)
+	// HandleResponse 处理设备在响应主题返回的执行结果
+	HandleResponse(ctx context.Context, deviceID string, payload []byte) error
+	GetCommand(ctx context.Context, deviceID, commandID string) (*models.DeviceCommand, error)
+	ListCommands(ctx context.Context, deviceID string, req *models.DeviceCommandListRequest) (*models.DeviceCommandListResponse, error)
+	Start()
+	Stop()
+}
+
+// commandWaiter 等待响应中的指令
+type commandWaiter struct {
+	done  chan struct{}
+	timer *time.Timer
+}
+
+// deviceCommandService 设备指令服务实现
+type deviceCommandService struct {
+	commandRepo repositories.DeviceCommandRepository
+	deviceRepo  repositories.DeviceRepository
+	logger      utils.Logger
+
+	mu        sync.Mutex
+	publisher MessagePublisher
+	waiters   map[string]*commandWaiter
+	started   bool
+	closed    bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewDeviceCommandService 创建设备指令服务
+func NewDeviceCommandService(commandRepo repositories.DeviceCommandRepository, deviceRepo repositories.DeviceRepository, logger utils.Logger) DeviceCommandService {
+	return &deviceCommandService{
+		commandRepo: commandRepo,
+		deviceRepo:  deviceRepo,
+		logger:      logger,
+		waiters:     make(map[string]*commandWaiter),
+		done:        make(chan struct{}),
+	}
+}
+
+// SetPublisher 设置MQTT发布器
+func (s *deviceCommandService) SetPublisher(publisher MessagePublisher) {
+	s.mu.Lock()
+	s.publisher = publisher
+	s.mu.Unlock()
+}
+
+// SendCommand 下发设备指令
+func (s *deviceCommandService) SendCommand(ctx context.Context, deviceID string, req *models.DeviceCommandRequest, userID *uint64) (*models.DeviceCommand, error) {
+	s.mu.Lock()
+	publisher := s.publisher
+	s.mu.Unlock()
+	if publisher == nil {
+		return nil, ErrCommandChannelUnavailable
+	}
+
+	if err := req.Command.ValidateParams(req.Params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if device.Status != models.DeviceStatusOnline {
+		return nil, ErrDeviceOffline
+	}
+
+	timeout := defaultCommandTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	now := time.Now()
+	command := &models.DeviceCommand{
+		CommandID:   uuid.New().String(),
+		DeviceID:    deviceID,
+		Command:     req.Command,
+		Status:      models.DeviceCommandStatusPending,
+		RequestedBy: userID,
+		SentAt:      now,
+		ExpiresAt:   now.Add(timeout),
+	}
+	if len(req.Params) > 0 {
+		data, err := json.Marshal(req.Params)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+		}
+		params := string(data)
+		command.Params = &params
+	}
+	if err := s.commandRepo.Create(ctx, command); err != nil {
+		return nil, err
+	}
+
+	// 先登记等待再发布，避免设备响应早于登记
+	s.register(command.CommandID, timeout)
+
+	topic := fmt.Sprintf("air-quality/%s/%s/cmd", device.Type, device.ID)
+	if err := publisher.Publish(topic, &models.DeviceCommandMessage{
+		CommandID: command.CommandID,
+		Command:   command.Command,
+		Params:    req.Params,
+		Timestamp: now.Unix(),
+		ExpiresAt: command.ExpiresAt.Unix(),
+	}); err != nil {
+		s.logger.Error("下发设备指令失败",
+			utils.String("device_id", deviceID),
+			utils.String("command_id", command.CommandID),
+			utils.ErrorField(err))
+		message := err.Error()
+		s.finish(ctx, command.CommandID, models.DeviceCommandStatusFailed, nil, &message)
+		return nil, fmt.Errorf("%w: %v", ErrCommandPublishFailed, err)
+	}
+
+	s.logger.Info("设备指令已下发",
+		utils.String("device_id", deviceID),
+		utils.String("command_id", command.CommandID),
+		utils.String("command", string(command.Command)),
+		utils.String("topic", topic))
+	return command, nil
+}
+
+// WaitForCompletion 等待指令结束
+func (s *deviceCommandService) WaitForCompletion(ctx context.Context, commandID string) (*models.DeviceCommand, error) {
+	s.mu.Lock()
+	waiter := s.waiters[commandID]
+	s.mu.Unlock()
+
+	if waiter != nil {
+		select {
+		case <-waiter.done:
+		case <-ctx.Done():
+		}
+	}
+
+	command, err := s.commandRepo.GetByCommandID(context.WithoutCancel(ctx), commandID)
+	if err != nil {
+		return nil, err
+	}
+	if command == nil {
+		return nil, ErrCommandNotFound
+	}
+	return command, nil
+}
+
+// HandleResponse 根据关联ID记录设备执行结果
+func (s *deviceCommandService) HandleResponse(ctx context.Context, deviceID string, payload []byte) error {
+	var resp models.DeviceCommandResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return fmt.Errorf("解析指令响应失败: %w", err)
+	}
+	if resp.CommandID == "" {
+		return fmt.Errorf("指令响应缺少command_id")
+	}
+	if resp.Status != models.DeviceCommandStatusAcked && resp.Status != models.DeviceCommandStatusFailed {
+		return fmt.Errorf("不支持的指令响应状态: %s", resp.Status)
+	}
+
+	command, err := s.commandRepo.GetByCommandID(ctx, resp.CommandID)
+	if err != nil {
+		return err
+	}
+	if command == nil || command.DeviceID != deviceID {
+		return ErrCommandNotFound
+	}
+
+	var result *string
+	if len(resp.Result) > 0 {
+		data, err := json.Marshal(resp.Result)
+		if err != nil {
+			return err
+		}
+		encoded := string(data)
+		result = &encoded
+	}
+	var errorMessage *string
+	if resp.Error != "" {
+		errorMessage = &resp.Error
+	}
+
+	if !s.finish(ctx, resp.CommandID, resp.Status, result, errorMessage) {
+		s.logger.Warn("设备指令已结束，忽略迟到的响应",
+			utils.String("device_id", deviceID),
+			utils.String("command_id", resp.CommandID),
+			utils.String("status", string(command.Status)))
+		return nil
+	}
+
+	s.logger.Info("收到设备指令响应",
+		utils.String("device_id", deviceID),
+		utils.String("command_id", resp.CommandID),
+		utils.String("status", string(resp.Status)))
+	return nil
+}
+
+// GetCommand 获取设备的指令
+func (s *deviceCommandService) GetCommand(ctx context.Context, deviceID, commandID string) (*models.DeviceCommand, error) {
+	command, err := s.commandRepo.GetByCommandID(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if command == nil || command.DeviceID != deviceID {
+		return nil, ErrCommandNotFound
+	}
+	return command, nil
+}
+
+// ListCommands 分页查询设备的指令历史
+func (s *deviceCommandService) ListCommands(ctx context.Context, deviceID string, req *models.DeviceCommandListRequest) (*models.DeviceCommandListResponse, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	commands, total, err := s.commandRepo.ListByDevice(ctx, deviceID, req)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DeviceCommandListResponse{
+		Commands: commands,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}, nil
+}
+
+// Start 启动超时巡检（兜底处理服务重启前遗留的等待中指令）
+func (s *deviceCommandService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	s.wg.Add(1)
+	go s.sweepLoop()
+	s.logger.Info("设备指令超时巡检已启动", utils.Duration("sweep_interval", commandSweepInterval))
+}
+
+// Stop 停止超时巡检并释放等待者
+func (s *deviceCommandService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	for commandID, waiter := range s.waiters {
+		waiter.timer.Stop()
+		close(waiter.done)
+		delete(s.waiters, commandID)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("设备指令超时巡检已停止")
+}
+
+// sweepLoop 定期将过期的等待中指令标记为超时
+func (s *deviceCommandService) sweepLoop() {
+	defer s.wg.Done()
+	s.expirePending()
+
+	ticker := time.NewTicker(commandSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.expirePending()
+		}
+	}
+}
+
+// expirePending 标记过期指令
+func (s *deviceCommandService) expirePending() {
+	ctx, cancel := context.WithTimeout(context.Background(), commandSweepTimeout)
+	defer cancel()
+	count, err := s.commandRepo.ExpirePending(ctx, time.Now())
+	if err != nil {
+		s.logger.Error("巡检超时设备指令失败", utils.ErrorField(err))
+		return
+	}
+	if count > 0 {
+		s.logger.Warn("设备指令超时未响应", utils.Int64("count", count))
+	}
+}
+
+// register 登记等待者，超时后自动标记指令超时
+func (s *deviceCommandService) register(commandID string, timeout time.Duration) {
+	waiter := &commandWaiter{done: make(chan struct{})}
+	waiter.timer = time.AfterFunc(timeout, func() {
+		message := "设备响应超时"
+		if s.finish(context.Background(), commandID, models.DeviceCommandStatusTimedOut, nil, &message) {
+			s.logger.Warn("设备指令响应超时", utils.String("command_id", commandID))
+		}
+	})
+
+	s.mu.Lock()
+	s.waiters[commandID] = waiter
+	s.mu.Unlock()
+}
+
+// finish 结束等待中的指令并唤醒等待者，返回是否实际更新
+func (s *deviceCommandService) finish(ctx context.Context, commandID string, status models.DeviceCommandStatus, result, errorMessage *string) bool {
+	changed, err := s.commandRepo.Complete(ctx, commandID, status, result, errorMessage, time.Now())
+	if err != nil {
+		s.logger.Error("更新设备指令状态失败", utils.String("command_id", commandID), utils.ErrorField(err))
+	}
+
+	s.mu.Lock()
+	if waiter, ok := s.waiters[commandID]; ok {
+		waiter.timer.Stop()
+		close(waiter.done)
+		delete(s.waiters, commandID)
+	}
+	s.mu.Unlock()
+	return changed
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetainedPublisher MQTT保留消息发布接口（由MQTT服务器实现）
+type RetainedPublisher interface {
+	PublishRetained(topic string, payload interface{}) error
+}
+
+// DeviceConfigService 设备配置同步服务接口
+// 期望配置以保留消息发布到 air-quality/{type}/{id}/config，设备应用后在 config/reported 上报
+type DeviceConfigService interface {
+	// SetPublisher 设置MQTT发布器，并重新发布尚未同步的期望配置
+	SetPublisher(publisher RetainedPublisher)
+	// SetDesired 记录设备期望配置并下发
+	SetDesired(ctx context.Context, deviceID, config string) error
+	// HandleReported 处理设备上报的已应用配置
+	HandleReported(ctx context.Context, deviceID string, payload []byte) error
+	GetSyncStatus(ctx context.Context, deviceID string) (*models.DeviceConfigSyncStatus, error)
+}
+
+// deviceConfigService 设备配置同步服务实现
+type deviceConfigService struct {
+	stateRepo  repositories.DeviceConfigStateRepository
+	deviceRepo repositories.DeviceRepository
+	logger     utils.Logger
+
+	mu        sync.RWMutex
+	publisher RetainedPublisher
+}
+
+// NewDeviceConfigService 创建设备配置同步服务
+func NewDeviceConfigService(stateRepo repositories.DeviceConfigStateRepository, deviceRepo repositories.DeviceRepository, logger utils.Logger) DeviceConfigService {
+	return &deviceConfigService{
+		stateRepo:  stateRepo,
+		deviceRepo: deviceRepo,
+		logger:     logger,
+	}
+}
+
+// SetPublisher 设置MQTT发布器
+func (s *deviceConfigService) SetPublisher(publisher RetainedPublisher) {
+	s.mu.Lock()
+	s.publisher = publisher
+	s.mu.Unlock()
+
+	// Broker重启后保留消息会丢失，重新发布未同步的期望配置
+	ctx := context.Background()
+	states, err := s.stateRepo.ListWithDesired(ctx)
+	if err != nil {
+		return
+	}
+	for i := range states {
+		state := &states[i]
+		if buildConfigSyncStatus(state.DeviceID, state).InSync {
+			continue
+		}
+		device, err := s.deviceRepo.GetByDeviceID(ctx, state.DeviceID)
+		if err != nil {
+			continue
+		}
+		s.publishDesired(device, state)
+	}
+}
+
+// SetDesired 记录期望配置并以保留消息下发
+func (s *deviceConfigService) SetDesired(ctx context.Context, deviceID, config string) error {
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return ErrDeviceNotFound
+	}
+
+	state, err := s.stateRepo.SaveDesired(ctx, deviceID, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// 下发失败不影响期望配置的保存，MQTT恢复后会重新发布
+	s.publishDesired(device, state)
+	return nil
+}
+
+// HandleReported 记录设备上报的已应用配置
+func (s *deviceConfigService) HandleReported(ctx context.Context, deviceID string, payload []byte) error {
+	var report models.DeviceConfigReport
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return fmt.Errorf("解析设备配置上报失败: %w", err)
+	}
+	if report.Config == nil {
+		return fmt.Errorf("设备配置上报缺少config")
+	}
+
+	data, err := json.Marshal(report.Config)
+	if err != nil {
+		return err
+	}
+	if err := s.stateRepo.SaveReported(ctx, deviceID, string(data), report.Version, time.Now()); err != nil {
+		return err
+	}
+
+	status, err := s.GetSyncStatus(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("设备配置已上报",
+		utils.String("device_id", deviceID),
+		utils.Int64("reported_version", report.Version),
+		utils.Int64("desired_version", status.DesiredVersion),
+		utils.Bool("in_sync", status.InSync))
+	return nil
+}
+
+// GetSyncStatus 获取期望配置、上报配置及差异
+func (s *deviceConfigService) GetSyncStatus(ctx context.Context, deviceID string) (*models.DeviceConfigSyncStatus, error) {
+	if _, err := s.deviceRepo.GetByDeviceID(ctx, deviceID); err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	state, err := s.stateRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &models.DeviceConfigState{DeviceID: deviceID}
+	}
+	return buildConfigSyncStatus(deviceID, state), nil
+}
+
+// publishDesired 以保留消息下发期望配置
+func (s *deviceConfigService) publishDesired(device *models.Device, state *models.DeviceConfigState) {
+	s.mu.RLock()
+	publisher := s.publisher
+	s.mu.RUnlock()
+	if publisher == nil {
+		s.logger.Warn("MQTT发布器未就绪，期望配置暂不下发", utils.String("device_id", device.ID))
+		return
+	}
+
+	topic := fmt.Sprintf("air-quality/%s/%s/config", device.Type, device.ID)
+	if err := publisher.PublishRetained(topic, &models.DeviceConfigMessage{
+		Version:   state.DesiredVersion,
+		Config:    decodeConfig(state.Desired),
+		Timestamp: time.Now().Unix(),
+	}); err != nil {
+		s.logger.Error("下发设备期望配置失败", utils.String("device_id", device.ID), utils.ErrorField(err))
+		return
+	}
+	s.logger.Info("设备期望配置已下发",
+		utils.String("device_id", device.ID),
+		utils.Int64("version", state.DesiredVersion),
+		utils.String("topic", topic))
+}
+
+// buildConfigSyncStatus 根据存储状态计算差异和同步标记
+func buildConfigSyncStatus(deviceID string, state *models.DeviceConfigState) *models.DeviceConfigSyncStatus {
+	desired := decodeConfig(state.Desired)
+	reported := decodeConfig(state.Reported)
+	delta := models.ConfigDelta(desired, reported)
+	return &models.DeviceConfigSyncStatus{
+		DeviceID:        deviceID,
+		Desired:         desired,
+		DesiredVersion:  state.DesiredVersion,
+		DesiredAt:       state.DesiredAt,
+		Reported:        reported,
+		ReportedVersion: state.ReportedVersion,
+		ReportedAt:      state.ReportedAt,
+		Delta:           delta,
+		InSync:          len(delta) == 0,
+	}
+}
+
+// decodeConfig 解析JSON配置，为空或无效时返回nil
+func decodeConfig(data *string) map[string]interface{} {
+	if data == nil || *data == "" {
+		return nil
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(*data), &config); err != nil {
+		return nil
+	}
+	return config
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retainedRecorder 记录发布的保留消息
+type retainedRecorder struct {
+	mu       sync.Mutex
+	topics   []string
+	payloads []interface{}
+}
+
+// PublishRetained 记录保留消息
+func (r *retainedRecorder) PublishRetained(topic string, payload interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topics = append(r.topics, topic)
+	r.payloads = append(r.payloads, payload)
+	return nil
+}
+
+// newDeviceConfigService 创建设备配置同步服务，数据库中已有设备1和设备2
+func newDeviceConfigService(t *testing.T) DeviceConfigService {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+	return NewDeviceConfigService(repositories.NewDeviceConfigStateRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger), logger)
+}
+
+// TestDeviceConfigService_DesiredReportedSync 测试期望配置下发、设备上报及差异计算
+func TestDeviceConfigService_DesiredReportedSync(t *testing.T) {
+	service := newDeviceConfigService(t)
+	publisher := &retainedRecorder{}
+	service.SetPublisher(publisher)
+	ctx := context.Background()
+	desired := `{"report_interval":60,"sensors":{"formaldehyde":true},"thresholds":{"formaldehyde_warning":0.08}}`
+
+	// 各步骤依次作用于设备1
+	steps := []struct {
+		name     string
+		apply    func() error
+		version  int64
+		reported int64
+		delta    map[string]interface{}
+	}{
+		{"no desired config", func() error { return nil }, 0, 0, nil},
+		{"set desired", func() error { return service.SetDesired(ctx, testutil.DeviceID1, desired) }, 1, 0,
+			map[string]interface{}{"report_interval": float64(60), "sensors": map[string]interface{}{"formaldehyde": true},
+				"thresholds": map[string]interface{}{"formaldehyde_warning": 0.08}}},
+		{"partially applied", func() error {
+			return service.HandleReported(ctx, testutil.DeviceID1,
+				[]byte(`{"version":1,"config":{"report_interval":60,"sensors":{"formaldehyde":true},"thresholds":{"formaldehyde_warning":0.1}}}`))
+		}, 1, 1, map[string]interface{}{"thresholds": map[string]interface{}{"formaldehyde_warning": 0.08}}},
+		{"fully applied", func() error {
+			return service.HandleReported(ctx, testutil.DeviceID1, []byte(`{"version":1,"config":`+desired+`}`))
+		}, 1, 1, nil},
+		{"desired changed", func() error { return service.SetDesired(ctx, testutil.DeviceID1, `{"report_interval":30}`) }, 2, 1,
+			map[string]interface{}{"report_interval": float64(30)}},
+	}
+	for _, step := range steps {
+		require.NoError(t, step.apply(), step.name)
+		status, err := service.GetSyncStatus(ctx, testutil.DeviceID1)
+		require.NoError(t, err, step.name)
+		assert.Equal(t, step.version, status.DesiredVersion, step.name)
+		assert.Equal(t, step.reported, status.ReportedVersion, step.name)
+		assert.Equal(t, len(step.delta) == 0, status.InSync, step.name)
+		if len(step.delta) == 0 {
+			assert.Empty(t, status.Delta, step.name)
+		} else {
+			assert.Equal(t, step.delta, status.Delta, step.name)
+		}
+	}
+
+	// 每次设置期望配置都以保留消息下发最新版本
+	require.NotEmpty(t, publisher.payloads)
+	last := len(publisher.payloads) - 1
+	assert.Equal(t, "air-quality/hcho/"+testutil.DeviceID1+"/config", publisher.topics[last])
+	msg, ok := publisher.payloads[last].(*models.DeviceConfigMessage)
+	require.True(t, ok)
+	assert.Equal(t, int64(2), msg.Version)
+	assert.Equal(t, float64(30), msg.Config["report_interval"])
+}
+
+// TestDeviceConfigService_SetPublisher 测试MQTT就绪后只重新发布尚未同步的期望配置
+func TestDeviceConfigService_SetPublisher(t *testing.T) {
+	service := newDeviceConfigService(t)
+	ctx := context.Background()
+	require.NoError(t, service.SetDesired(ctx, testutil.DeviceID1, `{"report_interval":60}`))
+	require.NoError(t, service.SetDesired(ctx, testutil.DeviceID2, `{"report_interval":60}`))
+	require.NoError(t, service.HandleReported(ctx, testutil.DeviceID2, []byte(`{"version":1,"config":{"report_interval":60}}`)))
+
+	publisher := &retainedRecorder{}
+	service.SetPublisher(publisher)
+	assert.Equal(t, []string{"air-quality/hcho/" + testutil.DeviceID1 + "/config"}, publisher.topics)
+}
+
+// TestDeviceConfigService_Errors 测试未知设备和无效上报
+func TestDeviceConfigService_Errors(t *testing.T) {
+	service := newDeviceConfigService(t)
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		apply func() error
+		err   error
+	}{
+		{"desired for unknown device", func() error { return service.SetDesired(ctx, "ghost", `{}`) }, ErrDeviceNotFound},
+		{"status of unknown device", func() error { _, err := service.GetSyncStatus(ctx, "ghost"); return err }, ErrDeviceNotFound},
+		{"malformed report", func() error { return service.HandleReported(ctx, testutil.DeviceID1, []byte(`{bad`)) }, nil},
+		{"report without config", func() error { return service.HandleReported(ctx, testutil.DeviceID1, []byte(`{"version":1}`)) }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.apply()
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 设备凭证认证错误
+var (
+	ErrCredentialNotFound = errors.New("设备凭证不存在")
+	ErrCredentialRevoked  = errors.New("设备凭证已吊销")
+	ErrCredentialMismatch = errors.New("设备密钥不匹配")
+)
+
+// credentialSecretBytes 随机密钥字节数（十六进制编码后为48个字符）
+const credentialSecretBytes = 24
+
+// DeviceCredentialService 设备凭证服务接口
+type DeviceCredentialService interface {
+	IssueCredential(ctx context.Context, deviceID string) (*models.DeviceCredentialIssueResponse, error)
+	RotateCredential(ctx context.Context, deviceID string) (*models.DeviceCredentialIssueResponse, error)
+	RevokeCredential(ctx context.Context, deviceID string) error
+	GetCredential(ctx context.Context, deviceID string) (*models.DeviceCredential, error)
+	Authenticate(ctx context.Context, deviceID, secret string) error
+}
+
+// deviceCredentialService 设备凭证服务实现
+type deviceCredentialService struct {
+	credentialRepo repositories.DeviceCredentialRepository
+	deviceRepo     repositories.DeviceRepository
+	logger         utils.Logger
+}
+
+// NewDeviceCredentialService 创建设备凭证服务
+func NewDeviceCredentialService(credentialRepo repositories.DeviceCredentialRepository, deviceRepo repositories.DeviceRepository, logger utils.Logger) DeviceCredentialService {
+	return &deviceCredentialService{
+		credentialRepo: credentialRepo,
+		deviceRepo:     deviceRepo,
+		logger:         logger,
+	}
+}
+
+// IssueCredential 为设备签发凭证（设备已有有效凭证时需使用轮换）
+func (s *deviceCredentialService) IssueCredential(ctx context.Context, deviceID string) (*models.DeviceCredentialIssueResponse, error) {
+	existing, err := s.credentialRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status == models.DeviceCredentialStatusActive {
+		return nil, errors.New("设备凭证已存在，请使用轮换接口")
+	}
+	return s.issue(ctx, deviceID)
+}
+
+// RotateCredential 轮换设备凭证，旧密钥立即失效
+func (s *deviceCredentialService) RotateCredential(ctx context.Context, deviceID string) (*models.DeviceCredentialIssueResponse, error) {
+	existing, err := s.credentialRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrCredentialNotFound
+	}
+	return s.issue(ctx, deviceID)
+}
+
+// RevokeCredential 吊销设备凭证
+func (s *deviceCredentialService) RevokeCredential(ctx context.Context, deviceID string) error {
+	if err := s.credentialRepo.Revoke(ctx, deviceID); err != nil {
+		s.logger.Error("吊销设备凭证失败", utils.ErrorField(err), utils.String("device_id", deviceID))
+		return err
+	}
+	s.logger.Info("设备凭证已吊销", utils.String("device_id", deviceID))
+	return nil
+}
+
+// GetCredential 获取设备凭证元信息（不含密钥）
+func (s *deviceCredentialService) GetCredential(ctx context.Context, deviceID string) (*models.DeviceCredential, error) {
+	credential, err := s.credentialRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrCredentialNotFound
+	}
+	return credential, nil
+}
+
+// Authenticate 校验设备ID与密钥
+func (s *deviceCredentialService) Authenticate(ctx context.Context, deviceID, secret string) error {
+	credential, err := s.credentialRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if credential == nil {
+		return ErrCredentialNotFound
+	}
+	if credential.Status != models.DeviceCredentialStatusActive {
+		return ErrCredentialRevoked
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(credential.SecretHash), []byte(secret)); err != nil {
+		return ErrCredentialMismatch
+	}
+
+	if err := s.credentialRepo.TouchLastUsed(ctx, deviceID); err != nil {
+		s.logger.Warn("更新凭证使用时间失败", utils.ErrorField(err), utils.String("device_id", deviceID))
+	}
+	return nil
+}
+
+// issue 生成新密钥并保存其哈希
+func (s *deviceCredentialService) issue(ctx context.Context, deviceID string) (*models.DeviceCredentialIssueResponse, error) {
+	if _, err := s.deviceRepo.GetByDeviceID(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateCredentialSecret()
+	if err != nil {
+		s.logger.Error("生成设备密钥失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("设备密钥加密失败", utils.ErrorField(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	credential := &models.DeviceCredential{
+		DeviceID:   deviceID,
+		SecretHash: string(hash),
+		Status:     models.DeviceCredentialStatusActive,
+		IssuedAt:   now,
+	}
+	if err := s.credentialRepo.Upsert(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("设备凭证已签发", utils.String("device_id", deviceID))
+	return &models.DeviceCredentialIssueResponse{
+		DeviceID: deviceID,
+		Username: deviceID,
+		Secret:   secret,
+		IssuedAt: now,
+	}, nil
+}
+
+// generateCredentialSecret 生成随机设备密钥
+func generateCredentialSecret() (string, error) {
+	buf := make([]byte, credentialSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("读取随机数失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrDeviceGroupNotFound 设备分组不存在
+	ErrDeviceGroupNotFound = errors.New("设备分组不存在")
+	// ErrDeviceGroupExists 分组名称已存在
+	ErrDeviceGroupExists = errors.New("分组名称已存在")
+	// ErrInvalidDeviceGroup 分组信息无效
+	ErrInvalidDeviceGroup = errors.New("分组无效")
+)
+
+// DeviceGroupService 设备分组服务接口
+type DeviceGroupService interface {
+	CreateGroup(ctx context.Context, req *models.DeviceGroupRequest) (*models.DeviceGroup, error)
+	GetGroup(ctx context.Context, id uint64) (*models.DeviceGroup, error)
+	ListGroups(ctx context.Context) ([]models.DeviceGroup, error)
+	UpdateGroup(ctx context.Context, id uint64, req *models.DeviceGroupRequest) (*models.DeviceGroup, error)
+	DeleteGroup(ctx context.Context, id uint64) error
+	ListMembers(ctx context.Context, id uint64) ([]string, error)
+	AddDevices(ctx context.Context, id uint64, deviceIDs []string) error
+	RemoveDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error)
+}
+
+// deviceGroupService 设备分组服务实现
+type deviceGroupService struct {
+	groupRepo  repositories.DeviceGroupRepository
+	deviceRepo repositories.DeviceRepository
+	scope      DeviceScopeService
+	logger     utils.Logger
+}
+
+// NewDeviceGroupService 创建设备分组服务（scope为空时不刷新告警规则的设备归属缓存）
+func NewDeviceGroupService(groupRepo repositories.DeviceGroupRepository, deviceRepo repositories.DeviceRepository, scope DeviceScopeService, logger utils.Logger) DeviceGroupService {
+	return &deviceGroupService{
+		groupRepo:  groupRepo,
+		deviceRepo: deviceRepo,
+		scope:      scope,
+		logger:     logger,
+	}
+}
+
+// CreateGroup 创建分组，名称唯一
+func (s *deviceGroupService) CreateGroup(ctx context.Context, req *models.DeviceGroupRequest) (*models.DeviceGroup, error) {
+	name, err := s.checkName(ctx, req.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+	group := &models.DeviceGroup{Name: name, Description: req.Description}
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, err
+	}
+	s.logger.Info("设备分组创建成功", utils.Int64("group_id", int64(group.ID)), utils.String("name", group.Name))
+	return group, nil
+}
+
+// GetGroup 获取分组
+func (s *deviceGroupService) GetGroup(ctx context.Context, id uint64) (*models.DeviceGroup, error) {
+	group, err := s.groupRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrDeviceGroupNotFound
+	}
+	return group, nil
+}
+
+// ListGroups 获取全部分组及成员数量
+func (s *deviceGroupService) ListGroups(ctx context.Context) ([]models.DeviceGroup, error) {
+	return s.groupRepo.List(ctx)
+}
+
+// UpdateGroup 更新分组名称和描述
+func (s *deviceGroupService) UpdateGroup(ctx context.Context, id uint64, req *models.DeviceGroupRequest) (*models.DeviceGroup, error) {
+	group, err := s.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	name, err := s.checkName(ctx, req.Name, id)
+	if err != nil {
+		return nil, err
+	}
+	group.Name = name
+	group.Description = req.Description
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// DeleteGroup 删除分组及成员关系（设备本身不受影响）
+func (s *deviceGroupService) DeleteGroup(ctx context.Context, id uint64) error {
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return err
+	}
+	if err := s.groupRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("设备分组已删除", utils.Int64("group_id", int64(id)))
+	return nil
+}
+
+// ListMembers 获取分组成员设备ID
+func (s *deviceGroupService) ListMembers(ctx context.Context, id uint64) ([]string, error) {
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.groupRepo.ListMemberIDs(ctx, id)
+}
+
+// AddDevices 校验设备存在后加入分组
+func (s *deviceGroupService) AddDevices(ctx context.Context, id uint64, deviceIDs []string) error {
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return err
+	}
+	if err := requireDevices(ctx, s.deviceRepo, deviceIDs); err != nil {
+		return err
+	}
+	if err := s.groupRepo.AddMembers(ctx, id, deviceIDs); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("设备已加入分组", utils.Int64("group_id", int64(id)), utils.Int("count", len(deviceIDs)))
+	return nil
+}
+
+// RemoveDevices 从分组移除设备
+func (s *deviceGroupService) RemoveDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error) {
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return 0, err
+	}
+	removed, err := s.groupRepo.RemoveMembers(ctx, id, deviceIDs)
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate()
+	return removed, nil
+}
+
+// checkName 校验名称非空且未被其他分组占用
+func (s *deviceGroupService) checkName(ctx context.Context, name string, id uint64) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: 名称不能为空", ErrInvalidDeviceGroup)
+	}
+	existing, err := s.groupRepo.GetByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.ID != id {
+		return "", ErrDeviceGroupExists
+	}
+	return name, nil
+}
+
+// invalidate 成员关系变化后刷新设备归属缓存
+func (s *deviceGroupService) invalidate() {
+	if s.scope != nil {
+		s.scope.Invalidate()
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxImportDevices 单次导入的设备数量上限
+const maxImportDevices = 5000
+
+// ErrInvalidImport 导入文件格式错误
+var ErrInvalidImport = errors.New("导入文件无效")
+
+// deviceIDPattern 设备ID格式（会出现在MQTT主题中，不允许/、+、#等字符）
+var deviceIDPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z_.:-]*$`)
+
+// ImportDevices 校验全部记录，没有错误且非试运行时在单个事务中创建设备
+func (s *deviceService) ImportDevices(ctx context.Context, format string, r io.Reader, dryRun bool) (*models.DeviceImportResult, error) {
+	records, rowErrors, err := decodeDeviceImport(format, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: 没有设备记录", ErrInvalidImport)
+	}
+	if len(records) > maxImportDevices {
+		return nil, fmt.Errorf("%w: 单次最多导入%d台设备", ErrInvalidImport, maxImportDevices)
+	}
+
+	result := &models.DeviceImportResult{
+		DryRun: dryRun,
+		Total:  len(records),
+		Errors: []models.DeviceImportError{},
+	}
+	fail := func(record *models.DeviceImportRecord, message string, duplicate bool) {
+		result.Errors = append(result.Errors, models.DeviceImportError{
+			Row:       record.Row,
+			DeviceID:  record.ID,
+			Error:     message,
+			Duplicate: duplicate,
+		})
+		if duplicate {
+			result.Duplicates++
+		}
+	}
+
+	devices := make([]models.Device, 0, len(records))
+	rows := make(map[string]*models.DeviceImportRecord, len(records))
+	for i := range records {
+		record := &records[i]
+		record.ID = strings.TrimSpace(record.ID)
+		if message, ok := rowErrors[record.Row]; ok {
+			fail(record, message, false)
+			continue
+		}
+		device, message := importRecordToDevice(record)
+		if message != "" {
+			fail(record, message, false)
+			continue
+		}
+		if first, ok := rows[device.ID]; ok {
+			fail(record, fmt.Sprintf("设备ID与第%d行重复", first.Row), true)
+			continue
+		}
+		rows[device.ID] = record
+		devices = append(devices, *device)
+	}
+
+	ids := make([]string, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	existing, err := s.deviceRepo.FindExistingIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		taken := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			taken[id] = true
+			fail(rows[id], "设备ID已存在", true)
+		}
+		remaining := devices[:0]
+		for _, device := range devices {
+			if !taken[device.ID] {
+				remaining = append(remaining, device)
+			}
+		}
+		devices = remaining
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	result.Valid = len(devices)
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if err := s.deviceRepo.CreateBatch(ctx, devices); err != nil {
+		return nil, err
+	}
+	result.Imported = len(devices)
+	s.logger.Info("批量导入设备成功", utils.Int("count", result.Imported))
+
+	if s.configService != nil {
+		for _, device := range devices {
+			if device.Config == nil {
+				continue
+			}
+			if err := s.configService.SetDesired(ctx, device.ID, *device.Config); err != nil {
+				s.logger.Error("下发设备期望配置失败", utils.ErrorField(err), utils.String("device_id", device.ID))
+			}
+		}
+	}
+	return result, nil
+}
+
+// ExportDevices 以导入格式导出全部设备
+func (s *deviceService) ExportDevices(ctx context.Context, format string, w io.Writer) error {
+	devices, err := s.deviceRepo.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	records := make([]models.DeviceImportRecord, 0, len(devices))
+	for _, device := range devices {
+		record := models.DeviceImportRecord{
+			ID:        device.ID,
+			Name:      device.Name,
+			Type:      device.Type,
+			Latitude:  device.LocationLatitude,
+			Longitude: device.LocationLongitude,
+		}
+		if device.LocationAddress != nil {
+			record.Address = *device.LocationAddress
+		}
+		if device.Config != nil && json.Valid([]byte(*device.Config)) {
+			record.Config = json.RawMessage(*device.Config)
+		}
+		records = append(records, record)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(models.DeviceImportColumns); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writer.Write([]string{
+				record.ID,
+				record.Name,
+				string(record.Type),
+				formatOptionalFloat(record.Latitude),
+				formatOptionalFloat(record.Longitude),
+				record.Address,
+				string(record.Config),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("%w: 不支持的格式 %s", ErrInvalidImport, format)
+	}
+}
+
+// decodeDeviceImport 解析CSV或JSON导入文件，返回记录及按行号记录的解析错误
+func decodeDeviceImport(format string, r io.Reader) ([]models.DeviceImportRecord, map[int]string, error) {
+	rowErrors := make(map[int]string)
+	switch format {
+	case "json":
+		var records []models.DeviceImportRecord
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&records); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		for i := range records {
+			records[i].Row = i + 1
+		}
+		return records, rowErrors, nil
+	case "csv":
+		return decodeDeviceCSV(r)
+	default:
+		return nil, nil, fmt.Errorf("%w: 不支持的格式 %s", ErrInvalidImport, format)
+	}
+}
+
+// decodeDeviceCSV 按表头列名解析CSV，列顺序不限
+func decodeDeviceCSV(r io.Reader) ([]models.DeviceImportRecord, map[int]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 读取表头失败: %v", ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	known := make(map[string]bool, len(models.DeviceImportColumns))
+	for _, name := range models.DeviceImportColumns {
+		known[name] = true
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, nil, fmt.Errorf("%w: 未知的列 %s", ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"id", "name", "type"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("%w: 缺少列 %s", ErrInvalidImport, name)
+		}
+	}
+
+	var records []models.DeviceImportRecord
+	rowErrors := make(map[int]string)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		record := models.DeviceImportRecord{
+			Row:     line,
+			ID:      value("id"),
+			Name:    value("name"),
+			Type:    models.DeviceType(value("type")),
+			Address: value("address"),
+		}
+		if config := value("config"); config != "" {
+			record.Config = json.RawMessage(config)
+		}
+		for _, coord := range []struct {
+			column string
+			label  string
+			target **float64
+		}{
+			{"latitude", "纬度", &record.Latitude},
+			{"longitude", "经度", &record.Longitude},
+		} {
+			raw := value(coord.column)
+			if raw == "" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				rowErrors[line] = coord.label + "格式错误"
+				continue
+			}
+			*coord.target = &parsed
+		}
+		records = append(records, record)
+	}
+	return records, rowErrors, nil
+}
+
+// importRecordToDevice 校验导入记录并转换为设备，校验失败时返回错误描述
+func importRecordToDevice(record *models.DeviceImportRecord) (*models.Device, string) {
+	name := strings.TrimSpace(record.Name)
+	switch {
+	case record.ID == "":
+		return nil, "设备ID不能为空"
+	case len(record.ID) > 64 || !deviceIDPattern.MatchString(record.ID):
+		return nil, "设备ID只能包含字母、数字、下划线、点、冒号和短横线，且不超过64个字符"
+	case name == "":
+		return nil, "设备名称不能为空"
+	case utf8.RuneCountInString(name) > 100:
+		return nil, "设备名称不能超过100个字符"
+	case !record.Type.IsValid():
+		return nil, fmt.Sprintf("不支持的设备类型 %s", record.Type)
+	case (record.Latitude == nil) != (record.Longitude == nil):
+		return nil, "经纬度必须同时提供"
+	case record.Latitude != nil && (*record.Latitude < -90 || *record.Latitude > 90):
+		return nil, "纬度必须在-90到90之间"
+	case record.Longitude != nil && (*record.Longitude < -180 || *record.Longitude > 180):
+		return nil, "经度必须在-180到180之间"
+	case utf8.RuneCountInString(record.Address) > 200:
+		return nil, "地址不能超过200个字符"
+	}
+
+	device := &models.Device{
+		ID:                record.ID,
+		Name:              name,
+		Type:              record.Type,
+		LocationLatitude:  record.Latitude,
+		LocationLongitude: record.Longitude,
+		Status:            models.DeviceStatusOffline,
+	}
+	if address := strings.TrimSpace(record.Address); address != "" {
+		device.LocationAddress = &address
+	}
+	if len(record.Config) > 0 && string(record.Config) != "null" {
+		var config map[string]interface{}
+		if err := json.Unmarshal(record.Config, &config); err != nil {
+			return nil, "配置必须是JSON对象"
+		}
+		compact, _ := json.Marshal(config)
+		configStr := string(compact)
+		device.Config = &configStr
+	}
+	return device, ""
+}
+
+// formatOptionalFloat 格式化可选数值，为空时输出空字符串
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
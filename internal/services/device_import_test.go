@@ -0,0 +1,115 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newDeviceImportService 创建已有一台设备的设备服务
+func newDeviceImportService(t *testing.T) (DeviceService, *gorm.DB) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "已有设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	return NewDeviceService(repositories.NewDeviceRepository(db, logger), nil, logger), db
+}
+
+// TestDeviceService_ImportDevices 测试逐行校验、重复检测、试运行和存在错误时整体不导入
+func TestDeviceService_ImportDevices(t *testing.T) {
+	invalidCSV := "id,name,type,latitude,longitude,address,config\n" +
+		"hcho_010,客厅,hcho,31.2,121.5,上海,\"{\"\"report_interval\"\":60}\"\n" +
+		"hcho_011,卧室,unknown,,,,\n" +
+		"hcho_010,重复,hcho,,,,\n" +
+		testutil.DeviceID1 + ",已存在,hcho,,,,\n" +
+		"hcho_012,书房,hcho,abc,121.5,,\n"
+	validJSON := `[{"id":"hcho_010","name":"客厅","type":"hcho","latitude":31.2,"longitude":121.5,"address":"上海","config":{"report_interval":60}},
+		{"id":"pm25_001","name":"阳台","type":"pm25"}]`
+
+	tests := []struct {
+		name       string
+		format     string
+		body       string
+		dryRun     bool
+		wantErr    error
+		valid      int
+		imported   int
+		duplicates int
+		errorRows  []int
+		devices    int64
+	}{
+		{"dry run reports row errors", "csv", invalidCSV, true, nil, 1, 0, 2, []int{3, 4, 5, 6}, 1},
+		{"errors block the whole import", "csv", invalidCSV, false, nil, 1, 0, 2, []int{3, 4, 5, 6}, 1},
+		{"valid records imported in one batch", "json", validJSON, false, nil, 2, 2, 0, nil, 3},
+		{"dry run does not write", "json", validJSON, true, nil, 2, 0, 0, nil, 1},
+		{"unknown csv columns", "csv", "id,serial\nx,y\n", false, ErrInvalidImport, 0, 0, 0, nil, 1},
+		{"json object instead of array", "json", `{"id":"x"}`, false, ErrInvalidImport, 0, 0, 0, nil, 1},
+		{"empty file", "json", `[]`, false, ErrInvalidImport, 0, 0, 0, nil, 1},
+		{"unsupported format", "xml", `<devices/>`, false, ErrInvalidImport, 0, 0, 0, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, db := newDeviceImportService(t)
+			result, err := service.ImportDevices(context.Background(), tt.format, strings.NewReader(tt.body), tt.dryRun)
+			var count int64
+			require.NoError(t, db.Model(&models.Device{}).Count(&count).Error)
+			assert.Equal(t, tt.devices, count)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.dryRun, result.DryRun)
+			assert.Equal(t, tt.valid, result.Valid)
+			assert.Equal(t, tt.imported, result.Imported)
+			assert.Equal(t, tt.duplicates, result.Duplicates)
+			rows := []int{}
+			for _, e := range result.Errors {
+				rows = append(rows, e.Row)
+			}
+			if tt.errorRows == nil {
+				tt.errorRows = []int{}
+			}
+			assert.Equal(t, tt.errorRows, rows)
+		})
+	}
+}
+
+// TestDeviceService_ExportDevices 测试导出的CSV列与导入格式一致，导出文件可再次导入
+func TestDeviceService_ExportDevices(t *testing.T) {
+	service, _ := newDeviceImportService(t)
+	ctx := context.Background()
+	_, err := service.ImportDevices(ctx, "json", strings.NewReader(
+		`[{"id":"hcho_010","name":"客厅","type":"hcho","latitude":31.2,"longitude":121.5,"address":"上海","config":{"report_interval":60}}]`), false)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, service.ExportDevices(ctx, "csv", &buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, models.DeviceImportColumns, records[0])
+	assert.Equal(t, []string{"hcho_010", "客厅", "hcho", "31.2", "121.5", "上海", `{"report_interval":60}`}, records[2])
+
+	for _, format := range []string{"csv", "json"} {
+		t.Run("reimport "+format, func(t *testing.T) {
+			var exported bytes.Buffer
+			require.NoError(t, service.ExportDevices(ctx, format, &exported))
+			result, err := service.ImportDevices(ctx, format, &exported, true)
+			require.NoError(t, err)
+			assert.Equal(t, 2, result.Total)
+			assert.Equal(t, 2, result.Duplicates)
+			assert.Equal(t, 0, result.Valid)
+		})
+	}
+
+	assert.ErrorIs(t, service.ExportDevices(ctx, "xml", &buf), ErrInvalidImport)
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 在线状态巡检默认参数（配置缺省时使用）
+const (
+	defaultOfflineTimeout = 300 * time.Second
+	defaultSweepInterval  = 60 * time.Second
+	presenceSweepTimeout  = 30 * time.Second
+)
+
+// DevicePresenceService 设备在线状态服务接口
+type DevicePresenceService interface {
+	// MarkConnected 设备建立MQTT会话
+	MarkConnected(ctx context.Context, deviceID string) error
+	// MarkDisconnected 设备断开MQTT连接（设备状态在超时后由巡检判定离线）
+	MarkDisconnected(ctx context.Context, deviceID string) error
+	// Touch 设备在MQTT连接上发送任意报文（含PINGREQ）时刷新心跳，避免空闲但仍连接的设备被巡检判定离线
+	Touch(ctx context.Context, deviceID string) error
+	// ApplyStatusReport 处理设备在状态主题上报的在线状态（含遗嘱消息）
+	ApplyStatusReport(ctx context.Context, deviceID string, report *models.DeviceStatusReport) error
+	// RecordReadings 根据上报数据刷新设备心跳、最后数据时间及电量信号
+	RecordReadings(ctx context.Context, readings []models.UnifiedSensorData) error
+	// GetRuntimeStatus 获取设备运行状态，设备从未上线时返回离线的空记录
+	GetRuntimeStatus(ctx context.Context, deviceID string) (*models.DeviceRuntimeStatus, error)
+	// SweepOffline 将超时未上报的在线设备标记为离线并产生离线告警，返回离线设备数
+	SweepOffline(ctx context.Context) (int, error)
+	Start()
+	Stop()
+}
+
+// devicePresenceService 设备在线状态服务实现
+type devicePresenceService struct {
+	deviceRepo     repositories.DeviceRepository
+	runtimeRepo    repositories.DeviceRuntimeStatusRepository
+	alertService   AlertService
+	logger         utils.Logger
+	offlineTimeout time.Duration
+	sweepInterval  time.Duration
+	touchInterval  time.Duration
+
+	touchMu sync.Mutex
+	touched map[string]time.Time // 设备最近一次写入心跳的时间，用于限制Touch的写入频率
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewDevicePresenceService 创建设备在线状态服务（alertService为nil时不产生离线告警）
+func NewDevicePresenceService(
+	cfg config.DeviceConfig,
+	deviceRepo repositories.DeviceRepository,
+	runtimeRepo repositories.DeviceRuntimeStatusRepository,
+	alertService AlertService,
+	logger utils.Logger,
+) DevicePresenceService {
+	offlineTimeout := time.Duration(cfg.OfflineTimeout) * time.Second
+	if offlineTimeout <= 0 {
+		offlineTimeout = defaultOfflineTimeout
+	}
+	sweepInterval := time.Duration(cfg.HeartbeatInterval) * time.Second
+	if sweepInterval <= 0 {
+		sweepInterval = defaultSweepInterval
+	}
+
+	return &devicePresenceService{
+		deviceRepo:     deviceRepo,
+		runtimeRepo:    runtimeRepo,
+		alertService:   alertService,
+		logger:         logger,
+		offlineTimeout: offlineTimeout,
+		sweepInterval:  sweepInterval,
+		touchInterval:  offlineTimeout / 4,
+		touched:        make(map[string]time.Time),
+		done:           make(chan struct{}),
+	}
+}
+
+// MarkConnected 记录设备上线
+func (s *devicePresenceService) MarkConnected(ctx context.Context, deviceID string) error {
+	now := time.Now()
+	if err := s.runtimeRepo.Upsert(ctx, &models.DeviceRuntimeStatus{
+		DeviceID:      deviceID,
+		Online:        true,
+		LastHeartbeat: now,
+	}, "online", "last_heartbeat"); err != nil {
+		return err
+	}
+	s.setTouched(deviceID, now)
+	return s.markOnline(ctx, deviceID)
+}
+
+// MarkDisconnected 记录设备断开连接
+func (s *devicePresenceService) MarkDisconnected(ctx context.Context, deviceID string) error {
+	s.touchMu.Lock()
+	delete(s.touched, deviceID)
+	s.touchMu.Unlock()
+	return s.runtimeRepo.SetOffline(ctx, deviceID)
+}
+
+// Touch 刷新设备心跳时间，同一设备在离线超时的1/4内只写入一次；只刷新心跳，不改变在线状态
+func (s *devicePresenceService) Touch(ctx context.Context, deviceID string) error {
+	now := time.Now()
+	s.touchMu.Lock()
+	last, ok := s.touched[deviceID]
+	s.touchMu.Unlock()
+	if ok && now.Sub(last) < s.touchInterval {
+		return nil
+	}
+
+	if err := s.runtimeRepo.Upsert(ctx, &models.DeviceRuntimeStatus{
+		DeviceID:      deviceID,
+		LastHeartbeat: now,
+	}, "last_heartbeat"); err != nil {
+		return err
+	}
+	s.setTouched(deviceID, now)
+	return nil
+}
+
+// setTouched 记录设备心跳的写入时间
+func (s *devicePresenceService) setTouched(deviceID string, at time.Time) {
+	s.touchMu.Lock()
+	s.touched[deviceID] = at
+	s.touchMu.Unlock()
+}
+
+// ApplyStatusReport 设备上报online时刷新固件版本和运行时长，上报offline时立即标记离线
+func (s *devicePresenceService) ApplyStatusReport(ctx context.Context, deviceID string, report *models.DeviceStatusReport) error {
+	switch report.Status {
+	case models.DeviceStatusOnline:
+		status := &models.DeviceRuntimeStatus{
+			DeviceID:        deviceID,
+			Online:          true,
+			LastHeartbeat:   time.Now(),
+			FirmwareVersion: report.FirmwareVersion,
+			UptimeSeconds:   report.Uptime,
+		}
+		columns := []string{"online", "last_heartbeat"}
+		if report.FirmwareVersion != "" {
+			columns = append(columns, "firmware_version")
+		}
+		if report.Uptime != nil {
+			columns = append(columns, "uptime_seconds")
+		}
+		if err := s.runtimeRepo.Upsert(ctx, status, columns...); err != nil {
+			return err
+		}
+		return s.markOnline(ctx, deviceID)
+	case models.DeviceStatusOffline:
+		if err := s.runtimeRepo.SetOffline(ctx, deviceID); err != nil {
+			return err
+		}
+		_, err := s.markOffline(ctx, deviceID, "设备上报离线")
+		return err
+	default:
+		return fmt.Errorf("不支持的设备状态: %s", report.Status)
+	}
+}
+
+// RecordReadings 按设备取最新读数刷新运行状态
+func (s *devicePresenceService) RecordReadings(ctx context.Context, readings []models.UnifiedSensorData) error {
+	latest := make(map[string]*models.UnifiedSensorData)
+	var order []string
+	for i := range readings {
+		reading := &readings[i]
+		current, ok := latest[reading.DeviceID]
+		if !ok {
+			order = append(order, reading.DeviceID)
+		}
+		if !ok || !reading.Timestamp.Before(current.Timestamp) {
+			latest[reading.DeviceID] = reading
+		}
+	}
+
+	now := time.Now()
+	for _, deviceID := range order {
+		reading := latest[deviceID]
+		dataTime := reading.Timestamp
+		status := &models.DeviceRuntimeStatus{
+			DeviceID:       deviceID,
+			Online:         true,
+			LastHeartbeat:  now,
+			LastDataTime:   &dataTime,
+			BatteryLevel:   reading.Battery,
+			SignalStrength: reading.SignalStrength,
+		}
+		columns := []string{"online", "last_heartbeat", "last_data_time"}
+		if reading.Battery != nil {
+			columns = append(columns, "battery_level")
+		}
+		if reading.SignalStrength != nil {
+			columns = append(columns, "signal_strength")
+		}
+
+		if err := s.runtimeRepo.Upsert(ctx, status, columns...); err != nil {
+			return err
+		}
+		s.setTouched(deviceID, now)
+		if err := s.markOnline(ctx, deviceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRuntimeStatus 获取设备运行状态
+func (s *devicePresenceService) GetRuntimeStatus(ctx context.Context, deviceID string) (*models.DeviceRuntimeStatus, error) {
+	if _, err := s.deviceRepo.GetByDeviceID(ctx, deviceID); err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	status, err := s.runtimeRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		status = &models.DeviceRuntimeStatus{DeviceID: deviceID}
+	}
+	return status, nil
+}
+
+// SweepOffline 巡检超时设备
+func (s *devicePresenceService) SweepOffline(ctx context.Context) (int, error) {
+	devices, err := s.deviceRepo.GetOfflineDevices(ctx, s.offlineTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range devices {
+		deviceID := devices[i].ID
+		if err := s.runtimeRepo.SetOffline(ctx, deviceID); err != nil {
+			s.logger.Warn("更新设备运行状态失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+		changed, err := s.markOffline(ctx, deviceID, "设备超时未上报")
+		if err != nil {
+			return count, err
+		}
+		if changed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Start 启动离线巡检协程
+func (s *devicePresenceService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	s.wg.Add(1)
+	go s.sweepLoop()
+
+	s.logger.Info("设备在线状态巡检已启动",
+		utils.Duration("offline_timeout", s.offlineTimeout),
+		utils.Duration("sweep_interval", s.sweepInterval))
+}
+
+// Stop 停止离线巡检协程
+func (s *devicePresenceService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("设备在线状态巡检已停止")
+}
+
+// sweepLoop 定期巡检离线设备
+func (s *devicePresenceService) sweepLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), presenceSweepTimeout)
+			if _, err := s.SweepOffline(ctx); err != nil {
+				s.logger.Error("巡检离线设备失败", utils.ErrorField(err))
+			}
+			cancel()
+		}
+	}
+}
+
+// markOnline 设备由离线恢复在线时更新设备状态并自动解决离线告警
+func (s *devicePresenceService) markOnline(ctx context.Context, deviceID string) error {
+	changed, err := s.deviceRepo.TransitionStatus(ctx, deviceID, models.DeviceStatusOffline, models.DeviceStatusOnline)
+	if err != nil || !changed {
+		return err
+	}
+
+	s.logger.Info("设备已上线", utils.String("device_id", deviceID))
+	if s.alertService == nil {
+		return nil
+	}
+	alerts, err := s.alertService.GetOpenDeviceAlerts(ctx, deviceID, models.AlertMetricDeviceOffline)
+	if err != nil {
+		s.logger.Warn("查询设备离线告警失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return nil
+	}
+	for i := range alerts {
+		if _, err := s.alertService.AutoResolveAlert(ctx, alerts[i].ID, 0); err != nil {
+			s.logger.Warn("自动解决离线告警失败", utils.Int64("alert_id", int64(alerts[i].ID)), utils.ErrorField(err))
+		}
+	}
+	return nil
+}
+
+// markOffline 设备由在线变为离线时更新设备状态并产生离线告警，返回是否实际变更
+func (s *devicePresenceService) markOffline(ctx context.Context, deviceID, reason string) (bool, error) {
+	changed, err := s.deviceRepo.TransitionStatus(ctx, deviceID, models.DeviceStatusOnline, models.DeviceStatusOffline)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	s.logger.Warn("设备已离线",
+		utils.String("device_id", deviceID),
+		utils.String("reason", reason))
+	s.raiseOfflineAlert(ctx, deviceID, reason)
+	return true, nil
+}
+
+// raiseOfflineAlert 产生设备离线告警（已有未关闭的离线告警时跳过）
+func (s *devicePresenceService) raiseOfflineAlert(ctx context.Context, deviceID, reason string) {
+	if s.alertService == nil {
+		return
+	}
+
+	existing, err := s.alertService.GetOpenDeviceAlerts(ctx, deviceID, models.AlertMetricDeviceOffline)
+	if err != nil {
+		s.logger.Warn("查询设备离线告警失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		return
+	}
+	if len(existing) > 0 {
+		return
+	}
+
+	// 当前值记录距最后心跳的秒数
+	var silence float64
+	if status, err := s.runtimeRepo.GetByDeviceID(ctx, deviceID); err == nil && status != nil {
+		silence = time.Since(status.LastHeartbeat).Seconds()
+	}
+	message := fmt.Sprintf("设备 %s 离线: %s", deviceID, reason)
+	alert := &models.Alert{
+		DeviceID:       deviceID,
+		Metric:         models.AlertMetricDeviceOffline,
+		CurrentValue:   silence,
+		ThresholdValue: s.offlineTimeout.Seconds(),
+		Severity:       string(models.AlertSeverityWarning),
+		Status:         string(models.AlertStatusActive),
+		TriggeredAt:    time.Now(),
+		Message:        &message,
+	}
+	if err := s.alertService.CreateAlert(ctx, alert); err != nil {
+		s.logger.Error("创建设备离线告警失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+	}
+}
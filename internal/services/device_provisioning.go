@@ -0,0 +1,332 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// registeredDeviceTTL 已注册设备的确认结果缓存时间，避免每条数据都查询设备表
+const registeredDeviceTTL = time.Minute
+
+var (
+	// ErrDeviceNotRegistered 设备未注册，strict策略下拒绝数据
+	ErrDeviceNotRegistered = errors.New("设备未注册")
+	// ErrDeviceQuarantined 设备待审批，数据已隔离
+	ErrDeviceQuarantined = errors.New("设备待审批，数据已隔离")
+	// ErrPendingDeviceNotFound 待审批设备不存在
+	ErrPendingDeviceNotFound = errors.New("待审批设备不存在")
+	// ErrInvalidApproval 审批参数无效
+	ErrInvalidApproval = errors.New("审批参数无效")
+)
+
+// ProvisioningResult 接入策略处理结果
+type ProvisioningResult struct {
+	Admitted    []models.UnifiedSensorData // 可入库的数据（保持原始顺序）
+	Rejected    int                        // 被拒绝的数据条数
+	Quarantined int                        // 被隔离等待审批的数据条数
+}
+
+// DeviceProvisioningService 未注册设备接入策略服务接口，MQTT与HTTP上报共用
+type DeviceProvisioningService interface {
+	Mode() models.ProvisioningMode
+	// Admit 按接入策略筛选数据：已注册设备直接放行，未注册设备按策略拒绝、自动注册或隔离
+	Admit(ctx context.Context, batch []models.UnifiedSensorData) (*ProvisioningResult, error)
+	ListPending(ctx context.Context) ([]models.PendingDevice, error)
+	// ApprovePending 审批通过：注册设备并将隔离数据写入传感器数据表
+	ApprovePending(ctx context.Context, deviceID string, req *models.PendingDeviceApproveRequest) (*models.Device, error)
+	// RejectPending 拒绝待审批设备并丢弃隔离数据
+	RejectPending(ctx context.Context, deviceID string) error
+}
+
+// deviceProvisioningService 接入策略服务实现
+type deviceProvisioningService struct {
+	mode        models.ProvisioningMode
+	maxPending  int
+	deviceRepo  repositories.DeviceRepository
+	pendingRepo repositories.PendingDeviceRepository
+	dataRepo    repositories.UnifiedSensorDataRepository
+	shadow      DeviceShadowService
+	logger      utils.Logger
+
+	mu         sync.Mutex
+	registered map[string]time.Time // 已确认注册的设备及确认时间
+}
+
+// NewDeviceProvisioningService 创建接入策略服务（shadow为空时审批回放的数据不更新设备影子）
+func NewDeviceProvisioningService(
+	cfg config.ProvisioningConfig,
+	deviceRepo repositories.DeviceRepository,
+	pendingRepo repositories.PendingDeviceRepository,
+	dataRepo repositories.UnifiedSensorDataRepository,
+	shadow DeviceShadowService,
+	logger utils.Logger,
+) DeviceProvisioningService {
+	mode := models.ProvisioningMode(cfg.Mode)
+	if !mode.IsValid() {
+		logger.Warn("未知的设备接入策略，使用strict", utils.String("mode", cfg.Mode))
+		mode = models.ProvisioningModeStrict
+	}
+	if cfg.MaxPendingReadings <= 0 {
+		cfg.MaxPendingReadings = 1000
+	}
+	return &deviceProvisioningService{
+		mode:        mode,
+		maxPending:  cfg.MaxPendingReadings,
+		deviceRepo:  deviceRepo,
+		pendingRepo: pendingRepo,
+		dataRepo:    dataRepo,
+		shadow:      shadow,
+		logger:      logger,
+		registered:  make(map[string]time.Time),
+	}
+}
+
+// Mode 当前接入策略
+func (s *deviceProvisioningService) Mode() models.ProvisioningMode {
+	return s.mode
+}
+
+// Admit 按设备分组处理数据，查询失败时返回错误由调用方决定是否重试
+func (s *deviceProvisioningService) Admit(ctx context.Context, batch []models.UnifiedSensorData) (*ProvisioningResult, error) {
+	byDevice := make(map[string][]models.UnifiedSensorData)
+	var order []string
+	for _, data := range batch {
+		if _, ok := byDevice[data.DeviceID]; !ok {
+			order = append(order, data.DeviceID)
+		}
+		byDevice[data.DeviceID] = append(byDevice[data.DeviceID], data)
+	}
+
+	result := &ProvisioningResult{}
+	admitted := make(map[string]bool, len(order))
+	for _, deviceID := range order {
+		readings := byDevice[deviceID]
+		ok, err := s.isRegistered(ctx, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			switch s.mode {
+			case models.ProvisioningModeAuto:
+				ok = s.register(ctx, deviceID, readings[0].DeviceType)
+			case models.ProvisioningModePending:
+				if err := s.quarantine(ctx, deviceID, readings); err != nil {
+					return nil, err
+				}
+				result.Quarantined += len(readings)
+				continue
+			default:
+				s.logger.Warn("设备未注册，拒绝数据",
+					utils.String("device_id", deviceID),
+					utils.Int("count", len(readings)))
+			}
+		}
+		if !ok {
+			result.Rejected += len(readings)
+			continue
+		}
+		admitted[deviceID] = true
+	}
+
+	for _, data := range batch {
+		if admitted[data.DeviceID] {
+			result.Admitted = append(result.Admitted, data)
+		}
+	}
+	return result, nil
+}
+
+// isRegistered 设备是否已注册（软删除的设备视为未注册）
+func (s *deviceProvisioningService) isRegistered(ctx context.Context, deviceID string) (bool, error) {
+	s.mu.Lock()
+	confirmedAt, ok := s.registered[deviceID]
+	s.mu.Unlock()
+	if ok && time.Since(confirmedAt) < registeredDeviceTTL {
+		return true, nil
+	}
+
+	count, err := s.deviceRepo.Count(ctx, map[string]interface{}{"id": deviceID})
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+	s.markRegistered(deviceID)
+	return true, nil
+}
+
+// markRegistered 缓存设备已注册的确认结果
+func (s *deviceProvisioningService) markRegistered(deviceID string) {
+	s.mu.Lock()
+	s.registered[deviceID] = time.Now()
+	s.mu.Unlock()
+}
+
+// register auto策略下按上报的设备类型和ID注册设备，返回是否可接收数据
+func (s *deviceProvisioningService) register(ctx context.Context, deviceID string, deviceType models.DeviceType) bool {
+	if !deviceType.IsValid() {
+		s.logger.Warn("设备类型无效，无法自动注册",
+			utils.String("device_id", deviceID),
+			utils.String("device_type", string(deviceType)))
+		return false
+	}
+
+	device := &models.Device{
+		ID:     deviceID,
+		Name:   deviceID,
+		Type:   deviceType,
+		Status: models.DeviceStatusOffline,
+	}
+	if err := s.deviceRepo.Create(ctx, device); err != nil {
+		// 并发注册时另一批次可能已创建成功；已被删除的设备ID不会自动恢复
+		if ok, checkErr := s.isRegistered(ctx, deviceID); checkErr == nil && ok {
+			return true
+		}
+		s.logger.Warn("自动注册设备失败，拒绝数据",
+			utils.String("device_id", deviceID),
+			utils.ErrorField(err))
+		return false
+	}
+
+	s.markRegistered(deviceID)
+	s.logger.Info("自动注册设备",
+		utils.String("device_id", deviceID),
+		utils.String("device_type", string(deviceType)))
+	return true
+}
+
+// quarantine pending策略下登记待审批设备并隔离数据
+func (s *deviceProvisioningService) quarantine(ctx context.Context, deviceID string, readings []models.UnifiedSensorData) error {
+	encoded := make([]string, 0, len(readings))
+	for i := range readings {
+		data, err := json.Marshal(&readings[i])
+		if err != nil {
+			return fmt.Errorf("序列化隔离数据失败: %w", err)
+		}
+		encoded = append(encoded, string(data))
+	}
+
+	stored, err := s.pendingRepo.Quarantine(ctx, deviceID, readings[0].DeviceType, encoded, time.Now(), s.maxPending)
+	if err != nil {
+		return err
+	}
+	if stored < len(readings) {
+		s.logger.Warn("待审批设备隔离数据已达上限，丢弃超出部分",
+			utils.String("device_id", deviceID),
+			utils.Int("dropped", len(readings)-stored),
+			utils.Int("limit", s.maxPending))
+	} else {
+		s.logger.Info("设备待审批，数据已隔离",
+			utils.String("device_id", deviceID),
+			utils.Int("count", stored))
+	}
+	return nil
+}
+
+// ListPending 获取待审批设备列表
+func (s *deviceProvisioningService) ListPending(ctx context.Context) ([]models.PendingDevice, error) {
+	return s.pendingRepo.List(ctx)
+}
+
+// ApprovePending 注册设备后回放隔离数据，回放失败时保留待审批记录以便重试
+func (s *deviceProvisioningService) ApprovePending(ctx context.Context, deviceID string, req *models.PendingDeviceApproveRequest) (*models.Device, error) {
+	pending, err := s.pendingRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, ErrPendingDeviceNotFound
+	}
+
+	deviceType := pending.DeviceType
+	if req.Type != "" {
+		deviceType = req.Type
+	}
+	if !deviceType.IsValid() {
+		return nil, fmt.Errorf("%w: 不支持的设备类型 %s", ErrInvalidApproval, deviceType)
+	}
+	name := req.Name
+	if name == "" {
+		name = deviceID
+	}
+
+	registered, err := s.isRegistered(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if !registered {
+		device := &models.Device{
+			ID:                deviceID,
+			Name:              name,
+			Type:              deviceType,
+			LocationLatitude:  req.LocationLatitude,
+			LocationLongitude: req.LocationLongitude,
+			LocationAddress:   req.LocationAddress,
+			Status:            models.DeviceStatusOffline,
+		}
+		if err := s.deviceRepo.Create(ctx, device); err != nil {
+			return nil, err
+		}
+		s.markRegistered(deviceID)
+	}
+
+	rows, err := s.pendingRepo.ListReadings(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]models.UnifiedSensorData, 0, len(rows))
+	for _, row := range rows {
+		var data models.UnifiedSensorData
+		if err := json.Unmarshal([]byte(row.Data), &data); err != nil {
+			s.logger.Warn("解析隔离数据失败，跳过", utils.Int64("reading_id", int64(row.ID)), utils.ErrorField(err))
+			continue
+		}
+		data.ID = 0
+		data.DeviceType = deviceType
+		readings = append(readings, data)
+	}
+	if err := s.dataRepo.BatchInsert(ctx, readings); err != nil {
+		return nil, fmt.Errorf("回放隔离数据失败: %w", err)
+	}
+	if s.shadow != nil && len(readings) > 0 {
+		if err := s.shadow.ApplyReadings(ctx, readings); err != nil {
+			s.logger.Error("更新设备影子失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+	}
+	if err := s.pendingRepo.Delete(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("待审批设备已通过",
+		utils.String("device_id", deviceID),
+		utils.String("device_type", string(deviceType)),
+		utils.Int("replayed", len(readings)))
+	return s.deviceRepo.GetByDeviceID(ctx, deviceID)
+}
+
+// RejectPending 删除待审批设备及隔离数据，设备再次上报时会重新进入待审批列表
+func (s *deviceProvisioningService) RejectPending(ctx context.Context, deviceID string) error {
+	pending, err := s.pendingRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return ErrPendingDeviceNotFound
+	}
+	if err := s.pendingRepo.Delete(ctx, deviceID); err != nil {
+		return err
+	}
+	s.logger.Info("待审批设备已拒绝",
+		utils.String("device_id", deviceID),
+		utils.Int64("discarded", pending.QuarantinedCount))
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// provisioningFixture 接入策略测试环境：设备1已注册，每台待审批设备最多隔离2条数据
+type provisioningFixture struct {
+	db           *gorm.DB
+	provisioning DeviceProvisioningService
+	data         UnifiedSensorDataService
+}
+
+// newProvisioningFixture 按指定接入策略创建接入服务和统一数据服务
+func newProvisioningFixture(t *testing.T, mode string) *provisioningFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+	f := &provisioningFixture{db: db}
+	f.provisioning = NewDeviceProvisioningService(config.ProvisioningConfig{Mode: mode, MaxPendingReadings: 2},
+		deviceRepo, repositories.NewPendingDeviceRepository(db, logger), dataRepo, nil, logger)
+	f.data = NewUnifiedSensorDataService(dataRepo, deviceRepo, nil, nil, nil, f.provisioning, nil, "", logger)
+	return f
+}
+
+// provisioningReading 构造一条甲醛读数
+func provisioningReading(deviceID string, deviceType models.DeviceType, formaldehyde float64) models.UnifiedSensorData {
+	return models.UnifiedSensorData{DeviceID: deviceID, DeviceType: deviceType, Timestamp: time.Now(), Formaldehyde: &formaldehyde}
+}
+
+// count 统计模型中满足条件的行数
+func (f *provisioningFixture) count(t *testing.T, model interface{}, deviceID string) int64 {
+	var count int64
+	query := f.db.Model(model)
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	require.NoError(t, query.Count(&count).Error)
+	return count
+}
+
+// registered 返回设备是否已注册及其类型
+func (f *provisioningFixture) registered(t *testing.T, deviceID string) (bool, models.DeviceType) {
+	var devices []models.Device
+	require.NoError(t, f.db.Where("id = ?", deviceID).Find(&devices).Error)
+	if len(devices) == 0 {
+		return false, ""
+	}
+	return true, devices[0].Type
+}
+
+// TestDeviceProvisioningService_Admit 测试已注册设备直接放行，未注册设备按策略拒绝、自动注册或隔离
+func TestDeviceProvisioningService_Admit(t *testing.T) {
+	tests := []struct {
+		mode        string
+		admitted    int
+		rejected    int
+		quarantined int
+		registered  bool
+	}{
+		{"strict", 1, 3, 0, false},
+		{"auto", 4, 0, 0, true},
+		{"pending", 1, 0, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newProvisioningFixture(t, tt.mode)
+			batch := []models.UnifiedSensorData{provisioningReading(testutil.DeviceID1, models.DeviceTypeFormaldehyde, 0.05)}
+			for _, value := range []float64{0.05, 0.06, 0.07} {
+				batch = append(batch, provisioningReading(testutil.DeviceID2, models.DeviceTypePM25, value))
+			}
+			result, err := f.provisioning.Admit(context.Background(), batch)
+			require.NoError(t, err)
+			assert.Len(t, result.Admitted, tt.admitted)
+			assert.Equal(t, tt.rejected, result.Rejected)
+			assert.Equal(t, tt.quarantined, result.Quarantined)
+
+			registered, deviceType := f.registered(t, testutil.DeviceID2)
+			assert.Equal(t, tt.registered, registered)
+			if registered {
+				assert.Equal(t, models.DeviceTypePM25, deviceType)
+			}
+		})
+	}
+}
+
+// TestUnifiedSensorDataService_CreateDataProvisioning 测试HTTP上报同样按接入策略处理未注册设备
+func TestUnifiedSensorDataService_CreateDataProvisioning(t *testing.T) {
+	tests := []struct {
+		mode   string
+		err    error
+		stored int64
+	}{
+		{"strict", ErrDeviceNotRegistered, 0},
+		{"auto", nil, 1},
+		{"pending", ErrDeviceQuarantined, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newProvisioningFixture(t, tt.mode)
+			reading := provisioningReading(testutil.DeviceID3, models.DeviceTypeCO2, 0.05)
+			err := f.data.CreateData(context.Background(), &reading)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.stored, f.count(t, &models.UnifiedSensorData{}, testutil.DeviceID3))
+		})
+	}
+}
+
+// TestDeviceProvisioningService_ApproveReject 测试pending策略超过上限只计数，审批后注册设备并回放隔离数据，拒绝后丢弃
+func TestDeviceProvisioningService_ApproveReject(t *testing.T) {
+	f := newProvisioningFixture(t, "pending")
+	ctx := context.Background()
+	batch := []models.UnifiedSensorData{provisioningReading(testutil.DeviceID3, models.DeviceTypeFormaldehyde, 0.05)}
+	for _, value := range []float64{0.05, 0.06, 0.07} {
+		batch = append(batch, provisioningReading(testutil.DeviceID2, models.DeviceTypeFormaldehyde, value))
+	}
+	_, err := f.provisioning.Admit(ctx, batch)
+	require.NoError(t, err)
+
+	pending, err := f.provisioning.ListPending(ctx)
+	require.NoError(t, err)
+	counts := map[string][2]int64{}
+	for _, device := range pending {
+		counts[device.DeviceID] = [2]int64{device.ReadingCount, device.QuarantinedCount}
+	}
+	assert.Equal(t, map[string][2]int64{testutil.DeviceID2: {3, 2}, testutil.DeviceID3: {1, 1}}, counts)
+
+	device, err := f.provisioning.ApprovePending(ctx, testutil.DeviceID2, &models.PendingDeviceApproveRequest{Name: "客厅甲醛"})
+	require.NoError(t, err)
+	assert.Equal(t, "客厅甲醛", device.Name)
+	assert.Equal(t, models.DeviceTypeFormaldehyde, device.Type)
+	assert.Equal(t, int64(2), f.count(t, &models.UnifiedSensorData{}, testutil.DeviceID2))
+	require.NoError(t, f.provisioning.RejectPending(ctx, testutil.DeviceID3))
+	assert.Equal(t, int64(0), f.count(t, &models.QuarantinedReading{}, ""))
+	registered, _ := f.registered(t, testutil.DeviceID3)
+	assert.False(t, registered)
+
+	// 已处理的设备不再待审批
+	_, err = f.provisioning.ApprovePending(ctx, testutil.DeviceID2, &models.PendingDeviceApproveRequest{})
+	assert.ErrorIs(t, err, ErrPendingDeviceNotFound)
+	assert.ErrorIs(t, f.provisioning.RejectPending(ctx, testutil.DeviceID3), ErrPendingDeviceNotFound)
+}
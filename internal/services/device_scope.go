@@ -0,0 +1,142 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"sync"
+	"time"
+)
+
+// deviceMembershipTTL 设备归属缓存有效期（分配变更时主动失效）
+const deviceMembershipTTL = time.Minute
+
+// DeviceScopeService 设备范围服务接口：将分组/位置筛选解析为设备集合，并为告警规则提供设备归属
+type DeviceScopeService interface {
+	// ResolveDeviceIDs 解析筛选条件对应的设备ID，筛选为空时返回nil表示不限制
+	ResolveDeviceIDs(ctx context.Context, filter models.DeviceScopeFilter) ([]string, error)
+	// Membership 获取设备所在位置路径和所属分组
+	Membership(ctx context.Context, deviceID string) (*models.DeviceMembership, error)
+	// Invalidate 清空设备归属缓存
+	Invalidate()
+}
+
+// membershipEntry 设备归属缓存项
+type membershipEntry struct {
+	membership *models.DeviceMembership
+	loadedAt   time.Time
+}
+
+// deviceScopeService 设备范围服务实现
+type deviceScopeService struct {
+	deviceRepo   repositories.DeviceRepository
+	locationRepo repositories.LocationRepository
+	groupRepo    repositories.DeviceGroupRepository
+	logger       utils.Logger
+
+	mu    sync.RWMutex
+	cache map[string]membershipEntry
+}
+
+// NewDeviceScopeService 创建设备范围服务
+func NewDeviceScopeService(deviceRepo repositories.DeviceRepository, locationRepo repositories.LocationRepository, groupRepo repositories.DeviceGroupRepository, logger utils.Logger) DeviceScopeService {
+	return &deviceScopeService{
+		deviceRepo:   deviceRepo,
+		locationRepo: locationRepo,
+		groupRepo:    groupRepo,
+		logger:       logger,
+		cache:        make(map[string]membershipEntry),
+	}
+}
+
+// ResolveDeviceIDs 分组取成员，位置取子树内全部设备，同时指定时取交集
+func (s *deviceScopeService) ResolveDeviceIDs(ctx context.Context, filter models.DeviceScopeFilter) ([]string, error) {
+	if filter.IsEmpty() {
+		return nil, nil
+	}
+
+	var groupIDs []string
+	if filter.GroupID != nil {
+		group, err := s.groupRepo.GetByID(ctx, *filter.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return nil, ErrDeviceGroupNotFound
+		}
+		if groupIDs, err = s.groupRepo.ListMemberIDs(ctx, group.ID); err != nil {
+			return nil, err
+		}
+		if filter.LocationID == nil {
+			return groupIDs, nil
+		}
+	}
+
+	node, err := s.locationRepo.GetByID(ctx, *filter.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, ErrLocationNotFound
+	}
+	subtree, err := s.locationRepo.ListSubtree(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	locationIDs := make([]uint64, 0, len(subtree))
+	for _, n := range subtree {
+		locationIDs = append(locationIDs, n.ID)
+	}
+	deviceIDs, err := s.deviceRepo.ListIDsByLocations(ctx, locationIDs)
+	if err != nil {
+		return nil, err
+	}
+	if filter.GroupID == nil {
+		return deviceIDs, nil
+	}
+
+	inGroup := make(map[string]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		inGroup[id] = true
+	}
+	ids := []string{}
+	for _, id := range deviceIDs {
+		if inGroup[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Membership 优先读取缓存，过期后重新查询
+func (s *deviceScopeService) Membership(ctx context.Context, deviceID string) (*models.DeviceMembership, error) {
+	s.mu.RLock()
+	entry, ok := s.cache[deviceID]
+	s.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < deviceMembershipTTL {
+		return entry.membership, nil
+	}
+
+	path, err := s.locationRepo.GetPathByDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs, err := s.groupRepo.ListGroupIDsByDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	membership := &models.DeviceMembership{LocationPath: path, GroupIDs: groupIDs}
+
+	s.mu.Lock()
+	s.cache[deviceID] = membershipEntry{membership: membership, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return membership, nil
+}
+
+// Invalidate 清空缓存
+func (s *deviceScopeService) Invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]membershipEntry)
+	s.mu.Unlock()
+}
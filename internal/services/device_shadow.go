@@ -0,0 +1,255 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// 设备影子缓存参数
+const (
+	deviceShadowKeyPrefix = "device:shadow:"
+	deviceShadowCacheTTL  = 24 * time.Hour
+)
+
+// DeviceShadowService 设备影子服务接口
+type DeviceShadowService interface {
+	// ApplyReadings 将入库成功的读数合并到设备影子（较旧的读数不覆盖较新的取值）
+	ApplyReadings(ctx context.Context, batch []models.UnifiedSensorData) error
+	// GetShadow 获取设备影子，包含指标最近取值、上报状态与期望状态
+	GetShadow(ctx context.Context, deviceID string) (*models.DeviceShadowView, error)
+	// ListShadows 获取全部设备的指标影子
+	ListShadows(ctx context.Context) ([]models.DeviceShadow, error)
+}
+
+// deviceShadowService 设备影子服务实现
+type deviceShadowService struct {
+	shadowRepo    repositories.DeviceShadowRepository
+	deviceRepo    repositories.DeviceRepository
+	runtimeRepo   repositories.DeviceRuntimeStatusRepository
+	configService DeviceConfigService
+	cache         *utils.Cache
+	logger        utils.Logger
+
+	// mu 串行化读改写，避免并发批次互相覆盖指标
+	mu sync.Mutex
+}
+
+// NewDeviceShadowService 创建设备影子服务，redis为nil时仅使用数据库
+func NewDeviceShadowService(
+	shadowRepo repositories.DeviceShadowRepository,
+	deviceRepo repositories.DeviceRepository,
+	runtimeRepo repositories.DeviceRuntimeStatusRepository,
+	configService DeviceConfigService,
+	redis *utils.Redis,
+	logger utils.Logger,
+) DeviceShadowService {
+	s := &deviceShadowService{
+		shadowRepo:    shadowRepo,
+		deviceRepo:    deviceRepo,
+		runtimeRepo:   runtimeRepo,
+		configService: configService,
+		logger:        logger,
+	}
+	if redis != nil {
+		s.cache = utils.NewCache(redis)
+	}
+	return s
+}
+
+// ApplyReadings 合并读数到设备影子
+func (s *deviceShadowService) ApplyReadings(ctx context.Context, batch []models.UnifiedSensorData) error {
+	byDevice := make(map[string][]*models.UnifiedSensorData)
+	var order []string
+	for i := range batch {
+		deviceID := batch[i].DeviceID
+		if _, ok := byDevice[deviceID]; !ok {
+			order = append(order, deviceID)
+		}
+		byDevice[deviceID] = append(byDevice[deviceID], &batch[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, deviceID := range order {
+		shadow, err := s.load(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if shadow == nil {
+			shadow = &models.DeviceShadow{DeviceID: deviceID}
+		}
+
+		metrics := decodeShadowMetrics(shadow.Metrics)
+		changed := false
+		for _, reading := range byDevice[deviceID] {
+			if mergeShadowReading(shadow, metrics, reading) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		data, err := json.Marshal(metrics)
+		if err != nil {
+			return err
+		}
+		encoded := string(data)
+		shadow.Metrics = &encoded
+		shadow.Version++
+		shadow.UpdatedAt = time.Now()
+		if err := s.shadowRepo.Save(ctx, shadow); err != nil {
+			return err
+		}
+		s.store(ctx, shadow)
+	}
+	return nil
+}
+
+// GetShadow 获取设备影子
+func (s *deviceShadowService) GetShadow(ctx context.Context, deviceID string) (*models.DeviceShadowView, error) {
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	view := &models.DeviceShadowView{
+		DeviceID:   deviceID,
+		DeviceType: device.Type,
+		Metrics:    map[string]models.ShadowMetric{},
+		InSync:     true,
+	}
+
+	shadow, err := s.load(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if shadow != nil {
+		view.Metrics = decodeShadowMetrics(shadow.Metrics)
+		view.LastDataTime = shadow.LastDataTime
+		view.Version = shadow.Version
+		view.UpdatedAt = &shadow.UpdatedAt
+	}
+
+	runtime, err := s.runtimeRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if runtime != nil {
+		view.Reported.Online = runtime.Online
+		view.Reported.FirmwareVersion = runtime.FirmwareVersion
+		view.Reported.UptimeSeconds = runtime.UptimeSeconds
+		view.Reported.LastHeartbeat = &runtime.LastHeartbeat
+	}
+
+	if s.configService != nil {
+		configSync, err := s.configService.GetSyncStatus(ctx, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		view.Reported.Config = configSync.Reported
+		view.Reported.ConfigVersion = configSync.ReportedVersion
+		view.Desired.Config = configSync.Desired
+		view.Desired.ConfigVersion = configSync.DesiredVersion
+		view.Delta = configSync.Delta
+		view.InSync = configSync.InSync
+	}
+	return view, nil
+}
+
+// ListShadows 获取全部设备影子
+func (s *deviceShadowService) ListShadows(ctx context.Context) ([]models.DeviceShadow, error) {
+	return s.shadowRepo.List(ctx)
+}
+
+// load 读取设备影子，优先使用Redis缓存
+func (s *deviceShadowService) load(ctx context.Context, deviceID string) (*models.DeviceShadow, error) {
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, deviceShadowKeyPrefix+deviceID); err == nil {
+			var shadow models.DeviceShadow
+			if err := json.Unmarshal([]byte(data), &shadow); err == nil {
+				return &shadow, nil
+			}
+		}
+	}
+
+	shadow, err := s.shadowRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if shadow != nil {
+		s.store(ctx, shadow)
+	}
+	return shadow, nil
+}
+
+// store 写入Redis缓存，失败时仅记录日志
+func (s *deviceShadowService) store(ctx context.Context, shadow *models.DeviceShadow) {
+	if s.cache == nil {
+		return
+	}
+	data, err := json.Marshal(shadow)
+	if err != nil {
+		return
+	}
+	if err := s.cache.Set(ctx, deviceShadowKeyPrefix+shadow.DeviceID, data, deviceShadowCacheTTL); err != nil {
+		s.logger.Warn("写入设备影子缓存失败", utils.String("device_id", shadow.DeviceID), utils.ErrorField(err))
+	}
+}
+
+// mergeShadowReading 合并单条读数，返回影子是否变化
+func mergeShadowReading(shadow *models.DeviceShadow, metrics map[string]models.ShadowMetric, reading *models.UnifiedSensorData) bool {
+	changed := false
+	for _, metric := range reading.GetAvailableMetrics() {
+		value := reading.GetMetricValue(metric)
+		if value == nil {
+			continue
+		}
+		changed = setShadowMetric(metrics, metric, *value, reading) || changed
+	}
+	if reading.Battery != nil {
+		changed = setShadowMetric(metrics, "battery", float64(*reading.Battery), reading) || changed
+	}
+	if reading.SignalStrength != nil {
+		changed = setShadowMetric(metrics, "signal_strength", float64(*reading.SignalStrength), reading) || changed
+	}
+
+	if shadow.LastDataTime == nil || !reading.Timestamp.Before(*shadow.LastDataTime) {
+		timestamp := reading.Timestamp
+		shadow.LastDataTime = &timestamp
+		shadow.DeviceType = reading.DeviceType
+		shadow.SensorID = reading.SensorID
+		shadow.SensorType = reading.SensorType
+		shadow.DataQuality = reading.DataQuality
+		changed = true
+	}
+	return changed
+}
+
+// setShadowMetric 仅当读数不早于现有取值时更新指标
+func setShadowMetric(metrics map[string]models.ShadowMetric, metric string, value float64, reading *models.UnifiedSensorData) bool {
+	if current, ok := metrics[metric]; ok && reading.Timestamp.Before(current.Timestamp) {
+		return false
+	}
+	metrics[metric] = models.ShadowMetric{
+		Value:     value,
+		Timestamp: reading.Timestamp,
+		SensorID:  reading.SensorID,
+	}
+	return true
+}
+
+// decodeShadowMetrics 解析影子指标，为空或无效时返回空表
+func decodeShadowMetrics(data *string) map[string]models.ShadowMetric {
+	metrics := make(map[string]models.ShadowMetric)
+	if data != nil && *data != "" {
+		_ = json.Unmarshal([]byte(*data), &metrics)
+	}
+	return metrics
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeviceShadowService_ApplyReadings 测试设备影子按指标保留最近取值，补传的旧读数不覆盖较新的取值
+func TestDeviceShadowService_ApplyReadings(t *testing.T) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	runtimeRepo := repositories.NewDeviceRuntimeStatusRepository(db, logger)
+	configService := NewDeviceConfigService(repositories.NewDeviceConfigStateRepository(db, logger), deviceRepo, logger)
+	shadowService := NewDeviceShadowService(repositories.NewDeviceShadowRepository(db, logger), deviceRepo, runtimeRepo, configService, nil, logger)
+	presence := NewDevicePresenceService(config.DeviceConfig{}, deviceRepo, runtimeRepo, nil, logger)
+
+	now := time.Now().Truncate(time.Second)
+	formaldehyde, temperature, staleTemperature, humidity := 0.05, 22.5, 18.0, 45.0
+	battery := 80
+	extended := `{"tvoc":0.3}`
+	for _, batch := range [][]models.UnifiedSensorData{
+		{
+			{DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeFormaldehyde, SensorID: "s1", Timestamp: now.Add(-time.Minute),
+				Formaldehyde: &formaldehyde, Temperature: &temperature, Battery: &battery, ExtendedData: &extended},
+			{DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeFormaldehyde, SensorID: "s2", Timestamp: now, Humidity: &humidity},
+		},
+		{
+			{DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeFormaldehyde, SensorID: "s1", Timestamp: now.Add(-time.Hour), Temperature: &staleTemperature},
+		},
+	} {
+		require.NoError(t, presence.RecordReadings(ctx, batch))
+		require.NoError(t, shadowService.ApplyReadings(ctx, batch))
+	}
+
+	shadow, err := shadowService.GetShadow(ctx, testutil.DeviceID1)
+	require.NoError(t, err)
+	tests := []struct {
+		metric   string
+		value    float64
+		at       time.Time
+		sensorID string
+	}{
+		{"formaldehyde", 0.05, now.Add(-time.Minute), "s1"},
+		{"temperature", 22.5, now.Add(-time.Minute), "s1"},
+		{"humidity", 45, now, "s2"},
+		{"tvoc", 0.3, now.Add(-time.Minute), "s1"},
+		{"battery", 80, now.Add(-time.Minute), "s1"},
+	}
+	for _, tt := range tests {
+		metric, ok := shadow.Metrics[tt.metric]
+		require.True(t, ok, tt.metric)
+		assert.Equal(t, tt.value, metric.Value, tt.metric)
+		assert.True(t, tt.at.Equal(metric.Timestamp), tt.metric)
+		assert.Equal(t, tt.sensorID, metric.SensorID, tt.metric)
+	}
+	assert.Equal(t, models.DeviceTypeFormaldehyde, shadow.DeviceType)
+	require.NotNil(t, shadow.LastDataTime)
+	assert.True(t, now.Equal(*shadow.LastDataTime))
+	assert.Equal(t, int64(1), shadow.Version, "旧读数不产生新版本")
+	assert.True(t, shadow.Reported.Online)
+	assert.True(t, shadow.InSync)
+
+	// 仪表板列表读取影子表
+	shadows, err := shadowService.ListShadows(ctx)
+	require.NoError(t, err)
+	require.Len(t, shadows, 1)
+	assert.Equal(t, "s2", shadows[0].SensorID)
+
+	_, err = shadowService.GetShadow(ctx, "unknown-device")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrFirmwareNotFound 固件不存在
+	ErrFirmwareNotFound = errors.New("固件不存在")
+	// ErrFirmwareExists 同类型设备的固件版本已存在
+	ErrFirmwareExists = errors.New("固件版本已存在")
+	// ErrInvalidFirmware 固件信息或文件无效
+	ErrInvalidFirmware = errors.New("固件无效")
+	// ErrFirmwareTooLarge 固件文件超过大小上限
+	ErrFirmwareTooLarge = errors.New("固件文件过大")
+	// ErrInvalidDownloadLink 下载链接签名无效或已过期
+	ErrInvalidDownloadLink = errors.New("下载链接无效或已过期")
+)
+
+// firmwareVersionPattern 固件版本号格式（同时用作文件名）
+var firmwareVersionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// FirmwareService 固件存储服务接口
+type FirmwareService interface {
+	// UploadFirmware 保存固件文件并计算SHA-256
+	UploadFirmware(ctx context.Context, req *models.FirmwareUploadRequest, fileName string, file io.Reader, userID *uint64) (*models.Firmware, error)
+	GetFirmware(ctx context.Context, id uint64) (*models.Firmware, error)
+	ListFirmware(ctx context.Context, deviceType models.DeviceType) ([]models.Firmware, error)
+	// DownloadURL 生成设备专用的签名下载链接
+	DownloadURL(firmware *models.Firmware, deviceID string, expiresAt time.Time) string
+	// VerifyDownload 校验下载链接签名，返回对应固件
+	VerifyDownload(ctx context.Context, id uint64, deviceID string, expires int64, signature string) (*models.Firmware, error)
+}
+
+// firmwareService 固件存储服务实现
+type firmwareService struct {
+	config       config.FirmwareConfig
+	firmwareRepo repositories.FirmwareRepository
+	logger       utils.Logger
+}
+
+// NewFirmwareService 创建固件存储服务
+func NewFirmwareService(cfg config.FirmwareConfig, firmwareRepo repositories.FirmwareRepository, logger utils.Logger) FirmwareService {
+	if cfg.Dir == "" {
+		cfg.Dir = "data/firmware"
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 64
+	}
+	return &firmwareService{
+		config:       cfg,
+		firmwareRepo: firmwareRepo,
+		logger:       logger,
+	}
+}
+
+// UploadFirmware 边写入磁盘边计算摘要，成功后登记固件记录。每次上传写入独立的文件，
+// 同一版本并发上传时登记失败的一方只删除自己写入的文件
+func (s *firmwareService) UploadFirmware(ctx context.Context, req *models.FirmwareUploadRequest, fileName string, file io.Reader, userID *uint64) (*models.Firmware, error) {
+	if !req.DeviceType.IsValid() {
+		return nil, fmt.Errorf("%w: 不支持的设备类型 %s", ErrInvalidFirmware, req.DeviceType)
+	}
+	if !firmwareVersionPattern.MatchString(req.Version) {
+		return nil, fmt.Errorf("%w: 版本号只能包含字母、数字、点、下划线和短横线", ErrInvalidFirmware)
+	}
+
+	existing, err := s.firmwareRepo.GetByVersion(ctx, req.DeviceType, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrFirmwareExists
+	}
+
+	dir := filepath.Join(s.config.Dir, string(req.DeviceType))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建固件目录失败: %w", err)
+	}
+	out, err := os.CreateTemp(dir, req.Version+"-*.bin")
+	if err != nil {
+		return nil, fmt.Errorf("创建固件文件失败: %w", err)
+	}
+	path := out.Name()
+	stored := false
+	defer func() {
+		if !stored {
+			os.Remove(path)
+		}
+	}()
+
+	maxSize := int64(s.config.MaxSize) << 20
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(file, maxSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存固件文件失败: %w", err)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: 固件文件为空", ErrInvalidFirmware)
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: 上限为 %dMB", ErrFirmwareTooLarge, s.config.MaxSize)
+	}
+
+	firmware := &models.Firmware{
+		DeviceType: req.DeviceType,
+		Version:    req.Version,
+		FileName:   filepath.Base(fileName),
+		FilePath:   path,
+		Size:       size,
+		SHA256:     hex.EncodeToString(hasher.Sum(nil)),
+		UploadedBy: userID,
+	}
+	if notes := strings.TrimSpace(req.Notes); notes != "" {
+		firmware.Notes = &notes
+	}
+	if err := s.firmwareRepo.Create(ctx, firmware); err != nil {
+		// 并发上传同一版本时唯一索引冲突
+		if existing, getErr := s.firmwareRepo.GetByVersion(ctx, req.DeviceType, req.Version); getErr == nil && existing != nil {
+			return nil, ErrFirmwareExists
+		}
+		return nil, err
+	}
+	stored = true
+
+	s.logger.Info("固件上传成功",
+		utils.String("device_type", string(firmware.DeviceType)),
+		utils.String("version", firmware.Version),
+		utils.Int64("size", firmware.Size),
+		utils.String("sha256", firmware.SHA256))
+	return firmware, nil
+}
+
+// GetFirmware 获取固件
+func (s *firmwareService) GetFirmware(ctx context.Context, id uint64) (*models.Firmware, error) {
+	firmware, err := s.firmwareRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if firmware == nil {
+		return nil, ErrFirmwareNotFound
+	}
+	return firmware, nil
+}
+
+// ListFirmware 获取固件列表
+func (s *firmwareService) ListFirmware(ctx context.Context, deviceType models.DeviceType) ([]models.Firmware, error) {
+	return s.firmwareRepo.List(ctx, deviceType)
+}
+
+// DownloadURL 生成签名下载链接，签名绑定固件、设备和过期时间
+func (s *firmwareService) DownloadURL(firmware *models.Firmware, deviceID string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set("device_id", deviceID)
+	query.Set("expires", fmt.Sprintf("%d", expires))
+	query.Set("signature", s.sign(firmware.ID, deviceID, expires))
+	return fmt.Sprintf("%s/api/v1/firmware/%d/download?%s", strings.TrimRight(s.config.PublicBaseURL, "/"), firmware.ID, query.Encode())
+}
+
+// VerifyDownload 校验签名和有效期
+func (s *firmwareService) VerifyDownload(ctx context.Context, id uint64, deviceID string, expires int64, signature string) (*models.Firmware, error) {
+	if s.config.URLSecret == "" || deviceID == "" || time.Now().Unix() > expires {
+		return nil, ErrInvalidDownloadLink
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(id, deviceID, expires))) {
+		return nil, ErrInvalidDownloadLink
+	}
+	return s.GetFirmware(ctx, id)
+}
+
+// sign 计算下载链接签名 HMAC-SHA256(id:device_id:expires)
+func (s *firmwareService) sign(id uint64, deviceID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.URLSecret))
+	fmt.Fprintf(mac, "%d:%s:%d", id, deviceID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
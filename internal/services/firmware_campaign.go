@@ -0,0 +1,705 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 升级活动巡检参数
+const firmwareCheckTimeout = 30 * time.Second
+
+var (
+	// ErrCampaignNotFound 升级活动不存在
+	ErrCampaignNotFound = errors.New("升级活动不存在")
+	// ErrInvalidCampaign 升级活动参数无效
+	ErrInvalidCampaign = errors.New("升级活动无效")
+	// ErrCampaignState 升级活动当前状态不允许该操作
+	ErrCampaignState = errors.New("升级活动状态不允许该操作")
+	// ErrOTAChannelUnavailable MQTT升级通道不可用
+	ErrOTAChannelUnavailable = errors.New("MQTT升级通道不可用")
+)
+
+// FirmwareCampaignService 固件升级活动服务接口
+// 升级通知以保留消息发布到 air-quality/{type}/{id}/ota，设备在 ota/progress 上报进度
+type FirmwareCampaignService interface {
+	// SetPublisher 设置MQTT发布器（MQTT服务器启动后注入）
+	SetPublisher(publisher RetainedPublisher)
+	// CreateCampaign 创建升级活动并按阶段百分比划分目标设备
+	CreateCampaign(ctx context.Context, req *models.FirmwareCampaignRequest, userID *uint64) (*models.FirmwareCampaign, error)
+	// StartCampaign 开始或恢复升级活动（草稿、暂停、熔断状态均可）
+	StartCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error)
+	// PauseCampaign 暂停升级活动并撤回尚未开始下载的升级通知
+	PauseCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error)
+	GetCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error)
+	ListCampaigns(ctx context.Context, status string) ([]models.FirmwareCampaign, error)
+	ListUpdates(ctx context.Context, id uint64, req *models.FirmwareUpdateListRequest) (*models.FirmwareUpdateListResponse, error)
+	// HandleProgress 处理设备上报的升级进度
+	HandleProgress(ctx context.Context, deviceID string, payload []byte) error
+	Start()
+	Stop()
+}
+
+// firmwareCampaignService 固件升级活动服务实现
+type firmwareCampaignService struct {
+	config          config.FirmwareConfig
+	campaignRepo    repositories.FirmwareCampaignRepository
+	updateRepo      repositories.FirmwareUpdateRepository
+	deviceRepo      repositories.DeviceRepository
+	runtimeRepo     repositories.DeviceRuntimeStatusRepository
+	firmwareService FirmwareService
+	logger          utils.Logger
+
+	// evalMu 串行化活动状态推进，避免并发上报重复推进阶段
+	evalMu sync.Mutex
+
+	mu        sync.Mutex
+	publisher RetainedPublisher
+	started   bool
+	closed    bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewFirmwareCampaignService 创建固件升级活动服务
+func NewFirmwareCampaignService(
+	cfg config.FirmwareConfig,
+	campaignRepo repositories.FirmwareCampaignRepository,
+	updateRepo repositories.FirmwareUpdateRepository,
+	deviceRepo repositories.DeviceRepository,
+	runtimeRepo repositories.DeviceRuntimeStatusRepository,
+	firmwareService FirmwareService,
+	logger utils.Logger,
+) FirmwareCampaignService {
+	if cfg.UpdateTimeout <= 0 {
+		cfg.UpdateTimeout = 86400
+	}
+	if cfg.FailureThreshold <= 0 || cfg.FailureThreshold > 1 {
+		cfg.FailureThreshold = 0.2
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 1
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 60
+	}
+	return &firmwareCampaignService{
+		config:          cfg,
+		campaignRepo:    campaignRepo,
+		updateRepo:      updateRepo,
+		deviceRepo:      deviceRepo,
+		runtimeRepo:     runtimeRepo,
+		firmwareService: firmwareService,
+		logger:          logger,
+		done:            make(chan struct{}),
+	}
+}
+
+// SetPublisher 设置MQTT发布器
+func (s *firmwareCampaignService) SetPublisher(publisher RetainedPublisher) {
+	s.mu.Lock()
+	s.publisher = publisher
+	s.mu.Unlock()
+}
+
+// getPublisher 获取MQTT发布器
+func (s *firmwareCampaignService) getPublisher() RetainedPublisher {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.publisher
+}
+
+// CreateCampaign 创建升级活动（草稿状态）
+func (s *firmwareCampaignService) CreateCampaign(ctx context.Context, req *models.FirmwareCampaignRequest, userID *uint64) (*models.FirmwareCampaign, error) {
+	firmware, err := s.firmwareService.GetFirmware(ctx, req.FirmwareID)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := req.Stages
+	if len(stages) == 0 {
+		stages = []int{100}
+	}
+	if err := models.ValidateStages(stages); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCampaign, err)
+	}
+
+	deviceIDs, err := s.resolveTargets(ctx, firmware.DeviceType, req.DeviceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := s.config.FailureThreshold
+	if req.FailureThreshold != nil {
+		threshold = *req.FailureThreshold
+	}
+	stagesJSON, _ := json.Marshal(stages)
+	campaign := &models.FirmwareCampaign{
+		Name:             req.Name,
+		FirmwareID:       firmware.ID,
+		DeviceType:       firmware.DeviceType,
+		Stages:           string(stagesJSON),
+		Status:           models.FirmwareCampaignStatusDraft,
+		FailureThreshold: threshold,
+		TotalDevices:     len(deviceIDs),
+		CreatedBy:        userID,
+	}
+	if len(req.DeviceIDs) > 0 {
+		targets, _ := json.Marshal(deviceIDs)
+		value := string(targets)
+		campaign.TargetDevices = &value
+	}
+
+	updates := assignStages(deviceIDs, stages)
+	if err := s.campaignRepo.Create(ctx, campaign, updates); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("固件升级活动已创建",
+		utils.Int64("campaign_id", int64(campaign.ID)),
+		utils.String("version", firmware.Version),
+		utils.Int("devices", len(deviceIDs)),
+		utils.String("stages", campaign.Stages))
+	return campaign, nil
+}
+
+// resolveTargets 确定目标设备：指定列表时逐个校验类型，否则取该类型全部设备
+func (s *firmwareCampaignService) resolveTargets(ctx context.Context, deviceType models.DeviceType, deviceIDs []string) ([]string, error) {
+	var targets []string
+	if len(deviceIDs) == 0 {
+		devices, err := s.deviceRepo.ListByType(ctx, deviceType)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range devices {
+			targets = append(targets, device.ID)
+		}
+	} else {
+		seen := make(map[string]bool, len(deviceIDs))
+		for _, id := range deviceIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			device, err := s.deviceRepo.GetByDeviceID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 设备 %s 不存在", ErrInvalidCampaign, id)
+			}
+			if device.Type != deviceType {
+				return nil, fmt.Errorf("%w: 设备 %s 类型为 %s，与固件类型 %s 不符", ErrInvalidCampaign, id, device.Type, deviceType)
+			}
+			targets = append(targets, id)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: 没有符合条件的目标设备", ErrInvalidCampaign)
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// assignStages 按累计百分比将设备依次划入各阶段（向上取整，保证每个非空阶段至少一台）
+func assignStages(deviceIDs []string, stages []int) []models.FirmwareUpdate {
+	updates := make([]models.FirmwareUpdate, 0, len(deviceIDs))
+	total := len(deviceIDs)
+	stage := 0
+	for i, deviceID := range deviceIDs {
+		for stage < len(stages)-1 && i >= (total*stages[stage]+99)/100 {
+			stage++
+		}
+		updates = append(updates, models.FirmwareUpdate{
+			DeviceID: deviceID,
+			Stage:    stage,
+			Status:   models.FirmwareUpdateStatusPending,
+		})
+	}
+	return updates
+}
+
+// StartCampaign 开始或恢复升级活动并推送当前阶段的升级通知
+func (s *firmwareCampaignService) StartCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error) {
+	if s.getPublisher() == nil {
+		return nil, ErrOTAChannelUnavailable
+	}
+
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+
+	campaign, err := s.loadCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      models.FirmwareCampaignStatusRunning,
+		"halt_reason": nil,
+		"updated_at":  now,
+	}
+	if campaign.StartedAt == nil {
+		updates["started_at"] = now
+	}
+	ok, err := s.campaignRepo.Transition(ctx, id, []models.FirmwareCampaignStatus{
+		models.FirmwareCampaignStatusDraft, models.FirmwareCampaignStatusPaused, models.FirmwareCampaignStatusHalted,
+	}, updates)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCampaignState
+	}
+
+	s.logger.Info("固件升级活动已开始",
+		utils.Int64("campaign_id", int64(id)),
+		utils.String("previous_status", string(campaign.Status)),
+		utils.Int("stage", campaign.CurrentStage))
+
+	if campaign, err = s.loadCampaign(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.advance(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return s.GetCampaign(ctx, id)
+}
+
+// PauseCampaign 人工暂停升级活动
+func (s *firmwareCampaignService) PauseCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error) {
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+
+	campaign, err := s.loadCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.campaignRepo.Transition(ctx, id, []models.FirmwareCampaignStatus{models.FirmwareCampaignStatusRunning}, map[string]interface{}{
+		"status":     models.FirmwareCampaignStatusPaused,
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCampaignState
+	}
+
+	s.logger.Info("固件升级活动已暂停", utils.Int64("campaign_id", int64(id)))
+	s.retract(ctx, campaign)
+	return s.GetCampaign(ctx, id)
+}
+
+// GetCampaign 获取升级活动及进度统计
+func (s *firmwareCampaignService) GetCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error) {
+	campaign, err := s.loadCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.updateRepo.CountByStatus(ctx, id, -1)
+	if err != nil {
+		return nil, err
+	}
+	campaign.Stats = buildCampaignStats(counts)
+	return campaign, nil
+}
+
+// ListCampaigns 获取升级活动列表
+func (s *firmwareCampaignService) ListCampaigns(ctx context.Context, status string) ([]models.FirmwareCampaign, error) {
+	return s.campaignRepo.List(ctx, status)
+}
+
+// ListUpdates 分页获取升级活动的设备升级记录
+func (s *firmwareCampaignService) ListUpdates(ctx context.Context, id uint64, req *models.FirmwareUpdateListRequest) (*models.FirmwareUpdateListResponse, error) {
+	if _, err := s.loadCampaign(ctx, id); err != nil {
+		return nil, err
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	updates, total, err := s.updateRepo.ListByCampaign(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FirmwareUpdateListResponse{
+		Updates:  updates,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}, nil
+}
+
+// HandleProgress 记录设备升级进度，结束时清除升级通知并推进活动
+func (s *firmwareCampaignService) HandleProgress(ctx context.Context, deviceID string, payload []byte) error {
+	var report models.FirmwareProgressReport
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return fmt.Errorf("解析升级进度失败: %w", err)
+	}
+	if report.CampaignID == 0 || !report.Status.IsReportable() {
+		return fmt.Errorf("升级进度缺少campaign_id或状态无效: %s", report.Status)
+	}
+
+	campaign, err := s.loadCampaign(ctx, report.CampaignID)
+	if err != nil {
+		return err
+	}
+	update, err := s.updateRepo.GetByCampaignDevice(ctx, campaign.ID, deviceID)
+	if err != nil {
+		return err
+	}
+	if update == nil {
+		return fmt.Errorf("设备 %s 不在升级活动 %d 中", deviceID, campaign.ID)
+	}
+	if update.Status.IsFinal() {
+		return nil
+	}
+
+	firmware, err := s.firmwareService.GetFirmware(ctx, campaign.FirmwareID)
+	if err != nil {
+		return err
+	}
+	status := report.Status
+	errorMessage := report.Error
+	if status == models.FirmwareUpdateStatusSucceeded && report.Version != "" && report.Version != firmware.Version {
+		status = models.FirmwareUpdateStatusFailed
+		errorMessage = fmt.Sprintf("升级后版本为 %s，期望 %s", report.Version, firmware.Version)
+	}
+
+	now := time.Now()
+	progress := min(max(report.Progress, 0), 100)
+	if status == models.FirmwareUpdateStatusSucceeded {
+		progress = 100
+	}
+	updates := map[string]interface{}{
+		"status":     status,
+		"progress":   progress,
+		"updated_at": now,
+	}
+	if status.IsFinal() {
+		updates["completed_at"] = now
+	}
+	if status == models.FirmwareUpdateStatusFailed {
+		if errorMessage == "" {
+			errorMessage = "设备上报升级失败"
+		}
+		updates["error_message"] = errorMessage
+	}
+	ok, err := s.updateRepo.Transition(ctx, update.ID, []models.FirmwareUpdateStatus{
+		models.FirmwareUpdateStatusPending, models.FirmwareUpdateStatusNotified,
+		models.FirmwareUpdateStatusDownloading, models.FirmwareUpdateStatusInstalling,
+	}, updates)
+	if err != nil || !ok || !status.IsFinal() {
+		return err
+	}
+
+	s.logger.Info("设备固件升级结束",
+		utils.String("device_id", deviceID),
+		utils.Int64("campaign_id", int64(campaign.ID)),
+		utils.String("status", string(status)),
+		utils.String("version", firmware.Version))
+	s.clearNotification(campaign, deviceID)
+
+	if status == models.FirmwareUpdateStatusSucceeded {
+		runtime := &models.DeviceRuntimeStatus{DeviceID: deviceID, FirmwareVersion: firmware.Version}
+		if err := s.runtimeRepo.Upsert(ctx, runtime, "firmware_version"); err != nil {
+			s.logger.Error("更新设备固件版本失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+		}
+	}
+
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+	return s.evaluateByID(ctx, campaign.ID)
+}
+
+// evaluateByID 重新加载活动并推进（调用方持有evalMu）
+func (s *firmwareCampaignService) evaluateByID(ctx context.Context, id uint64) error {
+	campaign, err := s.loadCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	if campaign.Status != models.FirmwareCampaignStatusRunning {
+		return nil
+	}
+	return s.advance(ctx, campaign)
+}
+
+// advance 推送当前阶段的升级通知，检查失败率，当前阶段全部结束后进入下一阶段（调用方持有evalMu）
+func (s *firmwareCampaignService) advance(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	stages := campaign.StageList()
+	for {
+		if err := s.notifyPending(ctx, campaign); err != nil {
+			return err
+		}
+
+		counts, err := s.updateRepo.CountByStatus(ctx, campaign.ID, campaign.CurrentStage)
+		if err != nil {
+			return err
+		}
+		stats := buildCampaignStats(counts)
+		if finished := stats.Succeeded + stats.Failed; finished >= int64(s.config.MinSamples) && stats.FailureRate >= campaign.FailureThreshold {
+			return s.halt(ctx, campaign, stats)
+		}
+		if stats.Pending+stats.InProgress > 0 {
+			return nil
+		}
+
+		now := time.Now()
+		if campaign.CurrentStage >= len(stages)-1 {
+			if _, err := s.campaignRepo.Transition(ctx, campaign.ID, []models.FirmwareCampaignStatus{models.FirmwareCampaignStatusRunning}, map[string]interface{}{
+				"status":       models.FirmwareCampaignStatusCompleted,
+				"completed_at": now,
+				"updated_at":   now,
+			}); err != nil {
+				return err
+			}
+			s.logger.Info("固件升级活动已完成",
+				utils.Int64("campaign_id", int64(campaign.ID)),
+				utils.Int64("succeeded", stats.Succeeded),
+				utils.Int64("failed", stats.Failed))
+			return nil
+		}
+
+		campaign.CurrentStage++
+		if err := s.campaignRepo.Update(ctx, campaign.ID, map[string]interface{}{
+			"current_stage": campaign.CurrentStage,
+			"updated_at":    now,
+		}); err != nil {
+			return err
+		}
+		s.logger.Info("固件升级活动进入下一阶段",
+			utils.Int64("campaign_id", int64(campaign.ID)),
+			utils.Int("stage", campaign.CurrentStage),
+			utils.Int("percent", stages[campaign.CurrentStage]))
+	}
+}
+
+// halt 失败率达到阈值时熔断活动并撤回未开始的升级通知
+func (s *firmwareCampaignService) halt(ctx context.Context, campaign *models.FirmwareCampaign, stats *models.FirmwareCampaignStats) error {
+	reason := fmt.Sprintf("失败率 %.1f%% 达到阈值 %.1f%%（失败 %d，成功 %d）",
+		stats.FailureRate*100, campaign.FailureThreshold*100, stats.Failed, stats.Succeeded)
+	ok, err := s.campaignRepo.Transition(ctx, campaign.ID, []models.FirmwareCampaignStatus{models.FirmwareCampaignStatusRunning}, map[string]interface{}{
+		"status":      models.FirmwareCampaignStatusHalted,
+		"halt_reason": reason,
+		"updated_at":  time.Now(),
+	})
+	if err != nil || !ok {
+		return err
+	}
+
+	s.logger.Warn("固件升级活动失败率过高，已自动暂停",
+		utils.Int64("campaign_id", int64(campaign.ID)),
+		utils.String("reason", reason))
+	s.retract(ctx, campaign)
+	return nil
+}
+
+// notifyPending 推送当前及之前阶段中尚未通知的设备
+func (s *firmwareCampaignService) notifyPending(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	pending, err := s.updateRepo.ListByStatus(ctx, campaign.ID, campaign.CurrentStage, models.FirmwareUpdateStatusPending)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	publisher := s.getPublisher()
+	if publisher == nil {
+		s.logger.Warn("MQTT发布器未就绪，升级通知暂不推送", utils.Int64("campaign_id", int64(campaign.ID)))
+		return nil
+	}
+	firmware, err := s.firmwareService.GetFirmware(ctx, campaign.FirmwareID)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(s.config.UpdateTimeout) * time.Second
+	for _, update := range pending {
+		now := time.Now()
+		expiresAt := now.Add(timeout)
+		message := &models.FirmwareUpdateMessage{
+			CampaignID: campaign.ID,
+			Version:    firmware.Version,
+			URL:        s.firmwareService.DownloadURL(firmware, update.DeviceID, expiresAt),
+			SHA256:     firmware.SHA256,
+			Size:       firmware.Size,
+			ExpiresAt:  expiresAt.Unix(),
+			Timestamp:  now.Unix(),
+		}
+		if err := publisher.PublishRetained(otaTopic(campaign.DeviceType, update.DeviceID), message); err != nil {
+			s.logger.Error("推送升级通知失败", utils.String("device_id", update.DeviceID), utils.ErrorField(err))
+			continue
+		}
+		if _, err := s.updateRepo.Transition(ctx, update.ID, []models.FirmwareUpdateStatus{models.FirmwareUpdateStatusPending}, map[string]interface{}{
+			"status":      models.FirmwareUpdateStatusNotified,
+			"notified_at": now,
+			"updated_at":  now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Info("升级通知已推送",
+		utils.Int64("campaign_id", int64(campaign.ID)),
+		utils.Int("stage", campaign.CurrentStage),
+		utils.Int("devices", len(pending)))
+	return nil
+}
+
+// retract 撤回已推送但设备尚未开始下载的升级通知，恢复为待推送
+func (s *firmwareCampaignService) retract(ctx context.Context, campaign *models.FirmwareCampaign) {
+	notified, err := s.updateRepo.ListByStatus(ctx, campaign.ID, campaign.CurrentStage, models.FirmwareUpdateStatusNotified)
+	if err != nil {
+		return
+	}
+	for _, update := range notified {
+		ok, err := s.updateRepo.Transition(ctx, update.ID, []models.FirmwareUpdateStatus{models.FirmwareUpdateStatusNotified}, map[string]interface{}{
+			"status":      models.FirmwareUpdateStatusPending,
+			"notified_at": nil,
+			"updated_at":  time.Now(),
+		})
+		if err == nil && ok {
+			s.clearNotification(campaign, update.DeviceID)
+		}
+	}
+}
+
+// clearNotification 发布空保留消息清除设备的升级通知
+func (s *firmwareCampaignService) clearNotification(campaign *models.FirmwareCampaign, deviceID string) {
+	publisher := s.getPublisher()
+	if publisher == nil {
+		return
+	}
+	if err := publisher.PublishRetained(otaTopic(campaign.DeviceType, deviceID), []byte{}); err != nil {
+		s.logger.Error("清除升级通知失败", utils.String("device_id", deviceID), utils.ErrorField(err))
+	}
+}
+
+// loadCampaign 获取升级活动，不存在时返回ErrCampaignNotFound
+func (s *firmwareCampaignService) loadCampaign(ctx context.Context, id uint64) (*models.FirmwareCampaign, error) {
+	campaign, err := s.campaignRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// Start 启动升级超时巡检
+func (s *firmwareCampaignService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	interval := time.Duration(s.config.CheckInterval) * time.Second
+	s.wg.Add(1)
+	go s.checkLoop(interval)
+	s.logger.Info("固件升级巡检已启动", utils.Duration("check_interval", interval))
+}
+
+// Stop 停止升级超时巡检
+func (s *firmwareCampaignService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("固件升级巡检已停止")
+}
+
+// checkLoop 定期处理超时设备并推进进行中的活动
+func (s *firmwareCampaignService) checkLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// check 将超时未结束的升级标记为失败，并补推因MQTT不可用而遗留的通知
+func (s *firmwareCampaignService) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), firmwareCheckTimeout)
+	defer cancel()
+
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+
+	timedOut, err := s.updateRepo.ListTimedOut(ctx, time.Now().Add(-time.Duration(s.config.UpdateTimeout)*time.Second))
+	if err != nil {
+		return
+	}
+	for _, update := range timedOut {
+		now := time.Now()
+		ok, err := s.updateRepo.Transition(ctx, update.ID, models.FirmwareUpdateInProgressStatuses, map[string]interface{}{
+			"status":        models.FirmwareUpdateStatusFailed,
+			"error_message": "升级超时",
+			"completed_at":  now,
+			"updated_at":    now,
+		})
+		if err != nil || !ok {
+			continue
+		}
+		s.logger.Warn("设备固件升级超时",
+			utils.String("device_id", update.DeviceID),
+			utils.Int64("campaign_id", int64(update.CampaignID)))
+		if campaign, err := s.loadCampaign(ctx, update.CampaignID); err == nil {
+			s.clearNotification(campaign, update.DeviceID)
+		}
+	}
+
+	campaigns, err := s.campaignRepo.List(ctx, string(models.FirmwareCampaignStatusRunning))
+	if err != nil {
+		return
+	}
+	for i := range campaigns {
+		if err := s.advance(ctx, &campaigns[i]); err != nil {
+			s.logger.Error("推进固件升级活动失败", utils.Int64("campaign_id", int64(campaigns[i].ID)), utils.ErrorField(err))
+		}
+	}
+}
+
+// buildCampaignStats 根据状态计数计算进度和失败率
+func buildCampaignStats(counts map[models.FirmwareUpdateStatus]int64) *models.FirmwareCampaignStats {
+	stats := &models.FirmwareCampaignStats{
+		Pending:   counts[models.FirmwareUpdateStatusPending],
+		Succeeded: counts[models.FirmwareUpdateStatusSucceeded],
+		Failed:    counts[models.FirmwareUpdateStatusFailed],
+	}
+	for _, status := range models.FirmwareUpdateInProgressStatuses {
+		stats.InProgress += counts[status]
+	}
+	stats.Total = stats.Pending + stats.InProgress + stats.Succeeded + stats.Failed
+	if finished := stats.Succeeded + stats.Failed; finished > 0 {
+		stats.FailureRate = float64(stats.Failed) / float64(finished)
+	}
+	return stats
+}
+
+// otaTopic 设备升级通知主题
+func otaTopic(deviceType models.DeviceType, deviceID string) string {
+	return fmt.Sprintf("air-quality/%s/%s/ota", deviceType, deviceID)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFirmwareService_UploadFirmwareConcurrent 测试并发上传同一版本时只登记一份，登记失败的上传不删除已登记固件的文件
+func TestFirmwareService_UploadFirmwareConcurrent(t *testing.T) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	dir := t.TempDir()
+	service := NewFirmwareService(config.FirmwareConfig{Dir: dir}, repositories.NewFirmwareRepository(db, logger), logger)
+	req := &models.FirmwareUploadRequest{DeviceType: models.DeviceTypeFormaldehyde, Version: "1.0.0"}
+
+	const uploads = 8
+	var wg sync.WaitGroup
+	results := make([]*models.Firmware, uploads)
+	errs := make([]error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = service.UploadFirmware(context.Background(), req, "hcho.bin",
+				strings.NewReader(fmt.Sprintf("firmware-%d", i)), nil)
+		}(i)
+	}
+	wg.Wait()
+
+	var stored *models.Firmware
+	for i, err := range errs {
+		if err == nil {
+			require.Nil(t, stored, "only one upload may succeed")
+			stored = results[i]
+			continue
+		}
+		assert.ErrorIs(t, err, ErrFirmwareExists)
+	}
+	require.NotNil(t, stored)
+
+	content, err := os.ReadFile(stored.FilePath)
+	require.NoError(t, err)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, stored.SHA256, hex.EncodeToString(sum[:]))
+	files, err := filepath.Glob(filepath.Join(dir, string(models.DeviceTypeFormaldehyde), "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{stored.FilePath}, files)
+}
+
+// campaignFixture 升级活动测试环境：设备1、设备2及已上传的甲醛传感器固件1.4.0，失败率达到50%（至少2台）时熔断
+type campaignFixture struct {
+	firmware  FirmwareService
+	campaigns FirmwareCampaignService
+	runtime   repositories.DeviceRuntimeStatusRepository
+	publisher *retainedRecorder
+	uploaded  *models.Firmware
+}
+
+// newCampaignFixture 创建设备、上传固件并创建升级活动服务（尚未设置MQTT发布器）
+func newCampaignFixture(t *testing.T) *campaignFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+	cfg := config.FirmwareConfig{
+		Dir:              t.TempDir(),
+		MaxSize:          1,
+		PublicBaseURL:    "http://firmware.example.com",
+		URLSecret:        "test-secret",
+		UpdateTimeout:    3600,
+		FailureThreshold: 0.5,
+		MinSamples:       2,
+	}
+	f := &campaignFixture{
+		firmware:  NewFirmwareService(cfg, repositories.NewFirmwareRepository(db, logger), logger),
+		runtime:   repositories.NewDeviceRuntimeStatusRepository(db, logger),
+		publisher: &retainedRecorder{},
+	}
+	f.campaigns = NewFirmwareCampaignService(cfg,
+		repositories.NewFirmwareCampaignRepository(db, logger), repositories.NewFirmwareUpdateRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger), f.runtime, f.firmware, logger)
+	var err error
+	f.uploaded, err = f.firmware.UploadFirmware(context.Background(),
+		&models.FirmwareUploadRequest{DeviceType: models.DeviceTypeFormaldehyde, Version: "1.4.0"},
+		"hcho.bin", strings.NewReader("firmware-binary-1.4.0"), nil)
+	require.NoError(t, err)
+	return f
+}
+
+// notifications 返回已推送的升级通知（不含撤回通知的空消息）
+func (f *campaignFixture) notifications() []*models.FirmwareUpdateMessage {
+	f.publisher.mu.Lock()
+	defer f.publisher.mu.Unlock()
+	var messages []*models.FirmwareUpdateMessage
+	for _, payload := range f.publisher.payloads {
+		if msg, ok := payload.(*models.FirmwareUpdateMessage); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// progress 以设备身份上报升级进度
+func (f *campaignFixture) progress(t *testing.T, deviceID string, campaignID uint64, body string) {
+	require.NoError(t, f.campaigns.HandleProgress(context.Background(), deviceID,
+		[]byte(`{"campaign_id":`+strconv.FormatUint(campaignID, 10)+`,`+body+`}`)))
+}
+
+// TestFirmwareService_VerifyDownload 测试签名下载链接只对签发的设备和有效期内有效，未配置签名密钥时拒绝所有链接
+func TestFirmwareService_VerifyDownload(t *testing.T) {
+	f := newCampaignFixture(t)
+	logger := testutil.NewLogger(t)
+	unsigned := NewFirmwareService(config.FirmwareConfig{}, nil, logger)
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		service   FirmwareService
+		expiresAt time.Time
+		deviceID  string
+		wantErr   bool
+	}{
+		{"signed link", f.firmware, expiresAt, testutil.DeviceID1, false},
+		{"other device", f.firmware, expiresAt, testutil.DeviceID2, true},
+		{"expired link", f.firmware, time.Now().Add(-time.Minute), testutil.DeviceID1, true},
+		{"no signing key", unsigned, expiresAt, testutil.DeviceID1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := url.Parse(tt.service.DownloadURL(f.uploaded, testutil.DeviceID1, tt.expiresAt))
+			require.NoError(t, err)
+			assert.Equal(t, testutil.DeviceID1, link.Query().Get("device_id"))
+			firmware, err := tt.service.VerifyDownload(context.Background(), f.uploaded.ID, tt.deviceID,
+				tt.expiresAt.Unix(), link.Query().Get("signature"))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidDownloadLink)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, f.uploaded.SHA256, firmware.SHA256)
+		})
+	}
+
+	// 默认签名密钥由JWT密钥派生，不与之相同
+	jwtCfg := config.JWTConfig{Secret: "test-secret"}
+	assert.NotEqual(t, jwtCfg.Secret, jwtCfg.DeriveSecret("firmware-download-url"))
+	assert.Equal(t, jwtCfg.DeriveSecret("firmware-download-url"), jwtCfg.DeriveSecret("firmware-download-url"))
+}
+
+// TestFirmwareCampaignService_StagedRollout 测试分阶段推送、进度上报推进阶段及失败率熔断
+func TestFirmwareCampaignService_StagedRollout(t *testing.T) {
+	f := newCampaignFixture(t)
+	ctx := context.Background()
+
+	// 阶段百分比必须以100结束
+	_, err := f.campaigns.CreateCampaign(ctx, &models.FirmwareCampaignRequest{Name: "bad", FirmwareID: f.uploaded.ID, Stages: []int{50}}, nil)
+	assert.ErrorIs(t, err, ErrInvalidCampaign)
+
+	// 两台设备按[50,100]分两个阶段
+	campaign, err := f.campaigns.CreateCampaign(ctx, &models.FirmwareCampaignRequest{
+		Name: "hcho 1.4.0", FirmwareID: f.uploaded.ID, Stages: []int{50, 100}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, campaign.TotalDevices)
+	assert.Equal(t, models.FirmwareCampaignStatusDraft, campaign.Status)
+
+	// MQTT发布器未就绪时无法开始
+	_, err = f.campaigns.StartCampaign(ctx, campaign.ID)
+	assert.ErrorIs(t, err, ErrOTAChannelUnavailable)
+	f.campaigns.SetPublisher(f.publisher)
+
+	// 各步骤依次执行：第一阶段只推送给第一台设备，第一台升级成功后推送给第二台，第二台失败后熔断
+	steps := []struct {
+		name    string
+		apply   func(t *testing.T)
+		devices []string // 累计收到通知的设备
+		status  models.FirmwareCampaignStatus
+	}{
+		{"start", func(t *testing.T) {
+			_, err := f.campaigns.StartCampaign(ctx, campaign.ID)
+			require.NoError(t, err)
+		}, []string{testutil.DeviceID1}, models.FirmwareCampaignStatusRunning},
+		{"first device downloading", func(t *testing.T) {
+			f.progress(t, testutil.DeviceID1, campaign.ID, `"status":"downloading","progress":30`)
+		}, []string{testutil.DeviceID1}, models.FirmwareCampaignStatusRunning},
+		{"first device succeeded", func(t *testing.T) {
+			f.progress(t, testutil.DeviceID1, campaign.ID, `"status":"succeeded","version":"1.4.0"`)
+		}, []string{testutil.DeviceID1, testutil.DeviceID2}, models.FirmwareCampaignStatusRunning},
+		{"second device failed", func(t *testing.T) {
+			f.progress(t, testutil.DeviceID2, campaign.ID, `"status":"failed","error":"校验失败"`)
+		}, []string{testutil.DeviceID1, testutil.DeviceID2}, models.FirmwareCampaignStatusHalted},
+	}
+	for _, step := range steps {
+		step.apply(t)
+		devices := []string{}
+		for _, msg := range f.notifications() {
+			assert.Equal(t, campaign.ID, msg.CampaignID, step.name)
+			assert.Equal(t, "1.4.0", msg.Version, step.name)
+			assert.Equal(t, f.uploaded.SHA256, msg.SHA256, step.name)
+			link, err := url.Parse(msg.URL)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(msg.URL, "http://firmware.example.com/api/v1/firmware/"), step.name)
+			devices = append(devices, link.Query().Get("device_id"))
+		}
+		assert.Equal(t, step.devices, devices, step.name)
+		got, err := f.campaigns.GetCampaign(ctx, campaign.ID)
+		require.NoError(t, err)
+		assert.Equal(t, step.status, got.Status, step.name)
+	}
+
+	status, err := f.runtime.GetByDeviceID(ctx, testutil.DeviceID1)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, "1.4.0", status.FirmwareVersion)
+
+	got, err := f.campaigns.GetCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.CurrentStage)
+	require.NotNil(t, got.HaltReason)
+	require.NotNil(t, got.Stats)
+	assert.Equal(t, int64(1), got.Stats.Succeeded)
+	assert.Equal(t, int64(1), got.Stats.Failed)
+	assert.Equal(t, 0.5, got.Stats.FailureRate)
+}
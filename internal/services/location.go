@@ -0,0 +1,280 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrLocationNotFound 位置不存在
+	ErrLocationNotFound = errors.New("位置不存在")
+	// ErrInvalidLocation 位置信息或层级关系无效
+	ErrInvalidLocation = errors.New("位置无效")
+	// ErrLocationInUse 位置下仍有子位置
+	ErrLocationInUse = errors.New("位置下仍有子位置，不能删除")
+)
+
+// LocationService 位置层级服务接口
+type LocationService interface {
+	CreateLocation(ctx context.Context, req *models.LocationRequest) (*models.LocationNode, error)
+	GetLocation(ctx context.Context, id uint64) (*models.LocationNode, error)
+	ListLocations(ctx context.Context) ([]models.LocationNode, error)
+	// GetTree 获取位置树（顶层为站点，子节点嵌套在children中）
+	GetTree(ctx context.Context) ([]models.LocationNode, error)
+	// UpdateLocation 更新位置，修改parent_id即移动整棵子树
+	UpdateLocation(ctx context.Context, id uint64, req *models.LocationRequest) (*models.LocationNode, error)
+	DeleteLocation(ctx context.Context, id uint64) error
+	// AssignDevices 将设备分配到位置（设备原有位置被替换）
+	AssignDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error)
+	// UnassignDevices 解除设备与该位置的关联（不在该位置的设备忽略）
+	UnassignDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error)
+}
+
+// locationService 位置层级服务实现
+type locationService struct {
+	locationRepo repositories.LocationRepository
+	deviceRepo   repositories.DeviceRepository
+	scope        DeviceScopeService
+	logger       utils.Logger
+}
+
+// NewLocationService 创建位置层级服务（scope为空时不刷新告警规则的设备归属缓存）
+func NewLocationService(locationRepo repositories.LocationRepository, deviceRepo repositories.DeviceRepository, scope DeviceScopeService, logger utils.Logger) LocationService {
+	return &locationService{
+		locationRepo: locationRepo,
+		deviceRepo:   deviceRepo,
+		scope:        scope,
+		logger:       logger,
+	}
+}
+
+// CreateLocation 校验父子层级后创建位置
+func (s *locationService) CreateLocation(ctx context.Context, req *models.LocationRequest) (*models.LocationNode, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 名称不能为空", ErrInvalidLocation)
+	}
+	parent, err := s.parent(ctx, req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Type.ValidateParent(parent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLocation, err)
+	}
+
+	node := &models.LocationNode{
+		ParentID:    req.ParentID,
+		Name:        name,
+		Type:        req.Type,
+		Description: req.Description,
+	}
+	if err := s.locationRepo.Create(ctx, node, parent); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("位置创建成功",
+		utils.Int64("location_id", int64(node.ID)),
+		utils.String("type", string(node.Type)),
+		utils.String("path", node.Path))
+	return node, nil
+}
+
+// GetLocation 获取位置
+func (s *locationService) GetLocation(ctx context.Context, id uint64) (*models.LocationNode, error) {
+	node, err := s.locationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, ErrLocationNotFound
+	}
+	return node, nil
+}
+
+// ListLocations 获取全部位置（按路径排序）
+func (s *locationService) ListLocations(ctx context.Context) ([]models.LocationNode, error) {
+	return s.locationRepo.List(ctx)
+}
+
+// GetTree 按parent_id将平铺列表组装为树
+func (s *locationService) GetTree(ctx context.Context) ([]models.LocationNode, error) {
+	nodes, err := s.locationRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint64][]models.LocationNode)
+	var roots []models.LocationNode
+	for _, node := range nodes {
+		if node.ParentID == nil {
+			roots = append(roots, node)
+		} else {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+	var attach func(nodes []models.LocationNode) []models.LocationNode
+	attach = func(nodes []models.LocationNode) []models.LocationNode {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	if roots == nil {
+		roots = []models.LocationNode{}
+	}
+	return attach(roots), nil
+}
+
+// UpdateLocation 更新名称、类型和父节点，父节点不能是自身或自身的后代
+func (s *locationService) UpdateLocation(ctx context.Context, id uint64, req *models.LocationRequest) (*models.LocationNode, error) {
+	node, err := s.GetLocation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 名称不能为空", ErrInvalidLocation)
+	}
+	parent, err := s.parent(ctx, req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil && models.InSubtree(parent.Path, node.ID) {
+		return nil, fmt.Errorf("%w: 不能移动到自身或下级位置之下", ErrInvalidLocation)
+	}
+	if err := req.Type.ValidateParent(parent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLocation, err)
+	}
+	if req.Type != node.Type {
+		subtree, err := s.locationRepo.ListSubtree(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range subtree {
+			if child.ParentID != nil && *child.ParentID == node.ID && child.Type.Level() <= req.Type.Level() {
+				return nil, fmt.Errorf("%w: 子位置 %s 的层级不低于 %s", ErrInvalidLocation, child.Name, req.Type)
+			}
+		}
+	}
+
+	oldPath := node.Path
+	parentPath := "/"
+	if parent != nil {
+		parentPath = parent.Path
+	}
+	node.ParentID = req.ParentID
+	node.Name = name
+	node.Type = req.Type
+	node.Description = req.Description
+	node.Path = models.LocationPath(parentPath, node.ID)
+	if err := s.locationRepo.Update(ctx, node, oldPath); err != nil {
+		return nil, err
+	}
+	if node.Path != oldPath {
+		s.invalidate()
+		s.logger.Info("位置已移动",
+			utils.Int64("location_id", int64(node.ID)),
+			utils.String("from", oldPath),
+			utils.String("to", node.Path))
+	}
+	return node, nil
+}
+
+// DeleteLocation 删除没有子位置的节点，其下设备变为未分配
+func (s *locationService) DeleteLocation(ctx context.Context, id uint64) error {
+	if _, err := s.GetLocation(ctx, id); err != nil {
+		return err
+	}
+	children, err := s.locationRepo.CountChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrLocationInUse
+	}
+	if err := s.locationRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("位置已删除", utils.Int64("location_id", int64(id)))
+	return nil
+}
+
+// AssignDevices 校验设备存在后批量设置位置
+func (s *locationService) AssignDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error) {
+	if _, err := s.GetLocation(ctx, id); err != nil {
+		return 0, err
+	}
+	if err := requireDevices(ctx, s.deviceRepo, deviceIDs); err != nil {
+		return 0, err
+	}
+	updated, err := s.deviceRepo.SetLocation(ctx, deviceIDs, id)
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate()
+	s.logger.Info("设备已分配到位置", utils.Int64("location_id", int64(id)), utils.Int64("count", updated))
+	return updated, nil
+}
+
+// UnassignDevices 批量解除设备位置
+func (s *locationService) UnassignDevices(ctx context.Context, id uint64, deviceIDs []string) (int64, error) {
+	if _, err := s.GetLocation(ctx, id); err != nil {
+		return 0, err
+	}
+	updated, err := s.deviceRepo.UnsetLocation(ctx, id, deviceIDs)
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate()
+	return updated, nil
+}
+
+// parent 获取父节点，parentID为空表示顶层
+func (s *locationService) parent(ctx context.Context, parentID *uint64) (*models.LocationNode, error) {
+	if parentID == nil {
+		return nil, nil
+	}
+	parent, err := s.locationRepo.GetByID(ctx, *parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("%w: 上级位置 %d 不存在", ErrInvalidLocation, *parentID)
+	}
+	return parent, nil
+}
+
+// invalidate 分配关系变化后刷新设备归属缓存
+func (s *locationService) invalidate() {
+	if s.scope != nil {
+		s.scope.Invalidate()
+	}
+}
+
+// requireDevices 校验设备全部存在，否则返回缺失的设备ID
+func requireDevices(ctx context.Context, deviceRepo repositories.DeviceRepository, deviceIDs []string) error {
+	devices, err := deviceRepo.ListByIDs(ctx, deviceIDs)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		found[d.ID] = true
+	}
+	var missing []string
+	for _, id := range deviceIDs {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, strings.Join(missing, ", "))
+	}
+	return nil
+}
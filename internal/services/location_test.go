@@ -0,0 +1,265 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// locationFixture 总部（站点）→A栋→101与分部（站点）两棵位置树；
+// 设备1在101，设备2在A栋，设备3在分部，设备2、3属于同一分组
+type locationFixture struct {
+	locations LocationService
+	scope     DeviceScopeService
+	rules     AlertRuleService
+	evaluator AlertEvaluator
+
+	site, building, room, other *models.LocationNode
+	group                       *models.DeviceGroup
+}
+
+// newLocationFixture 创建位置树、分组和设备分配
+func newLocationFixture(t *testing.T) *locationFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	ctx := context.Background()
+	for _, id := range []string{testutil.DeviceID1, testutil.DeviceID2, testutil.DeviceID3} {
+		require.NoError(t, db.Create(&models.Device{ID: id, Name: "测试设备", Type: models.DeviceTypeFormaldehyde}).Error)
+	}
+
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	locationRepo := repositories.NewLocationRepository(db, logger)
+	groupRepo := repositories.NewDeviceGroupRepository(db, logger)
+	f := &locationFixture{scope: NewDeviceScopeService(deviceRepo, locationRepo, groupRepo, logger)}
+	f.locations = NewLocationService(locationRepo, deviceRepo, f.scope, logger)
+	f.rules = NewAlertRuleService(repositories.NewAlertRuleRepository(db, logger), logger)
+	f.evaluator = NewAlertEvaluator(f.rules, NewAlertService(repositories.NewAlertRepository(db, logger), nil, logger), f.scope, logger)
+
+	create := func(name string, locationType models.LocationType, parent *models.LocationNode) *models.LocationNode {
+		req := &models.LocationRequest{Name: name, Type: locationType}
+		if parent != nil {
+			req.ParentID = &parent.ID
+		}
+		node, err := f.locations.CreateLocation(ctx, req)
+		require.NoError(t, err)
+		return node
+	}
+	f.site = create("总部", models.LocationTypeSite, nil)
+	f.building = create("A栋", models.LocationTypeBuilding, f.site)
+	f.room = create("101", models.LocationTypeRoom, f.building)
+	f.other = create("分部", models.LocationTypeSite, nil)
+
+	for id, node := range map[string]*models.LocationNode{
+		testutil.DeviceID1: f.room,
+		testutil.DeviceID2: f.building,
+		testutil.DeviceID3: f.other,
+	} {
+		_, err := f.locations.AssignDevices(ctx, node.ID, []string{id})
+		require.NoError(t, err)
+	}
+
+	groups := NewDeviceGroupService(groupRepo, deviceRepo, f.scope, logger)
+	group, err := groups.CreateGroup(ctx, &models.DeviceGroupRequest{Name: "会议室"})
+	require.NoError(t, err)
+	require.NoError(t, groups.AddDevices(ctx, group.ID, []string{testutil.DeviceID2, testutil.DeviceID3}))
+	f.group = group
+	return f
+}
+
+// TestLocationService_CreateLocation 测试位置层级校验和物化路径
+func TestLocationService_CreateLocation(t *testing.T) {
+	f := newLocationFixture(t)
+	assert.Equal(t, fmt.Sprintf("/%d/%d/%d/", f.site.ID, f.building.ID, f.room.ID), f.room.Path)
+
+	missing := uint64(999)
+	tests := []struct {
+		name     string
+		parent   *uint64
+		nodeType models.LocationType
+		nodeName string
+		wantErr  error
+	}{
+		{"top level must be site", nil, models.LocationTypeRoom, "101", ErrInvalidLocation},
+		{"building under room", &f.room.ID, models.LocationTypeBuilding, "B栋", ErrInvalidLocation},
+		{"unknown type", &f.site.ID, "zone", "东区", ErrInvalidLocation},
+		{"missing parent", &missing, models.LocationTypeRoom, "102", ErrInvalidLocation},
+		{"blank name", &f.site.ID, models.LocationTypeBuilding, " ", ErrInvalidLocation},
+		{"room directly under site", &f.site.ID, models.LocationTypeRoom, "大厅", nil},
+		{"floor under building", &f.building.ID, models.LocationTypeFloor, "2F", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := f.locations.CreateLocation(context.Background(),
+				&models.LocationRequest{ParentID: tt.parent, Name: tt.nodeName, Type: tt.nodeType})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			parent, err := f.locations.GetLocation(context.Background(), *tt.parent)
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("%s%d/", parent.Path, node.ID), node.Path)
+		})
+	}
+}
+
+// TestLocationService_UpdateLocation 测试移动子树、禁止移动到自身子树下以及修改类型时的层级校验
+func TestLocationService_UpdateLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(f *locationFixture) (uint64, *models.LocationRequest)
+		wantErr error
+		path    func(f *locationFixture) string
+	}{
+		{
+			name: "move room to other site",
+			update: func(f *locationFixture) (uint64, *models.LocationRequest) {
+				return f.room.ID, &models.LocationRequest{Name: "101", Type: models.LocationTypeRoom, ParentID: &f.other.ID}
+			},
+			path: func(f *locationFixture) string { return fmt.Sprintf("/%d/%d/", f.other.ID, f.room.ID) },
+		},
+		{
+			name: "move site under own descendant",
+			update: func(f *locationFixture) (uint64, *models.LocationRequest) {
+				return f.site.ID, &models.LocationRequest{Name: "总部", Type: models.LocationTypeSite, ParentID: &f.room.ID}
+			},
+			wantErr: ErrInvalidLocation,
+		},
+		{
+			name: "type not above existing children",
+			update: func(f *locationFixture) (uint64, *models.LocationRequest) {
+				return f.building.ID, &models.LocationRequest{Name: "A栋", Type: models.LocationTypeRoom, ParentID: &f.site.ID}
+			},
+			wantErr: ErrInvalidLocation,
+		},
+		{
+			name: "missing location",
+			update: func(f *locationFixture) (uint64, *models.LocationRequest) {
+				return 999, &models.LocationRequest{Name: "不存在", Type: models.LocationTypeSite}
+			},
+			wantErr: ErrLocationNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newLocationFixture(t)
+			id, req := tt.update(f)
+			node, err := f.locations.UpdateLocation(context.Background(), id, req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.path(f), node.Path)
+		})
+	}
+}
+
+// TestLocationService_DeleteLocation 测试有子位置时不能删除
+func TestLocationService_DeleteLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      func(f *locationFixture) uint64
+		wantErr error
+	}{
+		{"has children", func(f *locationFixture) uint64 { return f.site.ID }, ErrLocationInUse},
+		{"leaf", func(f *locationFixture) uint64 { return f.room.ID }, nil},
+		{"missing", func(f *locationFixture) uint64 { return 999 }, ErrLocationNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newLocationFixture(t)
+			err := f.locations.DeleteLocation(context.Background(), tt.id(f))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestDeviceScopeService_ResolveDeviceIDs 测试按位置子树、分组及两者交集解析设备
+func TestDeviceScopeService_ResolveDeviceIDs(t *testing.T) {
+	f := newLocationFixture(t)
+	missing := uint64(999)
+
+	tests := []struct {
+		name    string
+		filter  models.DeviceScopeFilter
+		want    []string
+		wantErr error
+	}{
+		{"no filter", models.DeviceScopeFilter{}, nil, nil},
+		{"site includes descendants", models.DeviceScopeFilter{LocationID: &f.site.ID}, []string{testutil.DeviceID1, testutil.DeviceID2}, nil},
+		{"room", models.DeviceScopeFilter{LocationID: &f.room.ID}, []string{testutil.DeviceID1}, nil},
+		{"group", models.DeviceScopeFilter{GroupID: &f.group.ID}, []string{testutil.DeviceID2, testutil.DeviceID3}, nil},
+		{"group within site", models.DeviceScopeFilter{GroupID: &f.group.ID, LocationID: &f.site.ID}, []string{testutil.DeviceID2}, nil},
+		{"missing location", models.DeviceScopeFilter{LocationID: &missing}, nil, ErrLocationNotFound},
+		{"missing group", models.DeviceScopeFilter{GroupID: &missing}, nil, ErrDeviceGroupNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := f.scope.ResolveDeviceIDs(context.Background(), tt.filter)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, ids)
+				return
+			}
+			assert.ElementsMatch(t, tt.want, ids)
+		})
+	}
+}
+
+// TestAlertEvaluator_LocationScopedRule 测试限定位置的告警规则只对子树内的设备生效，位置移动后随之变化
+func TestAlertEvaluator_LocationScopedRule(t *testing.T) {
+	f := newLocationFixture(t)
+	ctx := context.Background()
+	_, err := f.rules.CreateRule(ctx, &models.AlertRuleCreateRequest{
+		Name:           "总部甲醛超标",
+		LocationID:     &f.site.ID,
+		Metric:         "formaldehyde",
+		ConditionType:  string(models.AlertConditionGT),
+		ThresholdValue: 0.08,
+	})
+	require.NoError(t, err)
+
+	high := 0.1
+	match := func(deviceID string) bool {
+		matched, err := f.evaluator.Match(ctx, &models.UnifiedSensorData{DeviceID: deviceID, Formaldehyde: &high})
+		require.NoError(t, err)
+		return len(matched) == 1
+	}
+
+	tests := []struct {
+		deviceID string
+		fired    bool
+		moved    bool // 101移动到分部之后
+	}{
+		{testutil.DeviceID1, true, false},
+		{testutil.DeviceID2, true, false},
+		{testutil.DeviceID3, false, false},
+		{testutil.DeviceID1, false, true},
+		{testutil.DeviceID2, true, true},
+	}
+	moved := false
+	for _, tt := range tests {
+		if tt.moved && !moved {
+			_, err := f.locations.UpdateLocation(ctx, f.room.ID,
+				&models.LocationRequest{Name: "101", Type: models.LocationTypeRoom, ParentID: &f.other.ID})
+			require.NoError(t, err)
+			moved = true
+		}
+		assert.Equal(t, tt.fired, match(tt.deviceID), "%s moved=%v", tt.deviceID, tt.moved)
+	}
+}
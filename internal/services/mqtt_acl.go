@@ -0,0 +1,214 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// aclCacheTTL ACL规则缓存有效期（兜底刷新直接修改数据库的情况）
+const aclCacheTTL = 5 * time.Minute
+
+// MQTTACLService MQTT访问控制规则服务接口
+type MQTTACLService interface {
+	CreateRule(ctx context.Context, req *models.MQTTACLRuleCreateRequest) (*models.MQTTACLRule, error)
+	GetRule(ctx context.Context, id uint64) (*models.MQTTACLRule, error)
+	UpdateRule(ctx context.Context, id uint64, req *models.MQTTACLRuleUpdateRequest) (*models.MQTTACLRule, error)
+	DeleteRule(ctx context.Context, id uint64) error
+	ListRules(ctx context.Context, username string) ([]models.MQTTACLRule, error)
+	GetRulesForUsername(ctx context.Context, username string) ([]models.MQTTACLRule, error)
+}
+
+// mqttACLService MQTT访问控制规则服务实现
+type mqttACLService struct {
+	ruleRepo repositories.MQTTACLRuleRepository
+	logger   utils.Logger
+
+	mu       sync.RWMutex
+	cache    map[string][]models.MQTTACLRule
+	loadedAt time.Time
+}
+
+// NewMQTTACLService 创建MQTT访问控制规则服务
+func NewMQTTACLService(ruleRepo repositories.MQTTACLRuleRepository, logger utils.Logger) MQTTACLService {
+	return &mqttACLService{
+		ruleRepo: ruleRepo,
+		logger:   logger,
+	}
+}
+
+// CreateRule 创建规则
+func (s *mqttACLService) CreateRule(ctx context.Context, req *models.MQTTACLRuleCreateRequest) (*models.MQTTACLRule, error) {
+	clientType := models.MQTTClientType(req.ClientType)
+	if !clientType.IsValid() {
+		return nil, fmt.Errorf("不支持的客户端类型: %s", req.ClientType)
+	}
+	access := models.MQTTACLAccess(req.Access)
+	if !access.IsValid() {
+		return nil, fmt.Errorf("不支持的访问类型: %s", req.Access)
+	}
+	if err := ValidateTopicFilter(req.TopicFilter); err != nil {
+		return nil, err
+	}
+
+	rule := &models.MQTTACLRule{
+		Username:    req.Username,
+		ClientType:  clientType,
+		TopicFilter: req.TopicFilter,
+		Access:      access,
+		Allow:       true,
+		Priority:    req.Priority,
+		Enabled:     true,
+		Description: req.Description,
+	}
+	if req.Allow != nil {
+		rule.Allow = *req.Allow
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		s.logger.Error("创建ACL规则失败", utils.ErrorField(err))
+		return nil, err
+	}
+	s.invalidate()
+
+	s.logger.Info("ACL规则创建成功",
+		utils.Int64("rule_id", int64(rule.ID)),
+		utils.String("username", rule.Username),
+		utils.String("topic_filter", rule.TopicFilter))
+	return rule, nil
+}
+
+// GetRule 获取规则
+func (s *mqttACLService) GetRule(ctx context.Context, id uint64) (*models.MQTTACLRule, error) {
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// UpdateRule 更新规则
+func (s *mqttACLService) UpdateRule(ctx context.Context, id uint64, req *models.MQTTACLRuleUpdateRequest) (*models.MQTTACLRule, error) {
+	updates := map[string]interface{}{}
+	if req.TopicFilter != nil {
+		if err := ValidateTopicFilter(*req.TopicFilter); err != nil {
+			return nil, err
+		}
+		updates["topic_filter"] = *req.TopicFilter
+	}
+	if req.Access != nil {
+		if !models.MQTTACLAccess(*req.Access).IsValid() {
+			return nil, fmt.Errorf("不支持的访问类型: %s", *req.Access)
+		}
+		updates["access"] = *req.Access
+	}
+	if req.Allow != nil {
+		updates["allow"] = *req.Allow
+	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if len(updates) > 0 {
+		if err := s.ruleRepo.Update(ctx, id, updates); err != nil {
+			s.logger.Error("更新ACL规则失败", utils.ErrorField(err), utils.Int64("rule_id", int64(id)))
+			return nil, err
+		}
+		s.invalidate()
+	}
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// DeleteRule 删除规则
+func (s *mqttACLService) DeleteRule(ctx context.Context, id uint64) error {
+	if err := s.ruleRepo.Delete(ctx, id); err != nil {
+		s.logger.Error("删除ACL规则失败", utils.ErrorField(err), utils.Int64("rule_id", int64(id)))
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// ListRules 列出规则，username为空时返回全部
+func (s *mqttACLService) ListRules(ctx context.Context, username string) ([]models.MQTTACLRule, error) {
+	if username != "" {
+		return s.ruleRepo.GetByUsername(ctx, username)
+	}
+	resp, err := s.ruleRepo.List(ctx, &repositories.ListRequest{OrderBy: "priority", Order: "desc"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetRulesForUsername 从缓存获取客户端的启用规则（按优先级降序）
+func (s *mqttACLService) GetRulesForUsername(ctx context.Context, username string) ([]models.MQTTACLRule, error) {
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.loadedAt) < aclCacheTTL {
+		rules := s.cache[username]
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	if err := s.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache[username], nil
+}
+
+// reload 重新加载启用的规则
+func (s *mqttACLService) reload(ctx context.Context) error {
+	rules, err := s.ruleRepo.GetEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string][]models.MQTTACLRule)
+	for _, rule := range rules {
+		cache[rule.Username] = append(cache[rule.Username], rule)
+	}
+
+	s.mu.Lock()
+	s.cache = cache
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Debug("ACL规则缓存已刷新", utils.Int("rules_count", len(rules)))
+	return nil
+}
+
+// invalidate 使规则缓存失效
+func (s *mqttACLService) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+// ValidateTopicFilter 校验MQTT主题过滤器格式
+func ValidateTopicFilter(filter string) error {
+	if filter == "" {
+		return errors.New("主题过滤器不能为空")
+	}
+	parts := strings.Split(filter, "/")
+	for i, part := range parts {
+		if strings.Contains(part, "#") && (part != "#" || i != len(parts)-1) {
+			return fmt.Errorf("主题过滤器格式错误: %s", filter)
+		}
+		if strings.Contains(part, "+") && part != "+" {
+			return fmt.Errorf("主题过滤器格式错误: %s", filter)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通知分发默认参数（配置缺省时使用）
+const (
+	defaultNotificationWorkers      = 2
+	defaultNotificationQueueSize    = 1000
+	defaultNotificationRetryBackoff = time.Second
+	maxNotificationRetryBackoff     = time.Minute
+	notificationSendTimeout         = 30 * time.Second
+	notificationEscalationInterval  = time.Minute
+)
+
+// NotificationDispatcher 告警通知分发器接口
+type NotificationDispatcher interface {
+	// Register 注册通知渠道（同类型渠道后注册的覆盖先注册的）
+	Register(notifier Notifier)
+	// Dispatch 异步投递告警通知，按告警规则配置的渠道分发
+	Dispatch(event models.NotificationEvent, alert *models.Alert)
+	// CheckEscalations 检查长时间未确认的告警并发送升级通知，返回升级数量
+	CheckEscalations(ctx context.Context) (int, error)
+	Start()
+	Stop()
+}
+
+// notificationJob 待投递的通知
+type notificationJob struct {
+	event models.NotificationEvent
+	alert models.Alert
+}
+
+// notificationDispatcher 告警通知分发器实现
+type notificationDispatcher struct {
+	cfg           config.NotificationConfig
+	logRepo       repositories.NotificationLogRepository
+	alertRepo     repositories.AlertRepository
+	ruleService   AlertRuleService
+	configService ConfigService
+	logger        utils.Logger
+
+	queue   chan notificationJob
+	workers int
+	backoff time.Duration
+
+	mu        sync.RWMutex
+	notifiers map[models.NotificationChannel]Notifier
+	escalated map[uint64]bool
+	started   bool
+	closed    bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewNotificationDispatcher 创建告警通知分发器（根据配置注册Webhook和邮件渠道）
+func NewNotificationDispatcher(
+	cfg config.NotificationConfig,
+	logRepo repositories.NotificationLogRepository,
+	alertRepo repositories.AlertRepository,
+	ruleService AlertRuleService,
+	configService ConfigService,
+	logger utils.Logger,
+) NotificationDispatcher {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultNotificationWorkers
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultNotificationQueueSize
+	}
+	backoff := time.Duration(cfg.RetryBackoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultNotificationRetryBackoff
+	}
+
+	d := &notificationDispatcher{
+		cfg:           cfg,
+		logRepo:       logRepo,
+		alertRepo:     alertRepo,
+		ruleService:   ruleService,
+		configService: configService,
+		logger:        logger,
+		queue:         make(chan notificationJob, queueSize),
+		workers:       workers,
+		backoff:       backoff,
+		notifiers:     make(map[models.NotificationChannel]Notifier),
+		escalated:     make(map[uint64]bool),
+		done:          make(chan struct{}),
+	}
+
+	if cfg.Webhook.URL != "" {
+		d.Register(NewWebhookNotifier(cfg.Webhook))
+	}
+	if cfg.SMTP.Host != "" {
+		d.Register(NewSMTPNotifier(cfg.SMTP))
+	}
+	return d
+}
+
+// Register 注册通知渠道
+func (d *notificationDispatcher) Register(notifier Notifier) {
+	d.mu.Lock()
+	d.notifiers[notifier.Channel()] = notifier
+	d.mu.Unlock()
+
+	d.logger.Info("通知渠道已注册", utils.String("channel", string(notifier.Channel())))
+}
+
+// Start 启动投递协程及升级检查
+func (d *notificationDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started {
+		return
+	}
+	d.started = true
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	if d.cfg.EscalateAfter > 0 {
+		d.wg.Add(1)
+		go d.escalationLoop()
+	}
+
+	d.logger.Info("告警通知分发器已启动",
+		utils.Int("workers", d.workers),
+		utils.Int("queue_size", cap(d.queue)),
+		utils.Int("max_retries", d.cfg.MaxRetries),
+		utils.Int("escalate_after", d.cfg.EscalateAfter))
+}
+
+// Stop 停止接收通知，等待队列中的通知投递完成（不再等待重试）
+func (d *notificationDispatcher) Stop() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	close(d.done)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	d.logger.Info("告警通知分发器已停止")
+}
+
+// Dispatch 将通知放入投递队列，队列满时丢弃
+func (d *notificationDispatcher) Dispatch(event models.NotificationEvent, alert *models.Alert) {
+	if alert == nil {
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.queue <- notificationJob{event: event, alert: *alert}:
+	default:
+		d.logger.Warn("通知队列已满，丢弃通知",
+			utils.Int64("alert_id", int64(alert.ID)),
+			utils.String("event", string(event)))
+	}
+}
+
+// CheckEscalations 对超过升级时间仍未确认的告警发送升级通知（每条告警仅升级一次）
+func (d *notificationDispatcher) CheckEscalations(ctx context.Context) (int, error) {
+	if d.cfg.EscalateAfter <= 0 {
+		return 0, nil
+	}
+
+	alerts, err := d.alertRepo.GetByStatus(string(models.AlertStatusActive))
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-time.Duration(d.cfg.EscalateAfter) * time.Second)
+	escalated := 0
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.TriggeredAt.After(cutoff) || d.isEscalated(alert.ID) {
+			continue
+		}
+
+		// 重启后以投递记录为准，避免重复升级
+		sent, err := d.logRepo.HasEvent(ctx, alert.ID, models.NotificationEventEscalated)
+		if err != nil {
+			return escalated, err
+		}
+		d.markEscalated(alert.ID)
+		if sent {
+			continue
+		}
+
+		d.Dispatch(models.NotificationEventEscalated, alert)
+		escalated++
+	}
+
+	if escalated > 0 {
+		d.logger.Info("告警升级通知已发出", utils.Int("count", escalated))
+	}
+	return escalated, nil
+}
+
+// isEscalated 判断告警是否已升级
+func (d *notificationDispatcher) isEscalated(alertID uint64) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.escalated[alertID]
+}
+
+// markEscalated 标记告警已升级
+func (d *notificationDispatcher) markEscalated(alertID uint64) {
+	d.mu.Lock()
+	d.escalated[alertID] = true
+	d.mu.Unlock()
+}
+
+// worker 投递协程
+func (d *notificationDispatcher) worker() {
+	defer d.wg.Done()
+	for job := range d.queue {
+		d.process(job)
+	}
+}
+
+// escalationLoop 定期检查需要升级的告警
+func (d *notificationDispatcher) escalationLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(notificationEscalationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+			if _, err := d.CheckEscalations(ctx); err != nil {
+				d.logger.Error("检查告警升级失败", utils.ErrorField(err))
+			}
+			cancel()
+		}
+	}
+}
+
+// process 按规则渠道分发单条通知
+func (d *notificationDispatcher) process(job notificationJob) {
+	ctx := context.Background()
+
+	settings := d.systemSettings(ctx)
+	if enabled, ok := settings["enable_notifications"].(bool); ok && !enabled {
+		d.logger.Debug("系统设置已关闭通知，跳过", utils.Int64("alert_id", int64(job.alert.ID)))
+		return
+	}
+
+	channels, ruleName := d.resolveChannels(ctx, &job.alert)
+	if len(channels) == 0 {
+		return
+	}
+
+	msg := d.buildMessage(job.event, &job.alert, ruleName)
+	// 系统设置中的通知邮箱优先于配置文件中的默认收件人
+	if email, ok := settings["notification_email"].(string); ok {
+		for _, addr := range strings.Split(email, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				msg.Recipients = append(msg.Recipients, addr)
+			}
+		}
+	}
+	for _, channel := range channels {
+		d.mu.RLock()
+		notifier, ok := d.notifiers[channel]
+		d.mu.RUnlock()
+		if !ok {
+			d.logger.Debug("通知渠道未配置，跳过",
+				utils.String("channel", string(channel)),
+				utils.Int64("alert_id", int64(job.alert.ID)))
+			continue
+		}
+		d.deliver(ctx, notifier, msg)
+	}
+}
+
+// deliver 投递到单个渠道，失败后按指数退避重试，每次尝试记录投递日志
+func (d *notificationDispatcher) deliver(ctx context.Context, notifier Notifier, msg *models.NotificationMessage) {
+	backoff := d.backoff
+	attempts := d.cfg.MaxRetries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, notificationSendTimeout)
+		err := notifier.Send(sendCtx, msg)
+		cancel()
+		d.record(ctx, notifier, msg, attempt, err)
+
+		if err == nil {
+			d.logger.Info("告警通知已发送",
+				utils.Int64("alert_id", int64(msg.Alert.ID)),
+				utils.String("event", string(msg.Event)),
+				utils.String("channel", string(notifier.Channel())),
+				utils.Int("attempt", attempt))
+			return
+		}
+
+		d.logger.Warn("告警通知发送失败",
+			utils.Int64("alert_id", int64(msg.Alert.ID)),
+			utils.String("event", string(msg.Event)),
+			utils.String("channel", string(notifier.Channel())),
+			utils.Int("attempt", attempt),
+			utils.ErrorField(err))
+		if attempt == attempts {
+			return
+		}
+
+		select {
+		case <-d.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxNotificationRetryBackoff {
+			backoff = maxNotificationRetryBackoff
+		}
+	}
+}
+
+// record 记录一次投递尝试
+func (d *notificationDispatcher) record(ctx context.Context, notifier Notifier, msg *models.NotificationMessage, attempt int, sendErr error) {
+	entry := &models.NotificationLog{
+		AlertID: msg.Alert.ID,
+		RuleID:  msg.Alert.RuleID,
+		Event:   msg.Event,
+		Channel: notifier.Channel(),
+		Target:  notifier.Target(msg),
+		Attempt: attempt,
+		Status:  models.NotificationStatusSuccess,
+	}
+	if sendErr != nil {
+		errMsg := sendErr.Error()
+		entry.Status = models.NotificationStatusFailed
+		entry.Error = &errMsg
+	}
+	if err := d.logRepo.Create(ctx, entry); err != nil {
+		d.logger.Error("记录通知投递日志失败", utils.ErrorField(err), utils.Int64("alert_id", int64(msg.Alert.ID)))
+	}
+}
+
+// systemSettings 读取系统设置（通知开关、通知邮箱），读取失败时按未设置处理
+func (d *notificationDispatcher) systemSettings(ctx context.Context) map[string]interface{} {
+	if d.configService == nil {
+		return nil
+	}
+	settings, err := d.configService.GetSystemSettings(ctx)
+	if err != nil {
+		return nil
+	}
+	return settings
+}
+
+// resolveChannels 获取告警对应规则的通知渠道，无规则的告警使用默认渠道
+func (d *notificationDispatcher) resolveChannels(ctx context.Context, alert *models.Alert) ([]models.NotificationChannel, string) {
+	var names []string
+	ruleName := ""
+
+	if alert.RuleID == 0 {
+		names = d.cfg.DefaultChannels
+	} else {
+		rule, err := d.ruleService.GetRule(ctx, alert.RuleID)
+		if err != nil {
+			d.logger.Error("获取告警规则失败，无法发送通知",
+				utils.Int64("rule_id", int64(alert.RuleID)),
+				utils.ErrorField(err))
+			return nil, ""
+		}
+		ruleName = rule.Name
+		if rule.NotificationChannels != nil && *rule.NotificationChannels != "" {
+			if err := json.Unmarshal([]byte(*rule.NotificationChannels), &names); err != nil {
+				d.logger.Error("解析告警规则通知渠道失败",
+					utils.Int64("rule_id", int64(rule.ID)),
+					utils.ErrorField(err))
+				return nil, ruleName
+			}
+		}
+	}
+
+	channels := make([]models.NotificationChannel, 0, len(names))
+	seen := make(map[models.NotificationChannel]bool)
+	for _, name := range names {
+		channel := models.NotificationChannel(name)
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	return channels, ruleName
+}
+
+// buildMessage 构造通知内容
+func (d *notificationDispatcher) buildMessage(event models.NotificationEvent, alert *models.Alert, ruleName string) *models.NotificationMessage {
+	title := ruleName
+	if title == "" {
+		title = alert.Metric
+	}
+
+	var action string
+	switch event {
+	case models.NotificationEventResolved:
+		action = "告警已解决"
+	case models.NotificationEventEscalated:
+		action = "告警升级（长时间未确认）"
+	default:
+		action = "告警触发"
+	}
+
+	lines := []string{
+		fmt.Sprintf("事件: %s", action),
+		fmt.Sprintf("设备: %s", alert.DeviceID),
+		fmt.Sprintf("指标: %s", alert.Metric),
+		fmt.Sprintf("当前值: %.3f", alert.CurrentValue),
+		fmt.Sprintf("阈值: %.3f", alert.ThresholdValue),
+		fmt.Sprintf("级别: %s", alert.Severity),
+		fmt.Sprintf("触发时间: %s", alert.TriggeredAt.Format("2006-01-02 15:04:05")),
+	}
+	if alert.ResolvedAt != nil {
+		lines = append(lines, fmt.Sprintf("解决时间: %s", alert.ResolvedAt.Format("2006-01-02 15:04:05")))
+	}
+	if alert.Message != nil && *alert.Message != "" {
+		lines = append(lines, fmt.Sprintf("详情: %s", *alert.Message))
+	}
+
+	return &models.NotificationMessage{
+		Event:    event,
+		Subject:  fmt.Sprintf("[%s] %s - %s %s", strings.ToUpper(alert.Severity), action, alert.DeviceID, title),
+		Content:  strings.Join(lines, "\n"),
+		RuleName: ruleName,
+		Alert:    alert,
+		SentAt:   time.Now(),
+	}
+}
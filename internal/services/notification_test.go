@@ -0,0 +1,363 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"air-quality-server/internal/utils"
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStub 本地SMTP测试服务器，记录收到的邮件
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []string
+}
+
+// newSMTPStub 启动本地SMTP测试服务器
+func newSMTPStub(t *testing.T) *smtpStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	stub := &smtpStub{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+// serve 处理单个SMTP会话
+func (s *smtpStub) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stub")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// Messages 获取收到的邮件
+func (s *smtpStub) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// Port 监听端口
+func (s *smtpStub) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// fakePublisher 记录发布的MQTT消息
+type fakePublisher struct {
+	mu       sync.Mutex
+	topics   []string
+	payloads [][]byte
+}
+
+// Publish 记录消息
+func (p *fakePublisher) Publish(topic string, payload interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topics = append(p.topics, topic)
+	p.payloads = append(p.payloads, payload.([]byte))
+	return nil
+}
+
+// Count 已发布的消息数
+func (p *fakePublisher) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.topics)
+}
+
+// notificationFixture 通知分发测试环境
+type notificationFixture struct {
+	alerts  repositories.AlertRepository
+	logs    repositories.NotificationLogRepository
+	rules   AlertRuleService
+	configs ConfigService
+	logger  utils.Logger
+}
+
+// newNotificationFixture 创建基于测试数据库的告警、投递记录、规则和系统设置
+func newNotificationFixture(t *testing.T) *notificationFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	return &notificationFixture{
+		alerts:  repositories.NewAlertRepository(db, logger),
+		logs:    repositories.NewNotificationLogRepository(db, logger),
+		rules:   NewAlertRuleService(repositories.NewAlertRuleRepository(db, logger), logger),
+		configs: NewConfigService(repositories.NewConfigRepository(db, logger), logger),
+		logger:  logger,
+	}
+}
+
+// dispatcher 按配置创建通知分发器
+func (f *notificationFixture) dispatcher(cfg config.NotificationConfig) NotificationDispatcher {
+	return NewNotificationDispatcher(cfg, f.logs, f.alerts, f.rules, f.configs, f.logger)
+}
+
+// createRule 创建告警规则
+func (f *notificationFixture) createRule(t *testing.T, req *models.AlertRuleCreateRequest) *models.AlertRule {
+	rule, err := f.rules.CreateRule(context.Background(), req)
+	require.NoError(t, err)
+	return rule
+}
+
+// deliveries 获取告警的投递记录
+func (f *notificationFixture) deliveries(t *testing.T, alertID uint64) []models.NotificationLog {
+	logs, err := f.logs.GetByAlertID(context.Background(), alertID)
+	require.NoError(t, err)
+	return logs
+}
+
+// TestNotificationDispatcher_WebhookAndEmail 测试Webhook签名、失败重试及邮件发送
+func TestNotificationDispatcher_WebhookAndEmail(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+
+	const secret = "webhook-secret"
+	var requests atomic.Int32
+	var mu sync.Mutex
+	var events []models.NotificationMessage
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次请求模拟接收方故障
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookHeaderTimestamp), 10, 64)
+		if r.Header.Get(WebhookHeaderSignature) != SignWebhookPayload(secret, timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var msg models.NotificationMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		events = append(events, msg)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	smtpServer := newSMTPStub(t)
+	dispatcher := f.dispatcher(config.NotificationConfig{
+		MaxRetries:   2,
+		RetryBackoff: 10,
+		Webhook:      config.WebhookConfig{URL: webhook.URL, Secret: secret},
+		SMTP: config.SMTPConfig{
+			Host: "127.0.0.1",
+			Port: smtpServer.Port(),
+			From: "alert@air-quality.local",
+			To:   []string{"ops@air-quality.local"},
+		},
+	})
+	dispatcher.Start()
+	defer dispatcher.Stop()
+	alertService := NewAlertService(f.alerts, dispatcher, f.logger)
+
+	rule := f.createRule(t, &models.AlertRuleCreateRequest{
+		Name:                 "甲醛浓度超标",
+		Metric:               "formaldehyde",
+		ConditionType:        string(models.AlertConditionGT),
+		ThresholdValue:       0.08,
+		Severity:             string(models.AlertSeverityCritical),
+		NotificationChannels: []string{"webhook", "email", "sms"},
+	})
+
+	// 系统设置的通知邮箱优先于默认收件人
+	require.NoError(t, f.configs.SetConfig(ctx, "notification_email", "duty@air-quality.local", "system", "通知邮箱"))
+
+	alert := &models.Alert{
+		RuleID:         rule.ID,
+		DeviceID:       testutil.DeviceID1,
+		Metric:         "formaldehyde",
+		CurrentValue:   0.12,
+		ThresholdValue: 0.08,
+		Severity:       rule.Severity,
+		Status:         string(models.AlertStatusActive),
+		TriggeredAt:    time.Now(),
+	}
+	require.NoError(t, alertService.CreateAlert(ctx, alert))
+
+	// Webhook首次失败后重试成功，邮件一次成功，未配置的短信渠道跳过
+	require.Eventually(t, func() bool {
+		return len(f.deliveries(t, alert.ID)) == 3
+	}, 5*time.Second, 20*time.Millisecond)
+
+	tests := []struct {
+		channel models.NotificationChannel
+		status  models.NotificationStatus
+		attempt int
+		target  string
+	}{
+		{models.NotificationChannelWebhook, models.NotificationStatusFailed, 1, webhook.URL},
+		{models.NotificationChannelWebhook, models.NotificationStatusSuccess, 2, webhook.URL},
+		{models.NotificationChannelEmail, models.NotificationStatusSuccess, 1, "duty@air-quality.local"},
+	}
+	deliveries := f.deliveries(t, alert.ID)
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].Channel > deliveries[j].Channel })
+	for i, tt := range tests {
+		entry := deliveries[i]
+		assert.Equal(t, models.NotificationEventTriggered, entry.Event)
+		assert.Equal(t, rule.ID, entry.RuleID)
+		assert.Equal(t, tt.channel, entry.Channel)
+		assert.Equal(t, tt.status, entry.Status, string(tt.channel))
+		assert.Equal(t, tt.attempt, entry.Attempt, string(tt.channel))
+		assert.Equal(t, tt.target, entry.Target, string(tt.channel))
+		assert.Equal(t, tt.status == models.NotificationStatusFailed, entry.Error != nil, string(tt.channel))
+	}
+
+	mails := smtpServer.Messages()
+	require.Len(t, mails, 1)
+	assert.Contains(t, mails[0], "To: duty@air-quality.local")
+	assert.Contains(t, mails[0], "设备: "+testutil.DeviceID1)
+
+	// 告警自动解决后发送解决通知
+	resolved, err := alertService.AutoResolveAlert(ctx, alert.ID, 0.05)
+	require.NoError(t, err)
+	require.True(t, resolved)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, models.NotificationEventTriggered, events[0].Event)
+	assert.Equal(t, "甲醛浓度超标", events[0].RuleName)
+	assert.Equal(t, models.NotificationEventResolved, events[1].Event)
+	require.NotNil(t, events[1].Alert)
+	assert.NotNil(t, events[1].Alert.ResolvedAt)
+}
+
+// TestNotificationDispatcher_MQTTAndEscalation 测试MQTT渠道、告警升级及通知开关
+func TestNotificationDispatcher_MQTTAndEscalation(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+
+	cfg := config.NotificationConfig{EscalateAfter: 60}
+	dispatcher := f.dispatcher(cfg)
+	publisher := &fakePublisher{}
+	dispatcher.Register(NewMQTTNotifier(publisher, "air-quality/alerts/{device_id}"))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	rule := f.createRule(t, &models.AlertRuleCreateRequest{
+		Name:                 "电量低",
+		Metric:               "battery",
+		ConditionType:        string(models.AlertConditionLT),
+		ThresholdValue:       20,
+		NotificationChannels: []string{"mqtt"},
+	})
+
+	// 两分钟前触发且未确认的告警需要升级，刚触发的不升级
+	stale := &models.Alert{
+		RuleID: rule.ID, DeviceID: testutil.DeviceID1, Metric: "battery",
+		CurrentValue: 10, ThresholdValue: 20, Severity: rule.Severity,
+		Status: string(models.AlertStatusActive), TriggeredAt: time.Now().Add(-2 * time.Minute),
+	}
+	fresh := &models.Alert{
+		RuleID: rule.ID, DeviceID: testutil.DeviceID2, Metric: "battery",
+		CurrentValue: 12, ThresholdValue: 20, Severity: rule.Severity,
+		Status: string(models.AlertStatusActive), TriggeredAt: time.Now(),
+	}
+	require.NoError(t, f.alerts.Create(ctx, stale))
+	require.NoError(t, f.alerts.Create(ctx, fresh))
+
+	// 同一告警只升级一次，重启后依据投递记录判断
+	tests := []struct {
+		name       string
+		dispatcher NotificationDispatcher
+		escalated  int
+	}{
+		{"first check", dispatcher, 1},
+		{"repeated check", dispatcher, 0},
+		{"after restart", f.dispatcher(cfg), 0},
+	}
+	for _, tt := range tests {
+		escalated, err := tt.dispatcher.CheckEscalations(ctx)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.escalated, escalated, tt.name)
+		require.Eventually(t, func() bool { return len(f.deliveries(t, stale.ID)) == 1 }, 5*time.Second, 20*time.Millisecond, tt.name)
+	}
+	assert.Equal(t, 1, publisher.Count())
+
+	publisher.mu.Lock()
+	assert.Equal(t, "air-quality/alerts/"+testutil.DeviceID1, publisher.topics[0])
+	var msg models.NotificationMessage
+	require.NoError(t, json.Unmarshal(publisher.payloads[0], &msg))
+	publisher.mu.Unlock()
+	assert.Equal(t, models.NotificationEventEscalated, msg.Event)
+	assert.Equal(t, stale.ID, msg.Alert.ID)
+
+	// 系统设置关闭通知后不再投递
+	require.NoError(t, f.configs.SetConfig(ctx, "enable_notifications", "false", "system", "是否启用通知"))
+	dispatcher.Dispatch(models.NotificationEventTriggered, fresh)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, publisher.Count())
+	assert.Empty(t, f.deliveries(t, fresh.ID))
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook请求头
+const (
+	WebhookHeaderEvent     = "X-Alert-Event"
+	WebhookHeaderTimestamp = "X-Alert-Timestamp"
+	WebhookHeaderSignature = "X-Alert-Signature"
+)
+
+// Notifier 告警通知渠道接口
+type Notifier interface {
+	// Channel 渠道类型，与告警规则中的notification_channels对应
+	Channel() models.NotificationChannel
+	// Target 投递目标（用于记录投递日志）
+	Target(msg *models.NotificationMessage) string
+	// Send 投递一条通知
+	Send(ctx context.Context, msg *models.NotificationMessage) error
+}
+
+// MessagePublisher MQTT消息发布接口（由MQTT服务器实现）
+type MessagePublisher interface {
+	Publish(topic string, payload interface{}) error
+}
+
+// SignWebhookPayload 计算Webhook签名：sha256=HEX(HMAC-SHA256(secret, "时间戳.请求体"))
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookNotifier Webhook通知（JSON POST，附带HMAC签名）
+type webhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建Webhook通知渠道
+func NewWebhookNotifier(cfg config.WebhookConfig) Notifier {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &webhookNotifier{
+		url:    cfg.URL,
+		secret: cfg.Secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Channel 渠道类型
+func (n *webhookNotifier) Channel() models.NotificationChannel {
+	return models.NotificationChannelWebhook
+}
+
+// Target 投递目标
+func (n *webhookNotifier) Target(msg *models.NotificationMessage) string {
+	return n.url
+}
+
+// Send 发送Webhook请求，非2xx响应视为失败
+func (n *webhookNotifier) Send(ctx context.Context, msg *models.NotificationMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建Webhook请求失败: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, string(msg.Event))
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if n.secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(n.secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Webhook请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook响应异常: %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier 邮件通知
+type smtpNotifier struct {
+	cfg     config.SMTPConfig
+	timeout time.Duration
+}
+
+// NewSMTPNotifier 创建邮件通知渠道
+func NewSMTPNotifier(cfg config.SMTPConfig) Notifier {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = 25
+	}
+	return &smtpNotifier{cfg: cfg, timeout: timeout}
+}
+
+// Channel 渠道类型
+func (n *smtpNotifier) Channel() models.NotificationChannel {
+	return models.NotificationChannelEmail
+}
+
+// Target 投递目标
+func (n *smtpNotifier) Target(msg *models.NotificationMessage) string {
+	return strings.Join(n.recipients(msg), ",")
+}
+
+// recipients 收件人（通知指定的收件人优先）
+func (n *smtpNotifier) recipients(msg *models.NotificationMessage) []string {
+	if len(msg.Recipients) > 0 {
+		return msg.Recipients
+	}
+	return n.cfg.To
+}
+
+// Send 发送邮件（服务器支持时使用STARTTLS）
+func (n *smtpNotifier) Send(ctx context.Context, msg *models.NotificationMessage) error {
+	recipients := n.recipients(msg)
+	if len(recipients) == 0 {
+		return errors.New("未配置邮件收件人")
+	}
+
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("连接邮件服务器失败: %w", err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("邮件服务器握手失败: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("STARTTLS失败: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		auth := smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("邮件服务器认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.cfg.From); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("设置收件人%s失败: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if _, err := w.Write(n.buildMessage(msg, recipients)); err != nil {
+		w.Close()
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage 构造邮件正文（UTF-8纯文本）
+func (n *smtpNotifier) buildMessage(msg *models.NotificationMessage, recipients []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", msg.SentAt.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Content, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// mqttNotifier MQTT通知（发布到告警主题）
+type mqttNotifier struct {
+	publisher MessagePublisher
+	topic     string
+}
+
+// NewMQTTNotifier 创建MQTT通知渠道，主题中的{device_id}替换为告警设备ID
+func NewMQTTNotifier(publisher MessagePublisher, topic string) Notifier {
+	return &mqttNotifier{publisher: publisher, topic: topic}
+}
+
+// Channel 渠道类型
+func (n *mqttNotifier) Channel() models.NotificationChannel {
+	return models.NotificationChannelMQTT
+}
+
+// Target 投递目标
+func (n *mqttNotifier) Target(msg *models.NotificationMessage) string {
+	deviceID := ""
+	if msg.Alert != nil {
+		deviceID = msg.Alert.DeviceID
+	}
+	return strings.ReplaceAll(n.topic, "{device_id}", deviceID)
+}
+
+// Send 发布通知消息
+func (n *mqttNotifier) Send(ctx context.Context, msg *models.NotificationMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	return n.publisher.Publish(n.Target(msg), payload)
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 数据保留任务默认参数（配置缺省时使用）
+const (
+	defaultRetentionInterval  = time.Hour
+	defaultRetentionChunkSize = 1000
+	retentionRunTimeout       = 30 * time.Minute
+	// retentionSettingKey 系统设置中的原始数据保留天数
+	retentionSettingKey = "data_retention_days"
+)
+
+// RetentionService 数据保留服务接口
+type RetentionService interface {
+	// RunOnce 按保留策略分批删除过期数据，dryRun为true时只统计将被删除的行数
+	RunOnce(ctx context.Context, dryRun bool) ([]models.RetentionResult, error)
+	Start()
+	Stop()
+}
+
+// retentionPolicy 保留策略：表、过期条件和保留天数
+type retentionPolicy struct {
+	name   string
+	days   int
+	target models.RetentionTarget
+}
+
+// retentionService 数据保留服务实现
+type retentionService struct {
+	cfg            config.RetentionConfig
+	retentionRepo  repositories.RetentionRepository
+	configService  ConfigService
+	rollupRepo     repositories.SensorDataRollupRepository
+	archiveService SensorDataArchiveService
+	logger         utils.Logger
+	interval       time.Duration
+	chunkSize      int
+	chunkPause     time.Duration
+
+	runMu   sync.Mutex
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewRetentionService 创建数据保留服务（configService为空时原始数据只按配置文件保留，
+// rollupRepo为空时不检查汇总进度，archive为空时不检查冷归档进度）
+func NewRetentionService(
+	cfg config.RetentionConfig,
+	retentionRepo repositories.RetentionRepository,
+	configService ConfigService,
+	rollupRepo repositories.SensorDataRollupRepository,
+	archive SensorDataArchiveService,
+	logger utils.Logger,
+) RetentionService {
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	chunkSize := cfg.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultRetentionChunkSize
+	}
+
+	return &retentionService{
+		cfg:            cfg,
+		retentionRepo:  retentionRepo,
+		configService:  configService,
+		rollupRepo:     rollupRepo,
+		archiveService: archive,
+		logger:         logger,
+		interval:       interval,
+		chunkSize:      chunkSize,
+		chunkPause:     time.Duration(cfg.ChunkPause) * time.Millisecond,
+		done:           make(chan struct{}),
+	}
+}
+
+// RunOnce 依次执行各保留策略，保留天数为0的策略跳过
+func (s *retentionService) RunOnce(ctx context.Context, dryRun bool) ([]models.RetentionResult, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	policies, err := s.policies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := make([]models.RetentionResult, 0, len(policies))
+	for i := range policies {
+		policy := &policies[i]
+		if policy.days <= 0 {
+			continue
+		}
+		result := models.RetentionResult{
+			Policy: policy.name,
+			Table:  policy.target.Table,
+			Days:   policy.days,
+			Cutoff: now.AddDate(0, 0, -policy.days),
+			DryRun: dryRun,
+		}
+
+		if dryRun {
+			result.Matched, err = s.retentionRepo.CountExpired(ctx, &policy.target, result.Cutoff)
+		} else {
+			err = s.purge(ctx, &policy.target, &result)
+		}
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("执行保留策略%s失败: %w", policy.name, err)
+		}
+		if result.Deleted > 0 {
+			s.logger.Info("已清理过期数据",
+				utils.String("policy", policy.name),
+				utils.String("table", policy.target.Table),
+				utils.Int64("deleted", result.Deleted),
+				utils.Int64("archived", result.Archived))
+		}
+	}
+	return results, nil
+}
+
+// policies 生成保留策略列表
+func (s *retentionService) policies(ctx context.Context) ([]retentionPolicy, error) {
+	raw := models.RetentionTarget{Table: "unified_sensor_data", KeyColumns: []string{"id"}, TimeColumn: "timestamp"}
+	// 尚未汇总或尚未冷归档的原始数据不删除
+	var conditions []string
+	if s.rollupRepo != nil {
+		state, err := s.rollupRepo.GetState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "id <= ?")
+		raw.Args = append(raw.Args, state.LastID)
+	}
+	if s.archiveService != nil {
+		archivedUntil, err := s.archiveService.ArchivedUntil()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "timestamp < ?")
+		raw.Args = append(raw.Args, archivedUntil)
+	}
+	raw.Condition = strings.Join(conditions, " AND ")
+
+	alertChildren := []models.RetentionChild{
+		{Table: "alert_histories", ForeignKey: "alert_id"},
+		{Table: "notification_logs", ForeignKey: "alert_id"},
+	}
+	rollupKeys := []string{"device_id", "metric", "bucket_start"}
+
+	return []retentionPolicy{
+		{name: "raw", days: s.rawDays(ctx), target: raw},
+		{name: "rollup_1m", days: s.cfg.Rollup1mDays, target: models.RetentionTarget{
+			Table: models.RollupResolutionMinute.TableName(), KeyColumns: rollupKeys, TimeColumn: "bucket_start"}},
+		{name: "rollup_1h", days: s.cfg.Rollup1hDays, target: models.RetentionTarget{
+			Table: models.RollupResolutionHour.TableName(), KeyColumns: rollupKeys, TimeColumn: "bucket_start"}},
+		{name: "rollup_1d", days: s.cfg.Rollup1dDays, target: models.RetentionTarget{
+			Table: models.RollupResolutionDay.TableName(), KeyColumns: rollupKeys, TimeColumn: "bucket_start"}},
+		{name: "resolved_alerts", days: s.cfg.ResolvedAlertDays, target: models.RetentionTarget{
+			Table: "alerts", KeyColumns: []string{"id"}, TimeColumn: "resolved_at",
+			Condition: "status = ?", Args: []interface{}{models.AlertStatusResolved}, Children: alertChildren}},
+		{name: "deleted_sensor_data", days: s.cfg.SoftDeleteDays, target: models.RetentionTarget{
+			Table: "unified_sensor_data", KeyColumns: []string{"id"}, TimeColumn: "deleted_at"}},
+		{name: "deleted_alerts", days: s.cfg.SoftDeleteDays, target: models.RetentionTarget{
+			Table: "alerts", KeyColumns: []string{"id"}, TimeColumn: "deleted_at", Children: alertChildren}},
+		{name: "deleted_alert_rules", days: s.cfg.SoftDeleteDays, target: models.RetentionTarget{
+			Table: "alert_rules", KeyColumns: []string{"id"}, TimeColumn: "deleted_at"}},
+	}, nil
+}
+
+// rawDays 原始数据保留天数：优先使用系统设置，未设置或无效时使用配置文件
+func (s *retentionService) rawDays(ctx context.Context) int {
+	if s.configService == nil {
+		return s.cfg.RawDays
+	}
+	setting, err := s.configService.GetConfig(ctx, retentionSettingKey)
+	if err != nil || setting == nil || setting.Value == nil {
+		return s.cfg.RawDays
+	}
+	days, err := utils.StringToInt(*setting.Value)
+	if err != nil || days < 0 {
+		s.logger.Warn("系统设置中的数据保留天数无效，使用配置文件中的值",
+			utils.String("value", *setting.Value), utils.Int("raw_days", s.cfg.RawDays))
+		return s.cfg.RawDays
+	}
+	return days
+}
+
+// purge 分批归档并删除过期数据，每批单独提交，批次之间暂停以减少锁等待
+func (s *retentionService) purge(ctx context.Context, target *models.RetentionTarget, result *models.RetentionResult) error {
+	archive := s.cfg.ArchiveDir != ""
+	for {
+		rows, err := s.retentionRepo.ListExpired(ctx, target, result.Cutoff, s.chunkSize, archive)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if archive {
+			if err := s.archive(target.Table, rows); err != nil {
+				return err
+			}
+			result.Archived += int64(len(rows))
+		}
+		deleted, err := s.retentionRepo.DeleteRows(ctx, target, rows)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return fmt.Errorf("%s中%d行过期数据未能删除", target.Table, len(rows))
+		}
+		result.Deleted += deleted
+		if len(rows) < s.chunkSize {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.chunkPause):
+		}
+	}
+}
+
+// archive 将一批数据追加到归档目录下按表和日期命名的文件，每批写入一个独立的gzip分段
+func (s *retentionService) archive(table string, rows []map[string]interface{}) error {
+	dir := filepath.Join(s.cfg.ArchiveDir, table)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	path := filepath.Join(dir, time.Now().Format("2006-01-02")+".ndjson.gz")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, row := range rows {
+		for key, value := range row {
+			if bytes, ok := value.([]byte); ok {
+				row[key] = string(bytes)
+			}
+		}
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("写入归档文件失败: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	// 确认归档落盘后才删除数据
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	return nil
+}
+
+// Start 启动清理协程
+func (s *retentionService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	s.wg.Add(1)
+	go s.retentionLoop()
+
+	s.logger.Info("数据保留任务已启动", utils.Duration("interval", s.interval))
+}
+
+// Stop 停止清理协程，正在执行的清理在当前批次结束后退出
+func (s *retentionService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("数据保留任务已停止")
+}
+
+// retentionLoop 定期执行清理
+func (s *retentionService) retentionLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.runScheduled()
+		}
+	}
+}
+
+// runScheduled 执行一次定时清理，服务停止时取消
+func (s *retentionService) runScheduled() {
+	ctx, cancel := context.WithTimeout(context.Background(), retentionRunTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if _, err := s.RunOnce(ctx, false); err != nil {
+		s.logger.Error("清理过期数据失败", utils.ErrorField(err))
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// retentionFixture 系统设置保留30天原始数据（配置文件中的90天不生效）：5条40天前已汇总的数据、1条最近数据、
+// 1条10天前软删除的数据和1条40天前尚未汇总的数据；3个分钟汇总桶中2个过期；已解决和未解决的过期告警各一条
+type retentionFixture struct {
+	db         *gorm.DB
+	retention  RetentionService
+	archiveDir string
+	old        time.Time
+	recent     *models.UnifiedSensorData
+	deleted    *models.UnifiedSensorData
+	unrolled   *models.UnifiedSensorData
+}
+
+// newRetentionFixture 写入保留策略涉及的各类数据
+func newRetentionFixture(t *testing.T) *retentionFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	now := time.Now()
+	f := &retentionFixture{db: db, archiveDir: t.TempDir(), old: now.AddDate(0, 0, -40)}
+
+	value := 12.0
+	reading := func(at time.Time) *models.UnifiedSensorData {
+		data := &models.UnifiedSensorData{DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeAirQuality, Timestamp: at, PM25: &value}
+		require.NoError(t, db.Create(data).Error)
+		return data
+	}
+	days := "30"
+	require.NoError(t, db.Create(&models.SystemConfig{KeyName: "data_retention_days", Value: &days}).Error)
+	for i := 0; i < 5; i++ {
+		reading(f.old.Add(time.Duration(i) * time.Minute))
+	}
+	f.recent = reading(now.Add(-time.Hour))
+	f.deleted = reading(now.Add(-2 * time.Hour))
+	require.NoError(t, db.Delete(f.deleted).Error)
+	require.NoError(t, db.Unscoped().Model(f.deleted).Update("deleted_at", now.AddDate(0, 0, -10)).Error)
+	f.unrolled = reading(f.old.Add(10 * time.Minute))
+	require.NoError(t, db.Create(&models.SensorDataRollupState{Name: "sensor_data", LastID: f.unrolled.ID - 1}).Error)
+
+	for _, bucket := range []time.Time{f.old.Truncate(time.Minute), f.old.Truncate(time.Minute).Add(time.Minute), now.Truncate(time.Minute)} {
+		require.NoError(t, db.Create(&models.SensorDataRollup1m{SensorDataRollup: models.SensorDataRollup{
+			DeviceID: testutil.DeviceID1, Metric: "pm25", BucketStart: bucket, Avg: value, Count: 1}}).Error)
+	}
+
+	resolvedAt := now.AddDate(-2, 0, 0)
+	resolved := &models.Alert{RuleID: 1, DeviceID: testutil.DeviceID1, Metric: "pm25", Severity: "warning",
+		Status: string(models.AlertStatusResolved), TriggeredAt: resolvedAt, ResolvedAt: &resolvedAt}
+	active := &models.Alert{RuleID: 1, DeviceID: testutil.DeviceID1, Metric: "pm25", Severity: "warning",
+		Status: "active", TriggeredAt: resolvedAt}
+	require.NoError(t, db.Create(resolved).Error)
+	require.NoError(t, db.Create(active).Error)
+	require.NoError(t, db.Create(&models.AlertHistory{AlertID: resolved.ID, Action: models.AlertActionResolved, ToStatus: "resolved"}).Error)
+	require.NoError(t, db.Create(&models.AlertHistory{AlertID: active.ID, Action: models.AlertActionResolved, ToStatus: "active"}).Error)
+	require.NoError(t, db.Create(&models.NotificationLog{AlertID: resolved.ID, Event: models.NotificationEventResolved, Channel: "webhook", Attempt: 1, Status: "sent"}).Error)
+
+	f.retention = NewRetentionService(config.RetentionConfig{
+		ChunkSize:         2,
+		RawDays:           90,
+		Rollup1mDays:      30,
+		ResolvedAlertDays: 365,
+		SoftDeleteDays:    30,
+		ArchiveDir:        f.archiveDir,
+	}, repositories.NewRetentionRepository(db, logger),
+		NewConfigService(repositories.NewConfigRepository(db, logger), logger),
+		repositories.NewSensorDataRollupRepository(db, logger), nil, logger)
+	return f
+}
+
+// run 执行一次保留任务，返回各策略匹配或删除的行数
+func (f *retentionFixture) run(t *testing.T, dryRun bool) map[string]int64 {
+	results, err := f.retention.RunOnce(context.Background(), dryRun)
+	require.NoError(t, err)
+	counts := make(map[string]int64)
+	for _, result := range results {
+		counts[result.Policy] = result.Matched + result.Deleted
+	}
+	return counts
+}
+
+// count 统计表中的行数（含软删除）
+func (f *retentionFixture) count(t *testing.T, model interface{}) int64 {
+	var count int64
+	require.NoError(t, f.db.Unscoped().Model(model).Count(&count).Error)
+	return count
+}
+
+// TestRetentionService_RunOnce 测试试运行只统计，正式运行按相同数量删除
+func TestRetentionService_RunOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		dryRun bool
+		raw    int64
+	}{
+		{"dry run", true, 8},
+		{"run", false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRetentionFixture(t)
+			assert.Equal(t, map[string]int64{
+				"raw": 5, "rollup_1m": 2, "resolved_alerts": 1,
+				"deleted_sensor_data": 0, "deleted_alerts": 0, "deleted_alert_rules": 0,
+			}, f.run(t, tt.dryRun))
+			assert.Equal(t, tt.raw, f.count(t, &models.UnifiedSensorData{}))
+		})
+	}
+}
+
+// TestRetentionService_RawData 测试原始数据保留：尚未汇总的过期数据暂不删除，软删除超过宽限期的数据彻底删除
+func TestRetentionService_RawData(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *retentionFixture)
+		raw     int64
+		purged  int64
+		remains func(f *retentionFixture) []uint64
+	}{
+		{
+			name:    "unrolled data kept",
+			setup:   func(t *testing.T, f *retentionFixture) {},
+			raw:     5,
+			remains: func(f *retentionFixture) []uint64 { return []uint64{f.recent.ID, f.deleted.ID, f.unrolled.ID} },
+		},
+		{
+			name: "rollup caught up",
+			setup: func(t *testing.T, f *retentionFixture) {
+				require.NoError(t, f.db.Model(&models.SensorDataRollupState{}).Where("name = ?", "sensor_data").Update("last_id", f.unrolled.ID).Error)
+			},
+			raw:     6,
+			remains: func(f *retentionFixture) []uint64 { return []uint64{f.recent.ID, f.deleted.ID} },
+		},
+		{
+			name: "soft deleted past grace period",
+			setup: func(t *testing.T, f *retentionFixture) {
+				require.NoError(t, f.db.Unscoped().Model(f.deleted).Update("deleted_at", f.old).Error)
+			},
+			raw:     5,
+			purged:  1,
+			remains: func(f *retentionFixture) []uint64 { return []uint64{f.recent.ID, f.unrolled.ID} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRetentionFixture(t)
+			tt.setup(t, f)
+			counts := f.run(t, false)
+			assert.Equal(t, tt.raw, counts["raw"])
+			assert.Equal(t, tt.purged, counts["deleted_sensor_data"])
+
+			var ids []uint64
+			require.NoError(t, f.db.Unscoped().Model(&models.UnifiedSensorData{}).Order("id").Pluck("id", &ids).Error)
+			assert.Equal(t, tt.remains(f), ids)
+		})
+	}
+}
+
+// TestRetentionService_RollupsAndAlerts 测试过期汇总清理，已解决的过期告警连同历史和通知记录删除，未解决的保留
+func TestRetentionService_RollupsAndAlerts(t *testing.T) {
+	f := newRetentionFixture(t)
+	f.run(t, false)
+
+	tests := []struct {
+		name  string
+		model interface{}
+		want  int64
+	}{
+		{"rollup_1m", &models.SensorDataRollup1m{}, 1},
+		{"alerts", &models.Alert{}, 1},
+		{"alert_history", &models.AlertHistory{}, 1},
+		{"notification_logs", &models.NotificationLog{}, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, f.count(t, tt.model), tt.name)
+	}
+}
+
+// TestRetentionService_Archive 测试归档文件由每批一个gzip分段组成，可整体读出
+func TestRetentionService_Archive(t *testing.T) {
+	f := newRetentionFixture(t)
+	f.run(t, false)
+
+	file, err := os.Open(filepath.Join(f.archiveDir, "unified_sensor_data", time.Now().Format("2006-01-02")+".ndjson.gz"))
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	lines := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		assert.Contains(t, scanner.Text(), testutil.DeviceID1)
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, 5, lines)
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrRoleNotFound 角色不存在
+	ErrRoleNotFound = errors.New("角色不存在")
+	// ErrRoleNameExists 角色名称已存在
+	ErrRoleNameExists = errors.New("角色名称已存在")
+	// ErrRoleInUse 角色仍分配给用户
+	ErrRoleInUse = errors.New("角色仍分配给用户，无法删除")
+)
+
+// RoleService 角色服务接口
+type RoleService interface {
+	CreateRole(ctx context.Context, req *models.RoleCreateRequest) (*models.Role, error)
+	GetRole(ctx context.Context, id uint64) (*models.Role, error)
+	UpdateRole(ctx context.Context, id uint64, req *models.RoleUpdateRequest) (*models.Role, error)
+	DeleteRole(ctx context.Context, id uint64) error
+	ListRoles(ctx context.Context, req *models.RoleListRequest) (*models.RoleListResponse, error)
+	GetUserRoles(ctx context.Context, userID uint64) ([]models.Role, error)
+	AssignUserRoles(ctx context.Context, userID uint64, roleIDs []uint64) error
+	ValidateRoleIDs(ctx context.Context, roleIDs []uint64) error
+	GetUserPermissions(ctx context.Context, userID uint64) ([]string, error)
+}
+
+// roleService 角色服务实现
+type roleService struct {
+	roleRepo repositories.RoleRepository
+	logger   utils.Logger
+}
+
+// NewRoleService 创建角色服务
+func NewRoleService(roleRepo repositories.RoleRepository, logger utils.Logger) RoleService {
+	return &roleService{
+		roleRepo: roleRepo,
+		logger:   logger,
+	}
+}
+
+// CreateRole 创建角色
+func (s *roleService) CreateRole(ctx context.Context, req *models.RoleCreateRequest) (*models.Role, error) {
+	existing, err := s.roleRepo.GetByName(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrRoleNameExists
+	}
+
+	permissions, err := encodePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Permissions: permissions,
+	}
+	if req.Description != "" {
+		role.Description = &req.Description
+	}
+	if err := s.roleRepo.Create(ctx, role); err != nil {
+		s.logger.Error("创建角色失败", utils.ErrorField(err), utils.String("name", req.Name))
+		return nil, err
+	}
+
+	s.logger.Info("角色创建成功", utils.String("name", role.Name))
+	return role, nil
+}
+
+// GetRole 获取角色
+func (s *roleService) GetRole(ctx context.Context, id uint64) (*models.Role, error) {
+	role, err := s.roleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// UpdateRole 更新角色
+func (s *roleService) UpdateRole(ctx context.Context, id uint64, req *models.RoleUpdateRequest) (*models.Role, error) {
+	role, err := s.GetRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != role.Name {
+		existing, err := s.roleRepo.GetByName(ctx, *req.Name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrRoleNameExists
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Permissions != nil {
+		permissions, err := encodePermissions(req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		updates["permissions"] = *permissions
+	}
+
+	if len(updates) > 0 {
+		if err := s.roleRepo.Update(ctx, id, updates); err != nil {
+			s.logger.Error("更新角色失败", utils.ErrorField(err), utils.Int64("role_id", int64(id)))
+			return nil, err
+		}
+		s.logger.Info("角色更新成功", utils.Int64("role_id", int64(id)))
+	}
+	return s.GetRole(ctx, id)
+}
+
+// DeleteRole 删除角色，仍分配给用户的角色不允许删除
+func (s *roleService) DeleteRole(ctx context.Context, id uint64) error {
+	if _, err := s.GetRole(ctx, id); err != nil {
+		return err
+	}
+
+	count, err := s.roleRepo.CountUsers(ctx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.roleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.logger.Info("角色删除成功", utils.Int64("role_id", int64(id)))
+	return nil
+}
+
+// ListRoles 分页列出角色
+func (s *roleService) ListRoles(ctx context.Context, req *models.RoleListRequest) (*models.RoleListResponse, error) {
+	roles, total, err := s.roleRepo.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RoleListResponse{
+		Roles:    roles,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}, nil
+}
+
+// GetUserRoles 获取用户的角色
+func (s *roleService) GetUserRoles(ctx context.Context, userID uint64) ([]models.Role, error) {
+	return s.roleRepo.GetUserRoles(ctx, userID)
+}
+
+// AssignUserRoles 替换用户的角色
+func (s *roleService) AssignUserRoles(ctx context.Context, userID uint64, roleIDs []uint64) error {
+	roleIDs = uniqueIDs(roleIDs)
+	if err := s.ValidateRoleIDs(ctx, roleIDs); err != nil {
+		return err
+	}
+	if err := s.roleRepo.SetUserRoles(ctx, userID, roleIDs); err != nil {
+		return err
+	}
+	s.logger.Info("用户角色已更新", utils.Int64("user_id", int64(userID)), utils.Any("role_ids", roleIDs))
+	return nil
+}
+
+// ValidateRoleIDs 校验角色是否全部存在
+func (s *roleService) ValidateRoleIDs(ctx context.Context, roleIDs []uint64) error {
+	roleIDs = uniqueIDs(roleIDs)
+	roles, err := s.roleRepo.GetByIDs(ctx, roleIDs)
+	if err != nil {
+		return err
+	}
+	if len(roles) != len(roleIDs) {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// GetUserPermissions 汇总用户全部角色的权限
+func (s *roleService) GetUserPermissions(ctx context.Context, userID uint64) ([]string, error) {
+	roles, err := s.roleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var permissions []string
+	for i := range roles {
+		for _, p := range roles[i].PermissionList() {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// encodePermissions 校验并序列化权限列表
+func encodePermissions(permissions []string) (*string, error) {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return nil, fmt.Errorf("不支持的权限: %s", p)
+		}
+	}
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+// uniqueIDs 去除重复ID并保持顺序
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	result := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
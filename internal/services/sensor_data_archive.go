@@ -0,0 +1,439 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 归档任务默认参数（配置缺省时使用）
+const (
+	defaultArchiveInterval = time.Hour
+	// archiveMaxDaysPerRun 单次运行最多归档的天数，首次启用时分多次补齐历史数据
+	archiveMaxDaysPerRun = 31
+	archiveRunTimeout    = 30 * time.Minute
+	archiveRestoreBatch  = 500
+	archiveManifestName  = "manifest.json"
+)
+
+// SensorDataArchiveService 传感器数据冷归档服务接口
+type SensorDataArchiveService interface {
+	// RunOnce 按天顺序归档已结束且超过延迟天数的数据，返回归档的天数
+	RunOnce(ctx context.Context) (int, error)
+	// ArchivedUntil 返回已归档到的时间，此前的原始数据都已写入归档
+	ArchivedUntil() (time.Time, error)
+	// Restore 将[from, to]内各天（UTC日期）的归档数据写回原始数据表，deviceID为空时恢复全部设备
+	Restore(ctx context.Context, from, to time.Time, deviceID string) (*models.ArchiveRestoreResult, error)
+	Start()
+	Stop()
+}
+
+// sensorDataArchiveService 传感器数据冷归档服务实现
+type sensorDataArchiveService struct {
+	archiveRepo repositories.SensorDataArchiveRepository
+	logger      utils.Logger
+	dir         string
+	interval    time.Duration
+	delayDays   int
+
+	runMu   sync.Mutex
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSensorDataArchiveService 创建传感器数据冷归档服务
+func NewSensorDataArchiveService(cfg config.ArchiveConfig, archiveRepo repositories.SensorDataArchiveRepository, logger utils.Logger) SensorDataArchiveService {
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+	delayDays := cfg.DelayDays
+	if delayDays < 0 {
+		delayDays = 0
+	}
+
+	return &sensorDataArchiveService{
+		archiveRepo: archiveRepo,
+		logger:      logger,
+		dir:         cfg.Dir,
+		interval:    interval,
+		delayDays:   delayDays,
+		done:        make(chan struct{}),
+	}
+}
+
+// RunOnce 从上次归档到的日期开始，跳过没有数据的日期，逐天归档直到截止日期
+func (s *sensorDataArchiveService) RunOnce(ctx context.Context) (int, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := models.BucketStart(time.Now().AddDate(0, 0, -s.delayDays), 24*time.Hour)
+
+	archived := 0
+	day := manifest.ArchivedUntil
+	for archived < archiveMaxDaysPerRun {
+		next, err := s.archiveRepo.NextTimestamp(ctx, day)
+		if err != nil {
+			return archived, err
+		}
+		if next == nil || !models.BucketStart(*next, 24*time.Hour).Before(cutoff) {
+			if cutoff.After(manifest.ArchivedUntil) {
+				manifest.ArchivedUntil = cutoff
+				if err := s.saveManifest(manifest); err != nil {
+					return archived, err
+				}
+			}
+			break
+		}
+
+		day = models.BucketStart(*next, 24*time.Hour)
+		summary, err := s.archiveDay(ctx, day)
+		if err != nil {
+			return archived, err
+		}
+		manifest.Days = upsertArchiveDay(manifest.Days, *summary)
+		manifest.ArchivedUntil = day.Add(24 * time.Hour)
+		if err := s.saveManifest(manifest); err != nil {
+			return archived, err
+		}
+		archived++
+		day = manifest.ArchivedUntil
+	}
+	return archived, nil
+}
+
+// archiveDay 将一天的数据按设备写入归档文件，最后写入当天的清单
+func (s *sensorDataArchiveService) archiveDay(ctx context.Context, day time.Time) (*models.ArchiveDaySummary, error) {
+	date := day.UTC().Format(models.ArchiveDateLayout)
+	deviceIDs, err := s.archiveRepo.ListDeviceIDs(ctx, day, day.Add(24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, date), 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+
+	dayManifest := &models.ArchiveDayManifest{Version: models.ArchiveManifestVersion, Date: date, CreatedAt: time.Now()}
+	summary := &models.ArchiveDaySummary{Date: date, Devices: len(deviceIDs)}
+	for _, deviceID := range deviceIDs {
+		readings, err := s.archiveRepo.ListReadings(ctx, deviceID, day, day.Add(24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		file, err := s.writeArchiveFile(filepath.ToSlash(filepath.Join(date, url.PathEscape(deviceID)+".ndjson.gz")), readings)
+		if err != nil {
+			return nil, err
+		}
+		file.DeviceID = deviceID
+		dayManifest.Files = append(dayManifest.Files, *file)
+		summary.Records += file.Records
+	}
+
+	if err := writeJSONFile(filepath.Join(s.dir, date, archiveManifestName), dayManifest); err != nil {
+		return nil, err
+	}
+	s.logger.Info("传感器数据已归档",
+		utils.String("date", date),
+		utils.Int("devices", summary.Devices),
+		utils.Int64("records", summary.Records))
+	return summary, nil
+}
+
+// writeArchiveFile 写入单个设备一天的归档文件，先写临时文件再改名，避免留下不完整的文件
+func (s *sensorDataArchiveService) writeArchiveFile(path string, readings []models.UnifiedSensorData) (*models.ArchiveFile, error) {
+	fullPath := filepath.Join(s.dir, filepath.FromSlash(path))
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hash)}
+	writer := gzip.NewWriter(counter)
+	encoder := json.NewEncoder(writer)
+	for i := range readings {
+		if err := encoder.Encode(&readings[i]); err != nil {
+			return nil, fmt.Errorf("写入归档文件失败: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return nil, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+
+	file := &models.ArchiveFile{
+		Path:    path,
+		Records: int64(len(readings)),
+		Size:    counter.n,
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+	}
+	if len(readings) > 0 {
+		file.FirstTimestamp = readings[0].Timestamp
+		file.LastTimestamp = readings[len(readings)-1].Timestamp
+	}
+	return file, nil
+}
+
+// ArchivedUntil 读取总清单中的已归档时间
+func (s *sensorDataArchiveService) ArchivedUntil() (time.Time, error) {
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return manifest.ArchivedUntil, nil
+}
+
+// Restore 按天读取清单，校验文件摘要后逐批写回，已存在的数据（按ID）跳过
+func (s *sensorDataArchiveService) Restore(ctx context.Context, from, to time.Time, deviceID string) (*models.ArchiveRestoreResult, error) {
+	result := &models.ArchiveRestoreResult{}
+	for day := models.BucketStart(from, 24*time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		date := day.UTC().Format(models.ArchiveDateLayout)
+		var dayManifest models.ArchiveDayManifest
+		err := readJSONFile(filepath.Join(s.dir, date, archiveManifestName), &dayManifest)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		result.Days++
+		for _, file := range dayManifest.Files {
+			if deviceID != "" && file.DeviceID != deviceID {
+				continue
+			}
+			records, restored, err := s.restoreFile(ctx, &file)
+			if err != nil {
+				return result, err
+			}
+			result.Files++
+			result.Records += records
+			result.Restored += restored
+		}
+	}
+	return result, nil
+}
+
+// restoreFile 恢复单个归档文件
+func (s *sensorDataArchiveService) restoreFile(ctx context.Context, file *models.ArchiveFile) (int64, int64, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(file.Path))
+	if err := verifyArchiveFile(path, file.SHA256); err != nil {
+		return 0, 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer f.Close()
+	reader, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("读取归档文件%s失败: %w", file.Path, err)
+	}
+	defer reader.Close()
+
+	var records, restored int64
+	batch := make([]models.UnifiedSensorData, 0, archiveRestoreBatch)
+	flush := func() error {
+		count, err := s.archiveRepo.Restore(ctx, batch)
+		restored += count
+		batch = batch[:0]
+		return err
+	}
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var data models.UnifiedSensorData
+		if err := decoder.Decode(&data); err == io.EOF {
+			break
+		} else if err != nil {
+			return records, restored, fmt.Errorf("解析归档文件%s失败: %w", file.Path, err)
+		}
+		records++
+		batch = append(batch, data)
+		if len(batch) == archiveRestoreBatch {
+			if err := flush(); err != nil {
+				return records, restored, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return records, restored, err
+	}
+	return records, restored, nil
+}
+
+// verifyArchiveFile 校验归档文件的SHA-256摘要
+func verifyArchiveFile(path, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("读取归档文件失败: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("归档文件%s校验失败: 摘要为%s，清单中为%s", path, actual, expected)
+	}
+	return nil
+}
+
+// loadManifest 读取总清单，不存在时返回空清单
+func (s *sensorDataArchiveService) loadManifest() (*models.ArchiveManifest, error) {
+	manifest := &models.ArchiveManifest{Version: models.ArchiveManifestVersion}
+	err := readJSONFile(filepath.Join(s.dir, archiveManifestName), manifest)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// saveManifest 保存总清单
+func (s *sensorDataArchiveService) saveManifest(manifest *models.ArchiveManifest) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	manifest.Version = models.ArchiveManifestVersion
+	manifest.UpdatedAt = time.Now()
+	return writeJSONFile(filepath.Join(s.dir, archiveManifestName), manifest)
+}
+
+// upsertArchiveDay 按日期更新或插入一天的概要，保持日期顺序
+func upsertArchiveDay(days []models.ArchiveDaySummary, summary models.ArchiveDaySummary) []models.ArchiveDaySummary {
+	for i := range days {
+		if days[i].Date == summary.Date {
+			days[i] = summary
+			return days
+		}
+	}
+	days = append(days, summary)
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// readJSONFile 读取JSON文件
+func readJSONFile(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析%s失败: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile 先写临时文件再改名，保证清单文件完整
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("写入%s失败: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入%s失败: %w", path, err)
+	}
+	return nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Start 启动归档协程
+func (s *sensorDataArchiveService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	s.wg.Add(1)
+	go s.archiveLoop()
+
+	s.logger.Info("传感器数据归档任务已启动",
+		utils.String("dir", s.dir),
+		utils.Duration("interval", s.interval))
+}
+
+// Stop 停止归档协程
+func (s *sensorDataArchiveService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("传感器数据归档任务已停止")
+}
+
+// archiveLoop 定期运行归档任务
+func (s *sensorDataArchiveService) archiveLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), archiveRunTimeout)
+			if _, err := s.RunOnce(ctx); err != nil {
+				s.logger.Error("归档传感器数据失败", utils.ErrorField(err))
+			}
+			cancel()
+		}
+	}
+}
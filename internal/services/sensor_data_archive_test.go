@@ -0,0 +1,210 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// archiveFixture 10天前（设备1两条、设备2一条）、9天前（设备1一条）和7天前（设备2一条）的原始数据，今天另有一条不归档的数据
+type archiveFixture struct {
+	db      *gorm.DB
+	archive SensorDataArchiveService
+	dir     string
+	day0    time.Time
+}
+
+// newArchiveFixture 写入原始数据，归档延迟1天
+func newArchiveFixture(t *testing.T) *archiveFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	f := &archiveFixture{db: db, dir: t.TempDir(), day0: models.BucketStart(time.Now().AddDate(0, 0, -10), 24*time.Hour)}
+	f.archive = NewSensorDataArchiveService(config.ArchiveConfig{Dir: f.dir, DelayDays: 1},
+		repositories.NewSensorDataArchiveRepository(db, logger), logger)
+
+	f.reading(t, testutil.DeviceID1, f.day0.Add(time.Hour), 10)
+	f.reading(t, testutil.DeviceID1, f.day0.Add(2*time.Hour), 20)
+	f.reading(t, testutil.DeviceID2, f.day0.Add(3*time.Hour), 30)
+	f.reading(t, testutil.DeviceID1, f.day0.Add(24*time.Hour+time.Minute), 40)
+	f.reading(t, testutil.DeviceID2, f.day0.Add(72*time.Hour), 50)
+	f.reading(t, testutil.DeviceID1, time.Now(), 60)
+	return f
+}
+
+// reading 写入一条带扩展指标和消息ID的原始数据
+func (f *archiveFixture) reading(t *testing.T, deviceID string, at time.Time, pm25 float64) {
+	value := pm25
+	extended := `{"tvoc":0.3}`
+	messageID := at.Format(time.RFC3339Nano) + deviceID
+	require.NoError(t, f.db.Create(&models.UnifiedSensorData{
+		DeviceID:     deviceID,
+		DeviceType:   models.DeviceTypeAirQuality,
+		Timestamp:    at,
+		PM25:         &value,
+		ExtendedData: &extended,
+		MessageID:    &messageID,
+	}).Error)
+}
+
+// runOnce 归档后删除已归档的原始数据，返回归档到的时间
+func (f *archiveFixture) runOnce(t *testing.T) time.Time {
+	_, err := f.archive.RunOnce(context.Background())
+	require.NoError(t, err)
+	archivedUntil, err := f.archive.ArchivedUntil()
+	require.NoError(t, err)
+	require.NoError(t, f.db.Unscoped().Where("timestamp < ?", archivedUntil).Delete(&models.UnifiedSensorData{}).Error)
+	return archivedUntil
+}
+
+// readJSON 读取归档目录中的清单文件
+func (f *archiveFixture) readJSON(t *testing.T, path string, v interface{}) {
+	content, err := os.ReadFile(filepath.Join(f.dir, filepath.FromSlash(path)))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, v))
+}
+
+// TestSensorDataArchiveService_RunOnce 测试跳过无数据日期逐天归档到昨天，没有新的已结束日期时不重复归档
+func TestSensorDataArchiveService_RunOnce(t *testing.T) {
+	f := newArchiveFixture(t)
+	yesterday := models.BucketStart(time.Now().AddDate(0, 0, -1), 24*time.Hour)
+
+	for i, want := range []int{3, 0} {
+		days, err := f.archive.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, want, days, "run %d", i+1)
+		archivedUntil, err := f.archive.ArchivedUntil()
+		require.NoError(t, err)
+		assert.True(t, yesterday.Equal(archivedUntil), "run %d", i+1)
+	}
+}
+
+// TestSensorDataArchiveService_Manifest 测试总清单按天汇总，每天的清单按设备列出文件及校验和
+func TestSensorDataArchiveService_Manifest(t *testing.T) {
+	f := newArchiveFixture(t)
+	_, err := f.archive.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	var manifest models.ArchiveManifest
+	f.readJSON(t, "manifest.json", &manifest)
+	require.Len(t, manifest.Days, 3)
+	assert.Equal(t, f.day0.UTC().Format(models.ArchiveDateLayout), manifest.Days[0].Date)
+	assert.Equal(t, 2, manifest.Days[0].Devices)
+	assert.Equal(t, int64(3), manifest.Days[0].Records)
+
+	var day models.ArchiveDayManifest
+	f.readJSON(t, manifest.Days[0].Date+"/manifest.json", &day)
+	tests := []struct {
+		deviceID string
+		records  int64
+	}{
+		{testutil.DeviceID1, 2},
+		{testutil.DeviceID2, 1},
+	}
+	require.Len(t, day.Files, len(tests))
+	for i, tt := range tests {
+		file := day.Files[i]
+		assert.Equal(t, tt.deviceID, file.DeviceID)
+		assert.Equal(t, tt.records, file.Records)
+		assert.Len(t, file.SHA256, 64)
+		assert.FileExists(t, filepath.Join(f.dir, filepath.FromSlash(file.Path)))
+	}
+}
+
+// TestSensorDataArchiveService_Restore 测试按日期范围和设备恢复，重复恢复不产生重复数据，归档文件被改动时拒绝恢复
+func TestSensorDataArchiveService_Restore(t *testing.T) {
+	tests := []struct {
+		name     string
+		days     int // 恢复范围的天数（含首尾），从10天前开始
+		deviceID string
+		setup    func(t *testing.T, f *archiveFixture)
+		wantErr  bool
+		result   models.ArchiveRestoreResult
+	}{
+		{name: "device over two days", days: 2, deviceID: testutil.DeviceID1,
+			result: models.ArchiveRestoreResult{Days: 2, Files: 2, Records: 3, Restored: 3}},
+		{name: "all devices", days: 4,
+			result: models.ArchiveRestoreResult{Days: 3, Files: 4, Records: 5, Restored: 5}},
+		{name: "repeated restore", days: 2, deviceID: testutil.DeviceID1,
+			setup: func(t *testing.T, f *archiveFixture) {
+				_, err := f.archive.Restore(context.Background(), f.day0, f.day0.Add(24*time.Hour), testutil.DeviceID1)
+				require.NoError(t, err)
+			},
+			result: models.ArchiveRestoreResult{Days: 2, Files: 2, Records: 3, Restored: 0}},
+		{name: "corrupted file", days: 1, deviceID: testutil.DeviceID2,
+			setup: func(t *testing.T, f *archiveFixture) {
+				var day models.ArchiveDayManifest
+				f.readJSON(t, f.day0.UTC().Format(models.ArchiveDateLayout)+"/manifest.json", &day)
+				require.NoError(t, os.WriteFile(filepath.Join(f.dir, filepath.FromSlash(day.Files[1].Path)), []byte("corrupted"), 0644))
+			},
+			wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newArchiveFixture(t)
+			f.runOnce(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			result, err := f.archive.Restore(context.Background(), f.day0, f.day0.AddDate(0, 0, tt.days-1), tt.deviceID)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, *result)
+		})
+	}
+}
+
+// TestSensorDataArchiveService_RestoreRoundTrip 测试恢复的数据与归档前一致
+func TestSensorDataArchiveService_RestoreRoundTrip(t *testing.T) {
+	f := newArchiveFixture(t)
+	var original []models.UnifiedSensorData
+	require.NoError(t, f.db.Where("device_id = ? AND timestamp < ?", testutil.DeviceID1, f.day0.Add(48*time.Hour)).Order("id").Find(&original).Error)
+	archivedUntil := f.runOnce(t)
+
+	_, err := f.archive.Restore(context.Background(), f.day0, f.day0.Add(24*time.Hour), testutil.DeviceID1)
+	require.NoError(t, err)
+	var restored []models.UnifiedSensorData
+	require.NoError(t, f.db.Where("timestamp < ?", archivedUntil).Order("id").Find(&restored).Error)
+	require.Len(t, restored, len(original))
+	for i := range original {
+		assert.Equal(t, original[i].ID, restored[i].ID)
+		assert.True(t, original[i].Timestamp.Equal(restored[i].Timestamp))
+		assert.Equal(t, *original[i].PM25, *restored[i].PM25)
+		assert.Equal(t, *original[i].ExtendedData, *restored[i].ExtendedData)
+		assert.Equal(t, *original[i].MessageID, *restored[i].MessageID)
+	}
+}
+
+// TestRetentionService_KeepsUnarchivedData 测试启用归档时，未归档的原始数据不会被保留策略删除
+func TestRetentionService_KeepsUnarchivedData(t *testing.T) {
+	f := newArchiveFixture(t)
+	_, err := f.archive.RunOnce(context.Background())
+	require.NoError(t, err)
+	archivedUntil, err := f.archive.ArchivedUntil()
+	require.NoError(t, err)
+	f.reading(t, testutil.DeviceID2, archivedUntil.Add(time.Minute), 70)
+
+	logger := testutil.NewLogger(t)
+	retention := NewRetentionService(config.RetentionConfig{RawDays: 1}, repositories.NewRetentionRepository(f.db, logger), nil, nil, f.archive, logger)
+	results, err := retention.RunOnce(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, int64(5), results[0].Deleted)
+
+	var count int64
+	require.NoError(t, f.db.Model(&models.UnifiedSensorData{}).Where("timestamp >= ?", archivedUntil).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
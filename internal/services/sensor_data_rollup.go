@@ -0,0 +1,306 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/utils"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 汇总任务默认参数（配置缺省时使用）
+const (
+	defaultRollupInterval    = 60 * time.Second
+	defaultRollupBatchSize   = 5000
+	defaultRollupSettleDelay = 30 * time.Second
+	// rollupMaxBatchesPerRun 单次运行最多处理的批数，回填历史数据时分多次完成
+	rollupMaxBatchesPerRun = 20
+	rollupRunTimeout       = 5 * time.Minute
+)
+
+// SensorDataRollupService 传感器数据汇总服务接口
+type SensorDataRollupService interface {
+	// RunOnce 处理新增的原始数据，重新计算受影响的分钟、小时和天汇总，返回处理的数据条数
+	RunOnce(ctx context.Context) (int, error)
+	Start()
+	Stop()
+}
+
+// sensorDataRollupService 传感器数据汇总服务实现
+type sensorDataRollupService struct {
+	rollupRepo  repositories.SensorDataRollupRepository
+	logger      utils.Logger
+	interval    time.Duration
+	batchSize   int
+	settleDelay time.Duration
+
+	runMu   sync.Mutex
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSensorDataRollupService 创建传感器数据汇总服务
+func NewSensorDataRollupService(cfg config.RollupConfig, rollupRepo repositories.SensorDataRollupRepository, logger utils.Logger) SensorDataRollupService {
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultRollupInterval
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRollupBatchSize
+	}
+	settleDelay := time.Duration(cfg.SettleDelay) * time.Second
+	if settleDelay < 0 {
+		settleDelay = defaultRollupSettleDelay
+	}
+
+	return &sensorDataRollupService{
+		rollupRepo:  rollupRepo,
+		logger:      logger,
+		interval:    interval,
+		batchSize:   batchSize,
+		settleDelay: settleDelay,
+		done:        make(chan struct{}),
+	}
+}
+
+// RunOnce 按ID顺序读取新增数据（含迟到数据），只处理入库超过settleDelay的部分；
+// 追上最新数据时推进汇总覆盖时间，查询层据此决定哪些时间桶可从汇总表读取
+func (s *sensorDataRollupService) RunOnce(ctx context.Context) (int, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	state, err := s.rollupRepo.GetState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-s.settleDelay)
+
+	processed := 0
+	for batch := 0; batch < rollupMaxBatchesPerRun; batch++ {
+		changes, err := s.rollupRepo.ListChanges(ctx, state.LastID, s.batchSize)
+		if err != nil {
+			return processed, err
+		}
+		caughtUp := len(changes) < s.batchSize
+		for i := range changes {
+			if changes[i].CreatedAt.After(cutoff) {
+				changes = changes[:i]
+				caughtUp = true
+				break
+			}
+		}
+
+		if len(changes) > 0 {
+			if err := s.recompute(ctx, changes); err != nil {
+				return processed, err
+			}
+			state.LastID = changes[len(changes)-1].ID
+			processed += len(changes)
+		}
+		if caughtUp {
+			state.RolledUntil = cutoff
+		}
+		if err := s.rollupRepo.SaveState(ctx, state); err != nil {
+			return processed, err
+		}
+		if caughtUp {
+			break
+		}
+	}
+	return processed, nil
+}
+
+// recompute 重新计算新增数据所在小时的分钟和小时汇总，再由小时汇总重新计算所在天的汇总
+func (s *sensorDataRollupService) recompute(ctx context.Context, changes []models.SensorDataChange) error {
+	hours := make(map[models.RollupBucketKey]struct{})
+	for i := range changes {
+		hours[models.RollupBucketKey{
+			DeviceID:    changes[i].DeviceID,
+			BucketStart: models.BucketStart(changes[i].Timestamp, time.Hour),
+		}] = struct{}{}
+	}
+
+	days := make(map[models.RollupBucketKey]struct{})
+	for _, key := range sortedBucketKeys(hours) {
+		if err := s.recomputeHour(ctx, key.DeviceID, key.BucketStart); err != nil {
+			return err
+		}
+		days[models.RollupBucketKey{
+			DeviceID:    key.DeviceID,
+			BucketStart: models.BucketStart(key.BucketStart, 24*time.Hour),
+		}] = struct{}{}
+	}
+
+	for _, key := range sortedBucketKeys(days) {
+		if err := s.recomputeDay(ctx, key.DeviceID, key.BucketStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputeHour 由原始数据重新计算设备一小时内的分钟汇总和小时汇总
+func (s *sensorDataRollupService) recomputeHour(ctx context.Context, deviceID string, start time.Time) error {
+	end := start.Add(time.Hour)
+	readings, err := s.rollupRepo.ListReadings(ctx, models.RollupFilter{DeviceIDs: []string{deviceID}}, start, end)
+	if err != nil {
+		return err
+	}
+
+	minutes := newRollupAccumulator()
+	hour := newRollupAccumulator()
+	for i := range readings {
+		minutes.addReading(&readings[i], time.Minute)
+		hour.addReading(&readings[i], time.Hour)
+	}
+
+	if err := s.rollupRepo.Replace(ctx, models.RollupResolutionMinute, deviceID, start, end, minutes.rollups()); err != nil {
+		return err
+	}
+	return s.rollupRepo.Replace(ctx, models.RollupResolutionHour, deviceID, start, end, hour.rollups())
+}
+
+// recomputeDay 由小时汇总重新计算设备一天的汇总
+func (s *sensorDataRollupService) recomputeDay(ctx context.Context, deviceID string, start time.Time) error {
+	end := start.Add(24 * time.Hour)
+	hours, err := s.rollupRepo.Find(ctx, models.RollupResolutionHour, models.RollupFilter{DeviceIDs: []string{deviceID}}, start, end)
+	if err != nil {
+		return err
+	}
+
+	day := newRollupAccumulator()
+	for i := range hours {
+		day.merge(&hours[i], 24*time.Hour)
+	}
+	return s.rollupRepo.Replace(ctx, models.RollupResolutionDay, deviceID, start, end, day.rollups())
+}
+
+// Start 启动汇总协程
+func (s *sensorDataRollupService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+
+	s.wg.Add(1)
+	go s.rollupLoop()
+
+	s.logger.Info("传感器数据汇总任务已启动",
+		utils.Duration("interval", s.interval),
+		utils.Duration("settle_delay", s.settleDelay))
+}
+
+// Stop 停止汇总协程
+func (s *sensorDataRollupService) Stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("传感器数据汇总任务已停止")
+}
+
+// rollupLoop 定期运行汇总任务
+func (s *sensorDataRollupService) rollupLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), rollupRunTimeout)
+			count, err := s.RunOnce(ctx)
+			if err != nil {
+				s.logger.Error("汇总传感器数据失败", utils.ErrorField(err))
+			} else if count > 0 {
+				s.logger.Debug("汇总传感器数据完成", utils.Int("count", count))
+			}
+			cancel()
+		}
+	}
+}
+
+// rollupKey 汇总累加器中的一个设备、指标和时间桶
+type rollupKey struct {
+	deviceID string
+	metric   string
+	bucket   time.Time
+}
+
+// rollupAccumulator 按设备、指标和时间桶累加汇总值
+type rollupAccumulator struct {
+	values map[rollupKey]*models.SensorDataRollup
+}
+
+// newRollupAccumulator 创建汇总累加器
+func newRollupAccumulator() *rollupAccumulator {
+	return &rollupAccumulator{values: make(map[rollupKey]*models.SensorDataRollup)}
+}
+
+// get 获取或创建时间桶的汇总
+func (a *rollupAccumulator) get(deviceID, metric string, bucket time.Time) *models.SensorDataRollup {
+	key := rollupKey{deviceID: deviceID, metric: metric, bucket: bucket}
+	rollup, ok := a.values[key]
+	if !ok {
+		rollup = &models.SensorDataRollup{DeviceID: deviceID, Metric: metric, BucketStart: bucket}
+		a.values[key] = rollup
+	}
+	return rollup
+}
+
+// addReading 累加一条原始数据的全部指标及读数条数
+func (a *rollupAccumulator) addReading(reading *models.UnifiedSensorData, bucket time.Duration) {
+	start := models.BucketStart(reading.Timestamp, bucket)
+	a.get(reading.DeviceID, models.RollupMetricReadings, start).Add(1, reading.Timestamp, reading.DeviceType)
+	for _, metric := range reading.GetAvailableMetrics() {
+		if value := reading.GetMetricValue(metric); value != nil {
+			a.get(reading.DeviceID, metric, start).Add(*value, reading.Timestamp, reading.DeviceType)
+		}
+	}
+}
+
+// merge 合并细粒度汇总到更粗的时间桶
+func (a *rollupAccumulator) merge(rollup *models.SensorDataRollup, bucket time.Duration) {
+	a.get(rollup.DeviceID, rollup.Metric, models.BucketStart(rollup.BucketStart, bucket)).Merge(rollup)
+}
+
+// rollups 返回累加结果
+func (a *rollupAccumulator) rollups() []models.SensorDataRollup {
+	result := make([]models.SensorDataRollup, 0, len(a.values))
+	for _, rollup := range a.values {
+		result = append(result, *rollup)
+	}
+	return result
+}
+
+// sortedBucketKeys 按设备和时间排序时间桶，保证重算顺序稳定
+func sortedBucketKeys(keys map[models.RollupBucketKey]struct{}) []models.RollupBucketKey {
+	result := make([]models.RollupBucketKey, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceID != result[j].DeviceID {
+			return result[i].DeviceID < result[j].DeviceID
+		}
+		return result[i].BucketStart.Before(result[j].BucketStart)
+	})
+	return result
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"air-quality-server/internal/config"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// rollupFixture 汇总测试环境：设备1在第一天的两个小时和第二天各有PM2.5数据，设备2在第一天有一条；
+// raw直接扫描原始数据，rollups优先读取汇总表
+type rollupFixture struct {
+	db      *gorm.DB
+	repo    repositories.SensorDataRollupRepository
+	rollup  SensorDataRollupService
+	raw     UnifiedSensorDataService
+	rollups UnifiedSensorDataService
+	base    time.Time
+}
+
+// newRollupFixture 写入原始数据，汇总批大小为2
+func newRollupFixture(t *testing.T) *rollupFixture {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+	deviceRepo := repositories.NewDeviceRepository(db, logger)
+	f := &rollupFixture{
+		db:   db,
+		repo: repositories.NewSensorDataRollupRepository(db, logger),
+		base: time.Unix(1700000000/86400*86400, 0),
+	}
+	f.rollup = NewSensorDataRollupService(config.RollupConfig{BatchSize: 2}, f.repo, logger)
+	f.raw = NewUnifiedSensorDataService(dataRepo, deviceRepo, nil, nil, nil, nil, nil, "", logger)
+	f.rollups = NewUnifiedSensorDataService(dataRepo, deviceRepo, nil, nil, nil, nil, f.repo, "", logger)
+
+	f.reading(t, testutil.DeviceID1, 10*time.Second, 10)
+	f.reading(t, testutil.DeviceID1, 50*time.Second, 20)
+	f.reading(t, testutil.DeviceID1, 90*time.Minute, 40)
+	f.reading(t, testutil.DeviceID1, 26*time.Hour, 60)
+	f.reading(t, testutil.DeviceID2, 5*time.Minute, 30)
+	return f
+}
+
+// reading 写入一条PM2.5数据
+func (f *rollupFixture) reading(t *testing.T, deviceID string, offset time.Duration, pm25 float64) {
+	value := pm25
+	require.NoError(t, f.db.Create(&models.UnifiedSensorData{
+		DeviceID:   deviceID,
+		DeviceType: models.DeviceTypeAirQuality,
+		Timestamp:  f.base.Add(offset),
+		PM25:       &value,
+	}).Error)
+}
+
+// runOnce 执行一次汇总，返回处理的数据条数
+func (f *rollupFixture) runOnce(t *testing.T) int {
+	count, err := f.rollup.RunOnce(context.Background())
+	require.NoError(t, err)
+	return count
+}
+
+// TestSensorDataRollupService_RunOnce 测试分钟/小时/天汇总的计算，迟到数据使已汇总的时间桶重新计算
+func TestSensorDataRollupService_RunOnce(t *testing.T) {
+	f := newRollupFixture(t)
+	device1 := models.RollupFilter{DeviceIDs: []string{testutil.DeviceID1}, Metrics: []string{"pm25"}}
+
+	// 各步骤依次执行：批大小为2时单次运行分多批处理完全部数据，之后写入一条迟到数据
+	steps := []struct {
+		name       string
+		apply      func(t *testing.T)
+		processed  int
+		resolution models.RollupResolution
+		buckets    []models.SensorDataRollup // 只比较Avg、Min、Max、Last和Count
+	}{
+		{"minutes", func(t *testing.T) {}, 5, models.RollupResolutionMinute, []models.SensorDataRollup{
+			{Avg: 15, Min: 10, Max: 20, Last: 20, Count: 2},
+			{Avg: 40, Min: 40, Max: 40, Last: 40, Count: 1},
+			{Avg: 60, Min: 60, Max: 60, Last: 60, Count: 1},
+		}},
+		{"days", func(t *testing.T) {}, 0, models.RollupResolutionDay, []models.SensorDataRollup{
+			{Avg: 70.0 / 3, Min: 10, Max: 40, Last: 40, Count: 3},
+			{Avg: 60, Min: 60, Max: 60, Last: 60, Count: 1},
+		}},
+		{"late reading", func(t *testing.T) { f.reading(t, testutil.DeviceID1, 80*time.Minute, 100) }, 1, models.RollupResolutionDay, []models.SensorDataRollup{
+			{Avg: 42.5, Min: 10, Max: 100, Last: 40, Count: 4},
+			{Avg: 60, Min: 60, Max: 60, Last: 60, Count: 1},
+		}},
+	}
+	for _, step := range steps {
+		step.apply(t)
+		assert.Equal(t, step.processed, f.runOnce(t), step.name)
+		buckets, err := f.repo.Find(context.Background(), step.resolution, device1, f.base, f.base.Add(48*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, buckets, len(step.buckets), step.name)
+		for i, want := range step.buckets {
+			got := buckets[i]
+			assert.InDelta(t, want.Avg, got.Avg, 1e-9, step.name)
+			assert.InDelta(t, want.Min, got.Min, 1e-9, step.name)
+			assert.InDelta(t, want.Max, got.Max, 1e-9, step.name)
+			assert.InDelta(t, want.Last, got.Last, 1e-9, step.name)
+			assert.Equal(t, want.Count, got.Count, step.name)
+		}
+	}
+}
+
+// TestUnifiedSensorDataService_RollupQueries 测试读取汇总的聚合与统计结果和扫描原始数据一致，包括未对齐的起止时间
+func TestUnifiedSensorDataService_RollupQueries(t *testing.T) {
+	f := newRollupFixture(t)
+	f.runOnce(t)
+	ctx := context.Background()
+	deviceIDs := []string{testutil.DeviceID1, testutil.DeviceID2}
+
+	windows := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"aligned", f.base, f.base.Add(48*time.Hour - time.Second)},
+		{"unaligned", f.base.Add(30 * time.Second), f.base.Add(26*time.Hour + 30*time.Second)},
+	}
+	for _, window := range windows {
+		for _, interval := range []string{"1m", "1h", "1d"} {
+			for _, aggregation := range []string{"avg", "max", "min", "sum"} {
+				t.Run(fmt.Sprintf("%s %s %s", window.name, interval, aggregation), func(t *testing.T) {
+					query := &models.AirQualityDataQuery{
+						DeviceIDs:   deviceIDs,
+						Metrics:     []string{"pm25"},
+						StartTime:   window.start.Unix(),
+						EndTime:     window.end.Unix(),
+						Interval:    interval,
+						Aggregation: aggregation,
+						Limit:       5000,
+					}
+					expected, err := f.raw.AggregateData(ctx, query)
+					require.NoError(t, err)
+					actual, err := f.rollups.AggregateData(ctx, query)
+					require.NoError(t, err)
+					require.Len(t, actual, len(expected))
+					for i := range expected {
+						assert.Equal(t, expected[i].DeviceID, actual[i].DeviceID)
+						require.Len(t, actual[i].Points, len(expected[i].Points))
+						for j := range expected[i].Points {
+							assert.True(t, expected[i].Points[j].Time.Equal(actual[i].Points[j].Time))
+							assert.InDelta(t, expected[i].Points[j].Value, actual[i].Points[j].Value, 1e-9)
+							assert.Equal(t, expected[i].Points[j].Count, actual[i].Points[j].Count)
+						}
+					}
+				})
+			}
+		}
+
+		t.Run(window.name+" statistics", func(t *testing.T) {
+			expected, err := f.raw.GetMultiDeviceStatistics(ctx, deviceIDs, window.start.Unix(), window.end.Unix())
+			require.NoError(t, err)
+			actual, err := f.rollups.GetMultiDeviceStatistics(ctx, deviceIDs, window.start.Unix(), window.end.Unix())
+			require.NoError(t, err)
+			assert.Equal(t, expected.DataCount, actual.DataCount)
+			assert.InDelta(t, expected.PM25Avg, actual.PM25Avg, 1e-9)
+			assert.InDelta(t, expected.PM25Min, actual.PM25Min, 1e-9)
+			assert.InDelta(t, expected.PM25Max, actual.PM25Max, 1e-9)
+
+			typeStats, err := f.rollups.GetDeviceTypeStatistics(ctx, models.DeviceTypeAirQuality, window.start.Unix(), window.end.Unix())
+			require.NoError(t, err)
+			assert.Equal(t, expected.DataCount, typeStats.DataCount)
+		})
+	}
+}
+
+// TestUnifiedSensorDataService_RollupWholeBuckets 测试已汇总的整桶直接读取汇总表，不再扫描原始数据
+func TestUnifiedSensorDataService_RollupWholeBuckets(t *testing.T) {
+	f := newRollupFixture(t)
+	f.runOnce(t)
+	require.NoError(t, f.db.Unscoped().Where("device_id = ?", testutil.DeviceID2).Delete(&models.UnifiedSensorData{}).Error)
+
+	series, err := f.rollups.AggregateData(context.Background(), &models.AirQualityDataQuery{
+		DeviceIDs: []string{testutil.DeviceID2},
+		Metrics:   []string{"pm25"},
+		StartTime: f.base.Unix(),
+		EndTime:   f.base.Add(24*time.Hour - time.Second).Unix(),
+		Interval:  "1h",
+	})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.InDelta(t, 30, series[0].Points[0].Value, 1e-9)
+}
@@ -2,10 +2,30 @@ package services
 
 // Services 服务层集合
 type Services struct {
-	Device            DeviceService
-	AirQuality        AirQualityService
-	UnifiedSensorData UnifiedSensorDataService
-	User              UserService
-	Alert             AlertService
-	Config            ConfigService
+	Device             DeviceService
+	DeviceCredential   DeviceCredentialService
+	AirQuality         AirQualityService
+	UnifiedSensorData  UnifiedSensorDataService
+	User               UserService
+	Auth               AuthService
+	Role               RoleService
+	Alert              AlertService
+	AlertRule          AlertRuleService
+	AlertEvaluator     AlertEvaluator
+	Notification       NotificationDispatcher
+	Config             ConfigService
+	MQTTACL            MQTTACLService
+	DevicePresence     DevicePresenceService
+	DeviceCommand      DeviceCommandService
+	DeviceConfig       DeviceConfigService
+	DeviceShadow       DeviceShadowService
+	Firmware           FirmwareService
+	FirmwareCampaign   FirmwareCampaignService
+	DeviceProvisioning DeviceProvisioningService
+	DeviceScope        DeviceScopeService
+	Location           LocationService
+	DeviceGroup        DeviceGroupService
+	SensorDataRollup   SensorDataRollupService
+	Retention          RetentionService
+	SensorDataArchive  SensorDataArchiveService
 }
@@ -1,15 +1,35 @@
 package services
 
 import (
+	"air-quality-server/internal/aqi"
 	"air-quality-server/internal/models"
 	"air-quality-server/internal/repositories"
 	"air-quality-server/internal/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrInvalidUpload 上传数据格式或字段无效
+	ErrInvalidUpload = errors.New("上传数据无效")
+	// ErrDuplicateUpload 消息ID已上传过，数据被忽略
+	ErrDuplicateUpload = errors.New("消息ID重复，数据已忽略")
+	// ErrInvalidDataQuery 聚合查询参数无效
+	ErrInvalidDataQuery = errors.New("查询参数无效")
+)
+
+const (
+	// maxMessageIDLength 客户端消息ID最大长度
+	maxMessageIDLength = 64
+	// maxAggregatePoints 聚合查询未指定limit时每个序列最多返回的时间桶数
+	maxAggregatePoints = 1000
+)
+
 // UnifiedSensorDataService 统一传感器数据服务接口
 type UnifiedSensorDataService interface {
 	// 数据创建
@@ -27,11 +47,14 @@ type UnifiedSensorDataService interface {
 	// 统计分析
 	GetStatistics(ctx context.Context, deviceID string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error)
 	GetDeviceTypeStatistics(ctx context.Context, deviceType models.DeviceType, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error)
+	GetMultiDeviceStatistics(ctx context.Context, deviceIDs []string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error)
 	GetMetricData(ctx context.Context, deviceID, metric string, startTime, endTime int64) ([]models.UnifiedSensorData, error)
+	AggregateData(ctx context.Context, query *models.AirQualityDataQuery) ([]models.AggregatedSeries, error)
 
 	// 数据分析
 	AnalyzeData(ctx context.Context, data *models.UnifiedSensorData) (map[string]interface{}, error)
 	GetDataQualityScore(ctx context.Context, data *models.UnifiedSensorData) (float64, error)
+	CalculateAQI(ctx context.Context, deviceID string, at time.Time) (*aqi.Result, error)
 
 	// 告警检查
 	CheckAlerts(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error)
@@ -49,42 +72,50 @@ type UnifiedSensorDataService interface {
 
 // unifiedSensorDataService 统一传感器数据服务实现
 type unifiedSensorDataService struct {
-	dataRepo   repositories.UnifiedSensorDataRepository
-	deviceRepo repositories.DeviceRepository
-	alertSvc   AlertService
-	logger     utils.Logger
+	dataRepo     repositories.UnifiedSensorDataRepository
+	deviceRepo   repositories.DeviceRepository
+	evaluator    AlertEvaluator
+	presence     DevicePresenceService
+	shadow       DeviceShadowService
+	provisioning DeviceProvisioningService
+	rollupRepo   repositories.SensorDataRollupRepository
+	standard     aqi.Standard
+	logger       utils.Logger
 }
 
-// NewUnifiedSensorDataService 创建统一传感器数据服务
+// NewUnifiedSensorDataService 创建统一传感器数据服务（presence为空时不刷新设备在线状态，shadow为空时不维护设备影子，provisioning为空时只接收已注册设备的数据，
+// rollupRepo为空时统计和聚合查询只扫描原始数据，standard为空时按HJ 633-2012计算AQI）
 func NewUnifiedSensorDataService(
 	dataRepo repositories.UnifiedSensorDataRepository,
 	deviceRepo repositories.DeviceRepository,
-	alertSvc AlertService,
+	evaluator AlertEvaluator,
+	presence DevicePresenceService,
+	shadow DeviceShadowService,
+	provisioning DeviceProvisioningService,
+	rollupRepo repositories.SensorDataRollupRepository,
+	standard aqi.Standard,
 	logger utils.Logger,
 ) UnifiedSensorDataService {
+	if standard == "" {
+		standard = aqi.DefaultStandard
+	}
 	return &unifiedSensorDataService{
-		dataRepo:   dataRepo,
-		deviceRepo: deviceRepo,
-		alertSvc:   alertSvc,
-		logger:     logger,
+		dataRepo:     dataRepo,
+		deviceRepo:   deviceRepo,
+		evaluator:    evaluator,
+		presence:     presence,
+		shadow:       shadow,
+		provisioning: provisioning,
+		rollupRepo:   rollupRepo,
+		standard:     standard,
+		logger:       logger,
 	}
 }
 
-// CreateData 创建传感器数据
+// CreateData 创建传感器数据，未注册设备按接入策略拒绝、自动注册或隔离
 func (s *unifiedSensorDataService) CreateData(ctx context.Context, data *models.UnifiedSensorData) error {
-	// 验证设备是否存在
-	device, err := s.deviceRepo.GetByID(ctx, data.DeviceID)
-	if err != nil {
-		s.logger.Error("获取设备失败", utils.ErrorField(err), utils.String("device_id", data.DeviceID))
-		return fmt.Errorf("获取设备失败: %w", err)
-	}
-
-	// 验证设备类型是否匹配
-	if device.Type != data.DeviceType {
-		s.logger.Warn("设备类型不匹配",
-			utils.String("device_id", data.DeviceID),
-			utils.String("expected_type", string(device.Type)),
-			utils.String("actual_type", string(data.DeviceType)))
+	if err := s.admitDevice(ctx, data); err != nil {
+		return err
 	}
 
 	// 计算数据质量分数
@@ -102,11 +133,22 @@ func (s *unifiedSensorDataService) CreateData(ctx context.Context, data *models.
 		}
 	}
 
-	// 保存数据
-	if err := s.dataRepo.Create(ctx, data); err != nil {
+	// 保存数据，带消息ID的数据依赖唯一索引去重
+	if data.MessageID != nil {
+		created, err := s.dataRepo.CreateIfAbsent(ctx, data)
+		if err != nil {
+			s.logger.Error("创建传感器数据失败", utils.ErrorField(err), utils.String("device_id", data.DeviceID))
+			return fmt.Errorf("创建传感器数据失败: %w", err)
+		}
+		if !created {
+			return ErrDuplicateUpload
+		}
+	} else if err := s.dataRepo.Create(ctx, data); err != nil {
 		s.logger.Error("创建传感器数据失败", utils.ErrorField(err), utils.String("device_id", data.DeviceID))
 		return fmt.Errorf("创建传感器数据失败: %w", err)
 	}
+	s.recordReadings(ctx, []models.UnifiedSensorData{*data})
+	s.evaluateAlerts(ctx, []models.UnifiedSensorData{*data})
 
 	s.logger.Info("创建传感器数据成功",
 		utils.String("device_id", data.DeviceID),
@@ -122,40 +164,153 @@ func (s *unifiedSensorDataService) CreateBatchData(ctx context.Context, data []m
 		return nil
 	}
 
+	// 未注册设备的数据按接入策略过滤
+	if s.provisioning != nil {
+		result, err := s.provisioning.Admit(ctx, data)
+		if err != nil {
+			return fmt.Errorf("检查设备接入策略失败: %w", err)
+		}
+		if result.Rejected > 0 || result.Quarantined > 0 {
+			s.logger.Warn("部分数据来自未注册设备",
+				utils.Int("rejected", result.Rejected),
+				utils.Int("quarantined", result.Quarantined))
+		}
+		data = result.Admitted
+		if len(data) == 0 {
+			return nil
+		}
+	}
+
 	// 批量保存数据
 	if err := s.dataRepo.BatchInsert(ctx, data); err != nil {
 		s.logger.Error("批量创建传感器数据失败", utils.ErrorField(err))
 		return fmt.Errorf("批量创建传感器数据失败: %w", err)
 	}
 
+	s.recordReadings(ctx, data)
+	s.evaluateAlerts(ctx, data)
+
 	s.logger.Info("批量创建传感器数据成功", utils.Int("count", len(data)))
 	return nil
 }
 
-// CreateFromUpload 从上传请求创建数据
+// admitDevice 检查单条数据的设备是否可接收，隔离时返回ErrDeviceQuarantined
+func (s *unifiedSensorDataService) admitDevice(ctx context.Context, data *models.UnifiedSensorData) error {
+	if s.provisioning == nil {
+		device, err := s.deviceRepo.GetByDeviceID(ctx, data.DeviceID)
+		if err != nil {
+			s.logger.Warn("设备未注册，拒绝数据", utils.String("device_id", data.DeviceID), utils.ErrorField(err))
+			return ErrDeviceNotRegistered
+		}
+		if device.Type != data.DeviceType {
+			s.logger.Warn("设备类型不匹配",
+				utils.String("device_id", data.DeviceID),
+				utils.String("expected_type", string(device.Type)),
+				utils.String("actual_type", string(data.DeviceType)))
+		}
+		return nil
+	}
+
+	result, err := s.provisioning.Admit(ctx, []models.UnifiedSensorData{*data})
+	if err != nil {
+		return fmt.Errorf("检查设备接入策略失败: %w", err)
+	}
+	switch {
+	case result.Quarantined > 0:
+		return ErrDeviceQuarantined
+	case result.Rejected > 0:
+		return ErrDeviceNotRegistered
+	}
+	return nil
+}
+
+// recordReadings 用入库成功的数据刷新设备运行状态和设备影子，与MQTT入库路径一致
+func (s *unifiedSensorDataService) recordReadings(ctx context.Context, data []models.UnifiedSensorData) {
+	if s.presence != nil {
+		if err := s.presence.RecordReadings(ctx, data); err != nil {
+			s.logger.Error("更新设备运行状态失败", utils.Int("count", len(data)), utils.ErrorField(err))
+		}
+	}
+	if s.shadow != nil {
+		if err := s.shadow.ApplyReadings(ctx, data); err != nil {
+			s.logger.Error("更新设备影子失败", utils.Int("count", len(data)), utils.ErrorField(err))
+		}
+	}
+}
+
+// evaluateAlerts 按告警规则评估入库成功的读数，与MQTT入库路径一致
+func (s *unifiedSensorDataService) evaluateAlerts(ctx context.Context, data []models.UnifiedSensorData) {
+	if s.evaluator == nil {
+		return
+	}
+	for i := range data {
+		alerts, err := s.evaluator.Evaluate(ctx, &data[i])
+		if err != nil {
+			s.logger.Error("检查告警失败", utils.String("device_id", data[i].DeviceID), utils.ErrorField(err))
+			continue
+		}
+		for _, alert := range alerts {
+			s.logger.Warn("触发告警",
+				utils.String("device_id", alert.DeviceID),
+				utils.Int64("rule_id", int64(alert.RuleID)),
+				utils.String("metric", alert.Metric),
+				utils.Float64("value", alert.CurrentValue),
+				utils.String("severity", alert.Severity))
+		}
+	}
+}
+
+// CreateFromUpload 从上传请求创建数据，校验失败返回ErrInvalidUpload，消息ID重复返回ErrDuplicateUpload
 func (s *unifiedSensorDataService) CreateFromUpload(ctx context.Context, upload *models.UnifiedSensorDataUpload) error {
-	// 转换时间戳
-	timestamp := time.Unix(upload.Timestamp, 0)
+	if upload.DeviceID == "" || len(upload.DeviceID) > 64 {
+		return fmt.Errorf("%w: 设备ID不能为空且长度不能超过64", ErrInvalidUpload)
+	}
+	if len(upload.MessageID) > maxMessageIDLength {
+		return fmt.Errorf("%w: 消息ID长度不能超过%d", ErrInvalidUpload, maxMessageIDLength)
+	}
+	if upload.Timestamp < 0 {
+		return fmt.Errorf("%w: 时间戳无效", ErrInvalidUpload)
+	}
+
+	// 转换时间戳，未提供时使用接收时间
+	timestamp := time.Now()
+	if upload.Timestamp > 0 {
+		timestamp = time.Unix(upload.Timestamp, 0)
+	}
 
 	// 确定设备类型
 	deviceType := models.DeviceType(upload.DeviceType)
 	if !deviceType.IsValid() {
-		return fmt.Errorf("无效的设备类型: %s", upload.DeviceType)
+		return fmt.Errorf("%w: 无效的设备类型 %s", ErrInvalidUpload, upload.DeviceType)
 	}
 
 	// 创建传感器数据
 	sensorData := &models.UnifiedSensorData{
 		DeviceID:    upload.DeviceID,
 		DeviceType:  deviceType,
+		SensorID:    upload.SensorID,
+		SensorType:  upload.SensorType,
 		Timestamp:   timestamp,
 		DataQuality: "good",
 	}
 
-	// 解析数据字段
+	// 解析数据字段，电量按整数保存
+	metrics := 0
 	for metric, value := range upload.Data {
-		if floatValue, ok := value.(float64); ok {
+		floatValue, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%w: 指标 %s 必须为数值", ErrInvalidUpload, metric)
+		}
+		if metric == "battery" {
+			battery := int(floatValue)
+			sensorData.Battery = &battery
+		} else {
 			sensorData.SetMetricValue(metric, &floatValue)
 		}
+		metrics++
+	}
+	if metrics == 0 {
+		return fmt.Errorf("%w: 数据不能为空", ErrInvalidUpload)
 	}
 
 	// 解析位置信息
@@ -172,15 +327,37 @@ func (s *unifiedSensorDataService) CreateFromUpload(ctx context.Context, upload
 		}
 	}
 
-	// 解析扩展数据
-	if upload.Extended != nil && len(upload.Extended) > 0 {
-		extendedJSON, err := json.Marshal(upload.Extended)
+	// 合并扩展数据，data中的非标准指标已写入扩展数据，同名时以data中的值为准
+	if len(upload.Extended) > 0 {
+		extended := make(map[string]interface{})
+		if sensorData.ExtendedData != nil {
+			json.Unmarshal([]byte(*sensorData.ExtendedData), &extended)
+		}
+		for key, value := range upload.Extended {
+			if _, ok := extended[key]; !ok {
+				extended[key] = value
+			}
+		}
+		extendedJSON, err := json.Marshal(extended)
 		if err == nil {
 			extendedStr := string(extendedJSON)
 			sensorData.ExtendedData = &extendedStr
 		}
 	}
 
+	// 重复消息在接入检查前直接忽略，避免重复隔离或自动注册
+	if upload.MessageID != "" {
+		exists, err := s.dataRepo.ExistsByMessageID(ctx, upload.DeviceID, upload.MessageID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateUpload
+		}
+		messageID := upload.MessageID
+		sensorData.MessageID = &messageID
+	}
+
 	return s.CreateData(ctx, sensorData)
 }
 
@@ -211,12 +388,26 @@ func (s *unifiedSensorDataService) GetMultiDeviceData(ctx context.Context, devic
 
 // GetStatistics 获取统计数据
 func (s *unifiedSensorDataService) GetStatistics(ctx context.Context, deviceID string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
-	return s.dataRepo.GetStatistics(ctx, deviceID, startTime, endTime)
+	return s.statistics(ctx, models.RollupFilter{DeviceIDs: []string{deviceID}}, startTime, endTime, func() (*models.UnifiedSensorDataStatistics, error) {
+		return s.dataRepo.GetStatistics(ctx, deviceID, startTime, endTime)
+	})
 }
 
 // GetDeviceTypeStatistics 获取设备类型统计
 func (s *unifiedSensorDataService) GetDeviceTypeStatistics(ctx context.Context, deviceType models.DeviceType, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
-	return s.dataRepo.GetDeviceTypeStatistics(ctx, deviceType, startTime, endTime)
+	return s.statistics(ctx, models.RollupFilter{DeviceType: deviceType}, startTime, endTime, func() (*models.UnifiedSensorDataStatistics, error) {
+		return s.dataRepo.GetDeviceTypeStatistics(ctx, deviceType, startTime, endTime)
+	})
+}
+
+// GetMultiDeviceStatistics 获取多设备汇总统计
+func (s *unifiedSensorDataService) GetMultiDeviceStatistics(ctx context.Context, deviceIDs []string, startTime, endTime int64) (*models.UnifiedSensorDataStatistics, error) {
+	if len(deviceIDs) == 0 {
+		return &models.UnifiedSensorDataStatistics{}, nil
+	}
+	return s.statistics(ctx, models.RollupFilter{DeviceIDs: deviceIDs}, startTime, endTime, func() (*models.UnifiedSensorDataStatistics, error) {
+		return s.dataRepo.GetMultiDeviceStatistics(ctx, deviceIDs, startTime, endTime)
+	})
 }
 
 // GetMetricData 获取指定指标数据
@@ -224,6 +415,39 @@ func (s *unifiedSensorDataService) GetMetricData(ctx context.Context, deviceID,
 	return s.dataRepo.GetMetricData(ctx, deviceID, metric, startTime, endTime)
 }
 
+// AggregateData 按时间桶聚合多设备指标。未指定limit且时间桶数超过maxAggregatePoints时自动放大时间桶并回写query.Interval；
+// 参数无效或时间桶数超过指定的limit时返回ErrInvalidDataQuery，错误信息包含该时间桶允许的最长时间范围
+func (s *unifiedSensorDataService) AggregateData(ctx context.Context, query *models.AirQualityDataQuery) ([]models.AggregatedSeries, error) {
+	interval, ok := models.ParseAggregationInterval(query.Interval)
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的时间桶 %s", ErrInvalidDataQuery, query.Interval)
+	}
+	if query.Aggregation != "" && !models.AggregationType(query.Aggregation).IsValid() {
+		return nil, fmt.Errorf("%w: 不支持的聚合方式 %s", ErrInvalidDataQuery, query.Aggregation)
+	}
+	if query.StartTime > query.EndTime {
+		return nil, fmt.Errorf("%w: 开始时间不能晚于结束时间", ErrInvalidDataQuery)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = maxAggregatePoints
+		name := models.AutoAggregationInterval(query.StartTime, query.EndTime, limit)
+		if coarser, _ := models.ParseAggregationInterval(name); coarser > interval {
+			interval = coarser
+			query.Interval = name
+		}
+	}
+	if buckets := (query.EndTime-query.StartTime)/int64(interval/time.Second) + 1; buckets > int64(limit) {
+		return nil, fmt.Errorf("%w: interval=%s时最多返回%d个时间桶，时间范围最长%s，请缩小时间范围或增大interval",
+			ErrInvalidDataQuery, query.Interval, limit, formatSpan(time.Duration(limit)*interval))
+	}
+
+	if s.rollupRepo == nil || query.SensorID != "" || len(query.DeviceIDs) == 0 {
+		return s.dataRepo.Aggregate(ctx, query)
+	}
+	return s.aggregateWithRollups(ctx, query, interval)
+}
+
 // AnalyzeData 分析数据
 func (s *unifiedSensorDataService) AnalyzeData(ctx context.Context, data *models.UnifiedSensorData) (map[string]interface{}, error) {
 	analysis := make(map[string]interface{})
@@ -247,8 +471,14 @@ func (s *unifiedSensorDataService) AnalyzeData(ctx context.Context, data *models
 		}
 
 	case models.DeviceTypeAirQuality:
-		// 综合空气质量分析
-		analysis["air_quality_index"] = s.calculateAirQualityIndex(data)
+		// 按配置的标准计算截至本次测量的AQI
+		result, err := s.CalculateAQI(ctx, data.DeviceID, data.Timestamp)
+		if err == nil {
+			analysis["air_quality_index"] = result.AQI
+			analysis["aqi"] = result
+		} else if !errors.Is(err, aqi.ErrNoPollutants) {
+			return nil, err
+		}
 	}
 
 	// 环境参数分析
@@ -292,48 +522,9 @@ func (s *unifiedSensorDataService) GetDataQualityScore(ctx context.Context, data
 	return score, nil
 }
 
-// CheckAlerts 检查告警
+// CheckAlerts 按告警规则检查读数，返回触发的告警（不落库）
 func (s *unifiedSensorDataService) CheckAlerts(ctx context.Context, data *models.UnifiedSensorData) ([]models.Alert, error) {
-	var alerts []models.Alert
-
-	// 根据设备类型检查相应的告警
-	switch data.DeviceType {
-	case models.DeviceTypeFormaldehyde:
-		if data.Formaldehyde != nil {
-			alert := s.checkFormaldehydeAlert(ctx, data)
-			if alert != nil {
-				alerts = append(alerts, *alert)
-			}
-		}
-
-	case models.DeviceTypePM25:
-		if data.PM25 != nil {
-			alert := s.checkPM25Alert(ctx, data)
-			if alert != nil {
-				alerts = append(alerts, *alert)
-			}
-		}
-	}
-
-	// 检查设备状态告警
-	if data.Battery != nil && *data.Battery < 20 {
-		message := fmt.Sprintf("设备电池电量低: %d%%", *data.Battery)
-		alert := &models.Alert{
-			RuleID:         0, // 临时规则ID
-			DeviceID:       data.DeviceID,
-			Metric:         "battery",
-			CurrentValue:   float64(*data.Battery),
-			ThresholdValue: 20,
-			Severity:       "warning",
-			Status:         "active",
-			TriggeredAt:    time.Now(),
-			Message:        &message,
-			CreatedAt:      time.Now(),
-		}
-		alerts = append(alerts, *alert)
-	}
-
-	return alerts, nil
+	return s.evaluator.Match(ctx, data)
 }
 
 // GetSensorIDs 获取传感器ID列表
@@ -356,6 +547,32 @@ func (s *unifiedSensorDataService) GetDeviceIDs(ctx context.Context) ([]string,
 	return s.dataRepo.GetDeviceIDs(ctx)
 }
 
+// CalculateAQI 按标准规定的平均时段汇总设备截至at的污染物浓度并计算AQI，时段内没有污染物数据时返回aqi.ErrNoPollutants
+func (s *unifiedSensorDataService) CalculateAQI(ctx context.Context, deviceID string, at time.Time) (*aqi.Result, error) {
+	data, err := s.dataRepo.GetByTimeRange(ctx, deviceID, at.Add(-s.standard.MaxAveraging()).Unix(), at.Unix())
+	if err != nil {
+		s.logger.Error("获取AQI计算数据失败", utils.ErrorField(err), utils.String("device_id", deviceID))
+		return nil, fmt.Errorf("获取AQI计算数据失败: %w", err)
+	}
+
+	samples := make([]aqi.Sample, 0, len(data))
+	for i := range data {
+		values := make(aqi.Concentrations)
+		for pollutant, value := range map[aqi.Pollutant]*float64{
+			aqi.PM25: data[i].PM25, aqi.PM10: data[i].PM10, aqi.O3: data[i].O3,
+			aqi.CO: data[i].CO, aqi.NO2: data[i].NO2, aqi.SO2: data[i].SO2,
+		} {
+			if value != nil {
+				values[pollutant] = *value
+			}
+		}
+		if len(values) > 0 {
+			samples = append(samples, aqi.Sample{Time: data[i].Timestamp, Values: values})
+		}
+	}
+	return aqi.Calculate(s.standard, aqi.Averages(s.standard, samples, at))
+}
+
 // 辅助方法
 func (s *unifiedSensorDataService) getFormaldehydeStatus(value float64) string {
 	if value >= 0.1 {
@@ -397,90 +614,259 @@ func (s *unifiedSensorDataService) getHumidityStatus(value float64) string {
 	}
 }
 
-func (s *unifiedSensorDataService) calculateAirQualityIndex(data *models.UnifiedSensorData) float64 {
-	// 简化的空气质量指数计算
-	index := 0.0
-	count := 0
+// statisticsMetrics 统计结果包含的指标
+var statisticsMetrics = []string{"pm25", "pm10", "co2", "formaldehyde", "temperature", "humidity", "pressure"}
 
-	if data.PM25 != nil {
-		index += *data.PM25
-		count++
+// rollupWindow 计算可由汇总表提供的时间范围：起点按粒度向上对齐，终点取汇总覆盖时间和查询终点中较早者并按粒度向下对齐，
+// 范围之外的头尾部分由原始数据补齐；汇总尚未覆盖查询范围时返回false
+func (s *unifiedSensorDataService) rollupWindow(ctx context.Context, startTime, endTime int64, resolution, alignment time.Duration) (time.Time, time.Time, bool, error) {
+	state, err := s.rollupRepo.GetState(ctx)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
 	}
-	if data.PM10 != nil {
-		index += *data.PM10 * 0.5
-		count++
+
+	start := models.BucketStart(time.Unix(startTime, 0), resolution)
+	if start.Unix() < startTime {
+		start = start.Add(resolution)
 	}
-	if data.CO2 != nil {
-		index += *data.CO2 * 0.1
-		count++
+	end := models.BucketStart(state.RolledUntil, alignment)
+	if queryEnd := models.BucketStart(time.Unix(endTime, 0), resolution); queryEnd.Before(end) {
+		end = queryEnd
+	}
+	return start, end, start.Before(end), nil
+}
+
+// aggregateWithRollups 优先从能整除时间桶的最粗汇总表读取聚合值，汇总未覆盖的头尾部分扫描原始数据后合并
+func (s *unifiedSensorDataService) aggregateWithRollups(ctx context.Context, query *models.AirQualityDataQuery, interval time.Duration) ([]models.AggregatedSeries, error) {
+	resolution := models.RollupResolutionMinute
+	for _, candidate := range models.RollupResolutions {
+		if interval%candidate.Duration() == 0 {
+			resolution = candidate
+		}
 	}
 
-	if count > 0 {
-		return index / float64(count)
+	start, end, ok, err := s.rollupWindow(ctx, query.StartTime, query.EndTime, resolution.Duration(), interval)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+	if !ok {
+		return s.dataRepo.Aggregate(ctx, query)
+	}
+
+	rollups, err := s.rollupRepo.Find(ctx, resolution, models.RollupFilter{DeviceIDs: query.DeviceIDs, Metrics: query.Metrics}, start, end)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[rollupKey]*models.SensorDataRollup)
+	for i := range rollups {
+		if rollups[i].Metric == models.RollupMetricReadings {
+			continue
+		}
+		key := rollupKey{deviceID: rollups[i].DeviceID, metric: rollups[i].Metric, bucket: models.BucketStart(rollups[i].BucketStart, interval)}
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &models.SensorDataRollup{}
+			buckets[key] = bucket
+		}
+		bucket.Merge(&rollups[i])
+	}
+
+	aggregation := models.AggregationType(query.Aggregation)
+	points := make(map[rollupKey]models.AggregatedPoint, len(buckets))
+	for key, bucket := range buckets {
+		points[key] = models.AggregatedPoint{Time: key.bucket, Value: rollupValue(bucket, aggregation), Count: bucket.Count}
+	}
+
+	// 汇总范围之外的头尾部分
+	ranges := [][2]time.Time{
+		{time.Unix(query.StartTime, 0), start},
+		{end, time.Unix(query.EndTime, 0).Add(time.Second)},
+	}
+	for _, r := range ranges {
+		if !r[0].Before(r[1]) {
+			continue
+		}
+		series, err := s.dataRepo.AggregateRange(ctx, query, r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range series {
+			for _, point := range item.Points {
+				key := rollupKey{deviceID: item.DeviceID, metric: item.Metric, bucket: point.Time}
+				if existing, ok := points[key]; ok {
+					point = mergeAggregatedPoints(aggregation, existing, point)
+				}
+				points[key] = point
+			}
+		}
+	}
+
+	grouped := make(map[rollupKey][]models.AggregatedPoint)
+	for key, point := range points {
+		seriesKey := rollupKey{deviceID: key.deviceID, metric: key.metric}
+		grouped[seriesKey] = append(grouped[seriesKey], point)
+	}
+	result := make([]models.AggregatedSeries, 0, len(grouped))
+	for key, items := range grouped {
+		sort.Slice(items, func(i, j int) bool { return items[i].Time.Before(items[j].Time) })
+		result = append(result, models.AggregatedSeries{DeviceID: key.deviceID, Metric: key.metric, Points: items})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceID != result[j].DeviceID {
+			return result[i].DeviceID < result[j].DeviceID
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	return result, nil
 }
 
-func (s *unifiedSensorDataService) checkFormaldehydeAlert(ctx context.Context, data *models.UnifiedSensorData) *models.Alert {
-	formaldehyde := *data.Formaldehyde
-	var alertLevel string
-	var message string
-	var threshold float64
+// statistics 由汇总表计算统计值：整天、整小时、整分钟分别读取天、小时、分钟汇总，不足一分钟的头尾及汇总尚未覆盖的部分扫描原始数据
+func (s *unifiedSensorDataService) statistics(ctx context.Context, filter models.RollupFilter, startTime, endTime int64, raw func() (*models.UnifiedSensorDataStatistics, error)) (*models.UnifiedSensorDataStatistics, error) {
+	if s.rollupRepo == nil {
+		return raw()
+	}
+	start, end, ok, err := s.rollupWindow(ctx, startTime, endTime, time.Minute, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return raw()
+	}
 
-	if formaldehyde >= 0.1 {
-		alertLevel = "critical"
-		message = fmt.Sprintf("甲醛浓度严重超标: %.3f mg/m³", formaldehyde)
-		threshold = 0.1
-	} else if formaldehyde >= 0.08 {
-		alertLevel = "warning"
-		message = fmt.Sprintf("甲醛浓度超标: %.3f mg/m³", formaldehyde)
-		threshold = 0.08
-	} else {
-		return nil
+	filter.Metrics = append([]string{models.RollupMetricReadings}, statisticsMetrics...)
+	totals := make(map[string]*models.SensorDataRollup)
+	total := func(metric string) *models.SensorDataRollup {
+		if totals[metric] == nil {
+			totals[metric] = &models.SensorDataRollup{Metric: metric}
+		}
+		return totals[metric]
 	}
 
-	return &models.Alert{
-		RuleID:         0, // 临时规则ID
-		DeviceID:       data.DeviceID,
-		Metric:         "formaldehyde",
-		CurrentValue:   formaldehyde,
-		ThresholdValue: threshold,
-		Severity:       alertLevel,
-		Status:         "active",
-		TriggeredAt:    time.Now(),
-		Message:        &message,
-		CreatedAt:      time.Now(),
-	}
-}
-
-func (s *unifiedSensorDataService) checkPM25Alert(ctx context.Context, data *models.UnifiedSensorData) *models.Alert {
-	pm25 := *data.PM25
-	var alertLevel string
-	var message string
-	var threshold float64
-
-	if pm25 >= 75 {
-		alertLevel = "critical"
-		message = fmt.Sprintf("PM2.5浓度严重超标: %.1f μg/m³", pm25)
-		threshold = 75
-	} else if pm25 >= 35 {
-		alertLevel = "warning"
-		message = fmt.Sprintf("PM2.5浓度超标: %.1f μg/m³", pm25)
-		threshold = 35
-	} else {
+	for _, segment := range rollupSegments(start, end, len(models.RollupResolutions)-1) {
+		rollups, err := s.rollupRepo.Find(ctx, segment.resolution, filter, segment.start, segment.end)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rollups {
+			total(rollups[i].Metric).Merge(&rollups[i])
+		}
+	}
+
+	ranges := [][2]time.Time{
+		{time.Unix(startTime, 0), start},
+		{end, time.Unix(endTime, 0).Add(time.Second)},
+	}
+	for _, r := range ranges {
+		if !r[0].Before(r[1]) {
+			continue
+		}
+		readings, err := s.rollupRepo.ListReadings(ctx, filter, r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+		for i := range readings {
+			total(models.RollupMetricReadings).Add(1, readings[i].Timestamp, readings[i].DeviceType)
+			for _, metric := range statisticsMetrics {
+				if value := readings[i].GetMetricValue(metric); value != nil {
+					total(metric).Add(*value, readings[i].Timestamp, readings[i].DeviceType)
+				}
+			}
+		}
+	}
+
+	stats := &models.UnifiedSensorDataStatistics{DataCount: total(models.RollupMetricReadings).Count}
+	fields := map[string][3]*float64{
+		"pm25":         {&stats.PM25Avg, &stats.PM25Min, &stats.PM25Max},
+		"pm10":         {&stats.PM10Avg, &stats.PM10Min, &stats.PM10Max},
+		"co2":          {&stats.CO2Avg, &stats.CO2Min, &stats.CO2Max},
+		"formaldehyde": {&stats.FormaldehydeAvg, &stats.FormaldehydeMin, &stats.FormaldehydeMax},
+		"temperature":  {&stats.TemperatureAvg, &stats.TemperatureMin, &stats.TemperatureMax},
+		"humidity":     {&stats.HumidityAvg, &stats.HumidityMin, &stats.HumidityMax},
+		"pressure":     {&stats.PressureAvg, &stats.PressureMin, &stats.PressureMax},
+	}
+	for metric, field := range fields {
+		if t := totals[metric]; t != nil && t.Count > 0 {
+			*field[0], *field[1], *field[2] = t.Avg, t.Min, t.Max
+		}
+	}
+	return stats, nil
+}
+
+// rollupSegment 从某一汇总表读取的时间范围
+type rollupSegment struct {
+	resolution models.RollupResolution
+	start      time.Time
+	end        time.Time
+}
+
+// rollupSegments 将按分钟对齐的时间范围拆分为尽量粗的汇总段：中间整段用粗粒度，两端的零头递归使用更细的粒度
+func rollupSegments(start, end time.Time, level int) []rollupSegment {
+	if !start.Before(end) {
 		return nil
 	}
+	resolution := models.RollupResolutions[level]
+	if level == 0 {
+		return []rollupSegment{{resolution: resolution, start: start, end: end}}
+	}
 
-	return &models.Alert{
-		RuleID:         0, // 临时规则ID
-		DeviceID:       data.DeviceID,
-		Metric:         "pm25",
-		CurrentValue:   pm25,
-		ThresholdValue: threshold,
-		Severity:       alertLevel,
-		Status:         "active",
-		TriggeredAt:    time.Now(),
-		Message:        &message,
-		CreatedAt:      time.Now(),
+	alignedStart := models.BucketStart(start, resolution.Duration())
+	if alignedStart.Before(start) {
+		alignedStart = alignedStart.Add(resolution.Duration())
+	}
+	alignedEnd := models.BucketStart(end, resolution.Duration())
+	if !alignedStart.Before(alignedEnd) {
+		return rollupSegments(start, end, level-1)
 	}
+
+	segments := rollupSegments(start, alignedStart, level-1)
+	segments = append(segments, rollupSegment{resolution: resolution, start: alignedStart, end: alignedEnd})
+	return append(segments, rollupSegments(alignedEnd, end, level-1)...)
+}
+
+// rollupValue 按聚合方式取汇总值
+func rollupValue(rollup *models.SensorDataRollup, aggregation models.AggregationType) float64 {
+	switch aggregation {
+	case models.AggregationMax:
+		return rollup.Max
+	case models.AggregationMin:
+		return rollup.Min
+	case models.AggregationSum:
+		return rollup.Sum
+	default:
+		return rollup.Avg
+	}
+}
+
+// formatSpan 将时间范围格式化为天、小时、分钟，用于错误提示
+func formatSpan(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	hours := d % (24 * time.Hour) / time.Hour
+	minutes := d % time.Hour / time.Minute
+	var b strings.Builder
+	if days > 0 {
+		fmt.Fprintf(&b, "%d天", days)
+	}
+	if hours > 0 {
+		fmt.Fprintf(&b, "%d小时", hours)
+	}
+	if minutes > 0 || b.Len() == 0 {
+		fmt.Fprintf(&b, "%d分钟", minutes)
+	}
+	return b.String()
+}
+
+// mergeAggregatedPoints 合并同一时间桶内分别来自汇总表和原始数据的聚合值
+func mergeAggregatedPoints(aggregation models.AggregationType, a, b models.AggregatedPoint) models.AggregatedPoint {
+	merged := models.AggregatedPoint{Time: a.Time, Count: a.Count + b.Count}
+	switch aggregation {
+	case models.AggregationMax:
+		merged.Value = max(a.Value, b.Value)
+	case models.AggregationMin:
+		merged.Value = min(a.Value, b.Value)
+	case models.AggregationSum:
+		merged.Value = a.Value + b.Value
+	default:
+		merged.Value = (a.Value*float64(a.Count) + b.Value*float64(b.Count)) / float64(merged.Count)
+	}
+	return merged
 }
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"air-quality-server/internal/aqi"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// aqiReadings 写入设备1在计算时间at前后的PM2.5和O3数据：PM2.5 24小时平均80，O3 8小时平均120，
+// 超出平均时段和晚于计算时间的数据取值很高，被计入时会明显改变结果
+func aqiReadings(t *testing.T, db *gorm.DB, at time.Time) {
+	value := func(v float64) *float64 { return &v }
+	for _, r := range []struct {
+		offset   time.Duration
+		pm25, o3 *float64
+	}{
+		{-25 * time.Hour, value(500), nil},
+		{-23 * time.Hour, value(70), nil},
+		{-20 * time.Hour, nil, value(300)},
+		{-2 * time.Hour, nil, value(100)},
+		{-time.Hour, value(90), nil},
+		{-30 * time.Minute, nil, value(140)},
+		{time.Hour, value(500), value(500)},
+	} {
+		require.NoError(t, db.Create(&models.UnifiedSensorData{
+			DeviceID:   testutil.DeviceID1,
+			DeviceType: models.DeviceTypeAirQuality,
+			Timestamp:  at.Add(r.offset),
+			PM25:       r.pm25,
+			O3:         r.o3,
+		}).Error)
+	}
+}
+
+// TestUnifiedSensorDataService_CalculateAQI 测试按标准的平均时段汇总设备数据计算AQI
+func TestUnifiedSensorDataService_CalculateAQI(t *testing.T) {
+	tests := []struct {
+		name     string
+		standard aqi.Standard
+		aqi      int
+		iaqi     map[aqi.Pollutant]int
+		category string
+	}{
+		// PM2.5 24小时平均80 → 107；O3 8小时平均120 → 67
+		{"default hj633", "", 107, map[aqi.Pollutant]int{aqi.PM25: 107, aqi.O3: 67}, "light"},
+		// 同样的数据按EPA标准计算：PM2.5 80 → 168
+		{"us epa", aqi.StandardUSEPA, 168, map[aqi.Pollutant]int{aqi.PM25: 168}, "unhealthy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDatabase(t)
+			logger := testutil.NewLogger(t)
+			at := time.Unix(1700000000, 0)
+			aqiReadings(t, db, at)
+			service := NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger),
+				repositories.NewDeviceRepository(db, logger), nil, nil, nil, nil, nil, tt.standard, logger)
+
+			result, err := service.CalculateAQI(context.Background(), testutil.DeviceID1, at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.aqi, result.AQI)
+			for pollutant, want := range tt.iaqi {
+				assert.Equal(t, want, result.IAQI[pollutant], string(pollutant))
+			}
+			assert.Equal(t, []aqi.Pollutant{aqi.PM25}, result.PrimaryPollutants)
+			assert.Equal(t, tt.category, result.Category.Name)
+
+			analysis, err := service.AnalyzeData(context.Background(), &models.UnifiedSensorData{
+				DeviceID: testutil.DeviceID1, DeviceType: models.DeviceTypeAirQuality, Timestamp: at})
+			require.NoError(t, err)
+			assert.Equal(t, tt.aqi, analysis["air_quality_index"])
+		})
+	}
+}
+
+// aggregateReadings 写入设备1在base后两个小时内的甲醛数据（其中一条带扩展指标tvoc）和设备2在第一个小时的一条数据
+func aggregateReadings(t *testing.T, db *gorm.DB, base time.Time) {
+	tvoc := `{"tvoc":0.4}`
+	for _, r := range []struct {
+		deviceID string
+		sensorID string
+		offset   time.Duration
+		hcho     float64
+		extended *string
+	}{
+		{testutil.DeviceID1, "s1", 5 * time.Minute, 0.02, nil},
+		{testutil.DeviceID1, "s2", 35 * time.Minute, 0.04, &tvoc},
+		{testutil.DeviceID1, "s1", 65 * time.Minute, 0.10, nil},
+		{testutil.DeviceID2, "s1", 10 * time.Minute, 0.08, nil},
+	} {
+		value := r.hcho
+		require.NoError(t, db.Create(&models.UnifiedSensorData{
+			DeviceID:     r.deviceID,
+			DeviceType:   models.DeviceTypeFormaldehyde,
+			SensorID:     r.sensorID,
+			Timestamp:    base.Add(r.offset),
+			Formaldehyde: &value,
+			ExtendedData: r.extended,
+		}).Error)
+	}
+}
+
+// TestUnifiedSensorDataService_AggregateData 测试按设备和指标分序列聚合：聚合方式、扩展指标、传感器过滤及时间桶数上限
+func TestUnifiedSensorDataService_AggregateData(t *testing.T) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+	base := time.Unix(1700000000/3600*3600, 0)
+	aggregateReadings(t, db, base)
+	service := NewUnifiedSensorDataService(repositories.NewUnifiedSensorDataRepository(db, logger),
+		repositories.NewDeviceRepository(db, logger), nil, nil, nil, nil, nil, "", logger)
+	end := base.Add(2*time.Hour - time.Second).Unix()
+
+	type point struct {
+		value float64
+		count int64
+	}
+	tests := []struct {
+		name     string
+		query    models.AirQualityDataQuery
+		wantErr  bool
+		interval string
+		series   map[string][]point // 键为"设备ID/指标"
+	}{
+		{
+			name: "default avg per device",
+			query: models.AirQualityDataQuery{DeviceIDs: []string{testutil.DeviceID1, testutil.DeviceID2},
+				Metrics: []string{"formaldehyde"}, Interval: "1h"},
+			interval: "1h",
+			series: map[string][]point{
+				testutil.DeviceID1 + "/formaldehyde": {{0.03, 2}, {0.10, 1}},
+				testutil.DeviceID2 + "/formaldehyde": {{0.08, 1}},
+			},
+		},
+		{
+			name:     "max with extended metrics",
+			query:    models.AirQualityDataQuery{DeviceIDs: []string{testutil.DeviceID1}, Interval: "1d", Aggregation: "max"},
+			interval: "1d",
+			series: map[string][]point{
+				testutil.DeviceID1 + "/formaldehyde": {{0.10, 3}},
+				testutil.DeviceID1 + "/tvoc":         {{0.4, 1}},
+			},
+		},
+		{
+			name: "sum for one sensor",
+			query: models.AirQualityDataQuery{DeviceIDs: []string{testutil.DeviceID1}, Metrics: []string{"formaldehyde"},
+				Interval: "1h", Aggregation: "sum", SensorID: "s1"},
+			interval: "1h",
+			series:   map[string][]point{testutil.DeviceID1 + "/formaldehyde": {{0.02, 1}, {0.10, 1}}},
+		},
+		{name: "unsupported interval", query: models.AirQualityDataQuery{Interval: "2m"}, wantErr: true},
+		{name: "unsupported aggregation", query: models.AirQualityDataQuery{Interval: "1h", Aggregation: "median"}, wantErr: true},
+		{name: "explicit limit exceeded", query: models.AirQualityDataQuery{Interval: "1m", Limit: 60}, wantErr: true},
+		{
+			name:     "coarsened to fit a week",
+			query:    models.AirQualityDataQuery{DeviceIDs: []string{testutil.DeviceID1}, Interval: "1m", StartTime: base.Add(-7 * 24 * time.Hour).Unix()},
+			interval: "15m",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			if query.StartTime == 0 {
+				query.StartTime = base.Unix()
+			}
+			query.EndTime = end
+			series, err := service.AggregateData(context.Background(), &query)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidDataQuery), "%v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.interval, query.Interval)
+			if tt.series == nil {
+				return
+			}
+			interval, _ := models.ParseAggregationInterval(query.Interval)
+			got := make(map[string][]point)
+			for _, s := range series {
+				for i, p := range s.Points {
+					if i == 0 {
+						assert.True(t, models.BucketStart(base, interval).Equal(p.Time), "%s/%s", s.DeviceID, s.Metric)
+					}
+					got[s.DeviceID+"/"+s.Metric] = append(got[s.DeviceID+"/"+s.Metric], point{p.Value, p.Count})
+				}
+			}
+			require.Len(t, got, len(tt.series))
+			for key, want := range tt.series {
+				require.Len(t, got[key], len(want), key)
+				for i := range want {
+					assert.InDelta(t, want[i].value, got[key][i].value, 1e-9, key)
+					assert.Equal(t, want[i].count, got[key][i].count, key)
+				}
+			}
+		})
+	}
+}
+
+// TestAutoAggregationInterval 测试按最大点数自动选择时间桶
+func TestAutoAggregationInterval(t *testing.T) {
+	hour := int64(3600)
+	tests := []struct {
+		span int64
+		want string
+	}{
+		{hour, "1m"},
+		{24 * hour, "5m"},
+		{7 * 24 * hour, "1h"},
+		{30 * 24 * hour, "6h"},
+		{365 * 24 * hour, "1d"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, models.AutoAggregationInterval(0, tt.span, 300), "span %ds", tt.span)
+	}
+}
+
+// TestUnifiedSensorDataService_CreateFromUploadExtended 测试上传的扩展数据与data中的非标准指标合并保存，同名时以data中的值为准
+func TestUnifiedSensorDataService_CreateFromUploadExtended(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		extended map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{"extended only", map[string]interface{}{"pm25": 10.0}, map[string]interface{}{"firmware": "1.2.0"},
+			map[string]interface{}{"firmware": "1.2.0"}},
+		{"merged with data metrics", map[string]interface{}{"pm25": 10.0, "tvoc": 0.3}, map[string]interface{}{"firmware": "1.2.0"},
+			map[string]interface{}{"tvoc": 0.3, "firmware": "1.2.0"}},
+		{"data wins on conflict", map[string]interface{}{"tvoc": 0.3}, map[string]interface{}{"tvoc": 0.5, "firmware": "1.2.0"},
+			map[string]interface{}{"tvoc": 0.3, "firmware": "1.2.0"}},
+		{"data metrics only", map[string]interface{}{"tvoc": 0.3}, nil, map[string]interface{}{"tvoc": 0.3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDatabase(t)
+			logger := testutil.NewLogger(t)
+			require.NoError(t, db.Create(&models.Device{ID: testutil.DeviceID1, Name: "测试设备", Type: models.DeviceTypeAirQuality}).Error)
+			dataRepo := repositories.NewUnifiedSensorDataRepository(db, logger)
+			service := NewUnifiedSensorDataService(dataRepo, repositories.NewDeviceRepository(db, logger), nil, nil, nil, nil, nil, "", logger)
+
+			require.NoError(t, service.CreateFromUpload(context.Background(), &models.UnifiedSensorDataUpload{
+				DeviceID:   testutil.DeviceID1,
+				DeviceType: string(models.DeviceTypeAirQuality),
+				Data:       tt.data,
+				Extended:   tt.extended,
+			}))
+			stored, err := dataRepo.GetLatestByDeviceID(context.Background(), testutil.DeviceID1)
+			require.NoError(t, err)
+			require.NotNil(t, stored.ExtendedData)
+			var extended map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(*stored.ExtendedData), &extended))
+			assert.Equal(t, tt.want, extended)
+		})
+	}
+}
@@ -11,9 +11,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrUserDisabled 用户账户已被禁用
+	ErrUserDisabled = errors.New("用户账户已被禁用")
+)
+
 // UserService 用户服务接口
 type UserService interface {
 	CreateUser(ctx context.Context, user *models.User) error
+	CreateUserWithRoles(ctx context.Context, user *models.User, roleIDs []uint64) error
 	GetUser(ctx context.Context, id uint) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
@@ -41,6 +49,11 @@ func NewUserService(userRepo repositories.UserRepository, logger utils.Logger) U
 
 // CreateUser 创建用户
 func (s *userService) CreateUser(ctx context.Context, user *models.User) error {
+	return s.CreateUserWithRoles(ctx, user, nil)
+}
+
+// CreateUserWithRoles 创建用户并在同一事务中分配角色（角色ID需由调用方校验）
+func (s *userService) CreateUserWithRoles(ctx context.Context, user *models.User, roleIDs []uint64) error {
 	// 检查用户名是否已存在
 	if existingUser, _ := s.userRepo.GetByUsername(user.Username); existingUser != nil {
 		return errors.New("用户名已存在")
@@ -65,8 +78,7 @@ func (s *userService) CreateUser(ctx context.Context, user *models.User) error {
 	user.UpdatedAt = now
 	user.Status = "active"
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
-		s.logger.Error("创建用户失败", utils.ErrorField(err))
+	if err := s.userRepo.CreateWithRoles(ctx, user, uniqueIDs(roleIDs)); err != nil {
 		return err
 	}
 
@@ -104,8 +116,15 @@ func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models
 	return user, nil
 }
 
-// UpdateUser 更新用户
+// UpdateUser 更新用户，用户名或邮箱已被其他用户使用时返回错误
 func (s *userService) UpdateUser(ctx context.Context, user *models.User) error {
+	if existingUser, _ := s.userRepo.GetByUsername(user.Username); existingUser != nil && existingUser.ID != user.ID {
+		return errors.New("用户名已存在")
+	}
+	if existingUser, _ := s.userRepo.GetByEmail(user.Email); existingUser != nil && existingUser.ID != user.ID {
+		return errors.New("邮箱已存在")
+	}
+
 	now := time.Now()
 	user.UpdatedAt = now
 
@@ -158,26 +177,21 @@ func (s *userService) AuthenticateUser(ctx context.Context, username, password s
 	user, err := s.userRepo.GetByUsername(username)
 	if err != nil {
 		s.logger.Error("用户认证失败", utils.ErrorField(err), utils.String("username", username))
-		return nil, errors.New("用户名或密码错误")
+		return nil, ErrInvalidCredentials
 	}
 
 	if user == nil {
-		return nil, errors.New("用户名或密码错误")
+		return nil, ErrInvalidCredentials
 	}
 
 	if user.Status != "active" {
-		return nil, errors.New("用户账户已被禁用")
+		return nil, ErrUserDisabled
 	}
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		s.logger.Error("密码验证失败", utils.ErrorField(err), utils.String("username", username))
-		return nil, errors.New("用户名或密码错误")
-	}
-
-	// 更新最后登录时间
-	if err := s.userRepo.UpdateLastLogin(uint(user.ID)); err != nil {
-		s.logger.Error("更新最后登录时间失败", utils.ErrorField(err), utils.Int("user_id", int(user.ID)))
+		return nil, ErrInvalidCredentials
 	}
 
 	s.logger.Info("用户认证成功", utils.String("username", username))
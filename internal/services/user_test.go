@@ -0,0 +1,56 @@
+package services
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/repositories"
+	"air-quality-server/internal/testutil"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUserService_CreateUserWithRoles 测试创建用户和分配角色在同一事务中，分配角色失败时不留下用户
+func TestUserService_CreateUserWithRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     func(role uint64) []uint64
+		breakRole bool // 删除用户角色表使分配角色失败
+		wantErr   bool
+		users     int64
+		userRoles int
+	}{
+		{"without roles", func(uint64) []uint64 { return nil }, false, false, 1, 0},
+		{"duplicate role ids", func(role uint64) []uint64 { return []uint64{role, role} }, false, false, 1, 1},
+		{"role assignment fails", func(role uint64) []uint64 { return []uint64{role} }, true, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDatabase(t)
+			logger := testutil.NewLogger(t)
+			ctx := context.Background()
+			roleService := NewRoleService(repositories.NewRoleRepository(db, logger), logger)
+			userService := NewUserService(repositories.NewUserRepository(db, logger), logger)
+			role, err := roleService.CreateRole(ctx, &models.RoleCreateRequest{Name: "operator", Permissions: []string{"device:read"}})
+			require.NoError(t, err)
+			if tt.breakRole {
+				require.NoError(t, db.Migrator().DropTable(&models.UserRole{}))
+			}
+
+			user := &models.User{Username: "bob", Email: "bob@air-quality.local", PasswordHash: "secret123"}
+			err = userService.CreateUserWithRoles(ctx, user, tt.roles(role.ID))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				roles, err := roleService.GetUserRoles(ctx, user.ID)
+				require.NoError(t, err)
+				assert.Len(t, roles, tt.userRoles)
+			}
+			var count int64
+			require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+			assert.Equal(t, tt.users, count)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package testutil 提供各包测试共用的SQLite数据库、日志器和HTTP请求辅助函数
+package testutil
+
+import (
+	"air-quality-server/internal/utils"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 测试设备ID
+const (
+	DeviceID1 = "hcho_001"
+	DeviceID2 = "hcho_002"
+	DeviceID3 = "hcho_003"
+)
+
+// NewDatabase 创建已迁移全部数据模型的内存SQLite数据库
+func NewDatabase(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// 内存数据库的每个连接都是独立的库，限制为单连接保证事务与查询看到同一份数据
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(utils.AllModels()...))
+	return db
+}
+
+// NewLogger 创建输出到标准输出的测试日志器
+func NewLogger(t testing.TB) utils.Logger {
+	t.Helper()
+	logger, err := utils.NewLogger("info", "console", "stdout", 100, 3, 28, true)
+	require.NoError(t, err)
+	return logger
+}
+
+// Serve 向路由发送请求，body不为空时按contentType（默认application/json）发送
+func Serve(router http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	if body != "" {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// DecodeData 解析响应中的data字段
+func DecodeData(t testing.TB, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	resp := struct {
+		Data interface{} `json:"data"`
+	}{Data: v}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+}
@@ -140,7 +140,7 @@ func (d *Database) AutoMigrate() error {
 	}
 
 	// 获取所有数据模型
-	models := getAllModels()
+	models := AllModels()
 
 	// 执行自动迁移
 	if err := d.DB.AutoMigrate(models...); err != nil {
@@ -154,17 +154,37 @@ func (d *Database) AutoMigrate() error {
 	return nil
 }
 
-// getAllModels 获取所有数据模型
-func getAllModels() []interface{} {
+// AllModels 获取所有数据模型
+func AllModels() []interface{} {
 	return []interface{}{
 		&models.User{},
 		&models.Role{},
 		&models.UserRole{},
+		&models.RevokedToken{},
 		&models.Device{},
 		&models.UnifiedSensorData{},
+		&models.SensorDataRollup1m{},
+		&models.SensorDataRollup1h{},
+		&models.SensorDataRollup1d{},
+		&models.SensorDataRollupState{},
 		&models.DeviceRuntimeStatus{},
+		&models.DeviceCommand{},
+		&models.DeviceConfigState{},
+		&models.DeviceShadow{},
+		&models.Firmware{},
+		&models.FirmwareCampaign{},
+		&models.FirmwareUpdate{},
+		&models.PendingDevice{},
+		&models.QuarantinedReading{},
+		&models.LocationNode{},
+		&models.DeviceGroup{},
+		&models.DeviceGroupMember{},
+		&models.DeviceCredential{},
+		&models.MQTTACLRule{},
 		&models.Alert{},
+		&models.AlertHistory{},
 		&models.AlertRule{},
+		&models.NotificationLog{},
 		&models.SystemConfig{},
 	}
 }
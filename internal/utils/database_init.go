@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 
 	"air-quality-server/internal/models"
 
@@ -20,11 +23,11 @@ func InitDatabase(db *gorm.DB, logger Logger, force bool) error {
 		if logger != nil {
 			logger.Warn("数据库已包含数据，跳过初始化")
 		}
-		return nil
+		return MigrateRolePermissions(db, logger)
 	}
 
 	// 执行自动迁移
-	if err := db.AutoMigrate(getAllModels()...); err != nil {
+	if err := db.AutoMigrate(AllModels()...); err != nil {
 		return fmt.Errorf("自动迁移失败: %w", err)
 	}
 
@@ -37,6 +40,10 @@ func InitDatabase(db *gorm.DB, logger Logger, force bool) error {
 		return fmt.Errorf("插入初始数据失败: %w", err)
 	}
 
+	if err := MigrateRolePermissions(db, logger); err != nil {
+		return err
+	}
+
 	if logger != nil {
 		logger.Info("数据库初始化完成")
 	}
@@ -60,7 +67,7 @@ func insertInitialData(db *gorm.DB, logger Logger) error {
 		{
 			Name:        "operator",
 			Description: stringPtr("操作员"),
-			Permissions: stringPtr(`["device:read", "device:write", "data:read", "alert:read", "alert:write"]`),
+			Permissions: stringPtr(`["device:read", "device:write", "data:read", "data:write", "alert:read", "alert:write", "alert:ack", "alert:resolve"]`),
 		},
 		{
 			Name:        "viewer",
@@ -261,6 +268,88 @@ func insertInitialData(db *gorm.DB, logger Logger) error {
 	return nil
 }
 
+// rolePermissionsKey 系统配置中记录已合并到内置角色的默认权限的键
+const rolePermissionsKey = "default_role_permissions"
+
+// MigrateRolePermissions 将models.DefaultRoles中新增的默认权限合并到已存在的内置角色
+// 已合并过的默认权限记录在系统配置中，管理员之后从角色中移除的权限不会被再次加回，可重复执行
+func MigrateRolePermissions(db *gorm.DB, logger Logger) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		merged := make(map[string][]string)
+		var record models.SystemConfig
+		err := tx.Where("key_name = ?", rolePermissionsKey).First(&record).Error
+		switch {
+		case err == nil:
+			if record.Value != nil && *record.Value != "" {
+				if err := json.Unmarshal([]byte(*record.Value), &merged); err != nil {
+					return fmt.Errorf("解析已合并的角色权限失败: %w", err)
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			record = models.SystemConfig{
+				KeyName:     rolePermissionsKey,
+				Description: stringPtr("已合并到内置角色的默认权限"),
+				Category:    stringPtr("system"),
+			}
+		default:
+			return fmt.Errorf("查询已合并的角色权限失败: %w", err)
+		}
+
+		for _, def := range models.DefaultRoles {
+			var role models.Role
+			if err := tx.Where("name = ?", def.Name).First(&role).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return fmt.Errorf("查询角色失败: %w", err)
+			}
+
+			done := make(map[string]bool)
+			for _, p := range merged[def.Name] {
+				done[p] = true
+			}
+			permissions := role.PermissionList()
+			var added []string
+			for _, p := range def.PermissionList() {
+				if !done[p] && !models.HasPermission(permissions, p) {
+					permissions = append(permissions, p)
+					added = append(added, p)
+				}
+				done[p] = true
+			}
+			merged[def.Name] = merged[def.Name][:0]
+			for p := range done {
+				merged[def.Name] = append(merged[def.Name], p)
+			}
+			sort.Strings(merged[def.Name])
+
+			if len(added) == 0 {
+				continue
+			}
+			data, err := json.Marshal(permissions)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&role).Update("permissions", string(data)).Error; err != nil {
+				return fmt.Errorf("更新角色权限失败: %w", err)
+			}
+			if logger != nil {
+				logger.Info("内置角色已补充默认权限", String("role", role.Name), Any("permissions", added))
+			}
+		}
+
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		record.Value = stringPtr(string(data))
+		if err := tx.Save(&record).Error; err != nil {
+			return fmt.Errorf("保存已合并的角色权限失败: %w", err)
+		}
+		return nil
+	})
+}
+
 // stringPtr 创建字符串指针
 func stringPtr(s string) *string {
 	return &s
@@ -0,0 +1,54 @@
+package utils_test
+
+import (
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/testutil"
+	"air-quality-server/internal/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrateRolePermissions 测试已有内置角色补充新增的默认权限，且不加回管理员移除的权限
+func TestMigrateRolePermissions(t *testing.T) {
+	db := testutil.NewDatabase(t)
+	logger := testutil.NewLogger(t)
+
+	permissions := func(name string) []string {
+		var role models.Role
+		require.NoError(t, db.Where("name = ?", name).First(&role).Error)
+		return role.PermissionList()
+	}
+
+	// 升级前的内置角色缺少data:write、alert:ack和alert:resolve，自定义角色不受影响
+	require.NoError(t, db.Create(&models.Role{Name: "operator",
+		Permissions: &[]string{`["device:read","device:write","data:read","alert:read","alert:write"]`}[0]}).Error)
+	require.NoError(t, db.Create(&models.Role{Name: "viewer",
+		Permissions: &[]string{`["device:*","data:read","alert:read"]`}[0]}).Error)
+	require.NoError(t, db.Create(&models.Role{Name: "auditor",
+		Permissions: &[]string{`["data:read"]`}[0]}).Error)
+
+	require.NoError(t, utils.MigrateRolePermissions(db, logger))
+	tests := []struct {
+		role string
+		want []string
+	}{
+		{"operator", []string{"device:read", "device:write", "data:read", "alert:read", "alert:write", "data:write", "alert:ack", "alert:resolve"}},
+		{"viewer", []string{"device:*", "data:read", "alert:read"}},
+		{"auditor", []string{"data:read"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, permissions(tt.role), tt.role)
+	}
+	var count int64
+	require.NoError(t, db.Model(&models.Role{}).Where("name = ?", "admin").Count(&count).Error)
+	assert.Zero(t, count)
+
+	// 重复执行不改变权限，管理员移除的默认权限不会被加回
+	require.NoError(t, db.Model(&models.Role{}).Where("name = ?", "operator").
+		Update("permissions", `["device:read","data:read","alert:read","alert:ack"]`).Error)
+	require.NoError(t, utils.MigrateRolePermissions(db, logger))
+	require.NoError(t, utils.MigrateRolePermissions(db, logger))
+	assert.Equal(t, []string{"device:read", "data:read", "alert:read", "alert:ack"}, permissions("operator"))
+}
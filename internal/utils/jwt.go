@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JWT令牌类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	// ErrTokenInvalid 令牌格式或签名无效
+	ErrTokenInvalid = errors.New("令牌无效")
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("令牌已过期")
+	// ErrJWTSecretMissing 未配置签名密钥，拒绝签发和校验令牌
+	ErrJWTSecretMissing = errors.New("JWT密钥未配置")
+)
+
+// JWTClaims JWT载荷
+type JWTClaims struct {
+	ID        string `json:"jti"`
+	UserID    uint64 `json:"uid"`
+	Username  string `json:"username"`
+	TokenType string `json:"typ"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ExpiresTime 过期时间
+func (c *JWTClaims) ExpiresTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// jwtHeader 固定的HS256头部
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignJWT 使用HS256签发令牌
+func SignJWT(secret string, claims *JWTClaims) (string, error) {
+	if secret == "" {
+		return "", ErrJWTSecretMissing
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("序列化令牌载荷失败: %w", err)
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + signJWT(secret, unsigned), nil
+}
+
+// ParseJWT 校验签名、签发者与有效期并解析载荷，issuer为空时不校验签发者
+func ParseJWT(secret, issuer, token string) (*JWTClaims, error) {
+	if secret == "" {
+		return nil, ErrJWTSecretMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenInvalid
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return nil, ErrTokenInvalid
+	}
+
+	expected := signJWT(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrTokenInvalid
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	var claims JWTClaims
+	if err := json.Unmarshal(rawPayload, &claims); err != nil {
+		return nil, ErrTokenInvalid
+	}
+	if claims.ID == "" || claims.UserID == 0 || (issuer != "" && claims.Issuer != issuer) {
+		return nil, ErrTokenInvalid
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// signJWT 计算HMAC-SHA256签名
+func signJWT(secret, unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,656 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"air-quality-server/internal/models"
+)
+
+// 落盘缓冲文件约定
+const (
+	spoolSegmentPrefix      = "segment-"
+	spoolSegmentSuffix      = ".log"
+	spoolCheckpointFile     = "checkpoint.json"
+	spoolDeadLetterFile     = "dead-letter.log"
+	defaultSpoolSegmentSize = 8 << 20
+	defaultSpoolBatchSize   = 500
+)
+
+// SensorDataWriter 回放时写入数据库的函数
+type SensorDataWriter func(ctx context.Context, data []models.UnifiedSensorData) error
+
+// spoolRecord 段文件中的一条记录（每行一个JSON）
+type spoolRecord struct {
+	SpooledAt time.Time                `json:"spooled_at"`
+	Data      models.UnifiedSensorData `json:"data"`
+}
+
+// spoolEntry 回放中的记录及其在段文件中的结束位置
+type spoolEntry struct {
+	raw  []byte
+	data *models.UnifiedSensorData
+	end  int64
+}
+
+// spoolCheckpoint 回放进度（当前段文件及已回放的字节偏移）
+type spoolCheckpoint struct {
+	Segment string `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// SensorDataSpool 传感器数据落盘缓冲
+// 数据库写入失败时将数据追加到本地段文件，数据库恢复后按写入顺序回放
+type SensorDataSpool struct {
+	dir         string
+	segmentSize int64
+	logger      Logger
+
+	mu         sync.Mutex
+	active     *os.File
+	activeName string
+	activeSize int64
+	nextSeq    uint64
+	checkpoint spoolCheckpoint
+	depth      int64
+	lastError  string
+	closed     bool
+
+	replayMu sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	spooled      atomic.Int64
+	replayed     atomic.Int64
+	deadLettered atomic.Int64
+}
+
+// NewSensorDataSpool 创建落盘缓冲，并恢复目录中尚未回放的段文件
+func NewSensorDataSpool(dir string, segmentSize int64, logger Logger) (*SensorDataSpool, error) {
+	if segmentSize <= 0 {
+		segmentSize = defaultSpoolSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建落盘缓冲目录失败: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SensorDataSpool{
+		dir:         dir,
+		segmentSize: segmentSize,
+		logger:      logger,
+		nextSeq:     1,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	if err := s.recover(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if s.depth > 0 {
+		logger.Warn("发现未回放的落盘缓冲数据",
+			String("dir", dir),
+			Int64("depth", s.depth))
+	}
+	return s, nil
+}
+
+// Append 追加数据到当前段文件，写入并同步到磁盘后返回
+func (s *SensorDataSpool) Append(data []models.UnifiedSensorData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	now := time.Now()
+	for i := range data {
+		line, err := json.Marshal(spoolRecord{SpooledAt: now, Data: data[i]})
+		if err != nil {
+			return fmt.Errorf("序列化缓冲记录失败: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("落盘缓冲已关闭")
+	}
+	if s.active == nil {
+		if err := s.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.active.Write(buf.Bytes())
+	s.activeSize += int64(n)
+	if err == nil {
+		err = s.active.Sync()
+	}
+	if err != nil {
+		// 写入中断的段文件不再追加，避免半行记录与后续记录粘连
+		s.sealActive()
+		return fmt.Errorf("写入落盘缓冲失败: %w", err)
+	}
+
+	s.depth += int64(len(data))
+	s.spooled.Add(int64(len(data)))
+	if s.activeSize >= s.segmentSize {
+		s.sealActive()
+	}
+	return nil
+}
+
+// Depth 获取待回放记录数
+func (s *SensorDataSpool) Depth() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
+
+// Replay 按写入顺序回放全部缓冲数据，返回成功写入的记录数
+// 批量写入失败且数据库仍可用时逐条重试，无法写入的记录转入死信文件
+func (s *SensorDataSpool) Replay(ctx context.Context, ping func() error, write SensorDataWriter, batchSize int) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	if batchSize <= 0 {
+		batchSize = defaultSpoolBatchSize
+	}
+
+	total := 0
+	for {
+		name, offset, ok := s.nextSegment()
+		if !ok {
+			return total, nil
+		}
+
+		n, err := s.replaySegment(ctx, name, offset, ping, write, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		s.removeSegment(name)
+	}
+}
+
+// StartReplayer 启动后台回放协程，数据库可用时自动回放缓冲数据
+func (s *SensorDataSpool) StartReplayer(ping func() error, write SensorDataWriter, interval time.Duration, batchSize int) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.replayOnce(ping, write, batchSize)
+			}
+		}
+	}()
+
+	s.logger.Info("落盘缓冲回放协程已启动",
+		String("dir", s.dir),
+		Duration("interval", interval))
+}
+
+// Close 停止回放协程并关闭当前段文件
+func (s *SensorDataSpool) Close() error {
+	s.cancel()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.sealActive()
+}
+
+// Stats 获取落盘缓冲统计信息
+func (s *SensorDataSpool) Stats() map[string]interface{} {
+	s.mu.Lock()
+	depth := s.depth
+	lastError := s.lastError
+	s.mu.Unlock()
+
+	segments, _ := s.listSegments()
+	stats := map[string]interface{}{
+		"depth":              depth,
+		"segments":           len(segments),
+		"oldest_age_seconds": int64(0),
+		"spooled":            s.spooled.Load(),
+		"replayed":           s.replayed.Load(),
+		"dead_lettered":      s.deadLettered.Load(),
+		"last_replay_error":  lastError,
+	}
+
+	if depth > 0 {
+		if oldest, ok := s.oldestSpooledAt(); ok {
+			stats["oldest_spooled_at"] = oldest
+			stats["oldest_age_seconds"] = int64(time.Since(oldest).Seconds())
+		}
+	}
+	return stats
+}
+
+// replayOnce 执行一次回放检查
+func (s *SensorDataSpool) replayOnce(ping func() error, write SensorDataWriter, batchSize int) {
+	if s.Depth() == 0 {
+		return
+	}
+	if err := ping(); err != nil {
+		s.logger.Debug("数据库仍不可用，暂缓回放落盘缓冲", ErrorField(err))
+		return
+	}
+
+	start := time.Now()
+	n, err := s.Replay(s.ctx, ping, write, batchSize)
+
+	s.mu.Lock()
+	if err != nil {
+		s.lastError = err.Error()
+	} else {
+		s.lastError = ""
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.Warn("回放落盘缓冲中断",
+			Int("replayed", n),
+			Int64("remaining", s.Depth()),
+			ErrorField(err))
+		return
+	}
+	if n > 0 {
+		s.logger.Info("落盘缓冲回放完成",
+			Int("replayed", n),
+			Duration("elapsed", time.Since(start)))
+	}
+}
+
+// replaySegment 从检查点开始回放一个段文件
+func (s *SensorDataSpool) replaySegment(ctx context.Context, name string, offset int64, ping func() error, write SensorDataWriter, batchSize int) (int, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return 0, fmt.Errorf("打开缓冲段文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("定位缓冲段文件失败: %w", err)
+	}
+
+	reader := bufio.NewReader(f)
+	pos := offset
+	written := 0
+	entries := make([]spoolEntry, 0, batchSize)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return written, fmt.Errorf("读取缓冲段文件失败: %w", readErr)
+		}
+
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			pos += int64(len(line))
+			entry := spoolEntry{raw: bytes.TrimRight(line, "\n"), end: pos}
+			var record spoolRecord
+			if err := json.Unmarshal(line, &record); err == nil {
+				entry.data = &record.Data
+			}
+			entries = append(entries, entry)
+		} else if len(line) > 0 {
+			// 段文件末尾的半行记录（写入过程中进程退出），无法恢复
+			s.logger.Warn("丢弃不完整的缓冲记录",
+				String("segment", name),
+				Int64("offset", pos))
+		}
+
+		if len(entries) >= batchSize || (readErr == io.EOF && len(entries) > 0) {
+			n, err := s.flushEntries(ctx, name, entries, ping, write)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			entries = entries[:0]
+		}
+
+		if readErr == io.EOF {
+			return written, nil
+		}
+	}
+}
+
+// flushEntries 写入一批回放记录并推进检查点
+func (s *SensorDataSpool) flushEntries(ctx context.Context, name string, entries []spoolEntry, ping func() error, write SensorDataWriter) (int, error) {
+	records := make([]models.UnifiedSensorData, 0, len(entries))
+	for _, entry := range entries {
+		if entry.data != nil {
+			records = append(records, replayRecord(entry.data))
+		}
+	}
+
+	if len(records) > 0 {
+		if err := write(ctx, records); err != nil {
+			if pingErr := ping(); pingErr != nil {
+				return 0, err
+			}
+			// 数据库可用但批量写入失败，逐条写入定位异常记录
+			return s.flushEntriesOneByOne(ctx, name, entries, ping, write)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.data == nil {
+			s.deadLetter(entry.raw, "记录无法解析")
+		}
+	}
+	s.advance(name, entries[len(entries)-1].end, len(entries))
+	s.replayed.Add(int64(len(records)))
+	return len(records), nil
+}
+
+// flushEntriesOneByOne 逐条写入回放记录，每条处理后推进检查点
+func (s *SensorDataSpool) flushEntriesOneByOne(ctx context.Context, name string, entries []spoolEntry, ping func() error, write SensorDataWriter) (int, error) {
+	written := 0
+	for _, entry := range entries {
+		if entry.data == nil {
+			s.deadLetter(entry.raw, "记录无法解析")
+		} else if err := write(ctx, []models.UnifiedSensorData{replayRecord(entry.data)}); err != nil {
+			if pingErr := ping(); pingErr != nil {
+				return written, err
+			}
+			s.deadLetter(entry.raw, err.Error())
+		} else {
+			written++
+			s.replayed.Add(1)
+		}
+		s.advance(name, entry.end, 1)
+	}
+	return written, nil
+}
+
+// replayRecord 复制回放记录并清空主键，由数据库重新分配
+func replayRecord(data *models.UnifiedSensorData) models.UnifiedSensorData {
+	record := *data
+	record.ID = 0
+	return record
+}
+
+// deadLetter 将无法回放的记录写入死信文件
+func (s *SensorDataSpool) deadLetter(raw []byte, reason string) {
+	s.deadLettered.Add(1)
+	s.logger.Warn("缓冲记录无法回放，已转入死信文件", String("reason", reason))
+
+	line, err := json.Marshal(map[string]interface{}{
+		"dead_at": time.Now(),
+		"reason":  reason,
+		"raw":     string(raw),
+	})
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, spoolDeadLetterFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.logger.Error("写入死信文件失败", ErrorField(err))
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		s.logger.Error("写入死信文件失败", ErrorField(err))
+	}
+}
+
+// nextSegment 获取最早的待回放段文件，若为当前写入段则先封存
+func (s *SensorDataSpool) nextSegment() (string, int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.listSegments()
+	if err != nil || len(segments) == 0 {
+		return "", 0, false
+	}
+
+	name := segments[0]
+	if name == s.activeName && s.active != nil {
+		s.sealActive()
+	}
+
+	var offset int64
+	if s.checkpoint.Segment == name {
+		offset = s.checkpoint.Offset
+	}
+	return name, offset, true
+}
+
+// advance 推进回放检查点
+func (s *SensorDataSpool) advance(name string, offset int64, lines int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoint = spoolCheckpoint{Segment: name, Offset: offset}
+	s.depth -= int64(lines)
+	if s.depth < 0 {
+		s.depth = 0
+	}
+	if err := s.saveCheckpoint(); err != nil {
+		s.logger.Error("保存落盘缓冲检查点失败", ErrorField(err))
+	}
+}
+
+// removeSegment 删除已回放完毕的段文件并清除检查点
+func (s *SensorDataSpool) removeSegment(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("删除缓冲段文件失败", String("segment", name), ErrorField(err))
+		return
+	}
+	s.checkpoint = spoolCheckpoint{}
+	if err := os.Remove(filepath.Join(s.dir, spoolCheckpointFile)); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("删除落盘缓冲检查点失败", ErrorField(err))
+	}
+}
+
+// openSegment 创建新的段文件作为当前写入段
+func (s *SensorDataSpool) openSegment() error {
+	name := fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, s.nextSeq, spoolSegmentSuffix)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("创建缓冲段文件失败: %w", err)
+	}
+	s.nextSeq++
+	s.active = f
+	s.activeName = name
+	s.activeSize = 0
+	return nil
+}
+
+// sealActive 关闭当前写入段，后续追加将写入新段文件
+func (s *SensorDataSpool) sealActive() error {
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	s.activeName = ""
+	s.activeSize = 0
+	return err
+}
+
+// recover 加载检查点并统计已有段文件中的待回放记录
+func (s *SensorDataSpool) recover() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolCheckpointFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &s.checkpoint); err != nil {
+			s.logger.Warn("落盘缓冲检查点损坏，将从段文件起始位置回放", ErrorField(err))
+			s.checkpoint = spoolCheckpoint{}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("读取落盘缓冲检查点失败: %w", err)
+	}
+
+	segments, err := s.listSegments()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, name := range segments {
+		var offset int64
+		if name == s.checkpoint.Segment {
+			offset = s.checkpoint.Offset
+			found = true
+		}
+		count, err := countSpoolLines(filepath.Join(s.dir, name), offset)
+		if err != nil {
+			return err
+		}
+		s.depth += count
+	}
+	if !found {
+		s.checkpoint = spoolCheckpoint{}
+	}
+
+	if len(segments) > 0 {
+		s.nextSeq = segmentSeq(segments[len(segments)-1]) + 1
+	}
+	return nil
+}
+
+// saveCheckpoint 原子写入检查点文件
+func (s *SensorDataSpool) saveCheckpoint() error {
+	data, err := json.Marshal(s.checkpoint)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, spoolCheckpointFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// listSegments 按序号升序列出段文件
+func (s *SensorDataSpool) listSegments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取落盘缓冲目录失败: %w", err)
+	}
+
+	var segments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, spoolSegmentPrefix) && strings.HasSuffix(name, spoolSegmentSuffix) {
+			segments = append(segments, name)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// oldestSpooledAt 获取最早一条待回放记录的落盘时间
+func (s *SensorDataSpool) oldestSpooledAt() (time.Time, bool) {
+	s.mu.Lock()
+	checkpoint := s.checkpoint
+	s.mu.Unlock()
+
+	segments, err := s.listSegments()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	for _, name := range segments {
+		var offset int64
+		if name == checkpoint.Segment {
+			offset = checkpoint.Offset
+		}
+		if spooledAt, ok := firstSpooledAt(filepath.Join(s.dir, name), offset); ok {
+			return spooledAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// firstSpooledAt 读取段文件中指定偏移后的第一条有效记录时间
+func firstSpooledAt(path string, offset int64) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return time.Time{}, false
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var record spoolRecord
+			if json.Unmarshal(line, &record) == nil {
+				return record.SpooledAt, true
+			}
+		}
+		if err != nil {
+			return time.Time{}, false
+		}
+	}
+}
+
+// countSpoolLines 统计段文件中指定偏移后的完整记录行数
+func countSpoolLines(path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("打开缓冲段文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("定位缓冲段文件失败: %w", err)
+	}
+
+	var count int64
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("读取缓冲段文件失败: %w", err)
+		}
+	}
+}
+
+// segmentSeq 解析段文件序号
+func segmentSeq(name string) uint64 {
+	seq := strings.TrimSuffix(strings.TrimPrefix(name, spoolSegmentPrefix), spoolSegmentSuffix)
+	n, _ := strconv.ParseUint(seq, 10, 64)
+	return n
+}
@@ -16,12 +16,14 @@ CREATE TABLE IF NOT EXISTS devices (
     location_latitude DECIMAL(10, 8) COMMENT '纬度',
     location_longitude DECIMAL(11, 8) COMMENT '经度',
     location_address VARCHAR(200) COMMENT '地址',
+    location_id BIGINT UNSIGNED COMMENT '所属位置节点',
     status ENUM('online', 'offline', 'maintenance') DEFAULT 'offline' COMMENT '设备状态',
     config JSON COMMENT '设备配置',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     INDEX idx_status (status),
     INDEX idx_location (location_latitude, location_longitude),
+    INDEX idx_location_id (location_id),
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备信息表';
 
@@ -47,9 +49,11 @@ CREATE TABLE IF NOT EXISTS unified_sensor_data (
     location_address VARCHAR(200) COMMENT '地址',
     quality_score DECIMAL(4, 2) COMMENT '数据质量评分',
     extended_data JSON COMMENT '扩展数据',
+    message_id VARCHAR(64) COMMENT '客户端消息ID',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    UNIQUE KEY idx_device_message (device_id, message_id),
     INDEX idx_device_timestamp (device_id, timestamp),
     INDEX idx_device_type (device_type),
     INDEX idx_sensor_id (sensor_id),
@@ -98,21 +102,38 @@ CREATE TABLE IF NOT EXISTS user_roles (
     FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户角色关联表';
 
+-- 已注销令牌表
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    token_id VARCHAR(64) UNIQUE NOT NULL COMMENT '令牌ID(jti)',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    expires_at TIMESTAMP NOT NULL COMMENT '令牌过期时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '注销时间',
+    INDEX idx_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='已注销令牌表';
+
 -- 告警规则表
 CREATE TABLE IF NOT EXISTS alert_rules (
     id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '规则ID',
     name VARCHAR(100) NOT NULL COMMENT '规则名称',
     device_id VARCHAR(64) COMMENT '设备ID，NULL表示所有设备',
+    group_id BIGINT UNSIGNED COMMENT '限定设备分组，NULL表示不限',
+    location_id BIGINT UNSIGNED COMMENT '限定位置子树，NULL表示不限',
     metric VARCHAR(50) NOT NULL COMMENT '监控指标',
     condition_type ENUM('gt', 'lt', 'eq', 'ne', 'gte', 'lte') NOT NULL COMMENT '条件类型',
     threshold_value DECIMAL(10, 2) NOT NULL COMMENT '阈值',
     duration_seconds INT DEFAULT 0 COMMENT '持续时间(秒)',
+    clear_threshold DECIMAL(10, 2) COMMENT '恢复阈值，NULL表示按回差计算',
+    hysteresis DECIMAL(10, 2) DEFAULT 0 COMMENT '恢复回差',
     severity ENUM('critical', 'warning', 'info') DEFAULT 'warning' COMMENT '严重程度',
     enabled BOOLEAN DEFAULT TRUE COMMENT '是否启用',
     notification_channels JSON COMMENT '通知渠道',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     INDEX idx_device_id (device_id),
+    INDEX idx_group_id (group_id),
+    INDEX idx_location_id (location_id),
     INDEX idx_enabled (enabled),
     INDEX idx_severity (severity),
     FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
@@ -146,6 +167,42 @@ CREATE TABLE IF NOT EXISTS alerts (
     FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='告警记录表';
 
+-- 告警状态变更历史表
+CREATE TABLE IF NOT EXISTS alert_histories (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    alert_id BIGINT NOT NULL COMMENT '告警ID',
+    action VARCHAR(20) NOT NULL COMMENT '变更动作(triggered/acknowledged/resolved/auto_resolved)',
+    from_status VARCHAR(20) COMMENT '变更前状态',
+    to_status VARCHAR(20) NOT NULL COMMENT '变更后状态',
+    user_id BIGINT COMMENT '操作用户，NULL表示系统自动处理',
+    comment TEXT COMMENT '备注',
+    value DECIMAL(10, 2) COMMENT '变更时的指标值',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_alert_id (alert_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_created_at (created_at),
+    FOREIGN KEY (alert_id) REFERENCES alerts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='告警状态变更历史表';
+
+-- 告警通知投递记录表
+CREATE TABLE IF NOT EXISTS notification_logs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    alert_id BIGINT NOT NULL COMMENT '告警ID',
+    rule_id BIGINT COMMENT '规则ID',
+    event VARCHAR(20) NOT NULL COMMENT '通知事件(triggered/escalated/resolved)',
+    channel VARCHAR(20) NOT NULL COMMENT '通知渠道',
+    target VARCHAR(255) COMMENT '投递目标',
+    attempt INT NOT NULL COMMENT '尝试次数',
+    status VARCHAR(20) NOT NULL COMMENT '投递状态(success/failed)',
+    error TEXT COMMENT '失败原因',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_alert_id (alert_id),
+    INDEX idx_rule_id (rule_id),
+    INDEX idx_status (status),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='告警通知投递记录表';
+
 -- 系统配置表
 CREATE TABLE IF NOT EXISTS system_configs (
     id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '配置ID',
@@ -182,11 +239,251 @@ CREATE TABLE IF NOT EXISTS device_runtime_status (
     FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备运行时状态表';
 
+-- 设备指令表
+CREATE TABLE IF NOT EXISTS device_commands (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    command_id VARCHAR(64) NOT NULL COMMENT '关联ID，设备响应时原样返回',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    command VARCHAR(50) NOT NULL COMMENT '指令类型',
+    params JSON COMMENT '指令参数',
+    status VARCHAR(20) NOT NULL COMMENT '指令状态: pending/acked/failed/timed_out',
+    result JSON COMMENT '设备返回的执行结果',
+    error_message TEXT COMMENT '错误信息',
+    requested_by BIGINT UNSIGNED COMMENT '下发用户',
+    sent_at TIMESTAMP NULL COMMENT '下发时间',
+    expires_at TIMESTAMP NULL COMMENT '等待响应的截止时间',
+    responded_at TIMESTAMP NULL COMMENT '响应时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_command_id (command_id),
+    INDEX idx_device_id (device_id),
+    INDEX idx_status (status),
+    INDEX idx_expires_at (expires_at),
+    INDEX idx_created_at (created_at),
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备指令表';
+
+-- 设备配置同步状态表
+CREATE TABLE IF NOT EXISTS device_config_states (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    desired JSON COMMENT '期望配置',
+    desired_version BIGINT DEFAULT 0 COMMENT '期望配置版本，每次变更递增',
+    desired_at TIMESTAMP NULL COMMENT '期望配置变更时间',
+    reported JSON COMMENT '设备上报的已应用配置',
+    reported_version BIGINT DEFAULT 0 COMMENT '设备已应用的配置版本',
+    reported_at TIMESTAMP NULL COMMENT '设备上报时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_device_id (device_id),
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备配置同步状态表';
+
+-- 设备影子表
+CREATE TABLE IF NOT EXISTS device_shadows (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    device_type VARCHAR(50) COMMENT '设备类型',
+    sensor_id VARCHAR(64) COMMENT '最近一条数据的传感器ID',
+    sensor_type VARCHAR(50) COMMENT '最近一条数据的传感器类型',
+    data_quality VARCHAR(20) COMMENT '最近一条数据的数据质量',
+    metrics JSON COMMENT '各指标最近取值',
+    last_data_time TIMESTAMP NULL COMMENT '最后数据时间',
+    version BIGINT DEFAULT 0 COMMENT '影子版本，每次更新递增',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_device_id (device_id),
+    INDEX idx_last_data_time (last_data_time),
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备影子表';
+
+-- 固件表
+CREATE TABLE IF NOT EXISTS firmware (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '固件ID',
+    device_type VARCHAR(50) NOT NULL COMMENT '适用设备类型',
+    version VARCHAR(50) NOT NULL COMMENT '固件版本',
+    file_name VARCHAR(255) NOT NULL COMMENT '上传时的原始文件名',
+    file_path VARCHAR(512) NOT NULL COMMENT '本地存储路径',
+    size BIGINT NOT NULL COMMENT '文件大小(字节)',
+    sha256 CHAR(64) NOT NULL COMMENT 'SHA-256摘要',
+    notes TEXT COMMENT '版本说明',
+    uploaded_by BIGINT UNSIGNED COMMENT '上传用户',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY idx_firmware_type_version (device_type, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='固件表';
+
+-- 固件升级活动表
+CREATE TABLE IF NOT EXISTS firmware_campaigns (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '活动ID',
+    name VARCHAR(100) NOT NULL COMMENT '活动名称',
+    firmware_id BIGINT UNSIGNED NOT NULL COMMENT '固件ID',
+    device_type VARCHAR(50) NOT NULL COMMENT '目标设备类型',
+    target_devices JSON COMMENT '指定设备列表，为空表示该类型全部设备',
+    stages JSON NOT NULL COMMENT '各阶段累计覆盖百分比',
+    current_stage INT DEFAULT 0 COMMENT '当前阶段序号，从0开始',
+    status VARCHAR(20) NOT NULL COMMENT '活动状态',
+    failure_threshold DOUBLE COMMENT '失败率阈值',
+    total_devices INT DEFAULT 0 COMMENT '目标设备数',
+    halt_reason VARCHAR(255) COMMENT '熔断原因',
+    created_by BIGINT UNSIGNED COMMENT '创建用户',
+    started_at TIMESTAMP NULL COMMENT '开始时间',
+    completed_at TIMESTAMP NULL COMMENT '完成时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX idx_firmware_id (firmware_id),
+    INDEX idx_status (status),
+    FOREIGN KEY (firmware_id) REFERENCES firmware(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='固件升级活动表';
+
+-- 设备升级记录表
+CREATE TABLE IF NOT EXISTS firmware_updates (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    campaign_id BIGINT UNSIGNED NOT NULL COMMENT '升级活动ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    stage INT NOT NULL COMMENT '所属阶段序号',
+    status VARCHAR(20) NOT NULL COMMENT '升级状态',
+    progress INT DEFAULT 0 COMMENT '进度百分比',
+    error_message TEXT COMMENT '失败原因',
+    notified_at TIMESTAMP NULL COMMENT '首次推送时间',
+    completed_at TIMESTAMP NULL COMMENT '结束时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY idx_firmware_update_campaign_device (campaign_id, device_id),
+    INDEX idx_device_id (device_id),
+    INDEX idx_status (status),
+    INDEX idx_notified_at (notified_at),
+    FOREIGN KEY (campaign_id) REFERENCES firmware_campaigns(id) ON DELETE CASCADE,
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备升级记录表';
+
+-- 待审批设备表（pending接入策略）
+CREATE TABLE IF NOT EXISTS pending_devices (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    device_type VARCHAR(50) NOT NULL COMMENT '首次上报的设备类型',
+    reading_count BIGINT DEFAULT 0 COMMENT '累计上报数据条数',
+    quarantined_count BIGINT DEFAULT 0 COMMENT '已隔离保存的数据条数',
+    first_seen_at TIMESTAMP NOT NULL COMMENT '首次上报时间',
+    last_seen_at TIMESTAMP NOT NULL COMMENT '最后上报时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY idx_pending_devices_device_id (device_id),
+    INDEX idx_last_seen_at (last_seen_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='待审批设备表';
+
+-- 隔离数据表（待审批设备的上报数据）
+CREATE TABLE IF NOT EXISTS quarantined_readings (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    data JSON NOT NULL COMMENT '序列化的传感器数据',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '接收时间',
+    INDEX idx_device_id (device_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='待审批设备隔离数据表';
+
+
+-- 位置层级表（站点→楼栋→楼层→房间）
+CREATE TABLE IF NOT EXISTS locations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '位置ID',
+    parent_id BIGINT UNSIGNED COMMENT '上级位置ID，NULL表示站点',
+    name VARCHAR(100) NOT NULL COMMENT '名称',
+    type VARCHAR(20) NOT NULL COMMENT '层级类型：site/building/floor/room',
+    path VARCHAR(255) NOT NULL COMMENT '祖先ID路径，如/1/4/9/',
+    description TEXT COMMENT '描述',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX idx_parent_id (parent_id),
+    INDEX idx_path (path)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='位置层级表';
+
+-- 设备分组表（也用作标签）
+CREATE TABLE IF NOT EXISTS device_groups (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '分组ID',
+    name VARCHAR(100) NOT NULL COMMENT '分组名称',
+    description TEXT COMMENT '描述',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY idx_device_groups_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备分组表';
+
+-- 设备分组成员表
+CREATE TABLE IF NOT EXISTS device_group_members (
+    group_id BIGINT UNSIGNED NOT NULL COMMENT '分组ID',
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '加入时间',
+    PRIMARY KEY (group_id, device_id),
+    INDEX idx_device_id (device_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备分组成员表';
+
+-- 传感器数据分钟汇总表（每设备每指标每时间桶一行，_readings记录原始数据条数）
+CREATE TABLE IF NOT EXISTS sensor_data_1m (
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    metric VARCHAR(64) NOT NULL COMMENT '指标名称',
+    bucket_start DATETIME(3) NOT NULL COMMENT '时间桶起始时间',
+    device_type VARCHAR(50) COMMENT '设备类型',
+    `avg` DOUBLE COMMENT '平均值',
+    `min` DOUBLE COMMENT '最小值',
+    `max` DOUBLE COMMENT '最大值',
+    `sum` DOUBLE COMMENT '累计值',
+    `count` BIGINT COMMENT '读数条数',
+    `last` DOUBLE COMMENT '最后一个读数',
+    last_at DATETIME(3) COMMENT '最后一个读数的时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (device_id, metric, bucket_start),
+    INDEX idx_sensor_data_1m_bucket_start (bucket_start),
+    INDEX idx_sensor_data_1m_device_type (device_type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='传感器数据分钟汇总表';
+
+-- 传感器数据小时汇总表（每设备每指标每时间桶一行，_readings记录原始数据条数）
+CREATE TABLE IF NOT EXISTS sensor_data_1h (
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    metric VARCHAR(64) NOT NULL COMMENT '指标名称',
+    bucket_start DATETIME(3) NOT NULL COMMENT '时间桶起始时间',
+    device_type VARCHAR(50) COMMENT '设备类型',
+    `avg` DOUBLE COMMENT '平均值',
+    `min` DOUBLE COMMENT '最小值',
+    `max` DOUBLE COMMENT '最大值',
+    `sum` DOUBLE COMMENT '累计值',
+    `count` BIGINT COMMENT '读数条数',
+    `last` DOUBLE COMMENT '最后一个读数',
+    last_at DATETIME(3) COMMENT '最后一个读数的时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (device_id, metric, bucket_start),
+    INDEX idx_sensor_data_1h_bucket_start (bucket_start),
+    INDEX idx_sensor_data_1h_device_type (device_type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='传感器数据小时汇总表';
+
+-- 传感器数据天汇总表（每设备每指标每时间桶一行，_readings记录原始数据条数）
+CREATE TABLE IF NOT EXISTS sensor_data_1d (
+    device_id VARCHAR(64) NOT NULL COMMENT '设备ID',
+    metric VARCHAR(64) NOT NULL COMMENT '指标名称',
+    bucket_start DATETIME(3) NOT NULL COMMENT '时间桶起始时间',
+    device_type VARCHAR(50) COMMENT '设备类型',
+    `avg` DOUBLE COMMENT '平均值',
+    `min` DOUBLE COMMENT '最小值',
+    `max` DOUBLE COMMENT '最大值',
+    `sum` DOUBLE COMMENT '累计值',
+    `count` BIGINT COMMENT '读数条数',
+    `last` DOUBLE COMMENT '最后一个读数',
+    last_at DATETIME(3) COMMENT '最后一个读数的时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (device_id, metric, bucket_start),
+    INDEX idx_sensor_data_1d_bucket_start (bucket_start),
+    INDEX idx_sensor_data_1d_device_type (device_type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='传感器数据天汇总表';
+
+-- 汇总任务进度表
+CREATE TABLE IF NOT EXISTS sensor_data_rollup_state (
+    name VARCHAR(50) PRIMARY KEY COMMENT '任务名称',
+    last_id BIGINT UNSIGNED DEFAULT 0 COMMENT '已处理的原始数据最大ID',
+    rolled_until DATETIME(3) COMMENT '汇总已覆盖到的入库时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='传感器数据汇总任务进度表';
 
 -- 插入默认角色
 INSERT IGNORE INTO roles (name, description, permissions) VALUES 
 ('admin', '系统管理员', '["*"]'),
-('operator', '操作员', '["device:read", "device:write", "data:read", "alert:read", "alert:write"]'),
+('operator', '操作员', '["device:read", "device:write", "data:read", "alert:read", "alert:write", "alert:ack", "alert:resolve"]'),
 ('viewer', '查看者', '["device:read", "data:read", "alert:read"]');
 
 -- 插入默认用户 (密码: admin123)
@@ -43,7 +43,12 @@ func (h *WebHandlers) API(c *gin.Context) {
 
 	switch apiType {
 	case "device-stats":
-		stats, err := h.getDeviceStats(ctx)
+		deviceIDs, err := h.locationDeviceIDs(ctx, parseLocationID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		stats, err := h.getDeviceStats(ctx, deviceIDs)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -53,7 +58,12 @@ func (h *WebHandlers) API(c *gin.Context) {
 	case "latest-data":
 		deviceID := c.Query("device_id")
 		if deviceID == "" {
-			data, err := h.getLatestData(ctx)
+			deviceIDs, err := h.locationDeviceIDs(ctx, parseLocationID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			data, err := h.getLatestData(ctx, deviceIDs)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -94,22 +104,18 @@ func (h *WebHandlers) API(c *gin.Context) {
 			return
 		}
 
-		endTime := time.Now()
-		startTime := endTime.Add(-time.Duration(hours) * time.Hour)
-
-		historyData, err := h.services.UnifiedSensorData.GetDataByTimeRange(ctx, deviceID, startTime.Unix(), endTime.Unix())
+		chartData, err := h.getAggregatedChartData(ctx, deviceID, sensorID, metric, hours)
 		if err != nil {
 			h.logger.Error("获取图表数据失败", utils.ErrorField(err), utils.String("device_id", deviceID))
 			h.handleAPIError(c, "failed to get chart data", http.StatusInternalServerError)
 			return
 		}
 
-		if len(historyData) == 0 {
+		if len(chartData.Labels) == 0 {
 			h.handleAPIError(c, "no data found for the specified criteria", http.StatusNotFound)
 			return
 		}
 
-		chartData := h.convertToChartDataFromUnified(historyData, metric, sensorID)
 		c.JSON(http.StatusOK, chartData)
 
 	case "sensors":
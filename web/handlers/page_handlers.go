@@ -16,15 +16,27 @@ import (
 func (h *WebHandlers) Dashboard(c *gin.Context) {
 	ctx := context.Background()
 
+	// 按站点/房间筛选（包含下级位置）
+	locationID := parseLocationID(c)
+	locations, err := h.getLocationOptions(ctx, locationID)
+	if err != nil {
+		h.logger.Error("获取位置列表失败", utils.ErrorField(err))
+	}
+	deviceIDs, err := h.locationDeviceIDs(ctx, locationID)
+	if err != nil {
+		h.logger.Error("获取位置设备失败", utils.ErrorField(err))
+		deviceIDs = []string{}
+	}
+
 	// 获取设备统计信息
-	deviceStats, err := h.getDeviceStats(ctx)
+	deviceStats, err := h.getDeviceStats(ctx, deviceIDs)
 	if err != nil {
 		h.logger.Error("获取设备统计失败", utils.ErrorField(err))
 		deviceStats = &DeviceStats{}
 	}
 
 	// 获取最新数据
-	latestData, err := h.getLatestData(ctx)
+	latestData, err := h.getLatestData(ctx, deviceIDs)
 	if err != nil {
 		h.logger.Error("获取最新数据失败", utils.ErrorField(err))
 		latestData = []AirQualityDataSummary{}
@@ -43,6 +55,8 @@ func (h *WebHandlers) Dashboard(c *gin.Context) {
 		"DeviceStats": deviceStats,
 		"LatestData":  latestData,
 		"AlertStats":  alertStats,
+		"Locations":   locations,
+		"LocationID":  locationID,
 		"CurrentTime": time.Now().Format("2006-01-02 15:04:05"),
 	}
 
@@ -341,19 +355,16 @@ func (h *WebHandlers) Charts(c *gin.Context) {
 
 	var chartData *ChartData
 	if deviceID != "" {
-		// 根据时间范围获取数据
-		hours, _ := strconv.Atoi(timeRange)
-		endTime := time.Now()
-		startTime := endTime.Add(-time.Duration(hours) * time.Hour)
-
-		historyData, err := h.services.UnifiedSensorData.GetDataByTimeRange(ctx, deviceID, startTime.Unix(), endTime.Unix())
+		// 根据时间范围获取聚合后的图表数据
+		hours, err := strconv.Atoi(timeRange)
+		if err != nil || hours <= 0 || hours > 8760 {
+			hours = 24
+		}
+		chartData, err = h.getAggregatedChartData(ctx, deviceID, sensorID, metric, hours)
 		if err != nil {
 			h.logger.Error("获取图表数据失败", utils.ErrorField(err))
-			historyData = []models.UnifiedSensorData{}
+			chartData = &ChartData{Labels: []string{}, Datasets: []Dataset{}}
 		}
-
-		// 转换为图表数据格式
-		chartData = h.convertToChartDataFromUnified(historyData, metric, sensorID)
 	}
 
 	data := gin.H{
@@ -397,10 +408,16 @@ func (h *WebHandlers) Alerts(c *gin.Context) {
 	// 计算分页信息
 	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
 
+	// 确认/解决操作调用需认证的API，只对有权限的登录用户显示
+	user, permissions := h.sessionUser(c)
+
 	data := gin.H{
 		"Title":       "告警管理",
 		"CurrentPage": "alerts",
-		"Alerts":      alerts,
+		"CurrentUser": user,
+		"CanAck":      models.HasPermission(permissions, models.PermissionAlertAck),
+		"CanResolve":  models.HasPermission(permissions, models.PermissionAlertResolve),
+		"Alerts":      h.buildAlertViews(ctx, alerts),
 		"Pagination": Pagination{
 			CurrentPage: page,
 			TotalPages:  int(totalPages),
@@ -411,3 +428,68 @@ func (h *WebHandlers) Alerts(c *gin.Context) {
 
 	c.HTML(http.StatusOK, "base.html", data)
 }
+
+// buildAlertViews 组装告警列表及其状态变更时间线
+func (h *WebHandlers) buildAlertViews(ctx context.Context, alerts []models.Alert) []AlertView {
+	ids := make([]uint64, 0, len(alerts))
+	for _, alert := range alerts {
+		ids = append(ids, alert.ID)
+	}
+	histories, err := h.services.Alert.GetAlertHistories(ctx, ids)
+	if err != nil {
+		h.logger.Error("获取告警历史失败", utils.ErrorField(err))
+		histories = map[uint64][]models.AlertHistory{}
+	}
+
+	operators := make(map[uint64]string)
+	operatorName := func(userID *uint64) string {
+		if userID == nil {
+			return "系统"
+		}
+		if name, ok := operators[*userID]; ok {
+			return name
+		}
+		name := fmt.Sprintf("用户#%d", *userID)
+		if user, err := h.services.User.GetUser(ctx, uint(*userID)); err == nil && user != nil {
+			name = user.Username
+		}
+		operators[*userID] = name
+		return name
+	}
+
+	views := make([]AlertView, 0, len(alerts))
+	for _, alert := range alerts {
+		view := AlertView{Alert: alert}
+		for _, history := range histories[alert.ID] {
+			entry := AlertTimelineEntry{
+				Time:       history.CreatedAt,
+				Action:     string(history.Action),
+				ActionName: alertActionName(history.Action),
+				ToStatus:   history.ToStatus,
+				Operator:   operatorName(history.UserID),
+			}
+			if history.Comment != nil {
+				entry.Comment = *history.Comment
+			}
+			view.Timeline = append(view.Timeline, entry)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// alertActionName 告警状态变更动作的显示名称
+func alertActionName(action models.AlertAction) string {
+	switch action {
+	case models.AlertActionTriggered:
+		return "触发"
+	case models.AlertActionAcknowledged:
+		return "确认"
+	case models.AlertActionResolved:
+		return "解决"
+	case models.AlertActionAutoResolved:
+		return "自动恢复"
+	default:
+		return string(action)
+	}
+}
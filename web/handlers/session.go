@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"air-quality-server/internal/middleware"
+	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionUser 从访问令牌Cookie识别当前登录用户及其权限，未登录或令牌无效时返回nil
+func (h *WebHandlers) sessionUser(c *gin.Context) (*models.User, []string) {
+	if h.services.Auth == nil {
+		return nil, nil
+	}
+	token, err := c.Cookie(middleware.AccessTokenCookie)
+	if err != nil || token == "" {
+		return nil, nil
+	}
+	user, _, err := h.services.Auth.Authenticate(c.Request.Context(), token)
+	if err != nil {
+		return nil, nil
+	}
+	if h.services.Role == nil {
+		return user, nil
+	}
+	permissions, err := h.services.Role.GetUserPermissions(c.Request.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("获取用户权限失败", utils.ErrorField(err), utils.Int64("user_id", int64(user.ID)))
+		return user, nil
+	}
+	return user, permissions
+}
+
+// Login 登录页面，登录成功后由登录接口写入访问令牌Cookie并跳转回next
+func (h *WebHandlers) Login(c *gin.Context) {
+	next := c.Query("next")
+	// 只允许跳转到站内路径
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/dashboard"
+	}
+
+	user, _ := h.sessionUser(c)
+	c.HTML(http.StatusOK, "base.html", gin.H{
+		"Title":       "登录",
+		"CurrentPage": "login",
+		"CurrentUser": user,
+		"Next":        next,
+	})
+}
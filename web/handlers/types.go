@@ -1,6 +1,9 @@
 package handlers
 
-import "time"
+import (
+	"air-quality-server/internal/models"
+	"time"
+)
 
 // DeviceStats 设备统计信息
 type DeviceStats struct {
@@ -10,6 +13,13 @@ type DeviceStats struct {
 	ActiveDevices  int `json:"active_devices"`
 }
 
+// LocationOption 仪表板位置下拉选项（按层级缩进）
+type LocationOption struct {
+	ID       uint64 `json:"id"`
+	Label    string `json:"label"`
+	Selected bool   `json:"selected"`
+}
+
 // AlertStats 告警统计信息
 type AlertStats struct {
 	TotalAlerts      int `json:"total_alerts"`
@@ -38,6 +48,22 @@ type AirQualityDataSummary struct {
 	Status       string    `json:"status"`
 }
 
+// AlertView 告警页面展示数据（含状态变更时间线）
+type AlertView struct {
+	models.Alert
+	Timeline []AlertTimelineEntry
+}
+
+// AlertTimelineEntry 告警时间线条目
+type AlertTimelineEntry struct {
+	Time       time.Time
+	Action     string
+	ActionName string
+	ToStatus   string
+	Operator   string
+	Comment    string
+}
+
 // Pagination 分页信息
 type Pagination struct {
 	CurrentPage int `json:"current_page"`
@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"air-quality-server/internal/models"
+	"air-quality-server/internal/utils"
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 // getFloatValue 安全获取浮点数值
@@ -32,13 +38,54 @@ func getIntValue(ptr *int) int {
 	return *ptr
 }
 
-// getDeviceStats 获取设备统计信息
-func (h *WebHandlers) getDeviceStats(ctx context.Context) (*DeviceStats, error) {
-	// 获取设备总数
-	total, err := h.services.Device.CountDevices(ctx)
+// parseLocationID 解析查询参数中的location_id，未指定或无效时返回nil
+func parseLocationID(c *gin.Context) *uint64 {
+	id, err := strconv.ParseUint(c.Query("location_id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// locationDeviceIDs 获取位置子树内的设备ID，未指定位置时返回nil表示全部设备
+func (h *WebHandlers) locationDeviceIDs(ctx context.Context, locationID *uint64) ([]string, error) {
+	if locationID == nil || h.services.DeviceScope == nil {
+		return nil, nil
+	}
+	return h.services.DeviceScope.ResolveDeviceIDs(ctx, models.DeviceScopeFilter{LocationID: locationID})
+}
+
+// getLocationOptions 获取位置下拉选项，按路径深度缩进
+func (h *WebHandlers) getLocationOptions(ctx context.Context, selected *uint64) ([]LocationOption, error) {
+	if h.services.Location == nil {
+		return nil, nil
+	}
+	locations, err := h.services.Location.ListLocations(ctx)
 	if err != nil {
 		return nil, err
 	}
+	options := make([]LocationOption, 0, len(locations))
+	for _, l := range locations {
+		depth := strings.Count(l.Path, "/") - 2
+		options = append(options, LocationOption{
+			ID:       l.ID,
+			Label:    strings.Repeat("　", depth) + l.Name,
+			Selected: selected != nil && *selected == l.ID,
+		})
+	}
+	return options, nil
+}
+
+// getDeviceStats 获取设备统计信息，deviceIDs不为nil时只统计其中的设备
+func (h *WebHandlers) getDeviceStats(ctx context.Context, deviceIDs []string) (*DeviceStats, error) {
+	// 获取设备总数
+	total := int64(len(deviceIDs))
+	if deviceIDs == nil {
+		var err error
+		if total, err = h.services.Device.CountDevices(ctx); err != nil {
+			return nil, err
+		}
+	}
 
 	// 获取在线设备数（这里简化处理，实际应该根据设备状态统计）
 	onlineDevices := 0 // TODO: 实现在线设备统计
@@ -53,47 +100,61 @@ func (h *WebHandlers) getDeviceStats(ctx context.Context) (*DeviceStats, error)
 	}, nil
 }
 
-// getLatestData 获取最新数据
-func (h *WebHandlers) getLatestData(ctx context.Context) ([]AirQualityDataSummary, error) {
-	// 从UnifiedSensorData获取最新数据，按设备ID和传感器ID分组
-	latestData, err := h.services.UnifiedSensorData.GetAllData(ctx, 50, 0) // 获取最新50条数据
+// getLatestData 获取最新数据（读取设备影子，不再扫描原始数据表），deviceIDs不为nil时只返回其中的设备
+func (h *WebHandlers) getLatestData(ctx context.Context, deviceIDs []string) ([]AirQualityDataSummary, error) {
+	shadows, err := h.services.DeviceShadow.ListShadows(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	// 按设备ID和传感器ID分组，获取每个组合的最新数据
-	latestByDeviceSensor := make(map[string]models.UnifiedSensorData)
-	for _, data := range latestData {
-		key := data.DeviceID + ":" + data.SensorID
-		if existing, exists := latestByDeviceSensor[key]; !exists || data.Timestamp.After(existing.Timestamp) {
-			latestByDeviceSensor[key] = data
+	var inScope map[string]bool
+	if deviceIDs != nil {
+		inScope = make(map[string]bool, len(deviceIDs))
+		for _, id := range deviceIDs {
+			inScope[id] = true
 		}
 	}
 
 	var summaries []AirQualityDataSummary
-	for _, data := range latestByDeviceSensor {
+	for _, shadow := range shadows {
+		if shadow.LastDataTime == nil {
+			continue
+		}
+		if inScope != nil && !inScope[shadow.DeviceID] {
+			continue
+		}
+
 		// 判断设备状态（基于数据时间戳）
 		status := "online"
-		if time.Since(data.Timestamp) > 5*time.Minute {
+		if time.Since(*shadow.LastDataTime) > 5*time.Minute {
 			status = "offline"
 		}
 
+		var metrics map[string]models.ShadowMetric
+		if shadow.Metrics != nil {
+			if err := json.Unmarshal([]byte(*shadow.Metrics), &metrics); err != nil {
+				h.logger.Warn("解析设备影子失败", utils.String("device_id", shadow.DeviceID), utils.ErrorField(err))
+			}
+		}
+		value := func(metric string) float64 {
+			return metrics[metric].Value
+		}
+
 		summary := AirQualityDataSummary{
-			DeviceID:     data.DeviceID,
-			DeviceName:   data.DeviceID, // 使用设备ID作为名称，后续可以从设备表获取真实名称
-			DeviceType:   string(data.DeviceType),
-			SensorID:     data.SensorID,
-			SensorType:   data.SensorType,
-			PM25:         getFloatValueFromPointer(data.PM25),
-			PM10:         getFloatValueFromPointer(data.PM10),
-			CO2:          getFloatValueFromPointer(data.CO2),
-			Formaldehyde: getFloatValueFromPointer(data.Formaldehyde),
-			Temp:         getFloatValueFromPointer(data.Temperature),
-			Humidity:     getFloatValueFromPointer(data.Humidity),
-			Pressure:     getFloatValueFromPointer(data.Pressure),
-			Battery:      getIntValueFromPointer(data.Battery),
-			DataQuality:  data.DataQuality,
-			CreatedAt:    data.Timestamp,
+			DeviceID:     shadow.DeviceID,
+			DeviceName:   shadow.DeviceID, // 使用设备ID作为名称，后续可以从设备表获取真实名称
+			DeviceType:   string(shadow.DeviceType),
+			SensorID:     shadow.SensorID,
+			SensorType:   shadow.SensorType,
+			PM25:         value("pm25"),
+			PM10:         value("pm10"),
+			CO2:          value("co2"),
+			Formaldehyde: value("formaldehyde"),
+			Temp:         value("temperature"),
+			Humidity:     value("humidity"),
+			Pressure:     value("pressure"),
+			Battery:      int(value("battery")),
+			DataQuality:  shadow.DataQuality,
+			CreatedAt:    *shadow.LastDataTime,
 			Status:       status,
 		}
 		summaries = append(summaries, summary)
@@ -180,95 +241,105 @@ func (h *WebHandlers) convertToChartData(historyData []models.AirQualityData) *C
 	}
 }
 
-// convertToChartDataFromUnified 将统一传感器数据转换为图表数据格式，支持指标选择和传感器过滤
-func (h *WebHandlers) convertToChartDataFromUnified(historyData []models.UnifiedSensorData, metric string, sensorID string) *ChartData {
-	var labels []string
-	var pm25Data, formaldehydeData, tempData, humidityData []float64
+// maxChartPoints 图表每个数据集的最大点数，据此自动选择聚合时间桶
+const maxChartPoints = 300
+
+// chartMetrics 图表支持的指标及样式，按"全部指标"视图中的顺序排列
+var chartMetrics = []struct {
+	metric          string
+	label           string
+	borderColor     string
+	backgroundColor string
+}{
+	{"pm25", "PM2.5", "rgb(255, 99, 132)", "rgba(255, 99, 132, 0.2)"},
+	{"formaldehyde", "甲醛", "rgb(220, 53, 69)", "rgba(220, 53, 69, 0.2)"},
+	{"temperature", "温度", "rgb(255, 205, 86)", "rgba(255, 205, 86, 0.2)"},
+	{"humidity", "湿度", "rgb(75, 192, 192)", "rgba(75, 192, 192, 0.2)"},
+}
 
-	for _, data := range historyData {
-		// 如果指定了传感器ID，则过滤数据
-		if sensorID != "" && data.SensorID != sensorID {
-			continue
-		}
+// getAggregatedChartData 按时间范围自动选择聚合时间桶，查询设备最近hours小时的图表数据
+func (h *WebHandlers) getAggregatedChartData(ctx context.Context, deviceID, sensorID, metric string, hours int) (*ChartData, error) {
+	endTime := time.Now().Unix()
+	startTime := endTime - int64(hours)*int64(time.Hour/time.Second)
 
-		// 格式化时间标签
-		label := data.Timestamp.Format("15:04")
-		labels = append(labels, label)
+	var metrics []string
+	for _, item := range chartMetrics {
+		if metric == "all" || metric == item.metric {
+			metrics = append(metrics, item.metric)
+		}
+	}
 
-		// 添加数据点
-		pm25Data = append(pm25Data, getFloatValueFromPointer(data.PM25))
-		formaldehydeData = append(formaldehydeData, getFloatValueFromPointer(data.Formaldehyde))
-		tempData = append(tempData, getFloatValueFromPointer(data.Temperature))
-		humidityData = append(humidityData, getFloatValueFromPointer(data.Humidity))
+	query := &models.AirQualityDataQuery{
+		DeviceIDs: []string{deviceID},
+		SensorID:  sensorID,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  models.AutoAggregationInterval(startTime, endTime, maxChartPoints),
+		Metrics:   metrics,
+	}
+	series, err := h.services.UnifiedSensorData.AggregateData(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	var datasets []Dataset
+	interval, _ := models.ParseAggregationInterval(query.Interval)
+	return convertToChartDataFromSeries(series, metrics, interval, hours > 24), nil
+}
 
-	// 根据选择的指标添加数据集
-	switch metric {
-	case "pm25":
-		datasets = append(datasets, Dataset{
-			Label:           "PM2.5",
-			Data:            pm25Data,
-			BorderColor:     "rgb(255, 99, 132)",
-			BackgroundColor: "rgba(255, 99, 132, 0.2)",
-			Fill:            false,
-		})
-	case "formaldehyde":
-		datasets = append(datasets, Dataset{
-			Label:           "甲醛",
-			Data:            formaldehydeData,
-			BorderColor:     "rgb(220, 53, 69)",
-			BackgroundColor: "rgba(220, 53, 69, 0.2)",
-			Fill:            false,
-		})
-	case "temperature":
-		datasets = append(datasets, Dataset{
-			Label:           "温度",
-			Data:            tempData,
-			BorderColor:     "rgb(255, 205, 86)",
-			BackgroundColor: "rgba(255, 205, 86, 0.2)",
-			Fill:            false,
-		})
-	case "humidity":
+// convertToChartDataFromSeries 将聚合序列转换为图表数据格式，各指标按时间桶对齐，缺失的桶记为0
+func convertToChartDataFromSeries(series []models.AggregatedSeries, metrics []string, interval time.Duration, multiDay bool) *ChartData {
+	values := make(map[string]map[int64]float64, len(series))
+	var times []int64
+	seen := make(map[int64]bool)
+	for _, s := range series {
+		points := make(map[int64]float64, len(s.Points))
+		for _, point := range s.Points {
+			t := point.Time.Unix()
+			points[t] = point.Value
+			if !seen[t] {
+				seen[t] = true
+				times = append(times, t)
+			}
+		}
+		values[s.Metric] = points
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	layout := "15:04"
+	switch {
+	case interval >= 24*time.Hour:
+		layout = "01-02"
+	case multiDay:
+		layout = "01-02 15:04"
+	}
+	labels := make([]string, 0, len(times))
+	for _, t := range times {
+		labels = append(labels, time.Unix(t, 0).Format(layout))
+	}
+
+	datasets := []Dataset{}
+	for _, item := range chartMetrics {
+		selected := false
+		for _, metric := range metrics {
+			if metric == item.metric {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+		data := make([]float64, 0, len(times))
+		for _, t := range times {
+			data = append(data, values[item.metric][t])
+		}
 		datasets = append(datasets, Dataset{
-			Label:           "湿度",
-			Data:            humidityData,
-			BorderColor:     "rgb(75, 192, 192)",
-			BackgroundColor: "rgba(75, 192, 192, 0.2)",
+			Label:           item.label,
+			Data:            data,
+			BorderColor:     item.borderColor,
+			BackgroundColor: item.backgroundColor,
 			Fill:            false,
 		})
-	default: // "all"
-		datasets = []Dataset{
-			{
-				Label:           "PM2.5",
-				Data:            pm25Data,
-				BorderColor:     "rgb(255, 99, 132)",
-				BackgroundColor: "rgba(255, 99, 132, 0.2)",
-				Fill:            false,
-			},
-			{
-				Label:           "甲醛",
-				Data:            formaldehydeData,
-				BorderColor:     "rgb(220, 53, 69)",
-				BackgroundColor: "rgba(220, 53, 69, 0.2)",
-				Fill:            false,
-			},
-			{
-				Label:           "温度",
-				Data:            tempData,
-				BorderColor:     "rgb(255, 205, 86)",
-				BackgroundColor: "rgba(255, 205, 86, 0.2)",
-				Fill:            false,
-			},
-			{
-				Label:           "湿度",
-				Data:            humidityData,
-				BorderColor:     "rgb(75, 192, 192)",
-				BackgroundColor: "rgba(75, 192, 192, 0.2)",
-				Fill:            false,
-			},
-		}
 	}
 
 	return &ChartData{
@@ -315,11 +386,3 @@ func (h *WebHandlers) convertToCSV(data []models.UnifiedSensorData) string {
 
 	return csv
 }
-
-// getFloatValueFromPointer 从指针获取float64值，处理nil指针
-func getFloatValueFromPointer(ptr *float64) float64 {
-	if ptr == nil {
-		return 0
-	}
-	return *ptr
-}
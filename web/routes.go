@@ -55,6 +55,9 @@ func SetupRoutes(router *gin.Engine, services *services.Services, logger utils.L
 			c.Redirect(302, "/dashboard")
 		})
 
+		// 登录
+		webGroup.GET("/login", webHandlers.Login)
+
 		// 仪表板
 		webGroup.GET("/dashboard", webHandlers.Dashboard)
 
@@ -100,5 +100,14 @@ function updateCurrentTime() {
     });
 }
 
+// 退出登录：注销令牌并清除访问令牌Cookie
+function logout() {
+    fetch('/api/v1/auth/logout', { method: 'POST' })
+        .finally(() => {
+            window.location.href = '/login';
+        });
+}
+
 // 导出全局函数
 window.Utils = Utils;
+window.logout = logout;